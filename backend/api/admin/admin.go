@@ -2,14 +2,33 @@ package admin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"skillhub/config"
 	"skillhub/models"
 	"skillhub/services/analytics"
+	"skillhub/services/bundle"
+	"skillhub/services/coupon"
+	"skillhub/services/earnings"
+	"skillhub/services/entitlement"
+	"skillhub/services/invoice"
+	"skillhub/services/ledger"
+	"skillhub/services/orders"
+	svcpayment "skillhub/services/payment"
+	"skillhub/services/pricing"
+	"skillhub/services/reconcile"
+	"skillhub/services/refund"
+	"skillhub/services/risk"
+	"skillhub/services/tax"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ListSkills 列出所有skills
@@ -24,7 +43,7 @@ import (
 // @Param sort_by query string false "排序字段" Enums(name,downloads_count,purchases_count,rating,price,created_at,updated_at) default(created_at)
 // @Param sort_order query string false "排序方向" Enums(asc,desc) default(desc)
 // @Param category_id query string false "分类ID"
-// @Param price_type query string false "价格类型" Enums(free,paid)
+// @Param price_type query string false "价格类型" Enums(free,paid,subscription)
 // @Param is_active query bool false "是否激活"
 // @Success 200 {object} map[string]interface{}
 // @Router /admin/skills [get]
@@ -76,17 +95,17 @@ func ListSkills(c *gin.Context) {
 	query.Count(&total)
 
 	// 排序
-	validSortFields := map[string]bool{
-		"name":             true,
-		"downloads_count":  true,
-		"purchases_count":  true,
-		"rating":          true,
-		"price":           true,
-		"created_at":      true,
-		"updated_at":      true,
+	validSortFields := map[string]string{
+		"name":             "name",
+		"downloads_count":  "downloads_count",
+		"purchases_count":  "purchases_count",
+		"rating":          "rating",
+		"price":           "price_amount_minor",
+		"created_at":      "created_at",
+		"updated_at":      "updated_at",
 	}
-	if validSortFields[sortBy] {
-		orderClause := sortBy + " " + sortOrder
+	if column, ok := validSortFields[sortBy]; ok {
+		orderClause := column + " " + sortOrder
 		query = query.Order(orderClause)
 	}
 
@@ -112,8 +131,9 @@ type UpdateSkillRequest struct {
 	Name        *string  `json:"name,omitempty"`
 	Description *string  `json:"description,omitempty"`
 	CategoryID  *string  `json:"category_id,omitempty"`
+	PublisherID *string  `json:"publisher_id,omitempty"` // 发布者用户ID，空字符串表示清除
 	PriceType   *string  `json:"price_type,omitempty"`
-	Price       *float64 `json:"price,omitempty"`
+	Price       *models.Money `json:"price,omitempty"` // {"amount":"29.99","currency":"USD"}
 	IsActive    *bool    `json:"is_active,omitempty"`
 }
 
@@ -156,40 +176,2007 @@ func UpdateSkill(c *gin.Context) {
 		return
 	}
 
-	// 更新字段
-	if req.Name != nil {
-		skill.Name = *req.Name
-	}
-	if req.Description != nil {
-		skill.Description = *req.Description
+	// 更新字段
+	if req.Name != nil {
+		skill.Name = *req.Name
+	}
+	if req.Description != nil {
+		skill.Description = *req.Description
+	}
+	if req.CategoryID != nil {
+		if catID, err := uuid.Parse(*req.CategoryID); err == nil {
+			skill.CategoryID = &catID
+		}
+	}
+	if req.PublisherID != nil {
+		if *req.PublisherID == "" {
+			skill.PublisherID = nil
+		} else {
+			publisherID, err := uuid.Parse(*req.PublisherID)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid publisher ID"})
+				return
+			}
+			var count int64
+			db.Model(&models.User{}).Where("id = ?", publisherID).Count(&count)
+			if count == 0 {
+				c.JSON(400, gin.H{"error": "Publisher not found"})
+				return
+			}
+			skill.PublisherID = &publisherID
+		}
+	}
+	if req.PriceType != nil {
+		skill.PriceType = models.PriceType(*req.PriceType)
+	}
+	if req.Price != nil {
+		currency, err := pricing.NormalizeCurrency(req.Price.Currency)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Price.Amount < 0 {
+			c.JSON(400, gin.H{"error": "Invalid price"})
+			return
+		}
+		skill.Price = models.NewMoney(req.Price.Amount, currency)
+	}
+	if req.IsActive != nil {
+		skill.IsActive = *req.IsActive
+	}
+
+	if err := db.Save(&skill).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update skill"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    skill,
+	})
+}
+
+// SkillPriceRequest 地区价格
+type SkillPriceRequest struct {
+	Region string       `json:"region" binding:"required"`
+	Price  models.Money `json:"price"` // {"amount":"9.99","currency":"USD"}
+}
+
+// SetSkillPricesRequest 设置技能地区价格表请求
+type SetSkillPricesRequest struct {
+	Prices []SkillPriceRequest `json:"prices" binding:"dive"`
+}
+
+// SetSkillPrices 设置技能的地区价格表
+// @Summary 设置地区价格
+// @Description 整体替换技能的地区价格表，未列出的地区使用基础价格
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "技能ID"
+// @Param request body SetSkillPricesRequest true "地区价格"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/skills/{id}/prices [put]
+func SetSkillPrices(c *gin.Context) {
+	skillID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid skill ID"})
+		return
+	}
+
+	var req SetSkillPricesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var skill models.Skill
+	if err := db.First(&skill, "id = ?", skillID).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Skill not found"})
+		return
+	}
+
+	prices := make([]models.SkillPrice, 0, len(req.Prices))
+	seen := make(map[string]bool)
+	for _, p := range req.Prices {
+		currency, err := pricing.NormalizeCurrency(p.Price.Currency)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		if p.Price.Amount < 0 {
+			c.JSON(400, gin.H{"error": "Invalid price for region " + p.Region})
+			return
+		}
+		region := strings.ToUpper(strings.TrimSpace(p.Region))
+		if seen[region] {
+			c.JSON(400, gin.H{"error": "Duplicate region " + region})
+			return
+		}
+		seen[region] = true
+		prices = append(prices, models.SkillPrice{
+			ID:      uuid.New(),
+			SkillID: skill.ID,
+			Region:  region,
+			Price:   models.NewMoney(p.Price.Amount, currency),
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("skill_id = ?", skill.ID).Delete(&models.SkillPrice{}).Error; err != nil {
+			return err
+		}
+		if len(prices) == 0 {
+			return nil
+		}
+		return tx.Create(&prices).Error
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update prices"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    prices,
+	})
+}
+
+// ListExchangeRates 获取汇率表
+// @Summary 汇率列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/fx-rates [get]
+func ListExchangeRates(c *gin.Context) {
+	var rates []models.ExchangeRate
+	models.GetDB().Order("base_currency ASC, quote_currency ASC").Find(&rates)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    rates,
+	})
+}
+
+// ListTaxRules 获取当前加载的税率规则
+// @Summary 税率规则
+// @Description 列出启动时从 TAX_RULES_FILE 加载的税率规则，修改规则需更新文件并重启服务
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/tax-rules [get]
+func ListTaxRules(c *gin.Context) {
+	rules := tax.Current().All()
+	if rules == nil {
+		rules = []tax.Rule{}
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    rules,
+	})
+}
+
+// ExchangeRateRequest 汇率
+type ExchangeRateRequest struct {
+	BaseCurrency  string  `json:"base_currency" binding:"required"`
+	QuoteCurrency string  `json:"quote_currency" binding:"required"`
+	Rate          float64 `json:"rate" binding:"required,gt=0"`
+}
+
+// UpdateExchangeRatesRequest 更新汇率请求
+type UpdateExchangeRatesRequest struct {
+	Rates []ExchangeRateRequest `json:"rates" binding:"required,dive"`
+}
+
+// UpdateExchangeRates 新增或更新汇率
+// @Summary 更新汇率
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body UpdateExchangeRatesRequest true "汇率"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/fx-rates [put]
+func UpdateExchangeRates(c *gin.Context) {
+	var req UpdateExchangeRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	rates := make([]pricing.Rate, 0, len(req.Rates))
+	for _, r := range req.Rates {
+		rates = append(rates, pricing.Rate{Base: r.BaseCurrency, Quote: r.QuoteCurrency, Rate: r.Rate})
+	}
+	if err := pricing.SetRates(rates, pricing.SourceAdmin); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gin.H{"updated": len(rates)},
+	})
+}
+
+// ImportExchangeRates 从CSV导入汇率
+// @Summary 导入汇率
+// @Description 上传CSV文件（字段file）或直接以CSV作为请求体，每行 base,quote,rate
+// @Tags admin
+// @Accept multipart/form-data
+// @Produce json
+// @Security Bearer
+// @Param file formData file false "汇率CSV文件"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/fx-rates/import [post]
+func ImportExchangeRates(c *gin.Context) {
+	reader := io.Reader(c.Request.Body)
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Failed to read file"})
+			return
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	count, err := pricing.ImportRates(reader, pricing.SourceFile)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gin.H{"imported": count},
+	})
+}
+
+// ListPaymentRoutes 获取支付路由规则
+// @Summary 支付路由规则列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/payment-routes [get]
+func ListPaymentRoutes(c *gin.Context) {
+	var routes []models.PaymentRoute
+	models.GetDB().Order("priority ASC, created_at ASC").Find(&routes)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"routes":    routes,
+			"providers": svcpayment.EnabledProviders(*config.AppConfig),
+		},
+	})
+}
+
+// PaymentRouteRequest 支付路由规则
+type PaymentRouteRequest struct {
+	Priority    int    `json:"priority"`
+	Currency    string `json:"currency"`   // 为空匹配所有币种
+	Region      string `json:"region"`     // 为空匹配所有地区
+	MinAmount   string `json:"min_amount"` // 十进制金额，需同时指定币种
+	MaxAmount   string `json:"max_amount"`
+	PaymentType string `json:"payment_type" binding:"required"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// SetPaymentRoutesRequest 设置支付路由规则请求
+type SetPaymentRoutesRequest struct {
+	Routes []PaymentRouteRequest `json:"routes" binding:"dive"`
+}
+
+// SetPaymentRoutes 设置支付路由规则
+// @Summary 设置支付路由规则
+// @Description 整体替换支付路由规则，买家未指定支付方式时按优先级（数值小者优先）选择第一条命中的规则
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body SetPaymentRoutesRequest true "路由规则"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/payment-routes [put]
+func SetPaymentRoutes(c *gin.Context) {
+	var req SetPaymentRoutesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	routes := make([]models.PaymentRoute, 0, len(req.Routes))
+	for _, r := range req.Routes {
+		paymentType := svcpayment.PaymentType(strings.ToLower(strings.TrimSpace(r.PaymentType)))
+		if !svcpayment.IsKnownProvider(paymentType) {
+			c.JSON(400, gin.H{"error": "Unknown payment type " + r.PaymentType})
+			return
+		}
+
+		route := models.PaymentRoute{
+			ID:          uuid.New(),
+			Priority:    r.Priority,
+			Region:      pricing.NormalizeRegion(r.Region),
+			PaymentType: string(paymentType),
+			IsActive:    r.IsActive == nil || *r.IsActive,
+		}
+		if r.Currency != "" {
+			currency, err := pricing.NormalizeCurrency(r.Currency)
+			if err != nil {
+				c.JSON(400, gin.H{"error": err.Error()})
+				return
+			}
+			if !svcpayment.SupportsCurrency(paymentType, currency) {
+				c.JSON(400, gin.H{"error": string(paymentType) + " does not accept " + currency})
+				return
+			}
+			route.Currency = currency
+		}
+
+		if r.MinAmount != "" || r.MaxAmount != "" {
+			if route.Currency == "" {
+				c.JSON(400, gin.H{"error": "Amount bounds require a currency"})
+				return
+			}
+			bounds := []*int64{&route.MinAmount, &route.MaxAmount}
+			for i, amount := range []string{r.MinAmount, r.MaxAmount} {
+				if amount == "" {
+					continue
+				}
+				m, err := models.ParseMoney(amount, route.Currency)
+				if err != nil || m.Amount < 0 {
+					c.JSON(400, gin.H{"error": "Invalid amount " + amount})
+					return
+				}
+				*bounds[i] = m.Amount
+			}
+			if route.MaxAmount > 0 && route.MaxAmount < route.MinAmount {
+				c.JSON(400, gin.H{"error": "max_amount must not be less than min_amount"})
+				return
+			}
+		}
+		routes = append(routes, route)
+	}
+
+	err := models.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.PaymentRoute{}).Error; err != nil {
+			return err
+		}
+		if len(routes) == 0 {
+			return nil
+		}
+		return tx.Create(&routes).Error
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update payment routes"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    routes,
+	})
+}
+
+// ListCoupons 获取优惠券列表
+// @Summary 优惠券列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param search query string false "按优惠码搜索"
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/coupons [get]
+func ListCoupons(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.Coupon{})
+	if search := c.Query("search"); search != "" {
+		query = query.Where("code ILIKE ?", "%"+search+"%")
+	}
+	if isActive := c.Query("is_active"); isActive == "true" || isActive == "false" {
+		query = query.Where("is_active = ?", isActive == "true")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var coupons []models.Coupon
+	query.Preload("Skills").Preload("Categories").Preload("Publishers").
+		Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&coupons)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      coupons,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// CouponRequest 创建/更新优惠券请求
+type CouponRequest struct {
+	Code           string        `json:"code" binding:"required"`
+	Description    string        `json:"description"`
+	Type           string        `json:"type" binding:"required"` // percent 或 fixed
+	PercentOff     int           `json:"percent_off"`             // 1-100
+	AmountOff      *models.Money `json:"amount_off,omitempty"`    // {"amount":"10.00","currency":"USD"}
+	StartsAt       *time.Time    `json:"starts_at,omitempty"`
+	EndsAt         *time.Time    `json:"ends_at,omitempty"`
+	MaxUses        int           `json:"max_uses"`          // 0为不限
+	MaxUsesPerUser int           `json:"max_uses_per_user"` // 0为不限
+	IsActive       *bool         `json:"is_active,omitempty"`
+	SkillIDs       []uuid.UUID   `json:"skill_ids"` // 以下范围均为空时适用于所有技能
+	CategoryIDs    []uuid.UUID   `json:"category_ids"`
+	PublisherIDs   []uuid.UUID   `json:"publisher_ids"`
+}
+
+// CreateCoupon 创建优惠券
+// @Summary 创建优惠券
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CouponRequest true "优惠券"
+// @Success 200 {object} models.Coupon
+// @Router /admin/coupons [post]
+func CreateCoupon(c *gin.Context) {
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	item := models.Coupon{ID: uuid.New()}
+	saveCoupon(c, &item, &req, true)
+}
+
+// UpdateCoupon 更新优惠券（整体替换配置，已使用次数保留）
+// @Summary 更新优惠券
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "优惠券ID"
+// @Param request body CouponRequest true "优惠券"
+// @Success 200 {object} models.Coupon
+// @Router /admin/coupons/{id} [put]
+func UpdateCoupon(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.Coupon
+	if err := models.GetDB().First(&item, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Coupon not found"})
+		return
+	}
+	saveCoupon(c, &item, &req, false)
+}
+
+// saveCoupon 校验请求并保存优惠券及其适用范围
+func saveCoupon(c *gin.Context, item *models.Coupon, req *CouponRequest, create bool) {
+	item.Code = coupon.NormalizeCode(req.Code)
+	item.Description = req.Description
+	item.Type = models.CouponType(strings.ToLower(strings.TrimSpace(req.Type)))
+	item.PercentOff = 0
+	item.AmountOff = models.Money{}
+	if item.Type == models.CouponTypePercent {
+		item.PercentOff = req.PercentOff
+	}
+	if item.Type == models.CouponTypeFixed && req.AmountOff != nil {
+		currency, err := pricing.NormalizeCurrency(req.AmountOff.Currency)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		item.AmountOff = models.NewMoney(req.AmountOff.Amount, currency)
+	}
+	item.StartsAt = req.StartsAt
+	item.EndsAt = req.EndsAt
+	item.MaxUses = req.MaxUses
+	item.MaxUsesPerUser = req.MaxUsesPerUser
+	item.IsActive = req.IsActive == nil || *req.IsActive
+	if err := coupon.CheckDefinition(item); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var skills []models.Skill
+	var categories []models.SkillCategory
+	var publishers []models.User
+	if len(req.SkillIDs) > 0 {
+		db.Where("id IN ?", req.SkillIDs).Find(&skills)
+	}
+	if len(req.CategoryIDs) > 0 {
+		db.Where("id IN ?", req.CategoryIDs).Find(&categories)
+	}
+	if len(req.PublisherIDs) > 0 {
+		db.Where("id IN ?", req.PublisherIDs).Find(&publishers)
+	}
+	if len(skills) != len(req.SkillIDs) || len(categories) != len(req.CategoryIDs) || len(publishers) != len(req.PublisherIDs) {
+		c.JSON(400, gin.H{"error": "Unknown skill, category or publisher in coupon scope"})
+		return
+	}
+
+	var existing int64
+	db.Model(&models.Coupon{}).Where("code = ? AND id <> ?", item.Code, item.ID).Count(&existing)
+	if existing > 0 {
+		c.JSON(400, gin.H{"error": "Coupon code already exists"})
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		save := tx.Omit("Skills", "Categories", "Publishers")
+		if create {
+			if err := save.Create(item).Error; err != nil {
+				return err
+			}
+		} else if err := save.Save(item).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(item).Association("Skills").Replace(skills); err != nil {
+			return err
+		}
+		if err := tx.Model(item).Association("Categories").Replace(categories); err != nil {
+			return err
+		}
+		return tx.Model(item).Association("Publishers").Replace(publishers)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to save coupon"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    item,
+	})
+}
+
+// DeleteCoupon 删除优惠券，已被使用过的优惠券只停用以保留使用记录
+// @Summary 删除优惠券
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "优惠券ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/coupons/{id} [delete]
+func DeleteCoupon(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+
+	db := models.GetDB()
+	var item models.Coupon
+	if err := db.First(&item, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Coupon not found"})
+		return
+	}
+
+	var redemptions int64
+	db.Model(&models.CouponRedemption{}).Where("coupon_id = ?", item.ID).Count(&redemptions)
+	deleted := redemptions == 0
+	if deleted {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&item).Association("Skills").Clear(); err != nil {
+				return err
+			}
+			if err := tx.Model(&item).Association("Categories").Clear(); err != nil {
+				return err
+			}
+			if err := tx.Model(&item).Association("Publishers").Clear(); err != nil {
+				return err
+			}
+			return tx.Delete(&item).Error
+		})
+	} else {
+		err = db.Model(&item).Update("is_active", false).Error
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete coupon"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gin.H{"deleted": deleted, "deactivated": !deleted},
+	})
+}
+
+// ListCouponRedemptions 优惠券使用记录及汇总
+// @Summary 优惠券使用报表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "优惠券ID"
+// @Param status query string false "使用状态" Enums(reserved,redeemed,released)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/coupons/{id}/redemptions [get]
+func ListCouponRedemptions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid coupon ID"})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	db := models.GetDB()
+	var item models.Coupon
+	if err := db.First(&item, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Coupon not found"})
+		return
+	}
+
+	report, err := coupon.BuildReport(db, &item)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to build coupon report"})
+		return
+	}
+
+	query := db.Model(&models.CouponRedemption{}).Where("coupon_id = ?", item.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	query.Count(&total)
+
+	var redemptions []models.CouponRedemption
+	query.Preload("Order").Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&redemptions)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"summary":   report,
+			"list":      redemptions,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ListBundles 获取套餐列表
+// @Summary 套餐列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param publisher_id query string false "发布者ID，platform 表示平台套餐"
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/bundles [get]
+func ListBundles(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.Bundle{})
+	if publisherID := c.Query("publisher_id"); publisherID == "platform" {
+		query = query.Where("publisher_id IS NULL")
+	} else if id, err := uuid.Parse(publisherID); err == nil {
+		query = query.Where("publisher_id = ?", id)
+	}
+	if isActive := c.Query("is_active"); isActive == "true" || isActive == "false" {
+		query = query.Where("is_active = ?", isActive == "true")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var bundles []models.Bundle
+	query.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).Preload("Items.Skill").
+		Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&bundles)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      bundles,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// CreateBundle 创建平台套餐
+// @Summary 创建套餐
+// @Description 平台套餐可包含任意发布者上架的付费技能，至少2个
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body bundle.Definition true "套餐"
+// @Success 200 {object} models.Bundle
+// @Router /admin/bundles [post]
+func CreateBundle(c *gin.Context) {
+	var def bundle.Definition
+	if err := c.ShouldBindJSON(&def); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	item := models.Bundle{ID: uuid.New()}
+	saveBundle(c, &item, def, true)
+}
+
+// UpdateBundle 更新套餐（整体替换定义）。发布者的套餐仍只能包含该发布者的技能
+// @Summary 更新套餐
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "套餐ID"
+// @Param request body bundle.Definition true "套餐"
+// @Success 200 {object} models.Bundle
+// @Router /admin/bundles/{id} [put]
+func UpdateBundle(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid bundle ID"})
+		return
+	}
+
+	var def bundle.Definition
+	if err := c.ShouldBindJSON(&def); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item models.Bundle
+	if err := models.GetDB().First(&item, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Bundle not found"})
+		return
+	}
+	saveBundle(c, &item, def, false)
+}
+
+// saveBundle 保存套餐并返回结果
+func saveBundle(c *gin.Context, item *models.Bundle, def bundle.Definition, create bool) {
+	if err := bundle.Save(models.GetDB(), item, def, create); err != nil {
+		if bundle.IsDefinitionError(err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to save bundle"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    item,
+	})
+}
+
+// DeleteBundle 删除套餐，已售出的套餐只停用以保留订单信息
+// @Summary 删除套餐
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "套餐ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/bundles/{id} [delete]
+func DeleteBundle(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid bundle ID"})
+		return
+	}
+
+	db := models.GetDB()
+	var item models.Bundle
+	if err := db.First(&item, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Bundle not found"})
+		return
+	}
+
+	deleted, err := bundle.Delete(db, &item)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gin.H{"deleted": deleted, "deactivated": !deleted},
+	})
+}
+
+// ListSkillPlans 获取技能的订阅方案
+// @Summary 订阅方案列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "技能ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/skills/{id}/plans [get]
+func ListSkillPlans(c *gin.Context) {
+	skillID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid skill ID"})
+		return
+	}
+
+	var plans []models.SubscriptionPlan
+	if err := models.GetDB().Where("skill_id = ?", skillID).Order("created_at ASC").Find(&plans).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load plans"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    plans,
+	})
+}
+
+// SubscriptionPlanRequest 创建/更新订阅方案请求
+type SubscriptionPlanRequest struct {
+	Interval string        `json:"interval"`            // month 或 year，创建后不可修改
+	Price    *models.Money `json:"price,omitempty"`     // {"amount":"9.99","currency":"USD"}
+	IsActive *bool         `json:"is_active,omitempty"` // 停用后不再接受新订阅，已有订阅照常续费
+}
+
+// CreateSkillPlan 为技能创建订阅方案
+// @Summary 创建订阅方案
+// @Description 技能需先将价格类型设为subscription
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "技能ID"
+// @Param request body SubscriptionPlanRequest true "订阅方案"
+// @Success 200 {object} models.SubscriptionPlan
+// @Router /admin/skills/{id}/plans [post]
+func CreateSkillPlan(c *gin.Context) {
+	skillID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid skill ID"})
+		return
+	}
+
+	var req SubscriptionPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var skill models.Skill
+	if err := db.First(&skill, "id = ?", skillID).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Skill not found"})
+		return
+	}
+	if skill.PriceType != models.PriceTypeSubscription {
+		c.JSON(400, gin.H{"error": "Skill price type must be subscription"})
+		return
+	}
+
+	interval := models.BillingInterval(req.Interval)
+	if !interval.IsValid() {
+		c.JSON(400, gin.H{"error": "Interval must be month or year"})
+		return
+	}
+	if req.Price == nil {
+		c.JSON(400, gin.H{"error": "Price is required"})
+		return
+	}
+	price, ok := planPrice(c, req.Price)
+	if !ok {
+		return
+	}
+
+	plan := models.SubscriptionPlan{
+		ID:       uuid.New(),
+		SkillID:  skill.ID,
+		Interval: interval,
+		Price:    price,
+		IsActive: req.IsActive == nil || *req.IsActive,
+	}
+	if err := db.Create(&plan).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to create plan"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    plan,
+	})
+}
+
+// UpdateSubscriptionPlan 更新订阅方案的价格或启用状态
+// @Summary 更新订阅方案
+// @Description 新价格用于之后的新订阅和续费订单；已在Stripe/PayPal自动续费的订阅仍按原价扣款
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "方案ID"
+// @Param request body SubscriptionPlanRequest true "订阅方案"
+// @Success 200 {object} models.SubscriptionPlan
+// @Router /admin/subscription-plans/{id} [put]
+func UpdateSubscriptionPlan(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid plan ID"})
+		return
+	}
+
+	var req SubscriptionPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var plan models.SubscriptionPlan
+	if err := db.First(&plan, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Plan not found"})
+		return
+	}
+
+	if req.Interval != "" && models.BillingInterval(req.Interval) != plan.Interval {
+		c.JSON(400, gin.H{"error": "Interval cannot be changed, create a new plan instead"})
+		return
+	}
+	if req.Price != nil {
+		price, ok := planPrice(c, req.Price)
+		if !ok {
+			return
+		}
+		if price != plan.Price {
+			plan.Price = price
+			// PayPal计费方案价格固定，新订阅时按新价格重新创建
+			plan.PayPalPlanID = ""
+		}
+	}
+	if req.IsActive != nil {
+		plan.IsActive = *req.IsActive
+	}
+
+	if err := db.Save(&plan).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update plan"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    plan,
+	})
+}
+
+// planPrice 校验订阅方案价格
+func planPrice(c *gin.Context, price *models.Money) (models.Money, bool) {
+	currency, err := pricing.NormalizeCurrency(price.Currency)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return models.Money{}, false
+	}
+	if price.Amount <= 0 {
+		c.JSON(400, gin.H{"error": "Invalid price"})
+		return models.Money{}, false
+	}
+	return models.NewMoney(price.Amount, currency), true
+}
+
+// ListSubscriptions 订阅列表
+// @Summary 订阅列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param status query string false "订阅状态" Enums(incomplete,active,past_due,cancelled,expired)
+// @Param skill_id query string false "技能ID"
+// @Param user_id query string false "用户ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/subscriptions [get]
+func ListSubscriptions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.Subscription{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if skillID, err := uuid.Parse(c.Query("skill_id")); err == nil {
+		query = query.Where("skill_id = ?", skillID)
+	}
+	if userID, err := uuid.Parse(c.Query("user_id")); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var subs []models.Subscription
+	query.Preload("Plan").Preload("Skill").
+		Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&subs)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      subs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ListEntitlements 使用权列表
+// @Summary 使用权列表
+// @Description 按用户或技能查看使用权，包括已撤销和已过期的记录
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param user_id query string false "用户ID"
+// @Param skill_id query string false "技能ID"
+// @Param source query string false "来源" Enums(purchase,subscription,admin)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/entitlements [get]
+func ListEntitlements(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.UserEntitlement{})
+	if userID, err := uuid.Parse(c.Query("user_id")); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if skillID, err := uuid.Parse(c.Query("skill_id")); err == nil {
+		query = query.Where("skill_id = ?", skillID)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var grants []models.UserEntitlement
+	query.Preload("Skill").Preload("User").
+		Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&grants)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      grants,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// GrantEntitlementRequest 手动授予使用权请求
+type GrantEntitlementRequest struct {
+	UserID    uuid.UUID  `json:"user_id" binding:"required"`
+	SkillID   uuid.UUID  `json:"skill_id" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空表示永久
+	Reason    string     `json:"reason" binding:"required"`
+}
+
+// GrantEntitlement 手动授予使用权
+// @Summary 手动授予使用权
+// @Description 为用户授予技能的使用权（如补偿、合作赠送），需填写原因
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body GrantEntitlementRequest true "授予信息"
+// @Success 200 {object} models.UserEntitlement
+// @Router /admin/entitlements [post]
+func GrantEntitlement(c *gin.Context) {
+	var req GrantEntitlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(400, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	db := models.GetDB()
+	if err := db.First(&models.User{}, "id = ?", req.UserID).Error; err != nil {
+		c.JSON(404, gin.H{"error": "User not found"})
+		return
+	}
+	if err := db.First(&models.Skill{}, "id = ?", req.SkillID).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Skill not found"})
+		return
+	}
+
+	var adminID *uuid.UUID
+	if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		adminID = &uid
+	}
+	grant, err := entitlement.Grant(db, req.UserID, req.SkillID, adminID, req.ExpiresAt, strings.TrimSpace(req.Reason))
+	if err != nil {
+		if errors.Is(err, entitlement.ErrReasonRequired) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to grant entitlement"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    grant,
+	})
+}
+
+// RevokeEntitlementRequest 撤销使用权请求
+type RevokeEntitlementRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// RevokeEntitlement 撤销使用权
+// @Summary 撤销使用权
+// @Description 撤销任意来源的使用权，需填写原因。订阅的使用权在下次续费成功后恢复
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "使用权ID"
+// @Param request body RevokeEntitlementRequest true "撤销原因"
+// @Success 200 {object} models.UserEntitlement
+// @Router /admin/entitlements/{id}/revoke [post]
+func RevokeEntitlement(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid entitlement ID"})
+		return
+	}
+
+	var req RevokeEntitlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var adminID *uuid.UUID
+	if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		adminID = &uid
+	}
+	grant, err := entitlement.Revoke(models.GetDB(), id, adminID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		switch {
+		case errors.Is(err, entitlement.ErrNotFound):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, entitlement.ErrAlreadyRevoked), errors.Is(err, entitlement.ErrReasonRequired):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": "Failed to revoke entitlement"})
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    grant,
+	})
+}
+
+// ListInvoices 发票列表
+// @Summary 发票列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param status query string false "发票状态" Enums(issued,void)
+// @Param order_id query string false "订单ID"
+// @Param user_id query string false "用户ID"
+// @Param invoice_no query string false "发票号"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/invoices [get]
+func ListInvoices(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.Invoice{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if orderID, err := uuid.Parse(c.Query("order_id")); err == nil {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if userID, err := uuid.Parse(c.Query("user_id")); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if invoiceNo := c.Query("invoice_no"); invoiceNo != "" {
+		query = query.Where("invoice_no = ?", invoiceNo)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var invoices []models.Invoice
+	query.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("line_no") }).
+		Order("issued_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&invoices)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      invoices,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// VoidInvoiceRequest 作废发票请求
+type VoidInvoiceRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// VoidInvoice 作废发票
+// @Summary 作废发票
+// @Description 作废后订单没有有效发票，可通过重开补开新发票
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "发票ID"
+// @Param request body VoidInvoiceRequest true "作废原因"
+// @Success 200 {object} models.Invoice
+// @Router /admin/invoices/{id}/void [post]
+func VoidInvoice(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+	var req VoidInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var adminID *uuid.UUID
+	if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		adminID = &uid
+	}
+	result, err := invoice.Void(models.GetDB(), id, adminID, strings.TrimSpace(req.Reason))
+	if err != nil {
+		c.JSON(invoiceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
+
+// ReissueInvoiceRequest 重开发票请求
+type ReissueInvoiceRequest struct {
+	Reason string         `json:"reason" binding:"required"`
+	Buyer  *invoice.Buyer `json:"buyer"` // 为空时使用用户当前的开票资料
+}
+
+// ReissueInvoice 重开发票
+// @Summary 重开发票
+// @Description 作废原发票并以新发票号重新开具，可修改买方抬头和税号
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "发票ID"
+// @Param request body ReissueInvoiceRequest true "重开信息"
+// @Success 200 {object} models.Invoice
+// @Router /admin/invoices/{id}/reissue [post]
+func ReissueInvoice(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+	var req ReissueInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var adminID *uuid.UUID
+	if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		adminID = &uid
+	}
+	result, err := invoice.Reissue(models.GetDB(), config.AppConfig.Invoice, id, adminID, strings.TrimSpace(req.Reason), req.Buyer)
+	if err != nil {
+		c.JSON(invoiceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
+
+// invoiceErrorStatus 发票错误对应的HTTP状态码
+func invoiceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, invoice.ErrNotFound):
+		return 404
+	case errors.Is(err, invoice.ErrAlreadyVoid), errors.Is(err, invoice.ErrReasonRequired),
+		errors.Is(err, invoice.ErrInvalidBuyer), errors.Is(err, invoice.ErrNotInvoiceable):
+		return 400
+	}
+	return 500
+}
+
+// ListCommissionRules 抽成规则列表
+// @Summary 抽成规则列表
+// @Description 列出按技能和按发布者设置的抽成比例，以及全局默认比例
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/commission-rules [get]
+func ListCommissionRules(c *gin.Context) {
+	var rules []models.CommissionRule
+	if err := models.GetDB().Order("created_at DESC").Find(&rules).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load commission rules"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"default_rate_bps": earnings.DefaultRate(),
+			"rules":            rules,
+		},
+	})
+}
+
+// CommissionRuleRequest 抽成规则请求，skill_id和publisher_id二选一
+type CommissionRuleRequest struct {
+	SkillID     *uuid.UUID `json:"skill_id"`
+	PublisherID *uuid.UUID `json:"publisher_id"`
+	RateBps     *int       `json:"rate_bps" binding:"required"` // 基点，3000表示30%
+	Note        string     `json:"note"`
+}
+
+// SetCommissionRule 设置抽成规则
+// @Summary 设置抽成规则
+// @Description 为技能或发布者设置抽成比例，已有规则时覆盖。只影响之后支付的订单
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CommissionRuleRequest true "抽成规则"
+// @Success 200 {object} models.CommissionRule
+// @Router /admin/commission-rules [put]
+func SetCommissionRule(c *gin.Context) {
+	var req CommissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.SkillID == nil) == (req.PublisherID == nil) {
+		c.JSON(400, gin.H{"error": "exactly one of skill_id or publisher_id is required"})
+		return
+	}
+	if *req.RateBps < 0 || *req.RateBps > earnings.MaxRateBps {
+		c.JSON(400, gin.H{"error": earnings.ErrInvalidRate.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var rule models.CommissionRule
+	query := db.Where("skill_id = ?", req.SkillID)
+	if req.SkillID == nil {
+		query = db.Where("publisher_id = ? AND skill_id IS NULL", req.PublisherID)
+	}
+	if err := query.First(&rule).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(500, gin.H{"error": "Failed to load commission rule"})
+			return
+		}
+		rule = models.CommissionRule{ID: uuid.New(), SkillID: req.SkillID, PublisherID: req.PublisherID}
+	}
+	rule.RateBps, rule.Note = *req.RateBps, req.Note
+	if err := db.Save(&rule).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to save commission rule"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    rule,
+	})
+}
+
+// DeleteCommissionRule 删除抽成规则
+// @Summary 删除抽成规则
+// @Description 删除后回退到发布者规则或全局默认比例
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "规则ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/commission-rules/{id} [delete]
+func DeleteCommissionRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	result := models.GetDB().Delete(&models.CommissionRule{}, "id = ?", id)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete commission rule"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(404, gin.H{"error": "Commission rule not found"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+	})
+}
+
+// ListEarnings 发布者收益流水
+// @Summary 发布者收益流水
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param publisher_id query string false "发布者ID，指定时同时返回其余额"
+// @Param order_id query string false "订单ID"
+// @Param kind query string false "类型" Enums(sale,clawback)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/earnings [get]
+func ListEarnings(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	db := models.GetDB()
+	query := db.Model(&models.PublisherEarning{})
+	publisherID, publisherErr := uuid.Parse(c.Query("publisher_id"))
+	if publisherErr == nil {
+		query = query.Where("publisher_id = ?", publisherID)
+	}
+	if orderID, err := uuid.Parse(c.Query("order_id")); err == nil {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var list []models.PublisherEarning
+	query.Preload("Skill").Order("created_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&list)
+
+	data := gin.H{
+		"list":      list,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}
+	if publisherErr == nil {
+		balances, err := earnings.Balances(db, publisherID, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to load balance"})
+			return
+		}
+		data["balances"] = balances
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    data,
+	})
+}
+
+// CreatePayoutBatchRequest 生成付款批次请求
+type CreatePayoutBatchRequest struct {
+	Note string `json:"note"`
+}
+
+// CreatePayoutBatch 生成付款批次
+// @Summary 生成付款批次
+// @Description 将已过结算期的收益按发布者和币种汇总为待付款的批次，余额不为正的发布者不纳入
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CreatePayoutBatchRequest false "备注"
+// @Success 200 {object} models.PayoutBatch
+// @Router /admin/payout-batches [post]
+func CreatePayoutBatch(c *gin.Context) {
+	var req CreatePayoutBatchRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var adminID *uuid.UUID
+	if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		adminID = &uid
+	}
+	batch, err := earnings.CreateBatch(models.GetDB(), time.Now(), adminID, req.Note)
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    batch,
+	})
+}
+
+// ListPayoutBatches 付款批次列表
+// @Summary 付款批次列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param status query string false "批次状态" Enums(pending,processing,paid,cancelled)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/payout-batches [get]
+func ListPayoutBatches(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.PayoutBatch{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var batches []models.PayoutBatch
+	query.Preload("Payouts").Order("created_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&batches)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      batches,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// GetPayoutBatch 付款批次详情
+// @Summary 付款批次详情
+// @Description 返回批次及其中各发布者的付款；format=csv时下载付款文件
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "批次ID"
+// @Param format query string false "返回格式" Enums(json,csv) default(json)
+// @Success 200 {object} models.PayoutBatch
+// @Router /admin/payout-batches/{id} [get]
+func GetPayoutBatch(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+
+	db := models.GetDB()
+	batch, err := earnings.LoadBatch(db, id)
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		body, err := earnings.Export(db, batch)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to export payout batch"})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+batch.BatchNo+`.csv"`)
+		c.Data(200, "text/csv; charset=utf-8", body)
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    batch,
+	})
+}
+
+// PayoutBatchStatusRequest 付款批次状态变更请求
+type PayoutBatchStatusRequest struct {
+	Status models.PayoutBatchStatus `json:"status" binding:"required"`
+}
+
+// UpdatePayoutBatch 变更付款批次状态
+// @Summary 变更付款批次状态
+// @Description pending -> processing -> paid；未付款的批次可取消，取消后收益退回可提现余额
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "批次ID"
+// @Param request body PayoutBatchStatusRequest true "目标状态"
+// @Success 200 {object} models.PayoutBatch
+// @Router /admin/payout-batches/{id}/status [post]
+func UpdatePayoutBatch(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+	var req PayoutBatchStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	batch, err := earnings.UpdateBatchStatus(models.GetDB(), id, req.Status, time.Now())
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    batch,
+	})
+}
+
+// FailPayoutRequest 付款失败请求
+type FailPayoutRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// FailPayout 标记单笔付款失败
+// @Summary 标记付款失败
+// @Description 收款账户有误等原因未能付款时使用，该笔收益退回发布者可提现余额
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "付款ID"
+// @Param request body FailPayoutRequest true "失败原因"
+// @Success 200 {object} models.Payout
+// @Router /admin/payouts/{id}/fail [post]
+func FailPayout(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid payout ID"})
+		return
+	}
+	var req FailPayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	payout, err := earnings.FailPayout(models.GetDB(), id, req.Reason)
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    payout,
+	})
+}
+
+// payoutErrorStatus 付款错误对应的HTTP状态码
+func payoutErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, earnings.ErrBatchNotFound), errors.Is(err, earnings.ErrPayoutNotFound):
+		return 404
+	case errors.Is(err, earnings.ErrNothingToPay), errors.Is(err, earnings.ErrInvalidBatchStatus):
+		return 400
+	}
+	return 500
+}
+
+// parseLedgerDate 解析YYYY-MM-DD日期，返回该日结束（次日零点）的时点；为空时返回fallback
+func parseLedgerDate(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return date.AddDate(0, 0, 1), nil
+}
+
+// ListLedgerAccounts 总账科目余额
+// @Summary 总账科目余额
+// @Description 按科目和币种返回截至指定日期（含）的借贷发生额和余额
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param at query string false "截至日期 (格式: YYYY-MM-DD)，默认当前"
+// @Param code query string false "科目代码，如 sales、gateway:alipay"
+// @Success 200 {array} ledger.AccountBalance
+// @Router /admin/ledger/accounts [get]
+func ListLedgerAccounts(c *gin.Context) {
+	at, err := parseLedgerDate(c.Query("at"), time.Now())
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid at date, expected YYYY-MM-DD"})
+		return
+	}
+	var codes []string
+	if code := c.Query("code"); code != "" {
+		codes = append(codes, code)
+	}
+
+	balances, err := ledger.Balances(models.GetDB(), at, codes...)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load ledger balances"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    balances,
+	})
+}
+
+// GetTrialBalance 试算平衡表
+// @Summary 试算平衡表
+// @Description 各科目截至指定日期（含）的借方或贷方余额，以及各币种的借贷合计
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param at query string false "截至日期 (格式: YYYY-MM-DD)，默认当前"
+// @Success 200 {object} ledger.TrialBalance
+// @Router /admin/ledger/trial-balance [get]
+func GetTrialBalance(c *gin.Context) {
+	at, err := parseLedgerDate(c.Query("at"), time.Now())
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid at date, expected YYYY-MM-DD"})
+		return
+	}
+
+	trial, err := ledger.GetTrialBalance(models.GetDB(), at)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load trial balance"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    trial,
+	})
+}
+
+// ListJournalEntries 记账分录列表
+// @Summary 记账分录列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param event query string false "事件" Enums(payment,fee,refund,commission,payout,reversal)
+// @Param order_id query string false "订单ID"
+// @Param reference query string false "来源ID（交易、退款、收益、付款或被冲销分录的ID）"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/ledger/entries [get]
+func ListJournalEntries(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.JournalEntry{})
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if orderID, err := uuid.Parse(c.Query("order_id")); err == nil {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if reference := c.Query("reference"); reference != "" {
+		query = query.Where("reference = ?", reference)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var list []models.JournalEntry
+	query.Preload("Postings.Account").Order("posted_at DESC, entry_no DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&list)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ReverseJournalEntryRequest 冲销分录请求
+type ReverseJournalEntryRequest struct {
+	Memo string `json:"memo" binding:"required"`
+}
+
+// ReverseJournalEntry 冲销记账分录
+// @Summary 冲销记账分录
+// @Description 总账只能追加，错误的分录通过过账一笔借贷相反的分录冲销，每笔分录只能冲销一次
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "分录ID"
+// @Param request body ReverseJournalEntryRequest true "冲销原因"
+// @Success 200 {object} models.JournalEntry
+// @Router /admin/ledger/entries/{id}/reverse [post]
+func ReverseJournalEntry(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+	var req ReverseJournalEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var adminID *uuid.UUID
+	if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		adminID = &uid
 	}
-	if req.CategoryID != nil {
-		if catID, err := uuid.Parse(*req.CategoryID); err == nil {
-			skill.CategoryID = &catID
+	entry, err := ledger.Reverse(models.GetDB(), id, req.Memo, adminID)
+	if err != nil {
+		status := 500
+		switch {
+		case errors.Is(err, ledger.ErrEntryNotFound):
+			status = 404
+		case errors.Is(err, ledger.ErrAlreadyReversed):
+			status = 409
 		}
-	}
-	if req.PriceType != nil {
-		skill.PriceType = models.PriceType(*req.PriceType)
-	}
-	if req.Price != nil {
-		skill.Price = *req.Price
-	}
-	if req.IsActive != nil {
-		skill.IsActive = *req.IsActive
-	}
-
-	if err := db.Save(&skill).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update skill"})
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(200, gin.H{
 		"code":    0,
 		"message": "success",
-		"data":    skill,
+		"data":    entry,
 	})
 }
 
+// ExportLedger 导出总账分录
+// @Summary 导出总账分录
+// @Description 以CSV导出过账日期在[from, to]内的分录行（每行一个借方或贷方），供会计系统导入
+// @Tags admin
+// @Produce text/csv
+// @Security Bearer
+// @Param from query string true "开始日期 (格式: YYYY-MM-DD)"
+// @Param to query string true "结束日期 (格式: YYYY-MM-DD)，含当日"
+// @Success 200 {file} file
+// @Router /admin/ledger/export [get]
+func ExportLedger(c *gin.Context) {
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "from is required, expected YYYY-MM-DD"})
+		return
+	}
+	to, err := parseLedgerDate(c.Query("to"), time.Time{})
+	if err != nil || to.IsZero() {
+		c.JSON(400, gin.H{"error": "to is required, expected YYYY-MM-DD"})
+		return
+	}
+	if !to.After(from) {
+		c.JSON(400, gin.H{"error": "to must not be before from"})
+		return
+	}
+
+	body, err := ledger.Export(models.GetDB(), from, to)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to export ledger"})
+		return
+	}
+	filename := "ledger-" + c.Query("from") + "-" + c.Query("to") + ".csv"
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(200, "text/csv; charset=utf-8", body)
+}
+
 // ListUsers 列出用户
 // @Summary 管理员查看用户列表
 // @Tags admin
@@ -342,14 +2329,14 @@ func ListOrders(c *gin.Context) {
 	}
 
 	// 排序
-	validSortFields := map[string]bool{
-		"order_no":     true,
-		"total_amount": true,
-		"status":       true,
-		"created_at":   true,
+	validSortFields := map[string]string{
+		"order_no":     "order_no",
+		"total_amount": "total_amount_minor",
+		"status":       "status",
+		"created_at":   "created_at",
 	}
-	if validSortFields[sortBy] {
-		orderClause := "orders." + sortBy + " " + sortOrder
+	if column, ok := validSortFields[sortBy]; ok {
+		orderClause := "orders." + column + " " + sortOrder
 		query = query.Order(orderClause)
 	}
 
@@ -373,12 +2360,420 @@ func ListOrders(c *gin.Context) {
 	})
 }
 
+// ReconcilePayments 手动触发支付对账
+// @Summary 支付对账
+// @Description 向支付网关查询时间窗口内待支付和已支付订单的实际状态，修正丢失回调的订单并返回差异列表
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param hours query int false "对账时间窗口（小时）" default(72)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/payments/reconcile [post]
+func ReconcilePayments(c *gin.Context) {
+	window := reconcile.DefaultWindow
+	if hours, err := strconv.Atoi(c.Query("hours")); err == nil && hours > 0 {
+		window = time.Duration(hours) * time.Hour
+	}
+
+	report, err := reconcile.Run(*config.AppConfig, window)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to reconcile payments", "details": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    report,
+	})
+}
+
+// RefundOrderRequest 退款请求
+type RefundOrderRequest struct {
+	OrderItemID *uuid.UUID `json:"order_item_id"` // 为空表示整单退款
+	Amount      json.Number `json:"amount"`        // 按订单币种计的金额，为空或为0时退还剩余可退金额
+	Reason      string     `json:"reason"`
+}
+
+// RefundOrder 发起订单退款
+// @Summary 订单退款
+// @Description 对已支付订单发起全额或部分退款，可指定单个商品；商品全额退款后撤销其使用权
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "订单ID"
+// @Param request body RefundOrderRequest true "退款信息"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/orders/{id}/refunds [post]
+func RefundOrder(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req RefundOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	var operatorID *uuid.UUID
+	if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+		operatorID = &uid
+	}
+
+	result, err := refund.Create(*config.AppConfig, refund.Request{
+		OrderID:     orderID,
+		OrderItemID: req.OrderItemID,
+		Amount:      req.Amount.String(),
+		Reason:      req.Reason,
+		OperatorID:  operatorID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, refund.ErrOrderNotRefundable), errors.Is(err, refund.ErrInvalidAmount), errors.Is(err, refund.ErrNoTransaction):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case result == nil:
+			c.JSON(404, gin.H{"error": "Order not found"})
+		default:
+			respondRefundError(c, result, err)
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
+
+// RetryRefund 重新提交处理中的退款
+// @Summary 重试退款
+// @Description 网关超时或出错时退款保持处理中，可以原退款单号重新提交以确认结果，网关按退款单号去重不会重复退款
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "退款ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/refunds/{id}/retry [post]
+func RetryRefund(c *gin.Context) {
+	refundID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid refund ID"})
+		return
+	}
+
+	result, err := refund.Retry(*config.AppConfig, refundID)
+	if err != nil {
+		switch {
+		case result == nil:
+			c.JSON(404, gin.H{"error": "Refund not found"})
+		case errors.Is(err, refund.ErrRefundNotPending):
+			c.JSON(409, gin.H{"error": err.Error(), "data": result})
+		default:
+			respondRefundError(c, result, err)
+		}
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
+
+// respondRefundError 网关出错时的应答：明确拒绝的退款已标记失败，结果未知的退款仍在处理中可重试
+func respondRefundError(c *gin.Context, result *models.Refund, err error) {
+	if result.Status == models.RefundStatusPending {
+		c.JSON(502, gin.H{"error": "Refund pending, provider did not confirm", "details": err.Error(), "data": result})
+		return
+	}
+	c.JSON(502, gin.H{"error": "Refund failed", "details": err.Error(), "data": result})
+}
+
+// ListOrderRefunds 获取订单退款记录
+// @Summary 订单退款记录
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "订单ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/orders/{id}/refunds [get]
+func ListOrderRefunds(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var refunds []models.Refund
+	models.GetDB().Where("order_id = ?", orderID).Order("created_at DESC").Find(&refunds)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    refunds,
+	})
+}
+
+// ListOrderEvents 获取订单状态变更记录
+// @Summary 订单状态变更记录
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "订单ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/orders/{id}/events [get]
+func ListOrderEvents(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var events []models.OrderEvent
+	models.GetDB().Where("order_id = ?", orderID).Order("created_at ASC").Find(&events)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    events,
+	})
+}
+
+// ReviewOrderRequest 付款审核请求
+type ReviewOrderRequest struct {
+	Action string `json:"action" binding:"required,oneof=approve reject"`
+	Note   string `json:"note"`
+}
+
+// ReviewOrder 审核付款异常或风控挂起的订单
+// @Summary 审核订单
+// @Description 付款金额、币种或商户与订单不符的订单处于payment_review状态：approve置为已支付并授予权限，reject取消订单（已收款项可通过退款接口退还）。
+// @Description 风控挂起的订单处于risk_review状态：approve恢复为待支付（实付为零的直接完成），reject取消订单
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "订单ID"
+// @Param request body ReviewOrderRequest true "审核结果"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/orders/{id}/review [post]
+func ReviewOrder(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req ReviewOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := orders.ResolveReview(orderID, req.Action == "approve", req.Note)
+	switch {
+	case errors.Is(err, orders.ErrOrderNotFound):
+		c.JSON(404, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, orders.ErrInvalidTransition):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": "Failed to update order"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    order,
+	})
+}
+
+// ListRiskReviews 风控审核队列
+// @Summary 风控挂起的订单
+// @Description 列出风险分达到审核阈值、等待人工审核的订单，按下单时间先后排列，附带风险分、触发的规则和当前风控策略
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/risk/reviews [get]
+func ListRiskReviews(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := models.GetDB().Model(&models.Order{}).Where("status = ?", models.OrderStatusRiskReview)
+
+	var total int64
+	query.Count(&total)
+
+	var list []models.Order
+	query.Preload("User").Preload("Items.Skill").
+		Order("created_at ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list)
+
+	policy := risk.CurrentPolicy()
+	rules := make([]string, 0, len(policy.Rules))
+	for _, rule := range policy.Rules {
+		rules = append(rules, rule.String())
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"items":     list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"policy": gin.H{
+				"rules":        rules,
+				"review_score": policy.ReviewScore,
+				"block_score":  policy.BlockScore,
+			},
+		},
+	})
+}
+
+// ListAlerts 获取管理员告警
+// @Summary 告警列表
+// @Description 付款金额不符、重复付款、已取消订单收款等需要人工处理的告警
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param resolved query bool false "是否已处理" default(false)
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/alerts [get]
+func ListAlerts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	query := models.GetDB().Model(&models.AdminAlert{})
+	if c.DefaultQuery("resolved", "false") == "true" {
+		query = query.Where("resolved_at IS NOT NULL")
+	} else {
+		query = query.Where("resolved_at IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var alerts []models.AdminAlert
+	query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&alerts)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"items":     alerts,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ResolveAlertRequest 处理告警请求
+type ResolveAlertRequest struct {
+	// ConfirmPayment 重复付款告警：true 确认为另一笔真实付款并记入总账（需另行退款），false 驳回
+	ConfirmPayment bool `json:"confirm_payment"`
+}
+
+// ResolveAlert 标记告警已处理
+// @Summary 处理告警
+// @Description 重复付款的交易在处理告警前不记入总账，confirm_payment 为 true 时确认入账，否则作废该交易
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "告警ID"
+// @Param request body ResolveAlertRequest false "处理方式"
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/alerts/{id}/resolve [post]
+func ResolveAlert(c *gin.Context) {
+	alertID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	var req ResolveAlertRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var alert models.AdminAlert
+	err = models.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&alert, "id = ?", alertID).Error; err != nil {
+			return err
+		}
+		if alert.ResolvedAt != nil {
+			return nil
+		}
+		if err := orders.SettleDuplicatePayment(tx, &alert, req.ConfirmPayment); err != nil {
+			return err
+		}
+		now := time.Now()
+		updates := map[string]interface{}{"resolved_at": &now}
+		if uid, err := uuid.Parse(c.GetString("user_id")); err == nil {
+			updates["resolved_by"] = uid
+		}
+		if err := tx.Model(&alert).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(&alert, "id = ?", alertID).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": "Alert not found"})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to resolve alert"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    alert,
+	})
+}
+
 // Order 订单响应
 type Order struct {
 	ID           string  `json:"id"`
 	OrderNo      string  `json:"order_no"`
 	UserEmail    string  `json:"user_email"`
-	TotalAmount  float64 `json:"total_amount"`
+	Total        models.Money `json:"total"`
 	Status       string  `json:"status"`
 	CreatedAt    string  `json:"created_at"`
 }
@@ -388,10 +2783,10 @@ type AnalyticsData struct {
 	TotalUsers       int64   `json:"total_users"`
 	TotalSkills      int64   `json:"total_skills"`
 	TotalOrders      int64   `json:"total_orders"`
-	TotalRevenue     float64 `json:"total_revenue"`
+	TotalRevenue     []models.Money `json:"total_revenue"` // 按币种汇总
 	ActiveSkills     int64   `json:"active_skills"`
 	TodayOrders      int64   `json:"today_orders"`
-	TodayRevenue     float64 `json:"today_revenue"`
+	TodayRevenue     []models.Money `json:"today_revenue"`
 	PaidSkills       int64   `json:"paid_skills"`
 	FreeSkills       int64   `json:"free_skills"`
 	RecentOrders     []Order `json:"recent_orders"`
@@ -442,7 +2837,7 @@ func GetAnalytics(c *gin.Context) {
 			ID:          order.ID,
 			OrderNo:     order.OrderNo,
 			UserEmail:   order.UserEmail,
-			TotalAmount: order.TotalAmount,
+			Total:       order.Total,
 			Status:      order.Status,
 			CreatedAt:   order.CreatedAt,
 		})
@@ -1,16 +1,21 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"skillhub/lib"
 	"skillhub/models"
 	svcauth "skillhub/services/auth"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // LoginRequest 登录请求
@@ -19,10 +24,37 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginResponse 登录响应
+// LoginResponse 登录响应：访问令牌、刷新令牌及其过期时间
 type LoginResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	svcauth.TokenPair
+	User models.User `json:"user"`
+}
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"` // 访问令牌已过期时用刷新令牌指定要退出的会话
+	All          bool   `json:"all"`           // 退出所有设备
+}
+
+// VerifyEmailRequest 邮箱验证请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"` // 验证邮件链接中的token
+}
+
+// ForgotPasswordRequest 找回密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"` // 重置密码邮件链接中的token
+	NewPassword string `json:"new_password" binding:"required,min=6"`
 }
 
 // RegisterRequest 注册请求
@@ -61,10 +93,9 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT token
-	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	// 创建登录会话并签发访问令牌和刷新令牌
+	tokens, ok := issueSession(c, &user)
+	if !ok {
 		return
 	}
 
@@ -72,8 +103,8 @@ func Login(c *gin.Context) {
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
+		TokenPair: *tokens,
+		User:      user,
 	})
 }
 
@@ -129,10 +160,14 @@ func Register(c *gin.Context) {
 	}
 	models.DB.Create(&profile)
 
-	// 生成JWT token
-	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	// 发送邮箱验证邮件，发送失败不影响注册，用户可稍后重新发送
+	if err := svcauth.SendVerificationEmail(c.Request.Context(), models.DB, &user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
+	// 创建登录会话并签发访问令牌和刷新令牌
+	tokens, ok := issueSession(c, &user)
+	if !ok {
 		return
 	}
 
@@ -140,8 +175,8 @@ func Register(c *gin.Context) {
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
+		TokenPair: *tokens,
+		User:      user,
 	})
 }
 
@@ -169,9 +204,273 @@ func GetMe(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// Refresh 刷新访问令牌
+// @Summary 刷新令牌
+// @Description 用刷新令牌换发新的访问令牌和刷新令牌，旧刷新令牌随即失效；已使用过的刷新令牌再次提交时撤销整个会话
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "刷新令牌"
+// @Success 200 {object} LoginResponse
+// @Router /auth/refresh [post]
+func Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, user, err := svcauth.Refresh(models.GetDB(), req.RefreshToken, clientOf(c))
+	switch {
+	case errors.Is(err, svcauth.ErrInvalidRefreshToken), errors.Is(err, svcauth.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, svcauth.ErrUserInactive):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	user.PasswordHash = ""
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenPair: *tokens,
+		User:      *user,
+	})
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 撤销当前会话，会话的访问令牌和刷新令牌立即失效；all为true时退出所有设备。访问令牌已过期时可只提交刷新令牌（不经过鉴权中间件）
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body LogoutRequest false "退出选项"
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	var req LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 访问令牌有效时退出其所属会话，否则（如已过期）按请求体中的刷新令牌查找会话
+	db := models.GetDB()
+	sessionID := uuid.Nil
+	if token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); token != "" {
+		if claims, _, err := svcauth.Authenticate(db, token); err == nil {
+			sessionID = claims.SessionID
+		}
+	}
+	if sessionID == uuid.Nil && req.RefreshToken != "" {
+		if id, err := svcauth.SessionForRefreshToken(db, req.RefreshToken); err == nil {
+			sessionID = id
+		}
+	}
+	if sessionID == uuid.Nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var err error
+
+	if req.All {
+		var session models.AuthSession
+		if err := db.First(&session, "id = ?", sessionID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		_, err = svcauth.RevokeUserSessions(db, session.UserID, svcauth.RevokeLogoutAll)
+	} else {
+		err = svcauth.RevokeSession(db, sessionID, svcauth.RevokeLogout)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+	})
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件链接中的token完成邮箱验证，链接只能使用一次
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyEmailRequest true "验证token"
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/verify-email [post]
+func VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := svcauth.VerifyEmail(models.GetDB(), req.Token)
+	if errors.Is(err, svcauth.ErrInvalidEmailToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"email":             user.Email,
+			"email_verified_at": user.EmailVerifiedAt,
+		},
+	})
+}
+
+// ResendVerification 重新发送验证邮件
+// @Summary 重新发送验证邮件
+// @Description 向当前用户的邮箱重新发送验证链接，之前的链接随之作废；每分钟最多发送一次
+// @Tags auth
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/resend-verification [post]
+func ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	db := models.GetDB()
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := svcauth.SendVerificationEmail(c.Request.Context(), db, &user)
+	switch {
+	case errors.Is(err, svcauth.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, svcauth.ErrEmailTooSoon):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+	})
+}
+
+// sendPasswordResetEmail 发送重置密码邮件（测试中替换）
+var sendPasswordResetEmail = func(ctx context.Context, email string) error {
+	return svcauth.SendPasswordResetEmail(ctx, models.GetDB(), email)
+}
+
+// ForgotPassword 找回密码
+// @Summary 找回密码
+// @Description 向该邮箱发送重置密码链接。无论邮箱是否注册都返回成功，避免暴露注册情况
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "邮箱"
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/forgot-password [post]
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 在后台查找账号并发送邮件：邮箱是否注册、是否在发送间隔内、发送是否成功都不影响应答内容和耗时
+	go func(email string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := sendPasswordResetEmail(ctx, email); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	}(req.Email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "If the email is registered, a password reset link has been sent",
+	})
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用重置密码邮件链接中的token设置新密码，链接只能使用一次；成功后撤销该用户的全部登录会话，需要重新登录
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "token和新密码"
+// @Success 200 {object} map[string]interface{}
+// @Router /auth/reset-password [post]
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := svcauth.ResetPassword(models.GetDB(), req.Token, req.NewPassword)
+	switch {
+	case errors.Is(err, svcauth.ErrInvalidEmailToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, svcauth.ErrUserInactive):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Password has been reset, please log in again",
+	})
+}
+
+// issueSession 为登录成功的用户创建会话并签发令牌，失败时写入错误响应
+func issueSession(c *gin.Context, user *models.User) (*svcauth.TokenPair, bool) {
+	tokens, err := svcauth.IssueSession(models.GetDB(), user, clientOf(c))
+	if errors.Is(err, svcauth.ErrUserInactive) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return nil, false
+	}
+	return tokens, true
+}
+
+// clientOf 记录在会话上的客户端信息
+func clientOf(c *gin.Context) svcauth.Client {
+	return svcauth.Client{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
 // OAuthLogin OAuth登录
 // @Summary OAuth登录
-// @Description OAuth第三方登录，支持微信、飞书、小红书、GitHub、Google
+// @Description OAuth第三方登录，支持微信、飞书、小红书、GitHub、Google。返回授权地址和浏览器绑定值binding，回调时通过X-OAuth-Binding请求头或Cookie带回
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -197,22 +496,8 @@ func OAuthLogin(c *gin.Context) {
 		return
 	}
 
-	var oauthURL string
-	var err error
-
-	switch provider {
-	case "github":
-		oauthURL, err = svcauth.GetGitHubAuthURL()
-	case "google":
-		oauthURL, err = svcauth.GetGoogleAuthURL()
-	case "wechat":
-		oauthURL, err = svcauth.GetWeChatAuthURL()
-	case "feishu":
-		oauthURL, err = svcauth.GetFeishuAuthURL()
-	case "xiaohongshu":
-		oauthURL, err = svcauth.GetXiaohongshuAuthURL()
-	}
-
+	// 生成state并保存在服务端，浏览器绑定值写入Cookie并在响应中返回，回调时两者必须一致
+	request, err := svcauth.BeginOAuth(models.GetDB(), provider)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"error":   "Failed to generate OAuth URL",
@@ -220,14 +505,72 @@ func OAuthLogin(c *gin.Context) {
 		})
 		return
 	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(svcauth.OAuthBindingCookie, request.Binding, int(time.Until(request.ExpiresAt).Seconds()),
+		svcauth.OAuthCallbackPath, "", secureCookie(c), true)
 
 	c.JSON(200, gin.H{
-		"message":  "Redirect to OAuth provider",
-		"provider": provider,
-		"auth_url": oauthURL,
+		"message":    "Redirect to OAuth provider",
+		"provider":   provider,
+		"auth_url":   request.URL,
+		"binding":    request.Binding,
+		"expires_at": request.ExpiresAt,
 	})
 }
 
+// verifyOAuthState 校验回调的state和浏览器绑定值（X-OAuth-Binding请求头或Cookie），失败时写入错误响应。
+// 返回PKCE code_verifier，不支持PKCE的提供商为空
+func verifyOAuthState(c *gin.Context, provider, state string) (string, bool) {
+	binding := c.GetHeader(svcauth.OAuthBindingHeader)
+	if binding == "" {
+		binding, _ = c.Cookie(svcauth.OAuthBindingCookie)
+	}
+	verifier, err := svcauth.VerifyState(models.GetDB(), provider, state, binding)
+	if errors.Is(err, svcauth.ErrInvalidOAuthState) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to verify OAuth state"})
+		return "", false
+	}
+	// state只能使用一次，绑定Cookie随之作废
+	c.SetCookie(svcauth.OAuthBindingCookie, "", -1, svcauth.OAuthCallbackPath, "", secureCookie(c), true)
+	return verifier, true
+}
+
+// completeOAuthLogin 按第三方身份查找或创建用户并签发令牌。未经提供商验证的邮箱已被其他账号使用时返回409
+func completeOAuthLogin(c *gin.Context, userInfo *svcauth.OAuthUserInfo) {
+	user, err := svcauth.ResolveOAuthUser(models.GetDB(), userInfo)
+	if errors.Is(err, svcauth.ErrOAuthEmailUnverified) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// 创建登录会话并签发访问令牌和刷新令牌
+	tokens, ok := issueSession(c, user)
+	if !ok {
+		return
+	}
+
+	// 清除敏感信息
+	user.PasswordHash = ""
+
+	c.JSON(200, LoginResponse{
+		TokenPair: *tokens,
+		User:      *user,
+	})
+}
+
+// secureCookie 通过HTTPS访问或生产模式下Cookie只在HTTPS下发送
+func secureCookie(c *gin.Context) bool {
+	return c.Request.TLS != nil || gin.Mode() == gin.ReleaseMode
+}
+
 // GitHubCallback GitHub回调
 // @Summary GitHub OAuth回调
 // @Description GitHub OAuth登录回调
@@ -236,6 +579,7 @@ func OAuthLogin(c *gin.Context) {
 // @Produce json
 // @Param code query string true "OAuth授权码"
 // @Param state query string true "状态参数"
+// @Param X-OAuth-Binding header string false "发起登录时返回的binding，未携带oauth_binding Cookie时必填"
 // @Router /auth/callback/github [get]
 func GitHubCallback(c *gin.Context) {
 	code := c.Query("code")
@@ -246,8 +590,14 @@ func GitHubCallback(c *gin.Context) {
 		return
 	}
 
+	// 校验state属于当前浏览器发起的登录，防止CSRF和账号混淆
+	verifier, ok := verifyOAuthState(c, "github", state)
+	if !ok {
+		return
+	}
+
 	// 使用GitHub OAuth服务处理回调
-	userInfo, err := svcauth.HandleGitHubCallback(code, state)
+	userInfo, err := svcauth.HandleGitHubCallback(code, verifier)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"error":   "Failed to handle GitHub callback",
@@ -256,50 +606,7 @@ func GitHubCallback(c *gin.Context) {
 		return
 	}
 
-	// 查找或创建用户
-	var user models.User
-	err = models.DB.Where("email = ?", userInfo.Email).First(&user).Error
-	if err != nil {
-		// 创建新用户
-		user = models.User{
-			ID:           uuid.New(),
-			Email:        userInfo.Email,
-			Username:     userInfo.Username,
-			Name:         userInfo.Name,
-			PasswordHash: "", // OAuth用户没有密码
-			Role:         models.RoleUser,
-			IsActive:     true,
-		}
-		if err := models.DB.Create(&user).Error; err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create user"})
-			return
-		}
-	} else {
-		// 如果用户已存在但没有名称，更新名称
-		if user.Name == "" && userInfo.Name != "" {
-			user.Name = userInfo.Name
-			models.DB.Save(&user)
-		}
-		if user.Username == "" && userInfo.Username != "" {
-			user.Username = userInfo.Username
-			models.DB.Save(&user)
-		}
-	}
-
-	// 生成JWT token
-	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	// 清除敏感信息
-	user.PasswordHash = ""
-
-	c.JSON(200, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	completeOAuthLogin(c, userInfo)
 }
 
 // GoogleCallback Google回调
@@ -310,6 +617,7 @@ func GitHubCallback(c *gin.Context) {
 // @Produce json
 // @Param code query string true "OAuth授权码"
 // @Param state query string true "状态参数"
+// @Param X-OAuth-Binding header string false "发起登录时返回的binding，未携带oauth_binding Cookie时必填"
 // @Router /auth/callback/google [get]
 func GoogleCallback(c *gin.Context) {
 	code := c.Query("code")
@@ -320,8 +628,14 @@ func GoogleCallback(c *gin.Context) {
 		return
 	}
 
+	// 校验state属于当前浏览器发起的登录，防止CSRF和账号混淆
+	verifier, ok := verifyOAuthState(c, "google", state)
+	if !ok {
+		return
+	}
+
 	// 使用Google OAuth服务处理回调
-	userInfo, err := svcauth.HandleGoogleCallback(code, state)
+	userInfo, err := svcauth.HandleGoogleCallback(code, verifier)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"error":   "Failed to handle Google callback",
@@ -330,45 +644,7 @@ func GoogleCallback(c *gin.Context) {
 		return
 	}
 
-	// 查找或创建用户
-	var user models.User
-	err = models.DB.Where("email = ?", userInfo.Email).First(&user).Error
-	if err != nil {
-		// 创建新用户
-		user = models.User{
-			ID:           uuid.New(),
-			Email:        userInfo.Email,
-			Name:         userInfo.Name,
-			PasswordHash: "", // OAuth用户没有密码
-			Role:         models.RoleUser,
-			IsActive:     true,
-		}
-		if err := models.DB.Create(&user).Error; err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create user"})
-			return
-		}
-	} else {
-		// 如果用户已存在但没有名称，更新名称
-		if user.Name == "" && userInfo.Name != "" {
-			user.Name = userInfo.Name
-			models.DB.Save(&user)
-		}
-	}
-
-	// 生成JWT token
-	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	// 清除敏感信息
-	user.PasswordHash = ""
-
-	c.JSON(200, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	completeOAuthLogin(c, userInfo)
 }
 
 // WeChatCallback 微信回调
@@ -379,6 +655,7 @@ func GoogleCallback(c *gin.Context) {
 // @Produce json
 // @Param code query string true "OAuth授权码"
 // @Param state query string true "状态参数"
+// @Param X-OAuth-Binding header string false "发起登录时返回的binding，未携带oauth_binding Cookie时必填"
 // @Router /auth/callback/wechat [get]
 func WeChatCallback(c *gin.Context) {
 	code := c.Query("code")
@@ -389,8 +666,13 @@ func WeChatCallback(c *gin.Context) {
 		return
 	}
 
+	// 校验state属于当前浏览器发起的登录，防止CSRF和账号混淆
+	if _, ok := verifyOAuthState(c, "wechat", state); !ok {
+		return
+	}
+
 	// 使用微信OAuth服务处理回调
-	userInfo, err := svcauth.HandleWeChatCallback(code, state)
+	userInfo, err := svcauth.HandleWeChatCallback(code)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"error":   "Failed to handle WeChat callback",
@@ -399,50 +681,7 @@ func WeChatCallback(c *gin.Context) {
 		return
 	}
 
-	// 查找或创建用户
-	var user models.User
-	err = models.DB.Where("email = ?", userInfo.Email).First(&user).Error
-	if err != nil {
-		// 创建新用户
-		user = models.User{
-			ID:           uuid.New(),
-			Email:        userInfo.Email,
-			Username:     userInfo.Username,
-			Name:         userInfo.Name,
-			PasswordHash: "", // OAuth用户没有密码
-			Role:         models.RoleUser,
-			IsActive:     true,
-		}
-		if err := models.DB.Create(&user).Error; err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create user"})
-			return
-		}
-	} else {
-		// 如果用户已存在但没有名称，更新名称
-		if user.Name == "" && userInfo.Name != "" {
-			user.Name = userInfo.Name
-			models.DB.Save(&user)
-		}
-		if user.Username == "" && userInfo.Username != "" {
-			user.Username = userInfo.Username
-			models.DB.Save(&user)
-		}
-	}
-
-	// 生成JWT token
-	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	// 清除敏感信息
-	user.PasswordHash = ""
-
-	c.JSON(200, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	completeOAuthLogin(c, userInfo)
 }
 
 // FeishuCallback 飞书回调
@@ -453,6 +692,7 @@ func WeChatCallback(c *gin.Context) {
 // @Produce json
 // @Param code query string true "OAuth授权码"
 // @Param state query string true "状态参数"
+// @Param X-OAuth-Binding header string false "发起登录时返回的binding，未携带oauth_binding Cookie时必填"
 // @Router /auth/callback/feishu [get]
 func FeishuCallback(c *gin.Context) {
 	code := c.Query("code")
@@ -463,8 +703,13 @@ func FeishuCallback(c *gin.Context) {
 		return
 	}
 
+	// 校验state属于当前浏览器发起的登录，防止CSRF和账号混淆
+	if _, ok := verifyOAuthState(c, "feishu", state); !ok {
+		return
+	}
+
 	// 使用飞书OAuth服务处理回调
-	userInfo, err := svcauth.HandleFeishuCallback(code, state)
+	userInfo, err := svcauth.HandleFeishuCallback(code)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"error":   "Failed to handle Feishu callback",
@@ -473,50 +718,7 @@ func FeishuCallback(c *gin.Context) {
 		return
 	}
 
-	// 查找或创建用户
-	var user models.User
-	err = models.DB.Where("email = ?", userInfo.Email).First(&user).Error
-	if err != nil {
-		// 创建新用户
-		user = models.User{
-			ID:           uuid.New(),
-			Email:        userInfo.Email,
-			Username:     userInfo.Username,
-			Name:         userInfo.Name,
-			PasswordHash: "", // OAuth用户没有密码
-			Role:         models.RoleUser,
-			IsActive:     true,
-		}
-		if err := models.DB.Create(&user).Error; err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create user"})
-			return
-		}
-	} else {
-		// 如果用户已存在但没有名称，更新名称
-		if user.Name == "" && userInfo.Name != "" {
-			user.Name = userInfo.Name
-			models.DB.Save(&user)
-		}
-		if user.Username == "" && userInfo.Username != "" {
-			user.Username = userInfo.Username
-			models.DB.Save(&user)
-		}
-	}
-
-	// 生成JWT token
-	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	// 清除敏感信息
-	user.PasswordHash = ""
-
-	c.JSON(200, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	completeOAuthLogin(c, userInfo)
 }
 
 // XiaohongshuCallback 小红书回调
@@ -527,6 +729,7 @@ func FeishuCallback(c *gin.Context) {
 // @Produce json
 // @Param code query string true "OAuth授权码"
 // @Param state query string true "状态参数"
+// @Param X-OAuth-Binding header string false "发起登录时返回的binding，未携带oauth_binding Cookie时必填"
 // @Router /auth/callback/xiaohongshu [get]
 func XiaohongshuCallback(c *gin.Context) {
 	code := c.Query("code")
@@ -537,8 +740,17 @@ func XiaohongshuCallback(c *gin.Context) {
 		return
 	}
 
+	// 校验state属于当前浏览器发起的登录，防止CSRF和账号混淆
+	if _, ok := verifyOAuthState(c, "xiaohongshu", state); !ok {
+		return
+	}
+
 	// 使用小红书OAuth服务处理回调
-	userInfo, err := svcauth.HandleXiaohongshuCallback(code, state)
+	userInfo, err := svcauth.HandleXiaohongshuCallback(code)
+	if errors.Is(err, svcauth.ErrProviderUnavailable) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(500, gin.H{
 			"error":   "Failed to handle Xiaohongshu callback",
@@ -547,50 +759,7 @@ func XiaohongshuCallback(c *gin.Context) {
 		return
 	}
 
-	// 查找或创建用户
-	var user models.User
-	err = models.DB.Where("email = ?", userInfo.Email).First(&user).Error
-	if err != nil {
-		// 创建新用户
-		user = models.User{
-			ID:           uuid.New(),
-			Email:        userInfo.Email,
-			Username:     userInfo.Username,
-			Name:         userInfo.Name,
-			PasswordHash: "", // OAuth用户没有密码
-			Role:         models.RoleUser,
-			IsActive:     true,
-		}
-		if err := models.DB.Create(&user).Error; err != nil {
-			c.JSON(500, gin.H{"error": "Failed to create user"})
-			return
-		}
-	} else {
-		// 如果用户已存在但没有名称，更新名称
-		if user.Name == "" && userInfo.Name != "" {
-			user.Name = userInfo.Name
-			models.DB.Save(&user)
-		}
-		if user.Username == "" && userInfo.Username != "" {
-			user.Username = userInfo.Username
-			models.DB.Save(&user)
-		}
-	}
-
-	// 生成JWT token
-	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	// 清除敏感信息
-	user.PasswordHash = ""
-
-	c.JSON(200, LoginResponse{
-		Token: token,
-		User:  user,
-	})
+	completeOAuthLogin(c, userInfo)
 }
 
 // UpdateProfileRequest 更新用户信息请求
@@ -1018,7 +1187,7 @@ func jsonMarshal(v interface{}) []byte {
 
 // ChangePassword 修改密码
 // @Summary 修改用户密码
-// @Description 修改当前登录用户的密码
+// @Description 修改当前登录用户的密码，并撤销该用户的全部登录会话；响应返回当前设备的新令牌
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -1074,19 +1243,36 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	// 更新密码哈希
+	// 更新密码哈希并撤销全部会话，其他设备需要重新登录；未使用的重置密码链接一并作废
 	user.PasswordHash = newHash
-	if err := db.Save(&user).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		if err := svcauth.InvalidateEmailTokens(tx, user.ID, models.EmailTokenResetPassword); err != nil {
+			return err
+		}
+		_, err := svcauth.RevokeUserSessions(tx, user.ID, svcauth.RevokePasswordChanged)
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to update password",
 		})
 		return
 	}
 
+	// 为当前设备签发新的令牌
+	tokens, ok := issueSession(c, &user)
+	if !ok {
+		return
+	}
+
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "Password updated successfully",
+		"data":    tokens,
 	})
 }
 
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"skillhub/config"
+	svcauth "skillhub/services/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestForgotPasswordDoesNotRevealRegistration 未注册的邮箱、发送间隔内的已注册邮箱和发送失败时应答完全相同
+func TestForgotPasswordDoesNotRevealRegistration(t *testing.T) {
+	original := sendPasswordResetEmail
+	defer func() { sendPasswordResetEmail = original }()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/forgot-password", ForgotPassword)
+
+	outcomes := map[string]error{
+		"unknown@example.com":    nil,
+		"registered@example.com": svcauth.ErrEmailTooSoon,
+		"broken@example.com":     errors.New("smtp unavailable"),
+	}
+	sent := make(chan string, len(outcomes))
+	sendPasswordResetEmail = func(ctx context.Context, email string) error {
+		sent <- email
+		return outcomes[email]
+	}
+
+	var first string
+	for email := range outcomes {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/forgot-password", strings.NewReader(`{"email":"`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", email, w.Code)
+		}
+		if first == "" {
+			first = w.Body.String()
+		} else if w.Body.String() != first {
+			t.Errorf("%s: body %s differs from %s", email, w.Body.String(), first)
+		}
+	}
+	for range outcomes {
+		<-sent
+	}
+}
+
+// TestLogoutRequiresCredentials 无访问令牌或令牌无效、且未提交刷新令牌时拒绝退出
+func TestLogoutRequiresCredentials(t *testing.T) {
+	previous := config.AppConfig
+	defer func() { config.AppConfig = previous }()
+	config.AppConfig = config.LoadConfig()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/logout", Logout)
+
+	for _, header := range []string{"", "Bearer expired-or-forged"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/logout", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("logout with %q = %d, want 401", header, w.Code)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package bundles
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/bundle"
+	"skillhub/services/orders"
+	svcpayment "skillhub/services/payment"
+	"skillhub/services/pricing"
+	"skillhub/services/risk"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PurchaseRequest 购买套餐请求
+type PurchaseRequest struct {
+	Region      string `json:"region"`       // 国家/地区代码，可带省/州（如 US-CA），用于地区定价和计税
+	Currency    string `json:"currency"`     // 支付币种，默认为套餐定价币种
+	PaymentType string `json:"payment_type"` // 支付方式，为空时按路由规则选择
+	CouponCode  string `json:"coupon_code"`  // 优惠码
+	// CardFingerprint 支付卡指纹（如Stripe PaymentMethod的card.fingerprint），用于风控频率统计
+	CardFingerprint string `json:"card_fingerprint"`
+}
+
+// currentUser 从上下文获取当前用户ID
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// available 在售套餐：已启用、在销售时间内且所含技能均已上架
+func available(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("bundles.is_active = ?", true).
+		Where("bundles.starts_at IS NULL OR bundles.starts_at <= ?", now).
+		Where("bundles.ends_at IS NULL OR bundles.ends_at > ?", now).
+		Where("NOT EXISTS (SELECT 1 FROM bundle_items JOIN skills ON skills.id = bundle_items.skill_id " +
+			"WHERE bundle_items.bundle_id = bundles.id AND skills.is_active = false)")
+}
+
+// ListBundles 获取在售套餐列表
+// @Summary 套餐列表
+// @Description 列出在售的技能套餐及其包含的技能，限时套餐只在销售时间内显示
+// @Tags bundles
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param publisher_id query string false "发布者ID"
+// @Param search query string false "按名称搜索"
+// @Success 200 {object} map[string]interface{}
+// @Router /bundles [get]
+func ListBundles(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := available(models.GetDB().Model(&models.Bundle{}), time.Now())
+	if publisherID, err := uuid.Parse(c.Query("publisher_id")); err == nil {
+		query = query.Where("bundles.publisher_id = ?", publisherID)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("bundles.name ILIKE ?", "%"+search+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var list []models.Bundle
+	query.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).Preload("Items.Skill").
+		Order("bundles.created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// GetBundle 获取套餐详情
+// @Summary 套餐详情
+// @Description 返回套餐包含的技能，以及按地区/币种的套餐价、各技能单独购买的价格和分摊后的价格
+// @Tags bundles
+// @Accept json
+// @Produce json
+// @Param id path string true "套餐ID"
+// @Param region query string false "国家/地区代码"
+// @Param currency query string false "币种"
+// @Success 200 {object} map[string]interface{}
+// @Router /bundles/{id} [get]
+func GetBundle(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid bundle ID"})
+		return
+	}
+
+	db := models.GetDB()
+	var count int64
+	available(db.Model(&models.Bundle{}), time.Now()).Where("bundles.id = ?", id).Count(&count)
+	if count == 0 {
+		c.JSON(404, gin.H{"error": "Bundle not found"})
+		return
+	}
+	item, err := bundle.Load(db, id)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Bundle not found"})
+		return
+	}
+
+	quote, err := bundle.QuoteBundle(item, c.Query("region"), c.Query("currency"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"bundle": item,
+			"quote":  quote,
+		},
+	})
+}
+
+// PurchaseBundle 购买套餐
+// @Summary 购买套餐
+// @Description 创建套餐订单并返回支付链接；支付后授予套餐中每个技能的永久使用权。已拥有全部技能时拒绝购买
+// @Tags bundles
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "套餐ID"
+// @Param request body PurchaseRequest false "结算选项"
+// @Success 200 {object} map[string]interface{}
+// @Router /bundles/{id}/purchase [post]
+func PurchaseBundle(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	bundleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid bundle ID"})
+		return
+	}
+
+	var req PurchaseRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	db := models.GetDB()
+	order, err := orders.NewBundleOrder(db, userID, bundleID, req.Region, req.Currency)
+	if err != nil {
+		c.JSON(purchaseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := orders.ApplyCoupon(db, order, req.CouponCode); err != nil {
+		c.JSON(purchaseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := orders.ApplyTax(db, order); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to calculate tax"})
+		return
+	}
+	if err := orders.Screen(db, order, risk.Signals{IP: c.ClientIP(), CardFingerprint: req.CardFingerprint}); err != nil {
+		c.JSON(purchaseErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	paymentService, err := svcpayment.ServiceForOrder(*config.AppConfig, order, svcpayment.PaymentType(req.PaymentType))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := orders.Place(db, order); err != nil {
+		c.JSON(purchaseErrorStatus(err), gin.H{"error": "Failed to create order", "details": err.Error()})
+		return
+	}
+
+	// 风控挂起的订单待管理员审核通过后再支付
+	if order.Status == models.OrderStatusRiskReview {
+		c.JSON(200, gin.H{
+			"code":    0,
+			"message": "success",
+			"data":    gin.H{"order": order},
+		})
+		return
+	}
+
+	// 优惠后实付为零的订单无需经过支付网关
+	if order.Total.IsZero() {
+		if err := orders.SettleFree(db, order, orders.SourceCoupon); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to complete order", "details": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{
+			"code":    0,
+			"message": "success",
+			"data":    gin.H{"order": order},
+		})
+		return
+	}
+
+	paymentURL, err := paymentService.CreatePayment(order, orders.Subject(order))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to create payment", "details": err.Error()})
+		return
+	}
+	db.Model(order).Updates(map[string]interface{}{
+		"payment_method": string(paymentService.GetPaymentType()),
+		"payment_ref":    order.PaymentRef,
+	})
+	if orders.SandboxEnabled() {
+		if err := orders.SettleSandbox(order, paymentService.GetPaymentType()); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to complete order", "details": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"order":       order,
+			"payment_url": paymentURL,
+		},
+	})
+}
+
+// purchaseErrorStatus 购买套餐错误对应的HTTP状态码
+func purchaseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, bundle.ErrNotFound):
+		return 404
+	case errors.Is(err, bundle.ErrUnavailable), errors.Is(err, orders.ErrAlreadyOwned), orders.IsCouponError(err),
+		errors.Is(err, pricing.ErrNoRate), errors.Is(err, pricing.ErrInvalidCurrency):
+		return 400
+	}
+	return 500
+}
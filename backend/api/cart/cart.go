@@ -0,0 +1,325 @@
+package cart
+
+import (
+	"errors"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/gift"
+	"skillhub/services/orders"
+	svcpayment "skillhub/services/payment"
+	"skillhub/services/pricing"
+	"skillhub/services/risk"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// AddItemRequest 加入购物车请求
+type AddItemRequest struct {
+	SkillID uuid.UUID `json:"skill_id" binding:"required"`
+}
+
+// CheckoutRequest 购物车结算请求
+type CheckoutRequest struct {
+	SkillIDs    []uuid.UUID   `json:"skill_ids"`    // 为空时结算整个购物车
+	Region      string        `json:"region"`       // 国家/地区代码，可带省/州（如 US-CA），用于地区定价和计税
+	Currency    string        `json:"currency"`     // 支付币种，默认为第一个技能的价格币种
+	PaymentType string        `json:"payment_type"` // 支付方式，为空时按路由规则选择
+	CouponCode  string        `json:"coupon_code"`  // 优惠码
+	Gift        *gift.Options `json:"gift"`         // 作为礼品购买：支付后为每个技能生成兑换码
+	// CardFingerprint 支付卡指纹（如Stripe PaymentMethod的card.fingerprint），用于风控频率统计
+	CardFingerprint string `json:"card_fingerprint"`
+}
+
+// currentUser 从上下文获取当前用户ID
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// GetCart 获取购物车
+// @Summary 获取购物车
+// @Description 列出购物车中的技能及按当前地区/币种的报价
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param region query string false "国家/地区代码"
+// @Param currency query string false "报价币种"
+// @Param coupon_code query string false "优惠码"
+// @Success 200 {object} map[string]interface{}
+// @Router /cart [get]
+func GetCart(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := models.GetDB()
+	var items []models.CartItem
+	if err := db.Preload("Skill").Where("user_id = ?", userID).Order("created_at ASC").Find(&items).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load cart"})
+		return
+	}
+
+	skillIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		skillIDs = append(skillIDs, item.SkillID)
+	}
+
+	// 报价失败（如缺少汇率）时仍返回购物车内容，结算时再报错
+	data := gin.H{"items": items}
+	order, err := orders.NewOrder(db, userID, skillIDs, c.Query("region"), c.Query("currency"))
+	if err == nil {
+		err = orders.ApplyCoupon(db, order, c.Query("coupon_code"))
+	}
+	if err == nil {
+		err = orders.ApplyTax(db, order)
+	}
+	if err == nil {
+		data["total"] = order.Total
+		data["discount"] = order.Discount
+		data["tax"] = order.Tax
+		data["tax_inclusive"] = order.TaxInclusive
+		data["tax_lines"] = order.TaxLines
+	} else if !errors.Is(err, orders.ErrEmptyCart) {
+		data["error"] = err.Error()
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    data,
+	})
+}
+
+// AddItem 加入购物车
+// @Summary 加入购物车
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body AddItemRequest true "技能"
+// @Success 200 {object} models.CartItem
+// @Router /cart/items [post]
+func AddItem(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req AddItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var skill models.Skill
+	if err := db.First(&skill, "id = ? AND is_active = ?", req.SkillID, true).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Skill not found"})
+		return
+	}
+	if skill.PriceType == models.PriceTypeFree {
+		c.JSON(400, gin.H{"error": "This skill is free, no purchase required"})
+		return
+	}
+	if skill.PriceType == models.PriceTypeSubscription {
+		c.JSON(400, gin.H{"error": "This skill is sold by subscription"})
+		return
+	}
+	owned, err := orders.OwnedSkills(db, userID, []uuid.UUID{skill.ID})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to check purchases"})
+		return
+	}
+	if owned[skill.ID] {
+		c.JSON(400, gin.H{"error": "Skill already purchased"})
+		return
+	}
+
+	item := models.CartItem{ID: uuid.New(), UserID: userID, SkillID: skill.ID}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&item).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to add to cart"})
+		return
+	}
+	item.Skill = &skill
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    item,
+	})
+}
+
+// RemoveItem 从购物车移除
+// @Summary 从购物车移除技能
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param skill_id path string true "技能ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /cart/items/{skill_id} [delete]
+func RemoveItem(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	skillID, err := uuid.Parse(c.Param("skill_id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid skill ID"})
+		return
+	}
+
+	if err := models.GetDB().Where("user_id = ? AND skill_id = ?", userID, skillID).Delete(&models.CartItem{}).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to remove from cart"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+	})
+}
+
+// Checkout 结算购物车
+// @Summary 结算购物车
+// @Description 为购物车中的技能创建一个订单并返回支付链接，已购买或免费的技能会被拒绝
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CheckoutRequest false "结算选项"
+// @Success 200 {object} map[string]interface{}
+// @Router /cart/checkout [post]
+func Checkout(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req CheckoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	db := models.GetDB()
+	skillIDs := req.SkillIDs
+	if len(skillIDs) == 0 {
+		if err := db.Model(&models.CartItem{}).Where("user_id = ?", userID).
+			Order("created_at ASC").Pluck("skill_id", &skillIDs).Error; err != nil {
+			c.JSON(500, gin.H{"error": "Failed to load cart"})
+			return
+		}
+	}
+
+	var order *models.Order
+	var err error
+	if req.Gift != nil {
+		order, err = orders.NewGiftOrder(db, userID, skillIDs, *req.Gift, req.Region, req.Currency)
+	} else {
+		order, err = orders.NewOrder(db, userID, skillIDs, req.Region, req.Currency)
+	}
+	if err != nil {
+		c.JSON(checkoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := orders.ApplyCoupon(db, order, req.CouponCode); err != nil {
+		c.JSON(checkoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := orders.ApplyTax(db, order); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to calculate tax"})
+		return
+	}
+	if err := orders.Screen(db, order, risk.Signals{IP: c.ClientIP(), CardFingerprint: req.CardFingerprint}); err != nil {
+		c.JSON(checkoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	paymentService, err := svcpayment.ServiceForOrder(*config.AppConfig, order, svcpayment.PaymentType(req.PaymentType))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := orders.Place(db, order); err != nil {
+		c.JSON(checkoutErrorStatus(err), gin.H{"error": "Failed to create order", "details": err.Error()})
+		return
+	}
+	// 已下单的技能移出购物车
+	db.Where("user_id = ? AND skill_id IN ?", userID, skillIDs).Delete(&models.CartItem{})
+
+	// 风控挂起的订单待管理员审核通过后再支付
+	if order.Status == models.OrderStatusRiskReview {
+		c.JSON(200, gin.H{
+			"code":    0,
+			"message": "success",
+			"data":    gin.H{"order": order},
+		})
+		return
+	}
+
+	// 优惠后实付为零的订单无需经过支付网关
+	if order.Total.IsZero() {
+		if err := orders.SettleFree(db, order, orders.SourceCoupon); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to complete order", "details": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{
+			"code":    0,
+			"message": "success",
+			"data":    gin.H{"order": order},
+		})
+		return
+	}
+
+	paymentURL, err := paymentService.CreatePayment(order, orders.Subject(order))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to create payment", "details": err.Error()})
+		return
+	}
+	db.Model(order).Updates(map[string]interface{}{
+		"payment_method": string(paymentService.GetPaymentType()),
+		"payment_ref":    order.PaymentRef,
+	})
+	if orders.SandboxEnabled() {
+		if err := orders.SettleSandbox(order, paymentService.GetPaymentType()); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to complete order", "details": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"order":       order,
+			"payment_url": paymentURL,
+		},
+	})
+}
+
+// checkoutErrorStatus 结算错误对应的HTTP状态码
+func checkoutErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, orders.ErrSkillNotFound):
+		return 404
+	case errors.Is(err, orders.ErrEmptyCart), errors.Is(err, orders.ErrSkillFree), errors.Is(err, orders.ErrAlreadyOwned), errors.Is(err, orders.ErrSubscriptionRequired),
+		errors.Is(err, pricing.ErrInvalidCurrency), errors.Is(err, pricing.ErrNoRate), orders.IsCouponError(err),
+		errors.Is(err, gift.ErrInvalidEmail), errors.Is(err, gift.ErrInvalidQuantity):
+		return 400
+	}
+	return 500
+}
@@ -0,0 +1,157 @@
+package gifts
+
+import (
+	"errors"
+
+	"skillhub/models"
+	"skillhub/services/gift"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RedeemRequest 兑换礼品码请求
+type RedeemRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// currentUser 从上下文获取当前用户ID
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// ListSentGifts 获取当前用户购买的礼品
+// @Summary 我送出的礼品
+// @Description 列出礼品订单生成的兑换码及兑换状态，兑换码可转交他人
+// @Tags gifts
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param status query string false "礼品状态" Enums(unredeemed,redeemed,expired,refunded)
+// @Success 200 {object} map[string]interface{}
+// @Router /gifts [get]
+func ListSentGifts(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	query := models.GetDB().Preload("Skill").Where("purchaser_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var gifts []models.Gift
+	if err := query.Order("created_at DESC").Find(&gifts).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load gifts"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gifts,
+	})
+}
+
+// ListReceivedGifts 获取发送到当前用户邮箱的礼品
+// @Summary 我收到的礼品
+// @Description 列出收件人邮箱为当前用户已验证邮箱的礼品，以及当前用户已兑换的礼品
+// @Tags gifts
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /gifts/received [get]
+func ListReceivedGifts(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := models.GetDB()
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(404, gin.H{"error": "User not found"})
+		return
+	}
+
+	// 只按已验证的邮箱匹配收件人，否则任何人以收件人邮箱注册即可看到兑换码
+	query := db.Preload("Skill").Where("redeemed_by = ?", userID)
+	if email := gift.VerifiedEmail(&user); email != "" {
+		query = query.Or("recipient_email = ?", email)
+	}
+	var gifts []models.Gift
+	if err := query.Order("created_at DESC").Find(&gifts).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load gifts"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gifts,
+	})
+}
+
+// RedeemGift 兑换礼品码
+// @Summary 兑换礼品码
+// @Description 兑换礼品码获得技能的永久使用权；已拥有该技能时不能兑换，兑换码保持可用
+// @Tags gifts
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body RedeemRequest true "兑换码"
+// @Success 200 {object} map[string]interface{}
+// @Router /gifts/redeem [post]
+func RedeemGift(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req RedeemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(404, gin.H{"error": "User not found"})
+		return
+	}
+
+	redeemed, err := gift.Redeem(db, req.Code, &user)
+	if err != nil {
+		c.JSON(redeemErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	db.Preload("Skill").First(redeemed, "id = ?", redeemed.ID)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    redeemed,
+	})
+}
+
+// redeemErrorStatus 兑换错误对应的HTTP状态码
+func redeemErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gift.ErrNotFound):
+		return 404
+	case errors.Is(err, gift.ErrWrongRecipient), errors.Is(err, gift.ErrEmailNotVerified):
+		return 403
+	case errors.Is(err, gift.ErrAlreadyRedeemed), errors.Is(err, gift.ErrAlreadyOwned):
+		return 409
+	case errors.Is(err, gift.ErrExpired), errors.Is(err, gift.ErrRefunded):
+		return 410
+	}
+	return 500
+}
@@ -0,0 +1,167 @@
+package invoices
+
+import (
+	"errors"
+	"strings"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/invoice"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// currentUser 从上下文获取当前用户ID
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// GetOrderInvoice 获取订单的发票/收据
+// @Summary 订单发票
+// @Description 返回已支付订单的发票，尚未开具时按当前开票资料补开。format=html或pdf时返回可下载的收据
+// @Tags payment
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "订单ID"
+// @Param format query string false "返回格式" Enums(json,html,pdf) default(json)
+// @Success 200 {object} models.Invoice
+// @Router /payment/orders/{id}/invoice [get]
+func GetOrderInvoice(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	db := models.GetDB()
+	var order models.Order
+	if err := db.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Order not found"})
+		return
+	}
+
+	inv, err := invoice.ForOrder(db, config.AppConfig.Invoice, &order)
+	if err != nil {
+		if errors.Is(err, invoice.ErrNotInvoiceable) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to load invoice"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "html":
+		body, err := invoice.RenderHTML(inv)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to render invoice"})
+			return
+		}
+		c.Data(200, "text/html; charset=utf-8", body)
+	case "pdf":
+		c.Header("Content-Disposition", `attachment; filename="`+inv.InvoiceNo+`.pdf"`)
+		c.Data(200, "application/pdf", invoice.RenderPDF(inv))
+	default:
+		c.JSON(200, gin.H{
+			"code":    0,
+			"message": "success",
+			"data":    inv,
+		})
+	}
+}
+
+// GetBillingProfile 获取开票资料
+// @Summary 开票资料
+// @Description 返回当前用户的开票资料，未设置时data为空
+// @Tags payment
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} models.BillingProfile
+// @Router /payment/billing-profile [get]
+func GetBillingProfile(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var profile models.BillingProfile
+	err := models.GetDB().Where("user_id = ?", userID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(500, gin.H{"error": "Failed to load billing profile"})
+		return
+	}
+
+	var data interface{}
+	if err == nil {
+		data = profile
+	}
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    data,
+	})
+}
+
+// UpdateBillingProfile 保存开票资料
+// @Summary 保存开票资料
+// @Description 设置发票抬头、纳税人识别号等信息，用于之后开具的发票；已开具的发票需联系管理员重开
+// @Tags payment
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body invoice.Buyer true "开票资料"
+// @Success 200 {object} models.BillingProfile
+// @Router /payment/billing-profile [put]
+func UpdateBillingProfile(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req invoice.Buyer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	req.FapiaoTitle = strings.TrimSpace(req.FapiaoTitle)
+	req.Party.TaxID = strings.TrimSpace(req.Party.TaxID)
+	if err := req.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	var profile models.BillingProfile
+	err := db.Where("user_id = ?", userID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(500, gin.H{"error": "Failed to load billing profile"})
+		return
+	}
+	if err != nil {
+		profile = models.BillingProfile{ID: uuid.New(), UserID: userID}
+	}
+	profile.Party, profile.TitleType, profile.FapiaoTitle = req.Party, req.TitleType, req.FapiaoTitle
+	if err := db.Save(&profile).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to save billing profile"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    profile,
+	})
+}
@@ -1,15 +1,19 @@
 package payment
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
+	"log"
 	"net/url"
 	"skillhub/config"
 	"skillhub/models"
 	"strconv"
-	"time"
+	"skillhub/services/gift"
+	"skillhub/services/orders"
 	svcpayment "skillhub/services/payment"
+	"skillhub/services/refund"
+	"skillhub/services/risk"
+	"skillhub/services/subscription"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -17,7 +21,13 @@ import (
 
 // CreateOrderRequest 创建订单请求
 type CreateOrderRequest struct {
-	SkillID uuid.UUID `json:"skill_id" binding:"required"`
+	SkillID    uuid.UUID     `json:"skill_id" binding:"required"`
+	Region     string        `json:"region"`      // 国家/地区代码，可带省/州（如 US-CA），用于地区定价和计税
+	Currency   string        `json:"currency"`    // 支付币种，默认为价格币种
+	CouponCode string        `json:"coupon_code"` // 优惠码
+	Gift       *gift.Options `json:"gift"`        // 作为礼品购买：支付后生成兑换码或发送给收件人
+	// CardFingerprint 支付卡指纹（如Stripe PaymentMethod的card.fingerprint），用于风控频率统计
+	CardFingerprint string `json:"card_fingerprint"`
 }
 
 // CreateOrder 创建订单
@@ -31,8 +41,8 @@ type CreateOrderRequest struct {
 // @Success 200 {object} models.Order
 // @Router /payment/orders [post]
 func CreateOrder(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
 		c.JSON(401, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -45,47 +55,55 @@ func CreateOrder(c *gin.Context) {
 
 	db := models.GetDB()
 
-	// 查询技能
-	var skill models.Skill
-	if err := db.First(&skill, "id = ? AND is_active = ?", req.SkillID, true).Error; err != nil {
-		c.JSON(404, gin.H{"error": "Skill not found"})
+	// 按地区和币种定价，已购买或免费的技能会被拒绝（礼品订单不检查买家是否已购买）
+	var order *models.Order
+	if req.Gift != nil {
+		order, err = orders.NewGiftOrder(db, userID, []uuid.UUID{req.SkillID}, *req.Gift, req.Region, req.Currency)
+	} else {
+		order, err = orders.NewOrder(db, userID, []uuid.UUID{req.SkillID}, req.Region, req.Currency)
+	}
+	if err != nil {
+		if errors.Is(err, orders.ErrSkillNotFound) {
+			c.JSON(404, gin.H{"error": "Skill not found"})
+			return
+		}
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-
-	// 检查是否已购买
-	var existingOrder models.Order
-	if err := db.Where("user_id = ? AND skill_id = ?", userID, req.SkillID).
-		First(&existingOrder).Error; err == nil {
-		c.JSON(400, gin.H{"error": "Skill already purchased"})
+	if err := orders.ApplyCoupon(db, order, req.CouponCode); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
-
-	// 创建订单
-	orderNo := "ORD" + uuid.New().String()[:8]
-	order := models.Order{
-		ID:          uuid.New(),
-		OrderNo:     orderNo,
-		UserID:      userID.(uuid.UUID),
-		TotalAmount: skill.Price,
-		Status:      models.OrderStatusPending,
+	if err := orders.ApplyTax(db, order); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to calculate tax"})
+		return
+	}
+	if err := orders.Screen(db, order, risk.Signals{IP: c.ClientIP(), CardFingerprint: req.CardFingerprint}); err != nil {
+		if errors.Is(err, risk.ErrBlocked) {
+			c.JSON(429, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to check order risk"})
+		return
 	}
 
-	if err := db.Create(&order).Error; err != nil {
+	if err := orders.Place(db, order); err != nil {
+		if orders.IsCouponError(err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(500, gin.H{"error": "Failed to create order"})
 		return
 	}
 
-	// 创建订单项
-	orderItem := models.OrderItem{
-		ID:      uuid.New(),
-		OrderID: order.ID,
-		SkillID: &req.SkillID,
-		Price:   skill.Price,
-		Quantity: 1,
+	// 优惠后实付为零的订单无需支付（风控挂起的订单审核通过后再完成）
+	if order.Total.IsZero() && order.Status == models.OrderStatusPending {
+		if err := orders.SettleFree(db, order, orders.SourceCoupon); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to complete order", "details": err.Error()})
+			return
+		}
 	}
 
-	db.Create(&orderItem)
-
 	c.JSON(200, gin.H{
 		"code":    0,
 		"message": "success",
@@ -101,6 +119,8 @@ func CreateOrder(c *gin.Context) {
 // @Produce json
 // @Security Bearer
 // @Param id path string true "订单ID"
+// @Param payment_type query string false "支付方式，为空时按路由规则选择" Enums(alipay,wechat,stripe,paypal,mock)
+// @Param flow query string false "微信支付方式" Enums(native,h5) default(native)
 // @Success 200 {object} map[string]interface{}
 // @Router /payment/orders/{id}/pay [post]
 func GetPaymentURL(c *gin.Context) {
@@ -126,30 +146,52 @@ func GetPaymentURL(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "Order not found"})
 		return
 	}
-
-	// 获取支付服务
-	paymentService := svcpayment.GetDefaultPaymentService(*config.AppConfig)
-
-	// 获取技能名称作为支付主题
-	var skillName string
-	var orderItem models.OrderItem
-	if err := db.Where("order_id = ?", order.ID).First(&orderItem).Error; err == nil && orderItem.SkillID != nil {
-		var skill models.Skill
-		if err := db.First(&skill, "id = ?", orderItem.SkillID).Error; err == nil {
-			skillName = skill.Name
-		}
+	// 只有待支付订单可以获取支付链接，已支付、已取消、已退款或审核中的订单再次付款会重复扣款
+	if order.Status == models.OrderStatusRiskReview {
+		c.JSON(409, gin.H{"error": "Order is under review and cannot be paid yet"})
+		return
+	}
+	if order.Status != models.OrderStatusPending {
+		c.JSON(409, gin.H{"error": "Order is not awaiting payment", "status": order.Status})
+		return
 	}
-	if skillName == "" {
-		skillName = fmt.Sprintf("Skill Order #%s", order.OrderNo)
+
+	// 买家指定的支付方式，未指定时按路由规则选择支持订单币种的支付服务
+	paymentType := svcpayment.PaymentType(c.Query("payment_type"))
+	paymentService, err := svcpayment.ServiceForOrder(*config.AppConfig, &order, paymentType)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 生成支付链接
-	paymentURL, err := paymentService.CreatePayment(&order, skillName)
+	// 支付主题列出订单中的技能
+	subject := orders.LoadSubject(db, &order)
+
+	// 生成支付链接（微信支付可通过flow=h5选择H5支付，默认Native扫码）
+	var paymentURL string
+	if wechatClient, ok := paymentService.(*svcpayment.WeChatPayClient); ok && c.Query("flow") == "h5" {
+		paymentURL, err = wechatClient.CreateH5Payment(&order, subject, c.ClientIP())
+	} else {
+		paymentURL, err = paymentService.CreatePayment(&order, subject)
+	}
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to create payment", "details": err.Error()})
 		return
 	}
 
+	// 记录支付方式和网关侧订单号（PayPal捕获、对账查询需要）
+	db.Model(&order).Updates(map[string]interface{}{
+		"payment_method": string(paymentService.GetPaymentType()),
+		"payment_ref":    order.PaymentRef,
+	})
+	// 支付沙箱下自动确认付款，否则订单保持待支付直到网关回调或对账查询确认
+	if orders.SandboxEnabled() {
+		if err := orders.SettleSandbox(&order, paymentService.GetPaymentType()); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to complete payment", "details": err.Error()})
+			return
+		}
+	}
+
 	c.JSON(200, gin.H{
 		"code":    0,
 		"message": "success",
@@ -157,10 +199,26 @@ func GetPaymentURL(c *gin.Context) {
 			"payment_url": paymentURL,
 			"order_id":    order.ID.String(),
 			"order_no":    order.OrderNo,
+			"status":      order.Status,
 		},
 	})
 }
 
+// ListProviders 获取可用支付方式
+// @Summary 获取可用支付方式
+// @Description 列出已启用的支付方式及其支持的币种和支付流程
+// @Tags payment
+// @Produce json
+// @Success 200 {array} svcpayment.ProviderInfo
+// @Router /payment/providers [get]
+func ListProviders(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    svcpayment.EnabledProviders(*config.AppConfig),
+	})
+}
+
 // AlipayCallback 支付宝回调
 // @Summary 支付宝支付回调
 // @Description 支付宝支付成功后的回调
@@ -181,8 +239,12 @@ func AlipayCallback(c *gin.Context) {
 		params = c.Request.URL.Query()
 	}
 
-	// 获取支付服务
-	paymentService := svcpayment.GetDefaultPaymentService(*config.AppConfig)
+	// 回调始终使用支付宝客户端，与默认支付方式无关
+	paymentService, err := svcpayment.GetPaymentService(svcpayment.PaymentTypeAlipay, *config.AppConfig)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Alipay client not available"})
+		return
+	}
 
 	// 处理回调
 	callbackResult, err := paymentService.ProcessCallback(params)
@@ -192,45 +254,9 @@ func AlipayCallback(c *gin.Context) {
 		return
 	}
 
-	// 根据交易状态更新订单
-	db := models.GetDB()
-	var order models.Order
-	if err := db.Where("order_no = ?", callbackResult.OutTradeNo).First(&order).Error; err != nil {
-		c.JSON(404, gin.H{"error": "Order not found"})
-		return
-	}
-
-	// 更新订单状态
-	switch callbackResult.TradeStatus {
-	case "TRADE_SUCCESS", "TRADE_FINISHED":
-		now := time.Now()
-		order.Status = models.OrderStatusPaid
-		order.PaidAt = &now
-		order.PaymentMethod = string(callbackResult.PaymentType)
-
-		// 创建交易记录
-		transaction := models.Transaction{
-			ID:             uuid.New(),
-			OrderID:        order.ID,
-			PaymentChannel: string(callbackResult.PaymentType),
-			TransactionID:  callbackResult.TradeNo,
-			Amount:         parseFloat(callbackResult.TotalAmount),
-			Status:         models.TransactionStatusSuccess,
-			RawResponse:    marshalParams(callbackResult.RawParams),
-		}
-		db.Create(&transaction)
-
-	case "TRADE_CLOSED":
-		order.Status = models.OrderStatusCancelled
-
-	case "WAIT_BUYER_PAY":
-		// 等待支付，不做处理
-	default:
-		// 其他状态
-	}
-
-	if err := db.Save(&order).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update order"})
+	// 根据交易状态更新订单（退款通知更新退款记录）
+	if err := updateOrderFromCallback(callbackResult); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update order", "details": err.Error()})
 		return
 	}
 
@@ -260,8 +286,12 @@ func StripeCallback(c *gin.Context) {
 		return
 	}
 
-	// 获取支付服务
-	paymentService := svcpayment.GetDefaultPaymentService(*config.AppConfig)
+	// 获取Stripe支付服务（未配置时为模拟客户端）
+	paymentService, err := svcpayment.GetPaymentService(svcpayment.PaymentTypeStripe, *config.AppConfig)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Stripe client not available"})
+		return
+	}
 
 	// 尝试转换为Stripe客户端
 	stripeClient, ok := paymentService.(*svcpayment.StripeClient)
@@ -278,7 +308,10 @@ func StripeCallback(c *gin.Context) {
 			c.JSON(400, gin.H{"error": "Failed to process webhook", "details": err.Error()})
 			return
 		}
-		updateOrderFromCallback(callbackResult)
+		if err := updateOrderFromCallback(callbackResult); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to update order", "details": err.Error()})
+			return
+		}
 		c.JSON(200, gin.H{"status": "success"})
 		return
 	}
@@ -290,7 +323,10 @@ func StripeCallback(c *gin.Context) {
 		return
 	}
 
-	updateOrderFromCallback(callbackResult)
+	if err := updateOrderFromCallback(callbackResult); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update order", "details": err.Error()})
+		return
+	}
 	c.JSON(200, gin.H{"status": "success"})
 }
 
@@ -309,88 +345,179 @@ func PayPalCallback(c *gin.Context) {
 		return
 	}
 
-	// 获取签名
-	signature := c.GetHeader("PayPal-Transmission-Sig")
-	if signature == "" {
-		// PayPal可能使用其他头，尝试获取
-		signature = c.GetHeader("Paypal-Transmission-Signature")
+	// 回调始终使用PayPal客户端，与默认支付方式无关
+	paymentService, err := svcpayment.GetPaymentService(svcpayment.PaymentTypePayPal, *config.AppConfig)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "PayPal client not available"})
+		return
 	}
 
-	// 获取支付服务
-	paymentService := svcpayment.GetDefaultPaymentService(*config.AppConfig)
+	var callbackResult *svcpayment.CallbackResult
+	switch client := paymentService.(type) {
+	case *svcpayment.PayPalClient:
+		// 通过verify-webhook-signature验证传输签名后处理事件
+		callbackResult, err = client.ProcessWebhook(payload, c.Request.Header)
+	case *svcpayment.MockPayPalClient:
+		callbackResult, err = client.ProcessWebhook(payload, c.Request.Header)
+	default:
+		c.JSON(500, gin.H{"error": "PayPal client not available"})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Failed to process webhook", "details": err.Error()})
+		return
+	}
 
-	// 尝试转换为PayPal客户端
+	if err := updateOrderFromCallback(callbackResult); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update order", "details": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "success"})
+}
+
+// CapturePayPalOrderRequest PayPal捕获请求
+type CapturePayPalOrderRequest struct {
+	Token string `json:"token" binding:"required"` // return_url携带的PayPal订单ID
+}
+
+// CapturePayPalOrder 捕获PayPal订单
+// @Summary 捕获PayPal订单
+// @Description 买家在PayPal批准付款后跳回return_url，前端携带token调用此接口完成扣款
+// @Tags payment
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body CapturePayPalOrderRequest true "PayPal订单ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /payment/paypal/capture [post]
+func CapturePayPalOrder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req CapturePayPalOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+
+	var order models.Order
+	if err := db.Where("payment_ref = ? AND user_id = ?", req.Token, userID).
+		First(&order).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Order not found"})
+		return
+	}
+
+	paymentService, err := svcpayment.GetPaymentService(svcpayment.PaymentTypePayPal, *config.AppConfig)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "PayPal client not available"})
+		return
+	}
 	paypalClient, ok := paymentService.(*svcpayment.PayPalClient)
 	if !ok {
-		// 如果不是PayPal客户端，尝试模拟客户端
-		mockClient, ok := paymentService.(*svcpayment.MockPayPalClient)
-		if !ok {
-			c.JSON(500, gin.H{"error": "PayPal client not available"})
-			return
-		}
-		// 使用模拟客户端处理
-		callbackResult, err := mockClient.ProcessWebhook(payload, signature)
-		if err != nil {
-			c.JSON(400, gin.H{"error": "Failed to process webhook", "details": err.Error()})
-			return
-		}
-		updateOrderFromCallback(callbackResult)
-		c.JSON(200, gin.H{"status": "success"})
+		c.JSON(500, gin.H{"error": "PayPal client not available"})
 		return
 	}
 
-	// 使用真实PayPal客户端处理Webhook
-	callbackResult, err := paypalClient.ProcessWebhook(payload, signature)
+	callbackResult, err := paypalClient.CaptureOrder(req.Token)
 	if err != nil {
-		c.JSON(400, gin.H{"error": "Failed to process webhook", "details": err.Error()})
+		c.JSON(502, gin.H{"error": "Failed to capture payment", "details": err.Error()})
+		return
+	}
+	if callbackResult.OutTradeNo != order.OrderNo {
+		c.JSON(400, gin.H{"error": "Captured order does not match"})
 		return
 	}
 
-	updateOrderFromCallback(callbackResult)
-	c.JSON(200, gin.H{"status": "success"})
+	if err := updateOrderFromCallback(callbackResult); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to update order", "details": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"order_id": order.ID.String(),
+			"order_no": order.OrderNo,
+			"status":   callbackResult.TradeStatus,
+		},
+	})
 }
 
-// updateOrderFromCallback 根据回调结果更新订单
-func updateOrderFromCallback(callbackResult *svcpayment.CallbackResult) {
-	db := models.GetDB()
-	if db == nil {
+// WeChatCallback 微信支付回调
+// @Summary 微信支付回调
+// @Description 微信支付APIv3支付结果通知，验证签名并解密资源后更新订单
+// @Tags payment
+// @Accept json
+// @Produce json
+// @Router /payment/callback/wechat [post]
+func WeChatCallback(c *gin.Context) {
+	// 读取请求体
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"code": "FAIL", "message": "Failed to read request body"})
 		return
 	}
 
-	var order models.Order
-	if err := db.Where("order_no = ?", callbackResult.OutTradeNo).First(&order).Error; err != nil {
-		return
-	}
-
-	// 更新订单状态
-	switch callbackResult.TradeStatus {
-	case "TRADE_SUCCESS", "TRADE_FINISHED", "succeeded", "COMPLETED":
-		now := time.Now()
-		order.Status = models.OrderStatusPaid
-		order.PaidAt = &now
-		order.PaymentMethod = string(callbackResult.PaymentType)
-
-		// 创建交易记录
-		transaction := models.Transaction{
-			ID:             uuid.New(),
-			OrderID:        order.ID,
-			PaymentChannel: string(callbackResult.PaymentType),
-			TransactionID:  callbackResult.TradeNo,
-			Amount:         parseFloat(callbackResult.TotalAmount),
-			Status:         models.TransactionStatusSuccess,
-			RawResponse:    marshalParams(callbackResult.RawParams),
-		}
-		db.Create(&transaction)
+	// 回调始终使用微信支付客户端，与默认支付方式无关
+	paymentService, err := svcpayment.GetPaymentService(svcpayment.PaymentTypeWeChat, *config.AppConfig)
+	if err != nil {
+		c.JSON(500, gin.H{"code": "FAIL", "message": "WeChat Pay client not available"})
+		return
+	}
 
-	case "TRADE_CLOSED", "CANCELLED":
-		order.Status = models.OrderStatusCancelled
-	case "WAIT_BUYER_PAY", "PENDING":
-		// 等待支付，不做处理
-	default:
-		// 其他状态
+	notifier, ok := paymentService.(svcpayment.NotificationProcessor)
+	if !ok {
+		c.JSON(500, gin.H{"code": "FAIL", "message": "WeChat Pay client not available"})
+		return
 	}
 
-	db.Save(&order)
+	callbackResult, err := notifier.ProcessNotification(c.Request.Header, payload)
+	if err != nil {
+		// 返回非2xx状态码，微信支付会按策略重新通知
+		c.JSON(401, gin.H{"code": "FAIL", "message": err.Error()})
+		return
+	}
+
+	if err := updateOrderFromCallback(callbackResult); err != nil {
+		c.JSON(500, gin.H{"code": "FAIL", "message": "Failed to update order"})
+		return
+	}
+
+	// 微信支付要求应答200或204表示接收成功
+	c.Status(204)
+}
+
+// updateOrderFromCallback 根据回调结果更新订单，返回错误时网关应重试通知
+func updateOrderFromCallback(callbackResult *svcpayment.CallbackResult) error {
+	// 退款通知交给退款服务处理
+	if callbackResult.Refund != nil {
+		return refund.ApplyResult(callbackResult.Refund)
+	}
+	// 订阅续费、扣款失败和取消通知
+	if event := callbackResult.Subscription; event != nil {
+		err := orders.ApplySubscriptionEvent(event)
+		if errors.Is(err, subscription.ErrNotFound) || errors.Is(err, orders.ErrInvalidTransition) {
+			log.Printf("Ignored %s subscription event for %s: %v", event.PaymentType, event.Ref, err)
+			return nil
+		}
+		return err
+	}
+
+	source := orders.SourceCallback + ":" + string(callbackResult.PaymentType)
+	_, err := orders.ApplyPayment(callbackResult, source)
+	if errors.Is(err, orders.ErrInvalidTransition) || errors.Is(err, orders.ErrOrderNotFound) {
+		// 重试也无法处理，记录后应答成功，避免网关反复通知
+		log.Printf("Ignored payment callback for order %s (trade %s): %v",
+			callbackResult.OutTradeNo, callbackResult.TradeNo, err)
+		return nil
+	}
+	return err
 }
 
 // GetOrders 获取用户订单列表
@@ -441,32 +568,13 @@ func GetOrders(c *gin.Context) {
 	})
 }
 
-// parseFloat 解析字符串为浮点数
-func parseFloat(s string) float64 {
-	var result float64
-	fmt.Sscanf(s, "%f", &result)
-	return result
-}
-
-// marshalParams 将URL参数序列化为JSON字符串
-func marshalParams(params url.Values) string {
-	data := make(map[string]string)
-	for k, v := range params {
-		if len(v) > 0 {
-			data[k] = v[0]
-		}
-	}
-	jsonData, _ := json.Marshal(data)
-	return string(jsonData)
-}
-
 // MockCallbackRequest 模拟支付回调请求
 type MockCallbackRequest struct {
 	OrderNo     string `json:"order_no" binding:"required"`
 	TradeStatus string `json:"trade_status" binding:"required"`
 	PaymentType string `json:"payment_type"`
 	TradeNo     string `json:"trade_no"`
-	TotalAmount string `json:"total_amount"`
+	TotalAmount string `json:"total_amount" binding:"required"` // 与真实回调一样需与订单金额一致
 }
 
 // MockCallback 模拟支付回调
@@ -479,53 +587,41 @@ type MockCallbackRequest struct {
 // @Success 200 {string} string "success"
 // @Router /payment/callback/mock [post]
 func MockCallback(c *gin.Context) {
+	// 模拟回调不经过签名验证，只在支付沙箱下可用
+	if !orders.SandboxEnabled() {
+		c.JSON(404, gin.H{"error": "Payment sandbox is disabled"})
+		return
+	}
+
 	var req MockCallbackRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	db := models.GetDB()
+	paymentType := svcpayment.PaymentType(req.PaymentType)
+	if paymentType == "" {
+		paymentType = svcpayment.PaymentTypeMock
+	}
 
-	// 查找订单
-	var order models.Order
-	if err := db.Where("order_no = ?", req.OrderNo).First(&order).Error; err != nil {
+	// 与真实回调走同一状态机
+	_, err := orders.ApplyPayment(&svcpayment.CallbackResult{
+		TradeNo:     req.TradeNo,
+		OutTradeNo:  req.OrderNo,
+		TradeStatus: req.TradeStatus,
+		TotalAmount: req.TotalAmount,
+		MerchantID:  svcpayment.ExpectedMerchantID(paymentType, *config.AppConfig),
+		RawParams:   url.Values{"trade_status": {req.TradeStatus}},
+		PaymentType: paymentType,
+	}, orders.SourceMock)
+	switch {
+	case errors.Is(err, orders.ErrOrderNotFound):
 		c.JSON(404, gin.H{"error": "Order not found"})
 		return
-	}
-
-	// 更新订单状态
-	switch req.TradeStatus {
-	case "TRADE_SUCCESS", "TRADE_FINISHED":
-		now := time.Now()
-		order.Status = models.OrderStatusPaid
-		order.PaidAt = &now
-		order.PaymentMethod = req.PaymentType
-		if req.PaymentType == "" {
-			order.PaymentMethod = "mock"
-		}
-
-		// 创建交易记录
-		transaction := models.Transaction{
-			ID:             uuid.New(),
-			OrderID:        order.ID,
-			PaymentChannel: req.PaymentType,
-			TransactionID:  req.TradeNo,
-			Amount:         parseFloat(req.TotalAmount),
-			Status:         models.TransactionStatusSuccess,
-			RawResponse:    fmt.Sprintf("mock callback: %+v", req),
-		}
-		db.Create(&transaction)
-
-	case "TRADE_CLOSED":
-		order.Status = models.OrderStatusCancelled
-	case "WAIT_BUYER_PAY":
-		// 等待支付，不做处理
-	default:
-		// 其他状态
-	}
-
-	if err := db.Save(&order).Error; err != nil {
+	case errors.Is(err, orders.ErrInvalidTransition):
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		c.JSON(500, gin.H{"error": "Failed to update order"})
 		return
 	}
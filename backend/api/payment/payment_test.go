@@ -0,0 +1,207 @@
+package payment
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	svcauth "skillhub/services/auth"
+	"skillhub/services/entitlement"
+	svcpayment "skillhub/services/payment"
+	"skillhub/services/payment/paymenttest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// newTestRouter 使用与main.go相同的路由注册（鉴权中间件、免鉴权的回调分组）
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router.Group("/api/v1"))
+	return router
+}
+
+// newVerifiedUser 创建已验证邮箱的用户并签发访问令牌
+func newVerifiedUser(t *testing.T) (models.User, string) {
+	t.Helper()
+	now := time.Now()
+	user := models.User{ID: uuid.New(), Email: uuid.New().String() + "@example.com", IsActive: true, EmailVerifiedAt: &now}
+	if err := models.GetDB().Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	tokens, err := svcauth.IssueSession(models.GetDB(), &user, svcauth.Client{})
+	if err != nil {
+		t.Fatalf("IssueSession failed: %v", err)
+	}
+	return user, tokens.Token
+}
+
+// TestPurchaseThroughSimulatedGateway 走完"下单→获取支付链接→网关回调→授予使用权"的完整流程，
+// 回调由模拟网关签名并经真实客户端验签，且不携带登录令牌。需要PostgreSQL：设置E2E_DATABASE=1及DB_*环境变量后运行
+func TestPurchaseThroughSimulatedGateway(t *testing.T) {
+	if os.Getenv("E2E_DATABASE") == "" {
+		t.Skip("set E2E_DATABASE=1 and DB_* to run against PostgreSQL")
+	}
+
+	gateway, err := paymenttest.New()
+	if err != nil {
+		t.Fatalf("failed to start gateway simulator: %v", err)
+	}
+	defer gateway.Close()
+
+	server := httptest.NewServer(newTestRouter())
+	defer server.Close()
+	gateway.RouteWebhooks(server.URL + "/api/v1/payment/callback")
+
+	cfg := config.LoadConfig()
+	cfg.Payment = gateway.Config()
+	config.AppConfig = cfg
+	if err := models.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	db := models.GetDB()
+
+	post := func(path string, token string, body interface{}) map[string]interface{} {
+		t.Helper()
+		payload, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", server.URL+path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Data  map[string]interface{} `json:"data"`
+			Error string                 `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&out)
+		if resp.StatusCode != 200 {
+			t.Fatalf("POST %s = %d: %s", path, resp.StatusCode, out.Error)
+		}
+		return out.Data
+	}
+
+	tests := []struct {
+		provider svcpayment.PaymentType
+		currency string
+		scenario paymenttest.Scenario
+		status   models.OrderStatus
+	}{
+		{svcpayment.PaymentTypeAlipay, "CNY", paymenttest.Scenario{Duplicates: 1}, models.OrderStatusPaid},
+		{svcpayment.PaymentTypeStripe, "USD", paymenttest.Scenario{}, models.OrderStatusPaid},
+		{svcpayment.PaymentTypePayPal, "USD", paymenttest.Scenario{}, models.OrderStatusPaid},
+		{svcpayment.PaymentTypeWeChat, "CNY", paymenttest.Scenario{Duplicates: 1}, models.OrderStatusPaid},
+		{svcpayment.PaymentTypeAlipay, "CNY", paymenttest.Scenario{Decline: true}, models.OrderStatusCancelled},
+		{svcpayment.PaymentTypeStripe, "USD", paymenttest.Scenario{AmountDelta: -100}, models.OrderStatusPaymentReview},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.provider), func(t *testing.T) {
+			user, token := newVerifiedUser(t)
+			skill := models.Skill{ID: uuid.New(), Name: "E2E " + string(tt.provider), PriceType: models.PriceTypePaid,
+				Price: models.NewMoney(1999, tt.currency), IsActive: true}
+			if err := db.Create(&skill).Error; err != nil {
+				t.Fatalf("failed to create skill: %v", err)
+			}
+
+			order := post("/api/v1/payment/orders", token, map[string]interface{}{"skill_id": skill.ID})
+			pay := post("/api/v1/payment/orders/"+order["id"].(string)+"/pay?payment_type="+string(tt.provider), token, nil)
+
+			deliveries, err := gateway.Pay(pay["payment_url"].(string), tt.scenario)
+			if err != nil {
+				t.Fatalf("Pay failed: %v", err)
+			}
+			for _, d := range deliveries {
+				if !d.OK() {
+					t.Errorf("callback %s was not accepted: %d %s %v", d.EventID, d.StatusCode, d.Body, d.Err)
+				}
+			}
+
+			var saved models.Order
+			db.First(&saved, "order_no = ?", pay["order_no"])
+			if saved.Status != tt.status {
+				t.Errorf("order status = %s, want %s", saved.Status, tt.status)
+			}
+			var transactions int64
+			db.Model(&models.Transaction{}).Where("order_id = ?", saved.ID).Count(&transactions)
+			hasAccess, _ := entitlement.HasAccess(db, user.ID, skill.ID)
+			paid := tt.status == models.OrderStatusPaid
+			if hasAccess != paid {
+				t.Errorf("HasAccess = %v, want %v", hasAccess, paid)
+			}
+			if paid && transactions != 1 {
+				t.Errorf("recorded %d transactions, want 1", transactions)
+			}
+		})
+	}
+}
+
+// TestGetPaymentURLRejectsCancelledOrder 已取消的订单不能再获取支付链接（否则会重复扣款），沙箱下也不会再次确认付款。
+// 需要PostgreSQL：设置E2E_DATABASE=1及DB_*环境变量后运行
+func TestGetPaymentURLRejectsCancelledOrder(t *testing.T) {
+	if os.Getenv("E2E_DATABASE") == "" {
+		t.Skip("set E2E_DATABASE=1 and DB_* to run against PostgreSQL")
+	}
+
+	cfg := config.LoadConfig()
+	cfg.Payment.Sandbox = true
+	config.AppConfig = cfg
+	if err := models.InitDB(); err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	db := models.GetDB()
+
+	user, token := newVerifiedUser(t)
+	order := models.Order{ID: uuid.New(), OrderNo: "ORDE2E" + uuid.New().String()[:8], UserID: user.ID,
+		Total: models.NewMoney(1999, "CNY"), Status: models.OrderStatusCancelled}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatalf("failed to create order: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/payment/orders/"+order.ID.String()+"/pay?payment_type=alipay", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	newTestRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("pay cancelled order = %d %s, want 409", w.Code, w.Body.String())
+	}
+
+	var saved models.Order
+	db.First(&saved, "id = ?", order.ID)
+	if saved.Status != models.OrderStatusCancelled || saved.PaymentRef != "" {
+		t.Errorf("cancelled order changed: status %s, payment ref %q", saved.Status, saved.PaymentRef)
+	}
+}
+
+// TestCallbackRoutesSkipAuth 网关回调不经过鉴权中间件，携带无效令牌也能到达处理函数；其他支付接口仍需登录
+func TestCallbackRoutesSkipAuth(t *testing.T) {
+	previous := config.AppConfig
+	defer func() { config.AppConfig = previous }()
+	config.AppConfig = config.LoadConfig()
+	config.AppConfig.Payment.Sandbox = false
+
+	router := newTestRouter()
+	send := func(path string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("Authorization", "Bearer invalid")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 沙箱关闭时模拟回调由处理函数返回404
+	if code := send("/api/v1/payment/callback/mock"); code != http.StatusNotFound {
+		t.Errorf("mock callback = %d, want 404 from the handler", code)
+	}
+	if code := send("/api/v1/payment/orders"); code != http.StatusUnauthorized {
+		t.Errorf("create order with invalid token = %d, want 401", code)
+	}
+}
@@ -0,0 +1,28 @@
+package payment
+
+import (
+	"skillhub/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 在v1下注册支付路由，返回需登录的 /payment 路由组供发票等接口继续挂载。
+// 网关回调单独分组且不经过鉴权中间件：网关不携带登录令牌，回调的真实性由各渠道验签保证
+func RegisterRoutes(v1 *gin.RouterGroup) *gin.RouterGroup {
+	paymentGroup := v1.Group("/payment")
+	paymentGroup.Use(middleware.AuthMiddleware())
+	paymentGroup.GET("/providers", ListProviders)
+	paymentGroup.POST("/orders", middleware.RequireVerifiedEmail(), CreateOrder)
+	paymentGroup.GET("/orders", GetOrders)
+	paymentGroup.POST("/orders/:id/pay", middleware.RequireVerifiedEmail(), GetPaymentURL)
+	paymentGroup.POST("/paypal/capture", CapturePayPalOrder)
+
+	callbackGroup := v1.Group("/payment/callback")
+	callbackGroup.POST("/alipay", AlipayCallback)
+	callbackGroup.POST("/wechat", WeChatCallback)
+	callbackGroup.POST("/stripe", StripeCallback)
+	callbackGroup.POST("/paypal", PayPalCallback)
+	callbackGroup.POST("/mock", MockCallback)
+
+	return paymentGroup
+}
@@ -0,0 +1,278 @@
+package publisher
+
+import (
+	"strconv"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/bundle"
+	"skillhub/services/earnings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// currentUser 从上下文获取当前用户ID
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// GetBalance 获取收益余额
+// @Summary 发布者收益余额
+// @Description 按币种返回结算期内、可提现、付款中和已付款的收益
+// @Tags publisher
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} earnings.Balance
+// @Router /publisher/balance [get]
+func GetBalance(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	balances, err := earnings.Balances(models.GetDB(), userID, time.Now())
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load balance"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    balances,
+	})
+}
+
+// ListEarnings 获取收益明细
+// @Summary 发布者收益明细
+// @Tags publisher
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param kind query string false "类型" Enums(sale,clawback)
+// @Success 200 {object} map[string]interface{}
+// @Router /publisher/earnings [get]
+func ListEarnings(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.PublisherEarning{}).Where("publisher_id = ?", userID)
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var list []models.PublisherEarning
+	query.Preload("Skill").Order("created_at DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&list)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ListPayouts 获取付款记录
+// @Summary 发布者付款记录
+// @Tags publisher
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /publisher/payouts [get]
+func ListPayouts(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+
+	query := models.GetDB().Model(&models.Payout{}).Where("publisher_id = ?", userID)
+
+	var total int64
+	query.Count(&total)
+
+	var list []models.Payout
+	query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list)
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"list":      list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+// ListBundles 获取发布者创建的套餐
+// @Summary 我的套餐
+// @Description 列出发布者创建的全部套餐（含停用和不在销售时间内的）
+// @Tags publisher
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /publisher/bundles [get]
+func ListBundles(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var list []models.Bundle
+	if err := models.GetDB().Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).Preload("Items.Skill").
+		Where("publisher_id = ?", userID).Order("created_at DESC").Find(&list).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load bundles"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    list,
+	})
+}
+
+// CreateBundle 创建套餐
+// @Summary 创建套餐
+// @Description 套餐只能包含发布者自己上架的付费技能，至少2个
+// @Tags publisher
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body bundle.Definition true "套餐"
+// @Success 200 {object} models.Bundle
+// @Router /publisher/bundles [post]
+func CreateBundle(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var def bundle.Definition
+	if err := c.ShouldBindJSON(&def); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	item := models.Bundle{ID: uuid.New(), PublisherID: &userID}
+	saveBundle(c, &item, def, true)
+}
+
+// UpdateBundle 更新套餐（整体替换定义）
+// @Summary 更新套餐
+// @Tags publisher
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "套餐ID"
+// @Param request body bundle.Definition true "套餐"
+// @Success 200 {object} models.Bundle
+// @Router /publisher/bundles/{id} [put]
+func UpdateBundle(c *gin.Context) {
+	item, ok := ownBundle(c)
+	if !ok {
+		return
+	}
+
+	var def bundle.Definition
+	if err := c.ShouldBindJSON(&def); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	saveBundle(c, item, def, false)
+}
+
+// DeleteBundle 删除套餐，已售出的套餐只停用
+// @Summary 删除套餐
+// @Tags publisher
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "套餐ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /publisher/bundles/{id} [delete]
+func DeleteBundle(c *gin.Context) {
+	item, ok := ownBundle(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := bundle.Delete(models.GetDB(), item)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    gin.H{"deleted": deleted, "deactivated": !deleted},
+	})
+}
+
+// ownBundle 读取路径中的套餐，只能操作当前发布者自己的套餐
+func ownBundle(c *gin.Context) (*models.Bundle, bool) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid bundle ID"})
+		return nil, false
+	}
+
+	var item models.Bundle
+	if err := models.GetDB().Where("id = ? AND publisher_id = ?", id, userID).First(&item).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Bundle not found"})
+		return nil, false
+	}
+	return &item, true
+}
+
+// saveBundle 保存套餐并返回结果
+func saveBundle(c *gin.Context, item *models.Bundle, def bundle.Definition, create bool) {
+	if err := bundle.Save(models.GetDB(), item, def, create); err != nil {
+		if bundle.IsDefinitionError(err) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to save bundle"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    item,
+	})
+}
@@ -1,10 +1,15 @@
 package skills
 
 import (
+	"errors"
 	"log"
 	"skillhub/config"
 	"skillhub/models"
+	"skillhub/services/entitlement"
+	"skillhub/services/orders"
 	"skillhub/services/payment"
+	"skillhub/services/pricing"
+	"skillhub/services/risk"
 	"strconv"
 	"time"
 
@@ -101,7 +106,7 @@ func GetSkill(c *gin.Context) {
 	var skill models.Skill
 	db := models.GetDB()
 
-	if err := db.Preload("Category").Preload("Tags").Preload("Translations").
+	if err := db.Preload("Category").Preload("Tags").Preload("Translations").Preload("Prices").
 		Where("id = ? AND is_active = ?", uid, true).First(&skill).Error; err != nil {
 		c.JSON(404, gin.H{
 			"code":    404,
@@ -117,6 +122,52 @@ func GetSkill(c *gin.Context) {
 	})
 }
 
+// GetSkillPrice 获取技能在指定地区/币种下的价格
+// @Summary 获取技能报价
+// @Description 按地区价格表定价，指定币种时按汇率换算
+// @Tags skills
+// @Accept json
+// @Produce json
+// @Param id path string true "技能ID"
+// @Param region query string false "国家/地区代码，如CN、US"
+// @Param currency query string false "币种，如CNY、USD"
+// @Success 200 {object} pricing.Quote
+// @Router /skills/{id}/price [get]
+func GetSkillPrice(c *gin.Context) {
+	uid, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"code":    400,
+			"message": "Invalid skill ID",
+		})
+		return
+	}
+
+	var skill models.Skill
+	if err := models.GetDB().Where("id = ? AND is_active = ?", uid, true).First(&skill).Error; err != nil {
+		c.JSON(404, gin.H{
+			"code":    404,
+			"message": "Skill not found",
+		})
+		return
+	}
+
+	quote, err := pricing.QuoteSkill(&skill, c.Query("region"), c.Query("currency"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    quote,
+	})
+}
+
 // GetCategories 获取所有分类
 // @Summary 获取分类列表
 // @Description 获取所有技能分类
@@ -226,9 +277,9 @@ func DownloadSkill(c *gin.Context) {
 		return
 	}
 
-	// 检查是否需要购买
-	if skill.PriceType == models.PriceTypePaid {
-		// 验证购买记录
+	// 检查是否需要购买或订阅
+	if skill.PriceType == models.PriceTypePaid || skill.PriceType == models.PriceTypeSubscription {
+		// 验证使用权（购买、订阅当期或管理员授予）
 		userID = c.GetString("user_id")
 		if userID == "" {
 			c.JSON(401, gin.H{
@@ -238,15 +289,22 @@ func DownloadSkill(c *gin.Context) {
 			return
 		}
 
-		userUUID := uuid.MustParse(userID)
-		var existingOrder models.Order
-		if err := db.Joins("JOIN order_items ON order_items.order_id = orders.id").
-			Where("orders.user_id = ? AND orders.status = ? AND order_items.skill_id = ?",
-				userUUID, models.OrderStatusPaid, uid).
-			First(&existingOrder).Error; err != nil {
+		allowed, err := entitlement.HasAccess(db, uuid.MustParse(userID), uid)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"code":    500,
+				"message": "Failed to check entitlements",
+			})
+			return
+		}
+		if !allowed {
+			message := "Please purchase this skill first"
+			if skill.PriceType == models.PriceTypeSubscription {
+				message = "Please subscribe to this skill first"
+			}
 			c.JSON(403, gin.H{
 				"code":    403,
-				"message": "Please purchase this skill first",
+				"message": message,
 			})
 			return
 		}
@@ -288,11 +346,16 @@ func DownloadSkill(c *gin.Context) {
 
 // PurchaseSkill 购买技能
 // @Summary 购买技能
-// @Description 创建订单并处理支付
+// @Description 创建待支付订单并返回支付链接，网关回调或对账查询确认付款后授予使用权
 // @Tags skills
 // @Accept json
 // @Produce json
 // @Param id path string true "技能ID"
+// @Param region query string false "国家/地区代码，用于地区定价"
+// @Param currency query string false "支付币种，默认为价格币种"
+// @Param payment_type query string false "支付方式，为空时按路由规则选择" Enums(alipay,wechat,stripe,paypal,mock)
+// @Param coupon_code query string false "优惠码"
+// @Param card_fingerprint query string false "支付卡指纹，用于风控频率统计"
 // @Success 200 {object} object
 // @Router /skills/{id}/purchase [post]
 func PurchaseSkill(c *gin.Context) {
@@ -341,32 +404,69 @@ func PurchaseSkill(c *gin.Context) {
 		return
 	}
 
-	// 检查是否已购买
-	var existingOrder models.Order
-	if err := db.Joins("JOIN order_items ON order_items.order_id = orders.id").
-		Where("orders.user_id = ? AND orders.status = ? AND order_items.skill_id = ?",
-			userUUID, models.OrderStatusPaid, uid).
-		First(&existingOrder).Error; err == nil {
+	// 按地区和币种定价生成订单，已购买的技能会被拒绝
+	order, err := orders.NewOrder(db, userUUID, []uuid.UUID{uid}, c.Query("region"), c.Query("currency"))
+	if err != nil {
+		message := err.Error()
+		if errors.Is(err, orders.ErrAlreadyOwned) {
+			message = "Skill already purchased"
+		}
 		c.JSON(400, gin.H{
 			"code":    400,
-			"message": "Skill already purchased",
+			"message": message,
 		})
 		return
 	}
+	if err := orders.ApplyCoupon(db, order, c.Query("coupon_code")); err != nil {
+		c.JSON(400, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := orders.ApplyTax(db, order); err != nil {
+		c.JSON(500, gin.H{
+			"code":    500,
+			"message": "Failed to calculate tax",
+		})
+		return
+	}
+	// 风控：同一用户、IP或支付卡短时间内频繁下单时挂起或拒绝
+	if err := orders.Screen(db, order, risk.Signals{IP: c.ClientIP(), CardFingerprint: c.Query("card_fingerprint")}); err != nil {
+		if errors.Is(err, risk.ErrBlocked) {
+			c.JSON(429, gin.H{
+				"code":    429,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(500, gin.H{
+			"code":    500,
+			"message": "Failed to check order risk",
+		})
+		return
+	}
+	order.PaymentMethod = "pending"
 
-	// 创建订单
-	orderNo := "ORD" + time.Now().Format("20060102150405")
-	order := models.Order{
-		ID:            uuid.New(),
-		UserID:        userUUID,
-		OrderNo:       orderNo,
-		TotalAmount:   skill.Price,
-		PaymentMethod: "pending",
-		Status:        "pending",
-		CreatedAt:     time.Now(),
+	// 选择支付网关：买家指定的支付方式，未指定时按路由规则选择支持该币种的网关
+	paymentService, err := payment.ServiceForOrder(*config.AppConfig, order, payment.PaymentType(c.Query("payment_type")))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
 	}
 
-	if err := db.Create(&order).Error; err != nil {
+	// 创建订单
+	if err := orders.Place(db, order); err != nil {
+		if orders.IsCouponError(err) {
+			c.JSON(400, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(500, gin.H{
 			"code":    500,
 			"message": "Failed to create order",
@@ -374,25 +474,48 @@ func PurchaseSkill(c *gin.Context) {
 		return
 	}
 
-	// 创建订单项
-	orderItem := models.OrderItem{
-		ID:       uuid.New(),
-		OrderID:  order.ID,
-		SkillID:  &uid,
-		Quantity: 1,
-		Price:    skill.Price,
+	// 风控挂起的订单待管理员审核通过后再支付
+	if order.Status == models.OrderStatusRiskReview {
+		c.JSON(200, gin.H{
+			"code":    0,
+			"message": "Order is under review",
+			"data": gin.H{
+				"order_id": order.ID.String(),
+				"order_no": order.OrderNo,
+				"skill_id": id,
+				"amount":   order.Total,
+				"status":   order.Status,
+			},
+		})
+		return
 	}
-	if err := db.Create(&orderItem).Error; err != nil {
-		c.JSON(500, gin.H{
-			"code":    500,
-			"message": "Failed to create order item",
+
+	// 优惠后实付为零的订单无需经过支付网关
+	if order.Total.IsZero() {
+		if err := orders.SettleFree(db, order, orders.SourceCoupon); err != nil {
+			log.Printf("Failed to complete free order %s: %v", order.OrderNo, err)
+			c.JSON(500, gin.H{
+				"code":    500,
+				"message": "Failed to complete purchase",
+			})
+			return
+		}
+		c.JSON(200, gin.H{
+			"code":    0,
+			"message": "Purchase successful",
+			"data": gin.H{
+				"order_id": order.ID.String(),
+				"order_no": order.OrderNo,
+				"skill_id": id,
+				"amount":   order.Total,
+				"discount": order.Discount,
+			},
 		})
 		return
 	}
 
 	// 集成支付网关
-	paymentService := payment.GetDefaultPaymentService(*config.AppConfig)
-	paymentURL, err := paymentService.CreatePayment(&order, skill.Name)
+	paymentURL, err := paymentService.CreatePayment(order, skill.Name)
 	if err != nil {
 		log.Printf("Failed to create payment: %v", err)
 		c.JSON(500, gin.H{
@@ -402,17 +525,22 @@ func PurchaseSkill(c *gin.Context) {
 		return
 	}
 
-	// 检查支付类型，如果是模拟支付，直接标记为已支付
-	if paymentService.GetPaymentType() == payment.PaymentTypeMock || paymentService.GetPaymentType() == payment.PaymentTypeAlipay {
-		// 模拟支付或支付宝模拟支付，直接标记为已支付
-		now := time.Now()
-		order.Status = "paid"
-		order.PaidAt = &now
-		order.PaymentMethod = string(paymentService.GetPaymentType())
-		db.Save(&order)
+	// 记录支付方式和网关侧订单号（PayPal捕获、对账查询需要）
+	db.Model(order).Updates(map[string]interface{}{
+		"payment_method": string(paymentService.GetPaymentType()),
+		"payment_ref":    order.PaymentRef,
+	})
 
-		// 更新技能购买量
-		db.Model(&skill).UpdateColumn("purchases_count", gorm.Expr("purchases_count + ?", 1))
+	// 订单保持待支付，由网关回调或对账查询确认付款后授予使用权；仅支付沙箱下自动确认
+	if orders.SandboxEnabled() {
+		if err := orders.SettleSandbox(order, paymentService.GetPaymentType()); err != nil {
+			log.Printf("Failed to settle sandbox order %s: %v", order.OrderNo, err)
+			c.JSON(500, gin.H{
+				"code":    500,
+				"message": "Failed to complete purchase",
+			})
+			return
+		}
 
 		c.JSON(200, gin.H{
 			"code":    0,
@@ -421,24 +549,25 @@ func PurchaseSkill(c *gin.Context) {
 				"order_id":     order.ID.String(),
 				"order_no":     order.OrderNo,
 				"skill_id":     id,
-				"amount":       skill.Price,
+				"amount":       order.Total,
 				"payment_type": string(paymentService.GetPaymentType()),
+				"status":       order.Status,
 			},
 		})
-	} else {
-		// 真实支付，返回支付URL
-		c.JSON(200, gin.H{
-			"code":    0,
-			"message": "Payment created",
-			"data": gin.H{
-				"order_id":          order.ID.String(),
-				"order_no":          order.OrderNo,
-				"skill_id":          id,
-				"amount":            skill.Price,
-				"payment_type":      string(paymentService.GetPaymentType()),
-				"payment_url":       paymentURL,
-				"redirect_required": true,
-			},
-		})
+		return
 	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "Payment created",
+		"data": gin.H{
+			"order_id":          order.ID.String(),
+			"order_no":          order.OrderNo,
+			"skill_id":          id,
+			"amount":            order.Total,
+			"payment_type":      string(paymentService.GetPaymentType()),
+			"payment_url":       paymentURL,
+			"redirect_required": true,
+		},
+	})
 }
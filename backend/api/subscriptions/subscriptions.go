@@ -0,0 +1,251 @@
+package subscriptions
+
+import (
+	"errors"
+	"log"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/orders"
+	svcpayment "skillhub/services/payment"
+	"skillhub/services/pricing"
+	"skillhub/services/subscription"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SubscribeRequest 订阅请求
+type SubscribeRequest struct {
+	PlanID      uuid.UUID `json:"plan_id" binding:"required"`
+	Region      string    `json:"region"`       // 国家/地区代码，用于支付路由
+	PaymentType string    `json:"payment_type"` // 支付方式，为空时按路由规则选择
+}
+
+// currentUser 从上下文获取当前用户ID
+func currentUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// ListPlans 获取技能的订阅方案
+// @Summary 技能订阅方案
+// @Description 列出订阅类技能可选的计费方案（按月/按年）
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "技能ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /skills/{id}/plans [get]
+func ListPlans(c *gin.Context) {
+	skillID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid skill ID"})
+		return
+	}
+
+	var plans []models.SubscriptionPlan
+	if err := models.GetDB().Where("skill_id = ? AND is_active = ?", skillID, true).
+		Order("created_at ASC").Find(&plans).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load plans"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    plans,
+	})
+}
+
+// ListSubscriptions 获取当前用户的订阅
+// @Summary 我的订阅
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} map[string]interface{}
+// @Router /subscriptions [get]
+func ListSubscriptions(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var subs []models.Subscription
+	if err := models.GetDB().Preload("Plan").Preload("Skill").
+		Where("user_id = ?", userID).Order("created_at DESC").Find(&subs).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load subscriptions"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    subs,
+	})
+}
+
+// Subscribe 订阅技能
+// @Summary 订阅技能
+// @Description 创建订阅及首期订单并返回支付链接。Stripe/PayPal由网关按周期自动扣款，
+// @Description 其他支付方式在当期结束前生成续费订单，需用户手动支付
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param request body SubscribeRequest true "订阅方案"
+// @Success 200 {object} map[string]interface{}
+// @Router /subscriptions [post]
+func Subscribe(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req SubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := models.GetDB()
+	plan, err := subscription.LoadPlan(db, req.PlanID)
+	if err != nil {
+		c.JSON(subscribeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先按方案价格选择支付方式，避免创建无法支付的订阅
+	preview := &models.Order{Total: plan.Price, Region: pricing.NormalizeRegion(req.Region)}
+	paymentService, err := svcpayment.ServiceForOrder(*config.AppConfig, preview, svcpayment.PaymentType(req.PaymentType))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, order, err := orders.Subscribe(db, userID, plan, req.Region)
+	if err != nil {
+		c.JSON(subscribeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	subject := orders.Subject(order)
+	var paymentURL string
+	provider, autoRenew := paymentService.(svcpayment.SubscriptionProvider)
+	if autoRenew {
+		paypalPlanID := plan.PayPalPlanID
+		paymentURL, err = provider.CreateSubscription(order, plan, subject)
+		if err == nil && plan.PayPalPlanID != paypalPlanID {
+			db.Model(plan).Update("paypal_plan_id", plan.PayPalPlanID)
+		}
+	} else {
+		paymentURL, err = paymentService.CreatePayment(order, subject)
+	}
+	if err != nil {
+		if cancelErr := orders.Unsubscribe(db, sub); cancelErr != nil {
+			log.Printf("Failed to cancel subscription %s: %v", sub.ID, cancelErr)
+		}
+		c.JSON(500, gin.H{"error": "Failed to create payment", "details": err.Error()})
+		return
+	}
+
+	sub.Provider, sub.AutoRenew = string(paymentService.GetPaymentType()), autoRenew
+	db.Model(sub).Updates(map[string]interface{}{"provider": sub.Provider, "auto_renew": sub.AutoRenew})
+	db.Model(order).Updates(map[string]interface{}{
+		"payment_method": string(paymentService.GetPaymentType()),
+		"payment_ref":    order.PaymentRef,
+	})
+	if orders.SandboxEnabled() {
+		if err := orders.SettleSandbox(order, paymentService.GetPaymentType()); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to complete payment", "details": err.Error()})
+			return
+		}
+		db.First(sub, "id = ?", sub.ID)
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"subscription": sub,
+			"order":        order,
+			"payment_url":  paymentURL,
+		},
+	})
+}
+
+// CancelSubscription 取消订阅
+// @Summary 取消订阅
+// @Description 停止续费，已支付的当期结束前仍可下载
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param id path string true "订阅ID"
+// @Success 200 {object} models.Subscription
+// @Router /subscriptions/{id}/cancel [post]
+func CancelSubscription(c *gin.Context) {
+	userID, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	db := models.GetDB()
+	var sub models.Subscription
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&sub).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Subscription not found"})
+		return
+	}
+	if sub.Status == models.SubscriptionStatusCancelled || sub.Status == models.SubscriptionStatusExpired {
+		c.JSON(400, gin.H{"error": subscription.ErrNotCancellable.Error()})
+		return
+	}
+
+	// 网关自动续费的订阅先在网关侧取消，避免继续扣款
+	if sub.AutoRenew && sub.ProviderRef != "" {
+		paymentService, err := svcpayment.GetPaymentService(svcpayment.PaymentType(sub.Provider), *config.AppConfig)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if provider, ok := paymentService.(svcpayment.SubscriptionProvider); ok {
+			if err := provider.CancelSubscription(sub.ProviderRef); err != nil {
+				c.JSON(502, gin.H{"error": "Failed to cancel subscription with payment provider", "details": err.Error()})
+				return
+			}
+		}
+	}
+
+	if err := orders.Unsubscribe(db, &sub); err != nil {
+		c.JSON(subscribeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    sub,
+	})
+}
+
+// subscribeErrorStatus 订阅错误对应的HTTP状态码
+func subscribeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, subscription.ErrPlanNotFound), errors.Is(err, subscription.ErrNotFound):
+		return 404
+	case errors.Is(err, subscription.ErrNotSubscription), errors.Is(err, subscription.ErrAlreadySubscribed),
+		errors.Is(err, subscription.ErrNotCancellable), errors.Is(err, orders.ErrAlreadyOwned):
+		return 400
+	}
+	return 500
+}
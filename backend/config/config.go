@@ -17,12 +17,19 @@ type Config struct {
 	JWT      JWTConfig
 	OAuth    OAuthConfig
 	Payment  PaymentConfig
+	Pricing  PricingConfig
+	Invoice  InvoiceConfig
+	Payout   PayoutConfig
+	Risk     RiskConfig
+	Mail     MailConfig
 	GitHub   GitHubConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Mode string
+	// Scheduler 是否在本实例运行定时任务，多实例部署时只在一个实例上开启
+	Scheduler bool
 }
 
 type DatabaseConfig struct {
@@ -40,8 +47,9 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret     string
-	Expiration time.Duration
+	Secret            string
+	Expiration        time.Duration // 访问令牌有效期
+	RefreshExpiration time.Duration // 刷新令牌有效期，每次刷新换发新令牌并重新计时
 }
 
 type OAuthConfig struct {
@@ -50,6 +58,7 @@ type OAuthConfig struct {
 	Xiaohongshu OAuthProvider
 	GitHub      OAuthProvider
 	Google      OAuthProvider
+	StateTTL    time.Duration // 授权请求（state）有效期，超时未回调需重新发起登录
 }
 
 type OAuthProvider struct {
@@ -59,10 +68,21 @@ type OAuthProvider struct {
 }
 
 type PaymentConfig struct {
-	Alipay    AlipayConfig
-	WeChatPay WeChatPayConfig
-	Stripe    StripeConfig
-	PayPal    PayPalConfig
+	Alipay      AlipayConfig
+	WeChatPay   WeChatPayConfig
+	Stripe      StripeConfig
+	PayPal      PayPalConfig
+	OrderExpiry time.Duration // 待支付订单超过该时长自动取消并释放优惠券
+	// SubscriptionGrace 订阅当期结束后未能续费时仍保留使用权的宽限期
+	SubscriptionGrace time.Duration
+	// SubscriptionRenewalLead 支付宝/微信支付订阅在当期结束前多久生成续费订单
+	SubscriptionRenewalLead time.Duration
+	// FeeBps 各支付渠道的手续费率（基点），收款时按此记入总账的手续费科目
+	FeeBps map[string]int
+	// GiftExpiry 礼品兑换码的兑换期限，0表示不过期
+	GiftExpiry time.Duration
+	// Sandbox 支付沙箱：未配置的网关以模拟客户端代替，下单后自动确认付款。仅用于开发和测试，release模式下不允许启用
+	Sandbox bool
 }
 
 type AlipayConfig struct {
@@ -71,27 +91,84 @@ type AlipayConfig struct {
 	PublicKey  string
 	NotifyURL  string
 	ReturnURL  string
+	GatewayURL string
 }
 
 type WeChatPayConfig struct {
-	MchID     string
-	APIKey    string
-	SerialNo  string
-	CertPath  string
-	NotifyURL string
-	ReturnURL string
+	AppID          string
+	MchID          string
+	APIKey         string // APIv3密钥，用于回调和平台证书解密
+	SerialNo       string
+	CertPath       string
+	PrivateKeyPath string
+	NotifyURL      string
+	ReturnURL      string
+	BaseURL        string
 }
 
 type StripeConfig struct {
 	SecretKey      string
 	PublishableKey string
 	WebhookSecret  string
+	BaseURL        string
+	SuccessURL     string
+	CancelURL      string
 }
 
 type PayPalConfig struct {
 	ClientID     string
 	ClientSecret string
 	Mode         string
+	WebhookID    string
+	MerchantID   string
+	BaseURL      string
+	ReturnURL    string
+	CancelURL    string
+}
+
+type PricingConfig struct {
+	DefaultCurrency string // 技能未设置币种时的基础币种
+	FXRatesFile     string // 启动时导入的汇率CSV文件（base,quote,rate）
+	TaxRulesFile    string // 启动时加载的税率规则CSV文件，为空时不计税
+}
+
+// InvoiceConfig 发票编号规则和开票方（卖方）信息
+type InvoiceConfig struct {
+	Prefix      string // 发票号前缀，发票号形如 INV-2026-000001，按年连续编号
+	SellerName  string
+	SellerTaxID string
+	SellerAddr  string
+	SellerPhone string
+	SellerEmail string
+	SellerBank  string // 开户行
+	SellerAcct  string // 银行账号
+}
+
+// PayoutConfig 发布者分成和结算
+type PayoutConfig struct {
+	CommissionBps int           // 平台默认抽成（基点），可按技能或发布者覆盖
+	HoldPeriod    time.Duration // 收益在支付后经过该时长才可提现，用于覆盖退款期
+}
+
+// RiskConfig 下单风控阈值和频率规则
+type RiskConfig struct {
+	Rules       string // 下单频率规则，逗号分隔的 subject:window:limit:score，subject为user、ip或card
+	ReviewScore int    // 风险分达到该值的订单挂起待人工审核，0表示不挂起
+	BlockScore  int    // 风险分达到该值的订单直接拒绝，0表示不拒绝
+}
+
+// MailConfig 邮件发送（邮箱验证、找回密码）
+type MailConfig struct {
+	Driver           string // smtp 或 log（开发环境，只写日志或文件不真正发送）
+	From             string // 发件人，如 SkillHub <no-reply@example.com>
+	SMTPHost         string
+	SMTPPort         int // 465为隐式TLS，其余端口在服务器支持时使用STARTTLS
+	SMTPUsername     string
+	SMTPPassword     string
+	LogDir           string        // log驱动将邮件写成.eml文件的目录，为空时只写日志
+	FrontendURL      string        // 邮件中链接指向的前端地址
+	VerificationTTL  time.Duration // 邮箱验证链接有效期
+	PasswordResetTTL time.Duration // 重置密码链接有效期
 }
 
 type GitHubConfig struct {
@@ -122,8 +199,9 @@ func LoadConfig() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("BACKEND_PORT", "8080"),
-			Mode: getEnv("GIN_MODE", "debug"),
+			Port:      getEnv("BACKEND_PORT", "8080"),
+			Mode:      getEnv("GIN_MODE", "debug"),
+			Scheduler: getEnv("SCHEDULER_ENABLED", "true") == "true",
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -138,8 +216,9 @@ func LoadConfig() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", "default-secret-change-in-production"),
-			Expiration: parseDuration(getEnv("JWT_EXPIRATION", "24h")),
+			Secret:            getEnv("JWT_SECRET", "default-secret-change-in-production"),
+			Expiration:        parseDuration(getEnv("JWT_EXPIRATION", "15m")),
+			RefreshExpiration: parseDuration(getEnv("JWT_REFRESH_EXPIRATION", "720h")),
 		},
 		OAuth: OAuthConfig{
 			WeChat: OAuthProvider{
@@ -167,6 +246,7 @@ func LoadConfig() *Config {
 				AppSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
 				Redirect:  getEnv("GOOGLE_REDIRECT_URI", ""),
 			},
+			StateTTL: parseDuration(getEnv("OAUTH_STATE_TTL", "10m")),
 		},
 		Payment: PaymentConfig{
 			Alipay: AlipayConfig{
@@ -175,25 +255,79 @@ func LoadConfig() *Config {
 				PublicKey:  getEnv("ALIPAY_PUBLIC_KEY", ""),
 				NotifyURL:  getEnv("ALIPAY_NOTIFY_URL", ""),
 				ReturnURL:  getEnv("ALIPAY_RETURN_URL", "http://localhost:3000/orders"),
+				GatewayURL: getEnv("ALIPAY_GATEWAY_URL", "https://openapi.alipay.com/gateway.do"),
 			},
 			WeChatPay: WeChatPayConfig{
-				MchID:     getEnv("WECHAT_PAY_MCH_ID", ""),
-				APIKey:    getEnv("WECHAT_PAY_API_KEY", ""),
-				SerialNo:  getEnv("WECHAT_PAY_SERIAL_NO", ""),
-				CertPath:  getEnv("WECHAT_PAY_CERT_PATH", ""),
-				NotifyURL: getEnv("WECHAT_PAY_NOTIFY_URL", ""),
-				ReturnURL: getEnv("WECHAT_PAY_RETURN_URL", "http://localhost:3000/orders"),
+				AppID:          getEnv("WECHAT_PAY_APP_ID", ""),
+				MchID:          getEnv("WECHAT_PAY_MCH_ID", ""),
+				APIKey:         getEnv("WECHAT_PAY_API_KEY", ""),
+				SerialNo:       getEnv("WECHAT_PAY_SERIAL_NO", ""),
+				CertPath:       getEnv("WECHAT_PAY_CERT_PATH", ""),
+				PrivateKeyPath: getEnv("WECHAT_PAY_PRIVATE_KEY_PATH", ""),
+				NotifyURL:      getEnv("WECHAT_PAY_NOTIFY_URL", ""),
+				ReturnURL:      getEnv("WECHAT_PAY_RETURN_URL", "http://localhost:3000/orders"),
+				BaseURL:        getEnv("WECHAT_PAY_BASE_URL", "https://api.mch.weixin.qq.com"),
 			},
 			Stripe: StripeConfig{
 				SecretKey:      getEnv("STRIPE_SECRET_KEY", ""),
 				PublishableKey: getEnv("STRIPE_PUBLISHABLE_KEY", ""),
 				WebhookSecret:  getEnv("STRIPE_WEBHOOK_SECRET", ""),
+				BaseURL:        getEnv("STRIPE_BASE_URL", "https://api.stripe.com"),
+				SuccessURL:     getEnv("STRIPE_SUCCESS_URL", "http://localhost:3000/orders/success"),
+				CancelURL:      getEnv("STRIPE_CANCEL_URL", "http://localhost:3000/orders/cancel"),
 			},
 			PayPal: PayPalConfig{
 				ClientID:     getEnv("PAYPAL_CLIENT_ID", ""),
 				ClientSecret: getEnv("PAYPAL_CLIENT_SECRET", ""),
 				Mode:         getEnv("PAYPAL_MODE", "sandbox"),
+				WebhookID:    getEnv("PAYPAL_WEBHOOK_ID", ""),
+				MerchantID:   getEnv("PAYPAL_MERCHANT_ID", ""),
+				BaseURL:      getEnv("PAYPAL_BASE_URL", ""),
+				ReturnURL:    getEnv("PAYPAL_RETURN_URL", "http://localhost:3000/orders/success"),
+				CancelURL:    getEnv("PAYPAL_CANCEL_URL", "http://localhost:3000/orders/cancel"),
 			},
+			OrderExpiry:             parseDuration(getEnv("ORDER_EXPIRY", "24h")),
+			SubscriptionGrace:       parseDuration(getEnv("SUBSCRIPTION_GRACE_PERIOD", "72h")),
+			SubscriptionRenewalLead: parseDuration(getEnv("SUBSCRIPTION_RENEWAL_LEAD", "72h")),
+			FeeBps:                  parseIntMap(getEnv("PAYMENT_FEE_BPS", "")),
+			GiftExpiry:              parseDuration(getEnv("GIFT_EXPIRY", "8760h")),
+			Sandbox:                 getEnv("PAYMENT_SANDBOX", "false") == "true",
+		},
+		Pricing: PricingConfig{
+			DefaultCurrency: getEnv("DEFAULT_CURRENCY", "CNY"),
+			FXRatesFile:     getEnv("FX_RATES_FILE", ""),
+			TaxRulesFile:    getEnv("TAX_RULES_FILE", ""),
+		},
+		Invoice: InvoiceConfig{
+			Prefix:      getEnv("INVOICE_PREFIX", "INV"),
+			SellerName:  getEnv("INVOICE_SELLER_NAME", "SkillHub"),
+			SellerTaxID: getEnv("INVOICE_SELLER_TAX_ID", ""),
+			SellerAddr:  getEnv("INVOICE_SELLER_ADDRESS", ""),
+			SellerPhone: getEnv("INVOICE_SELLER_PHONE", ""),
+			SellerEmail: getEnv("INVOICE_SELLER_EMAIL", ""),
+			SellerBank:  getEnv("INVOICE_SELLER_BANK", ""),
+			SellerAcct:  getEnv("INVOICE_SELLER_BANK_ACCOUNT", ""),
+		},
+		Payout: PayoutConfig{
+			CommissionBps: getEnvInt("PLATFORM_COMMISSION_BPS", 3000),
+			HoldPeriod:    parseDuration(getEnv("EARNINGS_HOLD_PERIOD", "336h")),
+		},
+		Risk: RiskConfig{
+			Rules:       getEnv("RISK_RULES", "user:1m:3:40,user:1h:20:40,ip:1m:5:30,ip:1h:50:40,card:1h:5:50"),
+			ReviewScore: getEnvInt("RISK_REVIEW_SCORE", 40),
+			BlockScore:  getEnvInt("RISK_BLOCK_SCORE", 80),
+		},
+		Mail: MailConfig{
+			Driver:           getEnv("MAIL_DRIVER", "log"),
+			From:             getEnv("MAIL_FROM", "SkillHub <no-reply@localhost>"),
+			SMTPHost:         getEnv("SMTP_HOST", ""),
+			SMTPPort:         getEnvInt("SMTP_PORT", 587),
+			SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+			LogDir:           getEnv("MAIL_LOG_DIR", ""),
+			FrontendURL:      getEnv("FRONTEND_URL", "http://localhost:3000"),
+			VerificationTTL:  parseDuration(getEnv("EMAIL_VERIFICATION_TTL", "48h")),
+			PasswordResetTTL: parseDuration(getEnv("PASSWORD_RESET_TTL", "1h")),
 		},
 		GitHub: GitHubConfig{
 			Token:        getEnv("GITHUB_TOKEN", ""),
@@ -206,6 +340,17 @@ func LoadConfig() *Config {
 	}
 }
 
+// Validate 检查配置，拒绝在release模式下启用支付沙箱，以及配置了Stripe密钥却没有Webhook签名密钥
+func (c *Config) Validate() error {
+	if c.Payment.Sandbox && c.Server.Mode == "release" {
+		return fmt.Errorf("PAYMENT_SANDBOX cannot be enabled when GIN_MODE=release")
+	}
+	if c.Payment.Stripe.SecretKey != "" && c.Payment.Stripe.WebhookSecret == "" && !c.Payment.Sandbox {
+		return fmt.Errorf("STRIPE_WEBHOOK_SECRET is required when STRIPE_SECRET_KEY is set")
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -241,6 +386,23 @@ func parseStringSlice(s, sep string) []string {
 	return result
 }
 
+// parseIntMap 解析形如 "alipay:60,stripe:290" 的键值列表，忽略格式不正确的项
+func parseIntMap(s string) map[string]int {
+	result := make(map[string]int)
+	for _, part := range parseStringSlice(s, ",") {
+		key, value, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d", &n); err != nil {
+			continue
+		}
+		result[strings.TrimSpace(key)] = n
+	}
+	return result
+}
+
 func parseDuration(s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil {
@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"defaults", Config{}, false},
+		{"sandbox in release", Config{Server: ServerConfig{Mode: "release"}, Payment: PaymentConfig{Sandbox: true}}, true},
+		{"stripe without webhook secret", Config{Payment: PaymentConfig{Stripe: StripeConfig{SecretKey: "sk_live_1"}}}, true},
+		{"stripe with webhook secret", Config{Payment: PaymentConfig{Stripe: StripeConfig{SecretKey: "sk_live_1", WebhookSecret: "whsec_1"}}}, false},
+		{"stripe sandbox without webhook secret", Config{Payment: PaymentConfig{Sandbox: true, Stripe: StripeConfig{SecretKey: "sk_test_1"}}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -10,19 +10,22 @@ import (
 )
 
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
-	Role   string    `json:"role"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	SessionID uuid.UUID `json:"sid"` // 签发该令牌的登录会话，会话撤销后令牌失效
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uuid.UUID, email string, role string) (string, error) {
+// GenerateToken 签发访问令牌，有效期为JWT_EXPIRATION
+func GenerateToken(userID uuid.UUID, email string, role string, sessionID uuid.UUID) (string, error) {
 	cfg := config.AppConfig.JWT
 
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.Expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
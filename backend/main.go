@@ -5,16 +5,26 @@ import (
 	"skillhub/api/admin"
 	"skillhub/api/analytics"
 	authhandler "skillhub/api/auth"
+	"skillhub/api/bundles"
+	"skillhub/api/cart"
+	"skillhub/api/gifts"
+	"skillhub/api/invoices"
 	"skillhub/api/payment"
+	"skillhub/api/publisher"
 	"skillhub/api/skills"
+	"skillhub/api/subscriptions"
 	"skillhub/config"
 	_ "skillhub/docs"
 	"skillhub/middleware"
 	"skillhub/mock"
 	"skillhub/models"
 	svcauth "skillhub/services/auth"
+	"skillhub/services/mail"
+	"skillhub/services/pricing"
+	"skillhub/services/risk"
+	"skillhub/services/tax"
 	// "skillhub/services/payment"
-	// svcScheduler "skillhub/services/scheduler"
+	svcScheduler "skillhub/services/scheduler"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -30,6 +40,9 @@ import (
 // @BasePath /api/v1
 func main() {
 	config.AppConfig = config.LoadConfig()
+	if err := config.AppConfig.Validate(); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
 	log.Println("Configuration loaded")
 
 	// 初始化数据库
@@ -42,15 +55,58 @@ func main() {
 		log.Printf("Warning: Failed to seed mock data: %v", err)
 	}
 
+	// 导入汇率文件
+	if path := config.AppConfig.Pricing.FXRatesFile; path != "" {
+		if count, err := pricing.ImportRatesFile(path); err != nil {
+			log.Printf("Warning: Failed to import exchange rates from %s: %v", path, err)
+		} else {
+			log.Printf("Imported %d exchange rates from %s", count, path)
+		}
+	}
+
+	// 加载税率规则，规则文件有误时拒绝启动，避免少收或错收税款
+	if path := config.AppConfig.Pricing.TaxRulesFile; path != "" {
+		rules, err := tax.LoadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load tax rules from %s: %v", path, err)
+		}
+		tax.SetRules(rules)
+		log.Printf("Loaded %d tax rules from %s", len(rules.All()), path)
+	}
+
+	// 加载下单风控规则，规则有误时拒绝启动
+	riskRules, err := risk.ParseRules(config.AppConfig.Risk.Rules)
+	if err != nil {
+		log.Fatalf("Invalid RISK_RULES: %v", err)
+	}
+	riskPolicy := risk.Policy{
+		Rules:       riskRules,
+		ReviewScore: config.AppConfig.Risk.ReviewScore,
+		BlockScore:  config.AppConfig.Risk.BlockScore,
+	}
+	if err := riskPolicy.Validate(); err != nil {
+		log.Fatalf("Invalid risk thresholds: %v", err)
+	}
+	risk.SetPolicy(riskPolicy)
+
+	// 初始化邮件发送，配置有误时拒绝启动
+	mailer, err := mail.New(config.AppConfig.Mail)
+	if err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+	mail.SetMailer(mailer)
+
 	// 初始化OAuth
 	svcauth.InitOAuth()
 
 	// 初始化支付服务
 	// svcpayment.InitPayment()
 
-	// 初始化默认定时任务
-	// svcScheduler.InitDefaultTasks()
-	// svcScheduler.InitScheduler()
+	// 初始化默认定时任务并启动调度（对账、订单过期、礼品过期、订阅续费、会话清理）
+	if config.AppConfig.Server.Scheduler {
+		svcScheduler.InitDefaultTasks()
+		svcScheduler.InitScheduler()
+	}
 
 	if config.AppConfig.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -62,7 +118,7 @@ func main() {
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, X-OAuth-Binding, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -85,6 +141,13 @@ func main() {
 		{
 			auth.POST("/login", authhandler.Login)
 			auth.POST("/register", authhandler.Register)
+			auth.POST("/refresh", authhandler.Refresh)
+			// 不经过鉴权中间件：访问令牌过期时可凭刷新令牌退出
+			auth.POST("/logout", authhandler.Logout)
+			auth.POST("/verify-email", authhandler.VerifyEmail)
+			auth.POST("/resend-verification", middleware.AuthMiddleware(), authhandler.ResendVerification)
+			auth.POST("/forgot-password", authhandler.ForgotPassword)
+			auth.POST("/reset-password", authhandler.ResetPassword)
 			auth.GET("/me", middleware.AuthMiddleware(), authhandler.GetMe)
 			auth.PUT("/profile", middleware.AuthMiddleware(), authhandler.UpdateProfile)
 			auth.PUT("/password", middleware.AuthMiddleware(), authhandler.ChangePassword)
@@ -104,29 +167,71 @@ func main() {
 		{
 			skillsGroup.GET("", skills.ListSkills)
 			skillsGroup.GET("/:id", skills.GetSkill)
+			skillsGroup.GET("/:id/price", skills.GetSkillPrice)
+			skillsGroup.GET("/:id/plans", subscriptions.ListPlans)
 			skillsGroup.GET("/:id/download", middleware.AuthMiddleware(), skills.DownloadSkill)
-			skillsGroup.POST("/:id/purchase", middleware.AuthMiddleware(), skills.PurchaseSkill)
+			skillsGroup.POST("/:id/purchase", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(), skills.PurchaseSkill)
 			skillsGroup.GET("/categories", skills.GetCategories)
 			skillsGroup.GET("/hot", skills.GetHotSkills)
 			skillsGroup.GET("/trending", skills.GetTrendingSkills)
 		}
 
+		bundlesGroup := v1.Group("/bundles")
+		{
+			bundlesGroup.GET("", bundles.ListBundles)
+			bundlesGroup.GET("/:id", bundles.GetBundle)
+			bundlesGroup.POST("/:id/purchase", middleware.AuthMiddleware(), middleware.RequireVerifiedEmail(), bundles.PurchaseBundle)
+		}
+
 		users := v1.Group("/users")
 		{
 			users.Use(middleware.AuthMiddleware())
 			// users routes will be added later
 		}
 
-		paymentGroup := v1.Group("/payment")
+		publisherGroup := v1.Group("/publisher")
+		{
+			publisherGroup.Use(middleware.AuthMiddleware())
+			publisherGroup.GET("/balance", publisher.GetBalance)
+			publisherGroup.GET("/earnings", publisher.ListEarnings)
+			publisherGroup.GET("/payouts", publisher.ListPayouts)
+			publisherGroup.GET("/bundles", publisher.ListBundles)
+			publisherGroup.POST("/bundles", publisher.CreateBundle)
+			publisherGroup.PUT("/bundles/:id", publisher.UpdateBundle)
+			publisherGroup.DELETE("/bundles/:id", publisher.DeleteBundle)
+		}
+
+		cartGroup := v1.Group("/cart")
+		{
+			cartGroup.Use(middleware.AuthMiddleware())
+			cartGroup.GET("", cart.GetCart)
+			cartGroup.POST("/items", cart.AddItem)
+			cartGroup.DELETE("/items/:skill_id", cart.RemoveItem)
+			cartGroup.POST("/checkout", middleware.RequireVerifiedEmail(), cart.Checkout)
+		}
+
+		giftsGroup := v1.Group("/gifts")
+		{
+			giftsGroup.Use(middleware.AuthMiddleware())
+			giftsGroup.GET("", gifts.ListSentGifts)
+			giftsGroup.GET("/received", gifts.ListReceivedGifts)
+			giftsGroup.POST("/redeem", gifts.RedeemGift)
+		}
+
+		subscriptionsGroup := v1.Group("/subscriptions")
+		{
+			subscriptionsGroup.Use(middleware.AuthMiddleware())
+			subscriptionsGroup.GET("", subscriptions.ListSubscriptions)
+			subscriptionsGroup.POST("", middleware.RequireVerifiedEmail(), subscriptions.Subscribe)
+			subscriptionsGroup.POST("/:id/cancel", subscriptions.CancelSubscription)
+		}
+
+		// 支付路由（含免鉴权的网关回调）与端到端测试共用同一注册函数
+		paymentGroup := payment.RegisterRoutes(v1)
 		{
-			paymentGroup.Use(middleware.AuthMiddleware())
-			paymentGroup.POST("/orders", payment.CreateOrder)
-			paymentGroup.GET("/orders", payment.GetOrders)
-			paymentGroup.POST("/payment/orders/:id/pay", payment.GetPaymentURL)
-			paymentGroup.POST("/callback/alipay", payment.AlipayCallback)
-			paymentGroup.POST("/callback/stripe", payment.StripeCallback)
-			paymentGroup.POST("/callback/paypal", payment.PayPalCallback)
-			paymentGroup.POST("/callback/mock", payment.MockCallback)
+			paymentGroup.GET("/orders/:id/invoice", invoices.GetOrderInvoice)
+			paymentGroup.GET("/billing-profile", invoices.GetBillingProfile)
+			paymentGroup.PUT("/billing-profile", invoices.UpdateBillingProfile)
 		}
 
 		adminGroup := v1.Group("/admin")
@@ -135,8 +240,57 @@ func main() {
 			adminGroup.Use(middleware.AdminMiddleware())
 			adminGroup.GET("/skills", admin.ListSkills)
 			adminGroup.PUT("/skills/:id", admin.UpdateSkill)
+			adminGroup.PUT("/skills/:id/prices", admin.SetSkillPrices)
+			adminGroup.GET("/skills/:id/plans", admin.ListSkillPlans)
+			adminGroup.POST("/skills/:id/plans", admin.CreateSkillPlan)
+			adminGroup.PUT("/subscription-plans/:id", admin.UpdateSubscriptionPlan)
+			adminGroup.GET("/subscriptions", admin.ListSubscriptions)
+			adminGroup.GET("/entitlements", admin.ListEntitlements)
+			adminGroup.POST("/entitlements", admin.GrantEntitlement)
+			adminGroup.POST("/entitlements/:id/revoke", admin.RevokeEntitlement)
+			adminGroup.GET("/invoices", admin.ListInvoices)
+			adminGroup.POST("/invoices/:id/void", admin.VoidInvoice)
+			adminGroup.POST("/invoices/:id/reissue", admin.ReissueInvoice)
+			adminGroup.GET("/commission-rules", admin.ListCommissionRules)
+			adminGroup.PUT("/commission-rules", admin.SetCommissionRule)
+			adminGroup.DELETE("/commission-rules/:id", admin.DeleteCommissionRule)
+			adminGroup.GET("/earnings", admin.ListEarnings)
+			adminGroup.GET("/payout-batches", admin.ListPayoutBatches)
+			adminGroup.POST("/payout-batches", admin.CreatePayoutBatch)
+			adminGroup.GET("/payout-batches/:id", admin.GetPayoutBatch)
+			adminGroup.POST("/payout-batches/:id/status", admin.UpdatePayoutBatch)
+			adminGroup.POST("/payouts/:id/fail", admin.FailPayout)
+			adminGroup.GET("/ledger/accounts", admin.ListLedgerAccounts)
+			adminGroup.GET("/ledger/trial-balance", admin.GetTrialBalance)
+			adminGroup.GET("/ledger/entries", admin.ListJournalEntries)
+			adminGroup.POST("/ledger/entries/:id/reverse", admin.ReverseJournalEntry)
+			adminGroup.GET("/ledger/export", admin.ExportLedger)
+			adminGroup.GET("/fx-rates", admin.ListExchangeRates)
+			adminGroup.PUT("/fx-rates", admin.UpdateExchangeRates)
+			adminGroup.POST("/fx-rates/import", admin.ImportExchangeRates)
+			adminGroup.GET("/tax-rules", admin.ListTaxRules)
+			adminGroup.GET("/payment-routes", admin.ListPaymentRoutes)
+			adminGroup.PUT("/payment-routes", admin.SetPaymentRoutes)
+			adminGroup.GET("/coupons", admin.ListCoupons)
+			adminGroup.POST("/coupons", admin.CreateCoupon)
+			adminGroup.PUT("/coupons/:id", admin.UpdateCoupon)
+			adminGroup.DELETE("/coupons/:id", admin.DeleteCoupon)
+			adminGroup.GET("/coupons/:id/redemptions", admin.ListCouponRedemptions)
+			adminGroup.GET("/bundles", admin.ListBundles)
+			adminGroup.POST("/bundles", admin.CreateBundle)
+			adminGroup.PUT("/bundles/:id", admin.UpdateBundle)
+			adminGroup.DELETE("/bundles/:id", admin.DeleteBundle)
 			adminGroup.GET("/users", admin.ListUsers)
 			adminGroup.GET("/orders", admin.ListOrders)
+			adminGroup.POST("/orders/:id/refunds", admin.RefundOrder)
+			adminGroup.GET("/orders/:id/refunds", admin.ListOrderRefunds)
+			adminGroup.POST("/refunds/:id/retry", admin.RetryRefund)
+			adminGroup.GET("/orders/:id/events", admin.ListOrderEvents)
+			adminGroup.POST("/orders/:id/review", admin.ReviewOrder)
+			adminGroup.GET("/risk/reviews", admin.ListRiskReviews)
+			adminGroup.GET("/alerts", admin.ListAlerts)
+			adminGroup.POST("/alerts/:id/resolve", admin.ResolveAlert)
+			adminGroup.POST("/payments/reconcile", admin.ReconcilePayments)
 			adminGroup.GET("/analytics", admin.GetAnalytics)
 			adminGroup.GET("/analytics/daily", admin.GetDailyAnalytics)
 			adminGroup.GET("/analytics/revenue", admin.GetRevenueAnalytics)
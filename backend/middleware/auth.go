@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"skillhub/lib"
+	"skillhub/models"
+	svcauth "skillhub/services/auth"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -17,21 +19,44 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := lib.ValidateToken(tokenString)
+		// 校验令牌签名、所属会话未撤销且账号未停用
+		claims, user, err := svcauth.Authenticate(models.GetDB(), tokenString)
+		if errors.Is(err, svcauth.ErrUserInactive) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is deactivated"})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", claims.UserID.String())
-		c.Set("email", claims.Email)
-		c.Set("role", claims.Role)
+		c.Set("user_id", user.ID.String())
+		c.Set("email", user.Email)
+		c.Set("role", string(user.Role))
+		c.Set("session_id", claims.SessionID.String())
+		c.Set("email_verified", user.EmailVerifiedAt != nil)
 
 		c.Next()
 	}
 }
 
+// RequireVerifiedEmail 邮箱未验证的账号不能下单购买，需放在AuthMiddleware之后；未登录的请求交由处理函数返回401
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("user_id"); exists && !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Email not verified",
+				"message": "Please verify your email address before making a purchase",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
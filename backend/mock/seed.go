@@ -110,7 +110,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/claude-analyzer",
 			CategoryID:     &categories[0].ID,
 			PriceType:      models.PriceTypePaid,
-			Price:          99.00,
+			Price:          models.NewMoney(9900, "CNY"),
 			DownloadsCount: 1520,
 			PurchasesCount: 320,
 			Rating:         4.8,
@@ -125,7 +125,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/gpt4-docgen",
 			CategoryID:     &categories[0].ID,
 			PriceType:      models.PriceTypePaid,
-			Price:          149.00,
+			Price:          models.NewMoney(14900, "CNY"),
 			DownloadsCount: 890,
 			PurchasesCount: 180,
 			Rating:         4.9,
@@ -140,7 +140,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/data-cleaner",
 			CategoryID:     &categories[1].ID,
 			PriceType:      models.PriceTypeFree,
-			Price:          0.00,
+			Price:          models.NewMoney(0, "CNY"),
 			DownloadsCount: 3200,
 			PurchasesCount: 0,
 			Rating:         4.5,
@@ -155,7 +155,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/excel-auto",
 			CategoryID:     &categories[2].ID,
 			PriceType:      models.PriceTypePaid,
-			Price:          79.00,
+			Price:          models.NewMoney(7900, "CNY"),
 			DownloadsCount: 1100,
 			PurchasesCount: 240,
 			Rating:         4.7,
@@ -170,7 +170,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/code-quality",
 			CategoryID:     &categories[3].ID,
 			PriceType:      models.PriceTypeFree,
-			Price:          0.00,
+			Price:          models.NewMoney(0, "CNY"),
 			DownloadsCount: 2800,
 			PurchasesCount: 0,
 			Rating:         4.6,
@@ -185,7 +185,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/support-bot",
 			CategoryID:     &categories[4].ID,
 			PriceType:      models.PriceTypePaid,
-			Price:          199.00,
+			Price:          models.NewMoney(19900, "CNY"),
 			DownloadsCount: 650,
 			PurchasesCount: 140,
 			Rating:         4.9,
@@ -200,7 +200,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/image-recognition",
 			CategoryID:     &categories[0].ID,
 			PriceType:      models.PriceTypePaid,
-			Price:          129.00,
+			Price:          models.NewMoney(12900, "CNY"),
 			DownloadsCount: 780,
 			PurchasesCount: 165,
 			Rating:         4.7,
@@ -215,7 +215,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/sql-optimizer",
 			CategoryID:     &categories[3].ID,
 			PriceType:      models.PriceTypeFree,
-			Price:          0.00,
+			Price:          models.NewMoney(0, "CNY"),
 			DownloadsCount: 1950,
 			PurchasesCount: 0,
 			Rating:         4.8,
@@ -230,7 +230,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/workflow-automation",
 			CategoryID:     &categories[2].ID,
 			PriceType:      models.PriceTypePaid,
-			Price:          179.00,
+			Price:          models.NewMoney(17900, "CNY"),
 			DownloadsCount: 520,
 			PurchasesCount: 110,
 			Rating:         4.6,
@@ -245,7 +245,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/log-analyzer",
 			CategoryID:     &categories[3].ID,
 			PriceType:      models.PriceTypeFree,
-			Price:          0.00,
+			Price:          models.NewMoney(0, "CNY"),
 			DownloadsCount: 2400,
 			PurchasesCount: 0,
 			Rating:         4.5,
@@ -260,7 +260,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/email-autoresponder",
 			CategoryID:     &categories[4].ID,
 			PriceType:      models.PriceTypePaid,
-			Price:          89.00,
+			Price:          models.NewMoney(8900, "CNY"),
 			DownloadsCount: 920,
 			PurchasesCount: 195,
 			Rating:         4.8,
@@ -275,7 +275,7 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 			GitHubURL:      "https://github.com/example/text-summarizer",
 			CategoryID:     &categories[0].ID,
 			PriceType:      models.PriceTypeFree,
-			Price:          0.00,
+			Price:          models.NewMoney(0, "CNY"),
 			DownloadsCount: 3100,
 			PurchasesCount: 0,
 			Rating:         4.7,
@@ -294,24 +294,29 @@ func createMockSkills(db *gorm.DB, categories []models.SkillCategory) []models.S
 }
 
 func createMockUsers(db *gorm.DB) []models.User {
+	// 演示账号的邮箱视为已验证，可直接下单
+	verifiedAt := time.Now()
 	users := []models.User{
 		{
-			Email:        "admin@skillhub.com",
-			PasswordHash: "$2a$10$TpgcnE4sZOjoTmlXrXPjoeRBN.maoV0DBudKLVi2HjD2FD41rO9LC", // password: admin123
-			Role:         models.RoleAdmin,
-			IsActive:     true,
+			Email:           "admin@skillhub.com",
+			PasswordHash:    "$2a$10$TpgcnE4sZOjoTmlXrXPjoeRBN.maoV0DBudKLVi2HjD2FD41rO9LC", // password: admin123
+			Role:            models.RoleAdmin,
+			IsActive:        true,
+			EmailVerifiedAt: &verifiedAt,
 		},
 		{
-			Email:        "user@example.com",
-			PasswordHash: "$2a$10$TpgcnE4sZOjoTmlXrXPjoeRBN.maoV0DBudKLVi2HjD2FD41rO9LC", // password: admin123
-			Role:         models.RoleUser,
-			IsActive:     true,
+			Email:           "user@example.com",
+			PasswordHash:    "$2a$10$TpgcnE4sZOjoTmlXrXPjoeRBN.maoV0DBudKLVi2HjD2FD41rO9LC", // password: admin123
+			Role:            models.RoleUser,
+			IsActive:        true,
+			EmailVerifiedAt: &verifiedAt,
 		},
 		{
-			Email:        "test@example.com",
-			PasswordHash: "$2a$10$TpgcnE4sZOjoTmlXrXPjoeRBN.maoV0DBudKLVi2HjD2FD41rO9LC", // password: admin123
-			Role:         models.RoleUser,
-			IsActive:     true,
+			Email:           "test@example.com",
+			PasswordHash:    "$2a$10$TpgcnE4sZOjoTmlXrXPjoeRBN.maoV0DBudKLVi2HjD2FD41rO9LC", // password: admin123
+			Role:            models.RoleUser,
+			IsActive:        true,
+			EmailVerifiedAt: &verifiedAt,
 		},
 	}
 
@@ -346,7 +351,7 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 		{
 			OrderNo:       "ORD" + uuid.New().String()[:8],
 			UserID:        users[1].ID,
-			TotalAmount:   99.00,
+			Total:         models.NewMoney(9900, "CNY"),
 			PaymentMethod: "alipay",
 			Status:        models.OrderStatusPaid,
 			CreatedAt:     yesterday,
@@ -355,7 +360,7 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 		{
 			OrderNo:       "ORD" + uuid.New().String()[:8],
 			UserID:        users[1].ID,
-			TotalAmount:   248.00,
+			Total:         models.NewMoney(24800, "CNY"),
 			PaymentMethod: "alipay",
 			Status:        models.OrderStatusPaid,
 			CreatedAt:     now.Add(-12 * time.Hour),
@@ -364,7 +369,7 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 		{
 			OrderNo:       "ORD" + uuid.New().String()[:8],
 			UserID:        users[2].ID,
-			TotalAmount:   149.00,
+			Total:         models.NewMoney(14900, "CNY"),
 			PaymentMethod: "alipay",
 			Status:        models.OrderStatusPaid,
 			CreatedAt:     now.Add(-6 * time.Hour),
@@ -373,7 +378,7 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 		{
 			OrderNo:       "ORD" + uuid.New().String()[:8],
 			UserID:        users[1].ID,
-			TotalAmount:   199.00,
+			Total:         models.NewMoney(19900, "CNY"),
 			PaymentMethod: "wechat",
 			Status:        models.OrderStatusPending,
 			CreatedAt:     now,
@@ -387,11 +392,11 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 
 	// 创建订单项
 	orderItems := []models.OrderItem{
-		{OrderID: orders[0].ID, SkillID: &paidSkills[0].ID, Price: 99.00, Quantity: 1},
-		{OrderID: orders[1].ID, SkillID: &paidSkills[0].ID, Price: 99.00, Quantity: 1},
-		{OrderID: orders[1].ID, SkillID: &paidSkills[1].ID, Price: 149.00, Quantity: 1},
-		{OrderID: orders[2].ID, SkillID: &paidSkills[1].ID, Price: 149.00, Quantity: 1},
-		{OrderID: orders[3].ID, SkillID: &paidSkills[5].ID, Price: 199.00, Quantity: 1},
+		{OrderID: orders[0].ID, SkillID: &paidSkills[0].ID, Price: models.NewMoney(9900, "CNY"), Quantity: 1},
+		{OrderID: orders[1].ID, SkillID: &paidSkills[0].ID, Price: models.NewMoney(9900, "CNY"), Quantity: 1},
+		{OrderID: orders[1].ID, SkillID: &paidSkills[1].ID, Price: models.NewMoney(14900, "CNY"), Quantity: 1},
+		{OrderID: orders[2].ID, SkillID: &paidSkills[1].ID, Price: models.NewMoney(14900, "CNY"), Quantity: 1},
+		{OrderID: orders[3].ID, SkillID: &paidSkills[5].ID, Price: models.NewMoney(19900, "CNY"), Quantity: 1},
 	}
 
 	if err := db.Create(&orderItems).Error; err != nil {
@@ -404,7 +409,7 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 			OrderID:        orders[0].ID,
 			PaymentChannel: "alipay",
 			TransactionID:  "ALI" + uuid.New().String()[:8],
-			Amount:         99.00,
+			Amount:         models.NewMoney(9900, "CNY"),
 			Status:         models.TransactionStatusSuccess,
 			CreatedAt:      yesterday,
 		},
@@ -412,7 +417,7 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 			OrderID:        orders[1].ID,
 			PaymentChannel: "alipay",
 			TransactionID:  "ALI" + uuid.New().String()[:8],
-			Amount:         248.00,
+			Amount:         models.NewMoney(24800, "CNY"),
 			Status:         models.TransactionStatusSuccess,
 			CreatedAt:      now.Add(-12 * time.Hour),
 		},
@@ -420,7 +425,7 @@ func createMockOrders(db *gorm.DB, users []models.User, skills []models.Skill) {
 			OrderID:        orders[2].ID,
 			PaymentChannel: "alipay",
 			TransactionID:  "ALI" + uuid.New().String()[:8],
-			Amount:         149.00,
+			Amount:         models.NewMoney(14900, "CNY"),
 			Status:         models.TransactionStatusSuccess,
 			CreatedAt:      now.Add(-6 * time.Hour),
 		},
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AlertType string
+
+const (
+	AlertTypePaymentMismatch  AlertType = "payment_mismatch"  // 回调金额/币种/商户与订单不一致
+	AlertTypeDuplicatePayment AlertType = "duplicate_payment" // 已支付订单再次收到付款
+	AlertTypeLatePayment      AlertType = "late_payment"      // 已取消或已退款订单收到付款
+	AlertTypeRiskReview       AlertType = "risk_review"       // 订单风险分达到审核阈值，已挂起
+)
+
+// AdminAlert 需要管理员人工处理的告警
+type AdminAlert struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Type       AlertType  `gorm:"type:varchar(50);index" json:"type"`
+	OrderID    *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	Reference  string     `gorm:"type:varchar(255)" json:"reference,omitempty"` // 关联的网关交易号等
+	Message    string     `gorm:"type:text" json:"message"`
+	ResolvedAt *time.Time `gorm:"index" json:"resolved_at,omitempty"`
+	ResolvedBy *uuid.UUID `gorm:"type:uuid" json:"resolved_by,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bundle 技能套餐：一组技能按套餐价出售，购买后授予其中每个技能的永久使用权。
+// PublisherID为空的套餐由管理员创建，可包含任意技能；发布者创建的套餐只能包含自己的技能
+type Bundle struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name        string     `gorm:"type:varchar(255);not null" json:"name"`
+	Description string     `gorm:"type:text" json:"description,omitempty"`
+	Price       Money      `gorm:"embedded;embeddedPrefix:price_" json:"price"` // 套餐价，下单时按各技能标价比例分摊到订单项
+	PublisherID *uuid.UUID `gorm:"type:uuid;index" json:"publisher_id,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"` // 限时套餐的开售时间
+	EndsAt      *time.Time `json:"ends_at,omitempty"`   // 限时套餐的停售时间
+	IsActive    bool       `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Items     []BundleItem `gorm:"foreignKey:BundleID" json:"items,omitempty"`
+	Publisher *User        `gorm:"foreignKey:PublisherID" json:"publisher,omitempty"`
+}
+
+// AvailableAt 套餐在指定时间是否在售
+func (b *Bundle) AvailableAt(now time.Time) bool {
+	if !b.IsActive {
+		return false
+	}
+	if b.StartsAt != nil && now.Before(*b.StartsAt) {
+		return false
+	}
+	return b.EndsAt == nil || now.Before(*b.EndsAt)
+}
+
+// BundleItem 套餐中的技能
+type BundleItem struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BundleID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bundle_skill,priority:1" json:"bundle_id"`
+	SkillID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bundle_skill,priority:2;index" json:"skill_id"`
+	Position int       `gorm:"not null;default:0" json:"position"`
+
+	Skill *Skill `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CartItem 购物车中的技能，每个用户的同一技能只保留一条
+type CartItem struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_cart_user_skill" json:"user_id"`
+	SkillID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_cart_user_skill" json:"skill_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	Skill *Skill `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
+}
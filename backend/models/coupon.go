@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CouponType string
+
+const (
+	CouponTypePercent CouponType = "percent" // 按比例折扣
+	CouponTypeFixed   CouponType = "fixed"   // 固定金额立减，仅适用于同币种订单
+)
+
+type CouponRedemptionStatus string
+
+const (
+	CouponRedemptionReserved CouponRedemptionStatus = "reserved" // 已下单待支付，占用使用次数
+	CouponRedemptionRedeemed CouponRedemptionStatus = "redeemed" // 订单已支付
+	CouponRedemptionReleased CouponRedemptionStatus = "released" // 订单取消或过期，已释放使用次数
+)
+
+// Coupon 优惠券/促销码；Skills、Categories、Publishers 均为空时适用于所有技能
+type Coupon struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code           string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"code"` // 大写
+	Description    string     `gorm:"type:text" json:"description,omitempty"`
+	Type           CouponType `gorm:"type:varchar(20);not null" json:"type"`
+	PercentOff     int        `gorm:"default:0" json:"percent_off,omitempty"` // 1-100
+	AmountOff      Money      `gorm:"embedded;embeddedPrefix:amount_off_" json:"amount_off"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	MaxUses        int        `gorm:"default:0" json:"max_uses"`          // 总使用次数上限，0为不限
+	MaxUsesPerUser int        `gorm:"default:0" json:"max_uses_per_user"` // 每个用户的使用次数上限，0为不限
+	UsedCount      int        `gorm:"default:0" json:"used_count"`        // 已占用的使用次数（含待支付订单）
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Skills     []Skill         `gorm:"many2many:coupon_skills;" json:"skills,omitempty"`
+	Categories []SkillCategory `gorm:"many2many:coupon_categories;" json:"categories,omitempty"`
+	Publishers []User          `gorm:"many2many:coupon_publishers;" json:"publishers,omitempty"`
+}
+
+// CouponRedemption 优惠券的一次使用，每个订单最多一条
+type CouponRedemption struct {
+	ID         uuid.UUID              `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	CouponID   uuid.UUID              `gorm:"type:uuid;not null;index" json:"coupon_id"`
+	UserID     uuid.UUID              `gorm:"type:uuid;not null;index" json:"user_id"`
+	OrderID    uuid.UUID              `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	Discount   Money                  `gorm:"embedded;embeddedPrefix:discount_" json:"discount"`
+	Status     CouponRedemptionStatus `gorm:"type:varchar(20);index" json:"status"`
+	RedeemedAt *time.Time             `json:"redeemed_at,omitempty"`
+	ReleasedAt *time.Time             `json:"released_at,omitempty"`
+	CreatedAt  time.Time              `gorm:"autoCreateTime" json:"created_at"`
+
+	Coupon *Coupon `gorm:"foreignKey:CouponID" json:"coupon,omitempty"`
+	Order  *Order  `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+}
+
+// OrderDiscount 订单上的折扣明细，按订单项记录以便部分退款和分成按实付金额计算
+type OrderDiscount struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrderID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderItemID *uuid.UUID `gorm:"type:uuid;index" json:"order_item_id,omitempty"`
+	CouponID    *uuid.UUID `gorm:"type:uuid;index" json:"coupon_id,omitempty"`
+	Code        string     `gorm:"type:varchar(64)" json:"code,omitempty"`
+	Amount      Money      `gorm:"embedded" json:"amount"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
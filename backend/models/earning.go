@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommissionRule 平台抽成比例（基点，3000表示30%）。技能规则优先于发布者规则，
+// 都没有时使用全局默认比例
+type CommissionRule struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	SkillID     *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_commission_skill,where:skill_id IS NOT NULL" json:"skill_id,omitempty"`
+	PublisherID *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_commission_publisher,where:publisher_id IS NOT NULL AND skill_id IS NULL" json:"publisher_id,omitempty"`
+	RateBps     int        `gorm:"not null" json:"rate_bps"`
+	Note        string     `gorm:"type:varchar(255)" json:"note,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type EarningKind string
+
+const (
+	EarningKindSale     EarningKind = "sale"     // 订单项支付后的分成
+	EarningKindClawback EarningKind = "clawback" // 退款扣回，金额为负
+)
+
+// PublisherEarning 发布者收益流水。金额为扣除平台抽成后的净额（扣回为负数），
+// 结算期（available_at）之前计入待结算余额，之后计入可提现余额，纳入付款批次后记录payout_id
+type PublisherEarning struct {
+	ID          uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	PublisherID uuid.UUID   `gorm:"type:uuid;not null;index" json:"publisher_id"`
+	Kind        EarningKind `gorm:"type:varchar(20);not null" json:"kind"`
+	OrderID     uuid.UUID   `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderItemID uuid.UUID   `gorm:"type:uuid;not null;index;uniqueIndex:idx_earning_item_sale,where:kind = 'sale'" json:"order_item_id"`
+	SkillID     uuid.UUID   `gorm:"type:uuid;not null;index" json:"skill_id"`
+	RefundID    *uuid.UUID  `gorm:"type:uuid;index" json:"refund_id,omitempty"`
+	Gross       Money       `gorm:"embedded;embeddedPrefix:gross_" json:"gross"`           // 订单项实付（扣回时为退款分摊额）
+	Commission  Money       `gorm:"embedded;embeddedPrefix:commission_" json:"commission"` // 平台抽成
+	Amount      Money       `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`         // 发布者净收益
+	RateBps     int         `json:"rate_bps"`
+	AvailableAt time.Time   `gorm:"not null;index" json:"available_at"`
+	PayoutID    *uuid.UUID  `gorm:"type:uuid;index" json:"payout_id,omitempty"`
+	CreatedAt   time.Time   `gorm:"autoCreateTime" json:"created_at"`
+
+	Skill *Skill `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
+}
+
+type PayoutBatchStatus string
+
+const (
+	PayoutBatchPending    PayoutBatchStatus = "pending"    // 已生成，待导出付款
+	PayoutBatchProcessing PayoutBatchStatus = "processing" // 已提交银行/支付渠道
+	PayoutBatchPaid       PayoutBatchStatus = "paid"
+	PayoutBatchCancelled  PayoutBatchStatus = "cancelled" // 取消后收益退回可提现余额
+)
+
+// PayoutBatch 付款批次，每个发布者每种币种一笔付款
+type PayoutBatch struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BatchNo   string            `gorm:"type:varchar(64);uniqueIndex;not null" json:"batch_no"`
+	Status    PayoutBatchStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	CreatedBy *uuid.UUID        `gorm:"type:uuid" json:"created_by,omitempty"`
+	Note      string            `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+	PaidAt    *time.Time        `json:"paid_at,omitempty"`
+
+	Payouts []Payout `gorm:"foreignKey:BatchID" json:"payouts,omitempty"`
+}
+
+type PayoutStatus string
+
+const (
+	PayoutStatusPending PayoutStatus = "pending"
+	PayoutStatusPaid    PayoutStatus = "paid"
+	PayoutStatusFailed  PayoutStatus = "failed" // 付款失败，收益退回可提现余额
+)
+
+// Payout 付款批次中给一个发布者的一笔付款
+type Payout struct {
+	ID          uuid.UUID    `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BatchID     uuid.UUID    `gorm:"type:uuid;not null;index" json:"batch_id"`
+	PublisherID uuid.UUID    `gorm:"type:uuid;not null;index" json:"publisher_id"`
+	Amount      Money        `gorm:"embedded" json:"amount"`
+	Status      PayoutStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Entries     int          `json:"entries"`                                      // 包含的收益流水条数
+	Reference   string       `gorm:"type:varchar(255)" json:"reference,omitempty"` // 银行流水号等
+	Failure     string       `gorm:"type:text" json:"failure,omitempty"`
+	CreatedAt   time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Publisher *User `gorm:"foreignKey:PublisherID" json:"publisher,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 邮件令牌用途
+const (
+	EmailTokenVerifyEmail   = "verify_email"
+	EmailTokenResetPassword = "reset_password"
+)
+
+// EmailToken 邮件链接中的一次性令牌（邮箱验证、重置密码）。链接中的令牌带签名和过期时间，
+// 数据库记录保证每个令牌只能使用一次，重新发送时之前未使用的令牌随之作废
+type EmailToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_email_token_user_purpose" json:"user_id"`
+	Purpose   string     `gorm:"type:varchar(30);not null;index:idx_email_token_user_purpose" json:"purpose"`
+	Email     string     `gorm:"type:varchar(255);not null" json:"email"` // 发送时的邮箱，邮箱变更后旧链接失效
+	ExpiresAt time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EntitlementSource string
+
+const (
+	EntitlementSourcePurchase     EntitlementSource = "purchase"     // 订单支付
+	EntitlementSourceSubscription EntitlementSource = "subscription" // 订阅，随计费周期顺延
+	EntitlementSourceAdmin        EntitlementSource = "admin"        // 管理员手动授予
+	EntitlementSourceGift         EntitlementSource = "gift"         // 兑换礼品码
+)
+
+// UserEntitlement 用户对技能的使用权，下载和购买检查以此为准。
+// 购买的使用权每个订单项一条，订阅的使用权每个订阅一条，礼品的使用权每个兑换码一条
+type UserEntitlement struct {
+	ID             uuid.UUID         `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID         `gorm:"type:uuid;not null;index:idx_entitlement_user_skill" json:"user_id"`
+	SkillID        uuid.UUID         `gorm:"type:uuid;not null;index:idx_entitlement_user_skill;uniqueIndex:idx_entitlement_order_skill,priority:2" json:"skill_id"`
+	Source         EntitlementSource `gorm:"type:varchar(20);not null" json:"source"`
+	OrderID        *uuid.UUID        `gorm:"type:uuid;uniqueIndex:idx_entitlement_order_skill,priority:1,where:order_id IS NOT NULL" json:"order_id,omitempty"` // 同一订单的同一技能只授予一次
+	SubscriptionID *uuid.UUID        `gorm:"type:uuid;uniqueIndex:idx_entitlement_subscription,where:subscription_id IS NOT NULL" json:"subscription_id,omitempty"`
+	GiftID         *uuid.UUID        `gorm:"type:uuid;uniqueIndex:idx_entitlement_gift,where:gift_id IS NOT NULL" json:"gift_id,omitempty"` // 每个礼品码只授予一次
+	GrantedAt      time.Time         `gorm:"not null" json:"granted_at"`
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty"`                  // 为空表示永久有效
+	GrantedBy      *uuid.UUID        `gorm:"type:uuid" json:"granted_by,omitempty"` // 手动授予的管理员
+	Reason         string            `gorm:"type:text" json:"reason,omitempty"`
+	RevokedAt      *time.Time        `json:"revoked_at,omitempty"`
+	RevokedBy      *uuid.UUID        `gorm:"type:uuid" json:"revoked_by,omitempty"`
+	RevokeReason   string            `gorm:"type:text" json:"revoke_reason,omitempty"`
+	CreatedAt      time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Skill *Skill `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
+	User  *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// ActiveAt 使用权在指定时间是否有效
+func (e *UserEntitlement) ActiveAt(now time.Time) bool {
+	if e.RevokedAt != nil || now.Before(e.GrantedAt) {
+		return false
+	}
+	return e.ExpiresAt == nil || now.Before(*e.ExpiresAt)
+}
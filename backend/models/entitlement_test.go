@@ -0,0 +1,19 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserEntitlementActiveAt(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	assert.True(t, (&UserEntitlement{GrantedAt: past}).ActiveAt(now), "permanent")
+	assert.True(t, (&UserEntitlement{GrantedAt: past, ExpiresAt: &future}).ActiveAt(now), "not yet expired")
+	assert.False(t, (&UserEntitlement{GrantedAt: past, ExpiresAt: &now}).ActiveAt(now), "expired")
+	assert.False(t, (&UserEntitlement{GrantedAt: future}).ActiveAt(now), "not yet granted")
+	assert.False(t, (&UserEntitlement{GrantedAt: past, RevokedAt: &past}).ActiveAt(now), "revoked")
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type GiftStatus string
+
+const (
+	GiftStatusUnredeemed GiftStatus = "unredeemed" // 已支付，等待兑换
+	GiftStatusRedeemed   GiftStatus = "redeemed"   // 已兑换，使用权授予兑换人
+	GiftStatusExpired    GiftStatus = "expired"    // 超过兑换期限未兑换
+	GiftStatusRefunded   GiftStatus = "refunded"   // 礼品订单（或订单项）已退款，兑换后的使用权一并撤销
+)
+
+// Gift 礼品兑换码：礼品订单支付后每个订单项按数量各生成一个，一次性使用。
+// 指定收件人邮箱时只有该邮箱的用户可以兑换
+type Gift struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code           string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"code,omitempty"` // 形如 ABCD-EFGH-JKLM-NPQR
+	OrderID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderItemID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_item_id"`
+	SkillID        uuid.UUID  `gorm:"type:uuid;not null" json:"skill_id"`
+	PurchaserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"purchaser_id"`
+	RecipientEmail string     `gorm:"type:varchar(255);index" json:"recipient_email,omitempty"` // 小写
+	Message        string     `gorm:"type:text" json:"message,omitempty"`
+	Status         GiftStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"` // 为空表示不过期
+	RedeemedBy     *uuid.UUID `gorm:"type:uuid;index" json:"redeemed_by,omitempty"`
+	RedeemedAt     *time.Time `json:"redeemed_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Skill *Skill `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
+}
+
+// ExpiredAt 未兑换的礼品在指定时间是否已过兑换期限
+func (g *Gift) ExpiredAt(now time.Time) bool {
+	return g.ExpiresAt != nil && !now.Before(*g.ExpiresAt)
+}
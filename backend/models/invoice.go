@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceStatusIssued InvoiceStatus = "issued"
+	InvoiceStatusVoid   InvoiceStatus = "void" // 作废，重开时原发票作废
+)
+
+type FapiaoTitleType string
+
+const (
+	FapiaoTitlePersonal FapiaoTitleType = "personal" // 个人抬头
+	FapiaoTitleCompany  FapiaoTitleType = "company"  // 单位抬头，需填写纳税人识别号
+)
+
+// IsValid 抬头类型是否合法
+func (t FapiaoTitleType) IsValid() bool {
+	return t == FapiaoTitlePersonal || t == FapiaoTitleCompany
+}
+
+// InvoiceParty 发票上的买方或卖方信息
+type InvoiceParty struct {
+	Name        string `gorm:"type:varchar(255)" json:"name"`
+	Email       string `gorm:"type:varchar(255)" json:"email,omitempty"`
+	TaxID       string `gorm:"type:varchar(64)" json:"tax_id,omitempty"` // 纳税人识别号/VAT号
+	Address     string `gorm:"type:varchar(500)" json:"address,omitempty"`
+	Phone       string `gorm:"type:varchar(50)" json:"phone,omitempty"`
+	BankName    string `gorm:"type:varchar(255)" json:"bank_name,omitempty"`
+	BankAccount string `gorm:"type:varchar(64)" json:"bank_account,omitempty"`
+}
+
+// BillingProfile 用户的开票信息，开具发票时复制到发票
+type BillingProfile struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID      uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	Party       InvoiceParty    `gorm:"embedded" json:"party"`
+	TitleType   FapiaoTitleType `gorm:"type:varchar(20);default:'personal'" json:"title_type"`
+	FapiaoTitle string          `gorm:"type:varchar(255)" json:"fapiao_title,omitempty"` // 发票抬头，个人为空时使用姓名
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Invoice 已支付订单的发票/收据，买卖双方信息和金额在开具时快照，之后不随订单或资料变化。
+// 同一订单同时只有一张有效发票，重开时原发票作废
+type Invoice struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	InvoiceNo     string          `gorm:"type:varchar(64);uniqueIndex;not null" json:"invoice_no"`
+	OrderID       uuid.UUID       `gorm:"type:uuid;not null;index;uniqueIndex:idx_invoice_order_issued,where:status = 'issued'" json:"order_id"`
+	OrderNo       string          `gorm:"type:varchar(255)" json:"order_no"`
+	UserID        uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status        InvoiceStatus   `gorm:"type:varchar(20);not null;default:'issued'" json:"status"`
+	Buyer         InvoiceParty    `gorm:"embedded;embeddedPrefix:buyer_" json:"buyer"`
+	TitleType     FapiaoTitleType `gorm:"type:varchar(20)" json:"title_type"`
+	FapiaoTitle   string          `gorm:"type:varchar(255)" json:"fapiao_title"`
+	Seller        InvoiceParty    `gorm:"embedded;embeddedPrefix:seller_" json:"seller"`
+	Subtotal      Money           `gorm:"embedded;embeddedPrefix:subtotal_" json:"subtotal"`
+	Discount      Money           `gorm:"embedded;embeddedPrefix:discount_" json:"discount"`
+	Tax           Money           `gorm:"embedded;embeddedPrefix:tax_" json:"tax"`
+	TaxInclusive  bool            `gorm:"default:false" json:"tax_inclusive,omitempty"` // 价内税：税额已包含在小计中
+	Total         Money           `gorm:"embedded;embeddedPrefix:total_" json:"total"`
+	PaymentMethod string          `gorm:"type:varchar(50)" json:"payment_method"`
+	PaidAt        *time.Time      `json:"paid_at,omitempty"`
+	IssuedAt      time.Time       `gorm:"not null" json:"issued_at"`
+	ReplacesID    *uuid.UUID      `gorm:"type:uuid" json:"replaces_id,omitempty"` // 重开时被作废的原发票
+	VoidedAt      *time.Time      `json:"voided_at,omitempty"`
+	VoidedBy      *uuid.UUID      `gorm:"type:uuid" json:"voided_by,omitempty"`
+	VoidReason    string          `gorm:"type:text" json:"void_reason,omitempty"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Lines    []InvoiceLine    `gorm:"foreignKey:InvoiceID" json:"lines,omitempty"`
+	TaxLines []InvoiceTaxLine `gorm:"foreignKey:InvoiceID" json:"tax_lines,omitempty"`
+}
+
+// InvoiceLine 发票明细行
+type InvoiceLine struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	InvoiceID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"invoice_id"`
+	LineNo      int        `gorm:"not null;default:0" json:"line_no"`
+	SkillID     *uuid.UUID `gorm:"type:uuid" json:"skill_id,omitempty"`
+	Description string     `gorm:"type:varchar(500)" json:"description"`
+	Quantity    int        `json:"quantity"`
+	UnitPrice   Money      `gorm:"embedded;embeddedPrefix:unit_price_" json:"unit_price"`
+	Discount    Money      `gorm:"embedded;embeddedPrefix:discount_" json:"discount"`
+	Amount      Money      `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+}
+
+// InvoiceSequence 发票号计数器，每个范围（前缀+年份）一行，在开票事务中递增以保证连续
+type InvoiceSequence struct {
+	Scope     string `gorm:"type:varchar(64);primary_key"`
+	LastValue int64  `gorm:"not null;default:0"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrLedgerImmutable 总账只能追加，已过账的分录和分录行不能修改或删除，更正须过账冲销分录
+var ErrLedgerImmutable = errors.New("ledger entries are append-only")
+
+type LedgerAccountType string
+
+const (
+	LedgerAccountAsset     LedgerAccountType = "asset"
+	LedgerAccountLiability LedgerAccountType = "liability"
+	LedgerAccountEquity    LedgerAccountType = "equity"
+	LedgerAccountRevenue   LedgerAccountType = "revenue"
+	LedgerAccountExpense   LedgerAccountType = "expense"
+)
+
+// DebitNormal 资产和费用类科目余额在借方，其余在贷方
+func (t LedgerAccountType) DebitNormal() bool {
+	return t == LedgerAccountAsset || t == LedgerAccountExpense
+}
+
+// LedgerAccount 总账科目，首次过账时按科目表创建
+type LedgerAccount struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Code      string            `gorm:"type:varchar(100);uniqueIndex;not null" json:"code"` // 如 sales、gateway:alipay
+	Name      string            `gorm:"type:varchar(255);not null" json:"name"`
+	Type      LedgerAccountType `gorm:"type:varchar(20);not null" json:"type"`
+	CreatedAt time.Time         `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type JournalEvent string
+
+const (
+	JournalEventPayment    JournalEvent = "payment"    // 收到网关付款
+	JournalEventFee        JournalEvent = "fee"        // 支付渠道手续费
+	JournalEventRefund     JournalEvent = "refund"     // 退款成功
+	JournalEventCommission JournalEvent = "commission" // 发布者分成（退款扣回时金额为负）
+	JournalEventPayout     JournalEvent = "payout"     // 向发布者付款
+	JournalEventTax        JournalEvent = "tax"        // 订单税额转入应交税费（退款时冲回）
+	JournalEventReversal   JournalEvent = "reversal"   // 冲销错误分录
+)
+
+// JournalEntry 记账分录。同一事件和来源（reference）只过账一次，重复调用不会重复记账
+type JournalEntry struct {
+	ID        uuid.UUID    `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	EntryNo   string       `gorm:"type:varchar(64);uniqueIndex;not null" json:"entry_no"`
+	Event     JournalEvent `gorm:"type:varchar(30);not null;uniqueIndex:idx_journal_event_reference" json:"event"`
+	Reference string       `gorm:"type:varchar(255);not null;uniqueIndex:idx_journal_event_reference" json:"reference"` // 交易、退款、收益、付款或被冲销分录的ID
+	OrderID   *uuid.UUID   `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	Memo      string       `gorm:"type:text" json:"memo,omitempty"`
+	CreatedBy *uuid.UUID   `gorm:"type:uuid" json:"created_by,omitempty"` // 人工冲销的管理员
+	PostedAt  time.Time    `gorm:"not null;index" json:"posted_at"`
+	CreatedAt time.Time    `gorm:"autoCreateTime" json:"created_at"`
+
+	Postings []LedgerPosting `gorm:"foreignKey:EntryID" json:"postings,omitempty"`
+}
+
+// LedgerPosting 分录行，借方为正、贷方为负，同一分录各币种的分录行合计为零
+type LedgerPosting struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	EntryID   uuid.UUID `gorm:"type:uuid;not null;index" json:"entry_id"`
+	AccountID uuid.UUID `gorm:"type:uuid;not null;index" json:"account_id"`
+	Amount    Money     `gorm:"embedded" json:"amount"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	Account *LedgerAccount `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+}
+
+func (*JournalEntry) BeforeUpdate(*gorm.DB) error  { return ErrLedgerImmutable }
+func (*JournalEntry) BeforeDelete(*gorm.DB) error  { return ErrLedgerImmutable }
+func (*LedgerPosting) BeforeUpdate(*gorm.DB) error { return ErrLedgerImmutable }
+func (*LedgerPosting) BeforeDelete(*gorm.DB) error { return ErrLedgerImmutable }
@@ -46,7 +46,18 @@ func InitDB() error {
 }
 
 func AutoMigrate() error {
-	return DB.AutoMigrate(
+	if err := dedupeTransactions(); err != nil {
+		return err
+	}
+	// 币种列由AutoMigrate以默认值CNY添加，之后按支付方式修正历史记录
+	needsCurrencyBackfill := DB.Migrator().HasTable(&Order{}) &&
+		!DB.Migrator().HasColumn(&Order{}, "currency") && !DB.Migrator().HasColumn(&Order{}, "total_currency")
+	// 使用权表首次创建时由已支付订单和有效订阅生成
+	needsEntitlementBackfill := DB.Migrator().HasTable(&Order{}) && !DB.Migrator().HasTable(&UserEntitlement{})
+	// 邮箱验证上线前注册的账号视为已验证，不影响其继续购买
+	needsVerificationBackfill := DB.Migrator().HasTable(&User{}) && !DB.Migrator().HasColumn(&User{}, "email_verified_at")
+
+	if err := DB.AutoMigrate(
 		&User{},
 		&UserProfile{},
 		&OAuthProvider{},
@@ -54,13 +65,184 @@ func AutoMigrate() error {
 		&SkillTag{},
 		&Skill{},
 		&SkillTranslation{},
+		&SkillPrice{},
+		&ExchangeRate{},
 		&Order{},
 		&OrderItem{},
 		&Transaction{},
+		&Refund{},
+		&OrderEvent{},
+		&PaymentRoute{},
+		&CartItem{},
+		&Coupon{},
+		&CouponRedemption{},
+		&OrderDiscount{},
+		&OrderTaxLine{},
+		&SubscriptionPlan{},
+		&Subscription{},
+		&UserEntitlement{},
+		&Gift{},
+		&Bundle{},
+		&BundleItem{},
+		&BillingProfile{},
+		&Invoice{},
+		&InvoiceLine{},
+		&InvoiceTaxLine{},
+		&InvoiceSequence{},
+		&CommissionRule{},
+		&PublisherEarning{},
+		&PayoutBatch{},
+		&Payout{},
+		&LedgerAccount{},
+		&JournalEntry{},
+		&LedgerPosting{},
+		&AdminAlert{},
+		&AuthSession{},
+		&RefreshToken{},
+		&OAuthState{},
+		&EmailToken{},
 		&SkillAnalytics{},
 		&SyncLog{},
 		&ScheduledTask{},
-	)
+	); err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := migrateLegacyCurrencies(tx); err != nil {
+			return err
+		}
+		if needsCurrencyBackfill {
+			if err := backfillCurrencies(tx); err != nil {
+				return err
+			}
+		}
+		if err := migrateLegacyAmounts(tx); err != nil {
+			return err
+		}
+		if needsVerificationBackfill {
+			if err := tx.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+				return err
+			}
+		}
+		if needsEntitlementBackfill {
+			return backfillEntitlements(tx)
+		}
+		return nil
+	})
+}
+
+// legacyAmount 旧版以decimal(10,2)存储的金额列及对应的Money列
+type legacyAmount struct {
+	table    string
+	column   string // 旧金额列
+	minor    string // 新的最小单位金额列
+	currency string // 新的币种列
+	// currencyFrom 旧记录没有币种时用于填充币种列的SQL表达式，为空表示币种列已有值
+	currencyFrom string
+}
+
+var legacyAmounts = []legacyAmount{
+	{table: "skills", column: "price", minor: "price_amount_minor", currency: "price_currency"},
+	{table: "skill_prices", column: "amount", minor: "amount_minor", currency: "currency"},
+	{table: "orders", column: "total_amount", minor: "total_amount_minor", currency: "total_currency"},
+	{table: "orders", column: "refunded_amount", minor: "refunded_amount_minor", currency: "refunded_currency",
+		currencyFrom: "total_currency"},
+	{table: "order_items", column: "price", minor: "price_amount_minor", currency: "price_currency",
+		currencyFrom: "(SELECT o.total_currency FROM orders o WHERE o.id = order_items.order_id)"},
+	{table: "transactions", column: "amount", minor: "amount_minor", currency: "currency"},
+	{table: "refunds", column: "amount", minor: "amount_minor", currency: "currency",
+		currencyFrom: "(SELECT o.total_currency FROM orders o WHERE o.id = refunds.order_id)"},
+}
+
+// migrateLegacyCurrencies 将旧的币种列复制到Money的币种列
+func migrateLegacyCurrencies(tx *gorm.DB) error {
+	moves := []struct{ table, from, to string }{
+		{"skills", "currency", "price_currency"},
+		{"orders", "currency", "total_currency"},
+	}
+	for _, m := range moves {
+		if !tx.Migrator().HasColumn(m.table, m.from) {
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = COALESCE(NULLIF(%s, ''), %s)`, m.table, m.to, m.from, m.to)).Error; err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(m.table, m.from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyAmounts 将旧的decimal金额按币种精度换算为最小单位整数，并删除旧列
+func migrateLegacyAmounts(tx *gorm.DB) error {
+	for _, l := range legacyAmounts {
+		if !tx.Migrator().HasColumn(l.table, l.column) {
+			continue
+		}
+		if l.currencyFrom != "" {
+			if err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = COALESCE(%s, 'CNY')`, l.table, l.currency, l.currencyFrom)).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ROUND(COALESCE(%s, 0) * %s)`,
+			l.table, l.minor, l.column, minorUnitScaleSQL(l.currency))).Error; err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropColumn(l.table, l.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillCurrencies 历史订单按下单网关的结算币种记录币种（Stripe/PayPal以USD收款）
+func backfillCurrencies(tx *gorm.DB) error {
+	if err := tx.Exec(`UPDATE orders SET total_currency = 'USD' WHERE payment_method IN ('stripe', 'paypal')`).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`UPDATE transactions SET currency = 'USD' WHERE payment_channel IN ('stripe', 'paypal')`).Error
+}
+
+// backfillEntitlements 为已支付且未退款的订单项及当期有效的订阅生成使用权
+func backfillEntitlements(tx *gorm.DB) error {
+	if err := tx.Exec(`INSERT INTO user_entitlements
+		(id, user_id, skill_id, source, order_id, granted_at, reason, created_at, updated_at)
+		SELECT uuid_generate_v4(), o.user_id, oi.skill_id, ?, o.id, COALESCE(o.paid_at, o.created_at), 'order ' || o.order_no, NOW(), NOW()
+		FROM order_items oi JOIN orders o ON o.id = oi.order_id
+		WHERE o.status = ? AND o.subscription_id IS NULL AND oi.skill_id IS NOT NULL AND oi.refunded_at IS NULL
+		ON CONFLICT DO NOTHING`,
+		EntitlementSourcePurchase, OrderStatusPaid).Error; err != nil {
+		return err
+	}
+
+	var grace time.Duration
+	if config.AppConfig != nil {
+		grace = config.AppConfig.Payment.SubscriptionGrace
+	}
+	return tx.Exec(`INSERT INTO user_entitlements
+		(id, user_id, skill_id, source, subscription_id, granted_at, expires_at, reason, created_at, updated_at)
+		SELECT uuid_generate_v4(), s.user_id, s.skill_id, ?, s.id, s.current_period_start,
+			CASE WHEN s.status = ? THEN s.current_period_end ELSE s.current_period_end + make_interval(secs => ?) END,
+			'subscription ' || s.id, NOW(), NOW()
+		FROM subscriptions s
+		WHERE s.status IN ? AND s.current_period_start IS NOT NULL AND s.current_period_end IS NOT NULL`,
+		EntitlementSourceSubscription, SubscriptionStatusCancelled, grace.Seconds(),
+		[]SubscriptionStatus{SubscriptionStatusActive, SubscriptionStatusPastDue, SubscriptionStatusCancelled}).Error
+}
+
+// dedupeTransactions 删除重放回调产生的重复交易记录（保留最早一条），以便建立(渠道, 网关交易号)唯一索引
+func dedupeTransactions() error {
+	if !DB.Migrator().HasTable(&Transaction{}) || DB.Migrator().HasIndex(&Transaction{}, "idx_transaction_channel_trade_no") {
+		return nil
+	}
+
+	return DB.Exec(`DELETE FROM transactions t USING transactions d
+		WHERE t.payment_channel = d.payment_channel
+		AND t.transaction_id = d.transaction_id
+		AND t.transaction_id <> ''
+		AND (t.created_at, t.id) > (d.created_at, d.id)`).Error
 }
 
 func GetDB() *gorm.DB {
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAmount 金额格式不合法或超出币种精度
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// zeroDecimalCurrencies 没有小数位的币种（如日元），最小单位即为元
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// threeDecimalCurrencies 三位小数的币种
+var threeDecimalCurrencies = map[string]bool{
+	"BHD": true, "JOD": true, "KWD": true, "OMR": true, "TND": true,
+}
+
+// CurrencyExponent 币种的小数位数
+func CurrencyExponent(currency string) int {
+	currency = strings.ToUpper(currency)
+	switch {
+	case zeroDecimalCurrencies[currency]:
+		return 0
+	case threeDecimalCurrencies[currency]:
+		return 3
+	}
+	return 2
+}
+
+// Money 金额：以币种最小单位（分、日元等）存储的整数及其币种
+// 数据库中以嵌入字段存储为 <前缀>amount_minor 和 <前缀>currency 两列，
+// JSON 序列化为 {"amount":"19.99","currency":"USD"}，金额为十进制字符串以免精度丢失
+type Money struct {
+	Amount   int64  `gorm:"column:amount_minor;not null;default:0"`
+	Currency string `gorm:"column:currency;type:varchar(3);default:'CNY'"`
+}
+
+// NewMoney 以最小单位金额创建Money
+func NewMoney(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: strings.ToUpper(strings.TrimSpace(currency))}
+}
+
+// ParseMoney 精确解析十进制金额字符串（如"19.99"），小数位超出币种精度且非零时报错
+func ParseMoney(amount, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	s := strings.TrimSpace(amount)
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+
+	exp := CurrencyExponent(currency)
+	if len(frac) > exp {
+		if strings.Trim(frac[exp:], "0") != "" {
+			return Money{}, fmt.Errorf("%w: %q has more than %d decimal places for %s", ErrInvalidAmount, amount, exp, currency)
+		}
+		frac = frac[:exp]
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	digits := strings.TrimLeft(whole+frac, "0")
+	if digits == "" {
+		return Money{Currency: currency}, nil
+	}
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// isDigits 字符串是否只包含数字（空字符串视为合法）
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decimal 十进制金额字符串，小数位数由币种决定（如 "19.99"、日元 "1500"）
+func (m Money) Decimal() string {
+	exp := CurrencyExponent(m.Currency)
+	minor := m.Amount
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	digits := strconv.FormatInt(minor, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String 带币种的金额，如 "19.99 USD"
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero 金额是否为零
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// SameCurrency 两个金额币种是否相同
+func (m Money) SameCurrency(o Money) bool {
+	return strings.EqualFold(m.Currency, o.Currency)
+}
+
+// Add 相加，调用方需保证币种相同（零值金额的币种取另一方）
+func (m Money) Add(o Money) Money {
+	if m.Currency == "" {
+		m.Currency = o.Currency
+	}
+	m.Amount += o.Amount
+	return m
+}
+
+// Sub 相减，调用方需保证币种相同
+func (m Money) Sub(o Money) Money {
+	if m.Currency == "" {
+		m.Currency = o.Currency
+	}
+	m.Amount -= o.Amount
+	return m
+}
+
+// Mul 乘以数量
+func (m Money) Mul(n int64) Money {
+	m.Amount *= n
+	return m
+}
+
+// Convert 按汇率换算为目标币种，按目标币种精度四舍五入
+func (m Money) Convert(rate float64, to string) Money {
+	to = strings.ToUpper(to)
+	scale := math.Pow10(CurrencyExponent(to) - CurrencyExponent(m.Currency))
+	return Money{Amount: int64(math.Round(float64(m.Amount) * rate * scale)), Currency: to}
+}
+
+// moneyJSON Money的JSON形式
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON 序列化为 {"amount":"19.99","currency":"USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON 解析 {"amount":"19.99","currency":"USD"}，金额也可为JSON数字
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+	amount := strings.Trim(string(v.Amount), `"`)
+	if amount == "" {
+		*m = NewMoney(0, v.Currency)
+		return nil
+	}
+	parsed, err := ParseMoney(amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// SumByCurrency 按币种汇总金额，结果按币种排序
+func SumByCurrency(amounts ...Money) []Money {
+	totals := make(map[string]int64)
+	for _, a := range amounts {
+		totals[strings.ToUpper(a.Currency)] += a.Amount
+	}
+	result := make([]Money, 0, len(totals))
+	for currency, amount := range totals {
+		result = append(result, Money{Amount: amount, Currency: currency})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result
+}
+
+// minorUnitScaleSQL 将币种列换算为最小单位倍数的SQL表达式，用于迁移历史金额
+func minorUnitScaleSQL(currencyColumn string) string {
+	list := func(set map[string]bool) string {
+		codes := make([]string, 0, len(set))
+		for c := range set {
+			codes = append(codes, "'"+c+"'")
+		}
+		sort.Strings(codes)
+		return strings.Join(codes, ", ")
+	}
+	return fmt.Sprintf("CASE WHEN UPPER(%[1]s) IN (%[2]s) THEN 1 WHEN UPPER(%[1]s) IN (%[3]s) THEN 1000 ELSE 100 END",
+		currencyColumn, list(zeroDecimalCurrencies), list(threeDecimalCurrencies))
+}
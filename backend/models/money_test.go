@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		minor    int64
+		text     string
+	}{
+		{"19.99", "USD", 1999, "19.99"},
+		{"0.29", "usd", 29, "0.29"},
+		{"0.1", "CNY", 10, "0.10"},
+		{"1500", "JPY", 1500, "1500"},
+		{"1500.00", "JPY", 1500, "1500"},
+		{"1.234", "KWD", 1234, "1.234"},
+		{"-5.5", "EUR", -550, "-5.50"},
+		{".5", "USD", 50, "0.50"},
+	}
+
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.amount, tt.currency)
+		if assert.NoError(t, err, tt.amount) {
+			assert.Equal(t, tt.minor, m.Amount, tt.amount)
+			assert.Equal(t, tt.text, m.Decimal(), tt.amount)
+		}
+	}
+
+	for _, bad := range []string{"", ".", "abc", "1.2.3", "19.999", "1e3", "1,000"} {
+		_, err := ParseMoney(bad, "USD")
+		assert.True(t, errors.Is(err, ErrInvalidAmount), "expected ErrInvalidAmount for %q, got %v", bad, err)
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	price := NewMoney(1999, "usd")
+	assert.Equal(t, "USD", price.Currency)
+	assert.Equal(t, NewMoney(5997, "USD"), price.Mul(3))
+	assert.Equal(t, NewMoney(2009, "USD"), price.Add(NewMoney(10, "USD")))
+	assert.Equal(t, NewMoney(1999, "USD"), Money{}.Add(price))
+	assert.Equal(t, "19.99 USD", price.String())
+
+	// 0.1 + 0.2 在浮点数下不等于 0.3
+	sum := NewMoney(10, "CNY").Add(NewMoney(20, "CNY"))
+	assert.Equal(t, "0.30", sum.Decimal())
+
+	assert.Equal(t, []Money{NewMoney(300, "CNY"), NewMoney(150, "USD")},
+		SumByCurrency(NewMoney(100, "USD"), NewMoney(300, "CNY"), NewMoney(50, "usd")))
+}
+
+func TestMoneyConvert(t *testing.T) {
+	assert.Equal(t, NewMoney(7116, "CNY"), NewMoney(999, "USD").Convert(7.1234, "cny"))
+	assert.Equal(t, NewMoney(1512, "JPY"), NewMoney(999, "USD").Convert(151.37, "JPY"))
+	assert.Equal(t, NewMoney(660, "USD"), NewMoney(1000, "JPY").Convert(0.0066, "USD"))
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(1999, "USD"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"19.99","currency":"USD"}`, string(data))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":"19.99","currency":"usd"}`), &m))
+	assert.Equal(t, NewMoney(1999, "USD"), m)
+
+	// 兼容数字形式的金额，按十进制文本精确解析
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":0.29,"currency":"EUR"}`), &m))
+	assert.Equal(t, NewMoney(29, "EUR"), m)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"1.001","currency":"USD"}`), &m))
+}
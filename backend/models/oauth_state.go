@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthState 第三方登录的授权请求：state只保存哈希，并与发起登录的浏览器绑定。
+// 回调时state、浏览器绑定值和有效期都校验通过才会用授权码换取令牌，且每个state只能使用一次
+type OAuthState struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Provider     string     `gorm:"type:varchar(50);not null" json:"provider"`
+	StateHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"` // SHA-256十六进制
+	BindingHash  string     `gorm:"type:varchar(64);not null" json:"-"`             // 浏览器绑定值的SHA-256十六进制
+	CodeVerifier string     `gorm:"type:varchar(128)" json:"-"`                     // PKCE code_verifier，不支持PKCE的提供商为空
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
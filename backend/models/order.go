@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,10 @@ const (
 	OrderStatusPaid      OrderStatus = "paid"
 	OrderStatusCancelled OrderStatus = "cancelled"
 	OrderStatusRefunded  OrderStatus = "refunded"
+	// OrderStatusPaymentReview 已收到付款但金额、币种或商户与订单不符，待人工审核
+	OrderStatusPaymentReview OrderStatus = "payment_review"
+	// OrderStatusRiskReview 下单风险分达到审核阈值，人工审核通过后才能支付
+	OrderStatusRiskReview OrderStatus = "risk_review"
 )
 
 type TransactionStatus string
@@ -27,34 +32,86 @@ type Order struct {
 	ID            uuid.UUID    `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	OrderNo       string       `gorm:"type:varchar(255);uniqueIndex;not null" json:"order_no"`
 	UserID        uuid.UUID    `gorm:"type:uuid;not null;index" json:"user_id"`
-	TotalAmount   float64      `gorm:"type:decimal(10,2)" json:"total_amount"`
+	Total         Money        `gorm:"embedded;embeddedPrefix:total_" json:"total"`       // 实付金额（已扣除折扣，含价外税）
+	Discount      Money        `gorm:"embedded;embeddedPrefix:discount_" json:"discount"` // 折扣合计
+	Tax           Money        `gorm:"embedded;embeddedPrefix:tax_" json:"tax"`           // 税额合计，价外税已计入实付金额
+	TaxInclusive  bool         `gorm:"default:false" json:"tax_inclusive,omitempty"`     // 标价含税（价内税）
+	BuyerTaxID    string       `gorm:"type:varchar(64)" json:"buyer_tax_id,omitempty"`   // 下单时买方的税号，B2B反向征收的依据
+	Region        string       `gorm:"type:varchar(10)" json:"region,omitempty"` // 下单时的国家/地区，可带省/州（如 US-CA），用于地区定价、支付路由和计税
 	PaymentMethod string       `gorm:"type:varchar(50)" json:"payment_method"`
+	PaymentRef    string       `gorm:"type:varchar(255);index" json:"payment_ref,omitempty"` // 网关侧订单号/会话ID
+	SubscriptionID *uuid.UUID  `gorm:"type:uuid;index" json:"subscription_id,omitempty"` // 订阅的首期或续费订单
+	IsGift         bool        `gorm:"default:false" json:"is_gift,omitempty"`             // 礼品订单：支付后生成兑换码，不授予买家使用权
+	GiftEmail      string      `gorm:"type:varchar(255)" json:"gift_email,omitempty"`       // 礼品收件人邮箱，为空时兑换码可由任何人兑换
+	GiftMessage    string      `gorm:"type:text" json:"gift_message,omitempty"`
 	Status        OrderStatus  `gorm:"type:varchar(50);default:'pending'" json:"status"`
+	ClientIP        string     `gorm:"type:varchar(64);index" json:"client_ip,omitempty"`        // 下单时的客户端IP，用于风控频率统计
+	CardFingerprint string     `gorm:"type:varchar(128);index" json:"card_fingerprint,omitempty"` // 下单时提交的支付卡指纹，用于风控频率统计
+	RiskScore       int        `gorm:"default:0" json:"risk_score"`                               // 下单时的风险分（0-100）
+	RiskReasons     string     `gorm:"type:text" json:"risk_reasons,omitempty"`                    // 触发的风控规则
+	RiskReviewedAt  *time.Time `json:"risk_reviewed_at,omitempty"`                                 // 风控审核通过的时间，待支付过期从此时起算
+	Refunded      Money        `gorm:"embedded;embeddedPrefix:refunded_" json:"refunded"`
 	CreatedAt     time.Time    `gorm:"autoCreateTime" json:"created_at"`
 	PaidAt        *time.Time   `json:"paid_at,omitempty"`
 
 	User         User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Items        []OrderItem   `gorm:"foreignKey:OrderID" json:"items,omitempty"`
 	Transactions []Transaction `gorm:"foreignKey:OrderID" json:"transactions,omitempty"`
+	Refunds      []Refund      `gorm:"foreignKey:OrderID" json:"refunds,omitempty"`
+	Discounts    []OrderDiscount `gorm:"foreignKey:OrderID" json:"discounts,omitempty"`
+	TaxLines     []OrderTaxLine  `gorm:"foreignKey:OrderID" json:"tax_lines,omitempty"`
 }
 
 type OrderItem struct {
 	ID      uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	OrderID uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
 	SkillID *uuid.UUID `gorm:"type:uuid" json:"skill_id,omitempty"`
-	Price   float64    `gorm:"type:decimal(10,2)" json:"price"`
+	Price   Money      `gorm:"embedded;embeddedPrefix:price_" json:"price"`
 	Quantity int        `gorm:"default:1" json:"quantity"`
+	Discount Money      `gorm:"embedded;embeddedPrefix:discount_" json:"discount"` // 分摊到该项的折扣
+	Tax        Money      `gorm:"embedded;embeddedPrefix:tax_" json:"tax"`         // 分摊到该项的税额
+	TaxInclusive bool     `gorm:"default:false" json:"tax_inclusive,omitempty"`   // 税额是否已包含在小计中
+	BundleID   *uuid.UUID `gorm:"type:uuid;index" json:"bundle_id,omitempty"` // 套餐订单项，单价为套餐价分摊到该技能的部分
+	RefundedAt *time.Time `json:"refunded_at,omitempty"` // 全额退款后不再授予下载权限
 
 	Order Order  `gorm:"foreignKey:OrderID" json:"order,omitempty"`
 	Skill *Skill `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
 }
 
+// Subtotal 订单项实付金额（单价×数量减去折扣）
+func (i *OrderItem) Subtotal() Money {
+	quantity := i.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	return i.Price.Mul(int64(quantity)).Sub(i.Discount)
+}
+
+// Total 订单项应付金额：价外税加上税额，价内税即为小计
+func (i *OrderItem) Total() Money {
+	if i.TaxInclusive {
+		return i.Subtotal()
+	}
+	return i.Subtotal().Add(i.Tax)
+}
+
+// Net 订单项不含税金额，用于收入和发布者分成
+func (i *OrderItem) Net() Money {
+	return i.Total().Sub(i.Tax)
+}
+
+// SplitRegion 拆分下单地区为国家代码和可选的省/州代码（ISO 3166-2，如 US-CA）
+func SplitRegion(region string) (country, subdivision string) {
+	country, subdivision, _ = strings.Cut(strings.ToUpper(strings.TrimSpace(region)), "-")
+	return country, subdivision
+}
+
 type Transaction struct {
 	ID             uuid.UUID        `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	OrderID        uuid.UUID        `gorm:"type:uuid;not null;index" json:"order_id"`
-	PaymentChannel string           `gorm:"type:varchar(50)" json:"payment_channel"`
-	TransactionID  string           `gorm:"type:varchar(255)" json:"transaction_id"`
-	Amount         float64          `gorm:"type:decimal(10,2)" json:"amount"`
+	PaymentChannel string           `gorm:"type:varchar(50);uniqueIndex:idx_transaction_channel_trade_no,where:transaction_id <> ''" json:"payment_channel"`
+	TransactionID  string           `gorm:"type:varchar(255);uniqueIndex:idx_transaction_channel_trade_no" json:"transaction_id"` // 同一渠道的网关交易号唯一，防止重放回调重复入账
+	Amount         Money            `gorm:"embedded" json:"amount"`
 	Status         TransactionStatus `gorm:"type:varchar(50);default:'pending'" json:"status"`
 	RawResponse    string           `gorm:"type:jsonb" json:"raw_response,omitempty"`
 	CreatedAt      time.Time        `gorm:"autoCreateTime" json:"created_at"`
@@ -62,3 +119,15 @@ type Transaction struct {
 
 	Order Order `gorm:"foreignKey:OrderID" json:"order,omitempty"`
 }
+
+// OrderEvent 订单状态变更审计记录
+type OrderEvent struct {
+	ID         uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrderID    uuid.UUID   `gorm:"type:uuid;not null;index" json:"order_id"`
+	FromStatus OrderStatus `gorm:"type:varchar(50)" json:"from_status"`
+	ToStatus   OrderStatus `gorm:"type:varchar(50)" json:"to_status"`
+	Source     string      `gorm:"type:varchar(100)" json:"source"`              // 变更来源，如 callback:alipay、reconcile、refund
+	Reference  string      `gorm:"type:varchar(255)" json:"reference,omitempty"` // 关联的网关交易号、退款单号等
+	Note       string      `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt  time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
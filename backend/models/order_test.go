@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderItemTotals(t *testing.T) {
+	exclusive := OrderItem{
+		Price:    NewMoney(1000, "USD"),
+		Quantity: 2,
+		Discount: NewMoney(200, "USD"),
+		Tax:      NewMoney(130, "USD"),
+	}
+	assert.Equal(t, NewMoney(1800, "USD"), exclusive.Subtotal())
+	assert.Equal(t, NewMoney(1930, "USD"), exclusive.Total())
+	assert.Equal(t, NewMoney(1800, "USD"), exclusive.Net())
+
+	inclusive := OrderItem{Price: NewMoney(1190, "EUR"), Tax: NewMoney(190, "EUR"), TaxInclusive: true}
+	assert.Equal(t, NewMoney(1190, "EUR"), inclusive.Total())
+	assert.Equal(t, NewMoney(1000, "EUR"), inclusive.Net())
+}
+
+func TestSplitRegion(t *testing.T) {
+	tests := []struct {
+		region, country, subdivision string
+	}{
+		{"US-CA", "US", "CA"},
+		{" ca-qc ", "CA", "QC"},
+		{"DE", "DE", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		country, subdivision := SplitRegion(tt.region)
+		assert.Equal(t, tt.country, country, tt.region)
+		assert.Equal(t, tt.subdivision, subdivision, tt.region)
+	}
+}
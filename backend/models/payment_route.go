@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PaymentRoute 支付路由规则：买家未指定支付方式时，按优先级选择第一条命中且可用的规则
+type PaymentRoute struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Priority    int       `gorm:"default:0;index" json:"priority"`                           // 数值越小越优先
+	Currency    string    `gorm:"type:varchar(3)" json:"currency,omitempty"`                 // 为空匹配所有币种
+	Region      string    `gorm:"type:varchar(10)" json:"region,omitempty"`                  // 为空匹配所有地区
+	MinAmount   int64     `gorm:"column:min_amount_minor;default:0" json:"min_amount_minor"` // 订单金额下限（含，最小单位），0为不限
+	MaxAmount   int64     `gorm:"column:max_amount_minor;default:0" json:"max_amount_minor"` // 订单金额上限（含，最小单位），0为不限
+	PaymentType string    `gorm:"type:varchar(50);not null" json:"payment_type"`
+	IsActive    bool      `gorm:"default:true" json:"is_active"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Matches 规则是否适用于该订单（金额条件仅在规则指定币种时生效）
+func (r *PaymentRoute) Matches(order *Order) bool {
+	if r.Currency != "" && !strings.EqualFold(r.Currency, order.Total.Currency) {
+		return false
+	}
+	// 按国家配置的规则同样适用于该国各省/州的订单
+	if country, _ := SplitRegion(order.Region); r.Region != "" && !strings.EqualFold(r.Region, order.Region) && !strings.EqualFold(r.Region, country) {
+		return false
+	}
+	if r.Currency == "" {
+		return true
+	}
+	if r.MinAmount > 0 && order.Total.Amount < r.MinAmount {
+		return false
+	}
+	if r.MaxAmount > 0 && order.Total.Amount > r.MaxAmount {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SkillPrice 技能在某个地区的定价（覆盖技能的基础价格）
+type SkillPrice struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	SkillID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_skill_price_region" json:"skill_id"`
+	Region    string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_skill_price_region" json:"region"` // ISO 3166-1国家/地区代码，如CN、US
+	Price     Money     `gorm:"embedded" json:"price"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ExchangeRate 汇率：1单位BaseCurrency兑换Rate单位QuoteCurrency
+type ExchangeRate struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	BaseCurrency  string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair" json:"base_currency"`
+	QuoteCurrency string    `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair" json:"quote_currency"`
+	Rate          float64   `gorm:"type:decimal(18,8);not null" json:"rate"`
+	Source        string    `gorm:"type:varchar(50)" json:"source"` // admin 或 file
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
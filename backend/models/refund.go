@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending RefundStatus = "pending"
+	RefundStatusSuccess RefundStatus = "success"
+	RefundStatusFailed  RefundStatus = "failed"
+)
+
+// Refund 退款记录（全额或部分退款，关联到原支付交易）
+type Refund struct {
+	ID               uuid.UUID    `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	RefundNo         string       `gorm:"type:varchar(64);uniqueIndex;not null" json:"refund_no"` // 商户退款单号
+	OrderID          uuid.UUID    `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderItemID      *uuid.UUID   `gorm:"type:uuid;index" json:"order_item_id,omitempty"` // 为空表示整单退款
+	TransactionID    uuid.UUID    `gorm:"type:uuid;not null;index" json:"transaction_id"`
+	PaymentChannel   string       `gorm:"type:varchar(50)" json:"payment_channel"`
+	ProviderRefundID string       `gorm:"type:varchar(255)" json:"provider_refund_id,omitempty"`
+	Amount           Money        `gorm:"embedded" json:"amount"`
+	Reason           string       `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	Status           RefundStatus `gorm:"type:varchar(50);default:'pending'" json:"status"`
+	OperatorID       *uuid.UUID   `gorm:"type:uuid" json:"operator_id,omitempty"`
+	RawResponse      string       `gorm:"type:text" json:"raw_response,omitempty"`
+	CreatedAt        time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+	CompletedAt      *time.Time   `json:"completed_at,omitempty"`
+
+	Order       Order       `gorm:"foreignKey:OrderID" json:"-"`
+	Transaction Transaction `gorm:"foreignKey:TransactionID" json:"-"`
+}
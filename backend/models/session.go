@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthSession 登录会话：一次登录签发的访问令牌和轮换的刷新令牌同属一个会话，撤销会话即令其全部失效
+type AuthSession struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	UserAgent    string     `gorm:"type:varchar(500)" json:"user_agent,omitempty"`
+	IP           string     `gorm:"type:varchar(64)" json:"ip,omitempty"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"` // 最后一次刷新签发的刷新令牌的过期时间
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt    *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokeReason string     `gorm:"type:varchar(100)" json:"revoke_reason,omitempty"` // logout、password_changed、token_reuse 等
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RefreshToken 刷新令牌，只保存哈希。每个令牌只能使用一次，使用后换发新令牌；已使用的令牌再次出现视为泄露，撤销整个会话
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	SessionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"` // SHA-256十六进制
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -11,6 +11,8 @@ type PriceType string
 const (
 	PriceTypeFree PriceType = "free"
 	PriceTypePaid PriceType = "paid"
+	// PriceTypeSubscription 按订阅计费，价格见 SubscriptionPlan
+	PriceTypeSubscription PriceType = "subscription"
 )
 
 type SkillCategory struct {
@@ -38,8 +40,9 @@ type Skill struct {
 	Description    string     `gorm:"type:text" json:"description"`
 	GitHubURL      string     `gorm:"type:varchar(500);column:git_hub_url" json:"github_url"`
 	CategoryID     *uuid.UUID `gorm:"type:uuid;index" json:"category_id,omitempty"`
+	PublisherID    *uuid.UUID `gorm:"type:uuid;index" json:"publisher_id,omitempty"` // 发布者用户
 	PriceType      PriceType  `gorm:"type:varchar(20);default:'free'" json:"price_type"`
-	Price          float64    `gorm:"type:decimal(10,2);default:0.00" json:"price"`
+	Price          Money      `gorm:"embedded;embeddedPrefix:price_" json:"price"`
 	DownloadsCount int        `gorm:"default:0" json:"downloads_count"`
 	PurchasesCount int        `gorm:"default:0" json:"purchases_count"`
 	Rating         float64    `gorm:"type:decimal(3,2);default:0.00" json:"rating"`
@@ -53,10 +56,12 @@ type Skill struct {
 
 	// Relations
 	Category     *SkillCategory     `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Publisher    *User              `gorm:"foreignKey:PublisherID" json:"publisher,omitempty"`
 	Tags         []SkillTag         `gorm:"many2many:skill_tag_relations;" json:"tags,omitempty"`
 	Translations []SkillTranslation `gorm:"foreignKey:SkillID" json:"translations,omitempty"`
 	OrderItems   []OrderItem        `gorm:"foreignKey:SkillID" json:"order_items,omitempty"`
 	Analytics    []SkillAnalytics   `gorm:"foreignKey:SkillID" json:"analytics,omitempty"`
+	Prices       []SkillPrice       `gorm:"foreignKey:SkillID" json:"prices,omitempty"`
 }
 
 type SkillTranslation struct {
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BillingInterval string
+
+const (
+	BillingIntervalMonth BillingInterval = "month"
+	BillingIntervalYear  BillingInterval = "year"
+)
+
+// AddTo 计算从start开始一个计费周期后的时间
+func (i BillingInterval) AddTo(start time.Time) time.Time {
+	if i == BillingIntervalYear {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// IsValid 是否为支持的计费周期
+func (i BillingInterval) IsValid() bool {
+	return i == BillingIntervalMonth || i == BillingIntervalYear
+}
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusIncomplete SubscriptionStatus = "incomplete" // 已创建，首期尚未支付
+	SubscriptionStatusActive     SubscriptionStatus = "active"
+	SubscriptionStatusPastDue    SubscriptionStatus = "past_due"  // 续费失败或已过当期，处于宽限期
+	SubscriptionStatusCancelled  SubscriptionStatus = "cancelled" // 已取消，不再续费，当期结束前仍可使用
+	SubscriptionStatusExpired    SubscriptionStatus = "expired"   // 已失效
+)
+
+// SubscriptionPlan 订阅类技能的计费方案，同一技能可按月、按年或以不同币种提供多个方案
+type SubscriptionPlan struct {
+	ID           uuid.UUID       `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	SkillID      uuid.UUID       `gorm:"type:uuid;not null;index" json:"skill_id"`
+	Interval     BillingInterval `gorm:"type:varchar(20);not null" json:"interval"`
+	Price        Money           `gorm:"embedded;embeddedPrefix:price_" json:"price"`
+	PayPalPlanID string          `gorm:"type:varchar(255);column:paypal_plan_id" json:"-"` // PayPal侧的计费方案ID，首次订阅时创建
+	IsActive     bool            `gorm:"default:true" json:"is_active"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Skill *Skill `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
+}
+
+// Subscription 用户对技能的订阅。Stripe/PayPal由网关自动续费；
+// 支付宝/微信支付在当期结束前生成续费订单，由用户支付后顺延
+type Subscription struct {
+	ID                 uuid.UUID          `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID             uuid.UUID          `gorm:"type:uuid;not null;index" json:"user_id"`
+	SkillID            uuid.UUID          `gorm:"type:uuid;not null;index" json:"skill_id"`
+	PlanID             uuid.UUID          `gorm:"type:uuid;not null;index" json:"plan_id"`
+	Status             SubscriptionStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	Provider           string             `gorm:"type:varchar(50)" json:"provider"`
+	ProviderRef        string             `gorm:"type:varchar(255);index" json:"provider_ref,omitempty"` // 网关侧订阅ID
+	AutoRenew          bool               `gorm:"default:false" json:"auto_renew"`                       // 由网关按周期自动扣款
+	CurrentPeriodStart *time.Time         `json:"current_period_start,omitempty"`
+	CurrentPeriodEnd   *time.Time         `gorm:"index" json:"current_period_end,omitempty"`
+	CancelledAt        *time.Time         `json:"cancelled_at,omitempty"`
+	CreatedAt          time.Time          `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time          `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Plan  *SubscriptionPlan `gorm:"foreignKey:PlanID" json:"plan,omitempty"`
+	Skill *Skill            `gorm:"foreignKey:SkillID" json:"skill,omitempty"`
+}
+
+// AccessUntil 订阅授予的使用权截止时间：续费中的订阅享有宽限期，已取消的订阅到当期结束为止
+func (s *Subscription) AccessUntil(grace time.Duration) time.Time {
+	if s.CurrentPeriodEnd == nil {
+		return time.Time{}
+	}
+	switch s.Status {
+	case SubscriptionStatusActive, SubscriptionStatusPastDue:
+		return s.CurrentPeriodEnd.Add(grace)
+	case SubscriptionStatusCancelled:
+		return *s.CurrentPeriodEnd
+	}
+	return time.Time{}
+}
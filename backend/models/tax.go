@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// TaxLine 一项税费：按一条税率规则计算的计税金额和税额
+type TaxLine struct {
+	Name          string `gorm:"type:varchar(50)" json:"name"` // 如 VAT、GST、Sales tax
+	Country       string `gorm:"type:varchar(10)" json:"country"`
+	Region        string `gorm:"type:varchar(10)" json:"region,omitempty"`        // 省/州，为空表示全国性税种
+	RateBps       int    `json:"rate_bps"`                                        // 税率（基点），2000为20%
+	Inclusive     bool   `json:"inclusive"`                                       // 价内税：税额已包含在标价中
+	ReverseCharge bool   `json:"reverse_charge,omitempty"`                        // B2B反向征收：不收取税款，由买方自行申报
+	Taxable       Money  `gorm:"embedded;embeddedPrefix:taxable_" json:"taxable"` // 计税金额（不含税）
+	Amount        Money  `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`   // 税额，反向征收时为零
+}
+
+// OrderTaxLine 订单的税费明细，下单时计算，之后不随税率规则变化
+type OrderTaxLine struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrderID uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	TaxLine `gorm:"embedded"`
+}
+
+// InvoiceTaxLine 发票的税费明细，开具时从订单复制
+type InvoiceTaxLine struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	InvoiceID uuid.UUID `gorm:"type:uuid;not null;index" json:"invoice_id"`
+	TaxLine   `gorm:"embedded"`
+}
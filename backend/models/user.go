@@ -21,6 +21,7 @@ type User struct {
 	PasswordHash string     `gorm:"type:varchar(255)" json:"-"`
 	Role         UserRole   `gorm:"type:varchar(50);default:'user'" json:"role"`
 	IsActive     bool       `gorm:"default:true" json:"is_active"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"` // 为空表示邮箱未验证，不能下单购买
 	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 
@@ -17,7 +17,7 @@ type AnalyticsService interface {
 	// 数据跟踪
 	TrackView(skillID uuid.UUID) error
 	TrackDownload(skillID uuid.UUID, userID uuid.UUID) error
-	TrackPurchase(skillID uuid.UUID, userID uuid.UUID, amount float64) error
+	TrackPurchase(skillID uuid.UUID, userID uuid.UUID, amount models.Money) error
 
 	// 平台统计
 	GetPlatformStats(ctx context.Context) (*PlatformStats, error)
@@ -45,9 +45,9 @@ type PlatformStats struct {
 	PaidSkills      int64   `json:"paid_skills"`
 	FreeSkills      int64   `json:"free_skills"`
 	TotalOrders     int64   `json:"total_orders"`
-	TotalRevenue    float64 `json:"total_revenue"`
+	TotalRevenue    []models.Money `json:"total_revenue"` // 按币种汇总
 	TodayOrders     int64   `json:"today_orders"`
-	TodayRevenue    float64 `json:"today_revenue"`
+	TodayRevenue    []models.Money `json:"today_revenue"`
 	PendingOrders   int64   `json:"pending_orders"`
 	RecentOrders    []Order `json:"recent_orders"`
 }
@@ -60,16 +60,19 @@ type DailyStats struct {
 	TotalViews     int64     `json:"total_views"`
 	TotalDownloads int64     `json:"total_downloads"`
 	TotalPurchases int64     `json:"total_purchases"`
-	TotalRevenue   float64   `json:"total_revenue"`
+	TotalRevenue   []models.Money `json:"total_revenue"`
 }
 
 // RevenueStats 收入统计数据
 type RevenueStats struct {
 	Period       string           `json:"period"`
-	TotalRevenue float64          `json:"total_revenue"`
+	TotalRevenue []models.Money   `json:"total_revenue"` // 按币种汇总，不做汇率换算
+	TotalTax     []models.Money   `json:"total_tax"`     // 代收税款
+	NetRevenue   []models.Money   `json:"net_revenue"`   // 扣除税款后的收入
 	DailyRevenue []DailyRevenue   `json:"daily_revenue"`
 	ByCategory   []CategoryRevenue `json:"by_category"`
 	ByPayment    []PaymentRevenue `json:"by_payment"`
+	ByTax        []TaxRevenue     `json:"by_tax"`
 }
 
 // SkillAnalyticsData 技能分析数据
@@ -79,7 +82,7 @@ type SkillAnalyticsData struct {
 	TotalViews     int64           `json:"total_views"`
 	TotalDownloads int64           `json:"total_downloads"`
 	TotalPurchases int64           `json:"total_purchases"`
-	TotalRevenue   float64         `json:"total_revenue"`
+	TotalRevenue   []models.Money  `json:"total_revenue"`
 	DailyTrends    []SkillDailyTrend `json:"daily_trends"`
 	Rating         float64         `json:"rating"`
 	Rank           int             `json:"rank"`
@@ -93,7 +96,7 @@ type SkillRanking struct {
 	Views          int64     `json:"views"`
 	Downloads      int64     `json:"downloads"`
 	Purchases      int64     `json:"purchases"`
-	Revenue        float64   `json:"revenue"`
+	Revenue        models.Money `json:"revenue"`
 	GrowthRate     float64   `json:"growth_rate"`
 }
 
@@ -103,7 +106,7 @@ type CategoryStats struct {
 	CategoryName string    `json:"category_name"`
 	SkillCount   int64     `json:"skill_count"`
 	TotalViews   int64     `json:"total_views"`
-	TotalRevenue float64   `json:"total_revenue"`
+	TotalRevenue []models.Money `json:"total_revenue"`
 	AvgRating    float64   `json:"avg_rating"`
 }
 
@@ -111,7 +114,7 @@ type CategoryStats struct {
 type UserActivity struct {
 	UserID          uuid.UUID `json:"user_id"`
 	TotalPurchases  int64     `json:"total_purchases"`
-	TotalSpent      float64   `json:"total_spent"`
+	TotalSpent      []models.Money `json:"total_spent"`
 	LastActive      time.Time `json:"last_active"`
 	FavoriteCategory string   `json:"favorite_category"`
 	RecentSkills    []string  `json:"recent_skills"`
@@ -121,7 +124,7 @@ type UserActivity struct {
 type UserRanking struct {
 	UserID     uuid.UUID `json:"user_id"`
 	Email      string    `json:"email"`
-	TotalSpent float64   `json:"total_spent"`
+	TotalSpent []models.Money `json:"total_spent"`
 	SkillCount int64     `json:"skill_count"`
 	Rank       int       `json:"rank"`
 }
@@ -140,38 +143,47 @@ type Order struct {
 	ID          string    `json:"id"`
 	OrderNo     string    `json:"order_no"`
 	UserEmail   string    `json:"user_email"`
-	TotalAmount float64   `json:"total_amount"`
+	Total       models.Money `json:"total"`
 	Status      string    `json:"status"`
 	CreatedAt   string    `json:"created_at"`
 }
 
 // DailyRevenue 每日收入
 type DailyRevenue struct {
-	Date   time.Time `json:"date"`
-	Amount float64   `json:"amount"`
+	Date   time.Time    `json:"date"`
+	Amount models.Money `json:"amount"`
 }
 
 // CategoryRevenue 分类收入
 type CategoryRevenue struct {
 	Category string  `json:"category"`
-	Amount   float64 `json:"amount"`
+	Amount   models.Money `json:"amount"`
 	Percentage float64 `json:"percentage"`
 }
 
 // PaymentRevenue 支付渠道收入
 type PaymentRevenue struct {
 	PaymentMethod string  `json:"payment_method"`
-	Amount        float64 `json:"amount"`
+	Amount        models.Money `json:"amount"`
 	Percentage    float64 `json:"percentage"`
 }
 
+// TaxRevenue 按税种和管辖地区汇总的代收税款
+type TaxRevenue struct {
+	Country string       `json:"country"`
+	Region  string       `json:"region,omitempty"`
+	Name    string       `json:"name"`
+	Taxable models.Money `json:"taxable"`
+	Amount  models.Money `json:"amount"`
+}
+
 // SkillDailyTrend 技能每日趋势
 type SkillDailyTrend struct {
 	Date     time.Time `json:"date"`
 	Views    int64     `json:"views"`
 	Downloads int64    `json:"downloads"`
 	Purchases int64    `json:"purchases"`
-	Revenue  float64   `json:"revenue"`
+	Revenue  models.Money `json:"revenue"`
 }
 
 // AnalyticsServiceImpl 分析服务实现
@@ -211,7 +223,7 @@ func (s *AnalyticsServiceImpl) TrackDownload(skillID uuid.UUID, userID uuid.UUID
 }
 
 // TrackPurchase 跟踪技能购买
-func (s *AnalyticsServiceImpl) TrackPurchase(skillID uuid.UUID, userID uuid.UUID, amount float64) error {
+func (s *AnalyticsServiceImpl) TrackPurchase(skillID uuid.UUID, userID uuid.UUID, amount models.Money) error {
 	// 记录购买到数据库
 	return s.db.Exec(`
 		INSERT INTO skill_analytics (id, skill_id, date, views_count, downloads_count, purchases_count, created_at)
@@ -239,13 +251,13 @@ func (s *AnalyticsServiceImpl) GetPlatformStats(ctx context.Context) (*PlatformS
 	s.db.Model(&models.Order{}).Where("status = ?", "pending").Count(&stats.PendingOrders)
 
 	// 收入统计
-	s.db.Model(&models.Order{}).Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TotalRevenue)
+	stats.TotalRevenue = sumByCurrency(s.db.Model(&models.Order{}), "total_amount_minor", "total_currency")
 
 	// 今日统计
 	today := time.Now().Format("2006-01-02")
 	s.db.Model(&models.Order{}).Where("DATE(created_at) = ?", today).Count(&stats.TodayOrders)
-	s.db.Model(&models.Order{}).Where("DATE(created_at) = ?", today).
-		Select("COALESCE(SUM(total_amount), 0)").Scan(&stats.TodayRevenue)
+	stats.TodayRevenue = sumByCurrency(s.db.Model(&models.Order{}).Where("DATE(created_at) = ?", today),
+		"total_amount_minor", "total_currency")
 
 	// 最近订单
 	var recentOrders []models.Order
@@ -261,7 +273,7 @@ func (s *AnalyticsServiceImpl) GetPlatformStats(ctx context.Context) (*PlatformS
 			ID:          order.ID.String(),
 			OrderNo:     order.OrderNo,
 			UserEmail:   order.User.Email,
-			TotalAmount: order.TotalAmount,
+			Total:       order.Total,
 			Status:      string(order.Status),
 			CreatedAt:   order.CreatedAt.Format("2006-01-02 15:04:05"),
 		})
@@ -294,10 +306,9 @@ func (s *AnalyticsServiceImpl) GetDailyStats(ctx context.Context, date time.Time
 	}
 
 	// 当日收入
-	s.db.Model(&models.Order{}).
-		Where("DATE(created_at) = ? AND status = ?", dateStr, "paid").
-		Select("COALESCE(SUM(total_amount), 0)").
-		Scan(&stats.TotalRevenue)
+	stats.TotalRevenue = sumByCurrency(s.db.Model(&models.Order{}).
+		Where("DATE(created_at) = ? AND status = ?", dateStr, "paid"),
+		"total_amount_minor", "total_currency")
 
 	return &stats, nil
 }
@@ -309,10 +320,41 @@ func (s *AnalyticsServiceImpl) GetRevenueStats(ctx context.Context, startDate, e
 	}
 
 	// 总收入
-	s.db.Model(&models.Order{}).
-		Where("created_at BETWEEN ? AND ? AND status = ?", startDate, endDate, "paid").
-		Select("COALESCE(SUM(total_amount), 0)").
-		Scan(&stats.TotalRevenue)
+	paid := func() *gorm.DB {
+		return s.db.Model(&models.Order{}).
+			Where("created_at BETWEEN ? AND ? AND status = ?", startDate, endDate, "paid")
+	}
+	stats.TotalRevenue = sumByCurrency(paid(), "total_amount_minor", "total_currency")
+	stats.TotalTax = sumByCurrency(paid(), "tax_amount_minor", "total_currency")
+	stats.NetRevenue = sumByCurrency(paid(), "total_amount_minor - tax_amount_minor", "total_currency")
+
+	// 按管辖地区汇总税款，便于申报
+	var rows []struct {
+		Country  string
+		Region   string
+		Name     string
+		Currency string
+		Taxable  int64
+		Amount   int64
+	}
+	s.db.Table("order_tax_lines").
+		Select("order_tax_lines.country, order_tax_lines.region, order_tax_lines.name, order_tax_lines.amount_currency AS currency, "+
+			"SUM(order_tax_lines.taxable_amount_minor) AS taxable, SUM(order_tax_lines.amount_amount_minor) AS amount").
+		Joins("JOIN orders ON orders.id = order_tax_lines.order_id").
+		Where("orders.created_at BETWEEN ? AND ? AND orders.status = ?", startDate, endDate, "paid").
+		Group("order_tax_lines.country, order_tax_lines.region, order_tax_lines.name, order_tax_lines.amount_currency").
+		Order("order_tax_lines.country, order_tax_lines.region, order_tax_lines.name").
+		Scan(&rows)
+	stats.ByTax = make([]TaxRevenue, 0, len(rows))
+	for _, row := range rows {
+		stats.ByTax = append(stats.ByTax, TaxRevenue{
+			Country: row.Country,
+			Region:  row.Region,
+			Name:    row.Name,
+			Taxable: models.NewMoney(row.Taxable, row.Currency),
+			Amount:  models.NewMoney(row.Amount, row.Currency),
+		})
+	}
 
 	return stats, nil
 }
@@ -340,13 +382,10 @@ func (s *AnalyticsServiceImpl) GetSkillAnalytics(ctx context.Context, skillID uu
 	}
 
 	// 获取收入（从订单）
-	var totalRevenue float64
-	s.db.Model(&models.OrderItem{}).
+	data.TotalRevenue = sumByCurrency(s.db.Model(&models.OrderItem{}).
 		Joins("JOIN orders ON order_items.order_id = orders.id").
-		Where("order_items.skill_id = ? AND orders.status = ?", skillID, "paid").
-		Select("COALESCE(SUM(order_items.price * order_items.quantity), 0)").
-		Scan(&totalRevenue)
-	data.TotalRevenue = totalRevenue
+		Where("order_items.skill_id = ? AND orders.status = ?", skillID, "paid"),
+		"order_items.price_amount_minor * order_items.quantity", "order_items.price_currency")
 
 	data.Rating = skill.Rating
 
@@ -371,7 +410,7 @@ func (s *AnalyticsServiceImpl) GetTopSkills(ctx context.Context, limit int, peri
 			Views:     int64(skill.DownloadsCount), // 暂时使用下载量作为视图
 			Downloads: int64(skill.DownloadsCount),
 			Purchases: int64(skill.PurchasesCount),
-			Revenue:   skill.Price * float64(skill.PurchasesCount),
+			Revenue:   skill.Price.Mul(int64(skill.PurchasesCount)),
 		})
 	}
 
@@ -412,9 +451,11 @@ func (s *AnalyticsServiceImpl) GetUserActivity(ctx context.Context, userID uuid.
 		Find(&orders)
 
 	activity.TotalPurchases = int64(len(orders))
+	totals := make([]models.Money, 0, len(orders))
 	for _, order := range orders {
-		activity.TotalSpent += order.TotalAmount
+		totals = append(totals, order.Total)
 	}
+	activity.TotalSpent = models.SumByCurrency(totals...)
 
 	// 最后活动时间
 	var lastOrder models.Order
@@ -435,7 +476,7 @@ func (s *AnalyticsServiceImpl) GetUserRankings(ctx context.Context, limit int) (
 	var users []models.User
 	s.db.Model(&models.User{}).
 		Joins("LEFT JOIN orders ON users.id = orders.user_id AND orders.status = 'paid'").
-		Select("users.*, COALESCE(SUM(orders.total_amount), 0) as total_spent").
+		Select("users.*, COALESCE(SUM(orders.total_amount_minor), 0) as total_spent").
 		Group("users.id").
 		Order("total_spent DESC").
 		Limit(limit).
@@ -449,11 +490,9 @@ func (s *AnalyticsServiceImpl) GetUserRankings(ctx context.Context, limit int) (
 			Where("orders.user_id = ? AND orders.status = ?", user.ID, "paid").
 			Count(&skillCount)
 
-		var totalSpent float64
-		s.db.Model(&models.Order{}).
-			Where("user_id = ? AND status = ?", user.ID, "paid").
-			Select("COALESCE(SUM(total_amount), 0)").
-			Scan(&totalSpent)
+		totalSpent := sumByCurrency(s.db.Model(&models.Order{}).
+			Where("user_id = ? AND status = ?", user.ID, "paid"),
+			"total_amount_minor", "total_currency")
 
 		rankings = append(rankings, &UserRanking{
 			UserID:     user.ID,
@@ -496,6 +535,19 @@ func (s *AnalyticsServiceImpl) GenerateReport(ctx context.Context, reportType st
 	return report, nil
 }
 
+// sumByCurrency 按币种汇总金额列（最小单位），不同币种不做汇率换算
+func sumByCurrency(query *gorm.DB, amountColumn, currencyColumn string) []models.Money {
+	var totals []models.Money
+	query.Select(fmt.Sprintf("%s AS currency, COALESCE(SUM(%s), 0) AS amount_minor", currencyColumn, amountColumn)).
+		Group(currencyColumn).
+		Order(currencyColumn).
+		Scan(&totals)
+	if totals == nil {
+		totals = []models.Money{}
+	}
+	return totals
+}
+
 // GetAnalyticsService 获取分析服务（工厂函数）
 func GetAnalyticsService(db *gorm.DB, cfg *config.Config) AnalyticsService {
 	return NewAnalyticsService(db, cfg)
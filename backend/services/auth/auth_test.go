@@ -202,4 +202,25 @@ func TestOAuthConfigFields(t *testing.T) {
 	if googleOAuthConfig.RedirectURL == "" {
 		t.Error("Google RedirectURL should be set (could be empty from config)")
 	}
-}
\ No newline at end of file
+}
+
+func TestPrimaryVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		emails []GitHubEmail
+		want   string
+	}{
+		{"primary verified", []GitHubEmail{{Email: "alt@example.com", Verified: true}, {Email: "me@example.com", Primary: true, Verified: true}}, "me@example.com"},
+		{"primary unverified", []GitHubEmail{{Email: "me@example.com", Primary: true}, {Email: "alt@example.com", Verified: true}}, ""},
+		{"none", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := primaryVerifiedEmail(tt.emails); got != tt.want {
+			t.Errorf("%s: primaryVerifiedEmail = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ResolveOAuthUser(nil, &OAuthUserInfo{Email: " ", EmailVerified: true}); err == nil {
+		t.Error("ResolveOAuthUser accepted an empty email")
+	}
+}
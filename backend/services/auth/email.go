@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"skillhub/config"
+	"skillhub/lib"
+	"skillhub/models"
+	"skillhub/services/mail"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidEmailToken 邮件链接无效、已使用或已过期
+	ErrInvalidEmailToken = errors.New("invalid or expired link")
+	// ErrEmailAlreadyVerified 邮箱已经验证过
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	// ErrEmailTooSoon 距上一封同类邮件不足一分钟
+	ErrEmailTooSoon = errors.New("please wait a minute before requesting another email")
+)
+
+// emailResendInterval 同一用户同类邮件的最短发送间隔
+const emailResendInterval = time.Minute
+
+// SendVerificationEmail 向用户当前邮箱发送验证链接，之前发出的验证链接随之作废
+func SendVerificationEmail(ctx context.Context, db *gorm.DB, user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	ttl := mailConfig().VerificationTTL
+	token, err := issueEmailToken(db, user, models.EmailTokenVerifyEmail, ttl)
+	if err != nil {
+		return err
+	}
+	link := frontendLink("/verify-email", token)
+	return mail.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "验证您的 SkillHub 邮箱 / Verify your SkillHub email",
+		Text: fmt.Sprintf("您好，\n\n请在%s内打开以下链接完成邮箱验证，验证后即可购买技能：\n%s\n\n"+
+			"Please open the link above within %s to verify your email address. You need a verified email to make purchases.\n\n"+
+			"如果您没有注册 SkillHub，请忽略本邮件。 If you did not sign up for SkillHub, you can ignore this email.\n",
+			zhDuration(ttl), link, enDuration(ttl)),
+	})
+}
+
+// SendPasswordResetEmail 向该邮箱的账号发送重置密码链接。邮箱未注册、账号已停用或距上一封不足一分钟时
+// 不发送也不报错，避免调用方据此判断邮箱是否注册
+func SendPasswordResetEmail(ctx context.Context, db *gorm.DB, email string) error {
+	var user models.User
+	if err := db.Where("email = ?", strings.TrimSpace(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !user.IsActive {
+		return nil
+	}
+	ttl := mailConfig().PasswordResetTTL
+	token, err := issueEmailToken(db, &user, models.EmailTokenResetPassword, ttl)
+	if errors.Is(err, ErrEmailTooSoon) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	link := frontendLink("/reset-password", token)
+	return mail.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "重置您的 SkillHub 密码 / Reset your SkillHub password",
+		Text: fmt.Sprintf("您好，\n\n请在%s内打开以下链接设置新密码，链接只能使用一次：\n%s\n\n"+
+			"Open the link above within %s to choose a new password. The link can only be used once.\n\n"+
+			"如果不是您本人操作，请忽略本邮件，您的密码不会改变。 If you did not request this, ignore this email and your password will stay the same.\n",
+			zhDuration(ttl), link, enDuration(ttl)),
+	})
+}
+
+// VerifyEmail 使用验证链接中的令牌将邮箱标记为已验证
+func VerifyEmail(db *gorm.DB, token string) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		row, err := consumeEmailToken(tx, token, models.EmailTokenVerifyEmail)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, "id = ?", row.UserID).Error; err != nil {
+			return err
+		}
+		// 发送后邮箱已变更，旧邮箱的链接不能验证新邮箱
+		if !strings.EqualFold(user.Email, row.Email) {
+			return ErrInvalidEmailToken
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Update("email_verified_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ResetPassword 使用重置链接中的令牌设置新密码，并撤销该用户的全部登录会话。
+// 能收到邮件说明用户拥有该邮箱，邮箱同时标记为已验证
+func ResetPassword(db *gorm.DB, token, newPassword string) (*models.User, error) {
+	hash, err := lib.HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		row, err := consumeEmailToken(tx, token, models.EmailTokenResetPassword)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, "id = ?", row.UserID).Error; err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, row.Email) {
+			return ErrInvalidEmailToken
+		}
+		if !user.IsActive {
+			return ErrUserInactive
+		}
+		now := time.Now()
+		updates := map[string]interface{}{"password_hash": hash}
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = now
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := InvalidateEmailTokens(tx, user.ID, models.EmailTokenResetPassword); err != nil {
+			return err
+		}
+		_, err = RevokeUserSessions(tx, user.ID, RevokePasswordChanged)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = ""
+	return &user, nil
+}
+
+// InvalidateEmailTokens 作废用户某类尚未使用的邮件令牌（如修改密码后作废未使用的重置链接）
+func InvalidateEmailTokens(db *gorm.DB, userID uuid.UUID, purpose string) error {
+	return db.Model(&models.EmailToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error
+}
+
+// PurgeExpiredEmailTokens 删除before之前已过期的邮件令牌，返回删除的条数
+func PurgeExpiredEmailTokens(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("expires_at < ?", before).Delete(&models.EmailToken{})
+	return result.RowsAffected, result.Error
+}
+
+// issueEmailToken 作废之前未使用的同类令牌并签发新令牌，距上次签发不足emailResendInterval时返回ErrEmailTooSoon
+func issueEmailToken(db *gorm.DB, user *models.User, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	row := &models.EmailToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 锁住用户行，避免并发请求绕过发送间隔
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, "id = ?", user.ID).Error; err != nil {
+			return err
+		}
+		var recent int64
+		if err := tx.Model(&models.EmailToken{}).
+			Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, purpose, now.Add(-emailResendInterval)).
+			Count(&recent).Error; err != nil {
+			return err
+		}
+		if recent > 0 {
+			return ErrEmailTooSoon
+		}
+		if err := InvalidateEmailTokens(tx, user.ID, purpose); err != nil {
+			return err
+		}
+		return tx.Create(row).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return signEmailToken(row.ID, purpose, row.ExpiresAt), nil
+}
+
+// consumeEmailToken 校验令牌签名和有效期，并将对应记录标记为已使用
+func consumeEmailToken(tx *gorm.DB, token, purpose string) (*models.EmailToken, error) {
+	id, err := parseEmailToken(token, purpose, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var row models.EmailToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidEmailToken
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if row.Purpose != purpose || row.UsedAt != nil || !row.ExpiresAt.After(now) {
+		return nil, ErrInvalidEmailToken
+	}
+	if err := tx.Model(&row).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// signEmailToken 令牌格式为 base64url(记录ID 16字节 + 过期时间戳 8字节) + "." + base64url(HMAC-SHA256)，
+// 签名覆盖用途，验证邮箱的令牌不能用于重置密码
+func signEmailToken(id uuid.UUID, purpose string, expiresAt time.Time) string {
+	payload := make([]byte, 24)
+	copy(payload, id[:])
+	binary.BigEndian.PutUint64(payload[16:], uint64(expiresAt.Unix()))
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(emailTokenMAC(purpose, encoded))
+}
+
+// parseEmailToken 校验签名和过期时间，返回令牌对应的记录ID
+func parseEmailToken(token, purpose string, now time.Time) (uuid.UUID, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, ErrInvalidEmailToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, emailTokenMAC(purpose, encoded)) {
+		return uuid.Nil, ErrInvalidEmailToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) != 24 {
+		return uuid.Nil, ErrInvalidEmailToken
+	}
+	if int64(binary.BigEndian.Uint64(payload[16:])) <= now.Unix() {
+		return uuid.Nil, ErrInvalidEmailToken
+	}
+	id, err := uuid.FromBytes(payload[:16])
+	if err != nil {
+		return uuid.Nil, ErrInvalidEmailToken
+	}
+	return id, nil
+}
+
+// emailTokenMAC 用JWT密钥计算令牌签名
+func emailTokenMAC(purpose, payload string) []byte {
+	h := hmac.New(sha256.New, []byte(config.AppConfig.JWT.Secret))
+	h.Write([]byte("email-token:" + purpose + ":" + payload))
+	return h.Sum(nil)
+}
+
+// frontendLink 邮件中指向前端页面的链接
+func frontendLink(path, token string) string {
+	return strings.TrimRight(mailConfig().FrontendURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// zhDuration 邮件中的有效期，整小时显示为小时，否则显示为分钟
+func zhDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d小时", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d分钟", int(d.Minutes()))
+}
+
+// enDuration zhDuration的英文写法
+func enDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d hours", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d minutes", int(d.Minutes()))
+}
+
+// mailConfig 邮件配置，未配置的有效期使用默认值（验证48小时、重置1小时）
+func mailConfig() config.MailConfig {
+	var cfg config.MailConfig
+	if config.AppConfig != nil {
+		cfg = config.AppConfig.Mail
+	}
+	if cfg.VerificationTTL <= 0 {
+		cfg.VerificationTTL = 48 * time.Hour
+	}
+	if cfg.PasswordResetTTL <= 0 {
+		cfg.PasswordResetTTL = time.Hour
+	}
+	if cfg.FrontendURL == "" {
+		cfg.FrontendURL = "http://localhost:3000"
+	}
+	return cfg
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func TestEmailTokenSignature(t *testing.T) {
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test-secret"}}
+	now := time.Now()
+	id := uuid.New()
+
+	token := signEmailToken(id, models.EmailTokenVerifyEmail, now.Add(time.Hour))
+	got, err := parseEmailToken(token, models.EmailTokenVerifyEmail, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != id {
+		t.Errorf("parseEmailToken = %s, want %s", got, id)
+	}
+
+	// 把另一个令牌的内容接上本令牌的签名
+	other := signEmailToken(uuid.New(), models.EmailTokenVerifyEmail, now.Add(time.Hour))
+	tampered := strings.SplitN(other, ".", 2)[0] + "." + strings.SplitN(token, ".", 2)[1]
+
+	invalid := map[string]string{
+		"wrong purpose": token,
+		"tampered":      tampered,
+		"no signature":  strings.SplitN(token, ".", 2)[0],
+		"garbage":       "not-a-token",
+	}
+	for name, tok := range invalid {
+		purpose := models.EmailTokenVerifyEmail
+		if name == "wrong purpose" {
+			purpose = models.EmailTokenResetPassword
+		}
+		if _, err := parseEmailToken(tok, purpose, now); !errors.Is(err, ErrInvalidEmailToken) {
+			t.Errorf("%s: error = %v, want ErrInvalidEmailToken", name, err)
+		}
+	}
+
+	expired := signEmailToken(id, models.EmailTokenResetPassword, now.Add(-time.Second))
+	if _, err := parseEmailToken(expired, models.EmailTokenResetPassword, now); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("expired token error = %v, want ErrInvalidEmailToken", err)
+	}
+
+	config.AppConfig.JWT.Secret = "rotated-secret"
+	if _, err := parseEmailToken(token, models.EmailTokenVerifyEmail, now); !errors.Is(err, ErrInvalidEmailToken) {
+		t.Errorf("token signed with another secret error = %v, want ErrInvalidEmailToken", err)
+	}
+}
+
+func TestFrontendLink(t *testing.T) {
+	config.AppConfig = &config.Config{Mail: config.MailConfig{FrontendURL: "https://skillhub.example.com/"}}
+	if got := frontendLink("/reset-password", "a.b"); got != "https://skillhub.example.com/reset-password?token=a.b" {
+		t.Errorf("frontendLink = %q", got)
+	}
+	if zhDuration(48*time.Hour) != "48小时" || enDuration(30*time.Minute) != "30 minutes" {
+		t.Errorf("unexpected durations %q %q", zhDuration(48*time.Hour), enDuration(30*time.Minute))
+	}
+}
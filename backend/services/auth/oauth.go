@@ -3,21 +3,26 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 	"skillhub/config"
-	"skillhub/lib"
 	"skillhub/models"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 	"golang.org/x/oauth2/google"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrOAuthEmailUnverified 第三方账号的邮箱未经提供商验证，而该邮箱已有账号，不能据此登录该账号
+var ErrOAuthEmailUnverified = errors.New("the email of this account is not verified by the provider, please sign in with your password")
+
 var (
 	githubOAuthConfig *oauth2.Config
 	googleOAuthConfig *oauth2.Config
@@ -48,8 +53,11 @@ func InitOAuth() {
 
 // OAuthCallbackResponse OAuth回调响应
 type OAuthCallbackResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token            string      `json:"token"`
+	ExpiresAt        time.Time   `json:"expires_at"`
+	RefreshToken     string      `json:"refresh_token"`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at"`
+	User             models.User `json:"user"`
 }
 
 // GitHubUserInfo GitHub用户信息
@@ -102,221 +110,46 @@ type FeishuUserInfo struct {
 	EnterpriseEmail string `json:"enterprise_email"` // 企业邮箱
 }
 
-// OAuthLogin OAuth登录
-// @Summary OAuth登录
-// @Description 使用OAuth第三方登录
-// @Tags auth
-// @Param provider path string true "OAuth provider (github, google)"
-// @Router /auth/oauth/{provider} [get]
-func OAuthLogin(c *gin.Context) {
-	provider := c.Param("provider")
-
-	var url string
-	var state = uuid.New().String()
-
-	switch provider {
-	case "github":
-		url = githubOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
-	case "google":
-		url = googleOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline)
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported OAuth provider"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"auth_url": url})
-}
-
-// GitHubCallback GitHub OAuth回调
-// @Summary GitHub OAuth回调
-// @Description GitHub OAuth登录回调
-// @Tags auth
-// @Param code query string true "Authorization code"
-// @Router /auth/callback/github [get]
-func GitHubCallback(c *gin.Context) {
-	code := c.Query("code")
-	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
-		return
-	}
-
-	// 获取token
-	token, err := githubOAuthConfig.Exchange(context.Background(), code)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to exchange token"})
-		return
-	}
-
-	// 获取用户信息
-	client := githubOAuthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://api.github.com/user")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get user info"})
-		return
-	}
-	defer resp.Body.Close()
-
-	var githubUser GitHubUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&githubUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode user info"})
-		return
-	}
-
-	// 处理用户
-	user, err := handleOAuthUser("github", fmt.Sprintf("%d", githubUser.ID), githubUser.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
-	}
-
-	// 生成JWT token
-	jwtToken, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
-
-	c.JSON(http.StatusOK, OAuthCallbackResponse{
-		Token: jwtToken,
-		User:  *user,
-	})
-}
-
-// GoogleCallback Google OAuth回调
-// @Summary Google OAuth回调
-// @Description Google OAuth登录回调
-// @Tags auth
-// @Param code query string true "Authorization code"
-// @Router /auth/callback/google [get]
-func GoogleCallback(c *gin.Context) {
-	code := c.Query("code")
-	if code == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing authorization code"})
-		return
-	}
-
-	// 获取token
-	token, err := googleOAuthConfig.Exchange(context.Background(), code)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to exchange token"})
-		return
-	}
-
-	// 获取用户信息
-	client := googleOAuthConfig.Client(context.Background(), token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get user info"})
-		return
-	}
-	defer resp.Body.Close()
-
-	var googleUser GoogleUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to decode user info"})
-		return
-	}
-
-	// 处理用户
-	user, err := handleOAuthUser("google", googleUser.ID, googleUser.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-		return
-	}
-
-	// 生成JWT token
-	jwtToken, err := lib.GenerateToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+// GetGitHubAuthURL 获取GitHub OAuth授权URL，verifier非空时附带PKCE code_challenge
+func GetGitHubAuthURL(state, verifier string) (string, error) {
+	if githubOAuthConfig == nil {
+		return "", fmt.Errorf("GitHub OAuth not configured")
 	}
-
-	c.JSON(http.StatusOK, OAuthCallbackResponse{
-		Token: jwtToken,
-		User:  *user,
-	})
+	return githubOAuthConfig.AuthCodeURL(state, authCodeOptions(verifier)...), nil
 }
 
-// handleOAuthUser 处理OAuth用户
-func handleOAuthUser(provider, providerUserID, email string) (*models.User, error) {
-	// 查找是否已有OAuth绑定
-	var oauthProvider models.OAuthProvider
-	err := models.DB.Where("provider = ? AND provider_user_id = ?", provider, providerUserID).First(&oauthProvider).Error
-
-	if err == nil {
-		// 已存在，返回用户
-		var user models.User
-		if err := models.DB.First(&user, "id = ?", oauthProvider.UserID).Error; err != nil {
-			return nil, err
-		}
-		user.PasswordHash = ""
-		return &user, nil
-	}
-
-	// 不存在，创建新用户或绑定
-	var user models.User
-	err = models.DB.Where("email = ?", email).First(&user).Error
-
-	if err != nil {
-		// 创建新用户
-		user = models.User{
-			ID:       uuid.New(),
-			Email:    email,
-			Role:     models.RoleUser,
-			IsActive: true,
-		}
-		if err := models.DB.Create(&user).Error; err != nil {
-			return nil, err
-		}
-
-		// 创建用户档案
-		profile := models.UserProfile{
-			ID:     uuid.New(),
-			UserID: user.ID,
-		}
-		models.DB.Create(&profile)
-	}
-
-	// 创建OAuth绑定
-	oauthProvider = models.OAuthProvider{
-		ID:             uuid.New(),
-		UserID:         user.ID,
-		Provider:       provider,
-		ProviderUserID:  providerUserID,
-		AccessToken:    "", // 实际应用中应该保存token
+// GetGoogleAuthURL 获取Google OAuth授权URL，verifier非空时附带PKCE code_challenge
+func GetGoogleAuthURL(state, verifier string) (string, error) {
+	if googleOAuthConfig == nil {
+		return "", fmt.Errorf("Google OAuth not configured")
 	}
-	models.DB.Create(&oauthProvider)
-
-	user.PasswordHash = ""
-	return &user, nil
+	return googleOAuthConfig.AuthCodeURL(state, authCodeOptions(verifier)...), nil
 }
 
-// GetGitHubAuthURL 获取GitHub OAuth授权URL
-func GetGitHubAuthURL() (string, error) {
-	if githubOAuthConfig == nil {
-		return "", fmt.Errorf("GitHub OAuth not configured")
+// authCodeOptions 授权URL参数
+func authCodeOptions(verifier string) []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	if verifier != "" {
+		opts = append(opts, oauth2.S256ChallengeOption(verifier))
 	}
-	state := uuid.New().String()
-	return githubOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline), nil
+	return opts
 }
 
-// GetGoogleAuthURL 获取Google OAuth授权URL
-func GetGoogleAuthURL() (string, error) {
-	if googleOAuthConfig == nil {
-		return "", fmt.Errorf("Google OAuth not configured")
+// exchangeOptions 授权码换取令牌的参数
+func exchangeOptions(verifier string) []oauth2.AuthCodeOption {
+	if verifier == "" {
+		return nil
 	}
-	state := uuid.New().String()
-	return googleOAuthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline), nil
+	return []oauth2.AuthCodeOption{oauth2.VerifierOption(verifier)}
 }
 
 // GetWeChatAuthURL 获取微信OAuth授权URL
-func GetWeChatAuthURL() (string, error) {
+func GetWeChatAuthURL(state string) (string, error) {
 	cfg := config.AppConfig.OAuth.WeChat
 	if cfg.AppID == "" {
 		return "", fmt.Errorf("WeChat OAuth not configured")
 	}
 	// 微信OAuth授权URL格式
-	state := uuid.New().String()
 	url := fmt.Sprintf("https://open.weixin.qq.com/connect/qrconnect?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect",
 		cfg.AppID,
 		cfg.Redirect,
@@ -326,13 +159,12 @@ func GetWeChatAuthURL() (string, error) {
 }
 
 // GetFeishuAuthURL 获取飞书OAuth授权URL
-func GetFeishuAuthURL() (string, error) {
+func GetFeishuAuthURL(state string) (string, error) {
 	cfg := config.AppConfig.OAuth.Feishu
 	if cfg.AppID == "" {
 		return "", fmt.Errorf("Feishu OAuth not configured")
 	}
 	// 飞书OAuth授权URL格式
-	state := uuid.New().String()
 	// 请求用户基本信息和邮箱权限
 	scope := "contact:user.base:readonly contact:user.email:readonly"
 	url := fmt.Sprintf("https://open.feishu.cn/open-apis/authen/v1/authorize?app_id=%s&redirect_uri=%s&scope=%s&state=%s",
@@ -345,13 +177,12 @@ func GetFeishuAuthURL() (string, error) {
 }
 
 // GetXiaohongshuAuthURL 获取小红书OAuth授权URL
-func GetXiaohongshuAuthURL() (string, error) {
+func GetXiaohongshuAuthURL(state string) (string, error) {
 	cfg := config.AppConfig.OAuth.Xiaohongshu
 	if cfg.AppID == "" {
 		return "", fmt.Errorf("Xiaohongshu OAuth not configured")
 	}
 	// 小红书OAuth授权URL格式（示例）
-	state := uuid.New().String()
 	url := fmt.Sprintf("https://edith.xiaohongshu.com/api/sns/web/v2/login/authorize?appid=%s&redirect_uri=%s&response_type=code&scope=user.base.info&state=%s",
 		cfg.AppID,
 		cfg.Redirect,
@@ -365,12 +196,23 @@ type OAuthUserInfo struct {
 	Email    string `json:"email"`
 	Name     string `json:"name"`
 	Username string `json:"username"`
+	// EmailVerified 提供商确认用户拥有该邮箱
+	EmailVerified bool `json:"email_verified"`
+	// Placeholder 邮箱由提供商的用户ID生成（提供商未返回已验证邮箱），只用于识别同一第三方账号，不能收信
+	Placeholder bool `json:"placeholder"`
+}
+
+// GitHubEmail GitHub账号下的邮箱（/user/emails）
+type GitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
 }
 
-// HandleGitHubCallback 处理GitHub OAuth回调
-func HandleGitHubCallback(code, state string) (*OAuthUserInfo, error) {
+// HandleGitHubCallback 处理GitHub OAuth回调，调用前须先用VerifyState校验state，verifier为其返回的PKCE code_verifier
+func HandleGitHubCallback(code, verifier string) (*OAuthUserInfo, error) {
 	// 获取token
-	token, err := githubOAuthConfig.Exchange(context.Background(), code)
+	token, err := githubOAuthConfig.Exchange(context.Background(), code, exchangeOptions(verifier)...)
 	if err != nil {
 		return nil, err
 	}
@@ -387,18 +229,51 @@ func HandleGitHubCallback(code, state string) (*OAuthUserInfo, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&githubUser); err != nil {
 		return nil, err
 	}
+	if githubUser.ID == 0 {
+		return nil, fmt.Errorf("invalid GitHub user response")
+	}
 
-	return &OAuthUserInfo{
-		Email:    githubUser.Email,
+	// 资料中的邮箱是用户自填的公开邮箱，未经验证；从/user/emails取已验证的主邮箱
+	emailsResp, err := client.Get("https://api.github.com/user/emails")
+	if err != nil {
+		return nil, err
+	}
+	defer emailsResp.Body.Close()
+	var emails []GitHubEmail
+	if emailsResp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(emailsResp.Body).Decode(&emails); err != nil {
+			return nil, err
+		}
+	}
+
+	info := &OAuthUserInfo{
 		Name:     githubUser.Name,
 		Username: githubUser.Login,
-	}, nil
+	}
+	if email := primaryVerifiedEmail(emails); email != "" {
+		info.Email = email
+		info.EmailVerified = true
+	} else {
+		info.Email = fmt.Sprintf("github_%d@placeholder.com", githubUser.ID)
+		info.Placeholder = true
+	}
+	return info, nil
+}
+
+// primaryVerifiedEmail 返回已验证的主邮箱，没有时返回空
+func primaryVerifiedEmail(emails []GitHubEmail) string {
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email
+		}
+	}
+	return ""
 }
 
-// HandleGoogleCallback 处理Google OAuth回调
-func HandleGoogleCallback(code, state string) (*OAuthUserInfo, error) {
+// HandleGoogleCallback 处理Google OAuth回调，调用前须先用VerifyState校验state，verifier为其返回的PKCE code_verifier
+func HandleGoogleCallback(code, verifier string) (*OAuthUserInfo, error) {
 	// 获取token
-	token, err := googleOAuthConfig.Exchange(context.Background(), code)
+	token, err := googleOAuthConfig.Exchange(context.Background(), code, exchangeOptions(verifier)...)
 	if err != nil {
 		return nil, err
 	}
@@ -415,15 +290,19 @@ func HandleGoogleCallback(code, state string) (*OAuthUserInfo, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
 		return nil, err
 	}
+	if googleUser.Email == "" {
+		return nil, fmt.Errorf("Google did not return an email address")
+	}
 
 	return &OAuthUserInfo{
-		Email: googleUser.Email,
-		Name:  googleUser.Name,
+		Email:         googleUser.Email,
+		Name:          googleUser.Name,
+		EmailVerified: googleUser.VerifiedEmail,
 	}, nil
 }
 
-// HandleWeChatCallback 处理微信OAuth回调
-func HandleWeChatCallback(code, state string) (*OAuthUserInfo, error) {
+// HandleWeChatCallback 处理微信OAuth回调，调用前须先用VerifyState校验state
+func HandleWeChatCallback(code string) (*OAuthUserInfo, error) {
 	cfg := config.AppConfig.OAuth.WeChat
 	if cfg.AppID == "" || cfg.AppSecret == "" {
 		return nil, fmt.Errorf("微信OAuth配置不完整")
@@ -513,14 +392,15 @@ func HandleWeChatCallback(code, state string) (*OAuthUserInfo, error) {
 	}
 
 	return &OAuthUserInfo{
-		Email:    email,
-		Name:     wechatUser.Nickname,
-		Username: wechatUser.Nickname,
+		Email:       email,
+		Name:        wechatUser.Nickname,
+		Username:    wechatUser.Nickname,
+		Placeholder: true,
 	}, nil
 }
 
-// HandleFeishuCallback 处理飞书OAuth回调
-func HandleFeishuCallback(code, state string) (*OAuthUserInfo, error) {
+// HandleFeishuCallback 处理飞书OAuth回调，调用前须先用VerifyState校验state
+func HandleFeishuCallback(code string) (*OAuthUserInfo, error) {
 	cfg := config.AppConfig.OAuth.Feishu
 	if cfg.AppID == "" || cfg.AppSecret == "" {
 		return nil, fmt.Errorf("飞书OAuth配置不完整")
@@ -620,9 +500,10 @@ func HandleFeishuCallback(code, state string) (*OAuthUserInfo, error) {
 	feishuUser := userInfoResp.Data
 
 	// 3. 返回用户信息
-	// 优先使用邮箱，如果没有邮箱则使用open_id生成唯一邮箱
+	// 优先使用已验证的邮箱，否则使用union_id/open_id生成唯一邮箱
 	email := feishuUser.Email
-	if email == "" {
+	verified := email != "" && feishuUser.EmailVerified
+	if !verified {
 		if feishuUser.UnionID != "" {
 			email = fmt.Sprintf("feishu_union_%s@placeholder.com", feishuUser.UnionID)
 		} else if feishuUser.OpenID != "" {
@@ -638,17 +519,86 @@ func HandleFeishuCallback(code, state string) (*OAuthUserInfo, error) {
 	}
 
 	return &OAuthUserInfo{
-		Email:    email,
-		Name:     name,
-		Username: name,
+		Email:         email,
+		Name:          name,
+		Username:      name,
+		EmailVerified: verified,
+		Placeholder:   !verified,
 	}, nil
 }
 
-// HandleXiaohongshuCallback 处理小红书OAuth回调
-func HandleXiaohongshuCallback(code, state string) (*OAuthUserInfo, error) {
-	// 实现小红书OAuth回调处理
-	return &OAuthUserInfo{
-		Email: fmt.Sprintf("xhs_user_%s@placeholder.com", state),
-		Name:  "Xiaohongshu User",
-	}, nil
+// HandleXiaohongshuCallback 处理小红书OAuth回调。
+// 小红书的授权码换取用户信息尚未接入，拿不到可信的用户标识，一律拒绝登录，避免多个用户被合并到同一账号
+func HandleXiaohongshuCallback(code string) (*OAuthUserInfo, error) {
+	return nil, ErrProviderUnavailable
+}
+
+// ResolveOAuthUser 按第三方登录返回的身份查找或创建用户：
+// 提供商确认邮箱已验证时按邮箱关联已有账号，并将邮箱标记为已验证；已有账号的邮箱此前未验证时，
+// 注册时设置的密码无法确认属于邮箱主人，一并清除并撤销该账号的登录会话。
+// 占位邮箱由提供商的用户ID生成，可关联同一第三方账号，但不视为已验证。
+// 未经验证的邮箱不能关联已有账号，返回ErrOAuthEmailUnverified
+func ResolveOAuthUser(db *gorm.DB, info *OAuthUserInfo) (*models.User, error) {
+	email := strings.TrimSpace(info.Email)
+	if email == "" {
+		return nil, errors.New("oauth provider returned no email")
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = models.User{
+				ID:           uuid.New(),
+				Email:        email,
+				Username:     info.Username,
+				Name:         info.Name,
+				PasswordHash: "", // OAuth用户没有密码
+				Role:         models.RoleUser,
+				IsActive:     true,
+			}
+			if info.EmailVerified {
+				now := time.Now()
+				user.EmailVerifiedAt = &now
+			}
+			return tx.Create(&user).Error
+		}
+		if err != nil {
+			return err
+		}
+		if !info.EmailVerified && !info.Placeholder {
+			return ErrOAuthEmailUnverified
+		}
+
+		updates := map[string]interface{}{}
+		if info.EmailVerified && user.EmailVerifiedAt == nil {
+			now := time.Now()
+			updates["email_verified_at"] = now
+			user.EmailVerifiedAt = &now
+			if user.PasswordHash != "" {
+				updates["password_hash"] = ""
+				user.PasswordHash = ""
+				if _, err := RevokeUserSessions(tx, user.ID, RevokeEmailClaimed); err != nil {
+					return err
+				}
+			}
+		}
+		// 已有账号没有名称时使用第三方账号的名称
+		if user.Name == "" && info.Name != "" {
+			updates["name"] = info.Name
+			user.Name = info.Name
+		}
+		if user.Username == "" && info.Username != "" {
+			updates["username"] = info.Username
+			user.Username = info.Username
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"skillhub/config"
+	"skillhub/lib"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌不存在、已过期或所属会话已撤销
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused 已使用过的刷新令牌再次出现，所属会话已被撤销
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, session revoked")
+	// ErrSessionRevoked 访问令牌所属会话已撤销或不存在
+	ErrSessionRevoked = errors.New("session revoked")
+	// ErrUserInactive 账号已停用
+	ErrUserInactive = errors.New("account is deactivated")
+)
+
+// 会话撤销原因
+const (
+	RevokeLogout          = "logout"
+	RevokeLogoutAll       = "logout_all"
+	RevokePasswordChanged = "password_changed"
+	RevokeTokenReuse      = "token_reuse"
+	RevokeUserInactive    = "user_inactive"
+	// RevokeEmailClaimed 邮箱主人通过第三方登录认领了以该邮箱注册但未验证的账号
+	RevokeEmailClaimed = "email_claimed"
+)
+
+// TokenPair 登录或刷新后签发的访问令牌和刷新令牌
+type TokenPair struct {
+	Token            string    `json:"token"` // 访问令牌（Bearer）
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"` // 只能使用一次，刷新后换发新令牌
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Client 发起登录或刷新的客户端信息，记录在会话上
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// IssueSession 为登录成功的用户创建会话并签发令牌，停用的账号返回ErrUserInactive
+func IssueSession(db *gorm.DB, user *models.User, client Client) (*TokenPair, error) {
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+	now := time.Now()
+	session := &models.AuthSession{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		UserAgent: truncate(client.UserAgent, 500),
+		IP:        client.IP,
+		ExpiresAt: now.Add(refreshExpiration()),
+	}
+	var refresh string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		var err error
+		refresh, err = createRefreshToken(tx, session.ID, session.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signPair(user, session, refresh)
+}
+
+// Refresh 用刷新令牌换发新的令牌对，旧刷新令牌随即失效。
+// 已使用过的刷新令牌再次出现说明令牌可能被盗用，撤销整个会话并返回ErrRefreshTokenReused
+func Refresh(db *gorm.DB, refreshToken string, client Client) (*TokenPair, *models.User, error) {
+	var (
+		user    models.User
+		session models.AuthSession
+		refresh string
+		failure error
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var token models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(refreshToken)).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				failure = ErrInvalidRefreshToken
+				return nil
+			}
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, "id = ?", token.SessionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				failure = ErrInvalidRefreshToken
+				return nil
+			}
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case session.RevokedAt != nil:
+			failure = ErrInvalidRefreshToken
+			return nil
+		case token.UsedAt != nil:
+			// 撤销需要提交，因此不以错误回滚事务
+			log.Printf("Refresh token reuse detected for session %s of user %s, revoking", session.ID, session.UserID)
+			failure = ErrRefreshTokenReused
+			return revoke(tx.Where("id = ?", session.ID), RevokeTokenReuse, now)
+		case !token.ExpiresAt.After(now):
+			failure = ErrInvalidRefreshToken
+			return nil
+		}
+
+		if err := tx.First(&user, "id = ?", session.UserID).Error; err != nil {
+			return err
+		}
+		if !user.IsActive {
+			failure = ErrUserInactive
+			return revoke(tx.Where("id = ?", session.ID), RevokeUserInactive, now)
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		session.ExpiresAt = now.Add(refreshExpiration())
+		session.LastUsedAt = &now
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"expires_at":   session.ExpiresAt,
+			"last_used_at": now,
+			"ip":           client.IP,
+			"user_agent":   truncate(client.UserAgent, 500),
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		refresh, err = createRefreshToken(tx, session.ID, session.ExpiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if failure != nil {
+		return nil, nil, failure
+	}
+
+	pair, err := signPair(&user, &session, refresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+// Authenticate 校验访问令牌：签名和有效期有效、所属会话未撤销且账号未停用。返回令牌声明和当前用户（角色以数据库为准）
+func Authenticate(db *gorm.DB, tokenString string) (*lib.Claims, *models.User, error) {
+	claims, err := lib.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.SessionID == uuid.Nil {
+		return nil, nil, ErrSessionRevoked
+	}
+
+	var user models.User
+	err = db.Joins("JOIN auth_sessions ON auth_sessions.user_id = users.id").
+		Where("users.id = ? AND auth_sessions.id = ? AND auth_sessions.revoked_at IS NULL", claims.UserID, claims.SessionID).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, ErrUserInactive
+	}
+	return claims, &user, nil
+}
+
+// SessionForRefreshToken 查找刷新令牌所属的会话ID，用于访问令牌已过期时退出登录
+func SessionForRefreshToken(db *gorm.DB, refreshToken string) (uuid.UUID, error) {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(refreshToken)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, ErrInvalidRefreshToken
+		}
+		return uuid.Nil, err
+	}
+	return token.SessionID, nil
+}
+
+// RevokeSession 撤销一个会话，会话签发的访问令牌和刷新令牌随即失效
+func RevokeSession(db *gorm.DB, sessionID uuid.UUID, reason string) error {
+	return revoke(db.Where("id = ?", sessionID), reason, time.Now())
+}
+
+// RevokeUserSessions 撤销用户的全部会话（修改密码、退出所有设备），返回撤销的会话数
+func RevokeUserSessions(db *gorm.DB, userID uuid.UUID, reason string) (int64, error) {
+	result := db.Model(&models.AuthSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeExpired 删除before之前已过期或已撤销的会话及其刷新令牌，返回删除的会话数
+func PurgeExpired(db *gorm.DB, before time.Time) (int64, error) {
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		stale := tx.Model(&models.AuthSession{}).Select("id").
+			Where("expires_at < ? OR revoked_at < ?", before, before)
+		if err := tx.Where("session_id IN (?)", stale).Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("expires_at < ? OR revoked_at < ?", before, before).Delete(&models.AuthSession{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+// RunSessionCleanupScheduled 定时任务入口：清理过期或撤销超过7天的会话，以及已过期的第三方登录授权请求和邮件令牌
+func RunSessionCleanupScheduled() error {
+	db := models.GetDB()
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+	purged, err := PurgeExpired(db, time.Now().Add(-7*24*time.Hour))
+	if purged > 0 {
+		log.Printf("Purged %d expired sessions", purged)
+	}
+	if err != nil {
+		return err
+	}
+	states, err := PurgeExpiredStates(db, time.Now())
+	if states > 0 {
+		log.Printf("Purged %d expired oauth states", states)
+	}
+	if err != nil {
+		return err
+	}
+	tokens, err := PurgeExpiredEmailTokens(db, time.Now())
+	if tokens > 0 {
+		log.Printf("Purged %d expired email tokens", tokens)
+	}
+	return err
+}
+
+// revoke 撤销查询条件匹配的未撤销会话
+func revoke(sessions *gorm.DB, reason string, now time.Time) error {
+	return sessions.Model(&models.AuthSession{}).Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": reason}).Error
+}
+
+// signPair 签发访问令牌并与刷新令牌组成令牌对
+func signPair(user *models.User, session *models.AuthSession, refresh string) (*TokenPair, error) {
+	token, err := lib.GenerateToken(user.ID, user.Email, string(user.Role), session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		Token:            token,
+		ExpiresAt:        time.Now().Add(config.AppConfig.JWT.Expiration),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// createRefreshToken 生成刷新令牌并保存其哈希
+func createRefreshToken(tx *gorm.DB, sessionID uuid.UUID, expiresAt time.Time) (string, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return token, tx.Create(&models.RefreshToken{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// newRefreshToken 生成256位随机刷新令牌
+func newRefreshToken() (string, error) {
+	return randomToken()
+}
+
+// randomToken 生成256位随机值（base64url编码）
+func randomToken() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// hashToken 令牌的SHA-256哈希，数据库只保存哈希
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// refreshExpiration 刷新令牌有效期，未配置时为30天
+func refreshExpiration() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.JWT.RefreshExpiration > 0 {
+		return config.AppConfig.JWT.RefreshExpiration
+	}
+	return 30 * 24 * time.Hour
+}
+
+// truncate 截断超出字段长度的字符串
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
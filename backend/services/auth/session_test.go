@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/lib"
+
+	"github.com/google/uuid"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	a, err := newRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newRefreshToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || len(a) != 43 {
+		t.Errorf("expected distinct 43-character tokens, got %q and %q", a, b)
+	}
+	if hashToken(a) == hashToken(b) || len(hashToken(a)) != 64 {
+		t.Errorf("unexpected token hashes %q and %q", hashToken(a), hashToken(b))
+	}
+	if hashToken(a) != hashToken(a) {
+		t.Error("hashToken is not deterministic")
+	}
+}
+
+func TestAccessTokenCarriesSession(t *testing.T) {
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: "test-secret", Expiration: time.Minute}}
+
+	userID, sessionID := uuid.New(), uuid.New()
+	token, err := lib.GenerateToken(userID, "buyer@example.com", "user", sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := lib.ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != userID || claims.SessionID != sessionID {
+		t.Errorf("claims = %+v, want user %s session %s", claims, userID, sessionID)
+	}
+
+	// 不带会话的旧令牌无法撤销，一律拒绝（在查询数据库之前）
+	legacy, err := lib.GenerateToken(userID, "buyer@example.com", "user", uuid.Nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Authenticate(nil, legacy); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Authenticate(legacy) error = %v, want ErrSessionRevoked", err)
+	}
+
+	config.AppConfig.JWT.Expiration = -time.Minute
+	expired, err := lib.GenerateToken(userID, "buyer@example.com", "user", sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Authenticate(nil, expired); err == nil {
+		t.Error("Authenticate accepted an expired token")
+	}
+}
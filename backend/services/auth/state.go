@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidOAuthState 回调的state不存在、已使用、已过期，或不是由当前浏览器发起的登录
+	ErrInvalidOAuthState = errors.New("invalid or expired oauth state, please sign in again")
+	// ErrUnsupportedProvider 不支持的第三方登录提供商
+	ErrUnsupportedProvider = errors.New("unsupported oauth provider")
+	// ErrProviderUnavailable 提供商的登录尚未接入，无法获取用户身份
+	ErrProviderUnavailable = errors.New("oauth provider is not available yet")
+)
+
+const (
+	// OAuthBindingCookie 保存浏览器绑定值的Cookie，回调直接打到后端时随请求带回
+	OAuthBindingCookie = "oauth_binding"
+	// OAuthBindingHeader 前端回调页通过该请求头带回浏览器绑定值（前后端跨域时Cookie不会随请求发送）
+	OAuthBindingHeader = "X-OAuth-Binding"
+	// OAuthCallbackPath 回调接口路径前缀，绑定Cookie只在该路径下发送
+	OAuthCallbackPath = "/api/v1/auth/callback"
+)
+
+// AuthRequest 一次第三方登录授权请求
+type AuthRequest struct {
+	Provider  string
+	URL       string    // 跳转到提供商的授权地址
+	Binding   string    // 交由发起登录的浏览器保存，回调时原样带回
+	ExpiresAt time.Time // 超过该时间回调将被拒绝
+}
+
+// BeginOAuth 发起第三方登录：生成随机state和浏览器绑定值（只保存哈希），支持PKCE的提供商同时生成code_verifier
+func BeginOAuth(db *gorm.DB, provider string) (*AuthRequest, error) {
+	state, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	binding, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	var verifier string
+	if supportsPKCE(provider) {
+		verifier = oauth2.GenerateVerifier()
+	}
+
+	var url string
+	switch provider {
+	case "github":
+		url, err = GetGitHubAuthURL(state, verifier)
+	case "google":
+		url, err = GetGoogleAuthURL(state, verifier)
+	case "wechat":
+		url, err = GetWeChatAuthURL(state)
+	case "feishu":
+		url, err = GetFeishuAuthURL(state)
+	case "xiaohongshu":
+		url, err = GetXiaohongshuAuthURL(state)
+	default:
+		return nil, ErrUnsupportedProvider
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	row := &models.OAuthState{
+		ID:           uuid.New(),
+		Provider:     provider,
+		StateHash:    hashToken(state),
+		BindingHash:  hashToken(binding),
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(stateTTL()),
+	}
+	if err := db.Create(row).Error; err != nil {
+		return nil, err
+	}
+	return &AuthRequest{Provider: provider, URL: url, Binding: binding, ExpiresAt: row.ExpiresAt}, nil
+}
+
+// VerifyState 校验回调带回的state和浏览器绑定值，通过后将state标记为已使用并返回PKCE code_verifier（不支持PKCE的提供商为空）
+func VerifyState(db *gorm.DB, provider, state, binding string) (string, error) {
+	if state == "" || binding == "" {
+		return "", ErrInvalidOAuthState
+	}
+	var verifier string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var row models.OAuthState
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("state_hash = ?", hashToken(state)).First(&row).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidOAuthState
+			}
+			return err
+		}
+		now := time.Now()
+		if err := checkState(&row, provider, binding, now); err != nil {
+			return err
+		}
+		if err := tx.Model(&row).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		verifier = row.CodeVerifier
+		return nil
+	})
+	return verifier, err
+}
+
+// PurgeExpiredStates 删除before之前已过期的授权请求，返回删除的条数
+func PurgeExpiredStates(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("expires_at < ?", before).Delete(&models.OAuthState{})
+	return result.RowsAffected, result.Error
+}
+
+// checkState 校验授权请求：提供商一致、未使用、未过期，且绑定值与发起登录时的一致
+func checkState(row *models.OAuthState, provider, binding string, now time.Time) error {
+	if row.Provider != provider || row.UsedAt != nil || !row.ExpiresAt.After(now) {
+		return ErrInvalidOAuthState
+	}
+	if subtle.ConstantTimeCompare([]byte(row.BindingHash), []byte(hashToken(binding))) != 1 {
+		return ErrInvalidOAuthState
+	}
+	return nil
+}
+
+// supportsPKCE 提供商是否支持PKCE（S256）
+func supportsPKCE(provider string) bool {
+	return provider == "github" || provider == "google"
+}
+
+// stateTTL 授权请求有效期，未配置时为10分钟
+func stateTTL() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.OAuth.StateTTL > 0 {
+		return config.AppConfig.OAuth.StateTTL
+	}
+	return 10 * time.Minute
+}
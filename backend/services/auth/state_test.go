@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCheckState(t *testing.T) {
+	now := time.Now()
+	used := now.Add(-time.Second)
+	valid := func() *models.OAuthState {
+		return &models.OAuthState{
+			Provider:    "github",
+			StateHash:   hashToken("state"),
+			BindingHash: hashToken("binding"),
+			ExpiresAt:   now.Add(time.Minute),
+		}
+	}
+
+	if err := checkState(valid(), "github", "binding", now); err != nil {
+		t.Fatalf("checkState(valid) = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(*models.OAuthState)
+		provider string
+		binding  string
+	}{
+		{"other browser", func(*models.OAuthState) {}, "github", "attacker-binding"},
+		{"other provider", func(*models.OAuthState) {}, "google", "binding"},
+		{"already used", func(s *models.OAuthState) { s.UsedAt = &used }, "github", "binding"},
+		{"expired", func(s *models.OAuthState) { s.ExpiresAt = now }, "github", "binding"},
+	}
+	for _, tt := range tests {
+		row := valid()
+		tt.mutate(row)
+		if err := checkState(row, tt.provider, tt.binding, now); !errors.Is(err, ErrInvalidOAuthState) {
+			t.Errorf("%s: checkState error = %v, want ErrInvalidOAuthState", tt.name, err)
+		}
+	}
+
+	if _, err := VerifyState(nil, "github", "", "binding"); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("VerifyState without state error = %v, want ErrInvalidOAuthState", err)
+	}
+	if _, err := VerifyState(nil, "github", "state", ""); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("VerifyState without binding error = %v, want ErrInvalidOAuthState", err)
+	}
+}
+
+func TestAuthURLCarriesStateAndPKCE(t *testing.T) {
+	config.AppConfig = &config.Config{
+		OAuth: config.OAuthConfig{
+			GitHub: config.OAuthProvider{AppID: "github-id", AppSecret: "github-secret", Redirect: "http://localhost:3000/auth/callback?provider=github"},
+			WeChat: config.OAuthProvider{AppID: "wechat-id", Redirect: "http://localhost:3000/auth/callback?provider=wechat"},
+		},
+	}
+	InitOAuth()
+
+	verifier := oauth2.GenerateVerifier()
+	raw, err := GetGitHubAuthURL("state-123", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("state") != "state-123" {
+		t.Errorf("state = %q, want state-123", q.Get("state"))
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") != oauth2.S256ChallengeFromVerifier(verifier) {
+		t.Errorf("missing or wrong PKCE challenge in %s", raw)
+	}
+
+	raw, err = GetWeChatAuthURL("state-456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err = url.Parse(raw); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get("state"); got != "state-456" {
+		t.Errorf("wechat state = %q, want state-456", got)
+	}
+
+	if !supportsPKCE("google") || supportsPKCE("wechat") {
+		t.Error("unexpected PKCE support table")
+	}
+	if _, err := HandleXiaohongshuCallback("code"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Errorf("HandleXiaohongshuCallback error = %v, want ErrProviderUnavailable", err)
+	}
+}
@@ -0,0 +1,274 @@
+// Package bundle 技能套餐：套餐定义校验、报价，以及套餐价在各技能间的分摊
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/pricing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound 套餐不存在
+	ErrNotFound = errors.New("bundle not found")
+	// ErrUnavailable 套餐已停用、未开售、已停售或包含下架的技能
+	ErrUnavailable = errors.New("bundle is not available")
+	// ErrInvalidDefinition 套餐定义不合法
+	ErrInvalidDefinition = errors.New("invalid bundle")
+	// ErrSkillNotAllowed 技能不能加入该套餐
+	ErrSkillNotAllowed = errors.New("skill cannot be included in this bundle")
+)
+
+const (
+	// MinSkills 套餐至少包含的技能数
+	MinSkills = 2
+	// MaxSkills 套餐最多包含的技能数
+	MaxSkills = 50
+)
+
+// Definition 套餐定义，创建和更新时整体替换，技能按给定顺序展示
+type Definition struct {
+	Name        string       `json:"name" binding:"required"`
+	Description string       `json:"description"`
+	Price       models.Money `json:"price"` // {"amount":"99.00","currency":"CNY"}
+	StartsAt    *time.Time   `json:"starts_at,omitempty"`
+	EndsAt      *time.Time   `json:"ends_at,omitempty"`
+	IsActive    *bool        `json:"is_active,omitempty"` // 默认启用
+	SkillIDs    []uuid.UUID  `json:"skill_ids" binding:"required"`
+}
+
+// IsDefinitionError 是否为套餐定义不合法导致的错误
+func IsDefinitionError(err error) bool {
+	return errors.Is(err, ErrInvalidDefinition) || errors.Is(err, ErrSkillNotAllowed)
+}
+
+// normalize 校验并规范化定义：去除重复技能，币种转大写
+func (d *Definition) normalize() error {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidDefinition)
+	}
+	if d.Price.Amount <= 0 {
+		return fmt.Errorf("%w: price must be positive", ErrInvalidDefinition)
+	}
+	currency, err := pricing.NormalizeCurrency(d.Price.Currency)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	d.Price.Currency = currency
+	if d.StartsAt != nil && d.EndsAt != nil && !d.EndsAt.After(*d.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidDefinition)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(d.SkillIDs))
+	ids := d.SkillIDs[:0]
+	for _, id := range d.SkillIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	d.SkillIDs = ids
+	if len(ids) < MinSkills || len(ids) > MaxSkills {
+		return fmt.Errorf("%w: a bundle needs %d to %d skills", ErrInvalidDefinition, MinSkills, MaxSkills)
+	}
+	return nil
+}
+
+// checkSkill 套餐只能包含上架的付费买断技能；发布者的套餐只能包含自己的技能
+func checkSkill(skill *models.Skill, publisherID *uuid.UUID) error {
+	switch {
+	case !skill.IsActive:
+		return fmt.Errorf("%w: %s is not active", ErrSkillNotAllowed, skill.Name)
+	case skill.PriceType == models.PriceTypeFree:
+		return fmt.Errorf("%w: %s is free", ErrSkillNotAllowed, skill.Name)
+	case skill.PriceType == models.PriceTypeSubscription:
+		return fmt.Errorf("%w: %s is sold by subscription", ErrSkillNotAllowed, skill.Name)
+	case publisherID != nil && (skill.PublisherID == nil || *skill.PublisherID != *publisherID):
+		return fmt.Errorf("%w: %s belongs to another publisher", ErrSkillNotAllowed, skill.Name)
+	}
+	return nil
+}
+
+// Save 校验定义并保存套餐及其技能列表。bundle.PublisherID不为空时只允许包含该发布者的技能
+func Save(db *gorm.DB, bundle *models.Bundle, def Definition, create bool) error {
+	if err := def.normalize(); err != nil {
+		return err
+	}
+
+	var skills []models.Skill
+	if err := db.Where("id IN ?", def.SkillIDs).Find(&skills).Error; err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*models.Skill, len(skills))
+	for i := range skills {
+		byID[skills[i].ID] = &skills[i]
+	}
+	for _, id := range def.SkillIDs {
+		skill, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("%w: unknown skill %s", ErrSkillNotAllowed, id)
+		}
+		if err := checkSkill(skill, bundle.PublisherID); err != nil {
+			return err
+		}
+	}
+
+	bundle.Name = def.Name
+	bundle.Description = strings.TrimSpace(def.Description)
+	bundle.Price = def.Price
+	bundle.StartsAt, bundle.EndsAt = def.StartsAt, def.EndsAt
+	bundle.IsActive = def.IsActive == nil || *def.IsActive
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		save := tx.Omit("Items", "Publisher")
+		if create {
+			if err := save.Create(bundle).Error; err != nil {
+				return err
+			}
+		} else if err := save.Save(bundle).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BundleItem{}).Error; err != nil {
+			return err
+		}
+		bundle.Items = make([]models.BundleItem, 0, len(def.SkillIDs))
+		for i, id := range def.SkillIDs {
+			bundle.Items = append(bundle.Items, models.BundleItem{
+				ID:       uuid.New(),
+				BundleID: bundle.ID,
+				SkillID:  id,
+				Position: i,
+				Skill:    byID[id],
+			})
+		}
+		return tx.Omit("Skill").Create(&bundle.Items).Error
+	})
+}
+
+// Delete 删除套餐；已有订单购买过的套餐只停用，以保留订单的套餐信息。返回是否已删除
+func Delete(db *gorm.DB, bundle *models.Bundle) (bool, error) {
+	var ordered int64
+	if err := db.Model(&models.OrderItem{}).Where("bundle_id = ?", bundle.ID).Count(&ordered).Error; err != nil {
+		return false, err
+	}
+	if ordered > 0 {
+		bundle.IsActive = false
+		return false, db.Model(bundle).Update("is_active", false).Error
+	}
+	return true, db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", bundle.ID).Delete(&models.BundleItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(bundle).Error
+	})
+}
+
+// Load 按ID读取套餐及其技能
+func Load(db *gorm.DB, id uuid.UUID) (*models.Bundle, error) {
+	var bundle models.Bundle
+	err := db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Items.Skill").First(&bundle, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// ItemQuote 套餐中一个技能的报价
+type ItemQuote struct {
+	SkillID   uuid.UUID    `json:"skill_id"`
+	Name      string       `json:"name"`
+	ListPrice models.Money `json:"list_price"` // 单独购买的价格
+	Price     models.Money `json:"price"`      // 分摊到该技能的套餐价
+}
+
+// Quote 套餐报价
+type Quote struct {
+	Price     models.Money `json:"price"`
+	ListPrice models.Money `json:"list_price"` // 各技能单独购买的价格合计
+	Savings   models.Money `json:"savings"`    // 相对单独购买节省的金额，套餐价更高时为零
+	Items     []ItemQuote  `json:"items"`
+}
+
+// QuoteBundle 按地区和币种为套餐报价，并按各技能单独购买的价格比例分摊套餐价。
+// currency为空时使用套餐定价币种，否则按汇率换算
+func QuoteBundle(bundle *models.Bundle, region, currency string) (*Quote, error) {
+	price := bundle.Price
+	if currency != "" {
+		normalized, err := pricing.NormalizeCurrency(currency)
+		if err != nil {
+			return nil, err
+		}
+		if price, err = pricing.Convert(price, normalized); err != nil {
+			return nil, err
+		}
+	}
+
+	quote := &Quote{Price: price, ListPrice: models.NewMoney(0, price.Currency)}
+	weights := make([]int64, 0, len(bundle.Items))
+	for _, item := range bundle.Items {
+		if item.Skill == nil {
+			return nil, fmt.Errorf("%w: skill %s missing", ErrUnavailable, item.SkillID)
+		}
+		list, err := pricing.QuoteSkill(item.Skill, region, price.Currency)
+		if err != nil {
+			return nil, err
+		}
+		quote.Items = append(quote.Items, ItemQuote{SkillID: item.SkillID, Name: item.Skill.Name, ListPrice: list.Price})
+		quote.ListPrice = quote.ListPrice.Add(list.Price)
+		weights = append(weights, list.Price.Amount)
+	}
+	for i, share := range Prorate(price.Amount, weights) {
+		quote.Items[i].Price = models.NewMoney(share, price.Currency)
+	}
+	quote.Savings = models.NewMoney(0, price.Currency)
+	if quote.ListPrice.Amount > price.Amount {
+		quote.Savings = quote.ListPrice.Sub(price)
+	}
+	return quote, nil
+}
+
+// Prorate 按权重分摊金额，四舍五入后的尾差计入最后一项，各项之和等于总额；权重全为零时平均分摊
+func Prorate(total int64, weights []int64) []int64 {
+	shares := make([]int64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+	var sum int64
+	for _, w := range weights {
+		if w > 0 {
+			sum += w
+		}
+	}
+
+	remaining := total
+	for i, w := range weights {
+		if i == len(weights)-1 {
+			shares[i] = remaining
+			break
+		}
+		var share int64
+		switch {
+		case sum == 0:
+			share = total / int64(len(weights))
+		case w > 0:
+			share = (total*w + sum/2) / sum
+		}
+		if share > remaining {
+			share = remaining
+		}
+		shares[i] = share
+		remaining -= share
+	}
+	return shares
+}
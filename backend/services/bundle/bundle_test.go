@@ -0,0 +1,93 @@
+package bundle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func TestProrate(t *testing.T) {
+	tests := []struct {
+		total   int64
+		weights []int64
+		want    []int64
+	}{
+		{9900, []int64{5000, 3000, 2000}, []int64{4950, 2970, 1980}},
+		{1000, []int64{1, 1, 1}, []int64{333, 333, 334}},
+		{1000, []int64{0, 0}, []int64{500, 500}},
+		{1000, []int64{0, 700}, []int64{0, 1000}},
+		{999, []int64{2999, 2999, 1}, []int64{499, 499, 1}}, // 尾差计入最后一项
+	}
+	for _, tt := range tests {
+		got := Prorate(tt.total, tt.weights)
+		var sum int64
+		for i := range got {
+			sum += got[i]
+			if got[i] != tt.want[i] {
+				t.Errorf("Prorate(%d, %v) = %v, want %v", tt.total, tt.weights, got, tt.want)
+				break
+			}
+		}
+		if sum != tt.total {
+			t.Errorf("Prorate(%d, %v) sums to %d", tt.total, tt.weights, sum)
+		}
+	}
+	if got := Prorate(100, nil); len(got) != 0 {
+		t.Errorf("Prorate with no weights = %v", got)
+	}
+}
+
+func TestDefinitionNormalize(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	def := Definition{Name: " 入门套餐 ", Price: models.NewMoney(9900, "cny"), SkillIDs: []uuid.UUID{a, b, a}}
+	if err := def.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if def.Name != "入门套餐" || def.Price.Currency != "CNY" || len(def.SkillIDs) != 2 {
+		t.Errorf("unexpected normalized definition %+v", def)
+	}
+
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	invalid := []Definition{
+		{Name: "", Price: models.NewMoney(100, "USD"), SkillIDs: []uuid.UUID{a, b}},
+		{Name: "x", Price: models.NewMoney(0, "USD"), SkillIDs: []uuid.UUID{a, b}},
+		{Name: "x", Price: models.NewMoney(100, "US"), SkillIDs: []uuid.UUID{a, b}},
+		{Name: "x", Price: models.NewMoney(100, "USD"), SkillIDs: []uuid.UUID{a, a}},
+		{Name: "x", Price: models.NewMoney(100, "USD"), SkillIDs: []uuid.UUID{a, b}, StartsAt: &now, EndsAt: &earlier},
+	}
+	for _, def := range invalid {
+		if err := def.normalize(); !errors.Is(err, ErrInvalidDefinition) {
+			t.Errorf("normalize(%+v) = %v, want ErrInvalidDefinition", def, err)
+		}
+	}
+}
+
+func TestCheckSkill(t *testing.T) {
+	publisher, other := uuid.New(), uuid.New()
+	paid := models.Skill{Name: "a", IsActive: true, PriceType: models.PriceTypePaid, PublisherID: &publisher}
+
+	if err := checkSkill(&paid, nil); err != nil {
+		t.Errorf("admin bundle rejected paid skill: %v", err)
+	}
+	if err := checkSkill(&paid, &publisher); err != nil {
+		t.Errorf("publisher bundle rejected own skill: %v", err)
+	}
+	if err := checkSkill(&paid, &other); !errors.Is(err, ErrSkillNotAllowed) {
+		t.Errorf("publisher bundle accepted another publisher's skill: %v", err)
+	}
+
+	for _, skill := range []models.Skill{
+		{Name: "inactive", PriceType: models.PriceTypePaid},
+		{Name: "free", IsActive: true, PriceType: models.PriceTypeFree},
+		{Name: "subscription", IsActive: true, PriceType: models.PriceTypeSubscription},
+	} {
+		if err := checkSkill(&skill, nil); !errors.Is(err, ErrSkillNotAllowed) {
+			t.Errorf("checkSkill(%s) = %v, want ErrSkillNotAllowed", skill.Name, err)
+		}
+	}
+}
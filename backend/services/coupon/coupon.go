@@ -0,0 +1,328 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound 优惠码不存在
+	ErrNotFound = errors.New("coupon not found")
+	// ErrInactive 优惠券已停用、未开始或已过期
+	ErrInactive = errors.New("coupon is not active")
+	// ErrUsedUp 优惠券总使用次数已达上限
+	ErrUsedUp = errors.New("coupon usage limit reached")
+	// ErrUserLimit 当前用户的使用次数已达上限
+	ErrUserLimit = errors.New("coupon already used the maximum number of times")
+	// ErrNotApplicable 订单中没有适用该优惠券的技能，或币种不符
+	ErrNotApplicable = errors.New("coupon does not apply to this order")
+)
+
+// NormalizeCode 规范化优惠码（去除空白并转为大写）
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Lookup 按优惠码加载优惠券及其适用范围
+func Lookup(db *gorm.DB, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := db.Preload("Skills").Preload("Categories").Preload("Publishers").
+		Where("code = ?", NormalizeCode(code)).First(&coupon).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, NormalizeCode(code))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// Validate 检查优惠券在指定时间是否可用（不含使用次数）
+func Validate(coupon *models.Coupon, now time.Time) error {
+	if !coupon.IsActive {
+		return fmt.Errorf("%w: %s is disabled", ErrInactive, coupon.Code)
+	}
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return fmt.Errorf("%w: %s starts at %s", ErrInactive, coupon.Code, coupon.StartsAt.Format(time.RFC3339))
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return fmt.Errorf("%w: %s expired at %s", ErrInactive, coupon.Code, coupon.EndsAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Applies 优惠券是否适用于该技能
+func Applies(coupon *models.Coupon, skill *models.Skill) bool {
+	if len(coupon.Skills) == 0 && len(coupon.Categories) == 0 && len(coupon.Publishers) == 0 {
+		return true
+	}
+	if skill == nil {
+		return false
+	}
+	for _, s := range coupon.Skills {
+		if s.ID == skill.ID {
+			return true
+		}
+	}
+	if skill.CategoryID != nil {
+		for _, c := range coupon.Categories {
+			if c.ID == *skill.CategoryID {
+				return true
+			}
+		}
+	}
+	if skill.PublisherID != nil {
+		for _, p := range coupon.Publishers {
+			if p.ID == *skill.PublisherID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Discounts 计算每个订单项的折扣（最小单位）。订单项需已加载Skill；
+// 固定金额券按适用订单项的金额比例分摊，不超过适用订单项的合计
+func Discounts(coupon *models.Coupon, order *models.Order) ([]int64, error) {
+	discounts := make([]int64, len(order.Items))
+	var eligible []int
+	var base int64
+	for i := range order.Items {
+		item := &order.Items[i]
+		if Applies(coupon, item.Skill) {
+			eligible = append(eligible, i)
+			base += item.Price.Mul(int64(max(item.Quantity, 1))).Amount
+		}
+	}
+	if len(eligible) == 0 || base <= 0 {
+		return nil, fmt.Errorf("%w: no eligible items", ErrNotApplicable)
+	}
+
+	switch coupon.Type {
+	case models.CouponTypePercent:
+		if coupon.PercentOff <= 0 || coupon.PercentOff > 100 {
+			return nil, fmt.Errorf("%w: invalid percent_off %d", ErrNotApplicable, coupon.PercentOff)
+		}
+		for _, i := range eligible {
+			subtotal := order.Items[i].Price.Mul(int64(max(order.Items[i].Quantity, 1))).Amount
+			discounts[i] = (subtotal*int64(coupon.PercentOff) + 50) / 100
+		}
+	case models.CouponTypeFixed:
+		if !coupon.AmountOff.SameCurrency(order.Total) {
+			return nil, fmt.Errorf("%w: coupon is in %s, order is in %s", ErrNotApplicable, coupon.AmountOff.Currency, order.Total.Currency)
+		}
+		total := min(coupon.AmountOff.Amount, base)
+		var allocated int64
+		for n, i := range eligible {
+			if n == len(eligible)-1 {
+				// 余数计入最后一项，保证合计精确
+				discounts[i] = total - allocated
+				break
+			}
+			subtotal := order.Items[i].Price.Mul(int64(max(order.Items[i].Quantity, 1))).Amount
+			discounts[i] = total * subtotal / base
+			allocated += discounts[i]
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown coupon type %q", ErrNotApplicable, coupon.Type)
+	}
+	return discounts, nil
+}
+
+// Apply 将优惠码应用到尚未保存的订单：检查有效期和使用次数，写入各订单项的折扣和折扣明细，
+// 并从订单金额中扣除。使用次数在订单保存时由Reserve最终占用
+func Apply(db *gorm.DB, code string, userID uuid.UUID, order *models.Order) (*models.Coupon, error) {
+	coupon, err := Lookup(db, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(coupon, time.Now()); err != nil {
+		return nil, err
+	}
+	if coupon.MaxUses > 0 && coupon.UsedCount >= coupon.MaxUses {
+		return nil, fmt.Errorf("%w: %s", ErrUsedUp, coupon.Code)
+	}
+	if err := checkUserLimit(db, coupon, userID); err != nil {
+		return nil, err
+	}
+
+	discounts, err := Discounts(coupon, order)
+	if err != nil {
+		return nil, err
+	}
+
+	total := models.NewMoney(0, order.Total.Currency)
+	for i, amount := range discounts {
+		if amount == 0 {
+			continue
+		}
+		item := &order.Items[i]
+		item.Discount = models.NewMoney(amount, item.Price.Currency)
+		itemID := item.ID
+		couponID := coupon.ID
+		order.Discounts = append(order.Discounts, models.OrderDiscount{
+			ID:          uuid.New(),
+			OrderID:     order.ID,
+			OrderItemID: &itemID,
+			CouponID:    &couponID,
+			Code:        coupon.Code,
+			Amount:      item.Discount,
+		})
+		total = total.Add(item.Discount)
+	}
+	order.Discount = total
+	order.Total = order.Total.Sub(total)
+	return coupon, nil
+}
+
+// Reserve 在保存订单的事务中占用一次使用次数：锁定优惠券行后复核总次数和用户次数
+func Reserve(tx *gorm.DB, couponID, userID uuid.UUID, order *models.Order) error {
+	var coupon models.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, "id = ?", couponID).Error; err != nil {
+		return err
+	}
+	if coupon.MaxUses > 0 && coupon.UsedCount >= coupon.MaxUses {
+		return fmt.Errorf("%w: %s", ErrUsedUp, coupon.Code)
+	}
+	if err := checkUserLimit(tx, &coupon, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&coupon).UpdateColumn("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.CouponRedemption{
+		ID:       uuid.New(),
+		CouponID: coupon.ID,
+		UserID:   userID,
+		OrderID:  order.ID,
+		Discount: order.Discount,
+		Status:   models.CouponRedemptionReserved,
+	}).Error
+}
+
+// Redeem 订单支付成功后确认优惠券使用
+func Redeem(tx *gorm.DB, orderID uuid.UUID) error {
+	return tx.Model(&models.CouponRedemption{}).
+		Where("order_id = ? AND status = ?", orderID, models.CouponRedemptionReserved).
+		Updates(map[string]interface{}{"status": models.CouponRedemptionRedeemed, "redeemed_at": time.Now()}).Error
+}
+
+// Release 订单取消或过期时释放占用的使用次数
+func Release(tx *gorm.DB, orderID uuid.UUID) error {
+	var redemption models.CouponRedemption
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderID, models.CouponRedemptionReserved).
+		First(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&redemption).Updates(map[string]interface{}{
+		"status":      models.CouponRedemptionReleased,
+		"released_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Coupon{}).Where("id = ? AND used_count > 0", redemption.CouponID).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+// checkUserLimit 检查用户已占用（待支付或已支付）的使用次数
+func checkUserLimit(db *gorm.DB, coupon *models.Coupon, userID uuid.UUID) error {
+	if coupon.MaxUsesPerUser <= 0 {
+		return nil
+	}
+	var used int64
+	if err := db.Model(&models.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ? AND status IN ?", coupon.ID, userID,
+			[]models.CouponRedemptionStatus{models.CouponRedemptionReserved, models.CouponRedemptionRedeemed}).
+		Count(&used).Error; err != nil {
+		return err
+	}
+	if used >= int64(coupon.MaxUsesPerUser) {
+		return fmt.Errorf("%w: %s", ErrUserLimit, coupon.Code)
+	}
+	return nil
+}
+
+// Report 优惠券的使用汇总
+type Report struct {
+	CouponID  uuid.UUID                               `json:"coupon_id"`
+	Code      string                                  `json:"code"`
+	UsedCount int                                     `json:"used_count"`
+	ByStatus  map[models.CouponRedemptionStatus]int64 `json:"by_status"`
+	Discount  []models.Money                          `json:"discount"` // 已支付订单的折扣合计（按币种）
+}
+
+// BuildReport 汇总优惠券的使用情况
+func BuildReport(db *gorm.DB, coupon *models.Coupon) (*Report, error) {
+	report := &Report{
+		CouponID:  coupon.ID,
+		Code:      coupon.Code,
+		UsedCount: coupon.UsedCount,
+		ByStatus:  make(map[models.CouponRedemptionStatus]int64),
+		Discount:  []models.Money{},
+	}
+
+	var counts []struct {
+		Status models.CouponRedemptionStatus
+		Count  int64
+	}
+	if err := db.Model(&models.CouponRedemption{}).Select("status, COUNT(*) AS count").
+		Where("coupon_id = ?", coupon.ID).Group("status").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range counts {
+		report.ByStatus[c.Status] = c.Count
+	}
+
+	var redeemed []models.CouponRedemption
+	if err := db.Where("coupon_id = ? AND status = ?", coupon.ID, models.CouponRedemptionRedeemed).
+		Find(&redeemed).Error; err != nil {
+		return nil, err
+	}
+	amounts := make([]models.Money, 0, len(redeemed))
+	for _, r := range redeemed {
+		amounts = append(amounts, r.Discount)
+	}
+	report.Discount = models.SumByCurrency(amounts...)
+	return report, nil
+}
+
+// CheckDefinition 检查管理员配置的优惠券是否合法
+func CheckDefinition(coupon *models.Coupon) error {
+	if coupon.Code == "" || len(coupon.Code) > 64 {
+		return errors.New("code must be 1-64 characters")
+	}
+	switch coupon.Type {
+	case models.CouponTypePercent:
+		if coupon.PercentOff <= 0 || coupon.PercentOff > 100 {
+			return errors.New("percent_off must be between 1 and 100")
+		}
+	case models.CouponTypeFixed:
+		if coupon.AmountOff.Amount <= 0 || coupon.AmountOff.Currency == "" {
+			return errors.New("amount_off must be a positive amount with a currency")
+		}
+	default:
+		return fmt.Errorf("type must be %q or %q", models.CouponTypePercent, models.CouponTypeFixed)
+	}
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.EndsAt.After(*coupon.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if coupon.MaxUses < 0 || coupon.MaxUsesPerUser < 0 {
+		return errors.New("usage limits must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package coupon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func testOrder(currency string, skills ...*models.Skill) *models.Order {
+	order := &models.Order{Total: models.NewMoney(0, currency)}
+	for _, skill := range skills {
+		order.Items = append(order.Items, models.OrderItem{Price: skill.Price, Quantity: 1, Skill: skill})
+		order.Total = order.Total.Add(skill.Price)
+	}
+	return order
+}
+
+func TestDiscountsPercent(t *testing.T) {
+	category := uuid.New()
+	a := &models.Skill{ID: uuid.New(), Price: models.NewMoney(1999, "USD"), CategoryID: &category}
+	b := &models.Skill{ID: uuid.New(), Price: models.NewMoney(1000, "USD")}
+
+	coupon := &models.Coupon{Type: models.CouponTypePercent, PercentOff: 15, Categories: []models.SkillCategory{{ID: category}}}
+	discounts, err := Discounts(coupon, testOrder("USD", a, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 19.99 * 15% = 2.9985，四舍五入为 3.00；b 不在适用范围内
+	if discounts[0] != 300 || discounts[1] != 0 {
+		t.Errorf("unexpected discounts %v", discounts)
+	}
+
+	if _, err := Discounts(coupon, testOrder("USD", b)); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable without eligible items, got %v", err)
+	}
+}
+
+func TestDiscountsFixed(t *testing.T) {
+	a := &models.Skill{ID: uuid.New(), Price: models.NewMoney(1000, "USD")}
+	b := &models.Skill{ID: uuid.New(), Price: models.NewMoney(2000, "USD")}
+
+	coupon := &models.Coupon{Type: models.CouponTypeFixed, AmountOff: models.NewMoney(1000, "USD")}
+	discounts, err := Discounts(coupon, testOrder("USD", a, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if discounts[0] != 333 || discounts[1] != 667 {
+		t.Errorf("expected pro-rata split 333/667, got %v", discounts)
+	}
+
+	// 优惠金额不超过订单金额
+	coupon.AmountOff = models.NewMoney(5000, "USD")
+	discounts, _ = Discounts(coupon, testOrder("USD", a))
+	if discounts[0] != 1000 {
+		t.Errorf("expected discount capped at 1000, got %v", discounts)
+	}
+
+	if _, err := Discounts(coupon, testOrder("EUR", &models.Skill{Price: models.NewMoney(1000, "EUR")})); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable for a currency mismatch, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		coupon models.Coupon
+		valid  bool
+	}{
+		{models.Coupon{IsActive: true}, true},
+		{models.Coupon{IsActive: false}, false},
+		{models.Coupon{IsActive: true, StartsAt: &future}, false},
+		{models.Coupon{IsActive: true, EndsAt: &past}, false},
+		{models.Coupon{IsActive: true, StartsAt: &past, EndsAt: &future}, true},
+	}
+	for i, tt := range tests {
+		if err := Validate(&tt.coupon, now); (err == nil) != tt.valid {
+			t.Errorf("case %d: expected valid=%v, got %v", i, tt.valid, err)
+		}
+	}
+}
+
+func TestCheckDefinition(t *testing.T) {
+	if err := CheckDefinition(&models.Coupon{Code: "SPRING", Type: models.CouponTypePercent, PercentOff: 120}); err == nil {
+		t.Error("expected percent_off over 100 to be rejected")
+	}
+	if err := CheckDefinition(&models.Coupon{Code: "TENOFF", Type: models.CouponTypeFixed}); err == nil {
+		t.Error("expected fixed coupon without amount to be rejected")
+	}
+	if err := CheckDefinition(&models.Coupon{Code: "TENOFF", Type: models.CouponTypeFixed, AmountOff: models.NewMoney(1000, "USD")}); err != nil {
+		t.Errorf("expected valid coupon, got %v", err)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"log"
 	"skillhub/config"
 	"skillhub/models"
-	"strconv"
+	"skillhub/services/pricing"
 	"strings"
 	"time"
 )
@@ -56,7 +56,7 @@ type SkillMetadata struct {
 	Name        string
 	Description string
 	PriceType   models.PriceType
-	Price       float64
+	Price       models.Money
 	Category    string
 	Tags        []string
 	GitHubURL   string
@@ -73,6 +73,7 @@ func parseSkillMetadata(content string) (*SkillMetadata, error) {
 	// description: "智能代码生成和重构工具"
 	// price_type: "paid"
 	// price: 29.99
+	// currency: "USD"
 	// category: "Development"
 	// tags: ["AI", "Code", "Productivity"]
 	// ---
@@ -110,7 +111,7 @@ func parseSkillMetadata(content string) (*SkillMetadata, error) {
 			Name:        "未命名技能",
 			Description: "从GitHub仓库自动同步",
 			PriceType:   models.PriceTypeFree,
-			Price:       0,
+			Price:       models.NewMoney(0, pricing.DefaultCurrency()),
 			Category:    "其他",
 			Tags:        []string{"GitHub"},
 			GitHubURL:   "",
@@ -141,10 +142,16 @@ func parseSkillMetadata(content string) (*SkillMetadata, error) {
 		}
 	}
 
-	// 解析价格
-	price := 0.0
+	// 解析价格（未指定币种时为平台基础币种）
+	currency := pricing.DefaultCurrency()
+	if cur, ok := metadata["currency"]; ok {
+		if normalized, err := pricing.NormalizeCurrency(cur); err == nil {
+			currency = normalized
+		}
+	}
+	price := models.NewMoney(0, currency)
 	if p, ok := metadata["price"]; ok {
-		if parsedPrice, err := strconv.ParseFloat(p, 64); err == nil {
+		if parsedPrice, err := models.ParseMoney(p, currency); err == nil {
 			price = parsedPrice
 		}
 	}
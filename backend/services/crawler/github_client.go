@@ -6,6 +6,7 @@ import (
 	"log"
 	"skillhub/config"
 	"skillhub/models"
+	"skillhub/services/pricing"
 	"time"
 
 	"github.com/google/go-github/v58/github"
@@ -99,7 +100,7 @@ func (c *GitHubClient) ConvertToSkillModel(repo *github.Repository, topic string
 		StarsCount:  repo.GetStargazersCount(),
 		ForksCount:  repo.GetForksCount(),
 		PriceType:   models.PriceTypeFree, // 默认免费
-		Price:       0,
+		Price:       models.NewMoney(0, pricing.DefaultCurrency()),
 		IsActive:    true,
 		LastSyncAt:  &now,
 		SyncSource:  "github",
@@ -178,7 +179,7 @@ func (c *GitHubClient) ConvertToSkillModelWithMetadata(repo *github.Repository,
 		StarsCount:  repo.GetStargazersCount(),
 		ForksCount:  repo.GetForksCount(),
 		PriceType:   models.PriceTypeFree, // 默认免费
-		Price:       0,
+		Price:       models.NewMoney(0, pricing.DefaultCurrency()),
 		IsActive:    true,
 		LastSyncAt:  &now,
 		SyncSource:  "github",
@@ -0,0 +1,277 @@
+package earnings
+
+import (
+	"errors"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/ledger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxRateBps 抽成比例上限（100%）
+const MaxRateBps = 10000
+
+// ErrInvalidRate 抽成比例超出0-10000基点
+var ErrInvalidRate = errors.New("commission rate must be between 0 and 10000 bps")
+
+// DefaultRate 全局默认抽成比例
+func DefaultRate() int {
+	if config.AppConfig != nil {
+		return config.AppConfig.Payout.CommissionBps
+	}
+	return 3000
+}
+
+// HoldPeriod 收益从支付到可提现的结算期
+func HoldPeriod() time.Duration {
+	if config.AppConfig != nil {
+		return config.AppConfig.Payout.HoldPeriod
+	}
+	return 14 * 24 * time.Hour
+}
+
+// Split 按抽成比例拆分金额，返回平台抽成和发布者净额，抽成四舍五入到最小单位
+func Split(gross models.Money, rateBps int) (models.Money, models.Money) {
+	commission := models.NewMoney(mulDiv(gross.Amount, int64(rateBps), MaxRateBps), gross.Currency)
+	return commission, gross.Sub(commission)
+}
+
+// mulDiv 计算 a*b/c 并四舍五入（对负数对称）
+func mulDiv(a, b, c int64) int64 {
+	n := a * b
+	if n < 0 {
+		return -((-n + c/2) / c)
+	}
+	return (n + c/2) / c
+}
+
+// ResolveRate 技能的抽成比例：技能规则 > 发布者规则 > 全局默认
+func ResolveRate(db *gorm.DB, skillID uuid.UUID, publisherID *uuid.UUID) (int, error) {
+	var rules []models.CommissionRule
+	query := db.Where("skill_id = ?", skillID)
+	if publisherID != nil {
+		query = query.Or("publisher_id = ? AND skill_id IS NULL", *publisherID)
+	}
+	if err := query.Find(&rules).Error; err != nil {
+		return 0, err
+	}
+
+	rate := DefaultRate()
+	for _, rule := range rules {
+		if rule.SkillID != nil {
+			return rule.RateBps, nil
+		}
+		rate = rule.RateBps
+	}
+	return rate, nil
+}
+
+// Record 订单支付后为每个有发布者的订单项记录分成并记入总账，重复调用不会重复记录。调用方应在事务中
+func Record(tx *gorm.DB, order *models.Order, paidAt time.Time) error {
+	var items []models.OrderItem
+	if err := tx.Preload("Skill").Where("order_id = ? AND skill_id IS NOT NULL", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	availableAt := paidAt.Add(HoldPeriod())
+	for i := range items {
+		item := &items[i]
+		if item.Skill == nil || item.Skill.PublisherID == nil {
+			continue
+		}
+		// 代收的税款不参与分成
+		gross := item.Net()
+		if gross.Amount <= 0 {
+			continue
+		}
+		rate, err := ResolveRate(tx, *item.SkillID, item.Skill.PublisherID)
+		if err != nil {
+			return err
+		}
+
+		commission, net := Split(gross, rate)
+		earning := models.PublisherEarning{
+			ID:          uuid.New(),
+			PublisherID: *item.Skill.PublisherID,
+			Kind:        models.EarningKindSale,
+			OrderID:     order.ID,
+			OrderItemID: item.ID,
+			SkillID:     *item.SkillID,
+			Gross:       gross,
+			Commission:  commission,
+			Amount:      net,
+			RateBps:     rate,
+			AvailableAt: availableAt,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&earning)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			if err := ledger.RecordEarning(tx, &earning); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Allocate 将一笔退款分摊到订单项：指定商品的退款全部计入该商品，整单退款按各项实付（含价外税）比例分摊，
+// 尾差计入最后一项
+func Allocate(items []models.OrderItem, refund *models.Refund) map[uuid.UUID]int64 {
+	allocation := make(map[uuid.UUID]int64, len(items))
+	if refund.OrderItemID != nil {
+		allocation[*refund.OrderItemID] = refund.Amount.Amount
+		return allocation
+	}
+
+	var total int64
+	for i := range items {
+		total += items[i].Total().Amount
+	}
+	if total <= 0 {
+		return allocation
+	}
+	remaining := refund.Amount.Amount
+	for i := range items {
+		share := mulDiv(refund.Amount.Amount, items[i].Total().Amount, total)
+		if i == len(items)-1 || share > remaining {
+			share = remaining
+		}
+		allocation[items[i].ID] = share
+		remaining -= share
+	}
+	return allocation
+}
+
+// Clawback 退款成功后按退款分摊额扣回对应订单项的分成；订单全额退款时扣回全部剩余分成。
+// 已付款的分成同样扣回，负数计入发布者下一次付款。调用方应在事务中
+func Clawback(tx *gorm.DB, order *models.Order, refund *models.Refund, fullRefund bool) error {
+	allocation := Allocate(order.Items, refund)
+	now := time.Now()
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		refunded, ok := allocation[item.ID]
+		if !ok && !fullRefund {
+			continue
+		}
+
+		var sale models.PublisherEarning
+		err := tx.Where("order_item_id = ? AND kind = ?", item.ID, models.EarningKindSale).First(&sale).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// 退款金额含税，分成按不含税金额扣回
+		if total := item.Total().Amount; ok && item.Tax.Amount != 0 && total > 0 {
+			refunded = mulDiv(refunded, item.Net().Amount, total)
+		}
+
+		var clawed int64
+		if err := tx.Model(&models.PublisherEarning{}).
+			Where("order_item_id = ? AND kind = ?", item.ID, models.EarningKindClawback).
+			Select("COALESCE(SUM(amount_amount_minor), 0)").Scan(&clawed).Error; err != nil {
+			return err
+		}
+		remaining := sale.Amount.Amount + clawed
+		if remaining <= 0 {
+			continue
+		}
+
+		net := remaining
+		if !fullRefund && sale.Gross.Amount > 0 {
+			net = mulDiv(sale.Amount.Amount, refunded, sale.Gross.Amount)
+			if net > remaining {
+				net = remaining
+			}
+		}
+		if net <= 0 {
+			continue
+		}
+		if fullRefund && !ok {
+			refunded = mulDiv(net, sale.Gross.Amount, sale.Amount.Amount)
+		}
+
+		currency := sale.Amount.Currency
+		gross := models.NewMoney(-refunded, currency)
+		amount := models.NewMoney(-net, currency)
+		refundID := refund.ID
+		clawback := models.PublisherEarning{
+			ID:          uuid.New(),
+			PublisherID: sale.PublisherID,
+			Kind:        models.EarningKindClawback,
+			OrderID:     sale.OrderID,
+			OrderItemID: sale.OrderItemID,
+			SkillID:     sale.SkillID,
+			RefundID:    &refundID,
+			Gross:       gross,
+			Commission:  gross.Sub(amount),
+			Amount:      amount,
+			RateBps:     sale.RateBps,
+			AvailableAt: now,
+		}
+		if err := tx.Create(&clawback).Error; err != nil {
+			return err
+		}
+		if err := ledger.RecordEarning(tx, &clawback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Balance 发布者在某币种下的余额
+type Balance struct {
+	Currency  string       `json:"currency"`
+	Pending   models.Money `json:"pending"`   // 结算期内，尚不可提现
+	Available models.Money `json:"available"` // 可纳入下一次付款，扣回可能使其为负
+	InPayout  models.Money `json:"in_payout"` // 已纳入付款批次，尚未付款
+	PaidOut   models.Money `json:"paid_out"`  // 已付款
+}
+
+// Balances 按币种汇总发布者的收益余额
+func Balances(db *gorm.DB, publisherID uuid.UUID, now time.Time) ([]Balance, error) {
+	var rows []struct {
+		Currency  string
+		Pending   int64
+		Available int64
+		InPayout  int64
+		PaidOut   int64
+	}
+	err := db.Model(&models.PublisherEarning{}).
+		Select(`publisher_earnings.amount_currency AS currency,
+			COALESCE(SUM(CASE WHEN payout_id IS NULL AND available_at > ? THEN amount_amount_minor END), 0) AS pending,
+			COALESCE(SUM(CASE WHEN payout_id IS NULL AND available_at <= ? THEN amount_amount_minor END), 0) AS available,
+			COALESCE(SUM(CASE WHEN payouts.status = ? THEN amount_amount_minor END), 0) AS in_payout,
+			COALESCE(SUM(CASE WHEN payouts.status = ? THEN amount_amount_minor END), 0) AS paid_out`,
+			now, now, models.PayoutStatusPending, models.PayoutStatusPaid).
+		Joins("LEFT JOIN payouts ON payouts.id = publisher_earnings.payout_id").
+		Where("publisher_earnings.publisher_id = ?", publisherID).
+		Group("publisher_earnings.amount_currency").
+		Order("publisher_earnings.amount_currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]Balance, len(rows))
+	for i, row := range rows {
+		balances[i] = Balance{
+			Currency:  row.Currency,
+			Pending:   models.NewMoney(row.Pending, row.Currency),
+			Available: models.NewMoney(row.Available, row.Currency),
+			InPayout:  models.NewMoney(row.InPayout, row.Currency),
+			PaidOut:   models.NewMoney(row.PaidOut, row.Currency),
+		}
+	}
+	return balances, nil
+}
@@ -0,0 +1,69 @@
+package earnings
+
+import (
+	"testing"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		gross      int64
+		rate       int
+		commission int64
+		net        int64
+	}{
+		{999, 3000, 300, 699}, // 299.7四舍五入
+		{1000, 0, 0, 1000},
+		{1000, 10000, 1000, 0},
+		{333, 1500, 50, 283}, // 49.95
+		{-999, 3000, -300, -699},
+	}
+	for _, tt := range tests {
+		commission, net := Split(models.NewMoney(tt.gross, "USD"), tt.rate)
+		if commission.Amount != tt.commission || net.Amount != tt.net || net.Currency != "USD" {
+			t.Errorf("Split(%d, %d) = %d, %d; want %d, %d", tt.gross, tt.rate, commission.Amount, net.Amount, tt.commission, tt.net)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	items := []models.OrderItem{
+		{ID: uuid.New(), Price: models.NewMoney(1000, "USD")},
+		{ID: uuid.New(), Price: models.NewMoney(2000, "USD")},
+		{ID: uuid.New(), Price: models.NewMoney(1500, "USD"), Discount: models.NewMoney(500, "USD")},
+	}
+
+	// 指定商品的退款全部计入该商品
+	refund := &models.Refund{OrderItemID: &items[1].ID, Amount: models.NewMoney(700, "USD")}
+	if got := Allocate(items, refund); len(got) != 1 || got[items[1].ID] != 700 {
+		t.Errorf("unexpected item allocation %v", got)
+	}
+
+	// 整单退款按实付比例（1000:2000:1000）分摊，合计等于退款金额
+	refund = &models.Refund{Amount: models.NewMoney(1001, "USD")}
+	got := Allocate(items, refund)
+	if got[items[0].ID] != 250 || got[items[1].ID] != 501 || got[items[2].ID] != 250 {
+		t.Errorf("unexpected proportional allocation %v", got)
+	}
+	var sum int64
+	for _, amount := range got {
+		sum += amount
+	}
+	if sum != refund.Amount.Amount {
+		t.Errorf("allocation sums to %d, want %d", sum, refund.Amount.Amount)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	if !CanTransition(models.PayoutBatchPending, models.PayoutBatchProcessing) ||
+		!CanTransition(models.PayoutBatchProcessing, models.PayoutBatchPaid) {
+		t.Error("expected forward transitions to be allowed")
+	}
+	if CanTransition(models.PayoutBatchPaid, models.PayoutBatchCancelled) ||
+		CanTransition(models.PayoutBatchCancelled, models.PayoutBatchPending) {
+		t.Error("expected terminal batches to reject transitions")
+	}
+}
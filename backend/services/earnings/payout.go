@@ -0,0 +1,282 @@
+package earnings
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/ledger"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNothingToPay 没有可提现余额为正的发布者
+	ErrNothingToPay = errors.New("no available earnings to pay out")
+	// ErrBatchNotFound 付款批次不存在
+	ErrBatchNotFound = errors.New("payout batch not found")
+	// ErrPayoutNotFound 付款记录不存在
+	ErrPayoutNotFound = errors.New("payout not found")
+	// ErrInvalidBatchStatus 付款批次状态不允许该操作
+	ErrInvalidBatchStatus = errors.New("invalid payout batch status transition")
+)
+
+// payoutLockKey 生成付款批次时的事务级咨询锁，避免并发生成的批次重复包含同一笔收益
+const payoutLockKey = 740040
+
+// batchTransitions 付款批次允许的状态变更
+var batchTransitions = map[models.PayoutBatchStatus][]models.PayoutBatchStatus{
+	models.PayoutBatchPending:    {models.PayoutBatchProcessing, models.PayoutBatchPaid, models.PayoutBatchCancelled},
+	models.PayoutBatchProcessing: {models.PayoutBatchPaid, models.PayoutBatchCancelled},
+}
+
+// CanTransition 付款批次能否从from变更为to
+func CanTransition(from, to models.PayoutBatchStatus) bool {
+	for _, allowed := range batchTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func generateBatchNo(now time.Time) string {
+	return "PO" + now.Format("20060102150405") + strings.ToUpper(uuid.New().String()[:8])
+}
+
+// CreateBatch 将截至now可提现的收益按发布者和币种汇总生成付款批次。
+// 余额不为正的发布者不纳入，其扣回留待之后的收益抵扣
+func CreateBatch(db *gorm.DB, now time.Time, createdBy *uuid.UUID, note string) (*models.PayoutBatch, error) {
+	batch := &models.PayoutBatch{
+		ID:        uuid.New(),
+		BatchNo:   generateBatchNo(now),
+		Status:    models.PayoutBatchPending,
+		CreatedBy: createdBy,
+		Note:      note,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", payoutLockKey).Error; err != nil {
+			return err
+		}
+
+		var groups []struct {
+			PublisherID uuid.UUID
+			Currency    string
+		}
+		if err := tx.Model(&models.PublisherEarning{}).
+			Select("publisher_id, amount_currency AS currency").
+			Where("payout_id IS NULL AND available_at <= ?", now).
+			Group("publisher_id, amount_currency").
+			Having("SUM(amount_amount_minor) > 0").
+			Order("publisher_id, amount_currency").
+			Scan(&groups).Error; err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			return ErrNothingToPay
+		}
+
+		if err := tx.Omit("Payouts").Create(batch).Error; err != nil {
+			return err
+		}
+		for _, group := range groups {
+			payout := models.Payout{
+				ID:          uuid.New(),
+				BatchID:     batch.ID,
+				PublisherID: group.PublisherID,
+				Amount:      models.NewMoney(0, group.Currency),
+				Status:      models.PayoutStatusPending,
+			}
+			if err := tx.Create(&payout).Error; err != nil {
+				return err
+			}
+			assigned := tx.Model(&models.PublisherEarning{}).
+				Where("publisher_id = ? AND amount_currency = ? AND payout_id IS NULL AND available_at <= ?",
+					group.PublisherID, group.Currency, now).
+				Update("payout_id", payout.ID)
+			if assigned.Error != nil {
+				return assigned.Error
+			}
+
+			var total int64
+			if err := tx.Model(&models.PublisherEarning{}).Where("payout_id = ?", payout.ID).
+				Select("COALESCE(SUM(amount_amount_minor), 0)").Scan(&total).Error; err != nil {
+				return err
+			}
+			payout.Amount.Amount, payout.Entries = total, int(assigned.RowsAffected)
+			if err := tx.Model(&payout).Updates(map[string]interface{}{
+				"amount_minor": total,
+				"entries":      payout.Entries,
+			}).Error; err != nil {
+				return err
+			}
+			batch.Payouts = append(batch.Payouts, payout)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// LoadBatch 读取付款批次及其付款
+func LoadBatch(db *gorm.DB, id uuid.UUID) (*models.PayoutBatch, error) {
+	var batch models.PayoutBatch
+	err := db.Preload("Payouts", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Preload("Payouts.Publisher").First(&batch, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// releasePayouts 将待付款的付款标记为失败，其收益退回可提现余额
+func releasePayouts(tx *gorm.DB, payoutIDs []uuid.UUID, reason string) error {
+	if len(payoutIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.PublisherEarning{}).Where("payout_id IN ?", payoutIDs).
+		Update("payout_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Payout{}).Where("id IN ?", payoutIDs).Updates(map[string]interface{}{
+		"status":  models.PayoutStatusFailed,
+		"failure": reason,
+	}).Error
+}
+
+// UpdateBatchStatus 变更付款批次状态：付款完成时其中待付款的付款均标记为已付款并记入总账，
+// 取消时所有待付款的付款作废并退回收益
+func UpdateBatchStatus(db *gorm.DB, id uuid.UUID, to models.PayoutBatchStatus, now time.Time) (*models.PayoutBatch, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var batch models.PayoutBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&batch, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBatchNotFound
+			}
+			return err
+		}
+		if !CanTransition(batch.Status, to) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidBatchStatus, batch.Status, to)
+		}
+
+		updates := map[string]interface{}{"status": to}
+		switch to {
+		case models.PayoutBatchPaid:
+			updates["paid_at"] = now
+			var payouts []models.Payout
+			if err := tx.Where("batch_id = ? AND status = ?", batch.ID, models.PayoutStatusPending).
+				Find(&payouts).Error; err != nil {
+				return err
+			}
+			for i := range payouts {
+				if err := tx.Model(&payouts[i]).Update("status", models.PayoutStatusPaid).Error; err != nil {
+					return err
+				}
+				if err := ledger.RecordPayout(tx, &payouts[i]); err != nil {
+					return err
+				}
+			}
+		case models.PayoutBatchCancelled:
+			var pending []uuid.UUID
+			if err := tx.Model(&models.Payout{}).
+				Where("batch_id = ? AND status = ?", batch.ID, models.PayoutStatusPending).
+				Pluck("id", &pending).Error; err != nil {
+				return err
+			}
+			if err := releasePayouts(tx, pending, "batch cancelled"); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&batch).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return LoadBatch(db, id)
+}
+
+// FailPayout 单笔付款失败（如收款账户有误），其收益退回可提现余额，纳入之后的批次
+func FailPayout(db *gorm.DB, payoutID uuid.UUID, reason string) (*models.Payout, error) {
+	var payout models.Payout
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payout, "id = ?", payoutID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPayoutNotFound
+			}
+			return err
+		}
+		if payout.Status != models.PayoutStatusPending {
+			return fmt.Errorf("%w: payout is %s", ErrInvalidBatchStatus, payout.Status)
+		}
+		if err := releasePayouts(tx, []uuid.UUID{payout.ID}, reason); err != nil {
+			return err
+		}
+		payout.Status, payout.Failure = models.PayoutStatusFailed, reason
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// Export 生成付款批次的CSV付款文件，收款账户取发布者的开票资料
+func Export(db *gorm.DB, batch *models.PayoutBatch) ([]byte, error) {
+	publisherIDs := make([]uuid.UUID, 0, len(batch.Payouts))
+	for _, payout := range batch.Payouts {
+		publisherIDs = append(publisherIDs, payout.PublisherID)
+	}
+	var profiles []models.BillingProfile
+	if len(publisherIDs) > 0 {
+		if err := db.Where("user_id IN ?", publisherIDs).Find(&profiles).Error; err != nil {
+			return nil, err
+		}
+	}
+	payees := make(map[uuid.UUID]models.InvoiceParty, len(profiles))
+	for _, profile := range profiles {
+		payees[profile.UserID] = profile.Party
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"batch_no", "payout_id", "publisher_id", "publisher_email", "payee_name",
+		"bank_name", "bank_account", "currency", "amount", "entries", "status"})
+	for _, payout := range batch.Payouts {
+		var email, name string
+		if payout.Publisher != nil {
+			email, name = payout.Publisher.Email, payout.Publisher.Name
+		}
+		payee := payees[payout.PublisherID]
+		if payee.Name != "" {
+			name = payee.Name
+		}
+		w.Write([]string{
+			batch.BatchNo,
+			payout.ID.String(),
+			payout.PublisherID.String(),
+			email,
+			name,
+			payee.BankName,
+			payee.BankAccount,
+			payout.Amount.Currency,
+			payout.Amount.Decimal(),
+			strconv.Itoa(payout.Entries),
+			string(payout.Status),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,221 @@
+package entitlement
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound 使用权记录不存在
+	ErrNotFound = errors.New("entitlement not found")
+	// ErrAlreadyRevoked 使用权已被撤销
+	ErrAlreadyRevoked = errors.New("entitlement already revoked")
+	// ErrReasonRequired 手动授予或撤销需要填写原因
+	ErrReasonRequired = errors.New("reason is required")
+)
+
+// active 有效使用权的查询条件
+func active(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("revoked_at IS NULL AND granted_at <= ? AND (expires_at IS NULL OR expires_at > ?)", now, now)
+}
+
+// ActiveSkills 返回用户在指定时间拥有有效使用权的技能
+func ActiveSkills(db *gorm.DB, userID uuid.UUID, skillIDs []uuid.UUID, now time.Time) (map[uuid.UUID]bool, error) {
+	var ids []uuid.UUID
+	err := active(db.Model(&models.UserEntitlement{}), now).
+		Where("user_id = ? AND skill_id IN ?", userID, skillIDs).
+		Distinct().Pluck("skill_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// HasAccess 用户当前是否拥有技能的使用权
+func HasAccess(db *gorm.DB, userID, skillID uuid.UUID) (bool, error) {
+	skills, err := ActiveSkills(db, userID, []uuid.UUID{skillID}, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return skills[skillID], nil
+}
+
+// GrantOrder 订单支付后为每个未退款的订单项授予永久使用权，重复调用不会重复授予
+func GrantOrder(tx *gorm.DB, order *models.Order, grantedAt time.Time) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ? AND skill_id IS NOT NULL AND refunded_at IS NULL", order.ID).
+		Find(&items).Error; err != nil {
+		return err
+	}
+
+	orderID := order.ID
+	for _, item := range items {
+		grant := models.UserEntitlement{
+			ID:        uuid.New(),
+			UserID:    order.UserID,
+			SkillID:   *item.SkillID,
+			Source:    models.EntitlementSourcePurchase,
+			OrderID:   &orderID,
+			GrantedAt: grantedAt,
+			Reason:    "order " + order.OrderNo,
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeOrder 撤销订单授予的使用权，skillID为空时撤销整单
+func RevokeOrder(tx *gorm.DB, orderID uuid.UUID, skillID *uuid.UUID, reason string) error {
+	query := tx.Model(&models.UserEntitlement{}).Where("order_id = ? AND revoked_at IS NULL", orderID)
+	if skillID != nil {
+		query = query.Where("skill_id = ?", *skillID)
+	}
+	return query.Updates(map[string]interface{}{
+		"revoked_at":    time.Now(),
+		"revoke_reason": reason,
+	}).Error
+}
+
+// GrantGift 兑换礼品码后为兑换人授予永久使用权，同一兑换码只授予一次
+func GrantGift(tx *gorm.DB, gift *models.Gift, userID uuid.UUID, grantedAt time.Time) error {
+	giftID := gift.ID
+	grant := models.UserEntitlement{
+		ID:        uuid.New(),
+		UserID:    userID,
+		SkillID:   gift.SkillID,
+		Source:    models.EntitlementSourceGift,
+		GiftID:    &giftID,
+		GrantedAt: grantedAt,
+		Reason:    "gift " + gift.Code,
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&grant).Error
+}
+
+// RevokeGifts 撤销礼品兑换授予的使用权（如礼品订单被退款）
+func RevokeGifts(tx *gorm.DB, giftIDs []uuid.UUID, reason string) error {
+	if len(giftIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&models.UserEntitlement{}).
+		Where("gift_id IN ? AND revoked_at IS NULL", giftIDs).
+		Updates(map[string]interface{}{
+			"revoked_at":    time.Now(),
+			"revoke_reason": reason,
+		}).Error
+}
+
+// SyncSubscription 按订阅的状态和当期更新其使用权：有效期为订阅的使用截止时间，订阅失效时撤销
+func SyncSubscription(tx *gorm.DB, sub *models.Subscription, grace time.Duration) error {
+	until := sub.AccessUntil(grace)
+	if until.IsZero() {
+		return tx.Model(&models.UserEntitlement{}).
+			Where("subscription_id = ? AND revoked_at IS NULL", sub.ID).
+			Updates(map[string]interface{}{
+				"revoked_at":    time.Now(),
+				"revoke_reason": fmt.Sprintf("subscription %s", sub.Status),
+			}).Error
+	}
+
+	grantedAt := time.Now()
+	if sub.CurrentPeriodStart != nil && sub.CurrentPeriodStart.Before(grantedAt) {
+		grantedAt = *sub.CurrentPeriodStart
+	}
+	subID := sub.ID
+	grant := models.UserEntitlement{
+		ID:             uuid.New(),
+		UserID:         sub.UserID,
+		SkillID:        sub.SkillID,
+		Source:         models.EntitlementSourceSubscription,
+		SubscriptionID: &subID,
+		GrantedAt:      grantedAt,
+		ExpiresAt:      &until,
+		Reason:         "subscription " + sub.ID.String(),
+	}
+	// 已有记录时只更新有效期，续费后恢复被撤销的使用权
+	return tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "subscription_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "subscription_id IS NOT NULL"}}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"expires_at":    until,
+			"revoked_at":    nil,
+			"revoke_reason": "",
+			"updated_at":    time.Now(),
+		}),
+	}).Create(&grant).Error
+}
+
+// RevokeSubscription 撤销订阅授予的使用权（如订阅订单被退款）
+func RevokeSubscription(tx *gorm.DB, subscriptionID uuid.UUID, reason string) error {
+	return tx.Model(&models.UserEntitlement{}).
+		Where("subscription_id = ? AND revoked_at IS NULL", subscriptionID).
+		Updates(map[string]interface{}{
+			"revoked_at":    time.Now(),
+			"revoke_reason": reason,
+		}).Error
+}
+
+// Grant 管理员手动授予使用权，expiresAt为空表示永久
+func Grant(db *gorm.DB, userID, skillID uuid.UUID, adminID *uuid.UUID, expiresAt *time.Time, reason string) (*models.UserEntitlement, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	grant := &models.UserEntitlement{
+		ID:        uuid.New(),
+		UserID:    userID,
+		SkillID:   skillID,
+		Source:    models.EntitlementSourceAdmin,
+		GrantedAt: time.Now(),
+		ExpiresAt: expiresAt,
+		GrantedBy: adminID,
+		Reason:    reason,
+	}
+	if err := db.Create(grant).Error; err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// Revoke 管理员手动撤销任意来源的使用权
+func Revoke(db *gorm.DB, id uuid.UUID, adminID *uuid.UUID, reason string) (*models.UserEntitlement, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var grant models.UserEntitlement
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&grant, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if grant.RevokedAt != nil {
+			return ErrAlreadyRevoked
+		}
+
+		now := time.Now()
+		grant.RevokedAt, grant.RevokedBy, grant.RevokeReason = &now, adminID, reason
+		return tx.Model(&grant).Updates(map[string]interface{}{
+			"revoked_at":    now,
+			"revoked_by":    adminID,
+			"revoke_reason": reason,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
@@ -0,0 +1,307 @@
+// Package gift 礼品购买：礼品订单支付后生成一次性兑换码，兑换人获得技能的永久使用权
+package gift
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/entitlement"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound 兑换码不存在
+	ErrNotFound = errors.New("gift code not found")
+	// ErrAlreadyRedeemed 兑换码已被使用
+	ErrAlreadyRedeemed = errors.New("gift has already been redeemed")
+	// ErrExpired 超过兑换期限
+	ErrExpired = errors.New("gift has expired")
+	// ErrRefunded 礼品订单已退款
+	ErrRefunded = errors.New("gift has been refunded")
+	// ErrWrongRecipient 礼品指定了其他收件人
+	ErrWrongRecipient = errors.New("gift was sent to a different email address")
+	// ErrEmailNotVerified 指定收件人的礼品只能由邮箱已验证的账号兑换
+	ErrEmailNotVerified = errors.New("verify your email address to redeem this gift")
+	// ErrAlreadyOwned 兑换人已拥有该技能
+	ErrAlreadyOwned = errors.New("recipient already owns this skill")
+	// ErrInvalidEmail 收件人邮箱格式不正确
+	ErrInvalidEmail = errors.New("invalid recipient email")
+	// ErrInvalidQuantity 礼品份数超出范围
+	ErrInvalidQuantity = errors.New("invalid gift quantity")
+)
+
+const (
+	// MaxQuantity 每个技能单次购买的礼品份数上限
+	MaxQuantity = 50
+
+	// codeAlphabet 兑换码字符集，去掉了易混淆的 0/O、1/I
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 16
+	codeGroup    = 4
+)
+
+// Options 礼品结算选项
+type Options struct {
+	RecipientEmail string `json:"recipient_email"` // 为空时生成可转交的兑换码
+	Message        string `json:"message"`
+	Quantity       int    `json:"quantity"` // 每个技能的份数，默认1
+}
+
+// Normalize 校验并规范化礼品选项：邮箱转小写，份数默认为1
+func (o *Options) Normalize() error {
+	if o.Quantity == 0 {
+		o.Quantity = 1
+	}
+	if o.Quantity < 0 || o.Quantity > MaxQuantity {
+		return ErrInvalidQuantity
+	}
+	o.RecipientEmail = NormalizeEmail(o.RecipientEmail)
+	if o.RecipientEmail != "" {
+		addr, err := mail.ParseAddress(o.RecipientEmail)
+		if err != nil || addr.Address != o.RecipientEmail {
+			return ErrInvalidEmail
+		}
+		// 指定收件人的礼品只能兑换一次，多份时请分别购买或使用可转交的兑换码
+		if o.Quantity > 1 {
+			return ErrInvalidQuantity
+		}
+	}
+	o.Message = strings.TrimSpace(o.Message)
+	return nil
+}
+
+// NormalizeEmail 规范化收件人邮箱（去除空白、转小写），用于匹配兑换人
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeCode 规范化用户输入的兑换码：忽略大小写、空格和连字符，格式不正确时返回空字符串
+func NormalizeCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if r == '-' || r == ' ' {
+			continue
+		}
+		if !strings.ContainsRune(codeAlphabet, r) {
+			return ""
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() != codeLength {
+		return ""
+	}
+	return formatCode(b.String())
+}
+
+// formatCode 每4个字符插入一个连字符
+func formatCode(raw string) string {
+	groups := make([]string, 0, codeLength/codeGroup)
+	for i := 0; i < len(raw); i += codeGroup {
+		groups = append(groups, raw[i:i+codeGroup])
+	}
+	return strings.Join(groups, "-")
+}
+
+// generateCode 生成随机兑换码（80位熵）
+func generateCode() (string, error) {
+	buf := make([]byte, codeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, v := range buf {
+		buf[i] = codeAlphabet[int(v)%len(codeAlphabet)]
+	}
+	return formatCode(string(buf)), nil
+}
+
+// Issue 礼品订单支付后为每个未退款的订单项按数量生成兑换码，重复调用不会重复生成。
+// expiry为0表示兑换码不过期
+func Issue(tx *gorm.DB, order *models.Order, expiry time.Duration, issuedAt time.Time) ([]models.Gift, error) {
+	var gifts []models.Gift
+	if err := tx.Where("order_id = ?", order.ID).Find(&gifts).Error; err != nil {
+		return nil, err
+	}
+	if len(gifts) > 0 {
+		return gifts, nil
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ? AND skill_id IS NOT NULL AND refunded_at IS NULL", order.ID).
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if expiry > 0 {
+		at := issuedAt.Add(expiry)
+		expiresAt = &at
+	}
+	for _, item := range items {
+		for n := 0; n < max(item.Quantity, 1); n++ {
+			code, err := generateCode()
+			if err != nil {
+				return nil, err
+			}
+			gifts = append(gifts, models.Gift{
+				ID:             uuid.New(),
+				Code:           code,
+				OrderID:        order.ID,
+				OrderItemID:    item.ID,
+				SkillID:        *item.SkillID,
+				PurchaserID:    order.UserID,
+				RecipientEmail: order.GiftEmail,
+				Message:        order.GiftMessage,
+				Status:         models.GiftStatusUnredeemed,
+				ExpiresAt:      expiresAt,
+			})
+		}
+	}
+	if len(gifts) == 0 {
+		return nil, nil
+	}
+	if err := tx.Create(&gifts).Error; err != nil {
+		return nil, err
+	}
+	if order.GiftEmail != "" {
+		log.Printf("Order %s: %d gift(s) ready for %s", order.OrderNo, len(gifts), order.GiftEmail)
+	}
+	return gifts, nil
+}
+
+// checkRedeemable 检查礼品当前能否由指定邮箱的用户兑换，指定收件人的礼品还要求该邮箱已验证
+func checkRedeemable(gift *models.Gift, email string, verified bool, now time.Time) error {
+	switch gift.Status {
+	case models.GiftStatusRedeemed:
+		return ErrAlreadyRedeemed
+	case models.GiftStatusRefunded:
+		return ErrRefunded
+	case models.GiftStatusExpired:
+		return ErrExpired
+	}
+	if gift.ExpiredAt(now) {
+		return ErrExpired
+	}
+	if gift.RecipientEmail != "" {
+		if gift.RecipientEmail != NormalizeEmail(email) {
+			return ErrWrongRecipient
+		}
+		if !verified {
+			return ErrEmailNotVerified
+		}
+	}
+	return nil
+}
+
+// VerifiedEmail 用户可用于认领指定收件人礼品的邮箱。未验证的邮箱任何人都能注册，不算数，返回空
+func VerifiedEmail(user *models.User) string {
+	if user.EmailVerifiedAt == nil {
+		return ""
+	}
+	return NormalizeEmail(user.Email)
+}
+
+// Redeem 兑换礼品码并为兑换人授予使用权；兑换人已拥有该技能时拒绝兑换，兑换码保持可用。
+// 指定收件人的礼品只有邮箱与收件人一致且已验证的用户才能兑换
+func Redeem(db *gorm.DB, code string, user *models.User) (*models.Gift, error) {
+	userID := user.ID
+	code = NormalizeCode(code)
+	if code == "" {
+		return nil, ErrNotFound
+	}
+
+	var gift models.Gift
+	var expired bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).First(&gift).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		now := time.Now()
+		if err := checkRedeemable(&gift, user.Email, user.EmailVerifiedAt != nil, now); err != nil {
+			// 过期的兑换码在此更新状态，不回滚事务
+			if errors.Is(err, ErrExpired) && gift.Status == models.GiftStatusUnredeemed {
+				expired = true
+				gift.Status = models.GiftStatusExpired
+				return tx.Model(&gift).Update("status", gift.Status).Error
+			}
+			return err
+		}
+
+		owned, err := entitlement.ActiveSkills(tx, userID, []uuid.UUID{gift.SkillID}, now)
+		if err != nil {
+			return err
+		}
+		if owned[gift.SkillID] {
+			return ErrAlreadyOwned
+		}
+
+		if err := entitlement.GrantGift(tx, &gift, userID, now); err != nil {
+			return err
+		}
+		gift.Status, gift.RedeemedBy, gift.RedeemedAt = models.GiftStatusRedeemed, &userID, &now
+		return tx.Model(&gift).Updates(map[string]interface{}{
+			"status":      gift.Status,
+			"redeemed_by": userID,
+			"redeemed_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ErrExpired
+	}
+	return &gift, nil
+}
+
+// Refund 礼品订单（orderItemID不为空时为单个订单项）退款后作废兑换码，已兑换的撤销兑换人的使用权
+func Refund(tx *gorm.DB, orderID uuid.UUID, orderItemID *uuid.UUID, reason string) error {
+	query := tx.Model(&models.Gift{}).Where("order_id = ? AND status <> ?", orderID, models.GiftStatusRefunded)
+	if orderItemID != nil {
+		query = query.Where("order_item_id = ?", *orderItemID)
+	}
+	var giftIDs []uuid.UUID
+	if err := query.Pluck("id", &giftIDs).Error; err != nil {
+		return err
+	}
+	if len(giftIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.Gift{}).Where("id IN ?", giftIDs).
+		Update("status", models.GiftStatusRefunded).Error; err != nil {
+		return err
+	}
+	return entitlement.RevokeGifts(tx, giftIDs, reason)
+}
+
+// ExpireUnredeemed 将超过兑换期限的未兑换礼品标记为已过期，返回更新的数量
+func ExpireUnredeemed(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Model(&models.Gift{}).
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", models.GiftStatusUnredeemed, now).
+		Update("status", models.GiftStatusExpired)
+	return result.RowsAffected, result.Error
+}
+
+// RunExpireScheduled 定时任务入口：标记过期的礼品兑换码
+func RunExpireScheduled() error {
+	db := models.GetDB()
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+	expired, err := ExpireUnredeemed(db, time.Now())
+	if expired > 0 {
+		log.Printf("Expired %d unredeemed gifts", expired)
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package gift
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"skillhub/models"
+)
+
+func TestGenerateAndNormalizeCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		code, err := generateCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 19 || NormalizeCode(code) != code {
+			t.Fatalf("generated code %q is not in canonical form", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	tests := []struct {
+		in, want string
+	}{
+		{"abcd-efgh-jklm-npqr", "ABCD-EFGH-JKLM-NPQR"},
+		{" ABCD EFGH JKLM NPQR ", "ABCD-EFGH-JKLM-NPQR"},
+		{"ABCDEFGHJKLMNPQR", "ABCD-EFGH-JKLM-NPQR"},
+		{"ABCD-EFGH-JKLM", ""},        // 长度不足
+		{"ABCD-EFGH-JKLM-NPQ0", ""},   // 0 不在字符集中
+		{"ABCD-EFGH-JKLM-NPQR-S", ""}, // 过长
+		{"' OR 1=1 --", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeCode(tt.in); got != tt.want {
+			t.Errorf("NormalizeCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsNormalize(t *testing.T) {
+	opts := Options{RecipientEmail: "  Alice@Example.COM ", Message: " 加油 "}
+	if err := opts.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if opts.RecipientEmail != "alice@example.com" || opts.Message != "加油" || opts.Quantity != 1 {
+		t.Errorf("unexpected normalized options %+v", opts)
+	}
+
+	tests := []struct {
+		opts Options
+		want error
+	}{
+		{Options{Quantity: 5}, nil},
+		{Options{Quantity: -1}, ErrInvalidQuantity},
+		{Options{Quantity: MaxQuantity + 1}, ErrInvalidQuantity},
+		{Options{RecipientEmail: "not-an-email"}, ErrInvalidEmail},
+		{Options{RecipientEmail: "Bob <bob@example.com>"}, ErrInvalidEmail},
+		{Options{RecipientEmail: "bob@example.com", Quantity: 2}, ErrInvalidQuantity},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Normalize(); !errors.Is(err, tt.want) {
+			t.Errorf("Normalize(%+v) = %v, want %v", tt.opts, err, tt.want)
+		}
+	}
+}
+
+func TestCheckRedeemable(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		gift     models.Gift
+		email    string
+		verified bool
+		want     error
+	}{
+		{"open", models.Gift{Status: models.GiftStatusUnredeemed}, "anyone@example.com", true, nil},
+		{"before expiry", models.Gift{Status: models.GiftStatusUnredeemed, ExpiresAt: &future}, "", true, nil},
+		{"past expiry", models.Gift{Status: models.GiftStatusUnredeemed, ExpiresAt: &past}, "", true, ErrExpired},
+		{"expired", models.Gift{Status: models.GiftStatusExpired}, "", true, ErrExpired},
+		{"redeemed", models.Gift{Status: models.GiftStatusRedeemed}, "", true, ErrAlreadyRedeemed},
+		{"refunded", models.Gift{Status: models.GiftStatusRefunded}, "", true, ErrRefunded},
+		{"recipient", models.Gift{Status: models.GiftStatusUnredeemed, RecipientEmail: "alice@example.com"}, "Alice@Example.com", true, nil},
+		{"other recipient", models.Gift{Status: models.GiftStatusUnredeemed, RecipientEmail: "alice@example.com"}, "bob@example.com", true, ErrWrongRecipient},
+		{"unverified recipient", models.Gift{Status: models.GiftStatusUnredeemed, RecipientEmail: "alice@example.com"}, "alice@example.com", false, ErrEmailNotVerified},
+		{"unverified open", models.Gift{Status: models.GiftStatusUnredeemed}, "anyone@example.com", false, nil},
+	}
+	for _, tt := range tests {
+		if err := checkRedeemable(&tt.gift, tt.email, tt.verified, now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: checkRedeemable = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
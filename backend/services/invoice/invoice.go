@@ -0,0 +1,364 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotFound 发票不存在
+	ErrNotFound = errors.New("invoice not found")
+	// ErrNotInvoiceable 订单未支付或为免费订单，不开具发票
+	ErrNotInvoiceable = errors.New("order is not invoiceable")
+	// ErrAlreadyVoid 发票已作废
+	ErrAlreadyVoid = errors.New("invoice already void")
+	// ErrReasonRequired 作废或重开需要填写原因
+	ErrReasonRequired = errors.New("reason is required")
+	// ErrInvalidBuyer 开票信息不完整
+	ErrInvalidBuyer = errors.New("invalid billing details")
+)
+
+// Buyer 买方开票信息：用户的开票资料或管理员重开时填写的信息
+type Buyer struct {
+	Party       models.InvoiceParty    `json:"party"`
+	TitleType   models.FapiaoTitleType `json:"title_type"`
+	FapiaoTitle string                 `json:"fapiao_title"`
+}
+
+// Validate 校验开票信息：单位抬头必须填写抬头名称和纳税人识别号
+func (b *Buyer) Validate() error {
+	if b.TitleType == "" {
+		b.TitleType = models.FapiaoTitlePersonal
+	}
+	if !b.TitleType.IsValid() {
+		return fmt.Errorf("%w: unknown title type %q", ErrInvalidBuyer, b.TitleType)
+	}
+	if b.TitleType == models.FapiaoTitleCompany && (strings.TrimSpace(b.FapiaoTitle) == "" || strings.TrimSpace(b.Party.TaxID) == "") {
+		return fmt.Errorf("%w: company title requires fapiao_title and tax_id", ErrInvalidBuyer)
+	}
+	return nil
+}
+
+// Seller 按配置生成卖方信息
+func Seller(cfg config.InvoiceConfig) models.InvoiceParty {
+	return models.InvoiceParty{
+		Name:        cfg.SellerName,
+		Email:       cfg.SellerEmail,
+		TaxID:       cfg.SellerTaxID,
+		Address:     cfg.SellerAddr,
+		Phone:       cfg.SellerPhone,
+		BankName:    cfg.SellerBank,
+		BankAccount: cfg.SellerAcct,
+	}
+}
+
+// FormatNumber 发票号：前缀-年份-6位序号
+func FormatNumber(prefix string, year int, seq int64) string {
+	return fmt.Sprintf("%s-%d-%06d", prefix, year, seq)
+}
+
+// nextNumber 在事务中递增当年的发票序号。计数器行在事务提交前保持锁定，
+// 事务回滚时序号一并回滚，已提交的发票号因此连续无空号
+func nextNumber(tx *gorm.DB, prefix string, at time.Time) (string, error) {
+	year := at.Year()
+	var seq int64
+	err := tx.Raw(`INSERT INTO invoice_sequences (scope, last_value) VALUES (?, 1)
+		ON CONFLICT (scope) DO UPDATE SET last_value = invoice_sequences.last_value + 1
+		RETURNING last_value`, fmt.Sprintf("%s-%d", prefix, year)).Scan(&seq).Error
+	if err != nil {
+		return "", err
+	}
+	return FormatNumber(prefix, year, seq), nil
+}
+
+// BuildLines 按订单项生成发票明细，返回明细及折扣前小计、折扣合计
+func BuildLines(order *models.Order) ([]models.InvoiceLine, models.Money, models.Money) {
+	currency := order.Total.Currency
+	subtotal, discount := models.NewMoney(0, currency), models.NewMoney(0, currency)
+	lines := make([]models.InvoiceLine, 0, len(order.Items))
+	for i := range order.Items {
+		item := &order.Items[i]
+		quantity := item.Quantity
+		if quantity <= 0 {
+			quantity = 1
+		}
+		description := "Skill"
+		if item.Skill != nil && item.Skill.Name != "" {
+			description = item.Skill.Name
+		}
+		if order.SubscriptionID != nil {
+			description += "（订阅）"
+		}
+		if item.BundleID != nil {
+			description += "（套餐）"
+		}
+		lines = append(lines, models.InvoiceLine{
+			ID:          uuid.New(),
+			LineNo:      i + 1,
+			SkillID:     item.SkillID,
+			Description: description,
+			Quantity:    quantity,
+			UnitPrice:   item.Price,
+			Discount:    item.Discount,
+			Amount:      item.Subtotal(),
+		})
+		subtotal = subtotal.Add(item.Price.Mul(int64(quantity)))
+		discount = discount.Add(item.Discount)
+	}
+	return lines, subtotal, discount
+}
+
+// buyerFor 买方信息默认取用户的开票资料，没有资料时使用用户姓名和邮箱开具个人抬头
+func buyerFor(tx *gorm.DB, userID uuid.UUID) (Buyer, error) {
+	var profile models.BillingProfile
+	err := tx.Where("user_id = ?", userID).First(&profile).Error
+	if err == nil {
+		return Buyer{Party: profile.Party, TitleType: profile.TitleType, FapiaoTitle: profile.FapiaoTitle}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Buyer{}, err
+	}
+
+	var user models.User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+		return Buyer{}, err
+	}
+	name := user.Name
+	if name == "" {
+		name = user.Username
+	}
+	return Buyer{Party: models.InvoiceParty{Name: name, Email: user.Email}, TitleType: models.FapiaoTitlePersonal}, nil
+}
+
+// Issue 为已支付订单开具发票，订单已有有效发票时直接返回。调用方应在事务中
+func Issue(tx *gorm.DB, cfg config.InvoiceConfig, order *models.Order) (*models.Invoice, error) {
+	return issue(tx, cfg, order, nil, nil)
+}
+
+func issue(tx *gorm.DB, cfg config.InvoiceConfig, order *models.Order, buyer *Buyer, replaces *uuid.UUID) (*models.Invoice, error) {
+	if order.Status != models.OrderStatusPaid && order.Status != models.OrderStatusRefunded || order.Total.Amount <= 0 {
+		return nil, ErrNotInvoiceable
+	}
+
+	var existing models.Invoice
+	err := tx.Preload("Lines", orderedLines).Preload("TaxLines").Where("order_id = ? AND status = ?", order.ID, models.InvoiceStatusIssued).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var items []models.OrderItem
+	if err := tx.Preload("Skill").Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	var orderTaxLines []models.OrderTaxLine
+	if err := tx.Where("order_id = ?", order.ID).Find(&orderTaxLines).Error; err != nil {
+		return nil, err
+	}
+	snapshot := *order
+	snapshot.Items = items
+
+	if buyer == nil {
+		b, err := buyerFor(tx, order.UserID)
+		if err != nil {
+			return nil, err
+		}
+		buyer = &b
+	}
+	title := buyer.FapiaoTitle
+	if title == "" {
+		title = buyer.Party.Name
+	}
+
+	now := time.Now()
+	number, err := nextNumber(tx, cfg.Prefix, now)
+	if err != nil {
+		return nil, err
+	}
+	lines, subtotal, discount := BuildLines(&snapshot)
+	invoice := &models.Invoice{
+		ID:            uuid.New(),
+		InvoiceNo:     number,
+		OrderID:       order.ID,
+		OrderNo:       order.OrderNo,
+		UserID:        order.UserID,
+		Status:        models.InvoiceStatusIssued,
+		Buyer:         buyer.Party,
+		TitleType:     buyer.TitleType,
+		FapiaoTitle:   title,
+		Seller:        Seller(cfg),
+		Subtotal:      subtotal,
+		Discount:      discount,
+		Tax:           order.Tax,
+		TaxInclusive:  order.TaxInclusive,
+		Total:         order.Total,
+		PaymentMethod: order.PaymentMethod,
+		PaidAt:        order.PaidAt,
+		IssuedAt:      now,
+		ReplacesID:    replaces,
+	}
+	if err := tx.Omit("Lines", "TaxLines").Create(invoice).Error; err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].InvoiceID = invoice.ID
+	}
+	if len(lines) > 0 {
+		if err := tx.Create(&lines).Error; err != nil {
+			return nil, err
+		}
+	}
+	invoice.Lines = lines
+
+	for _, line := range orderTaxLines {
+		invoice.TaxLines = append(invoice.TaxLines, models.InvoiceTaxLine{ID: uuid.New(), InvoiceID: invoice.ID, TaxLine: line.TaxLine})
+	}
+	if len(invoice.TaxLines) > 0 {
+		if err := tx.Create(&invoice.TaxLines).Error; err != nil {
+			return nil, err
+		}
+	}
+	return invoice, nil
+}
+
+// orderedLines 按行号读取明细
+func orderedLines(db *gorm.DB) *gorm.DB {
+	return db.Order("line_no")
+}
+
+// ForOrder 订单的发票：返回最新一张（有效优先），尚未开具时为已支付订单补开
+func ForOrder(db *gorm.DB, cfg config.InvoiceConfig, order *models.Order) (*models.Invoice, error) {
+	var latest models.Invoice
+	err := db.Preload("Lines", orderedLines).Preload("TaxLines").
+		Where("order_id = ?", order.ID).
+		Order("CASE WHEN status = 'issued' THEN 0 ELSE 1 END, issued_at DESC").
+		First(&latest).Error
+	if err == nil {
+		return &latest, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var invoice *models.Invoice
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var locked models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", order.ID).Error; err != nil {
+			return err
+		}
+		var err error
+		invoice, err = Issue(tx, cfg, &locked)
+		return err
+	})
+	return invoice, err
+}
+
+// Load 按ID读取发票及明细
+func Load(db *gorm.DB, id uuid.UUID) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := db.Preload("Lines", orderedLines).Preload("TaxLines").First(&invoice, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// lockIssued 加锁读取发票，已作废时返回ErrAlreadyVoid
+func lockIssued(tx *gorm.DB, id uuid.UUID) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&invoice, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if invoice.Status == models.InvoiceStatusVoid {
+		return nil, ErrAlreadyVoid
+	}
+	return &invoice, nil
+}
+
+// void 作废发票，调用方应在事务中
+func void(tx *gorm.DB, invoice *models.Invoice, adminID *uuid.UUID, reason string) error {
+	now := time.Now()
+	if err := tx.Model(invoice).Updates(map[string]interface{}{
+		"status":      models.InvoiceStatusVoid,
+		"voided_at":   now,
+		"voided_by":   adminID,
+		"void_reason": reason,
+	}).Error; err != nil {
+		return err
+	}
+	invoice.Status, invoice.VoidedAt, invoice.VoidedBy, invoice.VoidReason = models.InvoiceStatusVoid, &now, adminID, reason
+	return nil
+}
+
+// Void 作废发票，作废后订单没有有效发票，可通过重开补开
+func Void(db *gorm.DB, id uuid.UUID, adminID *uuid.UUID, reason string) (*models.Invoice, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		invoice, err := lockIssued(tx, id)
+		if err != nil {
+			return err
+		}
+		return void(tx, invoice, adminID, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Load(db, id)
+}
+
+// Reissue 重开发票：作废原发票（已作废的直接补开），以新发票号重新开具。
+// buyer为空时使用用户当前的开票资料
+func Reissue(db *gorm.DB, cfg config.InvoiceConfig, id uuid.UUID, adminID *uuid.UUID, reason string, buyer *Buyer) (*models.Invoice, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if buyer != nil {
+		if err := buyer.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var reissued *models.Invoice
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var original models.Invoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", original.OrderID).Error; err != nil {
+			return err
+		}
+		if original.Status == models.InvoiceStatusIssued {
+			if err := void(tx, &original, adminID, "reissued: "+reason); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		reissued, err = issue(tx, cfg, &order, buyer, &original.ID)
+		return err
+	})
+	return reissued, err
+}
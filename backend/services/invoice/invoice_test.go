@@ -0,0 +1,149 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func testInvoice() *models.Invoice {
+	paidAt := time.Date(2026, 4, 2, 8, 0, 0, 0, time.UTC)
+	return &models.Invoice{
+		InvoiceNo:   FormatNumber("INV", 2026, 42),
+		OrderNo:     "ORD1",
+		Status:      models.InvoiceStatusIssued,
+		Buyer:       models.InvoiceParty{Name: "张三", TaxID: "91310000MA1FL0000X"},
+		TitleType:   models.FapiaoTitleCompany,
+		FapiaoTitle: "上海示例科技有限公司 <b>",
+		Seller:      models.InvoiceParty{Name: "SkillHub"},
+		Subtotal:    models.NewMoney(3000, "CNY"),
+		Discount:    models.NewMoney(500, "CNY"),
+		Total:       models.NewMoney(2500, "CNY"),
+		PaidAt:      &paidAt,
+		IssuedAt:    paidAt,
+		Lines: []models.InvoiceLine{
+			{LineNo: 1, Description: "代码审查助手", Quantity: 1, UnitPrice: models.NewMoney(3000, "CNY"),
+				Discount: models.NewMoney(500, "CNY"), Amount: models.NewMoney(2500, "CNY")},
+		},
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	if got := FormatNumber("INV", 2026, 42); got != "INV-2026-000042" {
+		t.Errorf("unexpected invoice number %s", got)
+	}
+}
+
+func TestBuildLines(t *testing.T) {
+	skillID := uuid.New()
+	order := &models.Order{
+		Total: models.NewMoney(2300, "USD"),
+		Items: []models.OrderItem{
+			{SkillID: &skillID, Price: models.NewMoney(1000, "USD"), Quantity: 2, Discount: models.NewMoney(200, "USD"),
+				Skill: &models.Skill{Name: "Linter"}},
+			{Price: models.NewMoney(500, "USD")},
+		},
+	}
+
+	lines, subtotal, discount := BuildLines(order)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].Description != "Linter" || lines[0].Amount.Amount != 1800 || lines[0].LineNo != 1 {
+		t.Errorf("unexpected first line %+v", lines[0])
+	}
+	if lines[1].Quantity != 1 || lines[1].Amount.Amount != 500 {
+		t.Errorf("unexpected second line %+v", lines[1])
+	}
+	if subtotal.Amount != 2500 || discount.Amount != 200 || subtotal.Sub(discount).Amount != order.Total.Amount {
+		t.Errorf("unexpected subtotal %s discount %s", subtotal, discount)
+	}
+}
+
+func TestBuyerValidate(t *testing.T) {
+	personal := &Buyer{Party: models.InvoiceParty{Name: "张三"}}
+	if err := personal.Validate(); err != nil || personal.TitleType != models.FapiaoTitlePersonal {
+		t.Errorf("expected personal title by default, got %v %s", err, personal.TitleType)
+	}
+
+	company := &Buyer{TitleType: models.FapiaoTitleCompany, FapiaoTitle: "示例公司"}
+	if err := company.Validate(); !errors.Is(err, ErrInvalidBuyer) {
+		t.Errorf("expected company title without tax id to be rejected, got %v", err)
+	}
+	company.Party.TaxID = "91310000MA1FL0000X"
+	if err := company.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	body, err := RenderHTML(testInvoice())
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(body)
+	for _, want := range []string{"INV-2026-000042", "91310000MA1FL0000X", "代码审查助手", "25.00 CNY", "&lt;b&gt;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected HTML to contain %q", want)
+		}
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	pdf := RenderPDF(testInvoice())
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	if !bytes.Contains(pdf, []byte("<"+encodeUCS2("代码审查助手")+">")) {
+		t.Error("expected line description in content stream")
+	}
+
+	// 交叉引用表中的偏移量必须指向对应对象
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[offset:offset+10])
+		}
+	}
+}
+
+func TestRenderTaxLines(t *testing.T) {
+	invoice := testInvoice()
+	invoice.Tax = models.NewMoney(399, "EUR")
+	invoice.TaxInclusive = true
+	invoice.TaxLines = []models.InvoiceTaxLine{
+		{TaxLine: models.TaxLine{Name: "VAT", Country: "DE", RateBps: 1900, Inclusive: true,
+			Taxable: models.NewMoney(2101, "EUR"), Amount: models.NewMoney(399, "EUR")}},
+		{TaxLine: models.TaxLine{Name: "QST", Country: "CA", Region: "QC", RateBps: 998, ReverseCharge: true}},
+	}
+
+	body, err := RenderHTML(invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"VAT 19%（含税 incl.）", "3.99 EUR", "QC QST 9.98% 反向征收 Reverse charge"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected HTML to contain %q", want)
+		}
+	}
+	if pdf := RenderPDF(invoice); !bytes.Contains(pdf, []byte("<"+encodeUCS2("VAT 19%（含税 incl.）")+">")) {
+		t.Error("expected tax line in PDF content stream")
+	}
+}
@@ -0,0 +1,167 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4页面尺寸及边距（单位pt）
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	marginTop    = 800
+	marginBottom = 60
+)
+
+// pdfDoc 最小化的PDF生成器：仅支持单一字体的文本和横线，按行自上而下排版。
+// 使用阅读器内置的STSong-Light（Adobe-GB1）字体，无需嵌入字体即可显示中文
+type pdfDoc struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+func newPDF() *pdfDoc {
+	d := &pdfDoc{}
+	d.newPage()
+	return d
+}
+
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = marginTop
+}
+
+func (d *pdfDoc) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// advance 下移一行，剩余空间不足时换页
+func (d *pdfDoc) advance(height float64) {
+	if d.y-height < marginBottom {
+		d.newPage()
+	}
+	d.y -= height
+}
+
+// gap 增加段落间距
+func (d *pdfDoc) gap(height float64) {
+	d.y -= height
+}
+
+// text 在新的一行左对齐输出文本
+func (d *pdfDoc) text(x, size float64, s string) {
+	d.advance(size * 1.5)
+	d.show(x, size, s)
+}
+
+// row 输出表格行：第一列在columns[0]左对齐，其余各列在columns[i]右对齐
+func (d *pdfDoc) row(columns []float64, size float64, cells ...string) {
+	d.advance(size * 1.6)
+	for i, cell := range cells {
+		if i >= len(columns) {
+			break
+		}
+		if i == 0 {
+			maxWidth := float64(pageWidth) - columns[0]
+			if len(columns) > 2 {
+				maxWidth = columns[1] - columns[0] - 40
+			}
+			d.show(columns[0], size, truncate(cell, size, maxWidth))
+			continue
+		}
+		d.show(columns[i]-textWidth(cell, size), size, cell)
+	}
+}
+
+// rule 在当前位置下方画一条横线
+func (d *pdfDoc) rule() {
+	d.advance(4)
+	fmt.Fprintf(d.page(), "0.5 w 50 %.2f m %d %.2f l S\n", d.y, pageWidth-50, d.y)
+}
+
+func (d *pdfDoc) show(x, size float64, s string) {
+	fmt.Fprintf(d.page(), "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, d.y, encodeUCS2(s))
+}
+
+// textWidth 估算文本宽度：半角字符按半个字宽，其余按一个字宽
+func textWidth(s string, size float64) float64 {
+	var width float64
+	for _, r := range s {
+		if r < 0x80 {
+			width += size / 2
+		} else {
+			width += size
+		}
+	}
+	return width
+}
+
+// truncate 超出宽度的文本截断并以省略号结尾
+func truncate(s string, size, maxWidth float64) string {
+	if textWidth(s, size) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes), size)+size*1.5 > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// encodeUCS2 按UniGB-UCS2-H编码文本，超出基本平面的字符替换为问号
+func encodeUCS2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// bytes 输出完整的PDF文件
+func (d *pdfDoc) bytes() []byte {
+	// 对象编号：1 目录，2 页面树，3-5 字体，之后每页两个对象（页面、内容流）
+	const fontObjects = 3
+	firstPage := 3 + fontObjects
+	var objects []string
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*2)
+	}
+
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [4 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light"+
+			" /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >>"+
+			" /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880]"+
+			" /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	)
+	for i, content := range d.pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, firstPage+i*2+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
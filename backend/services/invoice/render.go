@@ -0,0 +1,168 @@
+package invoice
+
+import (
+	"bytes"
+	"html/template"
+	"strconv"
+	"time"
+
+	"skillhub/models"
+)
+
+const dateLayout = "2006-01-02"
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"date": func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(dateLayout)
+	},
+	"money":    func(m models.Money) string { return m.Decimal() + " " + m.Currency },
+	"taxLabel": taxLabel,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.InvoiceNo}}</title>
+<style>
+body { font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; color: #222; max-width: 800px; margin: 32px auto; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+.void { color: #c00; font-weight: bold; }
+.parties { display: flex; gap: 32px; margin: 24px 0; }
+.parties div { flex: 1; }
+table { width: 100%; border-collapse: collapse; }
+th, td { padding: 6px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+td.num, th.num { text-align: right; }
+.totals td { border: none; }
+</style>
+</head>
+<body>
+<h1>收据 / Receipt</h1>
+<p>发票号 Invoice No.: {{.InvoiceNo}}<br>
+订单号 Order No.: {{.OrderNo}}<br>
+开具日期 Issued: {{.IssuedAt.Format "2006-01-02"}}{{with .PaidAt}}<br>
+支付日期 Paid: {{date .}}{{end}}{{if .PaymentMethod}}<br>
+支付方式 Payment: {{.PaymentMethod}}{{end}}</p>
+{{if eq .Status "void"}}<p class="void">已作废 VOID{{with .VoidReason}}：{{.}}{{end}}</p>{{end}}
+<div class="parties">
+<div>
+<strong>开票方 Seller</strong><br>
+{{.Seller.Name}}{{with .Seller.TaxID}}<br>纳税人识别号 Tax ID: {{.}}{{end}}{{with .Seller.Address}}<br>{{.}}{{end}}{{with .Seller.Phone}}<br>{{.}}{{end}}{{with .Seller.Email}}<br>{{.}}{{end}}{{with .Seller.BankName}}<br>开户行 Bank: {{.}}{{end}}{{with .Seller.BankAccount}}<br>账号 Account: {{.}}{{end}}
+</div>
+<div>
+<strong>购买方 Buyer</strong><br>
+抬头 Title: {{.FapiaoTitle}}{{if eq .TitleType "company"}}（单位）{{else}}（个人）{{end}}{{with .Buyer.TaxID}}<br>纳税人识别号 Tax ID: {{.}}{{end}}{{if ne .Buyer.Name .FapiaoTitle}}{{with .Buyer.Name}}<br>{{.}}{{end}}{{end}}{{with .Buyer.Address}}<br>{{.}}{{end}}{{with .Buyer.Phone}}<br>{{.}}{{end}}{{with .Buyer.Email}}<br>{{.}}{{end}}{{with .Buyer.BankName}}<br>开户行 Bank: {{.}}{{end}}{{with .Buyer.BankAccount}}<br>账号 Account: {{.}}{{end}}
+</div>
+</div>
+<table>
+<thead><tr><th>项目 Item</th><th class="num">数量 Qty</th><th class="num">单价 Unit price</th><th class="num">折扣 Discount</th><th class="num">金额 Amount</th></tr></thead>
+<tbody>
+{{range .Lines}}<tr><td>{{.Description}}</td><td class="num">{{.Quantity}}</td><td class="num">{{money .UnitPrice}}</td><td class="num">{{money .Discount}}</td><td class="num">{{money .Amount}}</td></tr>
+{{end}}</tbody>
+</table>
+<table class="totals">
+<tr><td class="num">小计 Subtotal</td><td class="num">{{money .Subtotal}}</td></tr>
+<tr><td class="num">折扣 Discount</td><td class="num">-{{money .Discount}}</td></tr>
+{{range .TaxLines}}<tr><td class="num">{{taxLabel .TaxLine}}</td><td class="num">{{money .Amount}}</td></tr>
+{{end}}<tr><td class="num"><strong>合计 Total</strong></td><td class="num"><strong>{{money .Total}}</strong></td></tr>
+</table>
+</body>
+</html>
+`))
+
+// RenderHTML 生成可打印的HTML收据
+func RenderHTML(invoice *models.Invoice) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, invoice); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderPDF 生成PDF收据，版式与HTML一致
+func RenderPDF(invoice *models.Invoice) []byte {
+	doc := newPDF()
+	money := func(m models.Money) string { return m.Decimal() + " " + m.Currency }
+
+	doc.text(50, 18, "收据 / Receipt")
+	doc.gap(6)
+	doc.text(50, 10, "发票号 Invoice No.: "+invoice.InvoiceNo)
+	doc.text(50, 10, "订单号 Order No.: "+invoice.OrderNo)
+	doc.text(50, 10, "开具日期 Issued: "+invoice.IssuedAt.Format(dateLayout))
+	if invoice.PaidAt != nil {
+		doc.text(50, 10, "支付日期 Paid: "+invoice.PaidAt.Format(dateLayout))
+	}
+	if invoice.PaymentMethod != "" {
+		doc.text(50, 10, "支付方式 Payment: "+invoice.PaymentMethod)
+	}
+	if invoice.Status == models.InvoiceStatusVoid {
+		doc.text(50, 12, "已作废 VOID "+invoice.VoidReason)
+	}
+
+	doc.gap(10)
+	doc.text(50, 11, "开票方 Seller")
+	doc.partyLines(invoice.Seller.Name, &invoice.Seller)
+	doc.gap(8)
+	doc.text(50, 11, "购买方 Buyer")
+	title := "抬头 Title: " + invoice.FapiaoTitle + "（个人）"
+	if invoice.TitleType == models.FapiaoTitleCompany {
+		title = "抬头 Title: " + invoice.FapiaoTitle + "（单位）"
+	}
+	buyerName := invoice.Buyer.Name
+	if buyerName == invoice.FapiaoTitle {
+		buyerName = ""
+	}
+	doc.text(50, 10, title)
+	doc.partyLines(buyerName, &invoice.Buyer)
+
+	doc.gap(12)
+	columns := []float64{50, 300, 380, 460, 545}
+	doc.row(columns, 10, "项目 Item", "数量 Qty", "单价 Unit", "折扣 Disc.", "金额 Amount")
+	doc.rule()
+	for _, line := range invoice.Lines {
+		doc.row(columns, 10, line.Description, strconv.Itoa(line.Quantity), money(line.UnitPrice), money(line.Discount), money(line.Amount))
+	}
+	doc.rule()
+	doc.row([]float64{380, 545}, 10, "小计 Subtotal", money(invoice.Subtotal))
+	doc.row([]float64{380, 545}, 10, "折扣 Discount", "-"+money(invoice.Discount))
+	for _, line := range invoice.TaxLines {
+		doc.row([]float64{300, 545}, 10, taxLabel(line.TaxLine), money(line.Amount))
+	}
+	doc.row([]float64{380, 545}, 11, "合计 Total", money(invoice.Total))
+
+	return doc.bytes()
+}
+
+// taxLabel 税费行的名称，如「VAT 19%（含税 incl.）」；反向征收时注明由买方申报
+func taxLabel(line models.TaxLine) string {
+	label := line.Name + " " + strconv.FormatFloat(float64(line.RateBps)/100, 'f', -1, 64) + "%"
+	if line.Region != "" {
+		label = line.Region + " " + label
+	}
+	switch {
+	case line.ReverseCharge:
+		label += " 反向征收 Reverse charge"
+	case line.Inclusive:
+		label += "（含税 incl.）"
+	}
+	return label
+}
+
+// partyLines 输出买方或卖方的各项信息，空值跳过
+func (d *pdfDoc) partyLines(name string, party *models.InvoiceParty) {
+	fields := []struct{ label, value string }{
+		{"", name},
+		{"纳税人识别号 Tax ID: ", party.TaxID},
+		{"", party.Address},
+		{"", party.Phone},
+		{"", party.Email},
+		{"开户行 Bank: ", party.BankName},
+		{"账号 Account: ", party.BankAccount},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			d.text(50, 10, f.label+f.value)
+		}
+	}
+}
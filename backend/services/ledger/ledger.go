@@ -0,0 +1,367 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUnbalanced 分录各币种借贷不相等
+	ErrUnbalanced = errors.New("journal entry does not balance")
+	// ErrEntryNotFound 分录不存在
+	ErrEntryNotFound = errors.New("journal entry not found")
+	// ErrAlreadyReversed 分录已被冲销
+	ErrAlreadyReversed = errors.New("journal entry already reversed")
+)
+
+// 科目代码
+const (
+	AccountSales            = "sales"             // 销售收入
+	AccountSalesRefunds     = "sales_refunds"     // 销售退款，收入的抵减科目
+	AccountPaymentFees      = "payment_fees"      // 支付渠道手续费
+	AccountPublisherShare   = "publisher_share"   // 发布者分成（销售收入扣除平台抽成的部分）
+	AccountPublisherPayable = "publisher_payable" // 应付发布者
+	AccountBank             = "bank"              // 银行存款，发布者付款从此支出
+	AccountTaxPayable       = "tax_payable"       // 应交税费，代收的税款
+
+	gatewayPrefix = "gateway:"
+)
+
+// chart 固定科目表
+var chart = map[string]struct {
+	name string
+	typ  models.LedgerAccountType
+}{
+	AccountSales:            {"销售收入", models.LedgerAccountRevenue},
+	AccountSalesRefunds:     {"销售退款", models.LedgerAccountRevenue},
+	AccountPaymentFees:      {"支付手续费", models.LedgerAccountExpense},
+	AccountPublisherShare:   {"发布者分成", models.LedgerAccountExpense},
+	AccountPublisherPayable: {"应付发布者", models.LedgerAccountLiability},
+	AccountBank:             {"银行存款", models.LedgerAccountAsset},
+	AccountTaxPayable:       {"应交税费", models.LedgerAccountLiability},
+}
+
+// GatewayAccount 支付渠道待结算资金科目，每个渠道一个
+func GatewayAccount(channel string) string {
+	if channel == "" {
+		channel = "unknown"
+	}
+	return gatewayPrefix + channel
+}
+
+// Line 分录行，借方为正、贷方为负
+type Line struct {
+	Account string
+	Amount  models.Money
+}
+
+// Debit 借记科目
+func Debit(account string, amount models.Money) Line {
+	return Line{Account: account, Amount: amount}
+}
+
+// Credit 贷记科目
+func Credit(account string, amount models.Money) Line {
+	return Line{Account: account, Amount: models.NewMoney(-amount.Amount, amount.Currency)}
+}
+
+// Entry 待过账的分录
+type Entry struct {
+	Event     models.JournalEvent
+	Reference string
+	OrderID   *uuid.UUID
+	Memo      string
+	CreatedBy *uuid.UUID
+	PostedAt  time.Time
+	Lines     []Line
+}
+
+// validate 去掉金额为零的分录行并检查各币种借贷相等
+func validate(lines []Line) ([]Line, error) {
+	kept := make([]Line, 0, len(lines))
+	sums := make(map[string]int64)
+	for _, line := range lines {
+		if line.Amount.Amount == 0 {
+			continue
+		}
+		if line.Account == "" || line.Amount.Currency == "" {
+			return nil, fmt.Errorf("%w: line without account or currency", ErrUnbalanced)
+		}
+		sums[line.Amount.Currency] += line.Amount.Amount
+		kept = append(kept, line)
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return nil, fmt.Errorf("%w: %s off by %d", ErrUnbalanced, currency, sum)
+		}
+	}
+	return kept, nil
+}
+
+// Post 过账分录。金额全为零时不记账；同一事件和来源已过账时直接返回nil，不重复记账。调用方应在事务中
+func Post(tx *gorm.DB, entry Entry) (*models.JournalEntry, error) {
+	lines, err := validate(entry.Lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	postedAt := entry.PostedAt
+	if postedAt.IsZero() {
+		postedAt = time.Now()
+	}
+	journal := &models.JournalEntry{
+		ID:        uuid.New(),
+		EntryNo:   generateEntryNo(postedAt),
+		Event:     entry.Event,
+		Reference: entry.Reference,
+		OrderID:   entry.OrderID,
+		Memo:      entry.Memo,
+		CreatedBy: entry.CreatedBy,
+		PostedAt:  postedAt,
+	}
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event"}, {Name: "reference"}},
+		DoNothing: true,
+	}).Omit("Postings").Create(journal)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	for _, line := range lines {
+		accountID, err := ensureAccount(tx, line.Account)
+		if err != nil {
+			return nil, err
+		}
+		posting := models.LedgerPosting{
+			ID:        uuid.New(),
+			EntryID:   journal.ID,
+			AccountID: accountID,
+			Amount:    line.Amount,
+		}
+		if err := tx.Create(&posting).Error; err != nil {
+			return nil, err
+		}
+		journal.Postings = append(journal.Postings, posting)
+	}
+	return journal, nil
+}
+
+// ensureAccount 按科目代码取得科目ID，不存在时按科目表创建
+func ensureAccount(tx *gorm.DB, code string) (uuid.UUID, error) {
+	name, typ := code, models.LedgerAccountAsset
+	if def, ok := chart[code]; ok {
+		name, typ = def.name, def.typ
+	} else if channel, ok := strings.CutPrefix(code, gatewayPrefix); ok {
+		name = channel + "待结算资金"
+	} else {
+		return uuid.Nil, fmt.Errorf("unknown ledger account %q", code)
+	}
+
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "code"}}, DoNothing: true}).
+		Create(&models.LedgerAccount{ID: uuid.New(), Code: code, Name: name, Type: typ}).Error; err != nil {
+		return uuid.Nil, err
+	}
+	var account models.LedgerAccount
+	if err := tx.Where("code = ?", code).First(&account).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return account.ID, nil
+}
+
+func generateEntryNo(now time.Time) string {
+	return "JE" + now.Format("20060102150405") + strings.ToUpper(uuid.New().String()[:8])
+}
+
+// FeeBps 支付渠道的手续费率（基点）
+func FeeBps(channel string) int {
+	if config.AppConfig != nil {
+		return config.AppConfig.Payment.FeeBps[channel]
+	}
+	return 0
+}
+
+// Fee 按费率计算手续费，四舍五入到最小单位
+func Fee(amount models.Money, bps int) models.Money {
+	return models.NewMoney((amount.Amount*int64(bps)+5000)/10000, amount.Currency)
+}
+
+// RecordPayment 记录网关收款（借：渠道待结算资金，贷：销售收入）及按渠道费率计算的手续费。调用方应在事务中
+func RecordPayment(tx *gorm.DB, txn *models.Transaction) error {
+	if txn.Status != models.TransactionStatusSuccess || txn.Amount.Amount <= 0 {
+		return nil
+	}
+	gateway := GatewayAccount(txn.PaymentChannel)
+	orderID := txn.OrderID
+	if _, err := Post(tx, Entry{
+		Event:     models.JournalEventPayment,
+		Reference: txn.ID.String(),
+		OrderID:   &orderID,
+		Memo:      fmt.Sprintf("%s payment %s", txn.PaymentChannel, txn.TransactionID),
+		Lines: []Line{
+			Debit(gateway, txn.Amount),
+			Credit(AccountSales, txn.Amount),
+		},
+	}); err != nil {
+		return err
+	}
+
+	fee := Fee(txn.Amount, FeeBps(txn.PaymentChannel))
+	_, err := Post(tx, Entry{
+		Event:     models.JournalEventFee,
+		Reference: txn.ID.String(),
+		OrderID:   &orderID,
+		Memo:      fmt.Sprintf("%s fee for %s", txn.PaymentChannel, txn.TransactionID),
+		Lines: []Line{
+			Debit(AccountPaymentFees, fee),
+			Credit(gateway, fee),
+		},
+	})
+	return err
+}
+
+// RecordRefund 记录退款成功（借：销售退款，贷：渠道待结算资金）。手续费通常不随退款退还，不冲回。调用方应在事务中
+func RecordRefund(tx *gorm.DB, refund *models.Refund) error {
+	orderID := refund.OrderID
+	postedAt := time.Now()
+	if refund.CompletedAt != nil {
+		postedAt = *refund.CompletedAt
+	}
+	_, err := Post(tx, Entry{
+		Event:     models.JournalEventRefund,
+		Reference: refund.ID.String(),
+		OrderID:   &orderID,
+		Memo:      "refund " + refund.RefundNo,
+		PostedAt:  postedAt,
+		Lines: []Line{
+			Debit(AccountSalesRefunds, refund.Amount),
+			Credit(GatewayAccount(refund.PaymentChannel), refund.Amount),
+		},
+	})
+	return err
+}
+
+// RecordTax 订单支付后将代收的税额从销售收入转入应交税费（借：销售收入，贷：应交税费）。调用方应在事务中
+func RecordTax(tx *gorm.DB, order *models.Order) error {
+	orderID := order.ID
+	_, err := Post(tx, Entry{
+		Event:     models.JournalEventTax,
+		Reference: order.ID.String(),
+		OrderID:   &orderID,
+		Memo:      "tax collected on " + order.OrderNo,
+		Lines: []Line{
+			Debit(AccountSales, order.Tax),
+			Credit(AccountTaxPayable, order.Tax),
+		},
+	})
+	return err
+}
+
+// RecordTaxRefund 退款中包含的税额冲回（借：应交税费，贷：销售退款）。调用方应在事务中
+func RecordTaxRefund(tx *gorm.DB, refund *models.Refund, amount models.Money) error {
+	orderID := refund.OrderID
+	_, err := Post(tx, Entry{
+		Event:     models.JournalEventTax,
+		Reference: refund.ID.String(),
+		OrderID:   &orderID,
+		Memo:      "tax refunded with " + refund.RefundNo,
+		Lines: []Line{
+			Debit(AccountTaxPayable, amount),
+			Credit(AccountSalesRefunds, amount),
+		},
+	})
+	return err
+}
+
+// RecordEarning 记录发布者分成（借：发布者分成，贷：应付发布者），扣回的金额为负即反向记账。调用方应在事务中
+func RecordEarning(tx *gorm.DB, earning *models.PublisherEarning) error {
+	orderID := earning.OrderID
+	_, err := Post(tx, Entry{
+		Event:     models.JournalEventCommission,
+		Reference: earning.ID.String(),
+		OrderID:   &orderID,
+		Memo:      fmt.Sprintf("publisher %s %s at %d bps", earning.PublisherID, earning.Kind, earning.RateBps),
+		Lines: []Line{
+			Debit(AccountPublisherShare, earning.Amount),
+			Credit(AccountPublisherPayable, earning.Amount),
+		},
+	})
+	return err
+}
+
+// RecordPayout 记录向发布者付款（借：应付发布者，贷：银行存款）。调用方应在事务中
+func RecordPayout(tx *gorm.DB, payout *models.Payout) error {
+	_, err := Post(tx, Entry{
+		Event:     models.JournalEventPayout,
+		Reference: payout.ID.String(),
+		Memo:      fmt.Sprintf("payout to publisher %s", payout.PublisherID),
+		Lines: []Line{
+			Debit(AccountPublisherPayable, payout.Amount),
+			Credit(AccountBank, payout.Amount),
+		},
+	})
+	return err
+}
+
+// Reverse 过账一笔借贷相反的分录冲销原分录，每笔分录只能冲销一次，冲销分录本身不能再冲销
+func Reverse(db *gorm.DB, id uuid.UUID, memo string, createdBy *uuid.UUID) (*models.JournalEntry, error) {
+	var reversal *models.JournalEntry
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var original models.JournalEntry
+		if err := tx.Preload("Postings.Account").First(&original, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEntryNotFound
+			}
+			return err
+		}
+		if original.Event == models.JournalEventReversal {
+			return fmt.Errorf("%w: %s is itself a reversal", ErrAlreadyReversed, original.EntryNo)
+		}
+
+		lines := make([]Line, 0, len(original.Postings))
+		for _, posting := range original.Postings {
+			lines = append(lines, Line{
+				Account: posting.Account.Code,
+				Amount:  models.NewMoney(-posting.Amount.Amount, posting.Amount.Currency),
+			})
+		}
+		note := "reversal of " + original.EntryNo
+		if memo != "" {
+			note += ": " + memo
+		}
+		var err error
+		reversal, err = Post(tx, Entry{
+			Event:     models.JournalEventReversal,
+			Reference: original.ID.String(),
+			OrderID:   original.OrderID,
+			Memo:      note,
+			CreatedBy: createdBy,
+			Lines:     lines,
+		})
+		if err != nil {
+			return err
+		}
+		if reversal == nil {
+			return ErrAlreadyReversed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
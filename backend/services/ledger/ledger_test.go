@@ -0,0 +1,78 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"skillhub/models"
+)
+
+func TestValidate(t *testing.T) {
+	usd := models.NewMoney(1000, "USD")
+	lines, err := validate([]Line{
+		Debit(GatewayAccount("stripe"), usd),
+		Credit(AccountSales, usd),
+		Debit(AccountPaymentFees, models.NewMoney(0, "USD")),
+	})
+	if err != nil || len(lines) != 2 {
+		t.Fatalf("validate balanced entry = %v, %v; want 2 lines", lines, err)
+	}
+
+	// 各币种分别平衡
+	_, err = validate([]Line{
+		Debit(GatewayAccount("stripe"), usd),
+		Credit(AccountSales, models.NewMoney(1000, "EUR")),
+	})
+	if !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("expected ErrUnbalanced for mixed currencies, got %v", err)
+	}
+
+	_, err = validate([]Line{
+		Debit(AccountSalesRefunds, usd),
+		Credit(GatewayAccount("stripe"), models.NewMoney(999, "USD")),
+	})
+	if !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("expected ErrUnbalanced, got %v", err)
+	}
+}
+
+func TestFee(t *testing.T) {
+	tests := []struct {
+		amount int64
+		bps    int
+		want   int64
+	}{
+		{10000, 60, 60},
+		{999, 290, 29}, // 28.971
+		{1000, 0, 0},
+		{25, 349, 1}, // 0.8725
+	}
+	for _, tt := range tests {
+		if got := Fee(models.NewMoney(tt.amount, "USD"), tt.bps); got.Amount != tt.want {
+			t.Errorf("Fee(%d, %d) = %d, want %d", tt.amount, tt.bps, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestTrialTotals(t *testing.T) {
+	balances := []AccountBalance{
+		{Code: "gateway:alipay", Currency: "CNY", Debit: models.NewMoney(10000, "CNY"), Credit: models.NewMoney(1060, "CNY")},
+		{Code: AccountPaymentFees, Currency: "CNY", Debit: models.NewMoney(60, "CNY")},
+		{Code: AccountSales, Currency: "CNY", Credit: models.NewMoney(10000, "CNY")},
+		{Code: AccountSalesRefunds, Currency: "CNY", Debit: models.NewMoney(1000, "CNY")},
+		{Code: AccountSales, Currency: "USD", Credit: models.NewMoney(500, "USD")},
+		{Code: "gateway:stripe", Currency: "USD", Debit: models.NewMoney(500, "USD")},
+	}
+	totals, balanced := trialTotals(balances)
+	if !balanced || len(totals) != 2 {
+		t.Fatalf("trialTotals = %v, %v; want 2 balanced currencies", totals, balanced)
+	}
+	if totals[0].Currency != "CNY" || totals[0].Debit.Amount != 10000 || totals[0].Credit.Amount != 10000 {
+		t.Errorf("unexpected CNY totals %+v", totals[0])
+	}
+
+	balances[1].Debit.Amount = 61
+	if _, balanced := trialTotals(balances); balanced {
+		t.Error("expected an out-of-balance ledger to be reported")
+	}
+}
@@ -0,0 +1,175 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"time"
+
+	"skillhub/models"
+
+	"gorm.io/gorm"
+)
+
+// AccountBalance 科目在某币种下截至某时点的发生额和余额
+type AccountBalance struct {
+	Code     string                   `json:"code"`
+	Name     string                   `json:"name"`
+	Type     models.LedgerAccountType `json:"type"`
+	Currency string                   `json:"currency"`
+	Debit    models.Money             `json:"debit"`   // 借方发生额
+	Credit   models.Money             `json:"credit"`  // 贷方发生额
+	Balance  models.Money             `json:"balance"` // 按科目余额方向计算的余额，为负表示反向余额
+}
+
+// CurrencyTotal 试算平衡表某币种的借贷余额合计
+type CurrencyTotal struct {
+	Currency string       `json:"currency"`
+	Debit    models.Money `json:"debit"`
+	Credit   models.Money `json:"credit"`
+}
+
+// TrialBalance 试算平衡表，各币种借方余额合计应等于贷方余额合计
+type TrialBalance struct {
+	At       time.Time        `json:"at"`
+	Accounts []AccountBalance `json:"accounts"`
+	Totals   []CurrencyTotal  `json:"totals"`
+	Balanced bool             `json:"balanced"`
+}
+
+// Balances 按科目和币种汇总截至at（含）已过账的分录行；codes不为空时只返回指定科目
+func Balances(db *gorm.DB, at time.Time, codes ...string) ([]AccountBalance, error) {
+	var rows []struct {
+		Code     string
+		Name     string
+		Type     models.LedgerAccountType
+		Currency string
+		Debit    int64
+		Credit   int64
+	}
+	query := db.Model(&models.LedgerPosting{}).
+		Select(`ledger_accounts.code, ledger_accounts.name, ledger_accounts.type,
+			ledger_postings.currency,
+			COALESCE(SUM(CASE WHEN ledger_postings.amount_minor > 0 THEN ledger_postings.amount_minor END), 0) AS debit,
+			COALESCE(SUM(CASE WHEN ledger_postings.amount_minor < 0 THEN -ledger_postings.amount_minor END), 0) AS credit`).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_postings.account_id").
+		Joins("JOIN journal_entries ON journal_entries.id = ledger_postings.entry_id").
+		Where("journal_entries.posted_at <= ?", at)
+	if len(codes) > 0 {
+		query = query.Where("ledger_accounts.code IN ?", codes)
+	}
+	err := query.Group("ledger_accounts.code, ledger_accounts.name, ledger_accounts.type, ledger_postings.currency").
+		Order("ledger_accounts.code, ledger_postings.currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]AccountBalance, len(rows))
+	for i, row := range rows {
+		net := row.Debit - row.Credit
+		if !row.Type.DebitNormal() {
+			net = -net
+		}
+		balances[i] = AccountBalance{
+			Code:     row.Code,
+			Name:     row.Name,
+			Type:     row.Type,
+			Currency: row.Currency,
+			Debit:    models.NewMoney(row.Debit, row.Currency),
+			Credit:   models.NewMoney(row.Credit, row.Currency),
+			Balance:  models.NewMoney(net, row.Currency),
+		}
+	}
+	return balances, nil
+}
+
+// GetTrialBalance 生成截至at的试算平衡表
+func GetTrialBalance(db *gorm.DB, at time.Time) (*TrialBalance, error) {
+	balances, err := Balances(db, at)
+	if err != nil {
+		return nil, err
+	}
+	totals, balanced := trialTotals(balances)
+	return &TrialBalance{At: at, Accounts: balances, Totals: totals, Balanced: balanced}, nil
+}
+
+// trialTotals 按币种合计各科目的借方余额和贷方余额
+func trialTotals(balances []AccountBalance) ([]CurrencyTotal, bool) {
+	byCurrency := make(map[string]*CurrencyTotal)
+	for _, b := range balances {
+		total, ok := byCurrency[b.Currency]
+		if !ok {
+			total = &CurrencyTotal{
+				Currency: b.Currency,
+				Debit:    models.NewMoney(0, b.Currency),
+				Credit:   models.NewMoney(0, b.Currency),
+			}
+			byCurrency[b.Currency] = total
+		}
+		if net := b.Debit.Amount - b.Credit.Amount; net > 0 {
+			total.Debit.Amount += net
+		} else {
+			total.Credit.Amount -= net
+		}
+	}
+
+	totals := make([]CurrencyTotal, 0, len(byCurrency))
+	balanced := true
+	for _, total := range byCurrency {
+		totals = append(totals, *total)
+		if total.Debit.Amount != total.Credit.Amount {
+			balanced = false
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Currency < totals[j].Currency })
+	return totals, balanced
+}
+
+// Export 导出过账时间在[from, to)内的分录行为CSV，每行一个分录行，供会计系统导入
+func Export(db *gorm.DB, from, to time.Time) ([]byte, error) {
+	var entries []models.JournalEntry
+	if err := db.Preload("Postings", func(db *gorm.DB) *gorm.DB { return db.Order("amount_minor DESC") }).
+		Preload("Postings.Account").
+		Where("posted_at >= ? AND posted_at < ?", from, to).
+		Order("posted_at, entry_no").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"entry_no", "posted_at", "event", "reference", "order_id",
+		"account_code", "account_name", "currency", "debit", "credit", "memo"})
+	for _, entry := range entries {
+		var orderID string
+		if entry.OrderID != nil {
+			orderID = entry.OrderID.String()
+		}
+		for _, posting := range entry.Postings {
+			var code, name, debit, credit string
+			if posting.Account != nil {
+				code, name = posting.Account.Code, posting.Account.Name
+			}
+			if posting.Amount.Amount > 0 {
+				debit = posting.Amount.Decimal()
+			} else {
+				credit = models.NewMoney(-posting.Amount.Amount, posting.Amount.Currency).Decimal()
+			}
+			w.Write([]string{
+				entry.EntryNo,
+				entry.PostedAt.UTC().Format(time.RFC3339),
+				string(entry.Event),
+				entry.Reference,
+				orderID,
+				code,
+				name,
+				posting.Amount.Currency,
+				debit,
+				credit,
+				entry.Memo,
+			})
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer 开发环境使用：不真正发送，Dir为空时把邮件写入日志，否则在Dir下保存为.eml文件。
+// 邮件中含有验证和重置密码链接，生产环境不要使用
+type LogMailer struct {
+	From string
+	Dir  string
+}
+
+// Send 记录一封邮件
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	from := m.From
+	if from == "" {
+		from = "SkillHub <no-reply@localhost>"
+	}
+	now := time.Now()
+	data, err := compose(from, msg, now)
+	if err != nil {
+		return err
+	}
+	if m.Dir == "" {
+		log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", now.Format("20060102T150405"), now.UnixNano()%1e9)
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+	log.Printf("Mail to %s saved to %s", msg.To, path)
+	return nil
+}
@@ -0,0 +1,118 @@
+// Package mail 邮件发送：Mailer接口及SMTP、日志两种实现，启动时按MAIL_DRIVER选择
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"skillhub/config"
+)
+
+// ErrInvalidRecipient 收件人地址格式不正确
+var ErrInvalidRecipient = errors.New("invalid recipient address")
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer 发送邮件
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	mu     sync.RWMutex
+	mailer Mailer = &LogMailer{}
+)
+
+// SetMailer 替换当前使用的Mailer
+func SetMailer(m Mailer) {
+	mu.Lock()
+	defer mu.Unlock()
+	mailer = m
+}
+
+// Send 用当前Mailer发送邮件，未设置时只写日志
+func Send(ctx context.Context, msg Message) error {
+	mu.RLock()
+	m := mailer
+	mu.RUnlock()
+	return m.Send(ctx, msg)
+}
+
+// New 按配置创建Mailer：smtp 或 log
+func New(cfg config.MailConfig) (Mailer, error) {
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %v", cfg.From, err)
+	}
+	switch strings.ToLower(cfg.Driver) {
+	case "", "log":
+		return &LogMailer{From: cfg.From, Dir: cfg.LogDir}, nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST is required when MAIL_DRIVER=smtp")
+		}
+		return &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_DRIVER %q, want smtp or log", cfg.Driver)
+}
+
+// compose 生成RFC 5322格式的邮件，正文使用UTF-8 quoted-printable编码
+func compose(from string, msg Message, now time.Time) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, ErrInvalidRecipient
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %v", from, err)
+	}
+	var id [12]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	domain := sender.Address[strings.LastIndex(sender.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", sender.String())
+	header("To", to.String())
+	// 主题中的换行会被当作新的邮件头，一律替换为空格
+	header("Subject", mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(msg.Subject), " ")))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id[:]), domain))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"skillhub/config"
+)
+
+func TestCompose(t *testing.T) {
+	data, err := compose("SkillHub <no-reply@skillhub.example.com>", Message{
+		To:      "buyer@example.com",
+		Subject: "重置密码\r\nBcc: attacker@example.com",
+		Text:    "Open https://skillhub.example.com/reset-password?token=abc\n",
+	}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(data)
+	head, body, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header/body separator in %q", msg)
+	}
+	for _, want := range []string{"From: \"SkillHub\" <no-reply@skillhub.example.com>", "To: <buyer@example.com>", "Subject: =?utf-8?q?", "@skillhub.example.com>", "Content-Type: text/plain; charset=UTF-8"} {
+		if !strings.Contains(head, want) {
+			t.Errorf("headers missing %q:\n%s", want, head)
+		}
+	}
+	if strings.Contains(head, "\r\nBcc:") {
+		t.Errorf("subject injected a header:\n%s", head)
+	}
+	if !strings.Contains(body, "token=3Dabc") {
+		t.Errorf("body not quoted-printable encoded: %q", body)
+	}
+
+	if _, err := compose("no-reply@skillhub.example.com", Message{To: "a@b.com\r\nBcc: c@d.com"}, time.Now()); !errors.Is(err, ErrInvalidRecipient) {
+		t.Errorf("compose with injected recipient error = %v, want ErrInvalidRecipient", err)
+	}
+}
+
+func TestLogMailerWritesFile(t *testing.T) {
+	dir := t.TempDir()
+	m := &LogMailer{From: "no-reply@skillhub.example.com", Dir: dir}
+	if err := m.Send(context.Background(), Message{To: "buyer@example.com", Subject: "Hi", Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: <buyer@example.com>") {
+		t.Errorf("unexpected message:\n%s", data)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if m, err := New(config.MailConfig{Driver: "log", From: "no-reply@localhost"}); err != nil {
+		t.Errorf("New(log) error = %v", err)
+	} else if _, ok := m.(*LogMailer); !ok {
+		t.Errorf("New(log) = %T, want *LogMailer", m)
+	}
+	if m, err := New(config.MailConfig{Driver: "SMTP", From: "no-reply@localhost", SMTPHost: "smtp.example.com", SMTPPort: 587}); err != nil {
+		t.Errorf("New(smtp) error = %v", err)
+	} else if _, ok := m.(*SMTPMailer); !ok {
+		t.Errorf("New(smtp) = %T, want *SMTPMailer", m)
+	}
+	bad := []config.MailConfig{
+		{Driver: "smtp", From: "no-reply@localhost"},
+		{Driver: "sendgrid", From: "no-reply@localhost"},
+		{Driver: "log", From: "not an address"},
+	}
+	for _, cfg := range bad {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) succeeded, want error", cfg)
+		}
+	}
+}
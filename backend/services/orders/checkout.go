@@ -0,0 +1,471 @@
+package orders
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/bundle"
+	"skillhub/services/coupon"
+	"skillhub/services/entitlement"
+	"skillhub/services/gift"
+	"skillhub/services/payment"
+	"skillhub/services/pricing"
+	"skillhub/services/risk"
+	"skillhub/services/tax"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrEmptyCart 没有可结算的技能
+	ErrEmptyCart = errors.New("no skills to check out")
+	// ErrSkillNotFound 技能不存在或已下架
+	ErrSkillNotFound = errors.New("skill not found")
+	// ErrSkillFree 免费技能无需购买
+	ErrSkillFree = errors.New("skill is free, no purchase required")
+	// ErrAlreadyOwned 用户已购买该技能
+	ErrAlreadyOwned = errors.New("skill already purchased")
+	// ErrSandboxDisabled 未启用支付沙箱，订单只能由网关回调或查询确认付款
+	ErrSandboxDisabled = errors.New("payment sandbox is disabled")
+)
+
+// maxSubjectLength 支付主题的最大长度（字符），超出时只列出第一项
+const maxSubjectLength = 100
+
+// NewOrder 为用户购买的一组技能生成待支付订单（未保存），所有订单项按同一币种计价：
+// 指定currency时按该币种报价，否则使用第一个技能报价的币种
+func NewOrder(db *gorm.DB, userID uuid.UUID, skillIDs []uuid.UUID, region, currency string) (*models.Order, error) {
+	return newOrder(db, userID, skillIDs, region, currency, nil)
+}
+
+// NewGiftOrder 生成礼品订单（未保存）：每个技能按份数计价，支付后生成兑换码而不授予买家使用权，
+// 因此买家已拥有的技能也可以购买
+func NewGiftOrder(db *gorm.DB, userID uuid.UUID, skillIDs []uuid.UUID, opts gift.Options, region, currency string) (*models.Order, error) {
+	if err := opts.Normalize(); err != nil {
+		return nil, err
+	}
+	return newOrder(db, userID, skillIDs, region, currency, &opts)
+}
+
+// newOrder 生成订单，giftOpts不为空时为礼品订单
+func newOrder(db *gorm.DB, userID uuid.UUID, skillIDs []uuid.UUID, region, currency string, giftOpts *gift.Options) (*models.Order, error) {
+	skillIDs = uniqueIDs(skillIDs)
+	if len(skillIDs) == 0 {
+		return nil, ErrEmptyCart
+	}
+
+	var skills []models.Skill
+	if err := db.Where("id IN ? AND is_active = ?", skillIDs, true).Find(&skills).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.Skill, len(skills))
+	for i := range skills {
+		byID[skills[i].ID] = &skills[i]
+	}
+
+	owned := map[uuid.UUID]bool{}
+	if giftOpts == nil {
+		var err error
+		if owned, err = OwnedSkills(db, userID, skillIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	order := newPending(userID, region)
+	quantity := 1
+	if giftOpts != nil {
+		order.IsGift, order.GiftEmail, order.GiftMessage = true, giftOpts.RecipientEmail, giftOpts.Message
+		quantity = giftOpts.Quantity
+	}
+	for _, id := range skillIDs {
+		skill, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrSkillNotFound, id)
+		}
+		if skill.PriceType == models.PriceTypeFree {
+			return nil, fmt.Errorf("%w: %s", ErrSkillFree, skill.Name)
+		}
+		if skill.PriceType == models.PriceTypeSubscription {
+			return nil, fmt.Errorf("%w: %s", ErrSubscriptionRequired, skill.Name)
+		}
+		if owned[id] {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyOwned, skill.Name)
+		}
+
+		quote, err := pricing.QuoteSkill(skill, region, currency)
+		if err != nil {
+			return nil, err
+		}
+		if currency == "" {
+			currency = quote.Price.Currency
+		}
+
+		skillID := skill.ID
+		order.Items = append(order.Items, models.OrderItem{
+			ID:       uuid.New(),
+			OrderID:  order.ID,
+			SkillID:  &skillID,
+			Price:    quote.Price,
+			Quantity: quantity,
+			Skill:    skill,
+		})
+		order.Total = order.Total.Add(quote.Price.Mul(int64(quantity)))
+	}
+	return order, nil
+}
+
+// NewBundleOrder 生成套餐订单（未保存）：每个技能一个订单项，单价为套餐价按各技能单独购买价格比例分摊的部分，
+// 退款和发布者分成均按分摊后的金额计算。已拥有套餐中任一技能时拒绝购买，避免为已有技能重复付费
+func NewBundleOrder(db *gorm.DB, userID, bundleID uuid.UUID, region, currency string) (*models.Order, error) {
+	b, err := bundle.Load(db, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	if !b.AvailableAt(time.Now()) {
+		return nil, bundle.ErrUnavailable
+	}
+	skillIDs := make([]uuid.UUID, 0, len(b.Items))
+	for _, item := range b.Items {
+		if item.Skill == nil || !item.Skill.IsActive {
+			return nil, bundle.ErrUnavailable
+		}
+		skillIDs = append(skillIDs, item.SkillID)
+	}
+
+	owned, err := OwnedSkills(db, userID, skillIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBundleOwnership(b, owned); err != nil {
+		return nil, err
+	}
+
+	quote, err := bundle.QuoteBundle(b, region, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	order := newPending(userID, region)
+	bundleID = b.ID
+	for i, item := range quote.Items {
+		skillID := item.SkillID
+		order.Items = append(order.Items, models.OrderItem{
+			ID:       uuid.New(),
+			OrderID:  order.ID,
+			SkillID:  &skillID,
+			Price:    item.Price,
+			Quantity: 1,
+			BundleID: &bundleID,
+			Skill:    b.Items[i].Skill,
+		})
+	}
+	order.Total = quote.Price
+	return order, nil
+}
+
+// checkBundleOwnership 检查套餐中是否有已拥有的技能，有则返回列出这些技能名称的 ErrAlreadyOwned
+func checkBundleOwnership(b *models.Bundle, owned map[uuid.UUID]bool) error {
+	var names []string
+	for _, item := range b.Items {
+		if !owned[item.SkillID] {
+			continue
+		}
+		if item.Skill != nil {
+			names = append(names, item.Skill.Name)
+		} else {
+			names = append(names, item.SkillID.String())
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrAlreadyOwned, strings.Join(names, ", "))
+}
+
+// newPending 生成待支付订单的订单头
+func newPending(userID uuid.UUID, region string) *models.Order {
+	return &models.Order{
+		ID:      uuid.New(),
+		OrderNo: newOrderNo(time.Now()),
+		UserID:  userID,
+		Region:  pricing.NormalizeRegion(region),
+		Status:  models.OrderStatusPending,
+	}
+}
+
+// orderNoEncoding 订单号随机部分的编码，只含大写字母和数字，便于口述和网关传递
+var orderNoEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newOrderNo 生成订单号：ORD + 下单时间（精确到秒）+ 10位随机字符（50位随机数），
+// 同一秒内大量下单也不会重复；订单号唯一索引兜底
+func newOrderNo(now time.Time) string {
+	var b [10]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// 系统随机源不可用时退化为UUID的随机部分
+		id := uuid.New()
+		copy(b[:], id[6:])
+	}
+	return "ORD" + now.Format("20060102150405") + orderNoEncoding.EncodeToString(b[:])[:10]
+}
+
+// Screen 按下单信号对尚未保存的订单做风控评估，需在计税之后、Place之前调用。
+// 风险分记录在订单上；达到拦截阈值返回risk.ErrBlocked，达到审核阈值的订单以待审核状态保存，审核通过前不能支付
+func Screen(db *gorm.DB, order *models.Order, signals risk.Signals) error {
+	signals.UserID = order.UserID
+	order.ClientIP = signals.IP
+	order.CardFingerprint = signals.CardFingerprint
+
+	assessment, err := risk.Assess(db, signals, time.Now())
+	if err != nil {
+		return err
+	}
+	order.RiskScore = assessment.Score
+	order.RiskReasons = strings.Join(assessment.Reasons, "; ")
+	switch assessment.Decision {
+	case risk.DecisionBlock:
+		log.Printf("Order %s for user %s blocked by risk checks (score %d): %s", order.OrderNo, order.UserID, order.RiskScore, order.RiskReasons)
+		return risk.ErrBlocked
+	case risk.DecisionReview:
+		order.Status = models.OrderStatusRiskReview
+	}
+	return nil
+}
+
+// ApplyCoupon 将优惠码应用到尚未保存的订单，code为空时不做处理
+func ApplyCoupon(db *gorm.DB, order *models.Order, code string) error {
+	if coupon.NormalizeCode(code) == "" {
+		return nil
+	}
+	_, err := coupon.Apply(db, code, order.UserID, order)
+	return err
+}
+
+// ApplyTax 按下单地区的税率规则为尚未保存的订单计税，需在应用优惠券之后调用，重复调用会重新计算。
+// 价外税计入实付金额；价内税从标价中拆出，实付金额不变。买方开票资料为单位抬头且填写了税号时按B2B处理
+func ApplyTax(db *gorm.DB, order *models.Order) error {
+	if !order.TaxInclusive {
+		order.Total = order.Total.Sub(order.Tax)
+	}
+	order.Tax, order.TaxInclusive, order.TaxLines, order.BuyerTaxID = models.NewMoney(0, order.Total.Currency), false, nil, ""
+	for i := range order.Items {
+		order.Items[i].Tax, order.Items[i].TaxInclusive = models.NewMoney(0, order.Items[i].Price.Currency), false
+	}
+
+	country, region := models.SplitRegion(order.Region)
+	rules := tax.Current().Match(country, region)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var profile models.BillingProfile
+	err := db.Where("user_id = ?", order.UserID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	b2b := err == nil && profile.TitleType == models.FapiaoTitleCompany && profile.Party.TaxID != ""
+	if b2b {
+		order.BuyerTaxID = profile.Party.TaxID
+	}
+
+	amounts := make([]int64, len(order.Items))
+	for i := range order.Items {
+		amounts[i] = order.Items[i].Subtotal().Amount
+	}
+	result := tax.Calculate(rules, amounts, order.Total.Currency, country, region, b2b)
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Tax = models.NewMoney(result.ItemTax[i], item.Price.Currency)
+		item.TaxInclusive = result.Inclusive
+	}
+	for _, line := range result.Lines {
+		order.TaxLines = append(order.TaxLines, models.OrderTaxLine{ID: uuid.New(), OrderID: order.ID, TaxLine: line})
+	}
+	order.Tax = models.NewMoney(result.Total, order.Total.Currency)
+	order.TaxInclusive = result.Inclusive
+	if !order.TaxInclusive {
+		order.Total = order.Total.Add(order.Tax)
+	}
+	return nil
+}
+
+// IsCouponError 是否为优惠券不可用导致的错误
+func IsCouponError(err error) bool {
+	return errors.Is(err, coupon.ErrNotFound) || errors.Is(err, coupon.ErrInactive) || errors.Is(err, coupon.ErrUsedUp) ||
+		errors.Is(err, coupon.ErrUserLimit) || errors.Is(err, coupon.ErrNotApplicable)
+}
+
+// Place 保存订单、订单项、税费和折扣明细，使用了优惠券时在同一事务中占用使用次数
+func Place(db *gorm.DB, order *models.Order) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items", "User", "Discounts", "TaxLines").Create(order).Error; err != nil {
+			return err
+		}
+		for i := range order.Items {
+			if err := tx.Omit("Order", "Skill").Create(&order.Items[i]).Error; err != nil {
+				return err
+			}
+		}
+		if len(order.TaxLines) > 0 {
+			if err := tx.Create(&order.TaxLines).Error; err != nil {
+				return err
+			}
+		}
+		if len(order.Discounts) > 0 {
+			if err := tx.Create(&order.Discounts).Error; err != nil {
+				return err
+			}
+			if couponID := order.Discounts[0].CouponID; couponID != nil {
+				if err := coupon.Reserve(tx, *couponID, order.UserID, order); err != nil {
+					return err
+				}
+			}
+		}
+		return hold(tx, order)
+	})
+}
+
+// hold 风控挂起的订单记录状态事件并通知管理员审核
+func hold(tx *gorm.DB, order *models.Order) error {
+	if order.Status != models.OrderStatusRiskReview {
+		return nil
+	}
+	if err := tx.Create(&models.OrderEvent{
+		ID:         uuid.New(),
+		OrderID:    order.ID,
+		FromStatus: models.OrderStatusPending,
+		ToStatus:   models.OrderStatusRiskReview,
+		Source:     SourceRisk,
+		Note:       order.RiskReasons,
+	}).Error; err != nil {
+		return err
+	}
+	return raiseAlert(tx, order, models.AlertTypeRiskReview, "",
+		fmt.Sprintf("order %s held for review with risk score %d: %s", order.OrderNo, order.RiskScore, order.RiskReasons))
+}
+
+// SettleFree 实付金额为零的订单（如全额优惠券）无需经过支付网关，直接置为已支付
+func SettleFree(db *gorm.DB, order *models.Order, source string) error {
+	if !order.Total.IsZero() {
+		return fmt.Errorf("order %s is not free: %s", order.OrderNo, order.Total)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		locked, err := LockByOrderNo(tx, order.OrderNo)
+		if err != nil {
+			return err
+		}
+		if locked.Status == models.OrderStatusPaid {
+			*order = *locked
+			return nil
+		}
+		if err := Transition(tx, locked, models.OrderStatusPaid, source, "", "zero total"); err != nil {
+			return err
+		}
+		order.Status, order.PaidAt = locked.Status, locked.PaidAt
+		return nil
+	})
+}
+
+// SandboxEnabled 是否启用支付沙箱（PAYMENT_SANDBOX），release模式下不允许启用
+func SandboxEnabled() bool {
+	return config.AppConfig != nil && config.AppConfig.Payment.Sandbox
+}
+
+// SettleSandbox 沙箱模式下模拟网关确认订单付款，与真实回调走同一状态机（记录交易、入账、授予使用权）
+func SettleSandbox(order *models.Order, paymentType payment.PaymentType) error {
+	if !SandboxEnabled() {
+		return ErrSandboxDisabled
+	}
+	settled, err := ApplyPayment(sandboxResult(order, paymentType, *config.AppConfig), SourceSandbox)
+	if err != nil {
+		return err
+	}
+	order.Status, order.PaidAt, order.PaymentMethod = settled.Status, settled.PaidAt, settled.PaymentMethod
+	return nil
+}
+
+// sandboxResult 模拟网关对订单的付款结果。模拟网关代表本商户收款，商户号按配置填写，
+// 否则配置了商户号的渠道（支付宝、微信支付）会被判为商户不符而转入人工审核
+func sandboxResult(order *models.Order, paymentType payment.PaymentType, cfg config.Config) *payment.CallbackResult {
+	return &payment.CallbackResult{
+		TradeNo:     "sandbox_" + uuid.New().String()[:8],
+		OutTradeNo:  order.OrderNo,
+		TradeStatus: "TRADE_SUCCESS",
+		TotalAmount: order.Total.Decimal(),
+		MerchantID:  payment.ExpectedMerchantID(paymentType, cfg),
+		RawParams:   url.Values{"sandbox": {"true"}},
+		PaymentType: paymentType,
+	}
+}
+
+// OwnedSkills 返回用户当前拥有有效使用权的技能（购买、订阅或管理员授予）
+func OwnedSkills(db *gorm.DB, userID uuid.UUID, skillIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	return entitlement.ActiveSkills(db, userID, skillIDs, time.Now())
+}
+
+// Subject 订单的支付主题：列出各订单项的技能名称，过长时显示第一项及剩余数量
+func Subject(order *models.Order) string {
+	var names []string
+	for _, item := range order.Items {
+		if item.Skill != nil && item.Skill.Name != "" {
+			names = append(names, item.Skill.Name)
+		}
+	}
+	switch {
+	case len(names) == 0:
+		return fmt.Sprintf("Skill Order #%s", order.OrderNo)
+	case len(names) < len(order.Items):
+		return fmt.Sprintf("Skill Order #%s (%d items)", order.OrderNo, len(order.Items))
+	}
+
+	subject := strings.Join(names, ", ")
+	if len(names) > 1 && len([]rune(subject)) > maxSubjectLength {
+		subject = fmt.Sprintf("%s and %d more", names[0], len(names)-1)
+	}
+	return subject
+}
+
+// LoadSubject 加载订单项及技能后生成支付主题
+func LoadSubject(db *gorm.DB, order *models.Order) string {
+	if len(order.Items) == 0 {
+		db.Preload("Skill").Where("order_id = ?", order.ID).Find(&order.Items)
+	}
+	return Subject(order)
+}
+
+// grantItems 订单支付成功后为每个订单项授予购买权益（计入技能购买数）
+func grantItems(tx *gorm.DB, order *models.Order) error {
+	var skillIDs []uuid.UUID
+	if err := tx.Model(&models.OrderItem{}).
+		Where("order_id = ? AND skill_id IS NOT NULL AND refunded_at IS NULL", order.ID).
+		Pluck("skill_id", &skillIDs).Error; err != nil {
+		return err
+	}
+	if len(skillIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&models.Skill{}).Where("id IN ?", skillIDs).
+		UpdateColumn("purchases_count", gorm.Expr("purchases_count + 1")).Error
+}
+
+// uniqueIDs 去除重复的ID并保持顺序
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	result := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package orders
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/payment"
+
+	"github.com/google/uuid"
+)
+
+func TestSubject(t *testing.T) {
+	item := func(name string) models.OrderItem {
+		return models.OrderItem{Skill: &models.Skill{Name: name}}
+	}
+
+	order := &models.Order{OrderNo: "ORD1", Items: []models.OrderItem{item("Translator"), item("Summarizer")}}
+	if got := Subject(order); got != "Translator, Summarizer" {
+		t.Errorf("expected item names, got %q", got)
+	}
+
+	long := strings.Repeat("x", maxSubjectLength)
+	order.Items = []models.OrderItem{item(long), item("Summarizer"), item("Coder")}
+	if got := Subject(order); got != long+" and 2 more" {
+		t.Errorf("expected truncated subject, got %q", got)
+	}
+
+	order.Items = []models.OrderItem{{}, item("Coder")}
+	if got := Subject(order); got != "Skill Order #ORD1 (2 items)" {
+		t.Errorf("expected fallback subject, got %q", got)
+	}
+}
+
+func TestCheckBundleOwnership(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	bundle := &models.Bundle{Name: "Writer Pack", Items: []models.BundleItem{
+		{SkillID: a, Skill: &models.Skill{Name: "Translator"}},
+		{SkillID: b, Skill: &models.Skill{Name: "Summarizer"}},
+		{SkillID: c, Skill: &models.Skill{Name: "Coder"}},
+	}}
+
+	if err := checkBundleOwnership(bundle, map[uuid.UUID]bool{}); err != nil {
+		t.Errorf("expected no error without owned skills, got %v", err)
+	}
+
+	// 只拥有部分技能时同样拒绝，并列出已拥有的技能
+	err := checkBundleOwnership(bundle, map[uuid.UUID]bool{b: true})
+	if !errors.Is(err, ErrAlreadyOwned) || !strings.HasSuffix(err.Error(), ": Summarizer") {
+		t.Errorf("expected ErrAlreadyOwned naming Summarizer, got %v", err)
+	}
+
+	err = checkBundleOwnership(bundle, map[uuid.UUID]bool{a: true, b: true, c: true})
+	if !errors.Is(err, ErrAlreadyOwned) || !strings.HasSuffix(err.Error(), ": Translator, Summarizer, Coder") {
+		t.Errorf("expected ErrAlreadyOwned naming all skills, got %v", err)
+	}
+}
+
+func TestUniqueIDs(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	got := uniqueIDs([]uuid.UUID{a, b, a})
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("expected [a b], got %v", got)
+	}
+}
+
+func TestNewOrderNo(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		no := newOrderNo(now)
+		if len(no) != 27 || !strings.HasPrefix(no, "ORD20261018093000") {
+			t.Fatalf("unexpected order number %q", no)
+		}
+		if seen[no] {
+			t.Fatalf("duplicate order number %q within the same second", no)
+		}
+		seen[no] = true
+	}
+}
+
+func TestSandboxResultPassesVerification(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Payment.Alipay.AppID = "2021000000000001"
+	cfg.Payment.WeChatPay.MchID = "1900000001"
+	order := &models.Order{OrderNo: "ORDSB001", Total: models.NewMoney(1999, "CNY")}
+
+	// 配置了商户号但密钥不全时沙箱回退到模拟网关，模拟付款不应被判为商户不符
+	for _, paymentType := range []payment.PaymentType{payment.PaymentTypeAlipay, payment.PaymentTypeWeChat} {
+		if problems := payment.VerifyResult(order, sandboxResult(order, paymentType, cfg), cfg); len(problems) > 0 {
+			t.Errorf("%s sandbox result flagged: %v", paymentType, problems)
+		}
+	}
+}
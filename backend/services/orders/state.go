@@ -0,0 +1,468 @@
+package orders
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/coupon"
+	"skillhub/services/earnings"
+	"skillhub/services/entitlement"
+	"skillhub/services/gift"
+	"skillhub/services/invoice"
+	"skillhub/services/ledger"
+	"skillhub/services/payment"
+	"skillhub/services/subscription"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 状态变更来源
+const (
+	SourceCallback  = "callback"
+	SourceReconcile = "reconcile"
+	SourceRefund    = "refund"
+	SourcePurchase  = "purchase"
+	SourceMock      = "mock"
+	SourceAdmin     = "admin"
+	SourceCoupon    = "coupon"
+	SourceExpire    = "expire"
+	SourceSandbox   = "sandbox" // 支付沙箱自动确认付款
+	SourceRisk      = "risk"    // 下单风控挂起
+)
+
+var (
+	// ErrInvalidTransition 状态机不允许的状态变更
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrOrderNotFound 订单不存在
+	ErrOrderNotFound = errors.New("order not found")
+)
+
+// transitions 订单允许的状态变更
+var transitions = map[models.OrderStatus][]models.OrderStatus{
+	models.OrderStatusPending:       {models.OrderStatusPaid, models.OrderStatusCancelled, models.OrderStatusPaymentReview},
+	models.OrderStatusPaid:          {models.OrderStatusRefunded},
+	models.OrderStatusPaymentReview: {models.OrderStatusPaid, models.OrderStatusCancelled, models.OrderStatusRefunded},
+	models.OrderStatusRiskReview:    {models.OrderStatusPending, models.OrderStatusCancelled},
+}
+
+// CanTransition 判断状态变更是否合法
+func CanTransition(from, to models.OrderStatus) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition 变更订单状态并记录审计事件，调用方应在事务中并已锁定订单行
+func Transition(tx *gorm.DB, order *models.Order, to models.OrderStatus, source, reference, note string) error {
+	from := order.Status
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+
+	updates := map[string]interface{}{"status": to}
+	if to == models.OrderStatusPaid && order.PaidAt == nil {
+		now := time.Now()
+		order.PaidAt = &now
+		updates["paid_at"] = order.PaidAt
+	}
+	if err := tx.Model(order).Updates(updates).Error; err != nil {
+		return err
+	}
+	order.Status = to
+
+	switch to {
+	case models.OrderStatusPaid:
+		if err := grantItems(tx, order); err != nil {
+			return err
+		}
+		// 一次性购买授予永久使用权，订阅订单的使用权随订阅周期更新；礼品订单生成兑换码，兑换时授予兑换人
+		switch {
+		case order.IsGift:
+			if _, err := gift.Issue(tx, order, giftExpiry(), *order.PaidAt); err != nil {
+				return err
+			}
+		case order.SubscriptionID == nil:
+			if err := entitlement.GrantOrder(tx, order, *order.PaidAt); err != nil {
+				return err
+			}
+		}
+		if err := coupon.Redeem(tx, order.ID); err != nil {
+			return err
+		}
+		// 订阅订单激活订阅或顺延一个计费周期
+		if order.SubscriptionID != nil {
+			if err := subscription.Extend(tx, *order.SubscriptionID, *order.PaidAt); err != nil {
+				return err
+			}
+		}
+		// 代收的税款不计入收入
+		if err := ledger.RecordTax(tx, order); err != nil {
+			return err
+		}
+		// 有发布者的订单项按抽成比例记录发布者收益
+		if err := earnings.Record(tx, order, *order.PaidAt); err != nil {
+			return err
+		}
+		// 付费订单支付后开具收据，免费订单不开具
+		if order.Total.Amount > 0 && config.AppConfig != nil {
+			if _, err := invoice.Issue(tx, config.AppConfig.Invoice, order); err != nil {
+				return err
+			}
+		}
+	case models.OrderStatusCancelled:
+		// 取消或过期的订单释放优惠券使用次数
+		if err := coupon.Release(tx, order.ID); err != nil {
+			return err
+		}
+		if order.SubscriptionID != nil {
+			if err := subscription.Abandon(tx, *order.SubscriptionID); err != nil {
+				return err
+			}
+		}
+	case models.OrderStatusRefunded:
+		// 全额退款撤销订单授予的使用权
+		if err := entitlement.RevokeOrder(tx, order.ID, nil, "order refunded"); err != nil {
+			return err
+		}
+		if order.SubscriptionID != nil {
+			if err := entitlement.RevokeSubscription(tx, *order.SubscriptionID, "subscription order refunded"); err != nil {
+				return err
+			}
+		}
+		if order.IsGift {
+			if err := gift.Refund(tx, order.ID, nil, "gift order refunded"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Create(&models.OrderEvent{
+		ID:         uuid.New(),
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Source:     source,
+		Reference:  reference,
+		Note:       note,
+	}).Error
+}
+
+// LockByOrderNo 在事务中按订单号加行锁读取订单
+func LockByOrderNo(tx *gorm.DB, orderNo string) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_no = ?", orderNo).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ApplyPayment 根据网关回调或查询结果推进订单状态。
+// 订单行在事务内加锁，同一(渠道, 网关交易号)只入账一次，重放的回调直接返回。
+func ApplyPayment(result *payment.CallbackResult, source string) (*models.Order, error) {
+	db := models.GetDB()
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	var order *models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = LockByOrderNo(tx, result.OutTradeNo)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case result.IsPaid():
+			return applyPaid(tx, order, result, source)
+		case result.IsClosed():
+			if order.Status != models.OrderStatusPending {
+				// 已支付订单的关闭通知（如退款后关闭交易）不影响订单状态
+				return nil
+			}
+			return Transition(tx, order, models.OrderStatusCancelled, source, result.TradeNo, "trade status "+result.TradeStatus)
+		default:
+			// 等待支付等中间状态，不做处理
+			return nil
+		}
+	})
+	return order, err
+}
+
+// applyPaid 记录支付交易并将订单置为已支付；金额、币种或商户与订单不符时转入人工审核
+func applyPaid(tx *gorm.DB, order *models.Order, result *payment.CallbackResult, source string) error {
+	channel := string(result.PaymentType)
+
+	// 重放的回调：交易已入账
+	if result.TradeNo != "" {
+		var count int64
+		if err := tx.Model(&models.Transaction{}).
+			Where("payment_channel = ? AND transaction_id = ?", channel, result.TradeNo).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	switch order.Status {
+	case models.OrderStatusPending:
+		// 正常支付
+	case models.OrderStatusPaid, models.OrderStatusPaymentReview:
+		if result.TradeNo == "" {
+			// 无交易号的重复通知（模拟支付）
+			return nil
+		}
+		// 同一订单的另一笔支付（疑似重复支付），交易记为待确认、暂不入总账，由管理员处理告警时确认或驳回；不改变订单状态
+		log.Printf("Order %s received an additional payment %s via %s", order.OrderNo, result.TradeNo, channel)
+		if err := createTransaction(tx, order, result, models.TransactionStatusPending); err != nil {
+			return err
+		}
+		return raiseAlert(tx, order, models.AlertTypeDuplicatePayment, result.TradeNo,
+			fmt.Sprintf("order %s received an additional payment of %s via %s", order.OrderNo, result.TotalAmount, channel))
+	default:
+		log.Printf("Order %s in status %s received payment %s via %s, ignored", order.OrderNo, order.Status, result.TradeNo, channel)
+		if result.TradeNo == "" {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, order.Status, models.OrderStatusPaid)
+		}
+		// 已取消/已退款订单收到的付款需要人工退还
+		if err := createTransaction(tx, order, result, models.TransactionStatusSuccess); err != nil {
+			return err
+		}
+		return raiseAlert(tx, order, models.AlertTypeLatePayment, result.TradeNo,
+			fmt.Sprintf("order %s in status %s received a payment of %s via %s", order.OrderNo, order.Status, result.TotalAmount, channel))
+	}
+
+	if err := createTransaction(tx, order, result, models.TransactionStatusSuccess); err != nil {
+		return err
+	}
+	if err := tx.Model(order).Update("payment_method", channel).Error; err != nil {
+		return err
+	}
+	order.PaymentMethod = channel
+	if order.SubscriptionID != nil {
+		if err := subscription.Link(tx, *order.SubscriptionID, result.SubscriptionRef); err != nil {
+			return err
+		}
+	}
+
+	var cfg config.Config
+	if config.AppConfig != nil {
+		cfg = *config.AppConfig
+	}
+	if problems := payment.VerifyResult(order, result, cfg); len(problems) > 0 {
+		note := strings.Join(problems, "; ")
+		log.Printf("Order %s payment %s via %s needs review: %s", order.OrderNo, result.TradeNo, channel, note)
+		if err := Transition(tx, order, models.OrderStatusPaymentReview, source, result.TradeNo, note); err != nil {
+			return err
+		}
+		return raiseAlert(tx, order, models.AlertTypePaymentMismatch, result.TradeNo,
+			fmt.Sprintf("order %s payment via %s does not match the order: %s", order.OrderNo, channel, note))
+	}
+
+	return Transition(tx, order, models.OrderStatusPaid, source, result.TradeNo, "")
+}
+
+// ResolveReview 管理员审核订单。付款异常的订单通过则置为已支付，驳回则取消（已收款项需另行退款）；
+// 风控挂起的订单通过则恢复为待支付（实付为零的直接完成），驳回则取消
+func ResolveReview(orderID uuid.UUID, approve bool, note string) (*models.Order, error) {
+	db := models.GetDB()
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	var order models.Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+		switch order.Status {
+		case models.OrderStatusPaymentReview:
+			to := models.OrderStatusCancelled
+			if approve {
+				to = models.OrderStatusPaid
+			}
+			return Transition(tx, &order, to, SourceAdmin, "", note)
+		case models.OrderStatusRiskReview:
+			if !approve {
+				return Transition(tx, &order, models.OrderStatusCancelled, SourceAdmin, "", note)
+			}
+			return releaseHold(tx, &order, note)
+		}
+		return fmt.Errorf("%w: order is %s, not under review", ErrInvalidTransition, order.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// releaseHold 风控审核通过：订单恢复为待支付，过期时间从审核通过时起算；实付为零的订单直接完成
+func releaseHold(tx *gorm.DB, order *models.Order, note string) error {
+	now := time.Now()
+	if err := tx.Model(order).Update("risk_reviewed_at", now).Error; err != nil {
+		return err
+	}
+	order.RiskReviewedAt = &now
+	if err := Transition(tx, order, models.OrderStatusPending, SourceAdmin, "", note); err != nil {
+		return err
+	}
+	if order.Total.IsZero() {
+		return Transition(tx, order, models.OrderStatusPaid, SourceCoupon, "", "zero total")
+	}
+	return nil
+}
+
+// raiseAlert 记录需要管理员处理的告警
+func raiseAlert(tx *gorm.DB, order *models.Order, alertType models.AlertType, reference, message string) error {
+	log.Printf("ALERT [%s] %s", alertType, message)
+	orderID := order.ID
+	return tx.Create(&models.AdminAlert{
+		ID:        uuid.New(),
+		Type:      alertType,
+		OrderID:   &orderID,
+		Reference: reference,
+		Message:   message,
+	}).Error
+}
+
+// createTransaction 创建支付交易记录
+func createTransaction(tx *gorm.DB, order *models.Order, result *payment.CallbackResult, status models.TransactionStatus) error {
+	// 金额无法解析时仍记录交易（金额记为0），由人工审核或告警跟进
+	amount, err := result.Amount(payment.OrderCurrency(order, result.PaymentType))
+	if err != nil {
+		amount = models.NewMoney(0, payment.OrderCurrency(order, result.PaymentType))
+	}
+	transaction := models.Transaction{
+		ID:             uuid.New(),
+		OrderID:        order.ID,
+		PaymentChannel: string(result.PaymentType),
+		TransactionID:  result.TradeNo,
+		Amount:         amount,
+		Status:         status,
+		RawResponse:    marshalParams(result.RawParams),
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+	// 成功的付款（包括迟到的付款）记入总账，待确认的重复付款在管理员确认后入账
+	return ledger.RecordPayment(tx, &transaction)
+}
+
+// SettleDuplicatePayment 处理重复付款告警：确认后交易置为成功并记入总账（款项需另行退还），
+// 驳回（如网关对同一笔付款使用了另一个交易号）则置为失败。调用方应在事务中
+func SettleDuplicatePayment(tx *gorm.DB, alert *models.AdminAlert, confirm bool) error {
+	if alert.Type != models.AlertTypeDuplicatePayment || alert.OrderID == nil {
+		return nil
+	}
+	var txn models.Transaction
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND transaction_id = ? AND status = ?", *alert.OrderID, alert.Reference, models.TransactionStatusPending).
+		First(&txn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	txn.Status = models.TransactionStatusFailed
+	if confirm {
+		txn.Status = models.TransactionStatusSuccess
+	}
+	if err := tx.Model(&txn).Update("status", txn.Status).Error; err != nil {
+		return err
+	}
+	return ledger.RecordPayment(tx, &txn)
+}
+
+// marshalParams 将URL参数序列化为JSON字符串
+func marshalParams(params url.Values) string {
+	data := make(map[string]string)
+	for k, v := range params {
+		if len(v) > 0 {
+			data[k] = v[0]
+		}
+	}
+	jsonData, _ := json.Marshal(data)
+	return string(jsonData)
+}
+
+// ExpirePending 取消创建（或风控审核通过）时间早于cutoff的待支付订单（释放占用的优惠券），返回取消的订单数
+func ExpirePending(cutoff time.Time) (int, error) {
+	db := models.GetDB()
+	if db == nil {
+		return 0, errors.New("database not initialized")
+	}
+
+	var orderNos []string
+	// 续费订单在订阅失效前保持待支付，由订阅定时任务作废
+	if err := db.Model(&models.Order{}).
+		Where("status = ? AND COALESCE(risk_reviewed_at, created_at) < ?", models.OrderStatusPending, cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM subscriptions WHERE subscriptions.id = orders.subscription_id AND subscriptions.current_period_end IS NOT NULL)").
+		Pluck("order_no", &orderNos).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, orderNo := range orderNos {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			order, err := LockByOrderNo(tx, orderNo)
+			if err != nil {
+				return err
+			}
+			if order.Status != models.OrderStatusPending {
+				// 加锁前已被回调或对账更新
+				return nil
+			}
+			if err := Transition(tx, order, models.OrderStatusCancelled, SourceExpire, "", "payment not received before "+cutoff.Format(time.RFC3339)); err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to expire order %s: %v", orderNo, err)
+		}
+	}
+	return expired, nil
+}
+
+// giftExpiry 礼品兑换码的有效期，0表示不过期
+func giftExpiry() time.Duration {
+	if config.AppConfig == nil {
+		return 0
+	}
+	return config.AppConfig.Payment.GiftExpiry
+}
+
+// RunExpireScheduled 定时任务入口：按配置的订单过期时间取消待支付订单
+func RunExpireScheduled() error {
+	expiry := 24 * time.Hour
+	if config.AppConfig != nil && config.AppConfig.Payment.OrderExpiry > 0 {
+		expiry = config.AppConfig.Payment.OrderExpiry
+	}
+	expired, err := ExpirePending(time.Now().Add(-expiry))
+	if expired > 0 {
+		log.Printf("Expired %d pending orders", expired)
+	}
+	return err
+}
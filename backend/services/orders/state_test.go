@@ -0,0 +1,36 @@
+package orders
+
+import (
+	"testing"
+
+	"skillhub/models"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to models.OrderStatus
+		want     bool
+	}{
+		{models.OrderStatusPending, models.OrderStatusPaid, true},
+		{models.OrderStatusPending, models.OrderStatusCancelled, true},
+		{models.OrderStatusPaid, models.OrderStatusRefunded, true},
+		{models.OrderStatusCancelled, models.OrderStatusPaid, false},
+		{models.OrderStatusRefunded, models.OrderStatusPaid, false},
+		{models.OrderStatusPaid, models.OrderStatusCancelled, false},
+		{models.OrderStatusPaid, models.OrderStatusPending, false},
+		{models.OrderStatusPending, models.OrderStatusRefunded, false},
+		{models.OrderStatusPending, models.OrderStatusPaymentReview, true},
+		{models.OrderStatusPaymentReview, models.OrderStatusPaid, true},
+		{models.OrderStatusPaymentReview, models.OrderStatusCancelled, true},
+		{models.OrderStatusPaid, models.OrderStatusPaymentReview, false},
+		{models.OrderStatusRiskReview, models.OrderStatusPending, true},
+		{models.OrderStatusRiskReview, models.OrderStatusCancelled, true},
+		{models.OrderStatusRiskReview, models.OrderStatusPaid, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
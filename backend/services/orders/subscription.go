@@ -0,0 +1,287 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/payment"
+	"skillhub/services/pricing"
+	"skillhub/services/subscription"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SourceSubscription 订阅续费、取消等引起的状态变更
+const SourceSubscription = "subscription"
+
+// ErrSubscriptionRequired 订阅类技能不能一次性购买
+var ErrSubscriptionRequired = errors.New("skill is sold by subscription, subscribe instead")
+
+// Subscribe 为用户创建未激活的订阅及其首期订单（已保存、待支付）。
+// 同一技能已有未支付的订阅时将其作废后重新创建
+func Subscribe(db *gorm.DB, userID uuid.UUID, plan *models.SubscriptionPlan, region string) (*models.Subscription, *models.Order, error) {
+	current, err := subscription.Current(db, userID, plan.SkillID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if current != nil && current.Status != models.SubscriptionStatusIncomplete {
+		return nil, nil, fmt.Errorf("%w: %s", subscription.ErrAlreadySubscribed, plan.Skill.Name)
+	}
+
+	// 已通过购买或管理员授予获得使用权时无需订阅
+	owned, err := OwnedSkills(db, userID, []uuid.UUID{plan.SkillID})
+	if err != nil {
+		return nil, nil, err
+	}
+	if owned[plan.SkillID] {
+		return nil, nil, fmt.Errorf("%w: %s", ErrAlreadyOwned, plan.Skill.Name)
+	}
+
+	if current != nil {
+		if err := Unsubscribe(db, current); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sub := &models.Subscription{
+		ID:      uuid.New(),
+		UserID:  userID,
+		SkillID: plan.SkillID,
+		PlanID:  plan.ID,
+		Status:  models.SubscriptionStatusIncomplete,
+		Plan:    plan,
+		Skill:   plan.Skill,
+	}
+	order := newSubscriptionOrder(sub, plan, region)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Plan", "Skill").Create(sub).Error; err != nil {
+			return err
+		}
+		return Place(tx, order)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub, order, nil
+}
+
+// newSubscriptionOrder 按方案当前价格生成订阅的一期订单（未保存）
+func newSubscriptionOrder(sub *models.Subscription, plan *models.SubscriptionPlan, region string) *models.Order {
+	subID, skillID := sub.ID, plan.SkillID
+	order := &models.Order{
+		ID:             uuid.New(),
+		OrderNo:        newOrderNo(time.Now()),
+		UserID:         sub.UserID,
+		Region:         pricing.NormalizeRegion(region),
+		Status:         models.OrderStatusPending,
+		SubscriptionID: &subID,
+		Total:          plan.Price,
+	}
+	order.Items = []models.OrderItem{{
+		ID:       uuid.New(),
+		OrderID:  order.ID,
+		SkillID:  &skillID,
+		Price:    plan.Price,
+		Quantity: 1,
+		Skill:    plan.Skill,
+	}}
+	return order
+}
+
+// Renew 为不由网关自动续费的订阅生成续费订单（已保存、待支付）
+func Renew(db *gorm.DB, sub *models.Subscription) (*models.Order, error) {
+	if sub.Plan == nil {
+		var plan models.SubscriptionPlan
+		if err := db.Preload("Skill").First(&plan, "id = ?", sub.PlanID).Error; err != nil {
+			return nil, err
+		}
+		sub.Plan = &plan
+	}
+	if sub.Plan.Skill == nil {
+		sub.Plan.Skill = sub.Skill
+	}
+
+	order := newSubscriptionOrder(sub, sub.Plan, "")
+	order.PaymentMethod = sub.Provider
+	if err := Place(db, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Unsubscribe 取消订阅并取消其待支付的订单，已支付的当期结束前仍可使用
+func Unsubscribe(db *gorm.DB, sub *models.Subscription) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		locked, err := subscription.Lock(tx, sub.ID)
+		if err != nil {
+			return err
+		}
+		if err := subscription.Cancel(tx, locked, time.Now()); err != nil {
+			return err
+		}
+		*sub = *locked
+		return cancelPendingOrders(tx, sub.ID, "subscription cancelled")
+	})
+}
+
+// cancelPendingOrders 取消订阅的待支付订单
+func cancelPendingOrders(tx *gorm.DB, subscriptionID uuid.UUID, note string) error {
+	var orderNos []string
+	if err := tx.Model(&models.Order{}).
+		Where("subscription_id = ? AND status = ?", subscriptionID, models.OrderStatusPending).
+		Pluck("order_no", &orderNos).Error; err != nil {
+		return err
+	}
+	for _, orderNo := range orderNos {
+		if err := cancelPending(tx, orderNo, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelPending 取消仍待支付的订单
+func cancelPending(tx *gorm.DB, orderNo, note string) error {
+	order, err := LockByOrderNo(tx, orderNo)
+	if err != nil {
+		return err
+	}
+	if order.Status != models.OrderStatusPending {
+		return nil
+	}
+	return Transition(tx, order, models.OrderStatusCancelled, SourceSubscription, "", note)
+}
+
+// ApplySubscriptionEvent 处理网关订阅通知：扣款成功时支付首期订单或生成并支付续费订单，
+// 扣款失败时订阅进入宽限期，网关取消时订阅不再续费
+func ApplySubscriptionEvent(event *payment.SubscriptionEvent) error {
+	db := models.GetDB()
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+
+	sub, err := findEventSubscription(db, event)
+	if err != nil {
+		return err
+	}
+	if err := subscription.Link(db, sub.ID, event.Ref); err != nil {
+		return err
+	}
+	source := SourceCallback + ":" + string(event.PaymentType)
+
+	switch event.Kind {
+	case payment.SubscriptionEventRenewed:
+		// 重放的通知：扣款已入账
+		var count int64
+		db.Model(&models.Transaction{}).
+			Where("payment_channel = ? AND transaction_id = ?", string(event.PaymentType), event.TradeNo).
+			Count(&count)
+		if count > 0 {
+			return nil
+		}
+
+		// 首期扣款支付创建订阅时的订单
+		var first models.Order
+		if err := db.Where("subscription_id = ? AND status = ?", sub.ID, models.OrderStatusPending).
+			Order("created_at ASC").First(&first).Error; err == nil {
+			_, err := ApplyPayment(event.AsCallbackResult(first.OrderNo), source)
+			return err
+		}
+
+		order, err := Renew(db, sub)
+		if err != nil {
+			return err
+		}
+		if _, err := ApplyPayment(event.AsCallbackResult(order.OrderNo), source); err != nil {
+			// 并发重放导致入账失败时作废刚生成的续费订单
+			cancelErr := db.Transaction(func(tx *gorm.DB) error {
+				return cancelPending(tx, order.OrderNo, "renewal payment not applied")
+			})
+			if cancelErr != nil {
+				log.Printf("Failed to cancel renewal order %s: %v", order.OrderNo, cancelErr)
+			}
+			return err
+		}
+		return nil
+
+	case payment.SubscriptionEventPaymentFailed:
+		log.Printf("Subscription %s renewal via %s failed", sub.ID, event.PaymentType)
+		return subscription.MarkPastDue(db, sub.ID)
+
+	case payment.SubscriptionEventCancelled:
+		if sub.Status == models.SubscriptionStatusCancelled || sub.Status == models.SubscriptionStatusExpired {
+			return nil
+		}
+		return Unsubscribe(db, sub)
+	}
+	return nil
+}
+
+// findEventSubscription 按网关订阅ID查找订阅，尚未关联时按首期订单号查找
+func findEventSubscription(db *gorm.DB, event *payment.SubscriptionEvent) (*models.Subscription, error) {
+	sub, err := subscription.FindByRef(db, string(event.PaymentType), event.Ref)
+	if err == nil || !errors.Is(err, subscription.ErrNotFound) || event.OrderNo == "" {
+		return sub, err
+	}
+
+	var order models.Order
+	if err := db.Where("order_no = ? AND subscription_id IS NOT NULL", event.OrderNo).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", subscription.ErrNotFound, event.Ref)
+		}
+		return nil, err
+	}
+	var linked models.Subscription
+	if err := db.First(&linked, "id = ?", *order.SubscriptionID).Error; err != nil {
+		return nil, err
+	}
+	return &linked, nil
+}
+
+// RunSubscriptionScheduled 定时任务入口：更新到期订阅的状态并作废已失效订阅的待支付订单，
+// 为不由网关自动续费的订阅生成续费订单
+func RunSubscriptionScheduled() error {
+	db := models.GetDB()
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+	now := time.Now()
+
+	expired, err := subscription.Lapse(db, now, subscription.GracePeriod())
+	if err != nil {
+		return err
+	}
+	for _, id := range expired {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return cancelPendingOrders(tx, id, "subscription expired")
+		})
+		if err != nil {
+			log.Printf("Failed to cancel orders of expired subscription %s: %v", id, err)
+		}
+	}
+	if len(expired) > 0 {
+		log.Printf("Expired %d lapsed subscriptions", len(expired))
+	}
+
+	due, err := subscription.DueForRenewal(db, now, subscription.RenewalLead())
+	if err != nil {
+		return err
+	}
+	renewed := 0
+	for i := range due {
+		if _, err := Renew(db, &due[i]); err != nil {
+			log.Printf("Failed to create renewal order for subscription %s: %v", due[i].ID, err)
+			continue
+		}
+		renewed++
+	}
+	if renewed > 0 {
+		log.Printf("Created %d subscription renewal orders", renewed)
+	}
+	return nil
+}
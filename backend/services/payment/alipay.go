@@ -6,9 +6,12 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"sort"
 	"strings"
@@ -27,8 +30,14 @@ type AlipayClient struct {
 	PublicKey  *rsa.PublicKey
 	NotifyURL  string
 	ReturnURL  string
+	GatewayURL string
+
+	httpClient *http.Client
 }
 
+// defaultAlipayGateway 支付宝开放平台网关
+const defaultAlipayGateway = "https://openapi.alipay.com/gateway.do"
+
 // NewAlipayClient 创建支付宝客户端
 func NewAlipayClient(cfg config.AlipayConfig) (*AlipayClient, error) {
 	if cfg.AppID == "" || cfg.PrivateKey == "" || cfg.PublicKey == "" {
@@ -51,17 +60,28 @@ func NewAlipayClient(cfg config.AlipayConfig) (*AlipayClient, error) {
 	if returnURL == "" {
 		returnURL = "http://localhost:3000/orders" // 默认返回URL
 	}
+	gatewayURL := cfg.GatewayURL
+	if gatewayURL == "" {
+		gatewayURL = defaultAlipayGateway
+	}
 	return &AlipayClient{
 		AppID:      cfg.AppID,
 		PrivateKey: privateKey,
 		PublicKey:  publicKey,
 		NotifyURL:  cfg.NotifyURL,
 		ReturnURL:  returnURL,
+		GatewayURL: gatewayURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
 	}, nil
 }
 
 // CreatePayment 创建支付宝支付
 func (c *AlipayClient) CreatePayment(order *models.Order, subject string) (string, error) {
+	amount, err := chargeAmount(c.GetPaymentType(), order)
+	if err != nil {
+		return "", err
+	}
+
 	params := map[string]string{
 		"app_id":        c.AppID,
 		"method":        "alipay.trade.page.pay",
@@ -73,7 +93,7 @@ func (c *AlipayClient) CreatePayment(order *models.Order, subject string) (strin
 		"return_url":    c.ReturnURL,
 		"biz_content":   buildBizContent(order, subject),
 		"out_trade_no":  order.OrderNo,
-		"total_amount":  fmt.Sprintf("%.2f", order.TotalAmount),
+		"total_amount":  amount.Decimal(),
 		"subject":       subject,
 		"product_code":  "FAST_INSTANT_TRADE_PAY",
 	}
@@ -91,7 +111,7 @@ func (c *AlipayClient) CreatePayment(order *models.Order, subject string) (strin
 		values.Set(k, v)
 	}
 
-	return "https://openapi.alipay.com/gateway.do?" + values.Encode(), nil
+	return c.GatewayURL + "?" + values.Encode(), nil
 }
 
 // VerifyCallback 验证支付宝回调签名
@@ -149,13 +169,206 @@ func (c *AlipayClient) ProcessCallback(params url.Values) (*CallbackResult, erro
 		OutTradeNo:  outTradeNo,
 		TradeStatus: tradeStatus,
 		TotalAmount: totalAmount,
+		Currency:    SettlementCurrency(c.GetPaymentType()),
+		MerchantID:  params.Get("app_id"),
 		RawParams:   params,
 		PaymentType: c.GetPaymentType(),
 	}
 
+	// 退款引起的交易状态变更通知带有out_biz_no（即退款请求号）和refund_fee
+	if refundNo := params.Get("out_biz_no"); refundNo != "" && params.Get("refund_fee") != "" {
+		result.Refund = &RefundResult{
+			RefundNo:         refundNo,
+			ProviderRefundID: tradeNo,
+			Status:           models.RefundStatusSuccess,
+			Amount:           params.Get("refund_fee"),
+			RawParams:        params,
+			PaymentType:      c.GetPaymentType(),
+		}
+	}
+
 	return result, nil
 }
 
+// QueryPayment 通过alipay.trade.query查询交易状态
+func (c *AlipayClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	content, err := c.execute("alipay.trade.query", map[string]string{"out_trade_no": order.OrderNo})
+	if err != nil {
+		return nil, fmt.Errorf("trade query failed: %w", err)
+	}
+
+	var queryResp struct {
+		alipayResponse
+		TradeNo     string `json:"trade_no"`
+		OutTradeNo  string `json:"out_trade_no"`
+		TradeStatus string `json:"trade_status"`
+		TotalAmount string `json:"total_amount"`
+	}
+	if err := json.Unmarshal(content, &queryResp); err != nil {
+		return nil, fmt.Errorf("failed to decode trade query response: %w", err)
+	}
+
+	// 买家未打开收银台时支付宝侧尚无交易
+	if queryResp.SubCode == "ACQ.TRADE_NOT_EXIST" {
+		return &CallbackResult{
+			OutTradeNo:  order.OrderNo,
+			TradeStatus: "WAIT_BUYER_PAY",
+			RawParams:   url.Values{"sub_code": {queryResp.SubCode}},
+			PaymentType: c.GetPaymentType(),
+		}, nil
+	}
+	if err := queryResp.err(); err != nil {
+		return nil, fmt.Errorf("trade query failed: %w", err)
+	}
+
+	return &CallbackResult{
+		TradeNo:     queryResp.TradeNo,
+		OutTradeNo:  queryResp.OutTradeNo,
+		TradeStatus: queryResp.TradeStatus,
+		TotalAmount: queryResp.TotalAmount,
+		Currency:    SettlementCurrency(c.GetPaymentType()),
+		// 查询响应不含app_id，但查询以本应用签名发起，只会返回本应用的交易
+		MerchantID: c.AppID,
+		RawParams: url.Values{
+			"trade_no":     {queryResp.TradeNo},
+			"trade_status": {queryResp.TradeStatus},
+			"total_amount": {queryResp.TotalAmount},
+		},
+		PaymentType: c.GetPaymentType(),
+	}, nil
+}
+
+// Refund 通过alipay.trade.refund退款（同步返回结果，out_request_no用于部分退款去重）
+func (c *AlipayClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	content, err := c.execute("alipay.trade.refund", map[string]string{
+		"out_trade_no":   req.OrderNo,
+		"refund_amount":  req.Amount.Decimal(),
+		"out_request_no": req.RefundNo,
+		"refund_reason":  req.Reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("trade refund failed: %w", err)
+	}
+
+	var refundResp struct {
+		alipayResponse
+		TradeNo    string `json:"trade_no"`
+		FundChange string `json:"fund_change"`
+		RefundFee  string `json:"refund_fee"`
+	}
+	if err := json.Unmarshal(content, &refundResp); err != nil {
+		return nil, fmt.Errorf("failed to decode trade refund response: %w", err)
+	}
+	if err := refundResp.err(); err != nil {
+		if refundResp.rejected() {
+			return nil, fmt.Errorf("%w: trade refund failed: %v", ErrRefundRejected, err)
+		}
+		return nil, fmt.Errorf("trade refund failed: %w", err)
+	}
+
+	return &RefundResult{
+		RefundNo:         req.RefundNo,
+		ProviderRefundID: refundResp.TradeNo,
+		Status:           models.RefundStatusSuccess,
+		Amount:           req.Amount.Decimal(),
+		RawParams: url.Values{
+			"fund_change": {refundResp.FundChange},
+			"refund_fee":  {refundResp.RefundFee},
+		},
+		PaymentType: c.GetPaymentType(),
+	}, nil
+}
+
+// alipayResponse 支付宝应答公共参数
+type alipayResponse struct {
+	Code    string `json:"code"`
+	Msg     string `json:"msg"`
+	SubCode string `json:"sub_code"`
+	SubMsg  string `json:"sub_msg"`
+}
+
+// err 将业务失败转换为错误
+func (r alipayResponse) err() error {
+	if r.Code == "10000" {
+		return nil
+	}
+	return fmt.Errorf("%s %s (%s)", r.Code, r.SubCode, r.SubMsg)
+}
+
+// rejected 业务明确失败（40xxx），ACQ.SYSTEM_ERROR和20000（服务不可用）结果未知，应以同一请求号重试
+func (r alipayResponse) rejected() bool {
+	return strings.HasPrefix(r.Code, "400") && r.SubCode != "ACQ.SYSTEM_ERROR"
+}
+
+// execute 调用支付宝开放接口，验证应答签名后返回响应节点
+func (c *AlipayClient) execute(method string, bizContent map[string]string) (json.RawMessage, error) {
+	biz, err := json.Marshal(bizContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal biz content: %w", err)
+	}
+
+	params := map[string]string{
+		"app_id":      c.AppID,
+		"method":      method,
+		"charset":     "utf-8",
+		"sign_type":   "RSA2",
+		"timestamp":   time.Now().Format("2006-01-02 15:04:05"),
+		"version":     "1.0",
+		"biz_content": string(biz),
+	}
+	sign, err := c.sign(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	params["sign"] = sign
+
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+
+	resp, err := c.httpClient.PostForm(c.GatewayURL, values)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// 应答签名针对响应节点的原始JSON文本，如alipay_trade_query_response
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	content := envelope[strings.ReplaceAll(method, ".", "_")+"_response"]
+
+	var responseSign string
+	json.Unmarshal(envelope["sign"], &responseSign)
+	if err := c.verifyResponseSign(content, responseSign); err != nil {
+		return nil, fmt.Errorf("response verification failed: %w", err)
+	}
+
+	return content, nil
+}
+
+// verifyResponseSign 验证同步应答签名
+func (c *AlipayClient) verifyResponseSign(content []byte, sign string) error {
+	if len(content) == 0 || sign == "" {
+		return errors.New("missing response content or sign")
+	}
+
+	signBytes, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return fmt.Errorf("failed to decode sign: %w", err)
+	}
+
+	hashed := sha256.Sum256(content)
+	return rsa.VerifyPKCS1v15(c.PublicKey, crypto.SHA256, hashed[:], signBytes)
+}
+
 // GetPaymentType 获取支付类型
 func (c *AlipayClient) GetPaymentType() PaymentType {
 	return PaymentTypeAlipay
@@ -167,11 +380,11 @@ func (c *AlipayClient) GetPaymentType() PaymentType {
 func buildBizContent(order *models.Order, subject string) string {
 	return fmt.Sprintf(`{
 		"out_trade_no": "%s",
-		"total_amount": "%.2f",
+		"total_amount": "%s",
 		"subject": "%s",
 		"product_code": "FAST_INSTANT_TRADE_PAY",
 		"timeout_express": "30m"
-	}`, order.OrderNo, order.TotalAmount, subject)
+	}`, order.OrderNo, order.Total.Decimal(), subject)
 }
 
 // sign 生成签名
@@ -307,8 +520,8 @@ func NewMockAlipayClient() *MockAlipayClient {
 func (c *MockAlipayClient) CreatePayment(order *models.Order, subject string) (string, error) {
 	// 生成模拟支付URL
 	paymentID := uuid.New().String()
-	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%.2f",
-		paymentID, order.OrderNo, order.TotalAmount), nil
+	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%s",
+		paymentID, order.OrderNo, order.Total.Decimal()), nil
 }
 
 // VerifyCallback 验证模拟回调
@@ -329,6 +542,16 @@ func (c *MockAlipayClient) ProcessCallback(params url.Values) (*CallbackResult,
 	}, nil
 }
 
+// QueryPayment 模拟支付不支持查询
+func (c *MockAlipayClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	return nil, ErrQueryNotSupported
+}
+
+// Refund 模拟退款，直接返回成功
+func (c *MockAlipayClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	return newMockRefundResult(req, c.GetPaymentType()), nil
+}
+
 // GetPaymentType 获取支付类型
 func (c *MockAlipayClient) GetPaymentType() PaymentType {
 	return PaymentTypeMock
@@ -0,0 +1,72 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"skillhub/models"
+)
+
+// ErrCurrencyNotSupported 支付方式不支持订单币种
+var ErrCurrencyNotSupported = errors.New("currency not supported by payment provider")
+
+// supportedCurrencies 各支付方式可收取的币种，第一个为默认结算币种
+var supportedCurrencies = map[PaymentType][]string{
+	PaymentTypeAlipay: {"CNY"},
+	PaymentTypeWeChat: {"CNY"},
+	PaymentTypeStripe: {"USD", "EUR", "GBP", "JPY", "HKD", "SGD", "AUD", "CAD", "CNY"},
+	PaymentTypePayPal: {"USD", "EUR", "GBP", "JPY", "HKD", "SGD", "AUD", "CAD"},
+}
+
+// SupportedCurrencies 支付方式支持的币种，模拟支付返回空（不限制）
+func SupportedCurrencies(paymentType PaymentType) []string {
+	return supportedCurrencies[paymentType]
+}
+
+// SupportsCurrency 支付方式是否支持该币种
+func SupportsCurrency(paymentType PaymentType, currency string) bool {
+	currencies, ok := supportedCurrencies[paymentType]
+	if !ok {
+		return true
+	}
+	for _, c := range currencies {
+		if strings.EqualFold(c, currency) {
+			return true
+		}
+	}
+	return false
+}
+
+// SettlementCurrency 支付方式的默认结算币种（订单未记录币种时使用）
+func SettlementCurrency(paymentType PaymentType) string {
+	if currencies := supportedCurrencies[paymentType]; len(currencies) > 0 {
+		return currencies[0]
+	}
+	return ""
+}
+
+// OrderCurrency 订单的计价币种，历史订单未记录时按支付方式的结算币种
+func OrderCurrency(order *models.Order, paymentType PaymentType) string {
+	if order.Total.Currency != "" {
+		return strings.ToUpper(order.Total.Currency)
+	}
+	return SettlementCurrency(paymentType)
+}
+
+// chargeAmount 下单前检查订单币种是否可由该支付方式收取，返回应收金额
+func chargeAmount(paymentType PaymentType, order *models.Order) (models.Money, error) {
+	currency := OrderCurrency(order, paymentType)
+	if !SupportsCurrency(paymentType, currency) {
+		return models.Money{}, fmt.Errorf("%w: %s does not accept %s", ErrCurrencyNotSupported, paymentType, currency)
+	}
+	return models.NewMoney(order.Total.Amount, currency), nil
+}
+
+// refundAmount 退款金额，历史订单未记录币种时按支付方式的结算币种
+func refundAmount(paymentType PaymentType, req *RefundRequest) models.Money {
+	if req.Amount.Currency == "" {
+		return models.NewMoney(req.Amount.Amount, SettlementCurrency(paymentType))
+	}
+	return req.Amount
+}
@@ -0,0 +1,50 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+
+	"skillhub/config"
+	"skillhub/models"
+)
+
+func TestGetPaymentServiceForCurrency(t *testing.T) {
+	// 未配置任何网关时仅沙箱模式使用模拟支付
+	if _, err := GetPaymentServiceForCurrency(config.Config{}, "EUR"); !errors.Is(err, ErrProviderNotAvailable) {
+		t.Fatalf("expected ErrProviderNotAvailable outside sandbox mode, got %v", err)
+	}
+	sandbox := config.Config{}
+	sandbox.Payment.Sandbox = true
+	service, err := GetPaymentServiceForCurrency(sandbox, "EUR")
+	if err != nil || service.GetPaymentType() != PaymentTypeMock {
+		t.Fatalf("expected mock service, got %v, %v", service, err)
+	}
+
+	cfg := config.Config{}
+	cfg.Payment.PayPal.ClientID = "client"
+	cfg.Payment.PayPal.ClientSecret = "secret"
+
+	service, err = GetPaymentServiceForCurrency(cfg, "usd")
+	if err != nil || service.GetPaymentType() != PaymentTypePayPal {
+		t.Errorf("expected paypal for USD, got %v, %v", service, err)
+	}
+	if _, err := GetPaymentServiceForCurrency(cfg, "CNY"); !errors.Is(err, ErrCurrencyNotSupported) {
+		t.Errorf("expected ErrCurrencyNotSupported for CNY, got %v", err)
+	}
+
+	cfg.Payment.Stripe.SecretKey = "sk_test"
+	service, err = GetPaymentServiceForCurrency(cfg, "CNY")
+	if err != nil || service.GetPaymentType() != PaymentTypeStripe {
+		t.Errorf("expected stripe for CNY, got %v, %v", service, err)
+	}
+}
+
+func TestChargeAmount(t *testing.T) {
+	if _, err := chargeAmount(PaymentTypeAlipay, &models.Order{Total: models.NewMoney(1999, "USD")}); !errors.Is(err, ErrCurrencyNotSupported) {
+		t.Errorf("expected alipay to reject USD, got %v", err)
+	}
+	amount, err := chargeAmount(PaymentTypeStripe, &models.Order{Total: models.Money{Amount: 1999}})
+	if err != nil || amount != models.NewMoney(1999, "USD") {
+		t.Errorf("expected legacy order to settle in USD, got %v, %v", amount, err)
+	}
+}
@@ -1,10 +1,16 @@
 package payment
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"sync"
 
 	"skillhub/config"
 	"skillhub/models"
+
+	"github.com/google/uuid"
 )
 
 // PaymentService 统一支付服务接口
@@ -15,10 +21,22 @@ type PaymentService interface {
 	VerifyCallback(params url.Values) (bool, error)
 	// ProcessCallback 处理支付回调
 	ProcessCallback(params url.Values) (*CallbackResult, error)
+	// QueryPayment 向网关查询订单的实际支付状态（回调丢失时用于对账）
+	QueryPayment(order *models.Order) (*CallbackResult, error)
+	// Refund 发起全额或部分退款
+	Refund(req *RefundRequest) (*RefundResult, error)
 	// GetPaymentType 获取支付类型
 	GetPaymentType() PaymentType
 }
 
+// ErrQueryNotSupported 支付方式不支持状态查询（如模拟支付、PayPal订阅订单）
+var ErrQueryNotSupported = errors.New("payment query not supported")
+
+// NotificationProcessor 支付通知处理接口（签名位于HTTP头、报文为JSON的通知，如微信支付APIv3）
+type NotificationProcessor interface {
+	ProcessNotification(header http.Header, body []byte) (*CallbackResult, error)
+}
+
 // PaymentType 支付类型枚举
 type PaymentType string
 
@@ -36,87 +54,242 @@ type CallbackResult struct {
 	OutTradeNo  string      `json:"out_trade_no"`
 	TradeStatus string      `json:"trade_status"`
 	TotalAmount string      `json:"total_amount"`
+	Currency    string      `json:"currency"`    // 网关上报的币种，为空表示未上报
+	MerchantID  string      `json:"merchant_id"` // 网关上报的商户号/应用ID，为空表示未上报
 	RawParams   url.Values  `json:"raw_params"`
 	PaymentType PaymentType `json:"payment_type"`
+
+	// Refund 退款通知时不为空，此时其余字段仅供参考
+	Refund *RefundResult `json:"refund,omitempty"`
+	// Subscription 网关订阅通知（续费扣款、扣款失败、取消）时不为空，此时其余字段仅供参考
+	Subscription *SubscriptionEvent `json:"subscription,omitempty"`
+	// SubscriptionRef 订阅首期支付完成时网关侧的订阅ID
+	SubscriptionRef string `json:"subscription_ref,omitempty"`
+}
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	OrderNo     string       // 商户订单号
+	TradeNo     string       // 网关交易号（支付成功时记录的交易ID）
+	PaymentRef  string       // 网关侧订单号/会话ID
+	RefundNo    string       // 商户退款单号，重复提交时网关按此去重
+	Amount      models.Money // 本次退款金额
+	TotalAmount models.Money // 原订单金额
+	Reason      string
+}
+
+// ErrRefundRejected 网关明确拒绝了退款申请（交易不可退、余额不足、参数错误等），退款没有发生。
+// 超时、网关5xx等结果未知的错误不包装此错误，应保留退款单并以同一退款单号重试
+var ErrRefundRejected = errors.New("refund rejected by provider")
+
+// apiError 网关HTTP接口返回的非2xx应答
+type apiError struct {
+	service string
+	status  int
+	body    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s api error (status %d): %s", e.service, e.status, e.body)
+}
+
+// refundError 网关以4xx应答明确拒绝时包装为ErrRefundRejected；
+// 409（同一幂等键的请求仍在处理）和429（限流）与其他错误一样视为结果未知，原样返回
+func refundError(err error) error {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.status >= 400 && apiErr.status < 500 &&
+		apiErr.status != http.StatusConflict && apiErr.status != http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %v", ErrRefundRejected, err)
+	}
+	return err
+}
+
+// RefundResult 退款结果（同步应答或异步通知）
+type RefundResult struct {
+	RefundNo         string              `json:"refund_no"`
+	ProviderRefundID string              `json:"provider_refund_id"`
+	Status           models.RefundStatus `json:"status"`
+	Amount           string              `json:"amount"`
+	RawParams        url.Values          `json:"raw_params"`
+	PaymentType      PaymentType         `json:"payment_type"`
+}
+
+// IsPaid 网关状态是否表示已支付
+func (r *CallbackResult) IsPaid() bool {
+	switch r.TradeStatus {
+	case "TRADE_SUCCESS", "TRADE_FINISHED", "succeeded", "COMPLETED":
+		return true
+	}
+	return false
+}
+
+// Amount 按币种精确解析网关上报的金额，网关未上报币种时使用currency
+func (r *CallbackResult) Amount(currency string) (models.Money, error) {
+	if r.Currency != "" {
+		currency = r.Currency
+	}
+	return models.ParseMoney(r.TotalAmount, currency)
+}
+
+// IsClosed 网关状态是否表示交易已关闭（未支付且不会再支付）
+func (r *CallbackResult) IsClosed() bool {
+	switch r.TradeStatus {
+	case "TRADE_CLOSED", "TRADE_CANCELLED", "CANCELLED", "expired", "VOIDED":
+		return true
+	}
+	return false
+}
+
+// newMockRefundResult 模拟客户端的退款结果（立即成功）
+func newMockRefundResult(req *RefundRequest, paymentType PaymentType) *RefundResult {
+	return &RefundResult{
+		RefundNo:         req.RefundNo,
+		ProviderRefundID: "mock_refund_" + uuid.New().String()[:8],
+		Status:           models.RefundStatusSuccess,
+		Amount:           req.Amount.Decimal(),
+		RawParams:        url.Values{},
+		PaymentType:      paymentType,
+	}
 }
 
 // Config, AlipayConfig, WeChatPayConfig 等类型在 config 包中定义，这里不再重复定义
 
-// GetPaymentService 获取支付服务（工厂函数）
+// GetPaymentService 获取支付服务（工厂函数）。
+// 网关未配置时只有沙箱模式下才以模拟客户端代替，否则返回ErrProviderNotAvailable，
+// 以免模拟客户端（验签总是通过）确认未经网关支付的订单
 func GetPaymentService(paymentType PaymentType, cfg config.Config) (PaymentService, error) {
 	switch paymentType {
 	case PaymentTypeAlipay:
 		alipayCfg := cfg.Payment.Alipay
 		if alipayCfg.AppID == "" || alipayCfg.PrivateKey == "" || alipayCfg.PublicKey == "" {
-			return NewMockAlipayClient(), nil
+			return sandboxService(cfg, paymentType, NewMockAlipayClient())
 		}
 		return NewAlipayClient(alipayCfg)
 	case PaymentTypeWeChat:
 		// 如果配置完整，使用真实微信支付客户端
 		wechatCfg := cfg.Payment.WeChatPay
 		if wechatCfg.MchID != "" && wechatCfg.APIKey != "" {
-			client, err := NewWeChatPayClient(wechatCfg)
+			client, err := wechatClients.get(wechatCfg, NewWeChatPayClient)
 			if err == nil {
 				return client, nil
 			}
+			if !cfg.Payment.Sandbox {
+				return nil, err
+			}
 		}
 		// 否则使用模拟微信支付客户端
-		return NewMockWeChatPayClient(), nil
+		return sandboxService(cfg, paymentType, NewMockWeChatPayClient())
 	case PaymentTypeStripe:
 		stripeCfg := cfg.Payment.Stripe
 		if stripeCfg.SecretKey == "" {
-			return NewMockStripeClient(), nil
+			return sandboxService(cfg, paymentType, NewMockStripeClient())
 		}
-		return NewStripeClient(stripeCfg)
+		client, err := NewStripeClient(stripeCfg)
+		if err != nil {
+			return nil, err
+		}
+		client.Sandbox = cfg.Payment.Sandbox
+		return client, nil
 	case PaymentTypePayPal:
 		paypalCfg := cfg.Payment.PayPal
 		if paypalCfg.ClientID == "" || paypalCfg.ClientSecret == "" {
-			return NewMockPayPalClient(), nil
+			return sandboxService(cfg, paymentType, NewMockPayPalClient())
 		}
-		return NewPayPalClient(paypalCfg)
+		return paypalClients.get(paypalCfg, NewPayPalClient)
 	default:
+		return sandboxService(cfg, paymentType, NewMockAlipayClient())
+	}
+}
+
+// clientCache 按渠道配置缓存的支付客户端，同一配置在进程内只创建一次。
+// 客户端上挂有需要跨请求保留的状态（微信支付平台证书、PayPal访问令牌），每次请求新建会使其失效；
+// 更换私钥文件后需重启进程
+type clientCache[C comparable, T any] struct {
+	mu      sync.Mutex
+	clients map[C]T
+}
+
+// 微信支付、PayPal客户端缓存
+var (
+	wechatClients = &clientCache[config.WeChatPayConfig, *WeChatPayClient]{}
+	paypalClients = &clientCache[config.PayPalConfig, *PayPalClient]{}
+)
+
+// get 返回该配置对应的客户端，不存在时用newClient创建；创建失败不缓存，下次请求重试
+func (c *clientCache[C, T]) get(cfg C, newClient func(C) (T, error)) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[cfg]; ok {
+		return client, nil
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		return client, err
+	}
+	if c.clients == nil {
+		c.clients = make(map[C]T)
+	}
+	c.clients[cfg] = client
+	return client, nil
+}
+
+// sandboxService 沙箱模式下返回模拟客户端，否则返回ErrProviderNotAvailable
+func sandboxService(cfg config.Config, paymentType PaymentType, mock PaymentService) (PaymentService, error) {
+	if !cfg.Payment.Sandbox {
+		return nil, fmt.Errorf("%w: %s is not configured", ErrProviderNotAvailable, paymentType)
+	}
+	return mock, nil
+}
+
+// GetPaymentServiceForCurrency 按默认优先级选择第一个支持该币种的已配置支付服务，均未配置时沙箱模式下使用模拟支付
+func GetPaymentServiceForCurrency(cfg config.Config, currency string) (PaymentService, error) {
+	services := configuredServices(cfg)
+	if len(services) == 0 {
+		if !cfg.Payment.Sandbox {
+			return nil, fmt.Errorf("%w: no payment provider is configured", ErrProviderNotAvailable)
+		}
 		return NewMockAlipayClient(), nil
 	}
+	for _, service := range services {
+		if SupportsCurrency(service.GetPaymentType(), currency) {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no configured provider accepts %s", ErrCurrencyNotSupported, currency)
 }
 
-// GetDefaultPaymentService 获取默认支付服务（根据配置自动选择）
-func GetDefaultPaymentService(cfg config.Config) PaymentService {
-	// 优先使用支付宝
+// configuredServices 按优先级（支付宝、微信支付、Stripe、PayPal）返回配置完整的支付服务
+func configuredServices(cfg config.Config) []PaymentService {
+	var services []PaymentService
+
 	alipayCfg := cfg.Payment.Alipay
 	if alipayCfg.AppID != "" && alipayCfg.PrivateKey != "" && alipayCfg.PublicKey != "" {
-		client, err := NewAlipayClient(alipayCfg)
-		if err == nil {
-			return client
+		if client, err := NewAlipayClient(alipayCfg); err == nil {
+			services = append(services, client)
 		}
 	}
 
-	// 其次使用微信支付
 	wechatCfg := cfg.Payment.WeChatPay
 	if wechatCfg.MchID != "" && wechatCfg.APIKey != "" {
-		client, err := NewWeChatPayClient(wechatCfg)
-		if err == nil {
-			return client
+		if client, err := wechatClients.get(wechatCfg, NewWeChatPayClient); err == nil {
+			services = append(services, client)
 		}
 	}
 
-	// 然后使用Stripe
 	stripeCfg := cfg.Payment.Stripe
 	if stripeCfg.SecretKey != "" {
-		client, err := NewStripeClient(stripeCfg)
-		if err == nil {
-			return client
+		if client, err := NewStripeClient(stripeCfg); err == nil {
+			client.Sandbox = cfg.Payment.Sandbox
+			services = append(services, client)
 		}
 	}
 
-	// 最后使用PayPal
 	paypalCfg := cfg.Payment.PayPal
 	if paypalCfg.ClientID != "" && paypalCfg.ClientSecret != "" {
-		client, err := NewPayPalClient(paypalCfg)
-		if err == nil {
-			return client
+		if client, err := paypalClients.get(paypalCfg, NewPayPalClient); err == nil {
+			services = append(services, client)
 		}
 	}
 
-	// 使用模拟支付作为后备
-	return NewMockAlipayClient()
+	return services
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package payment
 
 import (
+	"errors"
 	"net/url"
 	"testing"
 
@@ -18,7 +19,7 @@ func TestMockAlipayClient(t *testing.T) {
 		ID:          uuid.New(),
 		OrderNo:     "TEST123456",
 		UserID:      uuid.New(),
-		TotalAmount: 99.99,
+		Total:       models.NewMoney(9999, "CNY"),
 		Status:      models.OrderStatusPending,
 	}
 
@@ -73,7 +74,7 @@ func TestMockWeChatPayClient(t *testing.T) {
 		ID:          uuid.New(),
 		OrderNo:     "TEST654321",
 		UserID:      uuid.New(),
-		TotalAmount: 88.88,
+		Total:       models.NewMoney(8888, "CNY"),
 		Status:      models.OrderStatusPending,
 	}
 
@@ -113,8 +114,8 @@ func TestMockWeChatPayClient(t *testing.T) {
 	}
 }
 
-func TestGetDefaultPaymentService(t *testing.T) {
-	// 创建测试配置（所有支付配置为空）
+func TestGetPaymentService(t *testing.T) {
+	// 创建测试配置（沙箱模式）
 	cfg := &config.Config{
 		Payment: config.PaymentConfig{
 			Alipay: config.AlipayConfig{
@@ -122,43 +123,13 @@ func TestGetDefaultPaymentService(t *testing.T) {
 				PrivateKey: "",
 				PublicKey:  "",
 			},
-			WeChatPay: config.WeChatPayConfig{
-				MchID:  "",
-				APIKey: "",
-			},
-			Stripe: config.StripeConfig{
-				SecretKey: "",
-			},
-			PayPal: config.PayPalConfig{
-				ClientID:     "",
-				ClientSecret: "",
-			},
+			Sandbox: true,
 		},
 	}
 
-	// 获取默认支付服务（应该返回模拟支付）
-	service := GetDefaultPaymentService(*cfg)
-	if service == nil {
-		t.Fatal("GetDefaultPaymentService should not return nil")
-	}
-
-	// 验证服务类型（应该是模拟支付）
-	paymentType := service.GetPaymentType()
-	if paymentType != PaymentTypeMock && paymentType != PaymentTypeAlipay {
-		t.Logf("Payment type: %s (expected mock or alipay for empty config)", paymentType)
-	}
-}
-
-func TestGetPaymentService(t *testing.T) {
-	// 创建测试配置
-	cfg := &config.Config{
-		Payment: config.PaymentConfig{
-			Alipay: config.AlipayConfig{
-				AppID:      "",
-				PrivateKey: "",
-				PublicKey:  "",
-			},
-		},
+	// 非沙箱模式下未配置的网关不可用
+	if _, err := GetPaymentService(PaymentTypeAlipay, config.Config{}); !errors.Is(err, ErrProviderNotAvailable) {
+		t.Fatalf("expected ErrProviderNotAvailable outside sandbox mode, got %v", err)
 	}
 
 	// 测试获取支付宝服务（配置为空，应该返回模拟支付）
@@ -0,0 +1,227 @@
+package paymenttest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"skillhub/models"
+	"skillhub/services/payment"
+)
+
+// openAlipay 买家打开电脑网站支付链接：验证商户签名后创建交易
+func (g *Gateway) openAlipay(checkoutURL string) (*checkout, error) {
+	parsed, err := url.Parse(checkoutURL)
+	if err != nil {
+		return nil, err
+	}
+	params := parsed.Query()
+	if params.Get("method") != "alipay.trade.page.pay" || params.Get("app_id") != AlipayAppID {
+		return nil, errors.New("not an alipay page pay url for this merchant")
+	}
+	if err := verifyRSA(&g.alipayAppKey.PublicKey, alipaySignContent(params, "sign"), params.Get("sign")); err != nil {
+		return nil, fmt.Errorf("invalid merchant signature: %w", err)
+	}
+
+	orderNo := params.Get("out_trade_no")
+	amount, err := models.ParseMoney(params.Get("total_amount"), "CNY")
+	if err != nil || orderNo == "" {
+		return nil, fmt.Errorf("invalid page pay request: %v", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if co, ok := g.byOrderNo[orderNo]; ok && co.provider == payment.PaymentTypeAlipay {
+		return co, nil
+	}
+	co := &checkout{
+		provider:  payment.PaymentTypeAlipay,
+		id:        orderNo,
+		orderNo:   orderNo,
+		amount:    amount,
+		notifyURL: params.Get("notify_url"),
+	}
+	g.register(co)
+	return co, nil
+}
+
+// payAlipay 付款成功后通知TRADE_SUCCESS，拒付时通知交易关闭。调用方须持有g.mu
+func (g *Gateway) payAlipay(co *checkout) []*event {
+	co.tradeNo = "2026" + strings.ToUpper(randomHex(24))
+	co.status = "TRADE_SUCCESS"
+	if co.scenario.Decline {
+		co.status = "TRADE_CLOSED"
+	}
+
+	at := signedAt(co.scenario)
+	params := url.Values{
+		"notify_time":  {at.Format("2006-01-02 15:04:05")},
+		"notify_type":  {"trade_status_sync"},
+		"notify_id":    {randomHex(32)},
+		"app_id":       {AlipayAppID},
+		"charset":      {"utf-8"},
+		"version":      {"1.0"},
+		"trade_no":     {co.tradeNo},
+		"out_trade_no": {co.orderNo},
+		"trade_status": {co.status},
+		"total_amount": {co.paid.Decimal()},
+	}
+	if !co.scenario.Decline {
+		params.Set("receipt_amount", co.paid.Decimal())
+		params.Set("gmt_payment", at.Format("2006-01-02 15:04:05"))
+	}
+
+	// 异步通知的待签名串不含sign和sign_type
+	key := g.alipayKey
+	if co.scenario.BadSignature {
+		key = g.alipayAppKey
+	}
+	params.Set("sign", signRSA(key, alipaySignContent(params, "sign", "sign_type")))
+	params.Set("sign_type", "RSA2")
+
+	return []*event{{
+		provider: co.provider,
+		id:       params.Get("notify_id"),
+		orderNo:  co.orderNo,
+		url:      g.webhookURL(co),
+		body:     []byte(params.Encode()),
+		header:   http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+	}}
+}
+
+// handleAlipay 支付宝开放平台网关：交易查询和退款，应答按响应节点签名
+func (g *Gateway) handleAlipay(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+	method := params.Get("method")
+	if params.Get("app_id") != AlipayAppID {
+		g.alipayRespond(w, method, alipayError("40002", "isv.invalid-app-id", "无效的AppID参数"))
+		return
+	}
+	// 请求的待签名串只去掉sign
+	if err := verifyRSA(&g.alipayAppKey.PublicKey, alipaySignContent(params, "sign"), params.Get("sign")); err != nil {
+		g.alipayRespond(w, method, alipayError("40002", "isv.invalid-signature", "验签出错"))
+		return
+	}
+
+	var biz map[string]string
+	if err := json.Unmarshal([]byte(params.Get("biz_content")), &biz); err != nil {
+		g.alipayRespond(w, method, alipayError("40002", "isv.invalid-parameter", "biz_content格式错误"))
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	co, ok := g.byOrderNo[biz["out_trade_no"]]
+	if ok && co.provider != payment.PaymentTypeAlipay {
+		ok = false
+	}
+
+	switch method {
+	case "alipay.trade.query":
+		if !ok {
+			g.alipayRespond(w, method, alipayError("40004", "ACQ.TRADE_NOT_EXIST", "交易不存在"))
+			return
+		}
+		status, amount := co.status, co.amount
+		if status == statusOpen {
+			status = "WAIT_BUYER_PAY"
+		} else {
+			amount = co.paid
+		}
+		g.alipayRespond(w, method, map[string]string{
+			"code":         "10000",
+			"msg":          "Success",
+			"trade_no":     co.tradeNo,
+			"out_trade_no": co.orderNo,
+			"trade_status": status,
+			"total_amount": amount.Decimal(),
+		})
+
+	case "alipay.trade.refund":
+		if !ok || co.status != "TRADE_SUCCESS" {
+			g.alipayRespond(w, method, alipayError("40004", "ACQ.TRADE_STATUS_ERROR", "交易状态不合法"))
+			return
+		}
+		amount, err := models.ParseMoney(biz["refund_amount"], "CNY")
+		if err != nil || addRefund(co, biz["out_request_no"], co.tradeNo, amount.Amount) == nil {
+			g.alipayRespond(w, method, alipayError("40004", "ACQ.REFUND_AMT_NOT_EQUAL_TOTAL", "退款金额不合法"))
+			return
+		}
+		g.alipayRespond(w, method, map[string]string{
+			"code":         "10000",
+			"msg":          "Success",
+			"trade_no":     co.tradeNo,
+			"out_trade_no": co.orderNo,
+			"fund_change":  "Y",
+			"refund_fee":   models.NewMoney(refundedTotal(co), "CNY").Decimal(),
+		})
+
+	default:
+		g.alipayRespond(w, method, alipayError("40004", "isv.invalid-method", "不存在的方法名"))
+	}
+}
+
+// alipayRespond 按 {"<method>_response": {...}, "sign": "..."} 格式应答，签名针对响应节点的原始JSON
+func (g *Gateway) alipayRespond(w http.ResponseWriter, method string, content interface{}) {
+	node, _ := json.Marshal(content)
+	body, _ := json.Marshal(map[string]interface{}{
+		strings.ReplaceAll(method, ".", "_") + "_response": json.RawMessage(node),
+		"sign": signRSA(g.alipayKey, string(node)),
+	})
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.Write(body)
+}
+
+func alipayError(code, subCode, subMsg string) map[string]string {
+	return map[string]string{"code": code, "msg": "Business Failed", "sub_code": subCode, "sub_msg": subMsg}
+}
+
+// alipaySignContent 按参数名排序拼接非空参数，exclude中的参数不参与签名
+func alipaySignContent(params url.Values, exclude ...string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		skip := params.Get(k) == ""
+		for _, e := range exclude {
+			skip = skip || k == e
+		}
+		if !skip {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + params.Get(k)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// signRSA SHA256withRSA签名，Base64编码
+func signRSA(key *rsa.PrivateKey, message string) string {
+	hashed := sha256.Sum256([]byte(message))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// verifyRSA 验证SHA256withRSA签名
+func verifyRSA(key *rsa.PublicKey, message, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(message))
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig)
+}
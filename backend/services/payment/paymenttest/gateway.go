@@ -0,0 +1,466 @@
+// Package paymenttest 提供进程内的模拟支付网关，用于端到端测试。
+//
+// Gateway按真实接口模拟支付宝、Stripe、PayPal和微信支付的下单、查询、退款接口，
+// 并像真实网关一样对回调签名（支付宝RSA2、Stripe-Signature、PayPal传输签名、微信支付APIv3签名和加密），
+// 因此可以在不关闭验签的情况下离线测试"下单→回调→发放使用权"的完整流程。
+// 通过Scenario还可模拟拒付、重复回调、延迟回调和金额不符等异常情况。
+package paymenttest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/payment"
+
+	"github.com/google/uuid"
+)
+
+// 模拟网关使用的商户身份
+const (
+	AlipayAppID      = "2021000000000001"
+	WeChatAppID      = "wx_sim_app"
+	WeChatMchID      = "1900000001"
+	PayPalMerchantID = "SIMMERCHANT01"
+
+	wechatAPIv3Key = "sim0123456789abcdef0123456789abc"
+)
+
+// Scenario 买家付款时网关的行为，零值为正常付款并按时送达一次回调
+type Scenario struct {
+	Decline      bool          // 付款被拒绝（支付宝通知交易关闭，PayPal捕获被拒，Stripe和微信支付不发送回调）
+	AmountDelta  int64         // 网关上报的实付金额与下单金额之差（最小单位），模拟金额不符
+	Duplicates   int           // 额外重复投递同一回调的次数，模拟网关重试
+	Delay        time.Duration // 延迟投递回调；延迟的回调在后台投递，调用Wait等待投递完成
+	Age          time.Duration // 回调签名时间早于投递时间，模拟网关在很久之后才送达
+	BadSignature bool          // 回调签名无效，模拟伪造的回调
+}
+
+// Delivery 一次回调投递的结果
+type Delivery struct {
+	Provider   payment.PaymentType
+	EventID    string
+	OrderNo    string
+	URL        string
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+// OK 商户应答2xx，网关视为投递成功
+func (d Delivery) OK() bool {
+	return d.Err == nil && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// checkout 网关侧的一笔待付款或已付款交易
+type checkout struct {
+	provider  payment.PaymentType
+	id        string // 支付宝为商户订单号，Stripe为会话ID，PayPal为订单ID，微信支付为预支付ID
+	orderNo   string
+	amount    models.Money // 下单金额
+	paid      models.Money // 实际扣款金额
+	notifyURL string
+	status    string // 各网关自己的交易状态
+	tradeNo   string // 网关交易号（Stripe为PaymentIntent，PayPal为捕获ID）
+	scenario  Scenario
+	refunds   map[string]*refund // 按退款请求号（幂等键），重复请求返回同一笔退款
+}
+
+// refund 网关侧的退款
+type refund struct {
+	id     string
+	amount int64
+}
+
+// Gateway 进程内的模拟支付网关
+type Gateway struct {
+	URL string
+
+	server     *httptest.Server
+	dir        string
+	httpClient *http.Client
+
+	alipayKey    *rsa.PrivateKey // 支付宝平台私钥，签名回调和应答
+	alipayAppKey *rsa.PrivateKey // 商户应用私钥，由客户端签名请求
+
+	stripeSecretKey     string
+	stripeWebhookSecret string
+
+	paypalClientID  string
+	paypalSecret    string
+	paypalWebhookID string
+	paypalKey       *rsa.PrivateKey // 签名Webhook传输
+	paypalToken     string
+
+	wechatPlatformKey  *rsa.PrivateKey
+	wechatPlatformCert *x509.Certificate
+	wechatSerial       string
+	wechatMerchantKey  *rsa.PrivateKey
+	wechatMerchantPath string
+
+	mu         sync.Mutex
+	webhooks   map[payment.PaymentType]string
+	checkouts  map[string]*checkout // 按网关侧ID
+	byOrderNo  map[string]*checkout // 按商户订单号
+	deliveries []Delivery
+	pending    sync.WaitGroup
+}
+
+// keyPool 同一进程内的模拟网关共用RSA密钥，避免每个测试重复生成
+var keyPool struct {
+	once sync.Once
+	keys [5]*rsa.PrivateKey
+	err  error
+}
+
+func loadKeys() ([5]*rsa.PrivateKey, error) {
+	keyPool.once.Do(func() {
+		for i := range keyPool.keys {
+			if keyPool.keys[i], keyPool.err = rsa.GenerateKey(rand.Reader, 2048); keyPool.err != nil {
+				keyPool.err = fmt.Errorf("failed to generate key: %w", keyPool.err)
+				return
+			}
+		}
+	})
+	return keyPool.keys, keyPool.err
+}
+
+// New 启动模拟网关，使用完毕后调用Close
+func New() (*Gateway, error) {
+	g := &Gateway{
+		httpClient:          &http.Client{Timeout: 10 * time.Second},
+		stripeSecretKey:     "sk_test_sim_" + randomHex(12),
+		stripeWebhookSecret: "whsec_sim_" + randomHex(16),
+		paypalClientID:      "sim-client-" + randomHex(6),
+		paypalSecret:        "sim-secret-" + randomHex(12),
+		paypalWebhookID:     "WH-SIM-" + strings.ToUpper(randomHex(8)),
+		paypalToken:         "A21AA" + randomHex(16),
+		webhooks:            make(map[payment.PaymentType]string),
+		checkouts:           make(map[string]*checkout),
+		byOrderNo:           make(map[string]*checkout),
+	}
+
+	keys, err := loadKeys()
+	if err != nil {
+		return nil, err
+	}
+	g.alipayKey, g.alipayAppKey, g.paypalKey, g.wechatPlatformKey, g.wechatMerchantKey = keys[0], keys[1], keys[2], keys[3], keys[4]
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Simulated Platform"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &g.wechatPlatformKey.PublicKey, g.wechatPlatformKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create platform certificate: %w", err)
+	}
+	if g.wechatPlatformCert, err = x509.ParseCertificate(der); err != nil {
+		return nil, err
+	}
+	g.wechatSerial = strings.ToUpper(g.wechatPlatformCert.SerialNumber.Text(16))
+
+	// 微信支付客户端从文件读取商户私钥
+	if g.dir, err = os.MkdirTemp("", "paymenttest"); err != nil {
+		return nil, err
+	}
+	g.wechatMerchantPath = filepath.Join(g.dir, "apiclient_key.pem")
+	if err := os.WriteFile(g.wechatMerchantPath, privateKeyPEM(g.wechatMerchantKey), 0600); err != nil {
+		os.RemoveAll(g.dir)
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/alipay/gateway.do", g.handleAlipay)
+	mux.HandleFunc("/stripe/", g.handleStripe)
+	mux.HandleFunc("/paypal/", g.handlePayPal)
+	mux.HandleFunc("/wechat/", g.handleWeChat)
+	mux.HandleFunc("/checkout/", g.handleCheckoutPage)
+	g.server = httptest.NewServer(mux)
+	g.URL = g.server.URL
+	return g, nil
+}
+
+// Close 等待未完成的回调投递后关闭网关
+func (g *Gateway) Close() {
+	g.pending.Wait()
+	g.server.Close()
+	os.RemoveAll(g.dir)
+}
+
+// SetWebhookURL 设置回调投递地址，如 http://host/api/v1/payment/callback/stripe。
+// 支付宝和微信支付优先使用下单时携带的notify_url
+func (g *Gateway) SetWebhookURL(provider payment.PaymentType, url string) {
+	g.mu.Lock()
+	g.webhooks[provider] = url
+	g.mu.Unlock()
+}
+
+// RouteWebhooks 将四个网关的回调投递到 base/<网关>，base如 http://host/api/v1/payment/callback
+func (g *Gateway) RouteWebhooks(base string) {
+	base = strings.TrimRight(base, "/")
+	for _, provider := range []payment.PaymentType{payment.PaymentTypeAlipay, payment.PaymentTypeStripe, payment.PaymentTypePayPal, payment.PaymentTypeWeChat} {
+		g.SetWebhookURL(provider, base+"/"+string(provider))
+	}
+}
+
+// Config 返回指向模拟网关的支付配置，客户端以真实模式运行并验证所有签名
+func (g *Gateway) Config() config.PaymentConfig {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return config.PaymentConfig{
+		Alipay: config.AlipayConfig{
+			AppID:      AlipayAppID,
+			PrivateKey: string(privateKeyPEM(g.alipayAppKey)),
+			PublicKey:  string(publicKeyPEM(&g.alipayKey.PublicKey)),
+			NotifyURL:  g.webhooks[payment.PaymentTypeAlipay],
+			GatewayURL: g.URL + "/alipay/gateway.do",
+		},
+		WeChatPay: config.WeChatPayConfig{
+			AppID:          WeChatAppID,
+			MchID:          WeChatMchID,
+			APIKey:         wechatAPIv3Key,
+			SerialNo:       "SIMMERCHANTSERIAL",
+			PrivateKeyPath: g.wechatMerchantPath,
+			NotifyURL:      g.webhooks[payment.PaymentTypeWeChat],
+			BaseURL:        g.URL + "/wechat",
+		},
+		Stripe: config.StripeConfig{
+			SecretKey:     g.stripeSecretKey,
+			WebhookSecret: g.stripeWebhookSecret,
+			BaseURL:       g.URL + "/stripe",
+		},
+		PayPal: config.PayPalConfig{
+			ClientID:     g.paypalClientID,
+			ClientSecret: g.paypalSecret,
+			Mode:         "sandbox",
+			WebhookID:    g.paypalWebhookID,
+			MerchantID:   PayPalMerchantID,
+			BaseURL:      g.URL + "/paypal",
+		},
+		OrderExpiry: 30 * time.Minute,
+	}
+}
+
+// Pay 模拟买家打开收银台并付款。checkoutURL为客户端CreatePayment返回的支付链接，
+// 按scenario投递回调；未延迟的回调在返回前投递完成，返回本次投递的结果
+func (g *Gateway) Pay(checkoutURL string, scenario Scenario) ([]Delivery, error) {
+	co, err := g.open(checkoutURL)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	if co.status != "" && co.status != statusOpen {
+		g.mu.Unlock()
+		return nil, fmt.Errorf("checkout %s is already %s", co.id, co.status)
+	}
+	co.scenario = scenario
+	co.paid = models.NewMoney(co.amount.Amount+scenario.AmountDelta, co.amount.Currency)
+	var events []*event
+	switch co.provider {
+	case payment.PaymentTypeAlipay:
+		events = g.payAlipay(co)
+	case payment.PaymentTypeStripe:
+		events = g.payStripe(co)
+	case payment.PaymentTypePayPal:
+		events = g.payPayPal(co)
+	case payment.PaymentTypeWeChat:
+		events = g.payWeChat(co)
+	}
+	g.mu.Unlock()
+
+	var deliveries []Delivery
+	for _, ev := range events {
+		for i := 0; i <= scenario.Duplicates; i++ {
+			if scenario.Delay > 0 {
+				g.pending.Add(1)
+				go func(ev *event) {
+					defer g.pending.Done()
+					time.Sleep(scenario.Delay)
+					g.deliver(ev)
+				}(ev)
+				continue
+			}
+			deliveries = append(deliveries, g.deliver(ev))
+		}
+	}
+	return deliveries, nil
+}
+
+// Wait 等待延迟的回调投递完成
+func (g *Gateway) Wait() {
+	g.pending.Wait()
+}
+
+// Deliveries 返回目前为止的全部回调投递
+func (g *Gateway) Deliveries() []Delivery {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Delivery(nil), g.deliveries...)
+}
+
+// Checkout 按商户订单号返回网关侧的交易状态和实付金额，ok为false表示网关未见过该订单
+func (g *Gateway) Checkout(orderNo string) (status string, paid models.Money, ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	co, ok := g.byOrderNo[orderNo]
+	if !ok {
+		return "", models.Money{}, false
+	}
+	return co.status, co.paid, true
+}
+
+const statusOpen = "open"
+
+// open 解析支付链接，找到对应的交易
+func (g *Gateway) open(checkoutURL string) (*checkout, error) {
+	// 支付宝的支付链接是带签名的网关地址，买家打开时网关才创建交易
+	if strings.HasPrefix(checkoutURL, g.URL+"/alipay/gateway.do?") {
+		return g.openAlipay(checkoutURL)
+	}
+
+	var id string
+	switch {
+	case strings.HasPrefix(checkoutURL, g.URL+"/checkout/"):
+		parts := strings.Split(strings.TrimPrefix(checkoutURL, g.URL+"/checkout/"), "/")
+		id = parts[len(parts)-1]
+	case strings.HasPrefix(checkoutURL, "weixin://wxpay/bizpayurl?pr="):
+		id = strings.TrimPrefix(checkoutURL, "weixin://wxpay/bizpayurl?pr=")
+	}
+	if i := strings.IndexAny(id, "?&"); i >= 0 {
+		id = id[:i]
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	co, ok := g.checkouts[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown checkout url %s", checkoutURL)
+	}
+	return co, nil
+}
+
+// handleCheckoutPage 收银台页面，便于在浏览器中查看模拟交易
+func (g *Gateway) handleCheckoutPage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/checkout/"), "/")
+	g.mu.Lock()
+	co, ok := g.checkouts[parts[len(parts)-1]]
+	g.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintf(w, "%s checkout %s for order %s: %s %s (%s)\n",
+		co.provider, co.id, co.orderNo, co.amount.Decimal(), co.amount.Currency, co.status)
+}
+
+// event 待投递的回调
+type event struct {
+	provider payment.PaymentType
+	id       string
+	orderNo  string
+	url      string
+	body     []byte
+	header   http.Header
+}
+
+// deliver 投递回调并记录结果
+func (g *Gateway) deliver(ev *event) Delivery {
+	d := Delivery{Provider: ev.provider, EventID: ev.id, OrderNo: ev.orderNo, URL: ev.url}
+	if ev.url == "" {
+		d.Err = fmt.Errorf("no webhook url configured for %s", ev.provider)
+	} else {
+		req, err := http.NewRequest("POST", ev.url, bytes.NewReader(ev.body))
+		if err == nil {
+			for k, v := range ev.header {
+				req.Header[k] = v
+			}
+			var resp *http.Response
+			if resp, err = g.httpClient.Do(req); err == nil {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				d.StatusCode, d.Body = resp.StatusCode, string(body)
+			}
+		}
+		d.Err = err
+	}
+
+	g.mu.Lock()
+	g.deliveries = append(g.deliveries, d)
+	g.mu.Unlock()
+	return d
+}
+
+// webhookURL 回调地址：优先使用下单时的notify_url。调用方须持有g.mu
+func (g *Gateway) webhookURL(co *checkout) string {
+	if co.notifyURL != "" {
+		return co.notifyURL
+	}
+	return g.webhooks[co.provider]
+}
+
+// register 登记新交易。调用方须持有g.mu
+func (g *Gateway) register(co *checkout) {
+	co.status = statusOpen
+	co.refunds = make(map[string]*refund)
+	g.checkouts[co.id] = co
+	g.byOrderNo[co.orderNo] = co
+}
+
+// signedAt 回调的签名时间，按Scenario.Age回拨
+func signedAt(s Scenario) time.Time {
+	return time.Now().Add(-s.Age)
+}
+
+// refundedTotal 已退款金额合计。调用方须持有g.mu
+func refundedTotal(co *checkout) int64 {
+	var total int64
+	for _, r := range co.refunds {
+		total += r.amount
+	}
+	return total
+}
+
+// addRefund 登记退款，同一请求号重复调用返回已有退款，超出可退金额时返回nil。调用方须持有g.mu
+func addRefund(co *checkout, requestNo, id string, amount int64) *refund {
+	if r, ok := co.refunds[requestNo]; ok {
+		return r
+	}
+	if amount <= 0 || refundedTotal(co)+amount > co.paid.Amount {
+		return nil
+	}
+	r := &refund{id: id, amount: amount}
+	co.refunds[requestNo] = r
+	return r
+}
+
+func randomHex(n int) string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")[:n]
+}
+
+func privateKeyPEM(key *rsa.PrivateKey) []byte {
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicKeyPEM(key *rsa.PublicKey) []byte {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
@@ -0,0 +1,252 @@
+package paymenttest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/payment"
+
+	"github.com/google/uuid"
+)
+
+// merchant 模拟商户的回调端点，使用真实客户端验签（与api/payment中的回调处理一致）
+type merchant struct {
+	t   *testing.T
+	cfg config.Config
+
+	mu      sync.Mutex
+	results []*payment.CallbackResult
+}
+
+func (m *merchant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	provider := payment.PaymentType(strings.TrimPrefix(r.URL.Path, "/callback/"))
+	service, err := payment.GetPaymentService(provider, m.cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var result *payment.CallbackResult
+	switch client := service.(type) {
+	case *payment.AlipayClient:
+		r.ParseForm()
+		result, err = client.ProcessCallback(r.PostForm)
+	case *payment.StripeClient:
+		payload, _ := io.ReadAll(r.Body)
+		result, err = client.ProcessWebhook(payload, r.Header.Get("Stripe-Signature"))
+	case *payment.PayPalClient:
+		payload, _ := io.ReadAll(r.Body)
+		result, err = client.ProcessWebhook(payload, r.Header)
+	case *payment.WeChatPayClient:
+		payload, _ := io.ReadAll(r.Body)
+		result, err = client.ProcessNotification(r.Header, payload)
+	default:
+		m.t.Errorf("unexpected client %T for %s", service, provider)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.results = append(m.results, result)
+	m.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *merchant) received() []*payment.CallbackResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*payment.CallbackResult(nil), m.results...)
+}
+
+// setup 启动模拟网关和商户回调端点，支付配置关闭沙箱，所有网关使用真实客户端
+func setup(t *testing.T) (*Gateway, *merchant) {
+	t.Helper()
+	gateway, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(gateway.Close)
+
+	m := &merchant{t: t}
+	server := httptest.NewServer(m)
+	t.Cleanup(server.Close)
+	gateway.RouteWebhooks(server.URL + "/callback")
+	m.cfg = config.Config{Payment: gateway.Config()}
+	return gateway, m
+}
+
+// purchase 创建订单并通过真实客户端下单，返回订单和支付链接
+func purchase(t *testing.T, cfg config.Config, provider payment.PaymentType) (payment.PaymentService, *models.Order, string) {
+	t.Helper()
+	currency := "USD"
+	if provider == payment.PaymentTypeAlipay || provider == payment.PaymentTypeWeChat {
+		currency = "CNY"
+	}
+	service, err := payment.GetPaymentService(provider, cfg)
+	if err != nil {
+		t.Fatalf("GetPaymentService(%s) failed: %v", provider, err)
+	}
+	order := &models.Order{
+		ID:      uuid.New(),
+		OrderNo: "ORD" + strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", "")[:12]),
+		Total:   models.NewMoney(1999, currency),
+	}
+	checkoutURL, err := service.CreatePayment(order, "测试技能")
+	if err != nil {
+		t.Fatalf("%s CreatePayment failed: %v", provider, err)
+	}
+	return service, order, checkoutURL
+}
+
+var providers = []payment.PaymentType{
+	payment.PaymentTypeAlipay,
+	payment.PaymentTypeStripe,
+	payment.PaymentTypePayPal,
+	payment.PaymentTypeWeChat,
+}
+
+func TestPurchaseCallbackRefund(t *testing.T) {
+	for _, provider := range providers {
+		t.Run(string(provider), func(t *testing.T) {
+			gateway, m := setup(t)
+			service, order, checkoutURL := purchase(t, m.cfg, provider)
+
+			deliveries, err := gateway.Pay(checkoutURL, Scenario{})
+			if err != nil {
+				t.Fatalf("Pay failed: %v", err)
+			}
+			if len(deliveries) != 1 || !deliveries[0].OK() {
+				t.Fatalf("unexpected deliveries %+v", deliveries)
+			}
+			results := m.received()
+			if len(results) != 1 || !results[0].IsPaid() || results[0].OutTradeNo != order.OrderNo {
+				t.Fatalf("unexpected callback results %+v", results)
+			}
+			if problems := payment.VerifyResult(order, results[0], m.cfg); len(problems) > 0 {
+				t.Errorf("verified callback reported problems: %v", problems)
+			}
+
+			queried, err := service.QueryPayment(order)
+			if err != nil || !queried.IsPaid() {
+				t.Fatalf("QueryPayment = %+v, %v; want paid", queried, err)
+			}
+
+			refund, err := service.Refund(&payment.RefundRequest{
+				OrderNo:     order.OrderNo,
+				TradeNo:     results[0].TradeNo,
+				PaymentRef:  order.PaymentRef,
+				RefundNo:    "RF" + order.OrderNo,
+				Amount:      order.Total,
+				TotalAmount: order.Total,
+			})
+			if err != nil || refund.Status != models.RefundStatusSuccess || refund.Amount != order.Total.Decimal() {
+				t.Errorf("Refund = %+v, %v; want full refund", refund, err)
+			}
+		})
+	}
+}
+
+func TestDecline(t *testing.T) {
+	for _, provider := range providers {
+		t.Run(string(provider), func(t *testing.T) {
+			gateway, m := setup(t)
+			service, order, checkoutURL := purchase(t, m.cfg, provider)
+
+			if _, err := gateway.Pay(checkoutURL, Scenario{Decline: true}); err != nil {
+				t.Fatalf("Pay failed: %v", err)
+			}
+			for _, result := range m.received() {
+				if result.IsPaid() {
+					t.Errorf("declined payment reported as paid: %+v", result)
+				}
+			}
+			if queried, err := service.QueryPayment(order); err != nil || queried.IsPaid() {
+				t.Errorf("QueryPayment = %+v, %v; want unpaid", queried, err)
+			}
+		})
+	}
+}
+
+func TestAmountMismatch(t *testing.T) {
+	for _, provider := range providers {
+		t.Run(string(provider), func(t *testing.T) {
+			gateway, m := setup(t)
+			_, order, checkoutURL := purchase(t, m.cfg, provider)
+
+			if _, err := gateway.Pay(checkoutURL, Scenario{AmountDelta: -1000}); err != nil {
+				t.Fatalf("Pay failed: %v", err)
+			}
+			results := m.received()
+			if len(results) != 1 {
+				t.Fatalf("expected one verified callback, got %d", len(results))
+			}
+			if problems := payment.VerifyResult(order, results[0], m.cfg); len(problems) == 0 {
+				t.Errorf("expected an amount mismatch for %+v", results[0])
+			}
+		})
+	}
+}
+
+func TestDuplicateAndDelayedWebhooks(t *testing.T) {
+	gateway, m := setup(t)
+	_, _, checkoutURL := purchase(t, m.cfg, payment.PaymentTypeStripe)
+
+	deliveries, err := gateway.Pay(checkoutURL, Scenario{Duplicates: 2, Delay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Pay failed: %v", err)
+	}
+	if len(deliveries) != 0 || len(m.received()) != 0 {
+		t.Fatalf("delayed webhooks delivered early: %+v", deliveries)
+	}
+
+	gateway.Wait()
+	results := m.received()
+	if len(results) != 3 || len(gateway.Deliveries()) != 3 {
+		t.Fatalf("expected 3 deliveries, got %d", len(results))
+	}
+	for _, result := range results[1:] {
+		if result.TradeNo != results[0].TradeNo {
+			t.Errorf("duplicate webhooks carry different trades: %s, %s", result.TradeNo, results[0].TradeNo)
+		}
+	}
+
+	if _, err := gateway.Pay(checkoutURL, Scenario{}); err == nil {
+		t.Error("expected a completed checkout to reject a second payment")
+	}
+}
+
+func TestRejectedWebhooks(t *testing.T) {
+	for _, provider := range providers {
+		t.Run(string(provider), func(t *testing.T) {
+			gateway, m := setup(t)
+			_, _, checkoutURL := purchase(t, m.cfg, provider)
+
+			deliveries, err := gateway.Pay(checkoutURL, Scenario{BadSignature: true})
+			if err != nil {
+				t.Fatalf("Pay failed: %v", err)
+			}
+			if len(deliveries) != 1 || deliveries[0].StatusCode != http.StatusBadRequest || len(m.received()) != 0 {
+				t.Errorf("forged webhook was not rejected: %+v", deliveries)
+			}
+		})
+	}
+
+	// Stripe和微信支付拒绝签名时间超出容差的回调
+	for _, provider := range []payment.PaymentType{payment.PaymentTypeStripe, payment.PaymentTypeWeChat} {
+		gateway, m := setup(t)
+		_, _, checkoutURL := purchase(t, m.cfg, provider)
+		deliveries, _ := gateway.Pay(checkoutURL, Scenario{Age: 10 * time.Minute})
+		if len(deliveries) != 1 || deliveries[0].OK() {
+			t.Errorf("%s accepted a stale webhook: %+v", provider, deliveries)
+		}
+	}
+}
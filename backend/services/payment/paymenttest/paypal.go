@@ -0,0 +1,276 @@
+package paymenttest
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/payment"
+)
+
+// paypalAuthAlgo Webhook传输签名算法
+const paypalAuthAlgo = "SHA256withRSA"
+
+// handlePayPal PayPal REST API：OAuth令牌、订单、捕获、退款和Webhook签名验证
+func (g *Gateway) handlePayPal(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/paypal")
+
+	if path == "/v1/oauth2/token" {
+		if id, secret, ok := r.BasicAuth(); !ok || id != g.paypalClientID || secret != g.paypalSecret {
+			paypalError(w, http.StatusUnauthorized, "invalid_client", "Client Authentication failed")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": g.paypalToken,
+			"token_type":   "Bearer",
+			"expires_in":   32400,
+		})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+g.paypalToken {
+		paypalError(w, http.StatusUnauthorized, "AUTHENTICATION_FAILURE", "Authentication failed due to invalid authentication credentials")
+		return
+	}
+
+	if r.Method == "POST" && path == "/v1/notifications/verify-webhook-signature" {
+		g.verifyPayPalWebhook(w, r)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case r.Method == "POST" && path == "/v2/checkout/orders":
+		var req struct {
+			PurchaseUnits []struct {
+				ReferenceID string `json:"reference_id"`
+				CustomID    string `json:"custom_id"`
+				Amount      struct {
+					CurrencyCode string `json:"currency_code"`
+					Value        string `json:"value"`
+				} `json:"amount"`
+			} `json:"purchase_units"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.PurchaseUnits) == 0 {
+			paypalError(w, http.StatusBadRequest, "INVALID_REQUEST", "Request is not well-formed")
+			return
+		}
+		unit := req.PurchaseUnits[0]
+		amount, err := models.ParseMoney(unit.Amount.Value, unit.Amount.CurrencyCode)
+		if err != nil {
+			paypalError(w, http.StatusUnprocessableEntity, "UNPROCESSABLE_ENTITY", err.Error())
+			return
+		}
+		co := &checkout{
+			provider: payment.PaymentTypePayPal,
+			id:       strings.ToUpper(randomHex(17)),
+			orderNo:  unit.CustomID,
+			amount:   amount,
+		}
+		g.register(co)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(g.paypalOrder(co))
+
+	case strings.HasPrefix(path, "/v2/checkout/orders/"):
+		id, action, _ := strings.Cut(strings.TrimPrefix(path, "/v2/checkout/orders/"), "/")
+		co, ok := g.checkouts[id]
+		if !ok || co.provider != payment.PaymentTypePayPal {
+			paypalError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist")
+			return
+		}
+		switch {
+		case r.Method == "GET" && action == "":
+			json.NewEncoder(w).Encode(g.paypalOrder(co))
+		case r.Method == "POST" && action == "capture":
+			g.capturePayPalOrder(w, co)
+		default:
+			paypalError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist")
+		}
+
+	case r.Method == "POST" && strings.HasPrefix(path, "/v2/payments/captures/") && strings.HasSuffix(path, "/refund"):
+		captureID := strings.TrimSuffix(strings.TrimPrefix(path, "/v2/payments/captures/"), "/refund")
+		var co *checkout
+		for _, c := range g.checkouts {
+			if c.provider == payment.PaymentTypePayPal && c.tradeNo == captureID && c.status == "COMPLETED" {
+				co = c
+			}
+		}
+		if co == nil {
+			paypalError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist")
+			return
+		}
+		var req struct {
+			Amount struct {
+				CurrencyCode string `json:"currency_code"`
+				Value        string `json:"value"`
+			} `json:"amount"`
+			CustomID string `json:"custom_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		amount, err := models.ParseMoney(req.Amount.Value, co.paid.Currency)
+		requestNo := r.Header.Get("PayPal-Request-Id")
+		if requestNo == "" {
+			requestNo = req.CustomID
+		}
+		var rf *refund
+		if err == nil {
+			rf = addRefund(co, requestNo, strings.ToUpper(randomHex(17)), amount.Amount)
+		}
+		if rf == nil {
+			paypalError(w, http.StatusUnprocessableEntity, "REFUND_AMOUNT_EXCEEDED", "The refund amount must be less than or equal to the capture amount that has not yet been refunded")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":        rf.id,
+			"status":    "COMPLETED",
+			"custom_id": req.CustomID,
+			"amount":    paypalAmount(models.NewMoney(rf.amount, co.paid.Currency)),
+		})
+
+	default:
+		paypalError(w, http.StatusNotFound, "RESOURCE_NOT_FOUND", "The specified resource does not exist")
+	}
+}
+
+// capturePayPalOrder 捕获买家已批准的订单；拒付时捕获记录为DECLINED。调用方须持有g.mu
+func (g *Gateway) capturePayPalOrder(w http.ResponseWriter, co *checkout) {
+	switch co.status {
+	case "COMPLETED", "DECLINED":
+		paypalError(w, http.StatusUnprocessableEntity, "ORDER_ALREADY_CAPTURED", "Order already captured")
+		return
+	case "APPROVED":
+	default:
+		paypalError(w, http.StatusUnprocessableEntity, "ORDER_NOT_APPROVED", "Payer has not yet approved the Order for payment")
+		return
+	}
+
+	co.tradeNo = strings.ToUpper(randomHex(17))
+	co.status = "COMPLETED"
+	if co.scenario.Decline {
+		co.status = "DECLINED"
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(g.paypalOrder(co))
+}
+
+// payPayPal 买家批准付款后发送CHECKOUT.ORDER.APPROVED事件，由商户捕获资金。调用方须持有g.mu
+func (g *Gateway) payPayPal(co *checkout) []*event {
+	co.status = "APPROVED"
+
+	id := "WH-" + strings.ToUpper(randomHex(24))
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":            id,
+		"event_version": "1.0",
+		"create_time":   signedAt(co.scenario).UTC().Format(time.RFC3339),
+		"resource_type": "checkout-order",
+		"event_type":    "CHECKOUT.ORDER.APPROVED",
+		"summary":       "An order has been approved by buyer",
+		"resource":      g.paypalOrder(co),
+	})
+
+	transmissionID := randomHex(32)
+	transmissionTime := signedAt(co.scenario).UTC().Format(time.RFC3339)
+	signature := signRSA(g.paypalKey, paypalSignContent(transmissionID, transmissionTime, g.paypalWebhookID, payload))
+	if co.scenario.BadSignature {
+		signature = signRSA(g.alipayKey, paypalSignContent(transmissionID, transmissionTime, g.paypalWebhookID, payload))
+	}
+	return []*event{{
+		provider: co.provider,
+		id:       id,
+		orderNo:  co.orderNo,
+		url:      g.webhookURL(co),
+		body:     payload,
+		header: http.Header{
+			"Content-Type":             {"application/json"},
+			"Paypal-Transmission-Id":   {transmissionID},
+			"Paypal-Transmission-Time": {transmissionTime},
+			"Paypal-Transmission-Sig":  {signature},
+			"Paypal-Cert-Url":          {g.URL + "/paypal/v1/notifications/certs/CERT-SIM"},
+			"Paypal-Auth-Algo":         {paypalAuthAlgo},
+		},
+	}}
+}
+
+// verifyPayPalWebhook 按PayPal规则验证传输签名：签名内容为 transmission_id|transmission_time|webhook_id|crc32(报文)
+func (g *Gateway) verifyPayPalWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AuthAlgo         string          `json:"auth_algo"`
+		CertURL          string          `json:"cert_url"`
+		TransmissionID   string          `json:"transmission_id"`
+		TransmissionSig  string          `json:"transmission_sig"`
+		TransmissionTime string          `json:"transmission_time"`
+		WebhookID        string          `json:"webhook_id"`
+		WebhookEvent     json.RawMessage `json:"webhook_event"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		paypalError(w, http.StatusBadRequest, "INVALID_REQUEST", "Request is not well-formed")
+		return
+	}
+
+	status := "SUCCESS"
+	if req.WebhookID != g.paypalWebhookID || req.AuthAlgo != paypalAuthAlgo || !strings.HasPrefix(req.CertURL, g.URL+"/paypal/") ||
+		verifyRSA(&g.paypalKey.PublicKey, paypalSignContent(req.TransmissionID, req.TransmissionTime, req.WebhookID, req.WebhookEvent), req.TransmissionSig) != nil {
+		status = "FAILURE"
+	}
+	json.NewEncoder(w).Encode(map[string]string{"verification_status": status})
+}
+
+// paypalOrder 订单对象，已捕获时附带捕获记录。调用方须持有g.mu
+func (g *Gateway) paypalOrder(co *checkout) map[string]interface{} {
+	unit := map[string]interface{}{
+		"reference_id": co.orderNo,
+		"custom_id":    co.orderNo,
+		"amount":       paypalAmount(co.amount),
+		"payee":        map[string]string{"merchant_id": PayPalMerchantID},
+	}
+	status := co.status
+	if status == statusOpen {
+		status = "CREATED"
+	}
+	if co.tradeNo != "" {
+		captureStatus := co.status
+		status = "COMPLETED"
+		unit["payments"] = map[string]interface{}{
+			"captures": []map[string]interface{}{{
+				"id":        co.tradeNo,
+				"status":    captureStatus,
+				"custom_id": co.orderNo,
+				"amount":    paypalAmount(co.paid),
+			}},
+		}
+	}
+	return map[string]interface{}{
+		"id":             co.id,
+		"intent":         "CAPTURE",
+		"status":         status,
+		"purchase_units": []interface{}{unit},
+		"links": []map[string]string{
+			{"href": g.URL + "/paypal/v2/checkout/orders/" + co.id, "rel": "self", "method": "GET"},
+			{"href": g.URL + "/checkout/paypal/" + co.id, "rel": "approve", "method": "GET"},
+			{"href": g.URL + "/paypal/v2/checkout/orders/" + co.id + "/capture", "rel": "capture", "method": "POST"},
+		},
+	}
+}
+
+func paypalSignContent(transmissionID, transmissionTime, webhookID string, payload []byte) string {
+	return transmissionID + "|" + transmissionTime + "|" + webhookID + "|" + strconv.FormatUint(uint64(crc32.ChecksumIEEE(payload)), 10)
+}
+
+func paypalAmount(m models.Money) map[string]string {
+	return map[string]string{"currency_code": m.Currency, "value": m.Decimal()}
+}
+
+func paypalError(w http.ResponseWriter, status int, name, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":    name,
+		"message": message,
+		"details": []map[string]string{{"issue": name}},
+	})
+}
@@ -0,0 +1,175 @@
+package paymenttest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/payment"
+)
+
+// handleStripe Stripe REST API：Checkout会话和退款，表单编码请求、JSON应答
+func (g *Gateway) handleStripe(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+g.stripeSecretKey {
+		stripeError(w, http.StatusUnauthorized, "Invalid API Key provided")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		stripeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/stripe")
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case r.Method == "POST" && path == "/v1/checkout/sessions":
+		form := r.PostForm
+		if form.Get("mode") != "payment" {
+			stripeError(w, http.StatusBadRequest, "the simulator only supports mode=payment")
+			return
+		}
+		unitAmount, err := strconv.ParseInt(form.Get("line_items[0][price_data][unit_amount]"), 10, 64)
+		quantity, _ := strconv.ParseInt(form.Get("line_items[0][quantity]"), 10, 64)
+		if err != nil || quantity <= 0 {
+			stripeError(w, http.StatusBadRequest, "invalid line_items")
+			return
+		}
+		co := &checkout{
+			provider: payment.PaymentTypeStripe,
+			id:       "cs_test_" + randomHex(24),
+			orderNo:  form.Get("metadata[order_no]"),
+			amount:   models.NewMoney(unitAmount*quantity, form.Get("line_items[0][price_data][currency]")),
+		}
+		g.register(co)
+		json.NewEncoder(w).Encode(g.stripeSession(co))
+
+	case r.Method == "GET" && strings.HasPrefix(path, "/v1/checkout/sessions/"):
+		co, ok := g.checkouts[strings.TrimPrefix(path, "/v1/checkout/sessions/")]
+		if !ok || co.provider != payment.PaymentTypeStripe {
+			stripeError(w, http.StatusNotFound, "No such checkout.session")
+			return
+		}
+		json.NewEncoder(w).Encode(g.stripeSession(co))
+
+	case r.Method == "POST" && path == "/v1/refunds":
+		var co *checkout
+		for _, c := range g.checkouts {
+			if c.provider == payment.PaymentTypeStripe && c.tradeNo != "" && c.tradeNo == r.PostForm.Get("payment_intent") {
+				co = c
+			}
+		}
+		if co == nil {
+			stripeError(w, http.StatusNotFound, "No such payment_intent")
+			return
+		}
+		amount, _ := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			key = randomHex(16)
+		}
+		rf := addRefund(co, key, "re_"+randomHex(24), amount)
+		if rf == nil {
+			stripeError(w, http.StatusBadRequest, "Refund amount is greater than unrefunded amount on charge")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":             rf.id,
+			"object":         "refund",
+			"status":         "succeeded",
+			"amount":         rf.amount,
+			"currency":       strings.ToLower(co.paid.Currency),
+			"payment_intent": co.tradeNo,
+			"metadata": map[string]string{
+				"refund_no": r.PostForm.Get("metadata[refund_no]"),
+				"order_no":  r.PostForm.Get("metadata[order_no]"),
+			},
+		})
+
+	default:
+		stripeError(w, http.StatusNotFound, "Unrecognized request URL")
+	}
+}
+
+// payStripe 付款成功后发送checkout.session.completed事件；
+// 卡被拒绝时Stripe在收银台提示买家，会话保持open，不发送事件。调用方须持有g.mu
+func (g *Gateway) payStripe(co *checkout) []*event {
+	if co.scenario.Decline {
+		return nil
+	}
+	co.status = "complete"
+	co.tradeNo = "pi_" + randomHex(24)
+
+	at := signedAt(co.scenario)
+	id := "evt_" + randomHex(24)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"object":   "event",
+		"type":     "checkout.session.completed",
+		"created":  at.Unix(),
+		"livemode": false,
+		"data":     map[string]interface{}{"object": g.stripeSession(co)},
+	})
+
+	secret := g.stripeWebhookSecret
+	if co.scenario.BadSignature {
+		secret = "whsec_forged"
+	}
+	return []*event{{
+		provider: co.provider,
+		id:       id,
+		orderNo:  co.orderNo,
+		url:      g.webhookURL(co),
+		body:     payload,
+		header: http.Header{
+			"Content-Type":     {"application/json"},
+			"Stripe-Signature": {stripeSignature(secret, payload, at)},
+		},
+	}}
+}
+
+// stripeSession Checkout会话对象。调用方须持有g.mu
+func (g *Gateway) stripeSession(co *checkout) map[string]interface{} {
+	session := map[string]interface{}{
+		"id":                  co.id,
+		"object":              "checkout.session",
+		"url":                 g.URL + "/checkout/stripe/" + co.id,
+		"mode":                "payment",
+		"status":              "open",
+		"payment_status":      "unpaid",
+		"amount_total":        co.amount.Amount,
+		"currency":            strings.ToLower(co.amount.Currency),
+		"client_reference_id": co.orderNo,
+		"metadata":            map[string]string{"order_no": co.orderNo},
+	}
+	if co.status == "complete" {
+		session["status"] = "complete"
+		session["payment_status"] = "paid"
+		session["amount_total"] = co.paid.Amount
+		session["payment_intent"] = co.tradeNo
+	}
+	return session
+}
+
+// stripeSignature 生成Stripe-Signature头：t=时间戳,v1=HMAC-SHA256("t.payload")
+func stripeSignature(secret string, payload []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func stripeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"type": "invalid_request_error", "message": message},
+	})
+}
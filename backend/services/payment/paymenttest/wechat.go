@@ -0,0 +1,241 @@
+package paymenttest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"skillhub/models"
+	"skillhub/services/payment"
+)
+
+// handleWeChat 微信支付APIv3：验证商户请求签名，应答使用平台证书私钥签名
+func (g *Gateway) handleWeChat(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	// 商户按BaseURL之后的路径（含查询串）签名
+	path := strings.TrimPrefix(r.URL.RequestURI(), "/wechat")
+	if !g.verifyWeChatRequest(r.Method, path, r.Header.Get("Authorization"), body) {
+		g.wechatRespond(w, http.StatusUnauthorized, map[string]string{"code": "SIGN_ERROR", "message": "签名错误"})
+		return
+	}
+	route := strings.TrimPrefix(r.URL.Path, "/wechat")
+
+	if r.Method == "GET" && route == "/v3/certificates" {
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: g.wechatPlatformCert.Raw})
+		g.wechatRespond(w, http.StatusOK, map[string]interface{}{
+			"data": []interface{}{map[string]interface{}{
+				"serial_no":           g.wechatSerial,
+				"effective_time":      g.wechatPlatformCert.NotBefore.Format(time.RFC3339),
+				"expire_time":         g.wechatPlatformCert.NotAfter.Format(time.RFC3339),
+				"encrypt_certificate": encryptWeChatResource(certPEM, "certificate"),
+			}},
+		})
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case r.Method == "POST" && (route == "/v3/pay/transactions/native" || route == "/v3/pay/transactions/h5"):
+		var req struct {
+			AppID      string `json:"appid"`
+			MchID      string `json:"mchid"`
+			OutTradeNo string `json:"out_trade_no"`
+			NotifyURL  string `json:"notify_url"`
+			Amount     struct {
+				Total    int64  `json:"total"`
+				Currency string `json:"currency"`
+			} `json:"amount"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil || req.OutTradeNo == "" || req.Amount.Total <= 0 {
+			g.wechatRespond(w, http.StatusBadRequest, map[string]string{"code": "PARAM_ERROR", "message": "参数错误"})
+			return
+		}
+		if req.AppID != WeChatAppID || req.MchID != WeChatMchID {
+			g.wechatRespond(w, http.StatusBadRequest, map[string]string{"code": "APPID_MCHID_NOT_MATCH", "message": "appid和mch_id不匹配"})
+			return
+		}
+		co := &checkout{
+			provider:  payment.PaymentTypeWeChat,
+			id:        "wx" + randomHex(30),
+			orderNo:   req.OutTradeNo,
+			amount:    models.NewMoney(req.Amount.Total, req.Amount.Currency),
+			notifyURL: req.NotifyURL,
+		}
+		g.register(co)
+		if route == "/v3/pay/transactions/h5" {
+			g.wechatRespond(w, http.StatusOK, map[string]string{"h5_url": g.URL + "/checkout/wechat/" + co.id + "?prepay_id=" + co.id})
+			return
+		}
+		g.wechatRespond(w, http.StatusOK, map[string]string{"code_url": "weixin://wxpay/bizpayurl?pr=" + co.id})
+
+	case r.Method == "GET" && strings.HasPrefix(route, "/v3/pay/transactions/out-trade-no/"):
+		co, ok := g.byOrderNo[strings.TrimPrefix(route, "/v3/pay/transactions/out-trade-no/")]
+		if !ok || co.provider != payment.PaymentTypeWeChat {
+			g.wechatRespond(w, http.StatusNotFound, map[string]string{"code": "ORDER_NOT_EXIST", "message": "订单不存在"})
+			return
+		}
+		g.wechatRespond(w, http.StatusOK, wechatTransaction(co))
+
+	case r.Method == "POST" && route == "/v3/refund/domestic/refunds":
+		var req struct {
+			OutTradeNo  string `json:"out_trade_no"`
+			OutRefundNo string `json:"out_refund_no"`
+			Amount      struct {
+				Refund int64 `json:"refund"`
+				Total  int64 `json:"total"`
+			} `json:"amount"`
+		}
+		json.Unmarshal(body, &req)
+		co, ok := g.byOrderNo[req.OutTradeNo]
+		if !ok || co.provider != payment.PaymentTypeWeChat || co.status != "SUCCESS" {
+			g.wechatRespond(w, http.StatusNotFound, map[string]string{"code": "RESOURCE_NOT_EXISTS", "message": "订单不存在"})
+			return
+		}
+		if req.Amount.Total != co.paid.Amount {
+			g.wechatRespond(w, http.StatusBadRequest, map[string]string{"code": "PARAM_ERROR", "message": "订单金额与原订单不一致"})
+			return
+		}
+		rf := addRefund(co, req.OutRefundNo, "50"+randomHex(28), req.Amount.Refund)
+		if rf == nil {
+			g.wechatRespond(w, http.StatusForbidden, map[string]string{"code": "NOT_ENOUGH", "message": "退款金额超过可退金额"})
+			return
+		}
+		g.wechatRespond(w, http.StatusOK, map[string]interface{}{
+			"refund_id":      rf.id,
+			"out_refund_no":  req.OutRefundNo,
+			"transaction_id": co.tradeNo,
+			"out_trade_no":   co.orderNo,
+			"status":         "SUCCESS",
+			"amount":         map[string]int64{"total": co.paid.Amount, "refund": rf.amount},
+		})
+
+	default:
+		g.wechatRespond(w, http.StatusNotFound, map[string]string{"code": "NOT_FOUND", "message": "接口不存在"})
+	}
+}
+
+// payWeChat 付款成功后发送加密的TRANSACTION.SUCCESS通知；
+// 付款失败时微信支付不通知商户，只能查单得到PAYERROR。调用方须持有g.mu
+func (g *Gateway) payWeChat(co *checkout) []*event {
+	if co.scenario.Decline {
+		co.status = "PAYERROR"
+		return nil
+	}
+	co.status = "SUCCESS"
+	co.tradeNo = "4200" + strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	at := signedAt(co.scenario)
+	plaintext, _ := json.Marshal(wechatTransaction(co))
+	id := randomHex(8) + "-" + randomHex(4) + "-" + randomHex(4)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":            id,
+		"create_time":   at.Format(time.RFC3339),
+		"event_type":    "TRANSACTION.SUCCESS",
+		"resource_type": "encrypt-resource",
+		"summary":       "支付成功",
+		"resource":      encryptWeChatResource(plaintext, "transaction"),
+	})
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	g.signWeChat(header, payload, at)
+	if co.scenario.BadSignature {
+		header.Set("Wechatpay-Signature", signRSA(g.wechatMerchantKey, "forged"))
+	}
+	return []*event{{
+		provider: co.provider,
+		id:       id,
+		orderNo:  co.orderNo,
+		url:      g.webhookURL(co),
+		body:     payload,
+		header:   header,
+	}}
+}
+
+// verifyWeChatRequest 验证商户请求的Authorization头
+func (g *Gateway) verifyWeChatRequest(method, path, authorization string, body []byte) bool {
+	params, ok := strings.CutPrefix(authorization, "WECHATPAY2-SHA256-RSA2048 ")
+	if !ok {
+		return false
+	}
+	fields := make(map[string]string)
+	for _, part := range strings.Split(params, ",") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			fields[k] = strings.Trim(v, `"`)
+		}
+	}
+	if fields["mchid"] != WeChatMchID {
+		return false
+	}
+	message := method + "\n" + path + "\n" + fields["timestamp"] + "\n" + fields["nonce_str"] + "\n" + string(body) + "\n"
+	return verifyRSA(&g.wechatMerchantKey.PublicKey, message, fields["signature"]) == nil
+}
+
+// wechatRespond 返回JSON应答并附带平台签名头
+func (g *Gateway) wechatRespond(w http.ResponseWriter, status int, v interface{}) {
+	body, _ := json.Marshal(v)
+	g.signWeChat(w.Header(), body, time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// signWeChat 用平台私钥对应答或通知签名：时间戳\n随机串\n报文\n
+func (g *Gateway) signWeChat(header http.Header, body []byte, at time.Time) {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	nonce := randomHex(32)
+	header.Set("Wechatpay-Timestamp", timestamp)
+	header.Set("Wechatpay-Nonce", nonce)
+	header.Set("Wechatpay-Serial", g.wechatSerial)
+	header.Set("Wechatpay-Signature", signRSA(g.wechatPlatformKey, timestamp+"\n"+nonce+"\n"+string(body)+"\n"))
+}
+
+// wechatTransaction 交易对象（查单应答和支付通知解密后的资源）。调用方须持有g.mu
+func wechatTransaction(co *checkout) map[string]interface{} {
+	state, amount := co.status, co.amount
+	if state == statusOpen {
+		state = "NOTPAY"
+	} else {
+		amount = co.paid
+	}
+	tx := map[string]interface{}{
+		"appid":            WeChatAppID,
+		"mchid":            WeChatMchID,
+		"out_trade_no":     co.orderNo,
+		"trade_type":       "NATIVE",
+		"trade_state":      state,
+		"trade_state_desc": state,
+		"amount": map[string]interface{}{
+			"total":          amount.Amount,
+			"payer_total":    amount.Amount,
+			"currency":       amount.Currency,
+			"payer_currency": amount.Currency,
+		},
+	}
+	if co.tradeNo != "" {
+		tx["transaction_id"] = co.tradeNo
+		tx["success_time"] = time.Now().Format(time.RFC3339)
+	}
+	return tx
+}
+
+// encryptWeChatResource 使用APIv3密钥以AEAD_AES_256_GCM加密资源
+func encryptWeChatResource(plaintext []byte, associatedData string) payment.WeChatEncryptedResource {
+	block, _ := aes.NewCipher([]byte(wechatAPIv3Key))
+	gcm, _ := cipher.NewGCM(block)
+	nonce := randomHex(12)
+	return payment.WeChatEncryptedResource{
+		Algorithm:      "AEAD_AES_256_GCM",
+		Ciphertext:     base64.StdEncoding.EncodeToString(gcm.Seal(nil, []byte(nonce), plaintext, []byte(associatedData))),
+		AssociatedData: associatedData,
+		OriginalType:   associatedData,
+		Nonce:          nonce,
+	}
+}
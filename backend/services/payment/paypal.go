@@ -3,11 +3,13 @@ package payment
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"skillhub/config"
@@ -24,8 +26,14 @@ type PayPalClient struct {
 	BaseURL      string
 	SuccessURL   string
 	CancelURL    string
-	AccessToken  string
-	TokenExpiry  time.Time
+	WebhookID    string
+
+	httpClient *http.Client
+
+	// 访问令牌缓存，可能被多个请求并发读写
+	tokenMu     sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
 }
 
 // PayPalOrderResponse PayPal订单响应
@@ -39,6 +47,33 @@ type PayPalOrderResponse struct {
 	} `json:"links"`
 }
 
+// PayPalCaptureResponse PayPal订单捕获/查询响应
+type PayPalCaptureResponse struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	PurchaseUnits []struct {
+		ReferenceID string `json:"reference_id"`
+		CustomID    string `json:"custom_id"`
+		Amount      struct {
+			CurrencyCode string `json:"currency_code"`
+			Value        string `json:"value"`
+		} `json:"amount"`
+		Payee struct {
+			MerchantID string `json:"merchant_id"`
+		} `json:"payee"`
+		Payments struct {
+			Captures []struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+				Amount struct {
+					CurrencyCode string `json:"currency_code"`
+					Value        string `json:"value"`
+				} `json:"amount"`
+			} `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+}
+
 // PayPalWebhookEvent PayPal Webhook事件
 type PayPalWebhookEvent struct {
 	ID           string                 `json:"id"`
@@ -59,15 +94,24 @@ func NewPayPalClient(cfg config.PayPalConfig) (*PayPalClient, error) {
 		mode = "sandbox"
 	}
 
-	var baseURL string
-	if mode == "sandbox" {
-		baseURL = "https://api-m.sandbox.paypal.com"
-	} else {
-		baseURL = "https://api-m.paypal.com"
+	// 可配置BaseURL，便于本地测试时指向模拟服务
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		if mode == "sandbox" {
+			baseURL = "https://api-m.sandbox.paypal.com"
+		} else {
+			baseURL = "https://api-m.paypal.com"
+		}
 	}
 
-	successURL := "http://localhost:3000/orders/success"
-	cancelURL := "http://localhost:3000/orders/cancel"
+	successURL := cfg.ReturnURL
+	if successURL == "" {
+		successURL = "http://localhost:3000/orders/success"
+	}
+	cancelURL := cfg.CancelURL
+	if cancelURL == "" {
+		cancelURL = "http://localhost:3000/orders/cancel"
+	}
 
 	return &PayPalClient{
 		ClientID:     cfg.ClientID,
@@ -76,14 +120,19 @@ func NewPayPalClient(cfg config.PayPalConfig) (*PayPalClient, error) {
 		BaseURL:      baseURL,
 		SuccessURL:   successURL,
 		CancelURL:    cancelURL,
+		WebhookID:    cfg.WebhookID,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
 	}, nil
 }
 
-// getAccessToken 获取访问令牌
+// getAccessToken 获取访问令牌（并发安全，过期前复用缓存）
 func (c *PayPalClient) getAccessToken() (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
 	// 如果令牌未过期，直接返回
-	if c.AccessToken != "" && time.Now().Before(c.TokenExpiry) {
-		return c.AccessToken, nil
+	if c.accessToken != "" && time.Now().Before(c.tokenExpiry) {
+		return c.accessToken, nil
 	}
 
 	// 请求新的访问令牌
@@ -96,8 +145,7 @@ func (c *PayPalClient) getAccessToken() (string, error) {
 	req.SetBasicAuth(c.ClientID, c.ClientSecret)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to get access token: %w", err)
 	}
@@ -119,10 +167,68 @@ func (c *PayPalClient) getAccessToken() (string, error) {
 	}
 
 	// 更新令牌和过期时间
-	c.AccessToken = result.AccessToken
-	c.TokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn-60) * time.Second) // 提前60秒过期
+	c.accessToken = result.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn-60) * time.Second) // 提前60秒过期
+
+	return c.accessToken, nil
+}
 
-	return c.AccessToken, nil
+// invalidateToken 清除缓存的访问令牌（令牌被拒绝时调用）
+func (c *PayPalClient) invalidateToken() {
+	c.tokenMu.Lock()
+	c.accessToken = ""
+	c.tokenExpiry = time.Time{}
+	c.tokenMu.Unlock()
+}
+
+// doJSON 发送带访问令牌的JSON请求，返回状态码和响应体
+func (c *PayPalClient) doJSON(method, path string, payload interface{}) (int, []byte, error) {
+	return c.doJSONWithRequestID(method, path, payload, "")
+}
+
+// doJSONWithRequestID 同doJSON，requestID非空时作为PayPal-Request-Id，网关按此对重复请求去重
+func (c *PayPalClient) doJSONWithRequestID(method, path string, payload interface{}, requestID string) (int, []byte, error) {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	accessToken, err := c.getAccessToken()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Prefer", "return=representation")
+	if requestID != "" {
+		req.Header.Set("PayPal-Request-Id", requestID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.invalidateToken()
+	}
+
+	return resp.StatusCode, respBody, nil
 }
 
 // CreatePayment 创建PayPal支付订单
@@ -132,10 +238,9 @@ func (c *PayPalClient) CreatePayment(order *models.Order, subject string) (strin
 		return c.createMockPayment(order, subject)
 	}
 
-	// 获取访问令牌
-	accessToken, err := c.getAccessToken()
+	amount, err := chargeAmount(c.GetPaymentType(), order)
 	if err != nil {
-		return "", fmt.Errorf("failed to get access token: %w", err)
+		return "", err
 	}
 
 	// 创建订单请求
@@ -144,57 +249,42 @@ func (c *PayPalClient) CreatePayment(order *models.Order, subject string) (strin
 		"purchase_units": []map[string]interface{}{
 			{
 				"reference_id": order.OrderNo,
+				"custom_id":    order.OrderNo, // 捕获资源只携带custom_id，用于Webhook关联订单
 				"amount": map[string]interface{}{
-					"currency_code": "USD",
-					"value":         fmt.Sprintf("%.2f", order.TotalAmount),
+					"currency_code": amount.Currency,
+					"value":         amount.Decimal(),
 				},
 				"description": subject,
 			},
 		},
 		"application_context": map[string]interface{}{
-			"return_url": c.SuccessURL,
-			"cancel_url": c.CancelURL,
-			"brand_name": "SkillHub",
+			"return_url":  c.SuccessURL,
+			"cancel_url":  c.CancelURL,
+			"brand_name":  "SkillHub",
 			"user_action": "PAY_NOW",
 		},
 	}
 
-	jsonData, err := json.Marshal(orderData)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal order data: %w", err)
-	}
-
 	// 创建PayPal订单
-	url := c.BaseURL + "/v2/checkout/orders"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create order request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Prefer", "return=representation")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	status, body, err := c.doJSON("POST", "/v2/checkout/orders", orderData)
 	if err != nil {
 		return "", fmt.Errorf("failed to create paypal order: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
+	if status != http.StatusCreated && status != http.StatusOK {
 		return "", fmt.Errorf("failed to create paypal order: %s", string(body))
 	}
 
 	var orderResp PayPalOrderResponse
-	if err := json.NewDecoder(resp.Body).Decode(&orderResp); err != nil {
+	if err := json.Unmarshal(body, &orderResp); err != nil {
 		return "", fmt.Errorf("failed to decode order response: %w", err)
 	}
 
+	// 记录PayPal订单ID，买家批准后据此捕获
+	order.PaymentRef = orderResp.ID
+
 	// 查找approve链接
 	for _, link := range orderResp.Links {
-		if link.Rel == "approve" {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
 			return link.Href, nil
 		}
 	}
@@ -202,27 +292,331 @@ func (c *PayPalClient) CreatePayment(order *models.Order, subject string) (strin
 	return "", fmt.Errorf("approve link not found in response")
 }
 
-// VerifyCallback 验证PayPal回调签名
+// CreateSubscription 创建PayPal订阅并返回买家授权链接；计费方案不存在时先创建商品和方案，
+// 新方案ID写回plan.PayPalPlanID，由调用方保存
+func (c *PayPalClient) CreateSubscription(order *models.Order, plan *models.SubscriptionPlan, subject string) (string, error) {
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return c.createMockPayment(order, subject)
+	}
+
+	if plan.PayPalPlanID == "" {
+		planID, err := c.createBillingPlan(plan, subject)
+		if err != nil {
+			return "", err
+		}
+		plan.PayPalPlanID = planID
+	}
+
+	subscriptionData := map[string]interface{}{
+		"plan_id":   plan.PayPalPlanID,
+		"custom_id": order.OrderNo, // 每笔扣款的custom字段，用于关联首期订单
+		"application_context": map[string]interface{}{
+			"return_url":  c.SuccessURL,
+			"cancel_url":  c.CancelURL,
+			"brand_name":  "SkillHub",
+			"user_action": "SUBSCRIBE_NOW",
+		},
+	}
+	status, body, err := c.doJSON("POST", "/v1/billing/subscriptions", subscriptionData)
+	if err != nil {
+		return "", fmt.Errorf("failed to create paypal subscription: %w", err)
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return "", fmt.Errorf("failed to create paypal subscription: %s", string(body))
+	}
+
+	var subscriptionResp PayPalOrderResponse
+	if err := json.Unmarshal(body, &subscriptionResp); err != nil {
+		return "", fmt.Errorf("failed to decode subscription response: %w", err)
+	}
+	order.PaymentRef = subscriptionResp.ID
+
+	for _, link := range subscriptionResp.Links {
+		if link.Rel == "approve" {
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("approve link not found in response")
+}
+
+// createBillingPlan 为订阅方案创建PayPal商品和计费方案，返回方案ID
+func (c *PayPalClient) createBillingPlan(plan *models.SubscriptionPlan, name string) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+
+	status, body, err := c.doJSON("POST", "/v1/catalogs/products", map[string]interface{}{
+		"name": name,
+		"type": "DIGITAL",
+	})
+	if err != nil || (status != http.StatusCreated && status != http.StatusOK) {
+		return "", fmt.Errorf("failed to create paypal product: %v %s", err, string(body))
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", fmt.Errorf("failed to decode product response: %w", err)
+	}
+
+	status, body, err = c.doJSON("POST", "/v1/billing/plans", map[string]interface{}{
+		"product_id": created.ID,
+		"name":       fmt.Sprintf("%s (%sly)", name, plan.Interval),
+		"billing_cycles": []map[string]interface{}{
+			{
+				"frequency": map[string]interface{}{
+					"interval_unit":  strings.ToUpper(string(plan.Interval)),
+					"interval_count": 1,
+				},
+				"tenure_type":  "REGULAR",
+				"sequence":     1,
+				"total_cycles": 0,
+				"pricing_scheme": map[string]interface{}{
+					"fixed_price": map[string]interface{}{
+						"value":         plan.Price.Decimal(),
+						"currency_code": plan.Price.Currency,
+					},
+				},
+			},
+		},
+		"payment_preferences": map[string]interface{}{
+			"auto_bill_outstanding":     true,
+			"payment_failure_threshold": 3,
+		},
+	})
+	if err != nil || (status != http.StatusCreated && status != http.StatusOK) {
+		return "", fmt.Errorf("failed to create paypal billing plan: %v %s", err, string(body))
+	}
+	created.ID = ""
+	if err := json.Unmarshal(body, &created); err != nil || created.ID == "" {
+		return "", fmt.Errorf("failed to decode billing plan response: %s", string(body))
+	}
+	return created.ID, nil
+}
+
+// CancelSubscription 取消PayPal订阅
+func (c *PayPalClient) CancelSubscription(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("paypal subscription id is required")
+	}
+	status, body, err := c.doJSON("POST", "/v1/billing/subscriptions/"+url.PathEscape(ref)+"/cancel",
+		map[string]interface{}{"reason": "Cancelled by subscriber"})
+	if err != nil {
+		return fmt.Errorf("failed to cancel paypal subscription: %w", err)
+	}
+	if status != http.StatusNoContent && status != http.StatusOK {
+		return fmt.Errorf("failed to cancel paypal subscription: %s", string(body))
+	}
+	return nil
+}
+
+// CaptureOrder 捕获买家已批准的PayPal订单（return_url跳转或CHECKOUT.ORDER.APPROVED事件后调用）
+func (c *PayPalClient) CaptureOrder(paypalOrderID string) (*CallbackResult, error) {
+	if paypalOrderID == "" {
+		return nil, fmt.Errorf("paypal order id is required")
+	}
+
+	status, body, err := c.doJSON("POST", "/v2/checkout/orders/"+url.PathEscape(paypalOrderID)+"/capture", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture paypal order: %w", err)
+	}
+
+	// 重复捕获时PayPal返回422 ORDER_ALREADY_CAPTURED，此时查询订单的当前状态
+	if status == http.StatusUnprocessableEntity && strings.Contains(string(body), "ORDER_ALREADY_CAPTURED") {
+		return c.GetOrder(paypalOrderID)
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return nil, fmt.Errorf("failed to capture paypal order: %s", string(body))
+	}
+
+	var captureResp PayPalCaptureResponse
+	if err := json.Unmarshal(body, &captureResp); err != nil {
+		return nil, fmt.Errorf("failed to decode capture response: %w", err)
+	}
+
+	return c.captureResult(&captureResp)
+}
+
+// QueryPayment 查询PayPal订单状态
+func (c *PayPalClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	// 订阅订单的PaymentRef是订阅ID（I-开头），订单接口查不到；订阅扣款以Webhook为准
+	if order.SubscriptionID != nil || strings.HasPrefix(order.PaymentRef, "I-") {
+		return nil, ErrQueryNotSupported
+	}
+	if order.PaymentRef == "" {
+		return nil, fmt.Errorf("order %s has no paypal order id", order.OrderNo)
+	}
+	return c.GetOrder(order.PaymentRef)
+}
+
+// Refund 按捕获ID退款，未指定全额时按金额部分退款
+func (c *PayPalClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	if req.TradeNo == "" {
+		return nil, fmt.Errorf("paypal capture id is required")
+	}
+
+	amount := refundAmount(c.GetPaymentType(), req)
+	refundData := map[string]interface{}{
+		"amount": map[string]interface{}{
+			"value":         amount.Decimal(),
+			"currency_code": amount.Currency,
+		},
+		"custom_id":     req.RefundNo, // 退款Webhook中据此关联退款记录
+		"note_to_payer": req.Reason,
+	}
+
+	// 以退款单号作为PayPal-Request-Id，重试不会重复退款
+	status, body, err := c.doJSONWithRequestID("POST", "/v2/payments/captures/"+url.PathEscape(req.TradeNo)+"/refund", refundData, req.RefundNo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund paypal capture: %w", err)
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return nil, refundError(fmt.Errorf("failed to refund paypal capture: %w", &apiError{service: "paypal", status: status, body: string(body)}))
+	}
+
+	var refundResp struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &refundResp); err != nil {
+		return nil, fmt.Errorf("failed to decode refund response: %w", err)
+	}
+
+	return &RefundResult{
+		RefundNo:         req.RefundNo,
+		ProviderRefundID: refundResp.ID,
+		Status:           mapPayPalRefundStatus(refundResp.Status),
+		Amount:           req.Amount.Decimal(),
+		RawParams:        url.Values{"status": {refundResp.Status}},
+		PaymentType:      c.GetPaymentType(),
+	}, nil
+}
+
+// mapPayPalRefundStatus 映射PayPal退款状态
+func mapPayPalRefundStatus(status string) models.RefundStatus {
+	switch status {
+	case "COMPLETED":
+		return models.RefundStatusSuccess
+	case "FAILED", "CANCELLED":
+		return models.RefundStatusFailed
+	default:
+		// PENDING
+		return models.RefundStatusPending
+	}
+}
+
+// GetOrder 查询PayPal订单
+func (c *PayPalClient) GetOrder(paypalOrderID string) (*CallbackResult, error) {
+	status, body, err := c.doJSON("GET", "/v2/checkout/orders/"+url.PathEscape(paypalOrderID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paypal order: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("failed to get paypal order: %s", string(body))
+	}
+
+	var orderResp PayPalCaptureResponse
+	if err := json.Unmarshal(body, &orderResp); err != nil {
+		return nil, fmt.Errorf("failed to decode order response: %w", err)
+	}
+
+	return c.captureResult(&orderResp)
+}
+
+// captureResult 将PayPal订单转换为统一回调结果
+func (c *PayPalClient) captureResult(resp *PayPalCaptureResponse) (*CallbackResult, error) {
+	if len(resp.PurchaseUnits) == 0 {
+		return nil, fmt.Errorf("paypal order %s has no purchase units", resp.ID)
+	}
+
+	unit := resp.PurchaseUnits[0]
+	orderNo := unit.ReferenceID
+	if orderNo == "" || orderNo == "default" {
+		orderNo = unit.CustomID
+	}
+
+	result := &CallbackResult{
+		TradeNo:     resp.ID,
+		OutTradeNo:  orderNo,
+		TradeStatus: resp.Status,
+		TotalAmount: unit.Amount.Value,
+		Currency:    unit.Amount.CurrencyCode,
+		MerchantID:  unit.Payee.MerchantID,
+		RawParams:   url.Values{"paypal_order_id": {resp.ID}},
+		PaymentType: c.GetPaymentType(),
+	}
+
+	// 已捕获时以捕获记录为准
+	if captures := unit.Payments.Captures; len(captures) > 0 {
+		capture := captures[0]
+		result.TradeNo = capture.ID
+		result.TotalAmount = capture.Amount.Value
+		result.Currency = capture.Amount.CurrencyCode
+		result.RawParams.Set("capture_status", capture.Status)
+		if capture.Status != "COMPLETED" {
+			result.TradeStatus = capture.Status
+		}
+	}
+
+	return result, nil
+}
+
+// VerifyCallback PayPal回调为Webhook，签名位于HTTP头中，需使用VerifyWebhook验证
 func (c *PayPalClient) VerifyCallback(params url.Values) (bool, error) {
-	// PayPal使用Webhook签名验证，这里返回true让上层处理
-	return true, nil
+	return false, errors.New("paypal callbacks must be verified with VerifyWebhook")
 }
 
-// ProcessCallback 处理PayPal回调
+// ProcessCallback PayPal回调需使用ProcessWebhook处理
 func (c *PayPalClient) ProcessCallback(params url.Values) (*CallbackResult, error) {
-	// PayPal回调通过Webhook处理，这里返回模拟结果
-	return &CallbackResult{
-		TradeNo:     "paypal_" + uuid.New().String()[:8],
-		OutTradeNo:  params.Get("order_no"),
-		TradeStatus: "COMPLETED",
-		TotalAmount: params.Get("amount"),
-		RawParams:   params,
-		PaymentType: c.GetPaymentType(),
-	}, nil
+	return nil, errors.New("paypal callbacks must be processed with ProcessWebhook")
+}
+
+// VerifyWebhook 通过PayPal verify-webhook-signature接口验证Webhook签名
+func (c *PayPalClient) VerifyWebhook(payload []byte, header http.Header) error {
+	if c.WebhookID == "" {
+		return fmt.Errorf("paypal webhook id is not configured")
+	}
+
+	transmissionID := header.Get("Paypal-Transmission-Id")
+	transmissionSig := header.Get("Paypal-Transmission-Sig")
+	if transmissionID == "" || transmissionSig == "" {
+		return fmt.Errorf("missing paypal transmission headers")
+	}
+
+	verifyReq := map[string]interface{}{
+		"auth_algo":         header.Get("Paypal-Auth-Algo"),
+		"cert_url":          header.Get("Paypal-Cert-Url"),
+		"transmission_id":   transmissionID,
+		"transmission_sig":  transmissionSig,
+		"transmission_time": header.Get("Paypal-Transmission-Time"),
+		"webhook_id":        c.WebhookID,
+		"webhook_event":     json.RawMessage(payload),
+	}
+
+	status, body, err := c.doJSON("POST", "/v1/notifications/verify-webhook-signature", verifyReq)
+	if err != nil {
+		return fmt.Errorf("failed to verify webhook: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("failed to verify webhook: %s", string(body))
+	}
+
+	var result struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to decode verification response: %w", err)
+	}
+	if result.VerificationStatus != "SUCCESS" {
+		return fmt.Errorf("webhook signature verification failed: %s", result.VerificationStatus)
+	}
+
+	return nil
 }
 
-// ProcessWebhook 处理PayPal Webhook事件
-func (c *PayPalClient) ProcessWebhook(payload []byte, signature string) (*CallbackResult, error) {
+// ProcessWebhook 验证并处理PayPal Webhook事件
+func (c *PayPalClient) ProcessWebhook(payload []byte, header http.Header) (*CallbackResult, error) {
+	if err := c.VerifyWebhook(payload, header); err != nil {
+		return nil, err
+	}
+
 	// 解析Webhook事件
 	var event PayPalWebhookEvent
 	if err := json.Unmarshal(payload, &event); err != nil {
@@ -236,38 +630,117 @@ func (c *PayPalClient) ProcessWebhook(payload []byte, signature string) (*Callba
 func (c *PayPalClient) handlePayPalEvent(event *PayPalWebhookEvent) (*CallbackResult, error) {
 	switch event.EventType {
 	case "CHECKOUT.ORDER.APPROVED":
-		// 订单已批准，等待支付完成
-		return &CallbackResult{
-			TradeNo:     event.ID,
-			OutTradeNo:  extractOrderNo(event.Resource),
-			TradeStatus: "APPROVED",
-			TotalAmount: extractAmount(event.Resource),
-			RawParams:   url.Values{},
-			PaymentType: c.GetPaymentType(),
-		}, nil
+		// 订单已批准，捕获资金后才算支付完成
+		paypalOrderID, _ := event.Resource["id"].(string)
+		return c.CaptureOrder(paypalOrderID)
 
 	case "CHECKOUT.ORDER.COMPLETED":
 		// 订单已完成支付
+		amount, currency := extractAmount(event.Resource)
 		return &CallbackResult{
-			TradeNo:     event.ID,
+			TradeNo:     extractCaptureID(event.Resource),
 			OutTradeNo:  extractOrderNo(event.Resource),
 			TradeStatus: "COMPLETED",
-			TotalAmount: extractAmount(event.Resource),
-			RawParams:   url.Values{},
+			TotalAmount: amount,
+			Currency:    currency,
+			MerchantID:  extractMerchantID(event.Resource),
+			RawParams:   url.Values{"event_id": {event.ID}},
 			PaymentType: c.GetPaymentType(),
 		}, nil
 
-	case "PAYMENT.CAPTURE.COMPLETED":
-		// 支付捕获完成
+	case "PAYMENT.CAPTURE.COMPLETED", "PAYMENT.CAPTURE.DENIED":
+		// 捕获资源：id为捕获ID，custom_id为商户订单号
+		captureID, _ := event.Resource["id"].(string)
+		orderNo, _ := event.Resource["custom_id"].(string)
+		status, _ := event.Resource["status"].(string)
+		var amount, currency, merchantID string
+		if amountObj, ok := event.Resource["amount"].(map[string]interface{}); ok {
+			amount, _ = amountObj["value"].(string)
+			currency, _ = amountObj["currency_code"].(string)
+		}
+		if payee, ok := event.Resource["payee"].(map[string]interface{}); ok {
+			merchantID, _ = payee["merchant_id"].(string)
+		}
 		return &CallbackResult{
-			TradeNo:     event.ID,
-			OutTradeNo:  extractOrderNo(event.Resource),
-			TradeStatus: "COMPLETED",
-			TotalAmount: extractAmount(event.Resource),
-			RawParams:   url.Values{},
+			TradeNo:     captureID,
+			OutTradeNo:  orderNo,
+			TradeStatus: status,
+			TotalAmount: amount,
+			Currency:    currency,
+			MerchantID:  merchantID,
+			RawParams:   url.Values{"event_id": {event.ID}},
 			PaymentType: c.GetPaymentType(),
 		}, nil
 
+	case "PAYMENT.CAPTURE.REFUNDED":
+		// 退款资源：id为退款ID，custom_id为商户退款单号
+		refundID, _ := event.Resource["id"].(string)
+		refundNo, _ := event.Resource["custom_id"].(string)
+		status, _ := event.Resource["status"].(string)
+		var amount string
+		if amountObj, ok := event.Resource["amount"].(map[string]interface{}); ok {
+			amount, _ = amountObj["value"].(string)
+		}
+		if refundNo == "" {
+			return nil, fmt.Errorf("refund %s has no custom_id", refundID)
+		}
+		params := url.Values{"event_id": {event.ID}}
+		return &CallbackResult{
+			TradeNo:     refundID,
+			TradeStatus: status,
+			RawParams:   params,
+			PaymentType: c.GetPaymentType(),
+			Refund: &RefundResult{
+				RefundNo:         refundNo,
+				ProviderRefundID: refundID,
+				Status:           mapPayPalRefundStatus(status),
+				Amount:           amount,
+				RawParams:        params,
+				PaymentType:      c.GetPaymentType(),
+			},
+		}, nil
+
+	case "PAYMENT.SALE.COMPLETED":
+		// 订阅扣款（含首期）：billing_agreement_id为订阅ID，custom为创建订阅时的custom_id
+		saleID, _ := event.Resource["id"].(string)
+		subscriptionRef, _ := event.Resource["billing_agreement_id"].(string)
+		if subscriptionRef == "" {
+			return nil, fmt.Errorf("sale %s is not a subscription payment", saleID)
+		}
+		orderNo, _ := event.Resource["custom"].(string)
+		var amount, currency string
+		if amountObj, ok := event.Resource["amount"].(map[string]interface{}); ok {
+			amount, _ = amountObj["total"].(string)
+			currency, _ = amountObj["currency"].(string)
+		}
+		return subscriptionCallback(&SubscriptionEvent{
+			Kind:        SubscriptionEventRenewed,
+			Ref:         subscriptionRef,
+			OrderNo:     orderNo,
+			TradeNo:     saleID,
+			Amount:      amount,
+			Currency:    currency,
+			PaymentType: c.GetPaymentType(),
+		}, event.EventType), nil
+
+	case "BILLING.SUBSCRIPTION.ACTIVATED", "BILLING.SUBSCRIPTION.PAYMENT.FAILED", "BILLING.SUBSCRIPTION.SUSPENDED",
+		"BILLING.SUBSCRIPTION.CANCELLED", "BILLING.SUBSCRIPTION.EXPIRED":
+		subscriptionRef, _ := event.Resource["id"].(string)
+		orderNo, _ := event.Resource["custom_id"].(string)
+		kind := SubscriptionEventCancelled
+		switch event.EventType {
+		case "BILLING.SUBSCRIPTION.ACTIVATED":
+			kind = SubscriptionEventActivated
+		case "BILLING.SUBSCRIPTION.PAYMENT.FAILED", "BILLING.SUBSCRIPTION.SUSPENDED":
+			kind = SubscriptionEventPaymentFailed
+		}
+		return subscriptionCallback(&SubscriptionEvent{
+			Kind:        kind,
+			Ref:         subscriptionRef,
+			OrderNo:     orderNo,
+			PaymentType: c.GetPaymentType(),
+		}, event.EventType), nil
+
 	default:
 		return nil, fmt.Errorf("unhandled event type: %s", event.EventType)
 	}
@@ -277,26 +750,61 @@ func (c *PayPalClient) handlePayPalEvent(event *PayPalWebhookEvent) (*CallbackRe
 func extractOrderNo(resource map[string]interface{}) string {
 	if purchaseUnits, ok := resource["purchase_units"].([]interface{}); ok && len(purchaseUnits) > 0 {
 		if unit, ok := purchaseUnits[0].(map[string]interface{}); ok {
-			if referenceID, ok := unit["reference_id"].(string); ok {
+			if referenceID, ok := unit["reference_id"].(string); ok && referenceID != "default" {
 				return referenceID
 			}
+			if customID, ok := unit["custom_id"].(string); ok {
+				return customID
+			}
 		}
 	}
 	return "unknown"
 }
 
-// extractAmount 从资源中提取金额
-func extractAmount(resource map[string]interface{}) string {
+// extractCaptureID 从订单资源中提取捕获ID
+func extractCaptureID(resource map[string]interface{}) string {
 	if purchaseUnits, ok := resource["purchase_units"].([]interface{}); ok && len(purchaseUnits) > 0 {
 		if unit, ok := purchaseUnits[0].(map[string]interface{}); ok {
-			if amount, ok := unit["amount"].(map[string]interface{}); ok {
-				if value, ok := amount["value"].(string); ok {
-					return value
+			if payments, ok := unit["payments"].(map[string]interface{}); ok {
+				if captures, ok := payments["captures"].([]interface{}); ok && len(captures) > 0 {
+					if capture, ok := captures[0].(map[string]interface{}); ok {
+						if id, ok := capture["id"].(string); ok {
+							return id
+						}
+					}
 				}
 			}
 		}
 	}
-	return "0.00"
+	id, _ := resource["id"].(string)
+	return id
+}
+
+// extractAmount 从资源中提取金额和币种
+func extractAmount(resource map[string]interface{}) (string, string) {
+	if purchaseUnits, ok := resource["purchase_units"].([]interface{}); ok && len(purchaseUnits) > 0 {
+		if unit, ok := purchaseUnits[0].(map[string]interface{}); ok {
+			if amount, ok := unit["amount"].(map[string]interface{}); ok {
+				value, _ := amount["value"].(string)
+				currency, _ := amount["currency_code"].(string)
+				return value, currency
+			}
+		}
+	}
+	return "", ""
+}
+
+// extractMerchantID 从订单资源中提取收款商户ID
+func extractMerchantID(resource map[string]interface{}) string {
+	if purchaseUnits, ok := resource["purchase_units"].([]interface{}); ok && len(purchaseUnits) > 0 {
+		if unit, ok := purchaseUnits[0].(map[string]interface{}); ok {
+			if payee, ok := unit["payee"].(map[string]interface{}); ok {
+				merchantID, _ := payee["merchant_id"].(string)
+				return merchantID
+			}
+		}
+	}
+	return ""
 }
 
 // GetPaymentType 获取支付类型
@@ -307,8 +815,8 @@ func (c *PayPalClient) GetPaymentType() PaymentType {
 // createMockPayment 创建模拟支付
 func (c *PayPalClient) createMockPayment(order *models.Order, subject string) (string, error) {
 	paymentID := uuid.New().String()
-	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%.2f&provider=paypal",
-		paymentID, order.OrderNo, order.TotalAmount), nil
+	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%s&provider=paypal",
+		paymentID, order.OrderNo, order.Total.Decimal()), nil
 }
 
 // MockPayPalClient 模拟PayPal客户端（用于开发和测试）
@@ -322,8 +830,8 @@ func NewMockPayPalClient() *MockPayPalClient {
 // CreatePayment 创建模拟支付
 func (c *MockPayPalClient) CreatePayment(order *models.Order, subject string) (string, error) {
 	paymentID := uuid.New().String()
-	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%.2f&provider=paypal",
-		paymentID, order.OrderNo, order.TotalAmount), nil
+	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%s&provider=paypal",
+		paymentID, order.OrderNo, order.Total.Decimal()), nil
 }
 
 // VerifyCallback 验证模拟回调
@@ -348,8 +856,18 @@ func (c *MockPayPalClient) GetPaymentType() PaymentType {
 	return PaymentTypeMock
 }
 
+// Refund 模拟退款，直接返回成功
+func (c *MockPayPalClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	return newMockRefundResult(req, c.GetPaymentType()), nil
+}
+
+// QueryPayment 模拟支付不支持查询
+func (c *MockPayPalClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	return nil, ErrQueryNotSupported
+}
+
 // ProcessWebhook 处理模拟Webhook
-func (c *MockPayPalClient) ProcessWebhook(payload []byte, signature string) (*CallbackResult, error) {
+func (c *MockPayPalClient) ProcessWebhook(payload []byte, header http.Header) (*CallbackResult, error) {
 	return &CallbackResult{
 		TradeNo:     "mock_webhook_" + uuid.New().String()[:8],
 		OutTradeNo:  "mock_order",
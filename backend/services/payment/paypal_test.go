@@ -0,0 +1,201 @@
+package payment
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+// newTestPayPalClient 创建指向模拟服务的PayPal客户端
+func newTestPayPalClient(t *testing.T, tokenRequests *int32, handler http.HandlerFunc) *PayPalClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/oauth2/token" {
+			atomic.AddInt32(tokenRequests, 1)
+			w.Write([]byte(`{"access_token":"A21AAtest","token_type":"Bearer","expires_in":32400}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer A21AAtest" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewPayPalClient(config.PayPalConfig{
+		ClientID:     "test_client",
+		ClientSecret: "test_secret",
+		Mode:         "sandbox",
+		WebhookID:    "WH-TEST",
+		BaseURL:      server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewPayPalClient failed: %v", err)
+	}
+	return client
+}
+
+func TestPayPalTokenCacheConcurrent(t *testing.T) {
+	var tokenRequests int32
+	client := newTestPayPalClient(t, &tokenRequests, func(w http.ResponseWriter, r *http.Request) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.getAccessToken(); err != nil {
+				t.Errorf("getAccessToken failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if tokenRequests != 1 {
+		t.Errorf("expected 1 token request, got %d", tokenRequests)
+	}
+}
+
+func TestGetPaymentServiceReusesPayPalClient(t *testing.T) {
+	var tokenRequests int32
+	stub := newTestPayPalClient(t, &tokenRequests, func(w http.ResponseWriter, r *http.Request) {})
+	cfg := config.Config{}
+	cfg.Payment.PayPal = config.PayPalConfig{ClientID: "test_client", ClientSecret: "test_secret", BaseURL: stub.BaseURL}
+
+	// 访问令牌缓存挂在客户端上，跨请求只应获取一次令牌
+	for i := 0; i < 3; i++ {
+		service, err := GetPaymentService(PaymentTypePayPal, cfg)
+		if err != nil {
+			t.Fatalf("GetPaymentService failed: %v", err)
+		}
+		if _, err := service.(*PayPalClient).getAccessToken(); err != nil {
+			t.Fatalf("getAccessToken failed: %v", err)
+		}
+	}
+	byCurrency, _ := GetPaymentServiceForCurrency(cfg, "USD")
+	if _, err := byCurrency.(*PayPalClient).getAccessToken(); err != nil {
+		t.Fatalf("getAccessToken failed: %v", err)
+	}
+	if tokenRequests != 1 {
+		t.Errorf("expected 1 token request across calls, got %d", tokenRequests)
+	}
+}
+
+func TestPayPalCreatePaymentAndCapture(t *testing.T) {
+	var tokenRequests int32
+	client := newTestPayPalClient(t, &tokenRequests, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/checkout/orders":
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			unit := req["purchase_units"].([]interface{})[0].(map[string]interface{})
+			if unit["custom_id"] != "ORDPP001" {
+				t.Errorf("expected custom_id ORDPP001, got %v", unit["custom_id"])
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"5O190127TN364715T","status":"CREATED","links":[{"href":"https://www.sandbox.paypal.com/checkoutnow?token=5O190127TN364715T","rel":"approve","method":"GET"}]}`))
+		case "/v2/checkout/orders/5O190127TN364715T/capture":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"5O190127TN364715T","status":"COMPLETED","purchase_units":[{"reference_id":"ORDPP001","payments":{"captures":[{"id":"3C679366HH908993F","status":"COMPLETED","amount":{"currency_code":"USD","value":"19.99"}}]}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	order := &models.Order{ID: uuid.New(), OrderNo: "ORDPP001", Total: models.NewMoney(1999, "USD")}
+	approveURL, err := client.CreatePayment(order, "测试技能")
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if approveURL != "https://www.sandbox.paypal.com/checkoutnow?token=5O190127TN364715T" {
+		t.Errorf("unexpected approve url: %s", approveURL)
+	}
+	if order.PaymentRef != "5O190127TN364715T" {
+		t.Errorf("expected payment ref to be set, got %q", order.PaymentRef)
+	}
+
+	result, err := client.CaptureOrder(order.PaymentRef)
+	if err != nil {
+		t.Fatalf("CaptureOrder failed: %v", err)
+	}
+	if result.OutTradeNo != "ORDPP001" || result.TradeNo != "3C679366HH908993F" {
+		t.Errorf("unexpected capture result: %+v", result)
+	}
+	if result.TradeStatus != "COMPLETED" || result.TotalAmount != "19.99" {
+		t.Errorf("unexpected capture status/amount: %+v", result)
+	}
+}
+
+func TestPayPalProcessWebhook(t *testing.T) {
+	var tokenRequests int32
+	client := newTestPayPalClient(t, &tokenRequests, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/notifications/verify-webhook-signature" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			TransmissionSig string          `json:"transmission_sig"`
+			WebhookID       string          `json:"webhook_id"`
+			WebhookEvent    json.RawMessage `json:"webhook_event"`
+		}
+		json.Unmarshal(body, &req)
+		if req.WebhookID != "WH-TEST" {
+			t.Errorf("unexpected webhook id: %s", req.WebhookID)
+		}
+		status := "FAILURE"
+		if req.TransmissionSig == "valid-signature" && len(req.WebhookEvent) > 0 {
+			status = "SUCCESS"
+		}
+		w.Write([]byte(`{"verification_status":"` + status + `"}`))
+	})
+
+	payload := []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.COMPLETED","resource":{"id":"3C679366HH908993F","status":"COMPLETED","custom_id":"ORDPP001","amount":{"currency_code":"USD","value":"19.99"}}}`)
+	header := http.Header{}
+	header.Set("Paypal-Auth-Algo", "SHA256withRSA")
+	header.Set("Paypal-Cert-Url", "https://api.sandbox.paypal.com/v1/notifications/certs/CERT-360caa42")
+	header.Set("Paypal-Transmission-Id", "69cd13f0-d67a-11e5-baa3-778b53f4ae55")
+	header.Set("Paypal-Transmission-Time", "2016-02-18T20:01:35Z")
+	header.Set("Paypal-Transmission-Sig", "valid-signature")
+
+	result, err := client.ProcessWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("ProcessWebhook failed: %v", err)
+	}
+	if result.OutTradeNo != "ORDPP001" || result.TradeNo != "3C679366HH908993F" || result.TradeStatus != "COMPLETED" {
+		t.Errorf("unexpected webhook result: %+v", result)
+	}
+
+	// 签名验证失败应拒绝
+	header.Set("Paypal-Transmission-Sig", "forged-signature")
+	if _, err := client.ProcessWebhook(payload, header); err == nil {
+		t.Error("expected verification failure for forged signature")
+	}
+
+	// 未配置Webhook ID时应拒绝
+	client.WebhookID = ""
+	header.Set("Paypal-Transmission-Sig", "valid-signature")
+	if _, err := client.ProcessWebhook(payload, header); err == nil {
+		t.Error("expected failure without webhook id")
+	}
+
+	// 表单回调没有签名，不能确认付款
+	params := url.Values{"order_no": {"ORDPP001"}, "amount": {"19.99"}}
+	if ok, err := client.VerifyCallback(params); ok || err == nil {
+		t.Error("expected VerifyCallback to refuse unsigned paypal callbacks")
+	}
+	if result, err := client.ProcessCallback(params); result != nil || err == nil {
+		t.Errorf("expected ProcessCallback to refuse unsigned paypal callbacks, got %+v", result)
+	}
+}
@@ -0,0 +1,121 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"skillhub/config"
+	"skillhub/models"
+)
+
+// ErrProviderNotAvailable 请求的支付方式未启用
+var ErrProviderNotAvailable = errors.New("payment provider not available")
+
+// 支付流程
+const (
+	FlowRedirect = "redirect" // 跳转到收银台页面
+	FlowQRCode   = "qrcode"   // 展示二维码扫码支付
+	FlowH5       = "h5"       // 手机浏览器内跳转支付
+)
+
+// supportedFlows 各支付方式支持的支付流程，第一个为默认流程
+var supportedFlows = map[PaymentType][]string{
+	PaymentTypeAlipay: {FlowRedirect},
+	PaymentTypeWeChat: {FlowQRCode, FlowH5},
+	PaymentTypeStripe: {FlowRedirect},
+	PaymentTypePayPal: {FlowRedirect},
+	PaymentTypeMock:   {FlowRedirect},
+}
+
+// ProviderInfo 可供买家选择的支付方式
+type ProviderInfo struct {
+	Type       PaymentType `json:"type"`
+	Currencies []string    `json:"currencies"` // 为空表示不限制币种
+	Flows      []string    `json:"flows"`
+}
+
+// EnabledProviders 列出已启用的支付方式，均未配置时沙箱模式下仅返回模拟支付
+func EnabledProviders(cfg config.Config) []ProviderInfo {
+	services := configuredServices(cfg)
+	if len(services) == 0 {
+		if !cfg.Payment.Sandbox {
+			return []ProviderInfo{}
+		}
+		return []ProviderInfo{providerInfo(PaymentTypeMock)}
+	}
+
+	providers := make([]ProviderInfo, 0, len(services))
+	for _, service := range services {
+		providers = append(providers, providerInfo(service.GetPaymentType()))
+	}
+	return providers
+}
+
+// providerInfo 支付方式的币种和流程信息
+func providerInfo(paymentType PaymentType) ProviderInfo {
+	currencies := SupportedCurrencies(paymentType)
+	if currencies == nil {
+		currencies = []string{}
+	}
+	return ProviderInfo{Type: paymentType, Currencies: currencies, Flows: supportedFlows[paymentType]}
+}
+
+// IsKnownProvider 是否为平台支持的真实支付方式（不含模拟支付）
+func IsKnownProvider(paymentType PaymentType) bool {
+	_, ok := supportedCurrencies[paymentType]
+	return ok
+}
+
+// isEnabled 支付方式是否已启用
+func isEnabled(providers []ProviderInfo, paymentType PaymentType) bool {
+	for _, p := range providers {
+		if p.Type == paymentType {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceForOrder 选择订单的支付服务：买家指定支付方式时使用该方式，
+// 否则按管理员配置的路由规则选择，没有命中的规则时按默认优先级选择支持订单币种的支付方式
+func ServiceForOrder(cfg config.Config, order *models.Order, paymentType PaymentType) (PaymentService, error) {
+	providers := EnabledProviders(cfg)
+	currency := order.Total.Currency
+
+	if paymentType != "" {
+		if !isEnabled(providers, paymentType) {
+			return nil, fmt.Errorf("%w: %s", ErrProviderNotAvailable, paymentType)
+		}
+		if !SupportsCurrency(paymentType, currency) {
+			return nil, fmt.Errorf("%w: %s does not accept %s", ErrCurrencyNotSupported, paymentType, currency)
+		}
+		return GetPaymentService(paymentType, cfg)
+	}
+
+	if db := models.GetDB(); db != nil {
+		var routes []models.PaymentRoute
+		if err := db.Where("is_active = ?", true).Order("priority ASC, created_at ASC").Find(&routes).Error; err != nil {
+			log.Printf("Failed to load payment routes: %v", err)
+		} else if routed := matchRoute(routes, order, providers); routed != "" {
+			return GetPaymentService(routed, cfg)
+		}
+	}
+
+	return GetPaymentServiceForCurrency(cfg, currency)
+}
+
+// matchRoute 返回第一条命中订单、已启用且支持订单币种的路由规则的支付方式
+func matchRoute(routes []models.PaymentRoute, order *models.Order, providers []ProviderInfo) PaymentType {
+	for i := range routes {
+		route := &routes[i]
+		paymentType := PaymentType(route.PaymentType)
+		if !route.Matches(order) || !isEnabled(providers, paymentType) {
+			continue
+		}
+		if SupportsCurrency(paymentType, order.Total.Currency) {
+			return paymentType
+		}
+	}
+	return ""
+}
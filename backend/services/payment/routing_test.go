@@ -0,0 +1,83 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+
+	"skillhub/config"
+	"skillhub/models"
+)
+
+func TestEnabledProviders(t *testing.T) {
+	if providers := EnabledProviders(config.Config{}); len(providers) != 0 {
+		t.Fatalf("expected no provider outside sandbox mode, got %+v", providers)
+	}
+
+	sandbox := config.Config{}
+	sandbox.Payment.Sandbox = true
+	providers := EnabledProviders(sandbox)
+	if len(providers) != 1 || providers[0].Type != PaymentTypeMock {
+		t.Fatalf("expected only mock provider, got %+v", providers)
+	}
+
+	cfg := config.Config{}
+	cfg.Payment.PayPal.ClientID = "client"
+	cfg.Payment.PayPal.ClientSecret = "secret"
+	cfg.Payment.Stripe.SecretKey = "sk_test"
+
+	providers = EnabledProviders(cfg)
+	if len(providers) != 2 || providers[0].Type != PaymentTypeStripe || providers[1].Type != PaymentTypePayPal {
+		t.Fatalf("expected stripe and paypal, got %+v", providers)
+	}
+	if flows := providerInfo(PaymentTypeWeChat).Flows; len(flows) != 2 || flows[1] != FlowH5 {
+		t.Errorf("expected wechat to support h5, got %v", flows)
+	}
+}
+
+func TestServiceForOrderWithPaymentType(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Payment.Stripe.SecretKey = "sk_test"
+	order := &models.Order{Total: models.NewMoney(1999, "USD")}
+
+	service, err := ServiceForOrder(cfg, order, PaymentTypeStripe)
+	if err != nil || service.GetPaymentType() != PaymentTypeStripe {
+		t.Errorf("expected stripe, got %v, %v", service, err)
+	}
+	if _, err := ServiceForOrder(cfg, order, PaymentTypePayPal); !errors.Is(err, ErrProviderNotAvailable) {
+		t.Errorf("expected ErrProviderNotAvailable for unconfigured paypal, got %v", err)
+	}
+
+	cfg.Payment.PayPal.ClientID = "client"
+	cfg.Payment.PayPal.ClientSecret = "secret"
+	cnyOrder := &models.Order{Total: models.NewMoney(1999, "CNY")}
+	if _, err := ServiceForOrder(cfg, cnyOrder, PaymentTypePayPal); !errors.Is(err, ErrCurrencyNotSupported) {
+		t.Errorf("expected paypal to reject CNY, got %v", err)
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	providers := []ProviderInfo{providerInfo(PaymentTypeAlipay), providerInfo(PaymentTypeStripe), providerInfo(PaymentTypePayPal)}
+	routes := []models.PaymentRoute{
+		{Priority: 1, Currency: "USD", MinAmount: 10000, PaymentType: string(PaymentTypePayPal)},
+		{Priority: 2, Region: "DE", PaymentType: string(PaymentTypeWeChat)},
+		{Priority: 3, Region: "DE", PaymentType: string(PaymentTypeStripe)},
+		{Priority: 4, PaymentType: string(PaymentTypeAlipay)},
+	}
+
+	tests := []struct {
+		name  string
+		order models.Order
+		want  PaymentType
+	}{
+		{"amount above bound", models.Order{Total: models.NewMoney(15000, "USD")}, PaymentTypePayPal},
+		{"amount below bound", models.Order{Total: models.NewMoney(1999, "USD")}, ""},
+		{"region skips disabled provider", models.Order{Total: models.NewMoney(1999, "EUR"), Region: "DE"}, PaymentTypeStripe},
+		{"catch-all", models.Order{Total: models.NewMoney(1999, "CNY")}, PaymentTypeAlipay},
+	}
+
+	for _, tt := range tests {
+		if got := matchRoute(routes, &tt.order, providers); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
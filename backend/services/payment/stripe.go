@@ -1,9 +1,18 @@
 package payment
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"skillhub/config"
 	"skillhub/models"
@@ -18,12 +27,39 @@ type StripeClient struct {
 	WebhookSecret  string
 	SuccessURL     string
 	CancelURL      string
+	BaseURL        string
+	// Sandbox 支付沙箱下允许未配置WebhookSecret时跳过签名校验，其余情况未配置时拒绝所有Webhook
+	Sandbox bool
+
+	httpClient *http.Client
+}
+
+// StripeCheckoutSession Stripe Checkout会话
+type StripeCheckoutSession struct {
+	ID            string            `json:"id"`
+	URL           string            `json:"url"`
+	Status        string            `json:"status"`         // open, complete, expired
+	PaymentStatus string            `json:"payment_status"` // paid, unpaid, no_payment_required
+	AmountTotal   int64             `json:"amount_total"`
+	Currency      string            `json:"currency"`
+	PaymentIntent string            `json:"payment_intent"`
+	Metadata      map[string]string `json:"metadata"`
 }
 
 // NewStripeClient 创建Stripe客户端
 func NewStripeClient(cfg config.StripeConfig) (*StripeClient, error) {
-	successURL := "http://localhost:3000/orders/success"
-	cancelURL := "http://localhost:3000/orders/cancel"
+	successURL := cfg.SuccessURL
+	if successURL == "" {
+		successURL = "http://localhost:3000/orders/success"
+	}
+	cancelURL := cfg.CancelURL
+	if cancelURL == "" {
+		cancelURL = "http://localhost:3000/orders/cancel"
+	}
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://api.stripe.com"
+	}
 
 	return &StripeClient{
 		SecretKey:      cfg.SecretKey,
@@ -31,55 +67,322 @@ func NewStripeClient(cfg config.StripeConfig) (*StripeClient, error) {
 		WebhookSecret:  cfg.WebhookSecret,
 		SuccessURL:     successURL,
 		CancelURL:      cancelURL,
+		BaseURL:        baseURL,
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
 	}, nil
 }
 
-// CreatePayment 创建Stripe支付会话
+// CreatePayment 创建Stripe Checkout支付会话
 func (c *StripeClient) CreatePayment(order *models.Order, subject string) (string, error) {
 	// 如果配置不完整，返回模拟支付URL
 	if c.SecretKey == "" {
 		return c.createMockPayment(order, subject)
 	}
 
-	// TODO: 实现真实的Stripe API调用（需要配置STRIPE_SECRET_KEY）
-	// 参考Stripe API文档：https://stripe.com/docs/api
-	// 这里暂时使用模拟支付
-	return c.createMockPayment(order, subject)
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", c.SuccessURL+"?session_id={CHECKOUT_SESSION_ID}")
+	form.Set("cancel_url", c.CancelURL)
+	form.Set("client_reference_id", order.OrderNo)
+	form.Set("metadata[order_no]", order.OrderNo)
+	form.Set("payment_intent_data[metadata][order_no]", order.OrderNo)
+	form.Set("line_items[0][quantity]", "1")
+	amount, err := chargeAmount(c.GetPaymentType(), order)
+	if err != nil {
+		return "", err
+	}
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(amount.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(amount.Amount, 10))
+	form.Set("line_items[0][price_data][product_data][name]", subject)
+
+	var session StripeCheckoutSession
+	if err := c.doRequest("POST", "/v1/checkout/sessions", form, "", &session); err != nil {
+		return "", fmt.Errorf("failed to create checkout session: %w", err)
+	}
+
+	// 记录会话ID，用于查询支付状态
+	order.PaymentRef = session.ID
+	return session.URL, nil
 }
 
-// VerifyCallback 验证Stripe Webhook签名
-func (c *StripeClient) VerifyCallback(params url.Values) (bool, error) {
-	// Stripe使用Webhook签名验证，这里返回true让上层处理
-	return true, nil
+// CreateSubscription 创建订阅模式的Stripe Checkout会话，首期在会话中支付，之后由Stripe按周期自动扣款
+func (c *StripeClient) CreateSubscription(order *models.Order, plan *models.SubscriptionPlan, subject string) (string, error) {
+	if c.SecretKey == "" {
+		return c.createMockPayment(order, subject)
+	}
+
+	form := url.Values{}
+	form.Set("mode", "subscription")
+	form.Set("success_url", c.SuccessURL+"?session_id={CHECKOUT_SESSION_ID}")
+	form.Set("cancel_url", c.CancelURL)
+	form.Set("client_reference_id", order.OrderNo)
+	form.Set("metadata[order_no]", order.OrderNo)
+	// 订阅的metadata会带到后续每张账单，用于关联首期订单
+	form.Set("subscription_data[metadata][order_no]", order.OrderNo)
+	form.Set("line_items[0][quantity]", "1")
+	amount, err := chargeAmount(c.GetPaymentType(), order)
+	if err != nil {
+		return "", err
+	}
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(amount.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(amount.Amount, 10))
+	form.Set("line_items[0][price_data][recurring][interval]", string(plan.Interval))
+	form.Set("line_items[0][price_data][product_data][name]", subject)
+
+	var session StripeCheckoutSession
+	if err := c.doRequest("POST", "/v1/checkout/sessions", form, "", &session); err != nil {
+		return "", fmt.Errorf("failed to create checkout session: %w", err)
+	}
+
+	order.PaymentRef = session.ID
+	return session.URL, nil
 }
 
-// ProcessCallback 处理Stripe Webhook回调
-func (c *StripeClient) ProcessCallback(params url.Values) (*CallbackResult, error) {
-	// Stripe回调通过Webhook处理，这里返回模拟结果
-	// 实际实现中，应该从HTTP请求头和body中解析Webhook事件
+// CancelSubscription 在当期结束时取消Stripe订阅
+func (c *StripeClient) CancelSubscription(ref string) error {
+	if ref == "" {
+		return fmt.Errorf("stripe subscription id is required")
+	}
+	form := url.Values{}
+	form.Set("cancel_at_period_end", "true")
+	var subscription struct {
+		ID string `json:"id"`
+	}
+	if err := c.doRequest("POST", "/v1/subscriptions/"+url.PathEscape(ref), form, "", &subscription); err != nil {
+		return fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+	return nil
+}
+
+// QueryPayment 通过会话ID查询Stripe Checkout支付状态
+func (c *StripeClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	if order.PaymentRef == "" {
+		return nil, fmt.Errorf("order %s has no stripe checkout session", order.OrderNo)
+	}
+
+	var session StripeCheckoutSession
+	if err := c.doRequest("GET", "/v1/checkout/sessions/"+url.PathEscape(order.PaymentRef), nil, "", &session); err != nil {
+		return nil, fmt.Errorf("failed to retrieve checkout session: %w", err)
+	}
+
+	// 统一为Webhook使用的状态：已支付为succeeded，过期为expired，其余为待支付
+	status := session.Status
+	if session.PaymentStatus == "paid" {
+		status = "succeeded"
+	}
+
+	orderNo := session.Metadata["order_no"]
+	if orderNo == "" {
+		orderNo = order.OrderNo
+	}
+
+	// 与Webhook一致，以PaymentIntent ID为交易号
+	tradeNo := session.PaymentIntent
+	if tradeNo == "" {
+		tradeNo = session.ID
+	}
+
 	return &CallbackResult{
-		TradeNo:     "stripe_" + uuid.New().String()[:8],
-		OutTradeNo:  params.Get("order_no"),
-		TradeStatus: "succeeded",
-		TotalAmount: params.Get("amount"),
-		RawParams:   params,
+		TradeNo:     tradeNo,
+		OutTradeNo:  orderNo,
+		TradeStatus: status,
+		TotalAmount: models.NewMoney(session.AmountTotal, session.Currency).Decimal(),
+		Currency:    strings.ToUpper(session.Currency),
+		RawParams: url.Values{
+			"session_status": {session.Status},
+			"payment_status": {session.PaymentStatus},
+			"payment_intent": {session.PaymentIntent},
+		},
 		PaymentType: c.GetPaymentType(),
 	}, nil
 }
 
+// Refund 对Checkout会话对应的PaymentIntent发起全额或部分退款
+func (c *StripeClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	paymentIntent, err := c.resolvePaymentIntent(req)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("payment_intent", paymentIntent)
+	form.Set("amount", strconv.FormatInt(refundAmount(c.GetPaymentType(), req).Amount, 10))
+	form.Set("reason", "requested_by_customer")
+	form.Set("metadata[refund_no]", req.RefundNo)
+	form.Set("metadata[order_no]", req.OrderNo)
+	if req.Reason != "" {
+		form.Set("metadata[reason]", req.Reason)
+	}
+
+	var refund struct {
+		ID       string `json:"id"`
+		Status   string `json:"status"`
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	// 以退款单号作为幂等键，重试不会重复退款
+	if err := c.doRequest("POST", "/v1/refunds", form, req.RefundNo, &refund); err != nil {
+		return nil, refundError(fmt.Errorf("failed to create refund: %w", err))
+	}
+
+	return &RefundResult{
+		RefundNo:         req.RefundNo,
+		ProviderRefundID: refund.ID,
+		Status:           mapStripeRefundStatus(refund.Status),
+		Amount:           models.NewMoney(refund.Amount, refund.Currency).Decimal(),
+		RawParams:        url.Values{"status": {refund.Status}},
+		PaymentType:      c.GetPaymentType(),
+	}, nil
+}
+
+// resolvePaymentIntent 获取退款所需的PaymentIntent ID
+func (c *StripeClient) resolvePaymentIntent(req *RefundRequest) (string, error) {
+	if strings.HasPrefix(req.TradeNo, "pi_") {
+		return req.TradeNo, nil
+	}
+
+	sessionID := req.PaymentRef
+	if strings.HasPrefix(req.TradeNo, "cs_") {
+		sessionID = req.TradeNo
+	}
+	if sessionID == "" {
+		return "", fmt.Errorf("order %s has no stripe payment reference", req.OrderNo)
+	}
+
+	var session StripeCheckoutSession
+	if err := c.doRequest("GET", "/v1/checkout/sessions/"+url.PathEscape(sessionID), nil, "", &session); err != nil {
+		return "", fmt.Errorf("failed to retrieve checkout session: %w", err)
+	}
+	if session.PaymentIntent == "" {
+		return "", fmt.Errorf("checkout session %s has no payment intent", sessionID)
+	}
+	return session.PaymentIntent, nil
+}
+
+// mapStripeRefundStatus 映射Stripe退款状态
+func mapStripeRefundStatus(status string) models.RefundStatus {
+	switch status {
+	case "succeeded":
+		return models.RefundStatusSuccess
+	case "failed", "canceled":
+		return models.RefundStatusFailed
+	default:
+		// pending, requires_action
+		return models.RefundStatusPending
+	}
+}
+
+// doRequest 调用Stripe REST API（表单编码请求，JSON应答）
+func (c *StripeClient) doRequest(method, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.SecretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &apiError{service: "stripe", status: resp.StatusCode, body: string(respBody)}
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// VerifyCallback Stripe回调为Webhook，签名位于Stripe-Signature头中，需使用ProcessWebhook验证
+func (c *StripeClient) VerifyCallback(params url.Values) (bool, error) {
+	return false, errors.New("stripe callbacks must be verified with ProcessWebhook")
+}
+
+// ProcessCallback Stripe回调需使用ProcessWebhook处理
+func (c *StripeClient) ProcessCallback(params url.Values) (*CallbackResult, error) {
+	return nil, errors.New("stripe callbacks must be processed with ProcessWebhook")
+}
+
 // ProcessWebhook 处理Stripe Webhook事件
 func (c *StripeClient) ProcessWebhook(payload []byte, signature string) (*CallbackResult, error) {
 	if c.WebhookSecret == "" {
-		// 如果没有配置Webhook Secret，跳过验证（仅用于测试）
+		if !c.Sandbox {
+			return nil, errors.New("stripe webhook secret is not configured")
+		}
+		// 沙箱中未配置Webhook Secret时跳过验证
 		return c.parseWebhookEvent(payload)
 	}
 
-	// TODO: 实现Stripe Webhook签名验证（需要配置STRIPE_WEBHOOK_SECRET）
-	// 参考：https://stripe.com/docs/webhooks/signatures
-	// 这里暂时跳过验证
+	if err := c.verifyWebhookSignature(payload, signature, time.Now()); err != nil {
+		return nil, fmt.Errorf("webhook signature verification failed: %w", err)
+	}
 	return c.parseWebhookEvent(payload)
 }
 
+// stripeSignatureTolerance Webhook时间戳允许的误差
+const stripeSignatureTolerance = 5 * time.Minute
+
+// verifyWebhookSignature 验证Stripe-Signature头：t为时间戳，v1为HMAC-SHA256("t.payload")
+// 参考：https://stripe.com/docs/webhooks/signatures
+func (c *StripeClient) verifyWebhookSignature(payload []byte, header string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return errors.New("invalid Stripe-Signature header")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid signature timestamp")
+	}
+	if diff := now.Sub(time.Unix(ts, 0)); diff > stripeSignatureTolerance || diff < -stripeSignatureTolerance {
+		return errors.New("signature timestamp outside tolerance")
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		sig, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return errors.New("no matching signature")
+}
+
 // parseWebhookEvent 解析Webhook事件
 func (c *StripeClient) parseWebhookEvent(payload []byte) (*CallbackResult, error) {
 	// 简化实现：解析JSON获取基本信息
@@ -91,6 +394,8 @@ func (c *StripeClient) parseWebhookEvent(payload []byte) (*CallbackResult, error
 	eventType, _ := eventData["type"].(string)
 	data, _ := eventData["data"].(map[string]interface{})
 	object, _ := data["object"].(map[string]interface{})
+	// 金额以币种最小单位上报（JSON数字），需按币种换算
+	currency, _ := object["currency"].(string)
 
 	switch eventType {
 	case "checkout.session.completed":
@@ -101,16 +406,35 @@ func (c *StripeClient) parseWebhookEvent(payload []byte) (*CallbackResult, error
 			orderNo = "unknown"
 		}
 
-		id, _ := object["id"].(string)
+		// 同一笔付款的payment_intent.succeeded事件和对账查询都以PaymentIntent ID为交易号，
+		// 会话没有PaymentIntent（如订阅模式）时才使用会话ID
+		id, _ := object["payment_intent"].(string)
+		if id == "" {
+			id, _ = object["id"].(string)
+		}
 		amountTotal, _ := object["amount_total"].(float64)
+		if paymentStatus, _ := object["payment_status"].(string); paymentStatus != "" && paymentStatus != "paid" {
+			// 异步支付方式（如银行转账）会话完成时尚未到账，等待后续事件
+			return &CallbackResult{
+				TradeNo:     id,
+				OutTradeNo:  orderNo,
+				TradeStatus: paymentStatus,
+				RawParams:   url.Values{"payment_status": {paymentStatus}},
+				PaymentType: c.GetPaymentType(),
+			}, nil
+		}
 
+		// 订阅模式的会话完成时携带Stripe订阅ID
+		subscriptionRef, _ := object["subscription"].(string)
 		return &CallbackResult{
-			TradeNo:     id,
-			OutTradeNo:  orderNo,
-			TradeStatus: "succeeded",
-			TotalAmount: fmt.Sprintf("%.2f", amountTotal/100),
-			RawParams:   url.Values{},
-			PaymentType: c.GetPaymentType(),
+			TradeNo:         id,
+			OutTradeNo:      orderNo,
+			TradeStatus:     "succeeded",
+			TotalAmount:     models.NewMoney(int64(amountTotal), currency).Decimal(),
+			Currency:        strings.ToUpper(currency),
+			RawParams:       url.Values{},
+			PaymentType:     c.GetPaymentType(),
+			SubscriptionRef: subscriptionRef,
 		}, nil
 
 	case "payment_intent.succeeded":
@@ -128,21 +452,90 @@ func (c *StripeClient) parseWebhookEvent(payload []byte) (*CallbackResult, error
 			TradeNo:     id,
 			OutTradeNo:  orderNo,
 			TradeStatus: "succeeded",
-			TotalAmount: fmt.Sprintf("%.2f", amount/100),
+			TotalAmount: models.NewMoney(int64(amount), currency).Decimal(),
+			Currency:    strings.ToUpper(currency),
 			RawParams:   url.Values{},
 			PaymentType: c.GetPaymentType(),
 		}, nil
 
+	case "refund.created", "refund.updated", "refund.failed":
+		// 退款对象：metadata中的refund_no为商户退款单号
+		metadata, _ := object["metadata"].(map[string]interface{})
+		refundNo, _ := metadata["refund_no"].(string)
+		if refundNo == "" {
+			return nil, fmt.Errorf("refund event without refund_no")
+		}
+
+		id, _ := object["id"].(string)
+		status, _ := object["status"].(string)
+		amount, _ := object["amount"].(float64)
+		orderNo, _ := metadata["order_no"].(string)
+
+		return &CallbackResult{
+			TradeNo:     id,
+			OutTradeNo:  orderNo,
+			TradeStatus: status,
+			RawParams:   url.Values{},
+			PaymentType: c.GetPaymentType(),
+			Refund: &RefundResult{
+				RefundNo:         refundNo,
+				ProviderRefundID: id,
+				Status:           mapStripeRefundStatus(status),
+				Amount:           models.NewMoney(int64(amount), currency).Decimal(),
+				RawParams:        url.Values{"event_type": {eventType}},
+				PaymentType:      c.GetPaymentType(),
+			},
+		}, nil
+
+	case "invoice.paid", "invoice.payment_failed":
+		// 订阅账单：首期账单已通过Checkout会话入账，这里只关联订阅ID
+		subscriptionRef, _ := object["subscription"].(string)
+		if subscriptionRef == "" {
+			return nil, fmt.Errorf("invoice event without subscription")
+		}
+		details, _ := object["subscription_details"].(map[string]interface{})
+		metadata, _ := details["metadata"].(map[string]interface{})
+		orderNo, _ := metadata["order_no"].(string)
+		billingReason, _ := object["billing_reason"].(string)
+
+		event := &SubscriptionEvent{
+			Kind:        SubscriptionEventRenewed,
+			Ref:         subscriptionRef,
+			OrderNo:     orderNo,
+			PaymentType: c.GetPaymentType(),
+		}
+		switch {
+		case eventType == "invoice.payment_failed":
+			event.Kind = SubscriptionEventPaymentFailed
+		case billingReason == "subscription_create":
+			event.Kind = SubscriptionEventActivated
+		default:
+			amountPaid, _ := object["amount_paid"].(float64)
+			event.TradeNo, _ = object["payment_intent"].(string)
+			if event.TradeNo == "" {
+				event.TradeNo, _ = object["id"].(string)
+			}
+			event.Amount = models.NewMoney(int64(amountPaid), currency).Decimal()
+			event.Currency = strings.ToUpper(currency)
+		}
+		return subscriptionCallback(event, eventType), nil
+
+	case "customer.subscription.deleted":
+		id, _ := object["id"].(string)
+		metadata, _ := object["metadata"].(map[string]interface{})
+		orderNo, _ := metadata["order_no"].(string)
+		return subscriptionCallback(&SubscriptionEvent{
+			Kind:        SubscriptionEventCancelled,
+			Ref:         id,
+			OrderNo:     orderNo,
+			PaymentType: c.GetPaymentType(),
+		}, eventType), nil
+
 	default:
 		return nil, fmt.Errorf("unhandled event type: %s", eventType)
 	}
 }
 
-// handleStripeEvent 处理Stripe事件
-func (c *StripeClient) handleStripeEvent(payload []byte) (*CallbackResult, error) {
-	return c.parseWebhookEvent(payload)
-}
-
 // GetPaymentType 获取支付类型
 func (c *StripeClient) GetPaymentType() PaymentType {
 	return PaymentTypeStripe
@@ -151,8 +544,8 @@ func (c *StripeClient) GetPaymentType() PaymentType {
 // createMockPayment 创建模拟支付
 func (c *StripeClient) createMockPayment(order *models.Order, subject string) (string, error) {
 	paymentID := uuid.New().String()
-	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%.2f&provider=stripe",
-		paymentID, order.OrderNo, order.TotalAmount), nil
+	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%s&provider=stripe",
+		paymentID, order.OrderNo, order.Total.Decimal()), nil
 }
 
 // MockStripeClient 模拟Stripe客户端（用于开发和测试）
@@ -166,8 +559,8 @@ func NewMockStripeClient() *MockStripeClient {
 // CreatePayment 创建模拟支付
 func (c *MockStripeClient) CreatePayment(order *models.Order, subject string) (string, error) {
 	paymentID := uuid.New().String()
-	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%.2f&provider=stripe",
-		paymentID, order.OrderNo, order.TotalAmount), nil
+	return fmt.Sprintf("http://localhost:3000/mock-pay?id=%s&order_no=%s&amount=%s&provider=stripe",
+		paymentID, order.OrderNo, order.Total.Decimal()), nil
 }
 
 // VerifyCallback 验证模拟回调
@@ -187,6 +580,16 @@ func (c *MockStripeClient) ProcessCallback(params url.Values) (*CallbackResult,
 	}, nil
 }
 
+// QueryPayment 模拟支付不支持查询
+func (c *MockStripeClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	return nil, ErrQueryNotSupported
+}
+
+// Refund 模拟退款，直接返回成功
+func (c *MockStripeClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	return newMockRefundResult(req, c.GetPaymentType()), nil
+}
+
 // GetPaymentType 获取支付类型
 func (c *MockStripeClient) GetPaymentType() PaymentType {
 	return PaymentTypeMock
@@ -0,0 +1,154 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func TestStripeCheckoutSessionAndQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk_test_123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/checkout/sessions":
+			r.ParseForm()
+			if r.PostForm.Get("metadata[order_no]") != "ORDST001" {
+				t.Errorf("unexpected metadata order_no: %s", r.PostForm.Get("metadata[order_no]"))
+			}
+			if r.PostForm.Get("line_items[0][price_data][unit_amount]") != "1999" {
+				t.Errorf("unexpected unit_amount: %s", r.PostForm.Get("line_items[0][price_data][unit_amount]"))
+			}
+			w.Write([]byte(`{"id":"cs_test_a1","url":"https://checkout.stripe.com/c/pay/cs_test_a1","status":"open","payment_status":"unpaid"}`))
+		case r.Method == "GET" && r.URL.Path == "/v1/checkout/sessions/cs_test_a1":
+			w.Write([]byte(`{"id":"cs_test_a1","status":"complete","payment_status":"paid","amount_total":1999,"currency":"usd","payment_intent":"pi_1","metadata":{"order_no":"ORDST001"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, _ := NewStripeClient(config.StripeConfig{SecretKey: "sk_test_123", BaseURL: server.URL})
+
+	order := &models.Order{ID: uuid.New(), OrderNo: "ORDST001", Total: models.NewMoney(1999, "USD")}
+	checkoutURL, err := client.CreatePayment(order, "测试技能")
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if checkoutURL != "https://checkout.stripe.com/c/pay/cs_test_a1" || order.PaymentRef != "cs_test_a1" {
+		t.Errorf("unexpected checkout url %s or payment ref %s", checkoutURL, order.PaymentRef)
+	}
+
+	result, err := client.QueryPayment(order)
+	if err != nil {
+		t.Fatalf("QueryPayment failed: %v", err)
+	}
+	if !result.IsPaid() || result.OutTradeNo != "ORDST001" || result.TotalAmount != "19.99" || result.TradeNo != "pi_1" {
+		t.Errorf("unexpected query result: %+v", result)
+	}
+}
+
+func TestStripeWebhookSignature(t *testing.T) {
+	client, _ := NewStripeClient(config.StripeConfig{SecretKey: "sk_test_123", WebhookSecret: "whsec_test"})
+
+	payload := []byte(`{"id":"evt_1","type":"refund.updated","data":{"object":{"id":"re_1","status":"succeeded","amount":500,"metadata":{"refund_no":"RF001","order_no":"ORDST001"}}}}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	header := "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	result, err := client.ProcessWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("ProcessWebhook failed: %v", err)
+	}
+	if result.Refund == nil || result.Refund.RefundNo != "RF001" || result.Refund.Status != models.RefundStatusSuccess || result.Refund.Amount != "5.00" {
+		t.Errorf("unexpected refund result: %+v", result.Refund)
+	}
+
+	// 篡改报文
+	if _, err := client.ProcessWebhook(append(payload, ' '), header); err == nil {
+		t.Error("expected verification failure for tampered payload")
+	}
+
+	// 过期时间戳
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	mac = hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(old + "."))
+	mac.Write(payload)
+	if _, err := client.ProcessWebhook(payload, "t="+old+",v1="+hex.EncodeToString(mac.Sum(nil))); err == nil {
+		t.Error("expected verification failure for stale timestamp")
+	}
+
+	// 同一笔付款的会话完成和PaymentIntent成功事件使用同一交易号
+	completed, err := client.parseWebhookEvent([]byte(`{"type":"checkout.session.completed","data":{"object":{"id":"cs_1","payment_intent":"pi_9","payment_status":"paid","amount_total":500,"currency":"usd","metadata":{"order_no":"ORDST001"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	succeeded, err := client.parseWebhookEvent([]byte(`{"type":"payment_intent.succeeded","data":{"object":{"id":"pi_9","amount":500,"currency":"usd","metadata":{"order_no":"ORDST001"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.TradeNo != "pi_9" || succeeded.TradeNo != completed.TradeNo {
+		t.Errorf("trade no mismatch: checkout %q, payment intent %q", completed.TradeNo, succeeded.TradeNo)
+	}
+
+	// 未配置Webhook签名密钥时只有沙箱接受未签名事件
+	unsigned, _ := NewStripeClient(config.StripeConfig{SecretKey: "sk_test_123"})
+	if _, err := unsigned.ProcessWebhook(payload, ""); err == nil {
+		t.Error("expected unsigned webhook to be rejected outside sandbox")
+	}
+	unsigned.Sandbox = true
+	if _, err := unsigned.ProcessWebhook(payload, ""); err != nil {
+		t.Errorf("sandbox unsigned webhook failed: %v", err)
+	}
+
+	// 表单回调没有签名，不能确认付款
+	params := url.Values{"order_no": {"ORDST001"}, "amount": {"19.99"}}
+	if ok, err := client.VerifyCallback(params); ok || err == nil {
+		t.Error("expected VerifyCallback to refuse unsigned stripe callbacks")
+	}
+	if result, err := client.ProcessCallback(params); result != nil || err == nil {
+		t.Errorf("expected ProcessCallback to refuse unsigned stripe callbacks, got %+v", result)
+	}
+}
+
+func TestStripeRefundErrors(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Idempotency-Key") != "RF001" {
+			t.Errorf("unexpected idempotency key %q", r.Header.Get("Idempotency-Key"))
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":{"message":"charge already refunded"}}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewStripeClient(config.StripeConfig{SecretKey: "sk_test_123", BaseURL: server.URL})
+	req := &RefundRequest{OrderNo: "ORDST001", TradeNo: "pi_1", RefundNo: "RF001", Amount: models.NewMoney(500, "USD"), TotalAmount: models.NewMoney(1999, "USD")}
+
+	// 网关明确拒绝：退款没有发生
+	if _, err := client.Refund(req); !errors.Is(err, ErrRefundRejected) {
+		t.Errorf("400 error = %v, want ErrRefundRejected", err)
+	}
+	// 5xx、限流和幂等冲突结果未知，不能标记为失败
+	for _, status = range []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusConflict} {
+		if _, err := client.Refund(req); err == nil || errors.Is(err, ErrRefundRejected) {
+			t.Errorf("status %d error = %v, want an unknown outcome", status, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"net/url"
+
+	"skillhub/models"
+)
+
+// 网关订阅通知类型
+const (
+	SubscriptionEventActivated     = "activated"      // 买家已完成订阅授权，用于关联网关订阅ID
+	SubscriptionEventRenewed       = "renewed"        // 网关已扣款（首期或续费）
+	SubscriptionEventPaymentFailed = "payment_failed" // 续费扣款失败，网关会按其策略重试
+	SubscriptionEventCancelled     = "cancelled"      // 网关侧订阅已取消或终止，不再续费
+)
+
+// SubscriptionProvider 支持由网关自动续费的支付服务（Stripe、PayPal）
+type SubscriptionProvider interface {
+	// CreateSubscription 为订阅的首期订单创建网关订阅，返回买家授权页面链接
+	CreateSubscription(order *models.Order, plan *models.SubscriptionPlan, subject string) (string, error)
+	// CancelSubscription 取消网关订阅，当期结束后不再扣款
+	CancelSubscription(ref string) error
+}
+
+// SubscriptionEvent 网关订阅通知
+type SubscriptionEvent struct {
+	Kind        string      `json:"kind"`
+	Ref         string      `json:"ref"`      // 网关侧订阅ID
+	OrderNo     string      `json:"order_no"` // 首期订单号（创建订阅时写入网关的自定义字段），可能为空
+	TradeNo     string      `json:"trade_no"` // 扣款的网关交易号，仅renewed
+	Amount      string      `json:"amount"`
+	Currency    string      `json:"currency"`
+	PaymentType PaymentType `json:"payment_type"`
+}
+
+// AsCallbackResult 将扣款通知转换为订单支付结果
+func (e *SubscriptionEvent) AsCallbackResult(orderNo string) *CallbackResult {
+	return &CallbackResult{
+		TradeNo:         e.TradeNo,
+		OutTradeNo:      orderNo,
+		TradeStatus:     "succeeded",
+		TotalAmount:     e.Amount,
+		Currency:        e.Currency,
+		RawParams:       url.Values{"subscription": {e.Ref}},
+		PaymentType:     e.PaymentType,
+		SubscriptionRef: e.Ref,
+	}
+}
+
+// subscriptionCallback 包装订阅通知
+func subscriptionCallback(event *SubscriptionEvent, eventType string) *CallbackResult {
+	return &CallbackResult{
+		TradeNo:      event.TradeNo,
+		OutTradeNo:   event.OrderNo,
+		TradeStatus:  event.Kind,
+		RawParams:    url.Values{"event_type": {eventType}},
+		PaymentType:  event.PaymentType,
+		Subscription: event,
+	}
+}
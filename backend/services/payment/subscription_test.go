@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func TestStripeSubscriptionEvents(t *testing.T) {
+	client, _ := NewStripeClient(config.StripeConfig{SecretKey: "sk_test_123"})
+
+	tests := []struct {
+		name    string
+		payload string
+		kind    string
+		tradeNo string
+		amount  string
+	}{
+		{"renewal", `{"type":"invoice.paid","data":{"object":{"id":"in_2","subscription":"sub_1","billing_reason":"subscription_cycle","amount_paid":999,"currency":"usd","payment_intent":"pi_2","subscription_details":{"metadata":{"order_no":"ORDSUB1"}}}}}`,
+			SubscriptionEventRenewed, "pi_2", "9.99"},
+		{"first invoice", `{"type":"invoice.paid","data":{"object":{"id":"in_1","subscription":"sub_1","billing_reason":"subscription_create","amount_paid":999,"currency":"usd"}}}`,
+			SubscriptionEventActivated, "", ""},
+		{"payment failed", `{"type":"invoice.payment_failed","data":{"object":{"id":"in_3","subscription":"sub_1","billing_reason":"subscription_cycle","currency":"usd"}}}`,
+			SubscriptionEventPaymentFailed, "", ""},
+		{"deleted", `{"type":"customer.subscription.deleted","data":{"object":{"id":"sub_1","metadata":{"order_no":"ORDSUB1"}}}}`,
+			SubscriptionEventCancelled, "", ""},
+	}
+
+	for _, tt := range tests {
+		result, err := client.parseWebhookEvent([]byte(tt.payload))
+		if err != nil {
+			t.Fatalf("%s: parseWebhookEvent failed: %v", tt.name, err)
+		}
+		event := result.Subscription
+		if event == nil || event.Kind != tt.kind || event.Ref != "sub_1" || event.TradeNo != tt.tradeNo || event.Amount != tt.amount {
+			t.Errorf("%s: unexpected subscription event %+v", tt.name, event)
+		}
+	}
+
+	// 订阅模式的Checkout会话完成时支付首期订单并带出订阅ID
+	result, err := client.parseWebhookEvent([]byte(`{"type":"checkout.session.completed","data":{"object":{"id":"cs_1","mode":"subscription","subscription":"sub_1","payment_status":"paid","amount_total":999,"currency":"usd","metadata":{"order_no":"ORDSUB1"}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsPaid() || result.Subscription != nil || result.SubscriptionRef != "sub_1" || result.OutTradeNo != "ORDSUB1" {
+		t.Errorf("unexpected checkout result: %+v", result)
+	}
+}
+
+func TestPayPalCreateSubscription(t *testing.T) {
+	var tokenRequests int32
+	client := newTestPayPalClient(t, &tokenRequests, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]interface{}
+		json.Unmarshal(body, &req)
+
+		switch r.URL.Path {
+		case "/v1/catalogs/products":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"PROD-1"}`))
+		case "/v1/billing/plans":
+			if req["product_id"] != "PROD-1" {
+				t.Errorf("unexpected product id: %v", req["product_id"])
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"P-1"}`))
+		case "/v1/billing/subscriptions":
+			if req["plan_id"] != "P-1" || req["custom_id"] != "ORDSUB1" {
+				t.Errorf("unexpected subscription request: %v", req)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"I-SUB1","status":"APPROVAL_PENDING","links":[{"href":"https://www.sandbox.paypal.com/webapps/billing/subscriptions?ba_token=BA-1","rel":"approve","method":"GET"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	plan := &models.SubscriptionPlan{ID: uuid.New(), Interval: models.BillingIntervalMonth, Price: models.NewMoney(999, "USD")}
+	order := &models.Order{ID: uuid.New(), OrderNo: "ORDSUB1", Total: plan.Price}
+	approveURL, err := client.CreateSubscription(order, plan, "测试订阅")
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if approveURL == "" || plan.PayPalPlanID != "P-1" || order.PaymentRef != "I-SUB1" {
+		t.Errorf("unexpected approve url %s, plan %s or payment ref %s", approveURL, plan.PayPalPlanID, order.PaymentRef)
+	}
+	// 订阅ID无法通过订单接口查询，对账时跳过
+	if _, err := client.QueryPayment(order); !errors.Is(err, ErrQueryNotSupported) {
+		t.Errorf("QueryPayment on subscription order = %v, want ErrQueryNotSupported", err)
+	}
+
+	// 后续扣款通知：billing_agreement_id为订阅ID
+	result, err := client.handlePayPalEvent(&PayPalWebhookEvent{
+		ID:        "WH-2",
+		EventType: "PAYMENT.SALE.COMPLETED",
+		Resource: map[string]interface{}{
+			"id":                   "SALE-2",
+			"billing_agreement_id": "I-SUB1",
+			"custom":               "ORDSUB1",
+			"amount":               map[string]interface{}{"total": "9.99", "currency": "USD"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := result.Subscription
+	if event == nil || event.Kind != SubscriptionEventRenewed || event.Ref != "I-SUB1" || event.TradeNo != "SALE-2" || event.OrderNo != "ORDSUB1" {
+		t.Errorf("unexpected sale event: %+v", event)
+	}
+	if paid := event.AsCallbackResult("ORDSUB2"); !paid.IsPaid() || paid.OutTradeNo != "ORDSUB2" || paid.TotalAmount != "9.99" {
+		t.Errorf("unexpected callback result: %+v", paid)
+	}
+}
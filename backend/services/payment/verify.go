@@ -0,0 +1,62 @@
+package payment
+
+import (
+	"fmt"
+	"strings"
+
+	"skillhub/config"
+	"skillhub/models"
+)
+
+// ExpectedMerchantID 回调中应出现的商户号/应用ID，为空表示该渠道不上报或未配置
+func ExpectedMerchantID(paymentType PaymentType, cfg config.Config) string {
+	switch paymentType {
+	case PaymentTypeAlipay:
+		return cfg.Payment.Alipay.AppID
+	case PaymentTypeWeChat:
+		return cfg.Payment.WeChatPay.MchID
+	case PaymentTypePayPal:
+		return cfg.Payment.PayPal.MerchantID
+	}
+	// Stripe的Webhook由端点密钥签名、查询使用商户密钥，无需再比对账户
+	return ""
+}
+
+// reportsMerchantID 该渠道的回调和查询结果是否总会带上商户号/应用ID（支付宝app_id、微信支付mchid），
+// 缺失即视为不一致；PayPal的收款商户可能不在报文中，缺失时不比对
+func reportsMerchantID(paymentType PaymentType) bool {
+	return paymentType == PaymentTypeAlipay || paymentType == PaymentTypeWeChat
+}
+
+// VerifyResult 将支付结果的金额、币种和商户与订单比对，返回不一致项（为空表示一致）
+func VerifyResult(order *models.Order, result *CallbackResult, cfg config.Config) []string {
+	var problems []string
+
+	currency := OrderCurrency(order, result.PaymentType)
+	if result.Currency != "" && currency != "" && !strings.EqualFold(result.Currency, currency) {
+		problems = append(problems, fmt.Sprintf("currency %s differs from expected %s", result.Currency, currency))
+	}
+	if currency == "" {
+		currency = result.Currency
+	}
+
+	if result.TotalAmount == "" {
+		problems = append(problems, "payment result has no amount")
+	} else if paid, err := models.ParseMoney(result.TotalAmount, currency); err != nil {
+		problems = append(problems, err.Error())
+	} else if expected := models.NewMoney(order.Total.Amount, currency); paid.Amount != expected.Amount {
+		problems = append(problems, fmt.Sprintf("paid amount %s differs from order amount %s",
+			result.TotalAmount, expected.Decimal()))
+	}
+
+	if expected := ExpectedMerchantID(result.PaymentType, cfg); expected != "" {
+		switch {
+		case result.MerchantID == "" && reportsMerchantID(result.PaymentType):
+			problems = append(problems, fmt.Sprintf("payment result has no merchant, expected %s", expected))
+		case result.MerchantID != "" && result.MerchantID != expected:
+			problems = append(problems, fmt.Sprintf("merchant %s differs from configured %s", result.MerchantID, expected))
+		}
+	}
+
+	return problems
+}
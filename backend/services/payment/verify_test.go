@@ -0,0 +1,38 @@
+package payment
+
+import (
+	"testing"
+
+	"skillhub/config"
+	"skillhub/models"
+)
+
+func TestVerifyResult(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Payment.Alipay.AppID = "2021000000000001"
+	cfg.Payment.WeChatPay.MchID = "1900000001"
+	cfg.Payment.PayPal.MerchantID = "SIMMERCHANT01"
+	order := &models.Order{OrderNo: "ORD001", Total: models.Money{Amount: 1999}}
+
+	tests := []struct {
+		name     string
+		result   CallbackResult
+		problems int
+	}{
+		{"match", CallbackResult{PaymentType: PaymentTypeAlipay, TotalAmount: "19.99", Currency: "CNY", MerchantID: "2021000000000001"}, 0},
+		{"alipay app_id missing", CallbackResult{PaymentType: PaymentTypeAlipay, TotalAmount: "19.99"}, 1},
+		{"wechat mchid missing", CallbackResult{PaymentType: PaymentTypeWeChat, TotalAmount: "19.99"}, 1},
+		{"paypal merchant not reported", CallbackResult{PaymentType: PaymentTypePayPal, TotalAmount: "19.99"}, 0},
+		{"amount", CallbackResult{PaymentType: PaymentTypeAlipay, TotalAmount: "0.01", Currency: "CNY", MerchantID: "2021000000000001"}, 1},
+		{"missing amount", CallbackResult{PaymentType: PaymentTypeStripe, Currency: "USD"}, 1},
+		{"currency", CallbackResult{PaymentType: PaymentTypeStripe, TotalAmount: "19.99", Currency: "EUR"}, 1},
+		{"merchant", CallbackResult{PaymentType: PaymentTypeAlipay, TotalAmount: "19.99", MerchantID: "2021000000000999"}, 1},
+		{"all", CallbackResult{PaymentType: PaymentTypeAlipay, TotalAmount: "1", Currency: "USD", MerchantID: "other"}, 3},
+	}
+
+	for _, tt := range tests {
+		if got := VerifyResult(order, &tt.result, cfg); len(got) != tt.problems {
+			t.Errorf("%s: expected %d problems, got %v", tt.name, tt.problems, got)
+		}
+	}
+}
@@ -1,9 +1,28 @@
 package payment
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"skillhub/config"
 	"skillhub/models"
@@ -11,14 +30,92 @@ import (
 	"github.com/google/uuid"
 )
 
-// WeChatPayClient 微信支付客户端
+const (
+	// wechatAuthSchema 微信支付APIv3签名认证类型
+	wechatAuthSchema = "WECHATPAY2-SHA256-RSA2048"
+	// wechatCertRefreshInterval 平台证书缓存刷新间隔
+	wechatCertRefreshInterval = 12 * time.Hour
+	// wechatCertMinRefreshInterval 两次下载平台证书的最小间隔，避免伪造序列号的请求反复触发下载
+	wechatCertMinRefreshInterval = time.Minute
+	// wechatUnknownSerialTTL 下载后仍未找到的序列号的缓存时长，期间直接拒绝
+	wechatUnknownSerialTTL = 10 * time.Minute
+	// wechatTimestampSkew 回调时间戳允许的最大偏差
+	wechatTimestampSkew = 5 * time.Minute
+)
+
+// WeChatPayClient 微信支付客户端（APIv3）
 type WeChatPayClient struct {
-	MchID     string
-	APIKey    string
-	SerialNo  string
-	CertPath  string
-	NotifyURL string
-	ReturnURL string
+	AppID      string
+	MchID      string
+	APIKey     string // APIv3密钥
+	SerialNo   string // 商户API证书序列号
+	PrivateKey *rsa.PrivateKey
+	NotifyURL  string
+	ReturnURL  string
+	BaseURL    string
+
+	httpClient *http.Client
+
+	certMu         sync.RWMutex
+	platformCerts  map[string]*x509.Certificate
+	certsFetched   time.Time
+	unknownSerials map[string]time.Time // 未知序列号 -> 缓存到期时间
+
+	refreshMu        sync.Mutex // 串行化证书下载
+	refreshAttempted time.Time  // 最近一次下载尝试的时间（无论成败）
+	refreshErr       error      // 最近一次下载的结果
+}
+
+// WeChatNotification 微信支付回调通知
+type WeChatNotification struct {
+	ID           string                  `json:"id"`
+	CreateTime   string                  `json:"create_time"`
+	EventType    string                  `json:"event_type"`
+	ResourceType string                  `json:"resource_type"`
+	Summary      string                  `json:"summary"`
+	Resource     WeChatEncryptedResource `json:"resource"`
+}
+
+// WeChatEncryptedResource 微信支付加密资源
+type WeChatEncryptedResource struct {
+	Algorithm      string `json:"algorithm"`
+	Ciphertext     string `json:"ciphertext"`
+	AssociatedData string `json:"associated_data"`
+	OriginalType   string `json:"original_type"`
+	Nonce          string `json:"nonce"`
+}
+
+// WeChatTransaction 微信支付交易信息（回调解密后的资源）
+type WeChatTransaction struct {
+	AppID          string `json:"appid"`
+	MchID          string `json:"mchid"`
+	OutTradeNo     string `json:"out_trade_no"`
+	TransactionID  string `json:"transaction_id"`
+	TradeType      string `json:"trade_type"`
+	TradeState     string `json:"trade_state"`
+	TradeStateDesc string `json:"trade_state_desc"`
+	SuccessTime    string `json:"success_time"`
+	Amount         struct {
+		Total         int64  `json:"total"`
+		PayerTotal    int64  `json:"payer_total"`
+		Currency      string `json:"currency"`
+		PayerCurrency string `json:"payer_currency"`
+	} `json:"amount"`
+}
+
+// WeChatRefund 微信支付退款信息（退款应答及退款通知解密后的资源）
+type WeChatRefund struct {
+	MchID         string `json:"mchid"`
+	OutTradeNo    string `json:"out_trade_no"`
+	TransactionID string `json:"transaction_id"`
+	OutRefundNo   string `json:"out_refund_no"`
+	RefundID      string `json:"refund_id"`
+	Status        string `json:"status"`        // 退款应答中的状态
+	RefundStatus  string `json:"refund_status"` // 退款通知中的状态
+	Amount        struct {
+		Total  int64 `json:"total"`
+		Refund int64 `json:"refund"`
+	} `json:"amount"`
 }
 
 // NewWeChatPayClient 创建微信支付客户端
@@ -26,93 +123,339 @@ func NewWeChatPayClient(cfg config.WeChatPayConfig) (*WeChatPayClient, error) {
 	if cfg.MchID == "" || cfg.APIKey == "" {
 		return nil, fmt.Errorf("wechat pay config is incomplete")
 	}
+	if cfg.AppID == "" || cfg.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("wechat pay app id and merchant private key are required")
+	}
+	if len(cfg.APIKey) != 32 {
+		return nil, fmt.Errorf("wechat pay apiv3 key must be 32 bytes")
+	}
+
+	keyPEM, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merchant private key: %w", err)
+	}
+	privateKey, err := parsePrivateKey(string(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse merchant private key: %w", err)
+	}
+
+	// 未配置序列号时，从商户API证书中读取
+	serialNo := cfg.SerialNo
+	if serialNo == "" && cfg.CertPath != "" {
+		certPEM, err := os.ReadFile(cfg.CertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read merchant certificate: %w", err)
+		}
+		cert, err := parseCertificate(certPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse merchant certificate: %w", err)
+		}
+		serialNo = certSerialNo(cert)
+	}
+	if serialNo == "" {
+		return nil, fmt.Errorf("wechat pay merchant serial number is required")
+	}
 
 	returnURL := cfg.ReturnURL
 	if returnURL == "" {
 		returnURL = "http://localhost:3000/orders" // 默认返回URL
 	}
 
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = "https://api.mch.weixin.qq.com"
+	}
+
 	return &WeChatPayClient{
-		MchID:     cfg.MchID,
-		APIKey:    cfg.APIKey,
-		SerialNo:  cfg.SerialNo,
-		CertPath:  cfg.CertPath,
-		NotifyURL: cfg.NotifyURL,
-		ReturnURL: returnURL,
+		AppID:          cfg.AppID,
+		MchID:          cfg.MchID,
+		APIKey:         cfg.APIKey,
+		SerialNo:       serialNo,
+		PrivateKey:     privateKey,
+		NotifyURL:      cfg.NotifyURL,
+		ReturnURL:      returnURL,
+		BaseURL:        baseURL,
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		platformCerts:  make(map[string]*x509.Certificate),
+		unknownSerials: make(map[string]time.Time),
 	}, nil
 }
 
-// CreatePayment 创建微信支付
+// CreatePayment 创建微信支付（Native扫码支付，返回code_url用于生成二维码）
 func (c *WeChatPayClient) CreatePayment(order *models.Order, subject string) (string, error) {
-	// 如果配置不完整，返回模拟支付URL
-	if c.MchID == "" || c.APIKey == "" || c.SerialNo == "" {
-		return c.createMockPayment(order, subject)
+	if _, err := chargeAmount(c.GetPaymentType(), order); err != nil {
+		return "", err
+	}
+	body := c.buildTransactionRequest(order, subject)
+
+	var resp struct {
+		CodeURL string `json:"code_url"`
+	}
+	if err := c.doRequest("POST", "/v3/pay/transactions/native", body, &resp); err != nil {
+		return "", fmt.Errorf("failed to create native payment: %w", err)
+	}
+	if resp.CodeURL == "" {
+		return "", errors.New("code_url not found in response")
 	}
 
-	// TODO: 实现真实的微信支付API调用（需要配置MchID、APIKey、SerialNo）
-	// 参考微信支付API文档：https://pay.weixin.qq.com/wiki/doc/apiv3/index.shtml
-	// 这里暂时使用模拟支付
-	return c.createMockPayment(order, subject)
+	return resp.CodeURL, nil
 }
 
-// VerifyCallback 验证微信支付回调签名
-func (c *WeChatPayClient) VerifyCallback(params url.Values) (bool, error) {
-	// 如果配置不完整，模拟验证总是成功
-	if c.MchID == "" || c.APIKey == "" {
-		return true, nil
+// CreateH5Payment 创建微信H5支付，返回在手机浏览器中跳转的h5_url
+func (c *WeChatPayClient) CreateH5Payment(order *models.Order, subject, clientIP string) (string, error) {
+	if clientIP == "" {
+		return "", errors.New("client ip is required for h5 payment")
+	}
+	if _, err := chargeAmount(c.GetPaymentType(), order); err != nil {
+		return "", err
 	}
 
-	// TODO: 实现微信支付回调签名验证（需要配置完整的商户证书）
-	// 微信支付使用APIv3的签名验证，需要验证HTTP头中的签名和时间戳
-	// 参考：https://pay.weixin.qq.com/wiki/doc/apiv3/wechatpay/wechatpay4_1.shtml
-	return true, nil
+	body := c.buildTransactionRequest(order, subject)
+	body["scene_info"] = map[string]interface{}{
+		"payer_client_ip": clientIP,
+		"h5_info": map[string]interface{}{
+			"type": "Wap",
+		},
+	}
+
+	var resp struct {
+		H5URL string `json:"h5_url"`
+	}
+	if err := c.doRequest("POST", "/v3/pay/transactions/h5", body, &resp); err != nil {
+		return "", fmt.Errorf("failed to create h5 payment: %w", err)
+	}
+	if resp.H5URL == "" {
+		return "", errors.New("h5_url not found in response")
+	}
+
+	// 支付完成后跳回订单页
+	if c.ReturnURL != "" {
+		return resp.H5URL + "&redirect_url=" + url.QueryEscape(c.ReturnURL), nil
+	}
+	return resp.H5URL, nil
 }
 
-// ProcessCallback 处理微信支付回调
+// buildTransactionRequest 构建下单请求体
+func (c *WeChatPayClient) buildTransactionRequest(order *models.Order, subject string) map[string]interface{} {
+	return map[string]interface{}{
+		"appid":        c.AppID,
+		"mchid":        c.MchID,
+		"description":  truncateRunes(subject, 127),
+		"out_trade_no": order.OrderNo,
+		"notify_url":   c.NotifyURL,
+		"amount": map[string]interface{}{
+			"total":    order.Total.Amount,
+			"currency": "CNY",
+		},
+	}
+}
+
+// VerifyCallback 微信支付APIv3回调为JSON报文，签名位于HTTP头中，需使用VerifyNotification验证
+func (c *WeChatPayClient) VerifyCallback(params url.Values) (bool, error) {
+	return false, errors.New("wechat pay callbacks must be verified with VerifyNotification")
+}
+
+// ProcessCallback 微信支付APIv3回调需使用ProcessNotification处理
 func (c *WeChatPayClient) ProcessCallback(params url.Values) (*CallbackResult, error) {
-	// 验证签名
-	valid, err := c.VerifyCallback(params)
-	if !valid || err != nil {
+	return nil, errors.New("wechat pay callbacks must be processed with ProcessNotification")
+}
+
+// VerifyNotification 验证微信支付回调签名
+func (c *WeChatPayClient) VerifyNotification(header http.Header, body []byte) error {
+	timestamp := header.Get("Wechatpay-Timestamp")
+	nonce := header.Get("Wechatpay-Nonce")
+	signature := header.Get("Wechatpay-Signature")
+	serial := header.Get("Wechatpay-Serial")
+
+	if timestamp == "" || nonce == "" || signature == "" || serial == "" {
+		return errors.New("missing wechatpay signature headers")
+	}
+
+	// 拒绝过期的通知，防止重放
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid wechatpay timestamp: %w", err)
+	}
+	if skew := time.Since(time.Unix(ts, 0)); math.Abs(float64(skew)) > float64(wechatTimestampSkew) {
+		return errors.New("wechatpay timestamp expired")
+	}
+
+	cert, err := c.getPlatformCert(serial)
+	if err != nil {
+		return err
+	}
+
+	message := timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	return verifySHA256WithRSA(cert, message, signature)
+}
+
+// ProcessNotification 验证并解密微信支付回调通知
+func (c *WeChatPayClient) ProcessNotification(header http.Header, body []byte) (*CallbackResult, error) {
+	if err := c.VerifyNotification(header, body); err != nil {
 		return nil, fmt.Errorf("callback verification failed: %w", err)
 	}
 
-	// 解析回调参数
-	// 微信支付回调格式与支付宝不同，需要适配
-	tradeNo := params.Get("transaction_id")
-	outTradeNo := params.Get("out_trade_no")
-	tradeStatus := params.Get("trade_state")
-	totalAmount := params.Get("amount.total")
+	var notification WeChatNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("failed to parse notification: %w", err)
+	}
 
-	// 如果参数为空，尝试从模拟参数获取
-	if tradeNo == "" {
-		tradeNo = params.Get("trade_no")
+	plaintext, err := c.decryptResource(notification.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt notification resource: %w", err)
+	}
+
+	// 退款结果通知与支付通知使用同一通知地址，按事件类型区分
+	if strings.HasPrefix(notification.EventType, "REFUND.") {
+		return c.processRefundNotification(&notification, plaintext)
+	}
+
+	var transaction WeChatTransaction
+	if err := json.Unmarshal(plaintext, &transaction); err != nil {
+		return nil, fmt.Errorf("failed to parse transaction: %w", err)
+	}
+
+	if transaction.MchID != c.MchID || transaction.AppID != c.AppID {
+		return nil, errors.New("notification does not belong to this merchant")
 	}
-	if outTradeNo == "" {
-		outTradeNo = params.Get("out_trade_no")
+	if transaction.OutTradeNo == "" || transaction.TradeState == "" {
+		return nil, errors.New("missing required callback parameters")
 	}
-	if tradeStatus == "" {
-		tradeStatus = params.Get("trade_status")
-		if tradeStatus == "" {
-			tradeStatus = "SUCCESS" // 默认成功
+
+	params := url.Values{}
+	params.Set("notification_id", notification.ID)
+	params.Set("event_type", notification.EventType)
+	params.Set("transaction_id", transaction.TransactionID)
+	params.Set("out_trade_no", transaction.OutTradeNo)
+	params.Set("trade_type", transaction.TradeType)
+	params.Set("trade_state", transaction.TradeState)
+	params.Set("success_time", transaction.SuccessTime)
+	params.Set("amount.total", strconv.FormatInt(transaction.Amount.Total, 10))
+	params.Set("amount.currency", transaction.Amount.Currency)
+
+	return &CallbackResult{
+		TradeNo:     transaction.TransactionID,
+		OutTradeNo:  transaction.OutTradeNo,
+		TradeStatus: c.mapWeChatStatusToUnified(transaction.TradeState),
+		TotalAmount: fenToYuan(transaction.Amount.Total),
+		Currency:    transaction.Amount.Currency,
+		MerchantID:  transaction.MchID,
+		RawParams:   params,
+		PaymentType: c.GetPaymentType(),
+	}, nil
+}
+
+// QueryPayment 按商户订单号查询微信支付订单
+func (c *WeChatPayClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(order.OrderNo) + "?mchid=" + url.QueryEscape(c.MchID)
+
+	var transaction WeChatTransaction
+	if err := c.doRequest("GET", path, nil, &transaction); err != nil {
+		// 用户未扫码下单前查询会返回ORDER_NOT_EXIST
+		if strings.Contains(err.Error(), "ORDER_NOT_EXIST") {
+			return &CallbackResult{
+				OutTradeNo:  order.OrderNo,
+				TradeStatus: "WAIT_BUYER_PAY",
+				RawParams:   url.Values{"trade_state": {"NOTPAY"}},
+				PaymentType: c.GetPaymentType(),
+			}, nil
 		}
+		return nil, fmt.Errorf("failed to query transaction: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("transaction_id", transaction.TransactionID)
+	params.Set("trade_state", transaction.TradeState)
+	params.Set("amount.total", strconv.FormatInt(transaction.Amount.Total, 10))
+
+	return &CallbackResult{
+		TradeNo:     transaction.TransactionID,
+		OutTradeNo:  transaction.OutTradeNo,
+		TradeStatus: c.mapWeChatStatusToUnified(transaction.TradeState),
+		TotalAmount: fenToYuan(transaction.Amount.Total),
+		Currency:    transaction.Amount.Currency,
+		MerchantID:  transaction.MchID,
+		RawParams:   params,
+		PaymentType: c.GetPaymentType(),
+	}, nil
+}
+
+// processRefundNotification 处理退款结果通知
+func (c *WeChatPayClient) processRefundNotification(notification *WeChatNotification, plaintext []byte) (*CallbackResult, error) {
+	var refund WeChatRefund
+	if err := json.Unmarshal(plaintext, &refund); err != nil {
+		return nil, fmt.Errorf("failed to parse refund: %w", err)
+	}
+	if refund.MchID != c.MchID {
+		return nil, errors.New("notification does not belong to this merchant")
 	}
-	if totalAmount == "" {
-		totalAmount = params.Get("total_fee")
+	if refund.OutRefundNo == "" || refund.RefundStatus == "" {
+		return nil, errors.New("missing required refund parameters")
 	}
 
-	// 转换微信支付状态到统一状态
-	unifiedStatus := c.mapWeChatStatusToUnified(tradeStatus)
+	params := url.Values{}
+	params.Set("notification_id", notification.ID)
+	params.Set("event_type", notification.EventType)
+	params.Set("refund_status", refund.RefundStatus)
 
-	result := &CallbackResult{
-		TradeNo:     tradeNo,
-		OutTradeNo:  outTradeNo,
-		TradeStatus: unifiedStatus,
-		TotalAmount: totalAmount,
+	return &CallbackResult{
+		TradeNo:     refund.TransactionID,
+		OutTradeNo:  refund.OutTradeNo,
+		TradeStatus: refund.RefundStatus,
+		TotalAmount: fenToYuan(refund.Amount.Total),
 		RawParams:   params,
 		PaymentType: c.GetPaymentType(),
+		Refund: &RefundResult{
+			RefundNo:         refund.OutRefundNo,
+			ProviderRefundID: refund.RefundID,
+			Status:           mapWeChatRefundStatus(refund.RefundStatus),
+			Amount:           fenToYuan(refund.Amount.Refund),
+			RawParams:        params,
+			PaymentType:      c.GetPaymentType(),
+		},
+	}, nil
+}
+
+// Refund 申请退款，退款结果以异步通知为准（与支付通知共用通知地址）
+func (c *WeChatPayClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	body := map[string]interface{}{
+		"out_trade_no":  req.OrderNo,
+		"out_refund_no": req.RefundNo,
+		"reason":        truncateRunes(req.Reason, 80),
+		"notify_url":    c.NotifyURL,
+		"amount": map[string]interface{}{
+			"refund":   req.Amount.Amount,
+			"total":    req.TotalAmount.Amount,
+			"currency": "CNY",
+		},
+	}
+
+	var refund WeChatRefund
+	if err := c.doRequest("POST", "/v3/refund/domestic/refunds", body, &refund); err != nil {
+		return nil, refundError(fmt.Errorf("failed to create refund: %w", err))
 	}
 
-	return result, nil
+	return &RefundResult{
+		RefundNo:         req.RefundNo,
+		ProviderRefundID: refund.RefundID,
+		Status:           mapWeChatRefundStatus(refund.Status),
+		Amount:           fenToYuan(refund.Amount.Refund),
+		RawParams:        url.Values{"status": {refund.Status}},
+		PaymentType:      c.GetPaymentType(),
+	}, nil
+}
+
+// mapWeChatRefundStatus 映射微信支付退款状态
+func mapWeChatRefundStatus(status string) models.RefundStatus {
+	switch status {
+	case "SUCCESS":
+		return models.RefundStatusSuccess
+	case "CLOSED", "ABNORMAL":
+		return models.RefundStatusFailed
+	default:
+		// PROCESSING
+		return models.RefundStatusPending
+	}
 }
 
 // GetPaymentType 获取支付类型
@@ -120,12 +463,236 @@ func (c *WeChatPayClient) GetPaymentType() PaymentType {
 	return PaymentTypeWeChat
 }
 
-// createMockPayment 创建模拟支付
-func (c *WeChatPayClient) createMockPayment(order *models.Order, subject string) (string, error) {
-	// 生成模拟支付URL
-	paymentID := uuid.New().String()
-	return fmt.Sprintf("http://localhost:3000/mock-pay?type=wechat&id=%s&order_no=%s&amount=%.2f",
-		paymentID, order.OrderNo, order.TotalAmount), nil
+// doRequest 发送签名后的APIv3请求，并验证应答签名
+func (c *WeChatPayClient) doRequest(method, path string, payload interface{}, out interface{}) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	respBody, header, err := c.send(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	// 平台证书下载接口的应答由getPlatformCert自行验证
+	if path != "/v3/certificates" {
+		if err := c.verifyResponse(header, respBody); err != nil {
+			return fmt.Errorf("response verification failed: %w", err)
+		}
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// send 发送带商户签名的HTTP请求
+func (c *WeChatPayClient) send(method, path string, body []byte) ([]byte, http.Header, error) {
+	authorization, err := c.buildAuthorization(method, path, body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "skillhub")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, &apiError{service: "wechat pay", status: resp.StatusCode, body: string(respBody)}
+	}
+
+	return respBody, resp.Header, nil
+}
+
+// buildAuthorization 生成APIv3请求的Authorization头
+func (c *WeChatPayClient) buildAuthorization(method, path string, body []byte) (string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := strings.ReplaceAll(uuid.New().String(), "-", "")
+	message := method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.PrivateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		wechatAuthSchema, c.MchID, nonce, base64.StdEncoding.EncodeToString(signature), timestamp, c.SerialNo), nil
+}
+
+// verifyResponse 验证API应答签名
+func (c *WeChatPayClient) verifyResponse(header http.Header, body []byte) error {
+	serial := header.Get("Wechatpay-Serial")
+	signature := header.Get("Wechatpay-Signature")
+	if serial == "" || signature == "" {
+		return errors.New("missing wechatpay signature headers")
+	}
+
+	cert, err := c.getPlatformCert(serial)
+	if err != nil {
+		return err
+	}
+
+	message := header.Get("Wechatpay-Timestamp") + "\n" + header.Get("Wechatpay-Nonce") + "\n" + string(body) + "\n"
+	return verifySHA256WithRSA(cert, message, signature)
+}
+
+// getPlatformCert 按序列号获取平台证书，缓存过期或序列号未知时重新下载。
+// 下载至多每wechatCertMinRefreshInterval一次，下载后仍未找到的序列号在wechatUnknownSerialTTL内直接拒绝
+func (c *WeChatPayClient) getPlatformCert(serial string) (*x509.Certificate, error) {
+	c.certMu.RLock()
+	cert, ok := c.platformCerts[serial]
+	fresh := time.Since(c.certsFetched) < wechatCertRefreshInterval
+	unknownUntil, unknown := c.unknownSerials[serial]
+	c.certMu.RUnlock()
+
+	if ok && fresh {
+		return cert, nil
+	}
+	if !ok && unknown && time.Now().Before(unknownUntil) {
+		return nil, fmt.Errorf("unknown wechatpay platform certificate serial: %s", serial)
+	}
+
+	if err := c.throttledRefresh(); err != nil {
+		if ok {
+			// 刷新失败时继续使用已缓存的证书
+			return cert, nil
+		}
+		return nil, fmt.Errorf("failed to download platform certificates: %w", err)
+	}
+
+	c.certMu.Lock()
+	defer c.certMu.Unlock()
+	cert, ok = c.platformCerts[serial]
+	if !ok {
+		now := time.Now()
+		for s, until := range c.unknownSerials {
+			if now.After(until) {
+				delete(c.unknownSerials, s)
+			}
+		}
+		c.unknownSerials[serial] = now.Add(wechatUnknownSerialTTL)
+		return nil, fmt.Errorf("unknown wechatpay platform certificate serial: %s", serial)
+	}
+	return cert, nil
+}
+
+// throttledRefresh 下载平台证书，距上次尝试不足wechatCertMinRefreshInterval时不再下载，返回上次的结果
+func (c *WeChatPayClient) throttledRefresh() error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if time.Since(c.refreshAttempted) < wechatCertMinRefreshInterval {
+		return c.refreshErr
+	}
+	c.refreshAttempted = time.Now()
+	c.refreshErr = c.refreshPlatformCerts()
+	return c.refreshErr
+}
+
+// refreshPlatformCerts 下载并解密平台证书
+func (c *WeChatPayClient) refreshPlatformCerts() error {
+	respBody, header, err := c.send("GET", "/v3/certificates", nil)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data []struct {
+			SerialNo           string                  `json:"serial_no"`
+			EffectiveTime      string                  `json:"effective_time"`
+			ExpireTime         string                  `json:"expire_time"`
+			EncryptCertificate WeChatEncryptedResource `json:"encrypt_certificate"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("failed to decode certificates: %w", err)
+	}
+
+	certs := make(map[string]*x509.Certificate, len(resp.Data))
+	for _, item := range resp.Data {
+		plaintext, err := c.decryptResource(item.EncryptCertificate)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt certificate %s: %w", item.SerialNo, err)
+		}
+		cert, err := parseCertificate(plaintext)
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate %s: %w", item.SerialNo, err)
+		}
+		if time.Now().After(cert.NotAfter) {
+			continue
+		}
+		certs[item.SerialNo] = cert
+	}
+	if len(certs) == 0 {
+		return errors.New("no valid platform certificate returned")
+	}
+
+	// 使用下载到的证书验证应答本身
+	serial := header.Get("Wechatpay-Serial")
+	cert, ok := certs[serial]
+	if !ok {
+		return fmt.Errorf("certificate response signed by unknown serial: %s", serial)
+	}
+	message := header.Get("Wechatpay-Timestamp") + "\n" + header.Get("Wechatpay-Nonce") + "\n" + string(respBody) + "\n"
+	if err := verifySHA256WithRSA(cert, message, header.Get("Wechatpay-Signature")); err != nil {
+		return fmt.Errorf("certificate response verification failed: %w", err)
+	}
+
+	c.certMu.Lock()
+	c.platformCerts = certs
+	c.certsFetched = time.Now()
+	for serial := range certs {
+		delete(c.unknownSerials, serial)
+	}
+	c.certMu.Unlock()
+	return nil
+}
+
+// decryptResource 使用APIv3密钥解密AEAD_AES_256_GCM资源
+func (c *WeChatPayClient) decryptResource(resource WeChatEncryptedResource) ([]byte, error) {
+	if resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, fmt.Errorf("unsupported algorithm: %s", resource.Algorithm)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(resource.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+
+	block, err := aes.NewCipher([]byte(c.APIKey))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(resource.Nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Open(nil, []byte(resource.Nonce), ciphertext, []byte(resource.AssociatedData))
 }
 
 // mapWeChatStatusToUnified 映射微信支付状态到统一状态
@@ -151,6 +718,58 @@ func (c *WeChatPayClient) mapWeChatStatusToUnified(wechatStatus string) string {
 	}
 }
 
+// verifySHA256WithRSA 使用证书公钥验证SHA256withRSA签名
+func verifySHA256WithRSA(cert *x509.Certificate, message, signature string) error {
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("certificate does not contain an RSA public key")
+	}
+
+	signBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	hashed := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, hashed[:], signBytes); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+	return nil
+}
+
+// parseCertificate 解析PEM格式的X.509证书
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to parse PEM block")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certSerialNo 返回微信支付使用的证书序列号格式（大写十六进制）
+func certSerialNo(cert *x509.Certificate) string {
+	return strings.ToUpper(cert.SerialNumber.Text(16))
+}
+
+// fenToYuan 将分格式化为元
+func fenToYuan(fen int64) string {
+	sign := ""
+	if fen < 0 {
+		sign = "-"
+		fen = -fen
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, fen/100, fen%100)
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
 // MockWeChatPayClient 模拟微信支付客户端（用于开发和测试）
 type MockWeChatPayClient struct{}
 
@@ -162,8 +781,8 @@ func NewMockWeChatPayClient() *MockWeChatPayClient {
 // CreatePayment 创建模拟支付
 func (c *MockWeChatPayClient) CreatePayment(order *models.Order, subject string) (string, error) {
 	paymentID := uuid.New().String()
-	return fmt.Sprintf("http://localhost:3000/mock-pay?type=wechat&id=%s&order_no=%s&amount=%.2f",
-		paymentID, order.OrderNo, order.TotalAmount), nil
+	return fmt.Sprintf("http://localhost:3000/mock-pay?type=wechat&id=%s&order_no=%s&amount=%s",
+		paymentID, order.OrderNo, order.Total.Decimal()), nil
 }
 
 // VerifyCallback 验证模拟回调
@@ -183,6 +802,43 @@ func (c *MockWeChatPayClient) ProcessCallback(params url.Values) (*CallbackResul
 	}, nil
 }
 
+// ProcessNotification 处理模拟回调通知（明文交易JSON，不做签名验证）
+func (c *MockWeChatPayClient) ProcessNotification(header http.Header, body []byte) (*CallbackResult, error) {
+	var transaction WeChatTransaction
+	if err := json.Unmarshal(body, &transaction); err != nil {
+		return nil, fmt.Errorf("failed to parse notification: %w", err)
+	}
+
+	tradeNo := transaction.TransactionID
+	if tradeNo == "" {
+		tradeNo = "wechat_mock_trade_" + uuid.New().String()[:8]
+	}
+
+	params := url.Values{}
+	params.Set("out_trade_no", transaction.OutTradeNo)
+	params.Set("amount.total", strconv.FormatInt(transaction.Amount.Total, 10))
+
+	return &CallbackResult{
+		TradeNo:     tradeNo,
+		OutTradeNo:  transaction.OutTradeNo,
+		TradeStatus: "TRADE_SUCCESS",
+		TotalAmount: fenToYuan(transaction.Amount.Total),
+		Currency:    transaction.Amount.Currency,
+		RawParams:   params,
+		PaymentType: PaymentTypeWeChat,
+	}, nil
+}
+
+// QueryPayment 模拟支付不支持查询
+func (c *MockWeChatPayClient) QueryPayment(order *models.Order) (*CallbackResult, error) {
+	return nil, ErrQueryNotSupported
+}
+
+// Refund 模拟退款，直接返回成功
+func (c *MockWeChatPayClient) Refund(req *RefundRequest) (*RefundResult, error) {
+	return newMockRefundResult(req, c.GetPaymentType()), nil
+}
+
 // GetPaymentType 获取支付类型
 func (c *MockWeChatPayClient) GetPaymentType() PaymentType {
 	return PaymentTypeWeChat
@@ -0,0 +1,342 @@
+package payment
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+const testAPIv3Key = "0123456789abcdef0123456789abcdef"
+
+// wechatTestPlatform 模拟微信支付平台（平台证书与签名）
+type wechatTestPlatform struct {
+	key       *rsa.PrivateKey
+	cert      *x509.Certificate
+	serial    string
+	downloads int // 平台证书下载次数
+}
+
+func newWeChatTestPlatform(t *testing.T) *wechatTestPlatform {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate platform key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x5157F09EFDC096DE),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create platform certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &wechatTestPlatform{key: key, cert: cert, serial: certSerialNo(cert)}
+}
+
+func (p *wechatTestPlatform) sign(t *testing.T, header http.Header, body []byte) {
+	t.Helper()
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.New().String()
+	hashed := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	header.Set("Wechatpay-Timestamp", timestamp)
+	header.Set("Wechatpay-Nonce", nonce)
+	header.Set("Wechatpay-Serial", p.serial)
+	header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(signature))
+}
+
+func encryptWeChatResource(t *testing.T, plaintext []byte, associatedData string) WeChatEncryptedResource {
+	t.Helper()
+	block, _ := aes.NewCipher([]byte(testAPIv3Key))
+	gcm, _ := cipher.NewGCM(block)
+	nonce := "a1b2c3d4e5f6"
+	ciphertext := gcm.Seal(nil, []byte(nonce), plaintext, []byte(associatedData))
+	return WeChatEncryptedResource{
+		Algorithm:      "AEAD_AES_256_GCM",
+		Ciphertext:     base64.StdEncoding.EncodeToString(ciphertext),
+		AssociatedData: associatedData,
+		Nonce:          nonce,
+	}
+}
+
+// writeMerchantKey 生成商户私钥并写入临时文件
+func writeMerchantKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	merchantKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate merchant key: %v", err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(merchantKey)
+	keyPath := filepath.Join(t.TempDir(), "apiclient_key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("failed to write merchant key: %v", err)
+	}
+	return merchantKey, keyPath
+}
+
+// newTestWeChatClient 创建指向模拟平台的微信支付客户端
+func newTestWeChatClient(t *testing.T, platform *wechatTestPlatform, handler http.HandlerFunc) (*WeChatPayClient, *rsa.PrivateKey) {
+	t.Helper()
+	merchantKey, keyPath := writeMerchantKey(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v3/certificates" {
+			platform.downloads++
+			certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: platform.cert.Raw})
+			body, _ := json.Marshal(map[string]interface{}{
+				"data": []interface{}{map[string]interface{}{
+					"serial_no":           platform.serial,
+					"encrypt_certificate": encryptWeChatResource(t, certPEM, "certificate"),
+				}},
+			})
+			platform.sign(t, w.Header(), body)
+			w.Write(body)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewWeChatPayClient(config.WeChatPayConfig{
+		AppID:          "wx_test_app",
+		MchID:          "1900000001",
+		APIKey:         testAPIv3Key,
+		SerialNo:       "MERCHANTSERIAL",
+		PrivateKeyPath: keyPath,
+		NotifyURL:      "https://example.com/api/v1/payment/callback/wechat",
+		BaseURL:        server.URL,
+	})
+	if err != nil {
+		t.Fatalf("NewWeChatPayClient failed: %v", err)
+	}
+	return client, merchantKey
+}
+
+func TestWeChatPayCreateNativePayment(t *testing.T) {
+	platform := newWeChatTestPlatform(t)
+	var merchantKey *rsa.PrivateKey
+	var client *WeChatPayClient
+	client, merchantKey = newTestWeChatClient(t, platform, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/pay/transactions/native" {
+			w.WriteHeader(404)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+
+		// 验证商户请求签名
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, wechatAuthSchema+" ") {
+			t.Errorf("unexpected authorization schema: %s", auth)
+		}
+		fields := map[string]string{}
+		for _, part := range strings.Split(strings.TrimPrefix(auth, wechatAuthSchema+" "), ",") {
+			kv := strings.SplitN(part, "=", 2)
+			fields[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+		message := r.Method + "\n" + r.URL.Path + "\n" + fields["timestamp"] + "\n" + fields["nonce_str"] + "\n" + string(body) + "\n"
+		signature, _ := base64.StdEncoding.DecodeString(fields["signature"])
+		hashed := sha256.Sum256([]byte(message))
+		if err := rsa.VerifyPKCS1v15(&merchantKey.PublicKey, crypto.SHA256, hashed[:], signature); err != nil {
+			t.Errorf("request signature invalid: %v", err)
+		}
+
+		var req map[string]interface{}
+		json.Unmarshal(body, &req)
+		amount := req["amount"].(map[string]interface{})
+		if amount["total"].(float64) != 9999 {
+			t.Errorf("expected amount 9999 fen, got %v", amount["total"])
+		}
+
+		resp := []byte(`{"code_url":"weixin://wxpay/bizpayurl?pr=test"}`)
+		platform.sign(t, w.Header(), resp)
+		w.Write(resp)
+	})
+
+	order := &models.Order{ID: uuid.New(), OrderNo: "ORDWX001", Total: models.NewMoney(9999, "CNY")}
+	codeURL, err := client.CreatePayment(order, "测试技能")
+	if err != nil {
+		t.Fatalf("CreatePayment failed: %v", err)
+	}
+	if codeURL != "weixin://wxpay/bizpayurl?pr=test" {
+		t.Errorf("unexpected code_url: %s", codeURL)
+	}
+}
+
+func TestWeChatPayProcessNotification(t *testing.T) {
+	platform := newWeChatTestPlatform(t)
+	client, _ := newTestWeChatClient(t, platform, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	})
+
+	transaction, _ := json.Marshal(map[string]interface{}{
+		"appid":          "wx_test_app",
+		"mchid":          "1900000001",
+		"out_trade_no":   "ORDWX001",
+		"transaction_id": "4200000001",
+		"trade_type":     "NATIVE",
+		"trade_state":    "SUCCESS",
+		"amount":         map[string]interface{}{"total": 9999, "currency": "CNY"},
+	})
+	body, _ := json.Marshal(WeChatNotification{
+		ID:           "notify-1",
+		EventType:    "TRANSACTION.SUCCESS",
+		ResourceType: "encrypt-resource",
+		Resource:     encryptWeChatResource(t, transaction, "transaction"),
+	})
+
+	header := http.Header{}
+	platform.sign(t, header, body)
+
+	result, err := client.ProcessNotification(header, body)
+	if err != nil {
+		t.Fatalf("ProcessNotification failed: %v", err)
+	}
+	if result.OutTradeNo != "ORDWX001" || result.TradeNo != "4200000001" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.TradeStatus != "TRADE_SUCCESS" {
+		t.Errorf("expected TRADE_SUCCESS, got %s", result.TradeStatus)
+	}
+	if result.TotalAmount != "99.99" {
+		t.Errorf("expected amount 99.99, got %s", result.TotalAmount)
+	}
+
+	// 篡改报文后签名验证应失败
+	tampered := []byte(strings.Replace(string(body), "notify-1", "notify-2", 1))
+	if _, err := client.ProcessNotification(header, tampered); err == nil {
+		t.Error("expected verification failure for tampered body")
+	}
+
+	// 缺少签名头应失败
+	if _, err := client.ProcessNotification(http.Header{}, body); err == nil {
+		t.Error("expected verification failure without signature headers")
+	}
+}
+
+func TestWeChatPayProcessRefundNotification(t *testing.T) {
+	platform := newWeChatTestPlatform(t)
+	client, _ := newTestWeChatClient(t, platform, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	})
+
+	refund, _ := json.Marshal(map[string]interface{}{
+		"mchid":          "1900000001",
+		"out_trade_no":   "ORDWX001",
+		"transaction_id": "4200000001",
+		"out_refund_no":  "RF001",
+		"refund_id":      "50000000001",
+		"refund_status":  "SUCCESS",
+		"amount":         map[string]interface{}{"total": 9999, "refund": 5000},
+	})
+	body, _ := json.Marshal(WeChatNotification{
+		ID:           "notify-refund-1",
+		EventType:    "REFUND.SUCCESS",
+		ResourceType: "encrypt-resource",
+		Resource:     encryptWeChatResource(t, refund, "refund"),
+	})
+
+	header := http.Header{}
+	platform.sign(t, header, body)
+
+	result, err := client.ProcessNotification(header, body)
+	if err != nil {
+		t.Fatalf("ProcessNotification failed: %v", err)
+	}
+	if result.Refund == nil {
+		t.Fatal("expected refund result")
+	}
+	if result.Refund.RefundNo != "RF001" || result.Refund.Status != models.RefundStatusSuccess || result.Refund.Amount != "50.00" {
+		t.Errorf("unexpected refund result: %+v", result.Refund)
+	}
+}
+
+func TestWeChatPayPlatformCertRefreshThrottled(t *testing.T) {
+	platform := newWeChatTestPlatform(t)
+	client, _ := newTestWeChatClient(t, platform, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+	})
+
+	if _, err := client.getPlatformCert(platform.serial); err != nil {
+		t.Fatalf("getPlatformCert failed: %v", err)
+	}
+	// 伪造的序列号不应每次都触发下载
+	for _, serial := range []string{"FORGED1", "FORGED2", "FORGED1"} {
+		if _, err := client.getPlatformCert(serial); err == nil {
+			t.Errorf("expected unknown serial %s to be rejected", serial)
+		}
+	}
+	if platform.downloads != 1 {
+		t.Errorf("expected 1 certificate download within the refresh interval, got %d", platform.downloads)
+	}
+
+	// 间隔过后重新下载一次，下载后仍未知的序列号被缓存，不再触发下载
+	client.refreshAttempted = time.Now().Add(-wechatCertMinRefreshInterval)
+	client.getPlatformCert("FORGED3")
+	client.refreshAttempted = time.Now().Add(-wechatCertMinRefreshInterval)
+	if _, err := client.getPlatformCert("FORGED3"); err == nil {
+		t.Error("expected cached unknown serial to be rejected")
+	}
+	if platform.downloads != 2 {
+		t.Errorf("expected 2 certificate downloads, got %d", platform.downloads)
+	}
+	if _, err := client.getPlatformCert(platform.serial); err != nil {
+		t.Errorf("known serial rejected after refresh: %v", err)
+	}
+}
+
+func TestGetPaymentServiceReusesWeChatClient(t *testing.T) {
+	_, keyPath := writeMerchantKey(t)
+	cfg := config.Config{}
+	cfg.Payment.WeChatPay = config.WeChatPayConfig{
+		AppID:          "wx_test_app",
+		MchID:          "1900000001",
+		APIKey:         testAPIv3Key,
+		SerialNo:       "MERCHANTSERIAL",
+		PrivateKeyPath: keyPath,
+	}
+
+	// 平台证书缓存挂在客户端上，同一配置必须复用同一客户端
+	first, err := GetPaymentService(PaymentTypeWeChat, cfg)
+	if err != nil {
+		t.Fatalf("GetPaymentService failed: %v", err)
+	}
+	second, _ := GetPaymentService(PaymentTypeWeChat, cfg)
+	byCurrency, _ := GetPaymentServiceForCurrency(cfg, "CNY")
+	if first != second || first != byCurrency {
+		t.Error("expected the same wechat client for the same config")
+	}
+
+	cfg.Payment.WeChatPay.BaseURL = "https://example.com"
+	if other, _ := GetPaymentService(PaymentTypeWeChat, cfg); other == first {
+		t.Error("expected a new wechat client for a different config")
+	}
+}
@@ -0,0 +1,223 @@
+package pricing
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"skillhub/config"
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 汇率来源
+const (
+	SourceAdmin = "admin"
+	SourceFile  = "file"
+)
+
+var (
+	// ErrNoRate 缺少所需币种对的汇率
+	ErrNoRate = errors.New("exchange rate not available")
+	// ErrInvalidCurrency 币种代码不合法
+	ErrInvalidCurrency = errors.New("invalid currency code")
+)
+
+// Quote 技能在指定地区/币种下的报价
+type Quote struct {
+	Price  models.Money `json:"price"`
+	Region string       `json:"region,omitempty"` // 命中地区价格时为该地区
+	Rate   float64      `json:"rate,omitempty"`   // 经汇率换算时使用的汇率
+}
+
+// Rate 一条汇率记录
+type Rate struct {
+	Base  string
+	Quote string
+	Rate  float64
+}
+
+// DefaultCurrency 平台基础币种
+func DefaultCurrency() string {
+	if config.AppConfig != nil && config.AppConfig.Pricing.DefaultCurrency != "" {
+		return strings.ToUpper(config.AppConfig.Pricing.DefaultCurrency)
+	}
+	return "CNY"
+}
+
+// NormalizeCurrency 规范化币种代码（三位大写字母）
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) != 3 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+		}
+	}
+	return currency, nil
+}
+
+// NormalizeRegion 规范化国家/地区代码（大写）
+func NormalizeRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// SkillCurrency 技能基础价格的币种
+func SkillCurrency(skill *models.Skill) string {
+	if skill.Price.Currency != "" {
+		return strings.ToUpper(skill.Price.Currency)
+	}
+	return DefaultCurrency()
+}
+
+// QuoteSkill 计算技能报价：优先使用地区价格，其次为基础价格；指定币种与价格币种不同时按汇率换算
+func QuoteSkill(skill *models.Skill, region, currency string) (*Quote, error) {
+	quote := &Quote{Price: models.NewMoney(skill.Price.Amount, SkillCurrency(skill))}
+
+	if region = NormalizeRegion(region); region != "" {
+		// 带省/州的地区（如 US-CA）没有单独定价时使用国家价格
+		country, _ := models.SplitRegion(region)
+		var price models.SkillPrice
+		if err := models.GetDB().Where("skill_id = ? AND region IN ?", skill.ID, []string{region, country}).
+			Order("LENGTH(region) DESC").First(&price).Error; err == nil {
+			quote.Price = models.NewMoney(price.Price.Amount, price.Price.Currency)
+			quote.Region = price.Region
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if currency == "" {
+		return quote, nil
+	}
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	if currency == quote.Price.Currency {
+		return quote, nil
+	}
+
+	rate, err := LookupRate(quote.Price.Currency, currency)
+	if err != nil {
+		return nil, err
+	}
+	quote.Price = quote.Price.Convert(rate, currency)
+	quote.Rate = rate
+	return quote, nil
+}
+
+// Convert 按存储的汇率换算金额
+func Convert(amount models.Money, to string) (models.Money, error) {
+	if strings.EqualFold(amount.Currency, to) {
+		return amount, nil
+	}
+	rate, err := LookupRate(amount.Currency, to)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return amount.Convert(rate, to), nil
+}
+
+// LookupRate 查询汇率，没有直接汇率时使用反向汇率
+func LookupRate(from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	db := models.GetDB()
+
+	var rate models.ExchangeRate
+	if err := db.Where("base_currency = ? AND quote_currency = ?", from, to).First(&rate).Error; err == nil && rate.Rate > 0 {
+		return rate.Rate, nil
+	}
+	if err := db.Where("base_currency = ? AND quote_currency = ?", to, from).First(&rate).Error; err == nil && rate.Rate > 0 {
+		return 1 / rate.Rate, nil
+	}
+	return 0, fmt.Errorf("%w: %s -> %s", ErrNoRate, from, to)
+}
+
+// SetRates 写入或更新汇率
+func SetRates(rates []Rate, source string) error {
+	records := make([]models.ExchangeRate, 0, len(rates))
+	for _, r := range rates {
+		base, err := NormalizeCurrency(r.Base)
+		if err != nil {
+			return err
+		}
+		quote, err := NormalizeCurrency(r.Quote)
+		if err != nil {
+			return err
+		}
+		if r.Rate <= 0 || base == quote {
+			return fmt.Errorf("invalid rate %s/%s: %v", base, quote, r.Rate)
+		}
+		records = append(records, models.ExchangeRate{ID: uuid.New(), BaseCurrency: base, QuoteCurrency: quote, Rate: r.Rate, Source: source})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	return models.GetDB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base_currency"}, {Name: "quote_currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).Create(&records).Error
+}
+
+// ImportRates 从CSV导入汇率（每行 base,quote,rate，#开头为注释，可带表头）
+func ImportRates(r io.Reader, source string) (int, error) {
+	rates, err := ParseRates(r)
+	if err != nil {
+		return 0, err
+	}
+	if err := SetRates(rates, source); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// ImportRatesFile 从文件导入汇率
+func ImportRatesFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return ImportRates(f, SourceFile)
+}
+
+// ParseRates 解析汇率CSV
+func ParseRates(r io.Reader) ([]Rate, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rates []Rate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil {
+			if line == 1 {
+				// 表头
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, record[2])
+		}
+		rates = append(rates, Rate{Base: record[0], Quote: record[1], Rate: rate})
+	}
+	return rates, nil
+}
@@ -0,0 +1,40 @@
+package pricing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseRates(t *testing.T) {
+	input := `base,quote,rate
+# 2026-10 rates
+USD,CNY,7.1
+EUR, USD, 1.08
+`
+	rates, err := ParseRates(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseRates failed: %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("expected 2 rates, got %d", len(rates))
+	}
+	if rates[1].Base != "EUR" || rates[1].Quote != "USD" || rates[1].Rate != 1.08 {
+		t.Errorf("unexpected rate: %+v", rates[1])
+	}
+
+	if _, err := ParseRates(strings.NewReader("USD,CNY,7.1\nUSD,JPY,abc\n")); err == nil {
+		t.Error("expected error for invalid rate")
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	if c, err := NormalizeCurrency(" usd "); err != nil || c != "USD" {
+		t.Errorf("expected USD, got %q, %v", c, err)
+	}
+	for _, bad := range []string{"", "US", "US1", "DOLLAR"} {
+		if _, err := NormalizeCurrency(bad); !errors.Is(err, ErrInvalidCurrency) {
+			t.Errorf("expected ErrInvalidCurrency for %q, got %v", bad, err)
+		}
+	}
+}
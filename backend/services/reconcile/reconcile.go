@@ -0,0 +1,206 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/orders"
+	"skillhub/services/payment"
+)
+
+// DefaultWindow 默认对账时间窗口（只检查该时间内创建的订单）
+const DefaultWindow = 72 * time.Hour
+
+// Action 对账后对订单执行的修正动作
+type Action string
+
+const (
+	ActionNone     Action = "none"
+	ActionMarkPaid Action = "mark_paid"
+	ActionCancel   Action = "cancel"
+)
+
+// Mismatch 本地记录与网关不一致的订单
+type Mismatch struct {
+	OrderNo        string `json:"order_no"`
+	PaymentMethod  string `json:"payment_method"`
+	LocalStatus    string `json:"local_status"`
+	ProviderStatus string `json:"provider_status"`
+	Reason         string `json:"reason"`
+}
+
+// Report 对账结果
+type Report struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt time.Time  `json:"finished_at"`
+	Checked    int        `json:"checked"`
+	Fixed      int        `json:"fixed"`
+	Skipped    int        `json:"skipped"`
+	Errors     int        `json:"errors"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Decision 单个订单的对账结论
+type Decision struct {
+	Action  Action
+	Reasons []string
+}
+
+// Run 对时间窗口内待支付和已支付的订单向网关查询状态，修正订单状态并汇总差异
+func Run(cfg config.Config, window time.Duration) (*Report, error) {
+	db := models.GetDB()
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	report := &Report{StartedAt: time.Now(), Mismatches: []Mismatch{}}
+
+	var candidates []models.Order
+	if err := db.Preload("Transactions").
+		Where("status IN ? AND created_at >= ?",
+			[]models.OrderStatus{models.OrderStatusPending, models.OrderStatusPaid}, time.Now().Add(-window)).
+		Where("payment_method <> '' AND payment_method <> ?", string(payment.PaymentTypeMock)).
+		Order("created_at ASC").
+		Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load orders: %w", err)
+	}
+
+	for i := range candidates {
+		order := &candidates[i]
+
+		service, err := payment.GetPaymentService(payment.PaymentType(order.PaymentMethod), cfg)
+		if err != nil || string(service.GetPaymentType()) != order.PaymentMethod {
+			// 网关未配置（已回退为模拟支付），无法查询
+			report.Skipped++
+			continue
+		}
+
+		result, err := service.QueryPayment(order)
+		if errors.Is(err, payment.ErrQueryNotSupported) {
+			report.Skipped++
+			continue
+		}
+		if err != nil {
+			log.Printf("Reconcile: failed to query order %s: %v", order.OrderNo, err)
+			report.Errors++
+			continue
+		}
+		report.Checked++
+
+		decision := Decide(order, result)
+		for _, reason := range decision.Reasons {
+			report.Mismatches = append(report.Mismatches, Mismatch{
+				OrderNo:        order.OrderNo,
+				PaymentMethod:  order.PaymentMethod,
+				LocalStatus:    string(order.Status),
+				ProviderStatus: result.TradeStatus,
+				Reason:         reason,
+			})
+		}
+
+		if err := apply(order, result, decision.Action); err != nil {
+			log.Printf("Reconcile: failed to fix order %s: %v", order.OrderNo, err)
+			report.Errors++
+			continue
+		}
+		if decision.Action != ActionNone {
+			report.Fixed++
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	for _, m := range report.Mismatches {
+		log.Printf("Reconcile mismatch: order=%s method=%s local=%s provider=%s reason=%s",
+			m.OrderNo, m.PaymentMethod, m.LocalStatus, m.ProviderStatus, m.Reason)
+	}
+	log.Printf("Reconcile finished: checked=%d fixed=%d skipped=%d errors=%d mismatches=%d",
+		report.Checked, report.Fixed, report.Skipped, report.Errors, len(report.Mismatches))
+
+	return report, nil
+}
+
+// RunScheduled 定时任务入口
+func RunScheduled() error {
+	if config.AppConfig == nil {
+		return errors.New("config not loaded")
+	}
+	_, err := Run(*config.AppConfig, DefaultWindow)
+	return err
+}
+
+// Decide 比较本地订单与网关查询结果，得出修正动作和差异说明
+func Decide(order *models.Order, result *payment.CallbackResult) Decision {
+	decision := Decision{Action: ActionNone}
+
+	if result.OutTradeNo != "" && result.OutTradeNo != order.OrderNo {
+		decision.Reasons = append(decision.Reasons,
+			fmt.Sprintf("provider returned order %s", result.OutTradeNo))
+		return decision
+	}
+
+	switch order.Status {
+	case models.OrderStatusPending:
+		switch {
+		case result.IsPaid():
+			if !amountEqual(result, order.Total) {
+				// 金额不符仍交给状态机入账，由其转入人工审核
+				decision.Reasons = append(decision.Reasons,
+					fmt.Sprintf("provider paid amount %s differs from order amount %s", result.TotalAmount, order.Total.Decimal()))
+			}
+			// 回调丢失：网关已支付，本地仍待支付
+			decision.Action = ActionMarkPaid
+		case result.IsClosed():
+			decision.Action = ActionCancel
+		}
+
+	case models.OrderStatusPaid:
+		if !result.IsPaid() {
+			decision.Reasons = append(decision.Reasons, "order is paid locally but not at provider")
+			return decision
+		}
+
+		var txn *models.Transaction
+		for i := range order.Transactions {
+			if order.Transactions[i].Status == models.TransactionStatusSuccess {
+				txn = &order.Transactions[i]
+				break
+			}
+		}
+		if txn == nil {
+			decision.Reasons = append(decision.Reasons, "no successful transaction recorded")
+			return decision
+		}
+		if result.TradeNo != "" && txn.TransactionID != result.TradeNo {
+			decision.Reasons = append(decision.Reasons,
+				fmt.Sprintf("transaction id %s differs from provider trade no %s", txn.TransactionID, result.TradeNo))
+		}
+		if !amountEqual(result, txn.Amount) {
+			decision.Reasons = append(decision.Reasons,
+				fmt.Sprintf("transaction amount %s differs from provider amount %s", txn.Amount.Decimal(), result.TotalAmount))
+		}
+	}
+
+	return decision
+}
+
+// apply 执行修正动作（通过订单状态机，与回调处理共用幂等和加锁逻辑）
+func apply(order *models.Order, result *payment.CallbackResult, action Action) error {
+	if action == ActionNone {
+		return nil
+	}
+	if result.OutTradeNo == "" {
+		result.OutTradeNo = order.OrderNo
+	}
+	_, err := orders.ApplyPayment(result, orders.SourceReconcile)
+	return err
+}
+
+// amountEqual 按币种最小单位比较网关金额与本地金额，币种不同视为不一致
+func amountEqual(result *payment.CallbackResult, local models.Money) bool {
+	amount, err := result.Amount(local.Currency)
+	return err == nil && amount.Amount == local.Amount && (local.Currency == "" || amount.SameCurrency(local))
+}
@@ -0,0 +1,68 @@
+package reconcile
+
+import (
+	"testing"
+
+	"skillhub/models"
+	"skillhub/services/payment"
+)
+
+func TestDecidePendingOrder(t *testing.T) {
+	order := &models.Order{OrderNo: "ORD001", Total: models.NewMoney(1999, "CNY"), Status: models.OrderStatusPending}
+
+	// 网关已支付，本地待支付：补记为已支付
+	decision := Decide(order, &payment.CallbackResult{OutTradeNo: "ORD001", TradeStatus: "TRADE_SUCCESS", TotalAmount: "19.99"})
+	if decision.Action != ActionMarkPaid || len(decision.Reasons) != 0 {
+		t.Errorf("expected mark_paid without mismatch, got %+v", decision)
+	}
+
+	// 金额不一致时不修正，只记录差异
+	decision = Decide(order, &payment.CallbackResult{OutTradeNo: "ORD001", TradeStatus: "succeeded", TotalAmount: "1.99"})
+	if decision.Action != ActionMarkPaid || len(decision.Reasons) != 1 {
+		t.Errorf("expected mark_paid with amount mismatch, got %+v", decision)
+	}
+
+	// 交易关闭：取消订单
+	decision = Decide(order, &payment.CallbackResult{OutTradeNo: "ORD001", TradeStatus: "TRADE_CLOSED"})
+	if decision.Action != ActionCancel {
+		t.Errorf("expected cancel, got %+v", decision)
+	}
+
+	// 仍在等待支付：不处理
+	decision = Decide(order, &payment.CallbackResult{OutTradeNo: "ORD001", TradeStatus: "WAIT_BUYER_PAY"})
+	if decision.Action != ActionNone || len(decision.Reasons) != 0 {
+		t.Errorf("expected no action, got %+v", decision)
+	}
+}
+
+func TestDecidePaidOrder(t *testing.T) {
+	order := &models.Order{
+		OrderNo: "ORD002",
+		Total:   models.NewMoney(990, "CNY"),
+		Status:  models.OrderStatusPaid,
+		Transactions: []models.Transaction{
+			{TransactionID: "2024TRADE", Amount: models.NewMoney(990, "CNY"), Status: models.TransactionStatusSuccess},
+		},
+	}
+
+	decision := Decide(order, &payment.CallbackResult{OutTradeNo: "ORD002", TradeNo: "2024TRADE", TradeStatus: "TRADE_SUCCESS", TotalAmount: "9.90"})
+	if decision.Action != ActionNone || len(decision.Reasons) != 0 {
+		t.Errorf("expected consistent order, got %+v", decision)
+	}
+
+	decision = Decide(order, &payment.CallbackResult{OutTradeNo: "ORD002", TradeNo: "OTHER", TradeStatus: "TRADE_SUCCESS", TotalAmount: "9.90"})
+	if len(decision.Reasons) != 1 {
+		t.Errorf("expected trade no mismatch, got %+v", decision)
+	}
+
+	decision = Decide(order, &payment.CallbackResult{OutTradeNo: "ORD002", TradeNo: "2024TRADE", TradeStatus: "WAIT_BUYER_PAY"})
+	if decision.Action != ActionNone || len(decision.Reasons) != 1 {
+		t.Errorf("expected paid-locally mismatch, got %+v", decision)
+	}
+
+	order.Transactions = nil
+	decision = Decide(order, &payment.CallbackResult{OutTradeNo: "ORD002", TradeNo: "2024TRADE", TradeStatus: "TRADE_SUCCESS", TotalAmount: "9.90"})
+	if len(decision.Reasons) != 1 {
+		t.Errorf("expected missing transaction mismatch, got %+v", decision)
+	}
+}
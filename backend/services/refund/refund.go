@@ -0,0 +1,373 @@
+package refund
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/earnings"
+	"skillhub/services/entitlement"
+	"skillhub/services/gift"
+	"skillhub/services/ledger"
+	"skillhub/services/orders"
+	"skillhub/services/payment"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOrderNotRefundable = errors.New("order is not refundable")
+	ErrInvalidAmount      = errors.New("invalid refund amount")
+	ErrNoTransaction      = errors.New("no successful transaction for order")
+	ErrRefundNotPending   = errors.New("refund is not pending")
+)
+
+// Request 退款申请
+type Request struct {
+	OrderID     uuid.UUID
+	OrderItemID *uuid.UUID // 为空表示整单退款
+	Amount      string     // 按订单币种解析的退款金额，为空或为0时退还剩余可退金额
+	Reason      string
+	OperatorID  *uuid.UUID
+}
+
+// Create 创建退款记录并向支付网关发起退款。可退金额的计算和退款记录的写入在锁住订单行的事务中完成，
+// 并发的退款申请不会超额退款
+func Create(cfg config.Config, req Request) (*models.Refund, error) {
+	db := models.GetDB()
+
+	var order models.Order
+	var refund models.Refund
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", req.OrderID).Error; err != nil {
+			return err
+		}
+		if err := tx.Preload("Items").Preload("Transactions").Preload("Refunds").
+			First(&order, "id = ?", req.OrderID).Error; err != nil {
+			return err
+		}
+		// 付款审核中的订单可直接退款（驳回异常付款）
+		if order.Status != models.OrderStatusPaid && order.Status != models.OrderStatusPaymentReview {
+			return ErrOrderNotRefundable
+		}
+
+		var txn *models.Transaction
+		for i := range order.Transactions {
+			if order.Transactions[i].Status == models.TransactionStatusSuccess {
+				txn = &order.Transactions[i]
+				break
+			}
+		}
+		if txn == nil {
+			return ErrNoTransaction
+		}
+
+		amount, err := RefundableAmount(&order, req.OrderItemID)
+		if err != nil {
+			return err
+		}
+		if req.Amount != "" {
+			requested, err := models.ParseMoney(req.Amount, amount.Currency)
+			if err != nil || requested.Amount < 0 {
+				return fmt.Errorf("%w: %q", ErrInvalidAmount, req.Amount)
+			}
+			if requested.Amount > amount.Amount {
+				return fmt.Errorf("%w: at most %s can be refunded", ErrInvalidAmount, amount)
+			}
+			if !requested.IsZero() {
+				amount = requested
+			}
+		}
+		if amount.Amount <= 0 {
+			return fmt.Errorf("%w: nothing left to refund", ErrInvalidAmount)
+		}
+
+		refund = models.Refund{
+			ID:             uuid.New(),
+			RefundNo:       generateRefundNo(),
+			OrderID:        order.ID,
+			OrderItemID:    req.OrderItemID,
+			TransactionID:  txn.ID,
+			PaymentChannel: txn.PaymentChannel,
+			Amount:         amount,
+			Reason:         req.Reason,
+			Status:         models.RefundStatusPending,
+			OperatorID:     req.OperatorID,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return fmt.Errorf("failed to create refund: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &refund, submit(cfg, &order, &refund)
+}
+
+// Retry 以原退款单号重新提交仍在处理中的退款。网关按退款单号去重，已受理的退款不会重复退款，
+// 用于网关超时或5xx后确认退款结果
+func Retry(cfg config.Config, refundID uuid.UUID) (*models.Refund, error) {
+	db := models.GetDB()
+
+	var refund models.Refund
+	if err := db.First(&refund, "id = ?", refundID).Error; err != nil {
+		return nil, err
+	}
+	if refund.Status != models.RefundStatusPending {
+		return &refund, ErrRefundNotPending
+	}
+	var order models.Order
+	if err := db.First(&order, "id = ?", refund.OrderID).Error; err != nil {
+		return nil, err
+	}
+	return &refund, submit(cfg, &order, &refund)
+}
+
+// submit 向支付网关提交退款并应用同步结果。只有网关明确拒绝时标记为失败并释放可退金额；
+// 超时、5xx等结果未知的错误保持处理中，等待异步通知或以同一退款单号重试，避免重复退款
+func submit(cfg config.Config, order *models.Order, refund *models.Refund) error {
+	db := models.GetDB()
+
+	var txn models.Transaction
+	if err := db.First(&txn, "id = ?", refund.TransactionID).Error; err != nil {
+		return err
+	}
+
+	service, err := payment.GetPaymentService(payment.PaymentType(refund.PaymentChannel), cfg)
+	if err != nil {
+		// 网关未启用，退款请求没有发出
+		markFailed(refund, err)
+		return err
+	}
+
+	result, err := service.Refund(&payment.RefundRequest{
+		OrderNo:     order.OrderNo,
+		TradeNo:     txn.TransactionID,
+		PaymentRef:  order.PaymentRef,
+		RefundNo:    refund.RefundNo,
+		Amount:      refund.Amount,
+		TotalAmount: order.Total,
+		Reason:      refund.Reason,
+	})
+	if err != nil {
+		if errors.Is(err, payment.ErrRefundRejected) {
+			markFailed(refund, err)
+		} else {
+			log.Printf("Refund %s outcome unknown, left pending: %v", refund.RefundNo, err)
+			db.Model(refund).Update("raw_response", err.Error())
+		}
+		return err
+	}
+
+	if err := ApplyResult(result); err != nil {
+		return err
+	}
+
+	db.First(refund, "id = ?", refund.ID)
+	return nil
+}
+
+// ApplyResult 根据网关同步应答或异步通知更新退款状态；重复通知不会重复入账
+func ApplyResult(result *payment.RefundResult) error {
+	db := models.GetDB()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var refund models.Refund
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refund_no = ?", result.RefundNo).First(&refund).Error; err != nil {
+			return fmt.Errorf("refund %s not found: %w", result.RefundNo, err)
+		}
+
+		// 已是终态则忽略
+		if refund.Status != models.RefundStatusPending {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"status":       result.Status,
+			"raw_response": encodeParams(result.RawParams),
+		}
+		if result.ProviderRefundID != "" {
+			updates["provider_refund_id"] = result.ProviderRefundID
+		}
+
+		switch result.Status {
+		case models.RefundStatusPending:
+			delete(updates, "status")
+			return tx.Model(&refund).Updates(updates).Error
+		case models.RefundStatusFailed:
+			log.Printf("Refund %s failed at provider", refund.RefundNo)
+			return tx.Model(&refund).Updates(updates).Error
+		}
+
+		now := time.Now()
+		updates["completed_at"] = &now
+		if err := tx.Model(&refund).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		return settleOrder(tx, &refund, now)
+	})
+}
+
+// settleOrder 退款成功后累计订单退款金额并记入总账，扣回发布者分成，全额退款的商品撤销使用权
+func settleOrder(tx *gorm.DB, refund *models.Refund, now time.Time) error {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Items").First(&order, "id = ?", refund.OrderID).Error; err != nil {
+		return err
+	}
+
+	refundedBefore := order.Refunded.Amount
+	order.Refunded = models.NewMoney(order.Refunded.Amount+refund.Amount.Amount, order.Total.Currency)
+	if err := tx.Model(&order).Updates(map[string]interface{}{
+		"refunded_amount_minor": order.Refunded.Amount,
+		"refunded_currency":     order.Refunded.Currency,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := ledger.RecordRefund(tx, refund); err != nil {
+		return err
+	}
+	if err := ledger.RecordTaxRefund(tx, refund, refundedTax(&order, refundedBefore)); err != nil {
+		return err
+	}
+
+	// 按退款分摊额扣回发布者分成
+	if err := earnings.Clawback(tx, &order, refund, order.Refunded.Amount >= order.Total.Amount); err != nil {
+		return err
+	}
+
+	if order.Refunded.Amount >= order.Total.Amount {
+		// 整单退完：订单状态变为已退款，所有商品撤销权限
+		if err := orders.Transition(tx, &order, models.OrderStatusRefunded, orders.SourceRefund, refund.RefundNo, refund.Reason); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.OrderItem{}).
+			Where("order_id = ? AND refunded_at IS NULL", order.ID).
+			Update("refunded_at", now).Error; err != nil {
+			return err
+		}
+	} else if refund.OrderItemID != nil {
+		// 单个商品退完：撤销该商品的下载权限
+		for _, item := range order.Items {
+			if item.ID != *refund.OrderItemID {
+				continue
+			}
+			var itemRefunded int64
+			tx.Model(&models.Refund{}).
+				Where("order_item_id = ? AND status = ?", item.ID, models.RefundStatusSuccess).
+				Select("COALESCE(SUM(amount_minor), 0)").Scan(&itemRefunded)
+			if itemRefunded >= itemTotal(&item).Amount {
+				if err := tx.Model(&item).Update("refunded_at", now).Error; err != nil {
+					return err
+				}
+				if item.SkillID != nil {
+					if err := entitlement.RevokeOrder(tx, order.ID, item.SkillID, "item refunded"); err != nil {
+						return err
+					}
+				}
+				if order.IsGift {
+					if err := gift.Refund(tx, order.ID, &item.ID, "item refunded"); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// RefundableAmount 计算订单（或订单中某个商品）剩余可退金额，已申请但未完成的退款也计入
+func RefundableAmount(order *models.Order, orderItemID *uuid.UUID) (models.Money, error) {
+	remaining := order.Total.Amount
+	for _, r := range order.Refunds {
+		if r.Status != models.RefundStatusFailed {
+			remaining -= r.Amount.Amount
+		}
+	}
+
+	if orderItemID != nil {
+		var item *models.OrderItem
+		for i := range order.Items {
+			if order.Items[i].ID == *orderItemID {
+				item = &order.Items[i]
+				break
+			}
+		}
+		if item == nil {
+			return models.Money{}, fmt.Errorf("%w: item not in order", ErrOrderNotRefundable)
+		}
+		if item.RefundedAt != nil {
+			return models.Money{}, fmt.Errorf("%w: item already refunded", ErrOrderNotRefundable)
+		}
+
+		itemRemaining := itemTotal(item).Amount
+		for _, r := range order.Refunds {
+			if r.Status != models.RefundStatusFailed && r.OrderItemID != nil && *r.OrderItemID == item.ID {
+				itemRemaining -= r.Amount.Amount
+			}
+		}
+		if itemRemaining < remaining {
+			remaining = itemRemaining
+		}
+	}
+
+	if remaining < 0 {
+		remaining = 0
+	}
+	return models.NewMoney(remaining, order.Total.Currency), nil
+}
+
+// markFailed 网关明确拒绝（或退款请求未发出）时标记退款失败
+func markFailed(refund *models.Refund, cause error) {
+	refund.Status = models.RefundStatusFailed
+	models.GetDB().Model(refund).Updates(map[string]interface{}{
+		"status":       models.RefundStatusFailed,
+		"raw_response": cause.Error(),
+	})
+}
+
+// itemTotal 商品实付金额（扣除分摊的折扣，含价外税）
+func itemTotal(item *models.OrderItem) models.Money {
+	return item.Total()
+}
+
+// refundedTax 本次退款中包含的税额：按累计退款金额占实付金额的比例计算后减去此前已冲回的部分，
+// 多次部分退款的尾差不会累积，全额退款时恰好冲回全部税额
+func refundedTax(order *models.Order, refundedBefore int64) models.Money {
+	taxShare := func(refunded int64) int64 {
+		if order.Total.Amount <= 0 {
+			return 0
+		}
+		if refunded >= order.Total.Amount {
+			return order.Tax.Amount
+		}
+		return (refunded*order.Tax.Amount + order.Total.Amount/2) / order.Total.Amount
+	}
+	return models.NewMoney(taxShare(order.Refunded.Amount)-taxShare(refundedBefore), order.Total.Currency)
+}
+
+// generateRefundNo 生成退款单号
+func generateRefundNo() string {
+	return "RF" + time.Now().Format("20060102150405") + strings.ToUpper(uuid.New().String()[:8])
+}
+
+// encodeParams 序列化网关原始参数
+func encodeParams(params url.Values) string {
+	if params == nil {
+		return ""
+	}
+	return params.Encode()
+}
@@ -0,0 +1,76 @@
+package refund
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+)
+
+func TestRefundableAmount(t *testing.T) {
+	itemA := models.OrderItem{ID: uuid.New(), Price: models.NewMoney(3000, "CNY"), Quantity: 1}
+	itemB := models.OrderItem{ID: uuid.New(), Price: models.NewMoney(1999, "CNY"), Quantity: 1}
+	order := &models.Order{
+		Total: models.NewMoney(4999, "CNY"),
+		Items: []models.OrderItem{itemA, itemB},
+		Refunds: []models.Refund{
+			{OrderItemID: &itemA.ID, Amount: models.NewMoney(1000, "CNY"), Status: models.RefundStatusSuccess},
+			{OrderItemID: &itemB.ID, Amount: models.NewMoney(500, "CNY"), Status: models.RefundStatusFailed},
+		},
+	}
+
+	amount, err := RefundableAmount(order, nil)
+	if err != nil || amount != models.NewMoney(3999, "CNY") {
+		t.Errorf("expected 39.99 refundable for order, got %v (%v)", amount, err)
+	}
+
+	amount, err = RefundableAmount(order, &itemA.ID)
+	if err != nil || amount != models.NewMoney(2000, "CNY") {
+		t.Errorf("expected 20 refundable for item A, got %v (%v)", amount, err)
+	}
+
+	// 失败的退款不占用可退金额
+	amount, err = RefundableAmount(order, &itemB.ID)
+	if err != nil || amount != models.NewMoney(1999, "CNY") {
+		t.Errorf("expected 19.99 refundable for item B, got %v (%v)", amount, err)
+	}
+
+	now := time.Now()
+	order.Items[1].RefundedAt = &now
+	if _, err := RefundableAmount(order, &itemB.ID); !errors.Is(err, ErrOrderNotRefundable) {
+		t.Errorf("expected refunded item to be rejected, got %v", err)
+	}
+
+	missing := uuid.New()
+	if _, err := RefundableAmount(order, &missing); !errors.Is(err, ErrOrderNotRefundable) {
+		t.Errorf("expected unknown item to be rejected, got %v", err)
+	}
+}
+
+func TestRefundedTax(t *testing.T) {
+	// 实付 1070 其中价外税 70
+	order := &models.Order{Total: models.NewMoney(1070, "USD"), Tax: models.NewMoney(70, "USD")}
+
+	var reversed int64
+	for _, amount := range []int64{333, 333, 404} {
+		before := order.Refunded.Amount
+		order.Refunded = models.NewMoney(before+amount, "USD")
+		reversed += refundedTax(order, before).Amount
+	}
+	if reversed != 70 {
+		t.Errorf("partial refunds reversed %d tax, want 70", reversed)
+	}
+
+	order.Refunded = models.NewMoney(535, "USD")
+	if got := refundedTax(order, 0); got != models.NewMoney(35, "USD") {
+		t.Errorf("half refund reversed %v, want 0.35", got)
+	}
+
+	order.Tax = models.NewMoney(0, "USD")
+	if got := refundedTax(order, 0); !got.IsZero() {
+		t.Errorf("untaxed order reversed %v", got)
+	}
+}
@@ -0,0 +1,210 @@
+// Package risk 下单风控：按用户、IP和支付卡指纹统计时间窗口内的下单次数，
+// 超出限额的规则累计风险分，达到审核阈值的订单挂起待人工审核，达到拦截阈值的直接拒绝
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"skillhub/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrBlocked 风险分达到拦截阈值，拒绝下单
+var ErrBlocked = errors.New("order blocked by risk checks, please try again later")
+
+// MaxScore 风险分上限
+const MaxScore = 100
+
+// Subject 频率统计的维度
+type Subject string
+
+const (
+	SubjectUser Subject = "user" // 同一用户
+	SubjectIP   Subject = "ip"   // 同一客户端IP
+	SubjectCard Subject = "card" // 同一支付卡指纹
+)
+
+// column 维度对应的订单字段
+func (s Subject) column() string {
+	switch s {
+	case SubjectUser:
+		return "user_id"
+	case SubjectIP:
+		return "client_ip"
+	case SubjectCard:
+		return "card_fingerprint"
+	}
+	return ""
+}
+
+// Rule 频率规则：Window内同一维度已下单Limit次及以上时计Score分
+type Rule struct {
+	Subject Subject
+	Window  time.Duration
+	Limit   int
+	Score   int
+}
+
+// String 规则的配置写法，如 user:10m:5:40
+func (r Rule) String() string {
+	return fmt.Sprintf("%s:%s:%d:%d", r.Subject, r.Window, r.Limit, r.Score)
+}
+
+// Policy 风控策略。ReviewScore或BlockScore为0表示不启用对应处置
+type Policy struct {
+	Rules       []Rule
+	ReviewScore int
+	BlockScore  int
+}
+
+var (
+	mu     sync.RWMutex
+	policy Policy
+)
+
+// SetPolicy 替换当前使用的风控策略
+func SetPolicy(p Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	policy = p
+}
+
+// CurrentPolicy 当前使用的风控策略，未设置时没有规则（不拦截）
+func CurrentPolicy() Policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return policy
+}
+
+// ParseRules 解析逗号分隔的规则列表，每条为 subject:window:limit:score，如 "user:10m:5:40,ip:1h:30:50"
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("risk rule %q: want subject:window:limit:score", part)
+		}
+		rule := Rule{Subject: Subject(strings.ToLower(strings.TrimSpace(fields[0])))}
+		if rule.Subject.column() == "" {
+			return nil, fmt.Errorf("risk rule %q: unknown subject %q", part, fields[0])
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(fields[1]))
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("risk rule %q: invalid window %q", part, fields[1])
+		}
+		rule.Window = window
+		if rule.Limit, err = strconv.Atoi(strings.TrimSpace(fields[2])); err != nil || rule.Limit < 1 {
+			return nil, fmt.Errorf("risk rule %q: limit must be a positive integer", part)
+		}
+		if rule.Score, err = strconv.Atoi(strings.TrimSpace(fields[3])); err != nil || rule.Score < 1 || rule.Score > MaxScore {
+			return nil, fmt.Errorf("risk rule %q: score must be between 1 and %d", part, MaxScore)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Validate 检查阈值：拦截阈值应高于审核阈值
+func (p Policy) Validate() error {
+	if p.ReviewScore < 0 || p.ReviewScore > MaxScore || p.BlockScore < 0 || p.BlockScore > MaxScore {
+		return fmt.Errorf("risk thresholds must be between 0 and %d", MaxScore)
+	}
+	if p.ReviewScore > 0 && p.BlockScore > 0 && p.BlockScore <= p.ReviewScore {
+		return fmt.Errorf("risk block score %d must be higher than review score %d", p.BlockScore, p.ReviewScore)
+	}
+	return nil
+}
+
+// Decision 风控处置
+type Decision string
+
+const (
+	DecisionAllow  Decision = "allow"
+	DecisionReview Decision = "review"
+	DecisionBlock  Decision = "block"
+)
+
+// Signals 下单时采集的风控信号，为空的维度不参与统计
+type Signals struct {
+	UserID          uuid.UUID
+	IP              string
+	CardFingerprint string
+}
+
+// value 维度在本次下单中的取值
+func (s Signals) value(subject Subject) interface{} {
+	switch subject {
+	case SubjectUser:
+		if s.UserID != uuid.Nil {
+			return s.UserID
+		}
+	case SubjectIP:
+		if s.IP != "" {
+			return s.IP
+		}
+	case SubjectCard:
+		if s.CardFingerprint != "" {
+			return s.CardFingerprint
+		}
+	}
+	return nil
+}
+
+// Assessment 风控评估结果
+type Assessment struct {
+	Score    int      `json:"score"`
+	Reasons  []string `json:"reasons,omitempty"`
+	Decision Decision `json:"decision"`
+}
+
+// Evaluate 按规则计分：count返回规则窗口内该维度已有的下单次数，返回-1表示该维度无信号
+func (p Policy) Evaluate(count func(Rule) (int64, error)) (*Assessment, error) {
+	result := &Assessment{Decision: DecisionAllow}
+	for _, rule := range p.Rules {
+		n, err := count(rule)
+		if err != nil {
+			return nil, err
+		}
+		if n < int64(rule.Limit) {
+			continue
+		}
+		result.Score += rule.Score
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%d orders from the same %s within %s (limit %d)", n, rule.Subject, rule.Window, rule.Limit))
+	}
+	if result.Score > MaxScore {
+		result.Score = MaxScore
+	}
+	switch {
+	case p.BlockScore > 0 && result.Score >= p.BlockScore:
+		result.Decision = DecisionBlock
+	case p.ReviewScore > 0 && result.Score >= p.ReviewScore:
+		result.Decision = DecisionReview
+	}
+	return result, nil
+}
+
+// Assess 按当前策略统计各维度在窗口内的订单数（含未支付和已取消的订单）并评估风险
+func Assess(db *gorm.DB, signals Signals, now time.Time) (*Assessment, error) {
+	return CurrentPolicy().Evaluate(func(rule Rule) (int64, error) {
+		value := signals.value(rule.Subject)
+		if value == nil {
+			return -1, nil
+		}
+		var n int64
+		err := db.Model(&models.Order{}).
+			Where(rule.Subject.column()+" = ? AND created_at >= ?", value, now.Add(-rule.Window)).
+			Count(&n).Error
+		return n, err
+	})
+}
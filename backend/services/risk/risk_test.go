@@ -0,0 +1,83 @@
+package risk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("user:10m:5:40, ip:1h:30:50,card:24h:3:60,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("parsed %d rules, want 3", len(rules))
+	}
+	if got := rules[1]; got.Subject != SubjectIP || got.Window != time.Hour || got.Limit != 30 || got.Score != 50 {
+		t.Errorf("rules[1] = %+v", got)
+	}
+	if got := rules[0].String(); got != "user:10m0s:5:40" {
+		t.Errorf("String() = %q", got)
+	}
+
+	bad := []string{
+		"user:10m:5",
+		"device:10m:5:40",
+		"user:soon:5:40",
+		"user:10m:0:40",
+		"user:10m:5:101",
+	}
+	for _, spec := range bad {
+		if _, err := ParseRules(spec); err == nil {
+			t.Errorf("ParseRules(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	if err := (Policy{ReviewScore: 50, BlockScore: 80}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (Policy{ReviewScore: 50}).Validate(); err != nil {
+		t.Errorf("Validate() without block = %v", err)
+	}
+	if err := (Policy{ReviewScore: 80, BlockScore: 80}).Validate(); err == nil {
+		t.Error("Validate() accepted block score not above review score")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policy := Policy{
+		Rules: []Rule{
+			{Subject: SubjectUser, Window: time.Minute, Limit: 3, Score: 40},
+			{Subject: SubjectIP, Window: time.Hour, Limit: 10, Score: 30},
+			{Subject: SubjectCard, Window: time.Hour, Limit: 2, Score: 60},
+		},
+		ReviewScore: 50,
+		BlockScore:  80,
+	}
+	tests := []struct {
+		name   string
+		counts map[Subject]int64
+		score  int
+		want   Decision
+	}{
+		{"under limits", map[Subject]int64{SubjectUser: 2, SubjectIP: 9, SubjectCard: 1}, 0, DecisionAllow},
+		{"one rule", map[Subject]int64{SubjectUser: 3, SubjectIP: 0, SubjectCard: 0}, 40, DecisionAllow},
+		{"review", map[Subject]int64{SubjectUser: 3, SubjectIP: 10, SubjectCard: 0}, 70, DecisionReview},
+		{"block", map[Subject]int64{SubjectUser: 5, SubjectIP: 0, SubjectCard: 2}, 100, DecisionBlock},
+		{"no card signal", map[Subject]int64{SubjectUser: 0, SubjectIP: 0, SubjectCard: -1}, 0, DecisionAllow},
+	}
+	for _, tt := range tests {
+		got, err := policy.Evaluate(func(rule Rule) (int64, error) { return tt.counts[rule.Subject], nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Score != tt.score || got.Decision != tt.want {
+			t.Errorf("%s: got score %d %s, want %d %s", tt.name, got.Score, got.Decision, tt.score, tt.want)
+		}
+		if (got.Score > 0) != (len(got.Reasons) > 0) {
+			t.Errorf("%s: reasons %v do not match score %d", tt.name, got.Reasons, got.Score)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package scheduler
 import (
 	"log"
 	"skillhub/models"
+	"skillhub/services/auth"
 	"skillhub/services/crawler"
+	"skillhub/services/gift"
+	"skillhub/services/orders"
+	"skillhub/services/reconcile"
 
 	"github.com/robfig/cron/v3"
 )
@@ -44,7 +48,7 @@ func loadScheduledTasks() {
 func AddTask(taskID, cronExpression string) error {
 	_, err := GlobalScheduler.cron.AddFunc(cronExpression, func() {
 		log.Printf("Executing scheduled task: %s", taskID)
-		if err := crawler.RunScheduledTask(taskID); err != nil {
+		if err := runTask(taskID); err != nil {
 			log.Printf("Error executing task %s: %v", taskID, err)
 		}
 	})
@@ -58,6 +62,24 @@ func AddTask(taskID, cronExpression string) error {
 	return nil
 }
 
+// runTask 按任务名分发定时任务
+func runTask(taskID string) error {
+	switch taskID {
+	case "payment_reconcile":
+		return reconcile.RunScheduled()
+	case "order_expire":
+		return orders.RunExpireScheduled()
+	case "gift_expire":
+		return gift.RunExpireScheduled()
+	case "subscription_renewal":
+		return orders.RunSubscriptionScheduled()
+	case "session_cleanup":
+		return auth.RunSessionCleanupScheduled()
+	default:
+		return crawler.RunScheduledTask(taskID)
+	}
+}
+
 // RemoveTask 移除定时任务
 func RemoveTask(taskID string) {
 	// Note: cron.Cron doesn't provide direct removal by task ID
@@ -71,24 +93,57 @@ func (s *Scheduler) Stop() {
 	log.Println("Scheduler stopped")
 }
 
-// InitDefaultTasks 初始化默认定时任务
+// InitDefaultTasks 初始化默认定时任务，已存在的同名任务保持不变
 func InitDefaultTasks() {
 	db := models.GetDB()
 
+	for _, task := range defaultTasks() {
+		var existingTask models.ScheduledTask
+		if err := db.Where("task_name = ?", task.TaskName).First(&existingTask).Error; err != nil {
+			db.Create(&task)
+		}
+	}
+}
+
+// defaultTasks 默认定时任务，任务名需在runTask中有对应处理
+func defaultTasks() []models.ScheduledTask {
 	// 默认每日凌晨3点同步
-	tasks := []models.ScheduledTask{
+	return []models.ScheduledTask{
 		{
 			TaskName:       "daily_sync",
 			CronExpression: "0 3 * * *", // 每天3点
 			IsActive:       true,
 			Description:    "自动从GitHub同步Skills数据",
 		},
-	}
-
-	for _, task := range tasks {
-		var existingTask models.ScheduledTask
-		if err := db.Where("task_name = ?", task.TaskName).First(&existingTask).Error; err != nil {
-			db.Create(&task)
-		}
+		{
+			TaskName:       "payment_reconcile",
+			CronExpression: "*/30 * * * *", // 每30分钟
+			IsActive:       true,
+			Description:    "向支付网关查询近期订单状态，修正丢失回调的订单并记录差异",
+		},
+		{
+			TaskName:       "order_expire",
+			CronExpression: "15 * * * *", // 每小时
+			IsActive:       true,
+			Description:    "取消超过有效期仍未支付的订单并释放优惠券",
+		},
+		{
+			TaskName:       "gift_expire",
+			CronExpression: "20 * * * *", // 每小时
+			IsActive:       true,
+			Description:    "将超过兑换期限的礼品兑换码标记为过期",
+		},
+		{
+			TaskName:       "subscription_renewal",
+			CronExpression: "45 * * * *", // 每小时
+			IsActive:       true,
+			Description:    "为即将到期的订阅生成续费订单，宽限期结束仍未续费的订阅失效",
+		},
+		{
+			TaskName:       "session_cleanup",
+			CronExpression: "30 4 * * *", // 每天4:30
+			IsActive:       true,
+			Description:    "删除过期或撤销超过7天的登录会话和刷新令牌",
+		},
 	}
 }
@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestDefaultTasks(t *testing.T) {
+	tasks := make(map[string]string)
+	for _, task := range defaultTasks() {
+		if !task.IsActive {
+			t.Errorf("default task %s is not active", task.TaskName)
+		}
+		if _, err := cron.ParseStandard(task.CronExpression); err != nil {
+			t.Errorf("default task %s has invalid cron %q: %v", task.TaskName, task.CronExpression, err)
+		}
+		tasks[task.TaskName] = task.CronExpression
+	}
+	for _, name := range []string{"payment_reconcile", "order_expire", "gift_expire", "subscription_renewal", "session_cleanup"} {
+		if _, ok := tasks[name]; !ok {
+			t.Errorf("default tasks missing %s", name)
+		}
+	}
+}
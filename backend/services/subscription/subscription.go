@@ -0,0 +1,230 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"skillhub/config"
+	"skillhub/models"
+	"skillhub/services/entitlement"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPlanNotFound 订阅方案不存在或已停用
+	ErrPlanNotFound = errors.New("subscription plan not found")
+	// ErrNotSubscription 技能不是按订阅计费
+	ErrNotSubscription = errors.New("skill is not sold by subscription")
+	// ErrAlreadySubscribed 用户已有该技能的有效订阅
+	ErrAlreadySubscribed = errors.New("already subscribed to this skill")
+	// ErrNotFound 订阅不存在
+	ErrNotFound = errors.New("subscription not found")
+	// ErrNotCancellable 订阅已取消或已失效
+	ErrNotCancellable = errors.New("subscription is already cancelled or expired")
+)
+
+// GracePeriod 订阅当期结束后的宽限期
+func GracePeriod() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.Payment.SubscriptionGrace > 0 {
+		return config.AppConfig.Payment.SubscriptionGrace
+	}
+	return 72 * time.Hour
+}
+
+// RenewalLead 当期结束前多久生成续费订单
+func RenewalLead() time.Duration {
+	if config.AppConfig != nil && config.AppConfig.Payment.SubscriptionRenewalLead > 0 {
+		return config.AppConfig.Payment.SubscriptionRenewalLead
+	}
+	return 72 * time.Hour
+}
+
+// LoadPlan 加载可订阅的方案及其技能
+func LoadPlan(db *gorm.DB, planID uuid.UUID) (*models.SubscriptionPlan, error) {
+	var plan models.SubscriptionPlan
+	err := db.Preload("Skill").Where("id = ? AND is_active = ?", planID, true).First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if plan.Skill == nil || !plan.Skill.IsActive {
+		return nil, ErrPlanNotFound
+	}
+	if plan.Skill.PriceType != models.PriceTypeSubscription {
+		return nil, fmt.Errorf("%w: %s", ErrNotSubscription, plan.Skill.Name)
+	}
+	return &plan, nil
+}
+
+// Current 用户对技能的当前订阅（未失效），没有时返回nil
+func Current(db *gorm.DB, userID, skillID uuid.UUID) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := db.Where("user_id = ? AND skill_id = ? AND status IN ?", userID, skillID, []models.SubscriptionStatus{
+		models.SubscriptionStatusIncomplete, models.SubscriptionStatusActive,
+		models.SubscriptionStatusPastDue, models.SubscriptionStatusCancelled,
+	}).Order("created_at DESC").First(&sub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Lock 在事务中加行锁读取订阅
+func Lock(tx *gorm.DB, id uuid.UUID) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sub, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// FindByRef 按网关订阅ID查找订阅
+func FindByRef(db *gorm.DB, provider, ref string) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := db.Where("provider = ? AND provider_ref = ?", provider, ref).First(&sub).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Link 记录网关侧订阅ID（首期支付或授权通知时）
+func Link(tx *gorm.DB, id uuid.UUID, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	return tx.Model(&models.Subscription{}).Where("id = ? AND (provider_ref IS NULL OR provider_ref = '')", id).
+		Update("provider_ref", ref).Error
+}
+
+// NextPeriod 支付后的计费周期：当期尚未结束时从当期结束顺延，否则从支付时间开始
+func NextPeriod(sub *models.Subscription, interval models.BillingInterval, paidAt time.Time) (time.Time, time.Time) {
+	start := paidAt
+	if sub.Status != models.SubscriptionStatusExpired && sub.CurrentPeriodEnd != nil && sub.CurrentPeriodEnd.After(paidAt) {
+		start = *sub.CurrentPeriodEnd
+	}
+	return start, interval.AddTo(start)
+}
+
+// Extend 订阅订单（首期或续费）支付成功后激活订阅并顺延一个计费周期，调用方应在事务中
+func Extend(tx *gorm.DB, id uuid.UUID, paidAt time.Time) error {
+	sub, err := Lock(tx, id)
+	if err != nil {
+		return err
+	}
+	var plan models.SubscriptionPlan
+	if err := tx.First(&plan, "id = ?", sub.PlanID).Error; err != nil {
+		return err
+	}
+
+	start, end := NextPeriod(sub, plan.Interval, paidAt)
+	if err := tx.Model(sub).Updates(map[string]interface{}{
+		"status":               models.SubscriptionStatusActive,
+		"current_period_start": start,
+		"current_period_end":   end,
+		"cancelled_at":         nil,
+	}).Error; err != nil {
+		return err
+	}
+	sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CancelledAt = models.SubscriptionStatusActive, &start, &end, nil
+	return entitlement.SyncSubscription(tx, sub, GracePeriod())
+}
+
+// Abandon 首期订单取消或过期时，未激活的订阅随之失效
+func Abandon(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Model(&models.Subscription{}).
+		Where("id = ? AND status = ?", id, models.SubscriptionStatusIncomplete).
+		Update("status", models.SubscriptionStatusExpired).Error
+}
+
+// MarkPastDue 续费扣款失败，订阅进入宽限期
+func MarkPastDue(tx *gorm.DB, id uuid.UUID) error {
+	return tx.Model(&models.Subscription{}).
+		Where("id = ? AND status = ?", id, models.SubscriptionStatusActive).
+		Update("status", models.SubscriptionStatusPastDue).Error
+}
+
+// Cancel 取消订阅：不再续费，已支付的当期结束前仍可使用
+func Cancel(tx *gorm.DB, sub *models.Subscription, now time.Time) error {
+	switch sub.Status {
+	case models.SubscriptionStatusCancelled, models.SubscriptionStatusExpired:
+		return ErrNotCancellable
+	}
+	status := models.SubscriptionStatusCancelled
+	if sub.CurrentPeriodEnd == nil {
+		// 首期未支付，直接失效
+		status = models.SubscriptionStatusExpired
+	}
+	if err := tx.Model(sub).Updates(map[string]interface{}{
+		"status":       status,
+		"cancelled_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	sub.Status, sub.CancelledAt = status, &now
+	// 取消后使用权截止到当期结束，不再享有宽限期
+	return entitlement.SyncSubscription(tx, sub, GracePeriod())
+}
+
+// Lapse 更新到期订阅的状态：当期已结束的订阅进入宽限期，宽限期或已取消订阅的当期结束后失效。
+// 返回本次失效的订阅ID
+func Lapse(db *gorm.DB, now time.Time, grace time.Duration) ([]uuid.UUID, error) {
+	if err := db.Model(&models.Subscription{}).
+		Where("status = ? AND current_period_end < ?", models.SubscriptionStatusActive, now).
+		Update("status", models.SubscriptionStatusPastDue).Error; err != nil {
+		return nil, err
+	}
+
+	var expired []uuid.UUID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Subscription{}).
+			Where("(status = ? AND current_period_end < ?) OR (status = ? AND current_period_end < ?)",
+				models.SubscriptionStatusPastDue, now.Add(-grace), models.SubscriptionStatusCancelled, now).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Pluck("id", &expired).Error; err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.Subscription{}).Where("id IN ?", expired).
+			Update("status", models.SubscriptionStatusExpired).Error; err != nil {
+			return err
+		}
+		for _, id := range expired {
+			if err := entitlement.RevokeSubscription(tx, id, "subscription expired"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return expired, err
+}
+
+// DueForRenewal 需要生成续费订单的订阅：不由网关自动续费、未取消、当期将在lead内结束，且没有待支付的续费订单
+func DueForRenewal(db *gorm.DB, now time.Time, lead time.Duration) ([]models.Subscription, error) {
+	var subs []models.Subscription
+	err := db.Preload("Plan").Preload("Skill").
+		Where("auto_renew = ? AND status IN ? AND current_period_end < ?", false,
+			[]models.SubscriptionStatus{models.SubscriptionStatusActive, models.SubscriptionStatusPastDue}, now.Add(lead)).
+		Where("NOT EXISTS (SELECT 1 FROM orders WHERE orders.subscription_id = subscriptions.id AND orders.status = ?)",
+			models.OrderStatusPending).
+		Find(&subs).Error
+	return subs, err
+}
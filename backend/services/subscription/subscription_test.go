@@ -0,0 +1,56 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"skillhub/models"
+)
+
+func TestNextPeriod(t *testing.T) {
+	paidAt := time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	// 首期从支付时间开始
+	sub := &models.Subscription{Status: models.SubscriptionStatusIncomplete}
+	start, end := NextPeriod(sub, models.BillingIntervalYear, paidAt)
+	if !start.Equal(paidAt) || !end.Equal(paidAt.AddDate(1, 0, 0)) {
+		t.Errorf("unexpected first period %s - %s", start, end)
+	}
+
+	// 提前续费从当期结束顺延
+	periodEnd := paidAt.Add(48 * time.Hour)
+	sub = &models.Subscription{Status: models.SubscriptionStatusActive, CurrentPeriodEnd: &periodEnd}
+	start, end = NextPeriod(sub, models.BillingIntervalMonth, paidAt)
+	if !start.Equal(periodEnd) || !end.Equal(periodEnd.AddDate(0, 1, 0)) {
+		t.Errorf("unexpected renewal period %s - %s", start, end)
+	}
+
+	// 宽限期内续费从支付时间开始，不补前一段
+	lapsed := paidAt.Add(-24 * time.Hour)
+	sub = &models.Subscription{Status: models.SubscriptionStatusPastDue, CurrentPeriodEnd: &lapsed}
+	if start, _ = NextPeriod(sub, models.BillingIntervalMonth, paidAt); !start.Equal(paidAt) {
+		t.Errorf("expected late renewal to start at payment time, got %s", start)
+	}
+}
+
+func TestAccessUntil(t *testing.T) {
+	end := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	grace := 72 * time.Hour
+
+	tests := []struct {
+		status models.SubscriptionStatus
+		want   time.Time
+	}{
+		{models.SubscriptionStatusActive, end.Add(grace)},
+		{models.SubscriptionStatusPastDue, end.Add(grace)},
+		{models.SubscriptionStatusCancelled, end},
+		{models.SubscriptionStatusExpired, time.Time{}},
+		{models.SubscriptionStatusIncomplete, time.Time{}},
+	}
+	for _, tt := range tests {
+		sub := &models.Subscription{Status: tt.status, CurrentPeriodEnd: &end}
+		if got := sub.AccessUntil(grace); !got.Equal(tt.want) {
+			t.Errorf("%s: expected access until %s, got %s", tt.status, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package tax 按国家/地区的税率规则计算订单税费。规则在启动时从CSV文件加载到内存，不依赖外部税务服务
+package tax
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"skillhub/models"
+)
+
+// Rule 一条税率规则。国家级规则（Region为空）适用于该国所有省/州，省/州规则叠加在国家级规则之上
+type Rule struct {
+	Country       string `json:"country"`
+	Region        string `json:"region,omitempty"`
+	Name          string `json:"name"`
+	RateBps       int    `json:"rate_bps"`
+	Inclusive     bool   `json:"inclusive"`      // 价内税：标价已含税，税额从标价中拆出
+	ReverseCharge bool   `json:"reverse_charge"` // 买方提供税号时（B2B）反向征收，不收取该项税款
+}
+
+// Rules 已加载的税率规则，按国家索引
+type Rules struct {
+	byCountry map[string][]Rule
+}
+
+var (
+	mu      sync.RWMutex
+	current = &Rules{}
+)
+
+// SetRules 替换当前使用的税率规则
+func SetRules(rules *Rules) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = rules
+}
+
+// Current 当前使用的税率规则，未加载时为空规则（不计税）
+func Current() *Rules {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// NewRules 校验并建立规则集：同一国家的规则必须同为价内税或价外税
+func NewRules(rules []Rule) (*Rules, error) {
+	set := &Rules{byCountry: make(map[string][]Rule)}
+	for _, rule := range rules {
+		rule.Country = strings.ToUpper(strings.TrimSpace(rule.Country))
+		rule.Region = strings.ToUpper(strings.TrimSpace(rule.Region))
+		rule.Name = strings.TrimSpace(rule.Name)
+		if rule.Country == "" || rule.Name == "" {
+			return nil, fmt.Errorf("tax rule requires country and name: %+v", rule)
+		}
+		if rule.RateBps < 0 || rule.RateBps > 10000 {
+			return nil, fmt.Errorf("tax rule %s %s: rate out of range", rule.Country, rule.Name)
+		}
+		for _, existing := range set.byCountry[rule.Country] {
+			if existing.Inclusive != rule.Inclusive {
+				return nil, fmt.Errorf("tax rules for %s mix inclusive and exclusive pricing", rule.Country)
+			}
+			if existing.Region == rule.Region && existing.Name == rule.Name {
+				return nil, fmt.Errorf("duplicate tax rule %s %s %s", rule.Country, rule.Region, rule.Name)
+			}
+		}
+		set.byCountry[rule.Country] = append(set.byCountry[rule.Country], rule)
+	}
+	return set, nil
+}
+
+// Match 下单地区适用的规则：国家级规则在前，省/州规则在后
+func (r *Rules) Match(country, region string) []Rule {
+	if r == nil {
+		return nil
+	}
+	var national, regional []Rule
+	for _, rule := range r.byCountry[strings.ToUpper(country)] {
+		switch rule.Region {
+		case "":
+			national = append(national, rule)
+		case strings.ToUpper(region):
+			regional = append(regional, rule)
+		}
+	}
+	return append(national, regional...)
+}
+
+// All 全部规则，按国家、省/州排序
+func (r *Rules) All() []Rule {
+	if r == nil {
+		return nil
+	}
+	var all []Rule
+	for _, rules := range r.byCountry {
+		all = append(all, rules...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Country != all[j].Country {
+			return all[i].Country < all[j].Country
+		}
+		return all[i].Region < all[j].Region
+	})
+	return all
+}
+
+// LoadFile 从CSV文件加载规则
+func LoadFile(path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := ParseRules(f)
+	if err != nil {
+		return nil, err
+	}
+	return NewRules(rules)
+}
+
+// ParseRules 解析税率CSV：每行 country,region,name,rate,pricing,reverse_charge。
+// rate为百分比（如 7.25），pricing为 inclusive 或 exclusive，reverse_charge为 true/false；#开头为注释，可带表头
+func ParseRules(r io.Reader) ([]Rule, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 6
+	reader.TrimLeadingSpace = true
+
+	var rules []Rule
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+
+		rate, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			if line == 1 {
+				// 表头
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, record[3])
+		}
+		var inclusive bool
+		switch strings.ToLower(record[4]) {
+		case "inclusive":
+			inclusive = true
+		case "exclusive":
+		default:
+			return nil, fmt.Errorf("line %d: pricing must be inclusive or exclusive, got %q", line, record[4])
+		}
+		reverseCharge, err := strconv.ParseBool(record[5])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid reverse_charge %q", line, record[5])
+		}
+		rules = append(rules, Rule{
+			Country:       record[0],
+			Region:        record[1],
+			Name:          record[2],
+			RateBps:       int(math.Round(rate * 100)),
+			Inclusive:     inclusive,
+			ReverseCharge: reverseCharge,
+		})
+	}
+	return rules, nil
+}
+
+// Result 一笔订单的计税结果
+type Result struct {
+	Inclusive bool
+	Lines     []models.TaxLine
+	ItemTax   []int64 // 与传入的金额一一对应的税额
+	Total     int64
+}
+
+// Calculate 按适用规则为各项金额（已扣除折扣）计税。价外税按各项金额乘以税率；
+// 价内税从金额中拆出，各项税额与不含税金额之和等于原金额。b2b为真时反向征收的规则不收税，只记录计税金额
+func Calculate(rules []Rule, amounts []int64, currency, country, region string, b2b bool) Result {
+	result := Result{ItemTax: make([]int64, len(amounts))}
+	if len(rules) == 0 {
+		return result
+	}
+	result.Inclusive = rules[0].Inclusive
+
+	charged := make([]bool, len(rules))
+	var chargedBps int
+	for j, rule := range rules {
+		charged[j] = !(b2b && rule.ReverseCharge) && rule.RateBps > 0
+		if charged[j] {
+			chargedBps += rule.RateBps
+		}
+	}
+
+	taxable := make([]int64, len(rules))
+	amount := make([]int64, len(rules))
+	for i, base := range amounts {
+		if base <= 0 {
+			continue
+		}
+		net := base
+		if result.Inclusive && chargedBps > 0 {
+			net = roundDiv(base*10000, int64(10000+chargedBps))
+		}
+
+		var itemTax int64
+		lastJ := -1
+		for j, rule := range rules {
+			taxable[j] += net
+			if !charged[j] {
+				continue
+			}
+			share := roundDiv(net*int64(rule.RateBps), 10000)
+			amount[j] += share
+			itemTax += share
+			lastJ = j
+		}
+		// 价内税的尾差计入最后一项税费，保证不含税金额与税额之和等于标价
+		if result.Inclusive && lastJ >= 0 {
+			diff := base - net - itemTax
+			amount[lastJ] += diff
+			itemTax += diff
+		}
+		result.ItemTax[i] = itemTax
+		result.Total += itemTax
+	}
+
+	for j, rule := range rules {
+		result.Lines = append(result.Lines, models.TaxLine{
+			Name:          rule.Name,
+			Country:       country,
+			Region:        rule.Region,
+			RateBps:       rule.RateBps,
+			Inclusive:     rule.Inclusive,
+			ReverseCharge: b2b && rule.ReverseCharge,
+			Taxable:       models.NewMoney(taxable[j], currency),
+			Amount:        models.NewMoney(amount[j], currency),
+		})
+	}
+	return result
+}
+
+// roundDiv 四舍五入的整数除法（非负数）
+func roundDiv(a, b int64) int64 {
+	return (2*a + b) / (2 * b)
+}
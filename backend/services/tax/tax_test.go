@@ -0,0 +1,112 @@
+package tax
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleRules = `country,region,name,rate,pricing,reverse_charge
+# 欧盟增值税
+DE,,VAT,19,inclusive,true
+CA,,GST,5,exclusive,false
+CA,QC,QST,9.975,exclusive,false
+US,CA,Sales tax,7.25,exclusive,false
+`
+
+func loadSample(t *testing.T) *Rules {
+	t.Helper()
+	parsed, err := ParseRules(strings.NewReader(sampleRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := NewRules(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestParseRules(t *testing.T) {
+	rules := loadSample(t)
+	if got := len(rules.All()); got != 4 {
+		t.Fatalf("loaded %d rules, want 4", got)
+	}
+	qc := rules.Match("CA", "QC")
+	if len(qc) != 2 || qc[0].Name != "GST" || qc[1].RateBps != 998 {
+		t.Errorf("Match(CA, QC) = %+v", qc)
+	}
+	if got := rules.Match("CA", "ON"); len(got) != 1 {
+		t.Errorf("Match(CA, ON) = %+v, want only GST", got)
+	}
+	if got := rules.Match("US", ""); len(got) != 0 {
+		t.Errorf("Match(US) = %+v, want none", got)
+	}
+
+	bad := []string{
+		"DE,,VAT,19,inclusive,false\nFR,,VAT,abc,inclusive,false\n",
+		"DE,,VAT,19,gross,false\n",
+		"DE,,VAT,19,inclusive,maybe\n",
+		"DE,,VAT,19\n",
+	}
+	for _, in := range bad {
+		if _, err := ParseRules(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseRules(%q) succeeded, want error", in)
+		}
+	}
+
+	mixed, err := ParseRules(strings.NewReader("US,,Federal,1,inclusive,false\nUS,CA,Sales tax,7.25,exclusive,false\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRules(mixed); err == nil {
+		t.Error("NewRules accepted mixed inclusive and exclusive rules for one country")
+	}
+}
+
+func TestCalculateExclusive(t *testing.T) {
+	rules := loadSample(t).Match("CA", "QC")
+	result := Calculate(rules, []int64{1000, 333}, "CAD", "CA", "QC", false)
+
+	// GST: 50 + 16.65→17；QST: 99.75→100 + 33.22→33
+	if result.Inclusive {
+		t.Error("expected exclusive pricing")
+	}
+	if result.ItemTax[0] != 150 || result.ItemTax[1] != 50 || result.Total != 200 {
+		t.Errorf("item tax = %v, total = %d", result.ItemTax, result.Total)
+	}
+	if len(result.Lines) != 2 || result.Lines[0].Amount.Amount != 67 || result.Lines[1].Amount.Amount != 133 {
+		t.Errorf("tax lines = %+v", result.Lines)
+	}
+	if result.Lines[0].Taxable.Amount != 1333 || result.Lines[1].Region != "QC" {
+		t.Errorf("tax lines = %+v", result.Lines)
+	}
+}
+
+func TestCalculateInclusive(t *testing.T) {
+	rules := loadSample(t).Match("DE", "")
+	result := Calculate(rules, []int64{1190, 999, 0}, "EUR", "DE", "", false)
+
+	// 1190 含19%税 = 1000 + 190；999 = 839 + 160
+	if !result.Inclusive || result.ItemTax[0] != 190 || result.ItemTax[1] != 160 || result.ItemTax[2] != 0 {
+		t.Errorf("item tax = %v", result.ItemTax)
+	}
+	line := result.Lines[0]
+	if line.Amount.Amount != 350 || line.Taxable.Amount != 1839 || line.Amount.Amount+line.Taxable.Amount != 2189 {
+		t.Errorf("tax line = %+v", line)
+	}
+}
+
+func TestCalculateReverseCharge(t *testing.T) {
+	rules := loadSample(t).Match("DE", "")
+	result := Calculate(rules, []int64{1190}, "EUR", "DE", "", true)
+	if result.Total != 0 || result.ItemTax[0] != 0 {
+		t.Errorf("reverse charge collected tax %d", result.Total)
+	}
+	if line := result.Lines[0]; !line.ReverseCharge || line.Amount.Amount != 0 || line.Taxable.Amount != 1190 {
+		t.Errorf("tax line = %+v", line)
+	}
+
+	if result := Calculate(nil, []int64{1000}, "USD", "US", "", false); result.Total != 0 || len(result.Lines) != 0 {
+		t.Errorf("no rules produced tax %+v", result)
+	}
+}
@@ -8,6 +8,7 @@ import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@
 import { Badge } from "@/components/ui/badge"
 import { Input } from "@/components/ui/input"
 import api, { type User, type Order, type Analytics, type Skill } from "@/lib/api"
+import { formatPrice, formatPrices } from "@/lib/utils"
 import { Users, DollarSign, ShoppingCart, TrendingUp, Search, LayoutDashboard, Zap, CreditCard, Activity, ChevronRight } from "lucide-react"
 import { useI18n } from "@/contexts/i18n-context"
 import { useUser } from "@/contexts/user-context"
@@ -187,7 +188,7 @@ export default function AdminDashboard() {
             </div>
             <h3 className="text-sm text-slate-500 mb-2">{t.admin.totalRevenue || '总收入'}</h3>
             <div className="text-3xl font-bold text-slate-900 mb-1">
-              {analytics?.total_revenue ? `$${analytics.total_revenue.toFixed(2)}` : '$0.00'}
+              {formatPrices(analytics?.total_revenue)}
             </div>
             <p className="text-xs text-slate-400">
               {analytics?.today_orders || 0} {t.admin.todayOrders || '今日订单'}
@@ -293,7 +294,7 @@ export default function AdminDashboard() {
                     <TableRow key={order.id} className="border-slate-200/30 hover:bg-slate-100/20 transition-colors">
                       <TableCell className="font-medium text-slate-900">{order.order_no}</TableCell>
                       <TableCell className="text-slate-600">{order.user_email}</TableCell>
-                      <TableCell className="text-slate-900 font-semibold">${order.total_amount.toFixed(2)}</TableCell>
+                      <TableCell className="text-slate-900 font-semibold">{formatPrice(order.total)}</TableCell>
                       <TableCell>
                         <Badge variant={
                           order.status === 'paid' ? 'default' : 'secondary'
@@ -411,7 +412,7 @@ export default function AdminDashboard() {
                     <TableCell className="font-medium text-slate-900">{skill.name}</TableCell>
                     <TableCell className="text-slate-600">{skill.category?.name || '-'}</TableCell>
                     <TableCell className="text-slate-900 font-semibold">
-                      {skill.price_type === 'free' ? 'Free' : `$${skill.price?.toFixed(2)}`}
+                      {skill.price_type === 'free' ? 'Free' : formatPrice(skill.price)}
                     </TableCell>
                     <TableCell>
                       <Badge variant={skill.price_type === 'paid' ? 'default' : 'secondary'} className={
@@ -717,7 +718,7 @@ export default function AdminDashboard() {
                   <TableRow key={order.id} className="border-slate-200/30 hover:bg-slate-100/20 transition-colors">
                     <TableCell className="font-medium text-slate-900">{order.order_no}</TableCell>
                     <TableCell className="text-slate-600">{order.user?.email || order.user_email || '-'}</TableCell>
-                    <TableCell className="text-slate-900 font-semibold">${order.total_amount.toFixed(2)}</TableCell>
+                    <TableCell className="text-slate-900 font-semibold">{formatPrice(order.total)}</TableCell>
                     <TableCell>
                       <Badge variant={order.status === 'paid' ? 'default' : 'secondary'} className={
                         order.status === 'paid'
@@ -3,6 +3,7 @@
 import { useEffect, useState } from "react"
 import { useRouter, useSearchParams } from "next/navigation"
 import { useUser } from "@/contexts/user-context"
+import { authApi } from "@/lib/api"
 import { Card, CardContent } from "@/components/ui/card"
 
 export const dynamic = "force-dynamic"
@@ -18,6 +19,7 @@ export default function OAuthCallbackPage() {
   useEffect(() => {
     const provider = searchParams.get('provider')
     const code = searchParams.get('code')
+    const state = searchParams.get('state') || ''
     const token = searchParams.get('token')
     const userStr = searchParams.get('user')
 
@@ -25,7 +27,7 @@ export default function OAuthCallbackPage() {
     if (token && userStr) {
        try {
         const user = JSON.parse(decodeURIComponent(userStr))
-        loginUser(user, token)
+        loginUser(user, token, searchParams.get('refresh_token') || undefined)
         setStatus('success')
         setTimeout(() => {
           // 管理员跳转到管理后台，普通用户跳转到个人中心
@@ -45,7 +47,7 @@ export default function OAuthCallbackPage() {
       }
     } else if (code && provider) {
       // 如果有 code 和 provider，需要调用后端回调接口
-      handleOAuthCallback(provider, code)
+      handleOAuthCallback(provider, code, state)
     } else {
       setStatus('error')
       setErrorMessage('缺少授权参数')
@@ -55,23 +57,11 @@ export default function OAuthCallbackPage() {
     }
   }, [searchParams, router, loginUser])
 
-  const handleOAuthCallback = async (provider: string, code: string) => {
+  const handleOAuthCallback = async (provider: string, code: string, state: string) => {
     try {
-      const apiUrl = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
-      const response = await fetch(`${apiUrl}/api/v1/auth/callback/${provider}?code=${code}`, {
-        method: 'GET',
-        headers: {
-          'Content-Type': 'application/json',
-        },
-      })
-
-      if (!response.ok) {
-        throw new Error('OAuth callback failed')
-      }
-
-       const data = await response.json()
+      const data = await authApi.oauthCallback(provider, code, state)
       if (data.token && data.user) {
-        loginUser(data.user, data.token)
+        loginUser(data.user, data.token, data.refresh_token)
         setStatus('success')
         setTimeout(() => {
           // 管理员跳转到管理后台，普通用户跳转到个人中心
@@ -9,6 +9,7 @@ import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@
 import { useUser } from "@/contexts/user-context"
 import { useI18n } from "@/contexts/i18n-context"
 import { paymentApi, Order } from "@/lib/api"
+import { formatPrice } from "@/lib/utils"
 import {
   ShoppingCart,
   Search,
@@ -429,7 +430,7 @@ export default function OrdersPage() {
                         <div>
                           <p className="text-sm text-slate-500 dark:text-slate-400">订单金额</p>
                           <p className="text-lg font-bold text-slate-900 dark:text-slate-100">
-                            ¥{order.total_amount.toFixed(2)}
+                            {formatPrice(order.total)}
                           </p>
                         </div>
 
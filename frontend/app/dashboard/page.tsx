@@ -6,7 +6,7 @@ import { Button } from "@/components/ui/button"
 import { Card, CardContent, CardDescription, CardFooter, CardHeader, CardTitle } from "@/components/ui/card"
 import { useUser } from "@/contexts/user-context"
 import { useI18n } from "@/contexts/i18n-context"
-import { paymentApi, dashboardApi } from "@/lib/api"
+import { paymentApi, dashboardApi, authApi } from "@/lib/api"
 import {
   ShoppingCart,
   Download,
@@ -57,6 +57,7 @@ export default function DashboardPage() {
     quickActions: []
   })
   const [loading, setLoading] = useState(true)
+  const [resendMessage, setResendMessage] = useState('')
 
   useEffect(() => {
     const fetchDashboardData = async () => {
@@ -219,8 +220,30 @@ export default function DashboardPage() {
     )
   }
 
+  // 重新发送邮箱验证邮件，每分钟最多一次
+  const handleResendVerification = async () => {
+    try {
+      await authApi.resendVerification()
+      setResendMessage(t.auth.verificationSent || '验证邮件已发送，请查收')
+    } catch (err: any) {
+      setResendMessage(err.response?.status === 429
+        ? t.auth.errorTooSoon || '请稍候一分钟再重新发送'
+        : t.auth.errorLoginFailed || '操作失败，请重试')
+    }
+  }
+
   return (
     <div className="space-y-6">
+      {/* 邮箱未验证提示：验证后才能购买 */}
+      {user && !user.email_verified_at && (
+        <div className="flex flex-col md:flex-row md:items-center md:justify-between gap-3 rounded-lg border border-amber-200 bg-amber-50 p-4 text-sm text-amber-800">
+          <span>{resendMessage || t.auth.emailNotVerified || '您的邮箱尚未验证，验证后才能购买'}</span>
+          <Button variant="outline" size="sm" onClick={handleResendVerification}>
+            {t.auth.resendVerification || '重新发送验证邮件'}
+          </Button>
+        </div>
+      )}
+
       {/* 头部欢迎区域 */}
       <div className="rounded-2xl bg-gradient-to-r from-blue-500/10 via-purple-500/10 to-pink-500/10 p-6 border border-slate-200 dark:border-slate-800">
         <div className="flex flex-col md:flex-row md:items-center md:justify-between gap-4">
@@ -751,7 +751,7 @@ export default function SecurityPage() {
                 className="w-full text-red-600 dark:text-red-400 hover:text-red-700 dark:hover:text-red-300 hover:bg-red-50 dark:hover:bg-red-900/20"
                 onClick={() => {
                   if (confirm("确定要退出所有设备吗？这将在所有设备上退出登录。")) {
-                    logout()
+                    logout(true)
                   }
                 }}
               >
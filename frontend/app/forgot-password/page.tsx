@@ -0,0 +1,88 @@
+"use client"
+
+import { useState } from "react"
+import Link from "next/link"
+import { Button } from "@/components/ui/button"
+import { Input } from "@/components/ui/input"
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
+import { authApi } from "@/lib/api"
+import { useI18n } from "@/contexts/i18n-context"
+
+export default function ForgotPasswordPage() {
+  const { t, locale } = useI18n()
+  const [email, setEmail] = useState("")
+  const [loading, setLoading] = useState(false)
+  const [sent, setSent] = useState(false)
+  const [error, setError] = useState("")
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError("")
+    setLoading(true)
+
+    try {
+      await authApi.forgotPassword(email)
+      setSent(true)
+    } catch (err: any) {
+      setError(t.auth.errorLoginFailed || '操作失败，请重试')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  return (
+    <div className="flex items-center justify-center min-h-screen bg-slate-50 px-4">
+      <Card className="w-full max-w-md border-slate-200 bg-white">
+        <CardHeader className="space-y-1">
+          <CardTitle className="text-2xl font-bold text-center text-slate-900">
+            {t.auth.forgotPasswordTitle || '找回密码'}
+          </CardTitle>
+          <CardDescription className="text-center text-slate-600">
+            {t.auth.forgotPasswordSubtitle || '输入注册邮箱，我们会发送重置密码链接'}
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          {sent ? (
+            <div className="p-3 text-sm text-green-700 bg-green-50 border border-green-200 rounded-md">
+              {t.auth.resetLinkSent || '如果该邮箱已注册，重置链接已发送，请查收邮件'}
+            </div>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              {error && (
+                <div className="p-3 text-sm text-red-600 bg-red-50 border border-red-200 rounded-md">
+                  {error}
+                </div>
+              )}
+
+              <div className="space-y-2">
+                <label htmlFor="email" className="text-sm font-medium text-slate-700">
+                  {t.auth.emailAddress || '邮箱地址'}
+                </label>
+                <Input
+                  id="email"
+                  type="email"
+                  placeholder={t.auth.emailPlaceholder || 'your@email.com'}
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  required
+                  disabled={loading}
+                  className="border-slate-300"
+                />
+              </div>
+
+              <Button type="submit" className="w-full bg-gradient-to-r from-blue-600 to-purple-600 hover:from-blue-700 hover:to-purple-700 text-white" disabled={loading}>
+                {loading ? t.auth.processing || '处理中...' : t.auth.sendResetLink || '发送重置链接'}
+              </Button>
+            </form>
+          )}
+
+          <div className="mt-4 text-center text-sm">
+            <Link href={locale === 'zh' ? '/zh/login' : '/login'} className="text-blue-600 hover:underline font-medium">
+              {t.auth.backToLogin || '返回登录'}
+            </Link>
+          </div>
+        </CardContent>
+      </Card>
+    </div>
+  )
+}
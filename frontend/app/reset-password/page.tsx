@@ -0,0 +1,131 @@
+"use client"
+
+import { useState } from "react"
+import Link from "next/link"
+import { useSearchParams } from "next/navigation"
+import { Button } from "@/components/ui/button"
+import { Input } from "@/components/ui/input"
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
+import { authApi } from "@/lib/api"
+import { useI18n } from "@/contexts/i18n-context"
+import { useUser } from "@/contexts/user-context"
+
+export const dynamic = "force-dynamic"
+
+export default function ResetPasswordPage() {
+  const searchParams = useSearchParams()
+  const token = searchParams.get('token') || ''
+  const { t, locale } = useI18n()
+  const { clearSession } = useUser()
+  const [password, setPassword] = useState("")
+  const [confirmPassword, setConfirmPassword] = useState("")
+  const [loading, setLoading] = useState(false)
+  const [done, setDone] = useState(false)
+  const [error, setError] = useState(token ? "" : t.auth.errorInvalidLink || '链接无效或已过期，请重新获取')
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setError("")
+
+    if (password.length < 6) {
+      setError(t.auth.errorPasswordTooShort || '密码至少需要6个字符')
+      return
+    }
+    if (password !== confirmPassword) {
+      setError(t.auth.errorPasswordMismatch || '两次密码输入不一致')
+      return
+    }
+
+    setLoading(true)
+    try {
+      await authApi.resetPassword(token, password)
+      // 重置密码会撤销全部会话，本地保存的令牌已失效
+      clearSession()
+      setDone(true)
+    } catch (err: any) {
+      if (err.response?.status === 400) {
+        setError(t.auth.errorInvalidLink || '链接无效或已过期，请重新获取')
+      } else {
+        setError(t.auth.errorLoginFailed || '操作失败，请重试')
+      }
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const loginHref = locale === 'zh' ? '/zh/login' : '/login'
+
+  return (
+    <div className="flex items-center justify-center min-h-screen bg-slate-50 px-4">
+      <Card className="w-full max-w-md border-slate-200 bg-white">
+        <CardHeader className="space-y-1">
+          <CardTitle className="text-2xl font-bold text-center text-slate-900">
+            {t.auth.resetPasswordTitle || '重置密码'}
+          </CardTitle>
+        </CardHeader>
+        <CardContent>
+          {done ? (
+            <div className="space-y-4">
+              <div className="p-3 text-sm text-green-700 bg-green-50 border border-green-200 rounded-md">
+                {t.auth.resetPasswordSuccess || '密码已重置，请使用新密码登录'}
+              </div>
+              <Link href={loginHref}>
+                <Button className="w-full">{t.auth.login || '登录'}</Button>
+              </Link>
+            </div>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              {error && (
+                <div className="p-3 text-sm text-red-600 bg-red-50 border border-red-200 rounded-md">
+                  {error}
+                </div>
+              )}
+
+              <div className="space-y-2">
+                <label htmlFor="password" className="text-sm font-medium text-slate-700">
+                  {t.auth.newPassword || '新密码'}
+                </label>
+                <Input
+                  id="password"
+                  type="password"
+                  placeholder={t.auth.passwordMinLength || '至少6个字符'}
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  required
+                  disabled={loading || !token}
+                  className="border-slate-300"
+                />
+              </div>
+
+              <div className="space-y-2">
+                <label htmlFor="confirmPassword" className="text-sm font-medium text-slate-700">
+                  {t.auth.confirmPassword || '确认密码'}
+                </label>
+                <Input
+                  id="confirmPassword"
+                  type="password"
+                  placeholder={t.auth.confirmPasswordPlaceholder || '再次输入密码'}
+                  value={confirmPassword}
+                  onChange={(e) => setConfirmPassword(e.target.value)}
+                  required
+                  disabled={loading || !token}
+                  className="border-slate-300"
+                />
+              </div>
+
+              <Button type="submit" className="w-full bg-gradient-to-r from-blue-600 to-purple-600 hover:from-blue-700 hover:to-purple-700 text-white" disabled={loading || !token}>
+                {loading ? t.auth.processing || '处理中...' : t.auth.resetPassword || '重置密码'}
+              </Button>
+
+              <div className="text-center text-sm">
+                <Link href="/forgot-password" className="text-blue-600 hover:underline font-medium">
+                  {t.auth.forgotPasswordTitle || '找回密码'}
+                </Link>
+              </div>
+            </form>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  )
+}
@@ -9,6 +9,7 @@ import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs"
 import { Separator } from "@/components/ui/separator"
 import { Alert, AlertDescription } from "@/components/ui/alert"
 import { skillsApi, paymentApi, type Skill } from "@/lib/api"
+import { formatPrice } from "@/lib/utils"
 import { 
   Star, 
   GitFork, 
@@ -140,7 +141,7 @@ export default function SkillDetailPage() {
                 <span>({skill.stars_count})</span>
               </div>
               <Badge variant={isPaid ? "default" : "outline"} className={isPaid ? "bg-emerald-600" : ""}>
-                {isPaid ? `$${skill.price.toFixed(2)}` : t.home.free}
+                {isPaid ? formatPrice(skill.price) : t.home.free}
               </Badge>
             </div>
           </div>
@@ -256,7 +257,7 @@ export default function SkillDetailPage() {
                     <CardContent className="space-y-4">
                       <div className="text-center py-4">
                         <div className="text-4xl font-bold text-slate-900 mb-2">
-                          {isPaid ? `$${skill.price.toFixed(2)}` : t.home.free}
+                          {isPaid ? formatPrice(skill.price) : t.home.free}
                         </div>
                         <p className="text-sm text-slate-600">
                           {isPaid 
@@ -0,0 +1,100 @@
+"use client"
+
+import { useEffect, useRef, useState } from "react"
+import Link from "next/link"
+import { useSearchParams } from "next/navigation"
+import { Button } from "@/components/ui/button"
+import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card"
+import { authApi } from "@/lib/api"
+import { useI18n } from "@/contexts/i18n-context"
+import { useUser } from "@/contexts/user-context"
+
+export const dynamic = "force-dynamic"
+
+export default function VerifyEmailPage() {
+  const searchParams = useSearchParams()
+  const { t } = useI18n()
+  const { user, refreshUser } = useUser()
+  const [status, setStatus] = useState<'loading' | 'success' | 'error'>('loading')
+  const [resendMessage, setResendMessage] = useState('')
+  const started = useRef(false)
+
+  useEffect(() => {
+    // 令牌只能使用一次，避免开发模式下重复执行导致第二次请求失败
+    if (started.current) return
+    started.current = true
+
+    const token = searchParams.get('token')
+    if (!token) {
+      setStatus('error')
+      return
+    }
+    authApi.verifyEmail(token)
+      .then(() => {
+        setStatus('success')
+        if (localStorage.getItem('token')) {
+          refreshUser()
+        }
+      })
+      .catch(() => setStatus('error'))
+  }, [searchParams, refreshUser])
+
+  const handleResend = async () => {
+    try {
+      await authApi.resendVerification()
+      setResendMessage(t.auth.verificationSent || '验证邮件已发送，请查收')
+    } catch (err: any) {
+      if (err.response?.status === 429) {
+        setResendMessage(t.auth.errorTooSoon || '请稍候一分钟再重新发送')
+      } else {
+        setResendMessage(t.auth.errorLoginFailed || '操作失败，请重试')
+      }
+    }
+  }
+
+  return (
+    <div className="flex items-center justify-center min-h-screen bg-slate-50 px-4">
+      <Card className="w-full max-w-md border-slate-200 bg-white">
+        <CardHeader className="space-y-1">
+          <CardTitle className="text-2xl font-bold text-center text-slate-900">
+            {t.auth.verifyEmailTitle || '验证邮箱'}
+          </CardTitle>
+        </CardHeader>
+        <CardContent className="space-y-4">
+          {status === 'loading' && (
+            <p className="text-center text-slate-600">{t.auth.verifyingEmail || '正在验证邮箱...'}</p>
+          )}
+
+          {status === 'success' && (
+            <>
+              <div className="p-3 text-sm text-green-700 bg-green-50 border border-green-200 rounded-md">
+                {t.auth.emailVerified || '邮箱验证成功，现在可以购买技能了'}
+              </div>
+              <Link href={user ? '/dashboard' : '/login'}>
+                <Button className="w-full">{user ? t.dashboard.nav.dashboard : t.auth.login || '登录'}</Button>
+              </Link>
+            </>
+          )}
+
+          {status === 'error' && (
+            <>
+              <div className="p-3 text-sm text-red-600 bg-red-50 border border-red-200 rounded-md">
+                {t.auth.errorInvalidLink || '链接无效或已过期，请重新获取'}
+              </div>
+              {user ? (
+                <Button className="w-full" variant="outline" onClick={handleResend}>
+                  {t.auth.resendVerification || '重新发送验证邮件'}
+                </Button>
+              ) : (
+                <Link href="/login">
+                  <Button className="w-full">{t.auth.login || '登录'}</Button>
+                </Link>
+              )}
+              {resendMessage && <p className="text-center text-sm text-slate-600">{resendMessage}</p>}
+            </>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  )
+}
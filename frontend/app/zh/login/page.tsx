@@ -25,7 +25,7 @@ export default function LoginPage() {
 
     try {
       const response = await authApi.login(email, password)
-      login(response.user, response.token)
+      login(response.user, response.token, response.refresh_token)
       
        // 管理员跳转到管理后台，普通用户跳转到个人中心
       if (response.user.role === 'admin') {
@@ -196,9 +196,14 @@ export default function LoginPage() {
             </div>
 
             <div className="space-y-2">
-              <label htmlFor="password" className="text-sm font-medium text-slate-700">
-                密码
-              </label>
+              <div className="flex items-center justify-between">
+                <label htmlFor="password" className="text-sm font-medium text-slate-700">
+                  密码
+                </label>
+                <Link href="/forgot-password" className="text-sm text-blue-600 hover:underline">
+                  忘记密码？
+                </Link>
+              </div>
               <Input
                 id="password"
                 type="password"
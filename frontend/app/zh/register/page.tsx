@@ -38,7 +38,7 @@ export default function RegisterPage() {
 
     try {
       const response = await authApi.register(email, password, name)
-      login(response.user, response.token)
+      login(response.user, response.token, response.refresh_token)
       
        // 新注册用户跳转到个人中心
       router.push('/dashboard')
@@ -4,6 +4,8 @@ import { Badge } from "@/components/ui/badge"
 import { Button } from "@/components/ui/button"
 import { Star, GitFork, Download, DollarSign, Cpu, Zap } from "lucide-react"
 import { useI18n } from "@/contexts/i18n-context"
+import type { Money } from "@/lib/api"
+import { formatPrice } from "@/lib/utils"
 
 interface SkillCardProps {
   id: string
@@ -12,7 +14,7 @@ interface SkillCardProps {
   stars: number
   forks: number
   downloads: number
-  price: number
+  price: Money
   priceType: string
 }
 
@@ -56,8 +58,7 @@ export function SkillCard({
                 </Badge>
               ) : (
                 <Badge className="bg-gradient-to-r from-blue-100/50 to-purple-100/50 text-blue-700 border-blue-300 hover:from-blue-100 hover:to-purple-100">
-                  <DollarSign className="h-3 w-3 mr-1" />
-                  {price}
+                  {formatPrice(price)}
                 </Badge>
               )}
             </div>
@@ -95,6 +95,24 @@ interface Translations {
      register: string
      name: string
      namePlaceholder: string
+     forgotPassword: string
+     forgotPasswordTitle: string
+     forgotPasswordSubtitle: string
+     sendResetLink: string
+     resetLinkSent: string
+     resetPasswordTitle: string
+     newPassword: string
+     resetPassword: string
+     resetPasswordSuccess: string
+     verifyEmailTitle: string
+     verifyingEmail: string
+     emailVerified: string
+     emailNotVerified: string
+     resendVerification: string
+     verificationSent: string
+     errorInvalidLink: string
+     errorTooSoon: string
+     backToLogin: string
    }
   features: {
     quickIntegration: {
@@ -312,6 +330,24 @@ const translations: Record<Locale, Translations> = {
        register: "Register",
        name: "Name",
        namePlaceholder: "Your full name",
+       forgotPassword: "Forgot password?",
+       forgotPasswordTitle: "Forgot Password",
+       forgotPasswordSubtitle: "Enter your email and we will send you a link to reset your password",
+       sendResetLink: "Send Reset Link",
+       resetLinkSent: "If the email is registered, a reset link has been sent. Please check your inbox.",
+       resetPasswordTitle: "Reset Password",
+       newPassword: "New Password",
+       resetPassword: "Reset Password",
+       resetPasswordSuccess: "Your password has been reset. Please log in with your new password.",
+       verifyEmailTitle: "Verify Email",
+       verifyingEmail: "Verifying your email...",
+       emailVerified: "Your email has been verified. You can now make purchases.",
+       emailNotVerified: "Your email is not verified yet. Verify it to make purchases.",
+       resendVerification: "Resend verification email",
+       verificationSent: "Verification email sent, please check your inbox",
+       errorInvalidLink: "This link is invalid or has expired, please request a new one",
+       errorTooSoon: "Please wait a minute before requesting another email",
+       backToLogin: "Back to login",
     },
     features: {
       quickIntegration: {
@@ -527,6 +563,24 @@ const translations: Record<Locale, Translations> = {
        register: "注册",
        name: "姓名",
        namePlaceholder: "请输入您的全名",
+       forgotPassword: "忘记密码？",
+       forgotPasswordTitle: "找回密码",
+       forgotPasswordSubtitle: "输入注册邮箱，我们会发送重置密码链接",
+       sendResetLink: "发送重置链接",
+       resetLinkSent: "如果该邮箱已注册，重置链接已发送，请查收邮件",
+       resetPasswordTitle: "重置密码",
+       newPassword: "新密码",
+       resetPassword: "重置密码",
+       resetPasswordSuccess: "密码已重置，请使用新密码登录",
+       verifyEmailTitle: "验证邮箱",
+       verifyingEmail: "正在验证邮箱...",
+       emailVerified: "邮箱验证成功，现在可以购买技能了",
+       emailNotVerified: "您的邮箱尚未验证，验证后才能购买",
+       resendVerification: "重新发送验证邮件",
+       verificationSent: "验证邮件已发送，请查收",
+       errorInvalidLink: "链接无效或已过期，请重新获取",
+       errorTooSoon: "请稍候一分钟再重新发送",
+       backToLogin: "返回登录",
     },
     features: {
       quickIntegration: {
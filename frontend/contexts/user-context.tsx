@@ -6,8 +6,9 @@ import { User } from "@/lib/api"
 interface UserContextType {
   user: User | null
   loading: boolean
-  login: (user: User, token: string) => void
-  logout: () => void
+  login: (user: User, token: string, refreshToken?: string) => void
+  logout: (allDevices?: boolean) => void
+  clearSession: () => void // 只清除本地登录状态（服务端会话已撤销时使用）
   refreshUser: () => Promise<void>
 }
 
@@ -56,18 +57,34 @@ export function UserProvider({ children }: { children: ReactNode }) {
     return () => window.removeEventListener('storage', handleStorageChange)
   }, [])
 
-  const login = (user: User, token: string) => {
+  const login = (user: User, token: string, refreshToken?: string) => {
     localStorage.setItem('token', token)
+    if (refreshToken) {
+      localStorage.setItem('refresh_token', refreshToken)
+    }
     localStorage.setItem('user', JSON.stringify(user))
     setUser(user)
   }
 
-  const logout = () => {
+  const clearSession = () => {
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
     localStorage.removeItem('user')
     setUser(null)
   }
 
+  // 退出登录时撤销服务端会话，请求失败也清除本地登录状态
+  const logout = (allDevices = false) => {
+    if (localStorage.getItem('token') || localStorage.getItem('refresh_token')) {
+      import('@/lib/api')
+        .then(({ authApi }) => authApi.logout(allDevices))
+        .catch((error) => console.error('Failed to revoke session:', error))
+        .finally(clearSession)
+      return
+    }
+    clearSession()
+  }
+
   const refreshUser = async () => {
     try {
       const { authApi } = await import('@/lib/api')
@@ -76,12 +93,12 @@ export function UserProvider({ children }: { children: ReactNode }) {
       setUser(userData)
     } catch (error) {
       console.error('Failed to refresh user:', error)
-      logout()
+      clearSession()
     }
   }
 
   return (
-    <UserContext.Provider value={{ user, loading, login, logout, refreshUser }}>
+    <UserContext.Provider value={{ user, loading, login, logout, clearSession, refreshUser }}>
       {children}
     </UserContext.Provider>
   )
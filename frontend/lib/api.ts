@@ -23,29 +23,67 @@ api.interceptors.request.use(
   }
 )
 
+// 刷新访问令牌：并发的401请求共用同一次刷新，刷新令牌只能使用一次
+let refreshing: Promise<string | null> | null = null
+
+const refreshAccessToken = () => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshing = (refreshToken
+      ? axios
+          .post<LoginResponse>(`${API_BASE_URL}/auth/refresh`, { refresh_token: refreshToken })
+          .then(({ data }) => {
+            localStorage.setItem('token', data.token)
+            localStorage.setItem('refresh_token', data.refresh_token)
+            return data.token
+          })
+          .catch(() => null)
+      : Promise.resolve(null)
+    ).finally(() => {
+      refreshing = null
+    })
+  }
+  return refreshing
+}
+
 // 响应拦截器 - 处理错误
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
-      // 清除 token 并跳转登录
-      if (typeof window !== 'undefined') {
-        localStorage.removeItem('token')
-        window.location.href = '/login'
+  async (error) => {
+    const original = error.config
+    if (error.response?.status === 401 && typeof window !== 'undefined') {
+      // 访问令牌过期时用刷新令牌换发后重试一次
+      if (original && !original._retried && !original.url?.startsWith('/auth/refresh')) {
+        original._retried = true
+        const token = await refreshAccessToken()
+        if (token) {
+          original.headers.Authorization = `Bearer ${token}`
+          return api(original)
+        }
       }
+      // 清除 token 并跳转登录
+      localStorage.removeItem('token')
+      localStorage.removeItem('refresh_token')
+      window.location.href = '/login'
     }
     return Promise.reject(error)
   }
 )
 
+// 金额：amount为十进制字符串，小数位数由币种决定
+export interface Money {
+  amount: string
+  currency: string
+}
+
 export interface Skill {
   id: string
   name: string
   description: string
   github_url: string
   category_id: string | null
-  price_type: 'free' | 'paid'
-  price: number
+  price_type: 'free' | 'paid' | 'subscription'
+  price: Money
   downloads_count: number
   purchases_count: number
   rating: number
@@ -101,13 +139,17 @@ export interface User {
   username?: string
   role: string
   is_active: boolean
+  email_verified_at?: string // 为空表示邮箱未验证，不能下单购买
   created_at: string
   updated_at: string
   profile?: UserProfile
 }
 
 export interface LoginResponse {
-  token: string
+  token: string // 访问令牌，有效期较短
+  expires_at: string
+  refresh_token: string // 只能使用一次，刷新后换发新令牌
+  refresh_expires_at: string
   user: User
 }
 
@@ -115,17 +157,142 @@ export interface Order {
   id: string
   order_no: string
   user_id: string
-  total_amount: number
+  total: Money
+  discount?: Money
+  refunded?: Money
   payment_method: string
   status: string
   created_at: string
   paid_at: string | null
   user_email?: string
   user?: User
+  items?: OrderItem[]
+  subscription_id?: string
+}
+
+export interface OrderItem {
+  id: string
+  skill_id?: string
+  price: Money
+  quantity: number
+  skill?: Skill
+}
+
+export interface CartItem {
+  id: string
+  skill_id: string
+  created_at: string
+  skill?: Skill
+}
+
+export interface SubscriptionPlan {
+  id: string
+  skill_id: string
+  interval: 'month' | 'year'
+  price: Money
+  is_active: boolean
+}
+
+export interface Subscription {
+  id: string
+  skill_id: string
+  plan_id: string
+  status: 'incomplete' | 'active' | 'past_due' | 'cancelled' | 'expired'
+  provider: string
+  auto_renew: boolean
+  current_period_start?: string
+  current_period_end?: string
+  cancelled_at?: string
+  created_at: string
+  plan?: SubscriptionPlan
+  skill?: Skill
+}
+
+export interface InvoiceParty {
+  name: string
+  email?: string
+  tax_id?: string
+  address?: string
+  phone?: string
+  bank_name?: string
+  bank_account?: string
+}
+
+export interface BillingProfile {
+  party: InvoiceParty
+  title_type: 'personal' | 'company'
+  fapiao_title?: string
+}
+
+export interface InvoiceLine {
+  line_no: number
+  skill_id?: string
+  description: string
+  quantity: number
+  unit_price: Money
+  discount: Money
+  amount: Money
+}
+
+export interface Invoice {
+  id: string
+  invoice_no: string
+  order_id: string
+  order_no: string
+  status: 'issued' | 'void'
+  buyer: InvoiceParty
+  title_type: 'personal' | 'company'
+  fapiao_title: string
+  seller: InvoiceParty
+  subtotal: Money
+  discount: Money
+  total: Money
+  payment_method: string
+  paid_at?: string
+  issued_at: string
+  voided_at?: string
+  void_reason?: string
+  lines?: InvoiceLine[]
+}
+
+export interface PublisherBalance {
+  currency: string
+  pending: Money
+  available: Money
+  in_payout: Money
+  paid_out: Money
+}
+
+export interface PublisherEarning {
+  id: string
+  kind: 'sale' | 'clawback'
+  order_id: string
+  order_item_id: string
+  skill_id: string
+  refund_id?: string
+  gross: Money
+  commission: Money
+  amount: Money
+  rate_bps: number
+  available_at: string
+  payout_id?: string
+  created_at: string
+  skill?: Skill
+}
+
+export interface Payout {
+  id: string
+  batch_id: string
+  amount: Money
+  status: 'pending' | 'paid' | 'failed'
+  entries: number
+  reference?: string
+  failure?: string
+  created_at: string
 }
 
 export interface Analytics {
-  total_revenue: number
+  total_revenue: Money[]
   total_orders: number
   total_users: number
   total_skills: number
@@ -189,8 +356,21 @@ export const authApi = {
     return response.data
   },
 
+  // 获取第三方登录授权地址；浏览器绑定值保存在sessionStorage，回调页换取令牌时带回
   getOAuthUrl: async (provider: string) => {
-    const response = await api.get<{ url: string }>(`/auth/oauth/${provider}`)
+    const response = await api.get<{ auth_url: string; binding: string; provider: string }>(`/auth/oauth/${provider}`)
+    sessionStorage.setItem('oauth_binding', response.data.binding)
+    return { url: response.data.auth_url, provider: response.data.provider }
+  },
+
+  // OAuth回调：校验state并用授权码换取令牌，state只能使用一次
+  oauthCallback: async (provider: string, code: string, state: string) => {
+    const binding = sessionStorage.getItem('oauth_binding') || ''
+    sessionStorage.removeItem('oauth_binding')
+    const response = await api.get<LoginResponse>(`/auth/callback/${provider}`, {
+      params: { code, state },
+      headers: { 'X-OAuth-Binding': binding },
+    })
     return response.data
   },
 
@@ -199,6 +379,35 @@ export const authApi = {
     return response.data
   },
 
+  // 邮箱验证：token来自验证邮件中的链接
+  verifyEmail: async (token: string) => {
+    const response = await api.post('/auth/verify-email', { token })
+    return response.data
+  },
+
+  resendVerification: async () => {
+    const response = await api.post('/auth/resend-verification')
+    return response.data
+  },
+
+  // 找回密码：无论邮箱是否注册都返回成功
+  forgotPassword: async (email: string) => {
+    const response = await api.post('/auth/forgot-password', { email })
+    return response.data
+  },
+
+  // 重置密码：成功后全部会话被撤销，需要重新登录
+  resetPassword: async (token: string, newPassword: string) => {
+    const response = await api.post('/auth/reset-password', { token, new_password: newPassword })
+    return response.data
+  },
+
+  // 退出登录：撤销当前会话，all为true时退出所有设备
+  logout: async (all = false) => {
+    const refreshToken = localStorage.getItem('refresh_token')
+    await api.post('/auth/logout', { refresh_token: refreshToken || undefined, all })
+  },
+
   updateProfile: async (profileData: {
     name?: string
     username?: string
@@ -215,11 +424,17 @@ export const authApi = {
     return response.data
   },
 
+  // 修改密码会撤销所有会话，响应返回当前设备的新令牌
   changePassword: async (currentPassword: string, newPassword: string) => {
-    const response = await api.put<{ message: string }>('/auth/password', {
+    const response = await api.put<ApiResponse<LoginResponse>>('/auth/password', {
       current_password: currentPassword,
       new_password: newPassword,
     })
+    const tokens = response.data.data
+    if (tokens?.token) {
+      localStorage.setItem('token', tokens.token)
+      localStorage.setItem('refresh_token', tokens.refresh_token)
+    }
     return response.data
   },
 
@@ -289,6 +504,13 @@ export const authApi = {
   },
 }
 
+// PaymentProvider 可选支付方式
+export interface PaymentProvider {
+  type: string
+  currencies: string[] // 为空表示不限制币种
+  flows: string[]
+}
+
 // Payment API
 export const paymentApi = {
   createOrder: async (skillId: string) => {
@@ -296,9 +518,16 @@ export const paymentApi = {
     return response.data
   },
 
-  getPaymentUrl: async (orderId: string) => {
+  getProviders: async () => {
+    const response = await api.get<ApiResponse<PaymentProvider[]>>('/payment/providers')
+    return response.data
+  },
+
+  getPaymentUrl: async (orderId: string, paymentType?: string) => {
     const response = await api.post<ApiResponse<{ payment_url: string; order_id: string; order_no: string }>>(
-      `/payment/orders/${orderId}/pay`
+      `/payment/orders/${orderId}/pay`,
+      undefined,
+      { params: paymentType ? { payment_type: paymentType } : undefined }
     )
     return response.data
   },
@@ -323,6 +552,114 @@ export const paymentApi = {
   },
 }
 
+// Cart API
+export const cartApi = {
+  getCart: async (params?: { region?: string; currency?: string; coupon_code?: string }) => {
+    const response = await api.get<ApiResponse<{ items: CartItem[]; total?: Money; discount?: Money; error?: string }>>(
+      '/cart',
+      { params }
+    )
+    return response.data
+  },
+
+  addItem: async (skillId: string) => {
+    const response = await api.post<ApiResponse<CartItem>>('/cart/items', { skill_id: skillId })
+    return response.data
+  },
+
+  removeItem: async (skillId: string) => {
+    const response = await api.delete<ApiResponse<null>>(`/cart/items/${skillId}`)
+    return response.data
+  },
+
+  checkout: async (options?: {
+    skill_ids?: string[]
+    region?: string
+    currency?: string
+    payment_type?: string
+    coupon_code?: string
+  }) => {
+    const response = await api.post<ApiResponse<{ order: Order; payment_url?: string }>>('/cart/checkout', options || {})
+    return response.data
+  },
+}
+
+// Subscriptions API
+export const subscriptionsApi = {
+  getPlans: async (skillId: string) => {
+    const response = await api.get<ApiResponse<SubscriptionPlan[]>>(`/skills/${skillId}/plans`)
+    return response.data
+  },
+
+  list: async () => {
+    const response = await api.get<ApiResponse<Subscription[]>>('/subscriptions')
+    return response.data
+  },
+
+  subscribe: async (planId: string, options?: { region?: string; payment_type?: string }) => {
+    const response = await api.post<ApiResponse<{ subscription: Subscription; order: Order; payment_url: string }>>(
+      '/subscriptions',
+      { plan_id: planId, ...options }
+    )
+    return response.data
+  },
+
+  cancel: async (id: string) => {
+    const response = await api.post<ApiResponse<Subscription>>(`/subscriptions/${id}/cancel`)
+    return response.data
+  },
+}
+
+// Invoices API
+export const invoicesApi = {
+  get: async (orderId: string) => {
+    const response = await api.get<ApiResponse<Invoice>>(`/payment/orders/${orderId}/invoice`)
+    return response.data
+  },
+
+  download: async (orderId: string, format: 'html' | 'pdf' = 'pdf') => {
+    const response = await api.get<Blob>(`/payment/orders/${orderId}/invoice`, {
+      params: { format },
+      responseType: 'blob',
+    })
+    return response.data
+  },
+
+  getBillingProfile: async () => {
+    const response = await api.get<ApiResponse<BillingProfile | null>>('/payment/billing-profile')
+    return response.data
+  },
+
+  updateBillingProfile: async (profile: BillingProfile) => {
+    const response = await api.put<ApiResponse<BillingProfile>>('/payment/billing-profile', profile)
+    return response.data
+  },
+}
+
+// Publisher API
+export const publisherApi = {
+  getBalance: async () => {
+    const response = await api.get<ApiResponse<PublisherBalance[]>>('/publisher/balance')
+    return response.data
+  },
+
+  getEarnings: async (params?: { page?: number; page_size?: number; kind?: 'sale' | 'clawback' }) => {
+    const response = await api.get<ApiResponse<{ list: PublisherEarning[]; total: number; page: number; page_size: number }>>(
+      '/publisher/earnings',
+      { params }
+    )
+    return response.data
+  },
+
+  getPayouts: async (params?: { page?: number; page_size?: number }) => {
+    const response = await api.get<ApiResponse<{ list: Payout[]; total: number; page: number; page_size: number }>>(
+      '/publisher/payouts',
+      { params }
+    )
+    return response.data
+  },
+}
+
 // Dashboard API
 export interface UserDashboardStats {
   total_orders: number
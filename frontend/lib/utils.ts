@@ -1,6 +1,28 @@
 import { clsx, type ClassValue } from "clsx"
 import { twMerge } from "tailwind-merge"
+import type { Money } from "@/lib/api"
 
 export function cn(...inputs: ClassValue[]) {
   return twMerge(clsx(inputs))
 }
+
+// 金额由后端以十进制字符串下发（如 {"amount":"19.99","currency":"USD"}），仅在展示时转为数字
+export function formatPrice(price?: Money | null) {
+  if (!price) {
+    return ""
+  }
+  const currency = price.currency || "CNY"
+  try {
+    return new Intl.NumberFormat(undefined, { style: "currency", currency }).format(Number(price.amount))
+  } catch {
+    return `${currency} ${price.amount}`
+  }
+}
+
+// 按币种汇总的金额（如收入统计）逐项展示
+export function formatPrices(prices?: Money[] | null) {
+  if (!prices || prices.length === 0) {
+    return formatPrice({ amount: "0", currency: "CNY" })
+  }
+  return prices.map((p) => formatPrice(p)).join(" + ")
+}